@@ -3,16 +3,19 @@ module github.com/Bedrock-OSS/regolith
 go 1.18
 
 require (
+	github.com/aws/aws-sdk-go v1.43.25
 	github.com/denisbrodbeck/machineid v1.0.1
 	github.com/fatih/color v1.13.0
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/google/go-github/v39 v39.2.0
 	github.com/hashicorp/go-getter v1.5.11
 	github.com/otiai10/copy v1.7.0
 	github.com/urfave/cli/v2 v2.4.0
+	github.com/yuin/gopher-lua v0.0.0-20220504180219-658193537a64
 	go.uber.org/zap v1.21.0
 	golang.org/x/mod v0.5.1
-	golang.org/x/sys v0.0.0-20220319134239-a9b59b0215f8
-	muzzammil.xyz/jsonc v1.0.0
+	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad
+	golang.org/x/text v0.3.7
 )
 
 require (
@@ -20,7 +23,6 @@ require (
 	cloud.google.com/go/compute v1.5.0 // indirect
 	cloud.google.com/go/iam v0.3.0 // indirect
 	cloud.google.com/go/storage v1.21.0 // indirect
-	github.com/aws/aws-sdk-go v1.43.25 // indirect
 	github.com/bgentry/go-netrc v0.0.0-20140422174119-9fd32a8b3d3d // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.1 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
@@ -45,7 +47,6 @@ require (
 	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064 // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
 	golang.org/x/oauth2 v0.0.0-20220309155454-6242fa91716a // indirect
-	golang.org/x/text v0.3.7 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/api v0.73.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/fatih/color"
 
@@ -18,8 +19,15 @@ var (
 )
 
 func main() {
+	// The proxy must be set before the first network access
+	regolith.ApplyUserProxy()
+	// The output of the completions is read by the shell, so it can't
+	// contain the messages printed after the command
+	scriptOutput := os.Args[len(os.Args)-1] == "--generate-bash-completion"
 	status := make(chan regolith.UpdateStatus)
-	go regolith.CheckUpdate(version, status)
+	if !scriptOutput {
+		go regolith.CheckUpdate(version, status)
+	}
 	regolith.CustomHelp()
 	err := (&cli.App{
 		Name:                 "Regolith",
@@ -40,19 +48,106 @@ func main() {
 				Usage:       "Enables debugging.",
 				Destination: &regolith.Debug,
 			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "Prints the debug messages.",
+			},
+			&cli.BoolFlag{
+				Name:  "vv",
+				Usage: "Prints the debug messages and every file copied, moved or removed by Regolith.",
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "Prints only the warnings and the errors.",
+			},
+			&cli.StringFlag{
+				Name:        "log-format",
+				Value:       "text",
+				Usage:       "Sets the format of the messages: \"text\", \"json\" (one JSON object per line, for the tools that read the output of Regolith) or \"github\" (text with the annotations and the step summaries of GitHub Actions).",
+				Destination: &regolith.LogFormat,
+			},
+			&cli.StringFlag{
+				Name:        "events",
+				Usage:       "Sends the events of the runs as JSON objects, one per line, for the editors and other tools. The target is \"fd:<number>\" (an inherited file descriptor), \"unix:<path>\" or \"tcp:<host>:<port>\" (a socket), or a path to a file.",
+				Destination: &regolith.EventsOutput,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			switch regolith.LogFormat {
+			case "text", "json", "github":
+			default:
+				return regolith.WrappedErrorf(
+					"Unknown log format %q, use \"text\", \"json\" or \"github\".",
+					regolith.LogFormat)
+			}
+			switch {
+			case c.Bool("quiet"):
+				if c.Bool("verbose") || c.Bool("vv") {
+					return regolith.WrappedError(
+						"The \"--quiet\" flag can't be used with \"-v\" and \"-vv\".")
+				}
+				regolith.Verbosity = -1
+			case c.Bool("vv"):
+				regolith.Verbosity = 2
+			case c.Bool("verbose"):
+				regolith.Verbosity = 1
+			}
+			return regolith.OpenEventStream()
 		},
 		Commands: []*cli.Command{
 			{
-				Name:  "run",
-				Usage: "Runs Regolith, and generates compiled RP and BP, which will be exported to the destination specified in the config.",
+				Name:         "run",
+				Usage:        "Runs Regolith, and generates compiled RP and BP, which will be exported to the destination specified in the config.",
+				BashComplete: regolith.CompleteProfiles,
 				Action: func(c *cli.Context) error {
 					args := c.Args().Slice()
 					recycled := c.Bool("recycled")
+					regolith.Timings = regolith.Timings || regolith.TimingsOutput != ""
 					var profile string
 					if len(args) != 0 {
 						profile = args[0]
 					}
-					return regolith.Run(profile, recycled, regolith.Debug)
+					filters := c.StringSlice("filter")
+					noExport := c.Bool("no-export")
+					paths := regolith.RunPaths{
+						Rp:  c.String("rp"),
+						Bp:  c.String("bp"),
+						Out: c.String("out"),
+					}
+					customPaths := paths != regolith.RunPaths{}
+					if c.Bool("all") || c.String("project") != "" {
+						if c.Bool("all") && c.String("project") != "" {
+							return regolith.WrappedError(
+								"The \"--all\" and \"--project\" flags can't be used together.")
+						}
+						if len(filters) != 0 || noExport || customPaths || regolith.JunitOutput != "" || regolith.SarifOutput != "" {
+							return regolith.WrappedError(
+								"The \"--filter\", \"--no-export\", \"--rp\", \"--bp\", \"--out\", \"--junit\" and \"--sarif\" flags can't be used with \"--all\" and \"--project\".")
+						}
+						return regolith.RunWorkspace(
+							c.String("project"), profile,
+							c.StringSlice("define"), recycled,
+							c.Bool("dry-run"), regolith.Debug)
+					}
+					if c.Bool("dry-run") {
+						if customPaths {
+							return regolith.WrappedError(
+								"The \"--rp\", \"--bp\" and \"--out\" flags can't be used with \"--dry-run\".")
+						}
+						return regolith.DryRun(
+							profile, c.StringSlice("define"), filters,
+							noExport, regolith.Debug)
+					}
+					if len(filters) != 0 || noExport || customPaths {
+						return regolith.RunFilters(
+							profile, filters, c.StringSlice("define"),
+							recycled, noExport, paths, regolith.Debug)
+					}
+					return regolith.Run(
+						profile, c.StringSlice("define"), recycled,
+						regolith.Debug)
 				},
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
@@ -60,19 +155,85 @@ func main() {
 						Aliases: []string{"r"},
 						Usage:   "Uses different \"recycled\" function for moving files, might be faster in some cases. Not recommended.",
 					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Checks the filters and prints the execution plan and the export paths, without running the filters or exporting the files.",
+					},
+					&cli.StringSliceFlag{
+						Name:    "define",
+						Aliases: []string{"D"},
+						Usage:   "Sets a value (in the \"name=value\" format) that can be used in the \"when\" expressions of the filters.",
+					},
+					&cli.StringSliceFlag{
+						Name:  "filter",
+						Usage: "Runs only the filters of the profile with these IDs (a comma-separated list), skipping the rest of the filters.",
+					},
+					&cli.BoolFlag{
+						Name:  "no-export",
+						Usage: "Leaves the results in the \".regolith/tmp\" folder instead of exporting them.",
+					},
+					&cli.StringFlag{
+						Name:  "rp",
+						Usage: "Uses the resource pack from this path instead of the one from the config.",
+					},
+					&cli.StringFlag{
+						Name:  "bp",
+						Usage: "Uses the behavior pack from this path instead of the one from the config.",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "Exports the packs to the \"RP\" and \"BP\" folders in this path instead of the export target of the profile.",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Runs the profile in all of the projects of the workspace from the \"regolith-workspace.json\" file, in the dependency order.",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "Runs the profile in the project of the workspace with this name and in the projects it depends on.",
+					},
+					&cli.BoolFlag{
+						Name:        "timings",
+						Usage:       "Prints the durations of setting up the files, running the filters and exporting the packs after each run.",
+						Destination: &regolith.Timings,
+					},
+					&cli.StringFlag{
+						Name:        "timings-output",
+						Usage:       "Saves the timings to a file, in the JSON format if the file has the \".json\" extension, or in the folded stacks format for flame graphs otherwise. Enables \"--timings\".",
+						Destination: &regolith.TimingsOutput,
+					},
+					&cli.StringFlag{
+						Name:        "junit",
+						Usage:       "Saves the results of the filters to a JUnit XML file, with every execution of a filter as a test case, for the CI systems.",
+						Destination: &regolith.JunitOutput,
+					},
+					&cli.StringFlag{
+						Name:        "sarif",
+						Usage:       "Saves the diagnostics reported by the filters to a SARIF file, for GitHub code scanning and other tools that read the SARIF format.",
+						Destination: &regolith.SarifOutput,
+					},
+					&cli.BoolFlag{
+						Name:        "reproducible",
+						Usage:       "Exports the packs with fixed modification times (from the SOURCE_DATE_EPOCH environment variable or 1980-01-01), so the same project always produces byte-identical files and archives.",
+						Destination: &regolith.Reproducible,
+					},
 				},
 			},
 			{
-				Name:  "watch",
-				Usage: "Watches the project files and runs specified Regolith profile when they change.",
+				Name:         "watch",
+				Usage:        "Watches the project files and runs specified Regolith profile when they change.",
+				BashComplete: regolith.CompleteProfiles,
 				Action: func(c *cli.Context) error {
 					args := c.Args().Slice()
 					recycled := c.Bool("recycled")
+					regolith.Timings = regolith.Timings || regolith.TimingsOutput != ""
 					var profile string
 					if len(args) != 0 {
 						profile = args[0]
 					}
-					return regolith.Watch(profile, recycled, regolith.Debug)
+					return regolith.Watch(
+						profile, c.StringSlice("define"), recycled,
+						regolith.Debug, c.Int("reload-port"))
 				},
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
@@ -80,6 +241,30 @@ func main() {
 						Aliases: []string{"r"},
 						Usage:   "Uses different \"recycled\" function for moving files, might be faster in some cases. Not recommended.",
 					},
+					&cli.StringSliceFlag{
+						Name:    "define",
+						Aliases: []string{"D"},
+						Usage:   "Sets a value (in the \"name=value\" format) that can be used in the \"when\" expressions of the filters.",
+					},
+					&cli.IntFlag{
+						Name:  "reload-port",
+						Usage: "Starts a WebSocket server on this port. When Minecraft is connected to it with the \"/connect localhost:<port>\" command, Regolith runs \"/reload\" in the game after each successful export.",
+					},
+					&cli.BoolFlag{
+						Name:        "timings",
+						Usage:       "Prints the durations of setting up the files, running the filters and exporting the packs after each run.",
+						Destination: &regolith.Timings,
+					},
+					&cli.StringFlag{
+						Name:        "timings-output",
+						Usage:       "Saves the timings to a file, in the JSON format if the file has the \".json\" extension, or in the folded stacks format for flame graphs otherwise. Enables \"--timings\".",
+						Destination: &regolith.TimingsOutput,
+					},
+					&cli.BoolFlag{
+						Name:        "reproducible",
+						Usage:       "Exports the packs with fixed modification times (from the SOURCE_DATE_EPOCH environment variable or 1980-01-01), so the same project always produces byte-identical files and archives.",
+						Destination: &regolith.Reproducible,
+					},
 				},
 			},
 			{
@@ -87,9 +272,117 @@ func main() {
 				Usage: `It updates filters listed in "filters" parameter. The
 				names of the filters must be already present in the
 				filtersDefinitions list in the config.json file.`,
+				BashComplete: regolith.CompleteFilters,
 				Action: func(c *cli.Context) error {
+					if c.Bool("interactive") {
+						return regolith.UpdateInteractive(
+							c.Args().Slice(), regolith.Debug)
+					}
 					return regolith.Update(c.Args().Slice(), regolith.Debug)
 				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "interactive",
+						Aliases: []string{"i"},
+						Usage:   "Lists the filters with newer versions and their changelogs, and asks which of them should be updated. Checks all of the filters if no filters are specified.",
+					},
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "Upgrades the config.json file from an older format version to the format used by this version of Regolith and prints the changes.",
+				Action: func(c *cli.Context) error {
+					return regolith.Migrate(c.Bool("dry-run"), regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Prints the changes without saving the config file.",
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Reads and changes the values of the config.json file. The paths use dots (\"profiles.default.export.target\") or the JSON Pointer syntax (\"/regolith/profiles/default/export/target\").",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "get",
+						Usage:     "Prints a value of the config, with the local config merged.",
+						ArgsUsage: "<path>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return regolith.WrappedError(
+									"Usage: regolith config get <path>")
+							}
+							return regolith.ConfigGet(
+								c.Args().Get(0), regolith.Debug)
+						},
+					},
+					{
+						Name:      "set",
+						Usage:     "Changes a value of the config. The value is parsed as JSON or used as a string.",
+						ArgsUsage: "<path> <value>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 2 {
+								return regolith.WrappedError(
+									"Usage: regolith config set <path> <value>")
+							}
+							return regolith.ConfigSet(
+								c.Args().Get(0), c.Args().Get(1),
+								c.Bool("local"), regolith.Debug)
+						},
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "local",
+								Usage: "Changes the config.local.json file instead of config.json.",
+							},
+						},
+					},
+					{
+						Name:      "unset",
+						Usage:     "Removes a value from the config.",
+						ArgsUsage: "<path>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return regolith.WrappedError(
+									"Usage: regolith config unset <path>")
+							}
+							return regolith.ConfigUnset(
+								c.Args().Get(0), c.Bool("local"),
+								regolith.Debug)
+						},
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "local",
+								Usage: "Changes the config.local.json file instead of config.json.",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "Prints the profiles of the project with their filters (the resolved versions of the remote filters and whether the filters are enabled), and the filter definitions that aren't used by any profile.",
+				Action: func(c *cli.Context) error {
+					return regolith.List(regolith.Debug)
+				},
+			},
+			{
+				Name:         "graph",
+				Usage:        "Prints the graph of the filters of a profile, which shows the order of running them, based on their \"needs\", \"inputs\" and \"outputs\" properties.",
+				ArgsUsage:    "[profile]",
+				BashComplete: regolith.CompleteProfiles,
+				Action: func(c *cli.Context) error {
+					return regolith.Graph(
+						c.Args().Get(0), c.String("format"), regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "dot",
+						Usage: "The format of the graph: \"dot\" (Graphviz) or \"mermaid\".",
+					},
+				},
 			},
 			{
 				Name: "update-all",
@@ -101,17 +394,38 @@ func main() {
 			},
 			{
 				Name:  "install-all",
-				Usage: `Installs all of the filters from filtersDefintions of config.json file and their dependencies.`,
+				Usage: `Installs all of the filters from filtersDefintions of config.json file and their dependencies, in the versions from the regolith-lock.json file.`,
 				Action: func(c *cli.Context) error {
 					force := c.Bool("force")
-					return regolith.InstallAll(force, regolith.Debug)
+					offline := c.Bool("offline")
+					if c.Bool("all") || c.String("project") != "" {
+						if c.Bool("all") && c.String("project") != "" {
+							return regolith.WrappedError(
+								"The \"--all\" and \"--project\" flags can't be used together.")
+						}
+						return regolith.InstallAllWorkspace(
+							c.String("project"), force, offline, regolith.Debug)
+					}
+					return regolith.InstallAll(force, offline, regolith.Debug)
 				},
 				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Installs the filters of all of the projects of the workspace from the \"regolith-workspace.json\" file.",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "Installs the filters of the project of the workspace with this name and of the projects it depends on.",
+					},
 					&cli.BoolFlag{
 						Name:    "force",
 						Aliases: []string{"f"},
 						Usage:   "Force the operation, overriding potential safeguards.",
 					},
+					&cli.BoolFlag{
+						Name:  "offline",
+						Usage: "Installs the filters from the \"filters_vendored\" folder and the cache, without accessing the network.",
+					},
 				},
 			},
 			{
@@ -137,22 +451,161 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "link",
+				Usage: "Makes a remote filter of the project use its local working copy from the given path, instead of the downloaded files.",
+				Action: func(c *cli.Context) error {
+					return regolith.Link(
+						c.Args().First(), c.String("name"), regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "The name of the filter in the filterDefinitions list. Defaults to the name of the linked folder.",
+					},
+				},
+			},
+			{
+				Name:  "unlink",
+				Usage: "Removes the link of a remote filter to its local working copy.",
+				Action: func(c *cli.Context) error {
+					return regolith.Unlink(c.Args().First(), regolith.Debug)
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "Starts a daemon that keeps the project loaded and handles the \"run\" and \"watch\" commands of this project until it's stopped with Ctrl+C.",
+				Action: func(c *cli.Context) error {
+					if c.Bool("stop") {
+						return regolith.StopServe(regolith.Debug)
+					}
+					return regolith.Serve(c.Int("http-port"), regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "stop",
+						Usage: "Stops the daemon of the project.",
+					},
+					&cli.IntFlag{
+						Name:  "http-port",
+						Usage: "Also serves the HTTP API (\"POST /run\", \"GET /status\" and \"GET /events\") on this port of localhost.",
+					},
+				},
+			},
+			{
+				Name:  "lsp",
+				Usage: "Starts the language server that provides the completion, the hover documentation and the diagnostics of the config.json and filter.json files for the editors, using the standard input and output.",
+				Action: func(c *cli.Context) error {
+					return regolith.Lsp(regolith.Debug)
+				},
+			},
+			{
+				Name:  "vendor",
+				Usage: "Copies the installed remote filters to the \"filters_vendored\" folder of the project, so they can be installed with \"install-all --offline\".",
+				Action: func(c *cli.Context) error {
+					return regolith.Vendor(regolith.Debug)
+				},
+			},
+			{
+				Name:  "doctor",
+				Usage: "Checks the environment of the project (Python, NodeJS, Deno, Git, the config, the filters and the export paths) and prints how to fix the problems.",
+				Action: func(c *cli.Context) error {
+					return regolith.Doctor(regolith.Debug)
+				},
+			},
+			{
+				Name:      "explain",
+				Usage:     "Prints the causes and the fixes of the error with the code (like R0006) printed before its message. Without the code, lists the codes.",
+				ArgsUsage: "[code]",
+				Action: func(c *cli.Context) error {
+					return regolith.Explain(c.Args().First(), regolith.Debug)
+				},
+			},
+			{
+				Name:         "completion",
+				Usage:        "Prints the completion script of the shell (\"bash\", \"zsh\", \"fish\" or \"powershell\"), which completes the commands, the flags, and the names of the profiles and the filters of the project.",
+				ArgsUsage:    "<shell>",
+				BashComplete: regolith.CompleteShells,
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return regolith.WrappedError(
+							"Usage: regolith completion <shell>")
+					}
+					scriptOutput = true
+					return regolith.Completion(c.Args().First(), regolith.Debug)
+				},
+			},
+			{
+				Name:      "tool",
+				Usage:     "Runs a filter from the filterDefinitions list directly on the source files of the project (RP, BP and the data folder), after backing them up. The arguments after the name of the filter are passed to the filter.",
+				ArgsUsage: "<filter> [arguments]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 1 {
+						return regolith.WrappedError(
+							"Usage: regolith tool <filter> [arguments]")
+					}
+					return regolith.Tool(
+						c.Args().First(), c.Args().Tail(), c.Bool("yes"),
+						regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Changes the files without asking for confirmation.",
+					},
+				},
+			},
+			{
+				Name:  "search",
+				Usage: "Searches the filter registries for filters with names or descriptions that contain the search term.",
+				Action: func(c *cli.Context) error {
+					return regolith.Search(
+						strings.Join(c.Args().Slice(), " "), regolith.Debug)
+				},
+			},
 			{
 				Name:  "init",
 				Usage: "Initialize a Regolith project in the current directory.",
 				Action: func(c *cli.Context) error {
-					return regolith.Init(regolith.Debug)
+					return regolith.InitFromTemplate(
+						c.String("template"), c.String("name"),
+						c.String("namespace"), regolith.Debug)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Usage:   "Creates the project from a template: \"starter\", \"bare\", the name of a template from the user config or the URL of a template repository.",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "The name of the project used by the template. The name of the current directory by default.",
+					},
+					&cli.StringFlag{
+						Name:  "namespace",
+						Usage: "The namespace of the project used by the template. Created from the name of the project by default.",
+					},
 				},
 			},
 			{
 				Name:  "clean",
-				Usage: "Cleans Regolith cache.",
+				Usage: "Cleans Regolith cache. Without the flags that select the files to clean, removes the whole cache of the project.",
 				Action: func(c *cli.Context) error {
-					clearPathStates := c.Bool("path-states")
+					scope := regolith.CleanScope{
+						Tmp:        c.Bool("tmp"),
+						PathStates: c.Bool("path-states"),
+						Filters:    c.Bool("filters"),
+						Exported:   c.Bool("exported"),
+					}
 					userCache := c.Bool("user-cache")
-					return regolith.Clean(regolith.Debug, userCache, clearPathStates)
+					return regolith.Clean(regolith.Debug, userCache, scope)
 				},
 				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "tmp",
+						Usage: "Deletes the files of the last run from the \"tmp\" folder.",
+					},
 					&cli.BoolFlag{
 						Name:    "path-states",
 						Aliases: []string{"p"},
@@ -161,13 +614,98 @@ func main() {
 							"work in --recycled mode and Regolith doesn't " +
 							"export files propertly).",
 					},
+					&cli.BoolFlag{
+						Name:  "filters",
+						Usage: "Deletes the downloaded filters and their virtual environments. Run \"regolith install-all\" to download them again.",
+					},
+					&cli.BoolFlag{
+						Name:  "exported",
+						Usage: "Deletes the packs exported by the profiles with the \"development\" and \"preview\" export targets.",
+					},
 					&cli.BoolFlag{
 						Name:    "user-cache",
 						Aliases: []string{},
 						Usage: "Clears data of the projects cached in the " +
-							"user app data folder. This is useful to clean " +
-							"up leftover files from old projects that use " +
-							"the \"useAppData\" option.",
+							"user app data folder and the filters shared by " +
+							"the projects. This is useful to clean up " +
+							"leftover files from old projects that use the " +
+							"\"useAppData\" option.",
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Manages the cache of Regolith shared by the projects.",
+				Subcommands: []*cli.Command{
+					{
+						Name: "gc",
+						Usage: "Removes cached filters, venvs, filter outputs " +
+							"and state files that aren't used by any known " +
+							"project or are older than the retention period.",
+						Action: func(c *cli.Context) error {
+							return regolith.CacheGc(
+								c.Int("retention-days"), regolith.Debug)
+						},
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "retention-days",
+								Value: 30,
+								Usage: "Removes the cached files that weren't " +
+									"used for this number of days.",
+							},
+						},
+					},
+					{
+						Name:  "info",
+						Usage: "Prints the locations and sizes of the caches of the known projects.",
+						Action: func(c *cli.Context) error {
+							return regolith.CacheInfo(regolith.Debug)
+						},
+					},
+				},
+			},
+			{
+				Name:  "secret",
+				Usage: "Manages the secrets stored in the credential store of the operating system, used with the \"${secret:NAME}\" placeholder.",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Saves a secret. The value is read from the standard input if it's not specified.",
+						ArgsUsage: "<name> [value]",
+						Action: func(c *cli.Context) error {
+							if c.NArg() < 1 || c.NArg() > 2 {
+								return regolith.WrappedError(
+									"Usage: regolith secret set <name> [value]")
+							}
+							return regolith.SecretSet(
+								c.Args().Get(0), c.Args().Get(1), regolith.Debug)
+						},
+					},
+					{
+						Name:      "get",
+						Usage:     "Prints the value of a secret.",
+						ArgsUsage: "<name>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return regolith.WrappedError(
+									"Usage: regolith secret get <name>")
+							}
+							return regolith.SecretGet(
+								c.Args().Get(0), regolith.Debug)
+						},
+					},
+					{
+						Name:      "delete",
+						Usage:     "Removes a secret.",
+						ArgsUsage: "<name>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return regolith.WrappedError(
+									"Usage: regolith secret delete <name>")
+							}
+							return regolith.SecretDelete(
+								c.Args().Get(0), regolith.Debug)
+						},
 					},
 				},
 			},
@@ -180,8 +718,23 @@ func main() {
 			},
 		},
 	}).Run(os.Args)
+	regolith.CloseEventStream()
+	if scriptOutput && err == nil {
+		return
+	}
 	if err != nil {
+		regolith.InitLogging(regolith.Debug) // The flags may be invalid
 		regolith.Logger.Error(err)
+		// The last code is the code of the cause of the error
+		if codes := regolith.ErrorCodes(err); len(codes) > 0 {
+			regolith.Logger.Infof(
+				"Run \"regolith explain %s\" for the causes and the fixes of "+
+					"the error.", codes[len(codes)-1])
+		}
+		// The same exit code as when the signal stops the program
+		if regolith.IsExportInterruptedError(err) {
+			os.Exit(130)
+		}
 		os.Exit(1)
 	} else {
 		regolith.InitLogging(false)
@@ -191,7 +744,7 @@ func main() {
 	if result.Err != nil {
 		regolith.Logger.Warn("Update check failed")
 		regolith.Logger.Debug(*result.Err)
-	} else if result.ShouldUpdate {
+	} else if result.ShouldUpdate && regolith.Verbosity >= 0 {
 		_, _ = fmt.Fprintln(color.Output, color.GreenString("New version available!"))
 		_, _ = fmt.Fprintln(color.Output, color.GreenString(*result.Url))
 	}
@@ -0,0 +1,284 @@
+// Package regolith is the public Go API of Regolith, for the programs that
+// embed it instead of running the "regolith" binary. It loads the configs of
+// the projects, installs their filters and runs their profiles, reporting the
+// log messages to a Logger and the progress of the runs to a callback.
+//
+// Regolith works on the project in the current working directory, so the
+// functions of this package change the working directory of the process to
+// Options.ProjectDir while they run. Only one of them runs at a time, and the
+// other goroutines of the program shouldn't depend on the working directory
+// in the meantime.
+package regolith
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config is the parsed config.json file of a project, with the local config
+// and the user defaults merged.
+type Config = core.Config
+
+// Logger receives the log messages of Regolith.
+type Logger interface {
+	Debug(message string)
+	Info(message string)
+	Warn(message string)
+	Error(message string)
+}
+
+// Phase is the kind of a part of a run reported by the Progress callback.
+type Phase string
+
+const (
+	// PhaseSetup copies the source files to the temporary directory.
+	PhaseSetup Phase = "setup"
+	// PhaseFilter runs a filter.
+	PhaseFilter Phase = "filter"
+	// PhaseExport exports the packs to the export target.
+	PhaseExport Phase = "export"
+)
+
+// Progress describes the start or the end of a phase of a run.
+type Progress struct {
+	// Profiles are the names of the profiles that run the phase, starting
+	// with the profile that was run and ending with the nested profile of
+	// the phase.
+	Profiles []string
+	Phase    Phase
+	// Filter is the ID of the filter of the PhaseFilter phase.
+	Filter string
+	// Done is false at the start of the phase and true at its end.
+	Done bool
+	// Duration is the duration of the phase, zero at its start.
+	Duration time.Duration
+}
+
+// Options are the options of Run.
+type Options struct {
+	// ProjectDir is the directory with the config.json file of the project.
+	// The current working directory is used if it's empty.
+	ProjectDir string
+	// Profile is the name of the profile to run, "default" if it's empty.
+	Profile string
+	// Defines are the values used by the "when" expressions and the
+	// variables of the filters, like the "--define" flag.
+	Defines map[string]string
+	// Filters are the IDs of the filters of the profile to run. All of the
+	// filters run if it's empty.
+	Filters []string
+	// SkipExport leaves the results in the temporary directory instead of
+	// exporting them.
+	SkipExport bool
+	// Recycled uses the "recycled" mode of copying the files.
+	Recycled bool
+	// ResourcePack and BehaviorPack replace the source packs from the
+	// config, and OutputDir replaces the export target of the profile with
+	// the "RP" and "BP" folders in this directory.
+	ResourcePack string
+	BehaviorPack string
+	OutputDir    string
+	// Logger receives the log messages. They're discarded if it's nil.
+	Logger Logger
+	// Debug sends the debug messages to the Logger.
+	Debug bool
+	// OnProgress is called at the start and at the end of every phase of
+	// the run, from the goroutine that runs the phase.
+	OnProgress func(Progress)
+}
+
+// InstallOptions are the options of InstallFilters.
+type InstallOptions struct {
+	// ProjectDir is the directory with the config.json file of the project.
+	// The current working directory is used if it's empty.
+	ProjectDir string
+	// Force reinstalls the filters that are already installed.
+	Force bool
+	// Offline installs the filters from the vendored copies and the cache,
+	// without accessing the network.
+	Offline bool
+	// Logger receives the log messages. They're discarded if it's nil.
+	Logger Logger
+	// Debug sends the debug messages to the Logger.
+	Debug bool
+}
+
+// mutex makes the functions of the package run one at a time, because they
+// change the working directory and the global state of Regolith.
+var mutex sync.Mutex
+
+// Run runs a profile of the project and exports its packs, like the
+// "regolith run" command. The filters of the profile must be installed (see
+// InstallFilters).
+//
+// The run stops with an error when the ctx is cancelled. The cancellation is
+// checked before every filter and before the export, so the filter that is
+// running when the ctx is cancelled finishes first.
+func Run(ctx context.Context, options Options) error {
+	defines := make([]string, 0, len(options.Defines))
+	for name, value := range options.Defines {
+		defines = append(defines, name+"="+value)
+	}
+	sort.Strings(defines)
+	// The paths are relative to the working directory of the caller
+	paths := core.RunPaths{
+		Rp:  absPath(options.ResourcePack),
+		Bp:  absPath(options.BehaviorPack),
+		Out: absPath(options.OutputDir),
+	}
+	return inProject(
+		options.ProjectDir, options.Logger, options.Debug, func() error {
+			if options.OnProgress != nil {
+				core.PhaseObserver = func(
+					stack []string, done bool, duration time.Duration,
+				) {
+					options.OnProgress(newProgress(stack, done, duration))
+				}
+				defer func() { core.PhaseObserver = nil }()
+			}
+			return core.RunEmbedded(
+				ctx, options.Profile, defines, options.Filters,
+				options.SkipExport, options.Recycled, paths)
+		})
+}
+
+// InstallFilters installs the filters from the filterDefinitions list of the
+// config of the project, in the versions from its lock file, like the
+// "regolith install-all" command.
+func InstallFilters(ctx context.Context, options InstallOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return inProject(
+		options.ProjectDir, options.Logger, options.Debug, func() error {
+			return core.InstallAll(
+				options.Force, options.Offline, options.Debug)
+		})
+}
+
+// LoadConfig loads the config of the project from the directory (the
+// current working directory if it's empty).
+func LoadConfig(projectDir string) (*Config, error) {
+	var config *Config
+	err := inProject(projectDir, nil, false, func() error {
+		configJson, err := core.LoadConfigAsMap()
+		if err != nil {
+			return err
+		}
+		config, err = core.ConfigFromObject(configJson)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// inProject runs the function in the project directory, with the log
+// messages of Regolith sent to the logger.
+func inProject(
+	projectDir string, logger Logger, debug bool, f func() error,
+) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if projectDir != "" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get the working directory: %w", err)
+		}
+		if err := os.Chdir(projectDir); err != nil {
+			return fmt.Errorf("failed to open the project directory: %w", err)
+		}
+		defer os.Chdir(workingDir)
+	}
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	if debug {
+		level.SetLevel(zap.DebugLevel)
+	}
+	var zapCore zapcore.Core = zapcore.NewNopCore()
+	if logger != nil {
+		zapCore = &loggerCore{LevelEnabler: level, logger: logger}
+	}
+	core.SetLogger(zap.New(zapCore).Sugar(), level)
+	return f()
+}
+
+// newProgress creates the Progress from a phase reported to the
+// core.PhaseObserver.
+func newProgress(
+	stack []string, done bool, duration time.Duration,
+) Progress {
+	progress := Progress{
+		Profiles: stack[:len(stack)-1],
+		Phase:    Phase(stack[len(stack)-1]),
+		Done:     done,
+		Duration: duration,
+	}
+	// The phases of the filters are named "filter <id>"
+	name := string(progress.Phase)
+	if id := strings.TrimPrefix(name, "filter "); id != name {
+		progress.Phase = PhaseFilter
+		progress.Filter = id
+	}
+	return progress
+}
+
+// loggerCore is the zapcore.Core that sends the log messages of Regolith to
+// a Logger.
+type loggerCore struct {
+	zapcore.LevelEnabler
+	logger Logger
+}
+
+func (c *loggerCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *loggerCore) Check(
+	entry zapcore.Entry, checked *zapcore.CheckedEntry,
+) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *loggerCore) Write(entry zapcore.Entry, _ []zapcore.Field) error {
+	switch {
+	case entry.Level == zapcore.DebugLevel:
+		c.logger.Debug(entry.Message)
+	case entry.Level == zapcore.InfoLevel:
+		c.logger.Info(entry.Message)
+	case entry.Level == zapcore.WarnLevel:
+		c.logger.Warn(entry.Message)
+	default:
+		c.logger.Error(entry.Message)
+	}
+	return nil
+}
+
+func (c *loggerCore) Sync() error {
+	return nil
+}
+
+// absPath returns the absolute path, or the path itself if it's empty.
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	result, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return result
+}
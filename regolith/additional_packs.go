@@ -0,0 +1,148 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// additionalPackTargets are the export targets that can export the
+// additional packs of the project (see AdditionalPacksFromObject). The other
+// targets only export the main packs.
+var additionalPackTargets = []string{
+	"development", "preview", "exact", "world", "local"}
+
+// AdditionalPacksFromObject creates the additional packs of the project from
+// the "additionalPacks" property of the regolith namespace. The keys of the
+// object are the names of the packs, which are used in the names of their
+// directories in the tmp directory ("BP_<name>" and "RP_<name>") and in their
+// export paths (see additionalPackExportPath).
+func AdditionalPacksFromObject(
+	obj map[string]interface{},
+) (map[string]Packs, error) {
+	result := make(map[string]Packs, len(obj))
+	for name, packsObj := range obj {
+		if name == "" || strings.ContainsAny(name, `/\:*?"<>|`) {
+			return nil, WrappedErrorf(
+				"Invalid name of an additional pack.\nName: %q\n"+
+					"The name is used in the names of the directories, so it "+
+					"can't be empty or contain any of these characters: "+
+					"/\\:*?\"<>|", name)
+		}
+		packsMap, ok := packsObj.(map[string]interface{})
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyTypeError, name, "object")
+		}
+		packs := PacksFromObject(packsMap)
+		if packs.BehaviorFolder == "" && packs.ResourceFolder == "" {
+			return nil, WrappedErrorf(
+				"The additional pack must have the \"behaviorPack\" or "+
+					"\"resourcePack\" property.\nPack: %s", name)
+		}
+		result[name] = packs
+	}
+	return result, nil
+}
+
+// additionalPack is a single behavior pack or resource pack from the
+// additional packs of the project.
+type additionalPack struct {
+	// Kind is "BP" or "RP"
+	Kind string
+	// Source is the path to the pack in the project
+	Source string
+	// TmpDir is the name of the directory of the pack in the tmp directory
+	TmpDir string
+	// Name is the name of the pack from config.json
+	Name string
+}
+
+// listAdditionalPacks returns the behavior packs and resource packs from the
+// additional packs of the project, sorted by their names.
+func listAdditionalPacks(packs map[string]Packs) []additionalPack {
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []additionalPack
+	for _, name := range names {
+		if source := packs[name].BehaviorFolder; source != "" {
+			result = append(result, additionalPack{
+				Kind: "BP", Source: source, TmpDir: "BP_" + name, Name: name})
+		}
+		if source := packs[name].ResourceFolder; source != "" {
+			result = append(result, additionalPack{
+				Kind: "RP", Source: source, TmpDir: "RP_" + name, Name: name})
+		}
+	}
+	return result
+}
+
+// ExportPath returns the export path of the pack, based on the export path
+// of the main pack of the same kind. The additional packs are exported next
+// to the main packs, with the name of the pack added to the name of the
+// directory, for example "build/BP_<name>" for the "local" export target.
+func (p additionalPack) ExportPath(bpPath, rpPath string) string {
+	path := bpPath
+	if p.Kind == "RP" {
+		path = rpPath
+	}
+	return filepath.Clean(path) + "_" + p.Name
+}
+
+// checkAdditionalPacksExport returns an error if the export target can't
+// export the additional packs of the project.
+func checkAdditionalPacksExport(
+	exportTarget ExportTarget, packs map[string]Packs,
+) error {
+	if len(packs) == 0 {
+		return nil
+	}
+	if !stringInSlice(exportTarget.Target, additionalPackTargets) {
+		return WrappedErrorf(
+			"The %q export target doesn't support the additional packs.\n"+
+				"Export targets that support them: %s",
+			exportTarget.Target, strings.Join(additionalPackTargets, ", "))
+	}
+	if exportTarget.Symlink {
+		return WrappedError(
+			"The \"symlink\" property can't be used in the projects with " +
+				"additional packs.")
+	}
+	return nil
+}
+
+// tmpPackDirs returns the names of the directories of the packs in the tmp
+// directory: "BP", "RP" and the directories of the additional packs.
+func tmpPackDirs(tmpPath string) []string {
+	result := []string{"BP", "RP"}
+	for _, pattern := range []string{"BP_*", "RP_*"} {
+		matches, _ := filepath.Glob(filepath.Join(tmpPath, pattern))
+		for _, match := range matches {
+			result = append(result, filepath.Base(match))
+		}
+	}
+	return result
+}
+
+// removeUnusedPackDirs removes the directories of the additional packs that
+// are no longer in config.json from the tmp directory, which isn't cleared
+// by the recycled setup.
+func removeUnusedPackDirs(tmpPath string, packs map[string]Packs) error {
+	used := make(map[string]struct{})
+	for _, pack := range listAdditionalPacks(packs) {
+		used[pack.TmpDir] = struct{}{}
+	}
+	for _, dir := range tmpPackDirs(tmpPath)[2:] {
+		if _, ok := used[dir]; ok {
+			continue
+		}
+		path := filepath.Join(tmpPath, dir)
+		if err := os.RemoveAll(path); err != nil {
+			return WrapErrorf(err, osRemoveError, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+// Functions used for the "regolith cache gc" and "regolith cache info"
+// commands
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// knownProjectsPath is a path to the list of the projects that used Regolith
+// on this machine, relative to the GetRegolithConfigPath() directory. The list
+// is used for finding the cached files that aren't used by any project.
+const knownProjectsPath = "known_projects.json"
+
+// gcStateFiles are the state files from the .regolith directory, removed by
+// the garbage collection if they weren't modified for the retention period.
+// Without them, the next export is a full export.
+var gcStateFiles = []string{
+	exportStatePath, exportReportStatePath, sftpExportStatePath, exportHashPath,
+}
+
+// knownProject is an entry of the known projects list. The keys of the list
+// are the absolute paths to the projects.
+type knownProject struct {
+	DotRegolithPath string    `json:"dotRegolithPath"`
+	LastUsed        time.Time `json:"lastUsed"`
+}
+
+// loadKnownProjects loads the list of the known projects. If the file
+// doesn't exist, it returns an empty list.
+func loadKnownProjects() (map[string]knownProject, error) {
+	result := make(map[string]knownProject)
+	path, err := GetRegolithConfigPath()
+	if err != nil {
+		return nil, WrapError(err, getRegolithConfigPathError)
+	}
+	path = filepath.Join(path, knownProjectsPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	return result, nil
+}
+
+// saveKnownProjects saves the list of the known projects.
+func saveKnownProjects(projects map[string]knownProject) error {
+	path, err := GetRegolithConfigPath()
+	if err != nil {
+		return WrapError(err, getRegolithConfigPathError)
+	}
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, path)
+	}
+	path = filepath.Join(path, knownProjectsPath)
+	data, _ := json.MarshalIndent(projects, "", "\t") // no error
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	return nil
+}
+
+// recordKnownProject adds the project to the list of the known projects, or
+// updates the time of its last use. The errors are only logged, because the
+// list is used only by the garbage collection of the cache.
+func recordKnownProject(projectRoot, dotRegolithPath string) {
+	absoluteProjectRoot, err1 := filepath.Abs(projectRoot)
+	absoluteDotRegolith, err2 := filepath.Abs(dotRegolithPath)
+	projects, err3 := loadKnownProjects()
+	if err := firstErr(err1, err2, err3); err != nil {
+		Logger.Debugf("Failed to record the known project: %s", err)
+		return
+	}
+	projects[absoluteProjectRoot] = knownProject{
+		DotRegolithPath: absoluteDotRegolith,
+		LastUsed:        time.Now(),
+	}
+	if err := saveKnownProjects(projects); err != nil {
+		Logger.Debugf("Failed to record the known project: %s", err)
+	}
+}
+
+// loadProjectConfig loads and parses the config.json file of the project.
+func loadProjectConfig(projectRoot string) (*Config, error) {
+	path := filepath.Join(projectRoot, ConfigFilePath)
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	var configMap map[string]interface{}
+	err = unmarshalJsonc(file, &configMap)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	configMap, err = mergeUserProjectDefaults(configMap)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	configMap, err = mergeLocalConfig(configMap, projectRoot)
+	if err != nil {
+		return nil, WrapError(err, "Failed to load the local config.")
+	}
+	config, err := ConfigFromObject(configMap)
+	if err != nil {
+		return nil, WrapErrorf(err, "Failed to parse the config.\nPath: %s", path)
+	}
+	return config, nil
+}
+
+// cacheGc removes the files from the caches of Regolith, which aren't used
+// by any of the known projects, or weren't used for the retention period:
+//   - the caches of the projects that don't exist anymore,
+//   - the downloaded filters and the venvs that the projects don't use,
+//   - the cached filter outputs and the state files older than the retention
+//     period, and the stored files that no cached output uses anymore,
+//   - the filters from the shared filter cache that aren't locked by any
+//     project or are older than the retention period,
+//   - the caches of the unknown projects in the user app data, that are older
+//     than the retention period.
+//
+// It returns the number of freed bytes.
+func cacheGc(retention time.Duration) (int64, error) {
+	projects, err := loadKnownProjects()
+	if err != nil {
+		return 0, PassError(err)
+	}
+	var freed int64
+	remove := func(path, reason string) {
+		size := pathSize(path)
+		Logger.Infof("Removing \"%s\" (%s, %s).", path, formatSize(size), reason)
+		if err := os.RemoveAll(path); err != nil {
+			Logger.Warnf("%s", WrapErrorf(err, osRemoveError, path))
+			return
+		}
+		freed += size
+	}
+	sharedFilters := make(map[string]struct{})
+	usedDotRegoliths := make(map[string]struct{})
+	for projectRoot, project := range projects {
+		if _, err := os.Stat(filepath.Join(projectRoot, ConfigFilePath)); err != nil {
+			// The .regolith directory of a removed project is removed only
+			// if it's in the user app data, the other ones were removed
+			// with the project
+			if isAppDataCache(project.DotRegolithPath) {
+				remove(project.DotRegolithPath, "the project doesn't exist")
+			}
+			delete(projects, projectRoot)
+			continue
+		}
+		usedDotRegoliths[project.DotRegolithPath] = struct{}{}
+		config, err := loadProjectConfig(projectRoot)
+		if err != nil {
+			Logger.Warnf(
+				"Skipping the project, its config can't be loaded.\n"+
+					"Project: %s\n%s", projectRoot, err)
+			continue
+		}
+		// The nested remote filters are installed like the filters from
+		// config.json
+		filterDefinitions := withNestedFilters(
+			config.FilterDefinitions, project.DotRegolithPath)
+		gcProjectCache(
+			project.DotRegolithPath, filterDefinitions, retention, remove)
+		lockFile, err := loadLockFileFrom(
+			filepath.Join(projectRoot, LockFilePath))
+		if err != nil {
+			Logger.Warnf("%s", err)
+			continue
+		}
+		for name, locked := range lockFile.Filters {
+			dir, err := sharedFilterCacheDir(
+				locked.Url, name, filterVersionRef(name, locked.Resolved))
+			if err == nil {
+				sharedFilters[dir] = struct{}{}
+			}
+		}
+	}
+	userCache, err := regolithCacheDir()
+	if err != nil {
+		return freed, PassError(err)
+	}
+	sharedFilterCache := filepath.Join(userCache, sharedFilterCachePath)
+	for _, entry := range readDirOrEmpty(sharedFilterCache) {
+		path := filepath.Join(sharedFilterCache, entry.Name())
+		if isOlderThan(path, retention) {
+			remove(path, "older than the retention period")
+		} else if _, ok := sharedFilters[path]; !ok {
+			remove(path, "not used by any known project")
+		}
+	}
+	appDataCache := filepath.Join(userCache, appDataCachePath)
+	for _, entry := range readDirOrEmpty(appDataCache) {
+		path := filepath.Join(appDataCache, entry.Name())
+		if _, ok := usedDotRegoliths[path]; !ok && isOlderThan(path, retention) {
+			remove(path, "unknown project older than the retention period")
+		}
+	}
+	return freed, saveKnownProjects(projects)
+}
+
+// gcProjectCache removes the unused files from the .regolith directory of a
+// project (see cacheGc).
+func gcProjectCache(
+	dotRegolithPath string, filterDefinitions map[string]FilterInstaller,
+	retention time.Duration, remove func(path, reason string),
+) {
+	usedVenvs := make(map[string]struct{})
+	usedFilterVenvs := make(map[string]struct{})
+	for name, filterDefinition := range filterDefinitions {
+		switch filter := filterDefinition.(type) {
+		case *RemoteFilterDefinition:
+			if filter.sharedVenv {
+				usedVenvs[strconv.Itoa(filter.VenvSlot)] = struct{}{}
+			} else {
+				usedFilterVenvs[name] = struct{}{}
+			}
+		case *PythonFilterDefinition:
+			if filter.sharedVenv {
+				usedVenvs[strconv.Itoa(filter.VenvSlot)] = struct{}{}
+			} else {
+				usedFilterVenvs[name] = struct{}{}
+			}
+		}
+	}
+	filtersPath := filepath.Join(dotRegolithPath, "cache/filters")
+	for _, entry := range readDirOrEmpty(filtersPath) {
+		filter, ok := filterDefinitions[entry.Name()]
+		if _, isRemote := filter.(*RemoteFilterDefinition); !ok || !isRemote {
+			remove(
+				filepath.Join(filtersPath, entry.Name()),
+				"not used by the project")
+		}
+	}
+	venvsPath := filepath.Join(dotRegolithPath, "cache/venvs")
+	for _, entry := range readDirOrEmpty(venvsPath) {
+		if _, ok := usedVenvs[entry.Name()]; !ok {
+			remove(
+				filepath.Join(venvsPath, entry.Name()),
+				"venv slot not used by the project")
+		}
+	}
+	filterVenvs := filepath.Join(dotRegolithPath, filterVenvsPath)
+	for _, entry := range readDirOrEmpty(filterVenvs) {
+		if _, ok := usedFilterVenvs[entry.Name()]; !ok {
+			remove(
+				filepath.Join(filterVenvs, entry.Name()),
+				"venv of a filter not used by the project")
+		}
+	}
+	outputsPath := filepath.Join(dotRegolithPath, filterCachePath)
+	for _, filterDir := range readDirOrEmpty(outputsPath) {
+		filterPath := filepath.Join(outputsPath, filterDir.Name())
+		for _, output := range readDirOrEmpty(filterPath) {
+			path := filepath.Join(filterPath, output.Name())
+			if isOlderThan(path, retention) {
+				remove(path, "older than the retention period")
+			}
+		}
+		if len(readDirOrEmpty(filterPath)) == 0 {
+			os.Remove(filterPath)
+		}
+	}
+	if removed, err := removeUnusedBuildCacheObjects(dotRegolithPath); err != nil {
+		Logger.Warnf("%s", PassError(err).Error())
+	} else if removed > 0 {
+		Logger.Infof(
+			"Removed %d files not used by the cached filter outputs.", removed)
+	}
+	legacyPath := filepath.Join(dotRegolithPath, legacyFilterCachePath)
+	if _, err := os.Stat(legacyPath); err == nil {
+		remove(legacyPath, "filter cache of an older version of Regolith")
+	}
+	for _, stateFile := range gcStateFiles {
+		path := filepath.Join(dotRegolithPath, stateFile)
+		if isOlderThan(path, retention) {
+			remove(path, "older than the retention period")
+		}
+	}
+}
+
+// cacheInfo prints the locations and the sizes of the caches of Regolith.
+func cacheInfo() error {
+	projects, err := loadKnownProjects()
+	if err != nil {
+		return PassError(err)
+	}
+	roots := make([]string, 0, len(projects))
+	for root := range projects {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	var total int64
+	Logger.Infof("Known projects: %d", len(roots))
+	for _, root := range roots {
+		project := projects[root]
+		size := pathSize(project.DotRegolithPath)
+		total += size
+		status := ""
+		if _, err := os.Stat(filepath.Join(root, ConfigFilePath)); err != nil {
+			status = ", the project doesn't exist"
+		}
+		Logger.Infof(
+			"  %s\n    Cache: %s (%s, last used %s%s)", root,
+			project.DotRegolithPath, formatSize(size),
+			project.LastUsed.Format("2006-01-02"), status)
+	}
+	userCache, err := regolithCacheDir()
+	if err != nil {
+		return PassError(err)
+	}
+	sharedFilterCache := filepath.Join(userCache, sharedFilterCachePath)
+	size := pathSize(sharedFilterCache)
+	total += size
+	Logger.Infof(
+		"Shared filter cache: %s (%d filters, %s)", sharedFilterCache,
+		len(readDirOrEmpty(sharedFilterCache)), formatSize(size))
+	Logger.Infof("Total size: %s", formatSize(total))
+	return nil
+}
+
+// readDirOrEmpty returns the entries of the directory, or an empty list if
+// the directory can't be read.
+func readDirOrEmpty(path string) []fs.DirEntry {
+	entries, _ := os.ReadDir(path)
+	return entries
+}
+
+// isOlderThan returns true if the path exists and wasn't modified for the
+// duration.
+func isOlderThan(path string, duration time.Duration) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > duration
+}
+
+// isAppDataCache returns true if the .regolith directory is in the user app
+// data (the project uses the "useAppData" option).
+func isAppDataCache(dotRegolithPath string) bool {
+	userCache, err := regolithCacheDir()
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(
+		filepath.Join(userCache, appDataCachePath), dotRegolithPath)
+	return err == nil && filepath.Dir(rel) == "."
+}
+
+// pathSize returns the total size of the files in the path. The links are
+// not followed.
+func pathSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// formatSize formats the number of bytes for the user.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
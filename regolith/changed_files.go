@@ -0,0 +1,156 @@
+package regolith
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// changedFilesEnv is the environment variable with the absolute path to the
+// JSON file with the list of the changed files (see changedFiles). It's set
+// only in the watch mode.
+const changedFilesEnv = "REGOLITH_CHANGED_FILES"
+
+// changedFilesPath is the path to the JSON file with the list of the changed
+// files, relative to the .regolith directory.
+const changedFilesPath = "changed_files.json"
+
+// changedFiles is the list of the source files changed since the previous
+// run of the profile in the watch mode. The paths use slashes and they're
+// relative to the working directory of the filters, for example
+// "RP/textures/blocks/dirt.png". The list includes the removed files. It's
+// nil if Regolith doesn't know which files changed (outside of the watch
+// mode and until the first successful run), in which case the filters should
+// process all of the files.
+var changedFiles []string
+
+// changedFilesFile is the absolute path to the file with the changedFiles,
+// passed to the filters with the changedFilesEnv environment variable. It's
+// empty outside of the watch mode.
+var changedFilesFile = ""
+
+// changedFilesTracker collects the paths of the source files changed in the
+// watch mode, reported by the DirWatchers. The changes are kept until a run
+// that includes them succeeds, so the failed and the interrupted runs don't
+// lose them.
+type changedFilesTracker struct {
+	mutex sync.Mutex
+	// paths maps the changed paths to the generation in which they changed.
+	paths map[string]int
+	// generation is increased with every snapshot, so the changes that
+	// happen during a run aren't removed when the run succeeds.
+	generation int
+	// snapshotGeneration is the generation of the latest snapshot.
+	snapshotGeneration int
+	// unknown is true until the first successful run, which processes all
+	// of the files.
+	unknown bool
+}
+
+// newChangedFilesTracker creates a changedFilesTracker.
+func newChangedFilesTracker() *changedFilesTracker {
+	return &changedFilesTracker{
+		paths:              make(map[string]int),
+		snapshotGeneration: -1,
+		unknown:            true,
+	}
+}
+
+// watch makes the DirWatcher report its changes to the tracker. The source
+// is the path watched by the DirWatcher and the tmpDir is the name of the
+// directory in the working directory of the filters that it's copied to.
+func (t *changedFilesTracker) watch(
+	watcher *DirWatcher, source, tmpDir string,
+) {
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return // The watcher uses the absolute path, so it can't fail
+	}
+	watcher.onChange = func(path string) {
+		relPath, err := filepath.Rel(source, path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			return
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		// The files of a new directory can be created before the directory
+		// is watched, so all of them are added
+		filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if relPath, err := filepath.Rel(source, p); err == nil {
+				t.paths[filepath.ToSlash(filepath.Join(tmpDir, relPath))] =
+					t.generation
+			}
+			return nil
+		})
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			// Removed file
+			t.paths[filepath.ToSlash(filepath.Join(tmpDir, relPath))] =
+				t.generation
+		}
+	}
+}
+
+// snapshot returns the sorted list of the files changed since the last
+// successful run. It returns nil if the changes are unknown.
+func (t *changedFilesTracker) snapshot() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.snapshotGeneration = t.generation
+	t.generation++
+	if t.unknown {
+		return nil
+	}
+	result := make([]string, 0, len(t.paths))
+	for path := range t.paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// commit removes the changes included in the latest snapshot, after the run
+// that processed them succeeded.
+func (t *changedFilesTracker) commit() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for path, generation := range t.paths {
+		if generation <= t.snapshotGeneration {
+			delete(t.paths, path)
+		}
+	}
+	t.unknown = false
+}
+
+// prepareChangedFiles sets the changedFiles for the next run in the watch
+// mode and saves them to the file passed to the filters. It's called again
+// when the run restarts after an interruption. Outside of the watch mode it
+// does nothing.
+func (c *RunContext) prepareChangedFiles() error {
+	if c.changedFiles == nil {
+		return nil
+	}
+	changedFiles = c.changedFiles.snapshot()
+	if changedFiles == nil {
+		Logger.Debug("Changed files are unknown, running all of the filters " +
+			"on all of the files.")
+	} else {
+		Logger.Debugf("Changed files: %s", strings.Join(changedFiles, ", "))
+	}
+	path, err := filepath.Abs(filepath.Join(c.DotRegolithPath, changedFilesPath))
+	if err != nil {
+		return WrapErrorf(err, filepathAbsError, c.DotRegolithPath)
+	}
+	data, _ := json.Marshal(changedFiles) // no error
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	changedFilesFile = path
+	return nil
+}
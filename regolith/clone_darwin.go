@@ -0,0 +1,27 @@
+//go:build darwin
+// +build darwin
+
+package regolith
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates the target file as a copy-on-write clone of the source
+// file with clonefile, supported by APFS. The clone shares the data blocks
+// with the source until one of the files is modified, so it's created almost
+// instantly. It returns an error if the file system doesn't support cloning.
+func cloneFile(source, target string) error {
+	// Unlike the copy, clonefile can't replace the existing files
+	err := os.Remove(target)
+	if err != nil && !os.IsNotExist(err) {
+		return WrapErrorf(err, osRemoveError, target)
+	}
+	err = unix.Clonefile(source, target, unix.CLONE_NOFOLLOW)
+	if err != nil {
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package regolith
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates the target file as a copy-on-write clone of the source
+// file with the FICLONE ioctl, supported by Btrfs, XFS and some other file
+// systems. The clone shares the data blocks with the source until one of the
+// files is modified, so it's created almost instantly. It returns an error if
+// the file system doesn't support cloning.
+func cloneFile(source, target string) error {
+	sourceF, err := os.Open(source)
+	if err != nil {
+		return WrapErrorf(err, osOpenError, source)
+	}
+	defer sourceF.Close()
+	targetF, err := os.Create(target)
+	if err != nil {
+		return WrapErrorf(err, osCreateError, target)
+	}
+	err = unix.IoctlFileClone(int(targetF.Fd()), int(sourceF.Fd()))
+	if err != nil {
+		targetF.Close()
+		os.Remove(target)
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	err = targetF.Close()
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, target)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package regolith
+
+// cloneFile placeholder for a function which creates the copy-on-write clones
+// of the files on the systems that support it. The files are always copied
+// on this system.
+func cloneFile(source, target string) error {
+	return WrappedError(notImplementedOnThisSystemError)
+}
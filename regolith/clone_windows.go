@@ -0,0 +1,152 @@
+//go:build windows
+// +build windows
+
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// procGetDiskFreeSpaceW is the function used for getting the size of the
+// clusters of a volume.
+var procGetDiskFreeSpaceW = windows.NewLazySystemDLL(
+	"kernel32.dll").NewProc("GetDiskFreeSpaceW")
+
+// fileSupportsBlockRefcounting is the flag of the file systems that support
+// the block cloning (ReFS).
+const fileSupportsBlockRefcounting = 0x08000000
+
+// cloneChunkSize is the maximal number of bytes cloned with a single
+// FSCTL_DUPLICATE_EXTENTS_TO_FILE call. It must be less than 4 GiB and a
+// multiple of the cluster size.
+const cloneChunkSize = 1 << 30
+
+// duplicateExtentsData is the DUPLICATE_EXTENTS_DATA structure. The handle
+// is stored as uint64, so the structure has the same layout as in C (with
+// the padding after the handle) on the 32-bit systems.
+type duplicateExtentsData struct {
+	FileHandle       uint64
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// cloneFile creates the target file as a copy-on-write clone of the source
+// file with the block cloning of ReFS (FSCTL_DUPLICATE_EXTENTS_TO_FILE). The
+// clone shares the data blocks with the source until one of the files is
+// modified, so it's created almost instantly. It returns an error if the
+// file system doesn't support cloning or the files are on different volumes.
+func cloneFile(source, target string) error {
+	sourceF, err := os.Open(source)
+	if err != nil {
+		return WrapErrorf(err, osOpenError, source)
+	}
+	defer sourceF.Close()
+	sourceHandle := windows.Handle(sourceF.Fd())
+	var fsFlags uint32
+	err = windows.GetVolumeInformationByHandle(
+		sourceHandle, nil, 0, nil, nil, &fsFlags, nil, 0)
+	if err != nil {
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	var fileInfo windows.ByHandleFileInformation
+	err = windows.GetFileInformationByHandle(sourceHandle, &fileInfo)
+	if err != nil {
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	// The sparse files would need the sparse targets
+	if fsFlags&fileSupportsBlockRefcounting == 0 ||
+		fileInfo.FileAttributes&windows.FILE_ATTRIBUTE_SPARSE_FILE != 0 {
+		return WrappedErrorf(
+			cloneFileError+"\nThe file system doesn't support cloning.",
+			source, target)
+	}
+	clusterSize, err := volumeClusterSize(source)
+	if err != nil {
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	size := int64(fileInfo.FileSizeHigh)<<32 | int64(fileInfo.FileSizeLow)
+	targetF, err := os.Create(target)
+	if err != nil {
+		return WrapErrorf(err, osCreateError, target)
+	}
+	err = cloneFileData(sourceHandle, targetF, size, clusterSize)
+	if err != nil {
+		targetF.Close()
+		os.Remove(target)
+		return WrapErrorf(err, cloneFileError, source, target)
+	}
+	err = targetF.Close()
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, target)
+	}
+	return nil
+}
+
+// cloneFileData clones the data of the source file to the empty target file.
+// The cloned regions must be aligned to the clusters, so the last region
+// ends at the end of the last cluster of the file.
+func cloneFileData(
+	sourceHandle windows.Handle, targetF *os.File, size, clusterSize int64,
+) error {
+	err := targetF.Truncate(size)
+	if err != nil {
+		return err
+	}
+	alignedSize := (size + clusterSize - 1) / clusterSize * clusterSize
+	for offset := int64(0); offset < alignedSize; offset += cloneChunkSize {
+		data := duplicateExtentsData{
+			FileHandle:       uint64(sourceHandle),
+			SourceFileOffset: offset,
+			TargetFileOffset: offset,
+			ByteCount:        alignedSize - offset,
+		}
+		if data.ByteCount > cloneChunkSize {
+			data.ByteCount = cloneChunkSize
+		}
+		var bytesReturned uint32
+		err = windows.DeviceIoControl(
+			windows.Handle(targetF.Fd()),
+			windows.FSCTL_DUPLICATE_EXTENTS_TO_FILE,
+			(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)),
+			nil, 0, &bytesReturned, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// volumeClusterSize returns the size of the clusters of the volume with the
+// file.
+func volumeClusterSize(path string) (int64, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, WrapErrorf(err, filepathAbsError, path)
+	}
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	volumePath := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumePathName(
+		pathPtr, &volumePath[0], uint32(len(volumePath)))
+	if err != nil {
+		return 0, err
+	}
+	var sectorsPerCluster, bytesPerSector, freeClusters, totalClusters uint32
+	result, _, err := procGetDiskFreeSpaceW.Call(
+		uintptr(unsafe.Pointer(&volumePath[0])),
+		uintptr(unsafe.Pointer(&sectorsPerCluster)),
+		uintptr(unsafe.Pointer(&bytesPerSector)),
+		uintptr(unsafe.Pointer(&freeClusters)),
+		uintptr(unsafe.Pointer(&totalClusters)))
+	if result == 0 {
+		return 0, err
+	}
+	return int64(sectorsPerCluster) * int64(bytesPerSector), nil
+}
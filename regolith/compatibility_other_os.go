@@ -3,6 +3,17 @@
 
 package regolith
 
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+)
+
 // venvScriptsPath is a folder name between "venv" and "python" that leads to
 // the python executable.
 const venvScriptsPath = "bin"
@@ -19,31 +30,231 @@ func copyFileSecurityInfo(source string, target string) error {
 	return nil
 }
 
-type DirWatcher struct{}
+// processUmask is the umask of the process, read by currentUmask.
+var processUmask struct {
+	once  sync.Once
+	value os.FileMode
+}
 
-func NewDirWatcher(path string) (*DirWatcher, error) {
-	return nil, WrappedError(notImplementedOnThisSystemError)
+// currentUmask returns the umask of the process. The umask can only be read
+// by changing it, so it's read once and restored right away.
+func currentUmask() os.FileMode {
+	processUmask.once.Do(func() {
+		umask := syscall.Umask(0)
+		syscall.Umask(umask)
+		processUmask.value = os.FileMode(umask)
+	})
+	return processUmask.value
 }
 
-func (d *DirWatcher) WaitForChange() error {
-	return WrappedError(notImplementedOnThisSystemError)
+// inheritParentPermissions gives the files and the directories of the target
+// path the permissions of the new files in its parent directory: the default
+// permission bits (0666 for the files, 0777 for the directories and the
+// executable files) without the bits of the umask, and the group of the
+// parent directory if it has the setgid bit.
+func inheritParentPermissions(target string) error {
+	parentInfo, err := os.Stat(filepath.Dir(target))
+	if err != nil {
+		return WrapErrorf(err, osStatErrorAny, filepath.Dir(target))
+	}
+	gid := -1
+	if parentInfo.Mode()&os.ModeSetgid != 0 {
+		if stat, ok := parentInfo.Sys().(*syscall.Stat_t); ok {
+			gid = int(stat.Gid)
+		}
+	}
+	umask := currentUmask()
+	err = filepath.WalkDir(target, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0666)
+		if d.IsDir() || info.Mode()&0111 != 0 {
+			mode = 0777
+		}
+		mode &^= umask
+		if d.IsDir() && gid != -1 {
+			mode |= os.ModeSetgid
+		}
+		if gid != -1 {
+			os.Lchown(p, -1, gid) // Only the members of the group can do it
+		}
+		return os.Chmod(p, mode)
+	})
+	if err != nil {
+		return WrapErrorf(err, osWalkError, target)
+	}
+	return nil
 }
 
-func (d *DirWatcher) WaitForChangeGroup(
-	groupTimeout uint32, interruptionChannel chan string,
-	interruptionMessage string,
-) error {
-	return WrappedError(notImplementedOnThisSystemError)
+// FindMojangDir returns the path to the com.mojang folder of the standard
+// build of Minecraft, installed with mcpelauncher.
+func FindMojangDir() (string, error) {
+	return FindMojangDirOfBuild("")
 }
 
-func (d *DirWatcher) Close() error {
-	return WrappedError(notImplementedOnThisSystemError)
+// FindPreviewDir is not supported outside of Windows, because mcpelauncher
+// doesn't separate the preview versions of the game.
+func FindPreviewDir() (string, error) {
+	return FindMojangDirOfBuild("preview")
 }
 
-func FindMojangDir() (string, error) {
-	return "", WrappedError(notImplementedOnThisSystemError)
+// FindMojangDirOfBuild returns the path to the com.mojang folder of a build
+// of Minecraft (see ExportTarget.Build). Outside of Windows, Minecraft is
+// installed with mcpelauncher, which can be installed natively or from
+// Flathub.
+func FindMojangDirOfBuild(build string) (string, error) {
+	candidates, err := mojangDirCandidates(build)
+	if err != nil {
+		return "", PassError(err)
+	}
+	for _, candidate := range candidates {
+		_, err := os.Stat(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", WrapErrorf(err, osStatErrorAny, candidate)
+		}
+	}
+	return "", WrappedErrorf(
+		"Failed to find the \"com.mojang\" folder of Minecraft.\n"+
+			"Build: %s\nChecked paths:\n%s\n"+
+			"Make sure that Minecraft was started at least once with "+
+			"mcpelauncher.",
+		build, strings.Join(candidates, "\n"))
 }
 
-func FindPreviewDir() (string, error) {
-	return "", WrappedError(notImplementedOnThisSystemError)
+// mojangDirCandidates returns the possible paths to the com.mojang folder of
+// a build of Minecraft in the order in which they should be checked.
+func mojangDirCandidates(build string) ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, WrapError(err, "Failed to get the home directory.")
+	}
+	// The data directory of the native installation of mcpelauncher
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if runtime.GOOS == "darwin" {
+		dataHome = filepath.Join(home, "Library", "Application Support")
+	}
+	nativePath := filepath.Join(dataHome, "mcpelauncher", "games", "com.mojang")
+	flatpakPath := filepath.Join(
+		home, ".var", "app", "io.mrarm.mcpelauncher", "data", "mcpelauncher",
+		"games", "com.mojang")
+	switch build {
+	case "", "standard":
+		return []string{nativePath, flatpakPath}, nil
+	case "mcpelauncher":
+		return []string{nativePath}, nil
+	case "flatpak":
+		return []string{flatpakPath}, nil
+	}
+	return nil, WrappedErrorf(
+		unknownMinecraftBuildError, build,
+		"standard, mcpelauncher, flatpak, android")
+}
+
+// createDirectoryLink creates a symbolic link to the target directory.
+func createDirectoryLink(target, link string) error {
+	return os.Symlink(target, link)
+}
+
+// sameFileSystem returns true if both of the paths are on the same device, so
+// the files can be moved between them without copying.
+func sameFileSystem(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	aStat, ok := aInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	bStat, ok := bInfo.Sys().(*syscall.Stat_t)
+	return ok && aStat.Dev == bStat.Dev
+}
+
+// isHardlinked returns true if the file has more than one hardlink. The info
+// is the result of os.Stat or os.Lstat of the path.
+func isHardlinked(path string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Nlink > 1
+}
+
+// setProcessGroup makes the command start in a new process group, so it can
+// be killed together with its child processes by killProcessTree.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree kills the process group of a command started with
+// setProcessGroup.
+func killProcessTree(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// stopSignals are the signals that stop Regolith, which are forwarded to the
+// process groups of the running sub-processes (see forwardStopSignals).
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// forwardedStopSignals receives the stopSignals while the sub-processes are
+// running. It's nil when they aren't forwarded. It's guarded by the
+// runningSubProcessesMutex.
+var forwardedStopSignals chan os.Signal
+
+// forwardStopSignals starts forwarding the stopSignals to the process groups
+// of the runningSubProcesses, which don't get the signals of the terminal.
+// After forwarding a signal, Regolith sends it to itself again without the
+// handler, so it stops like without the forwarding (unless the signal is
+// handled elsewhere, like by the export, see checkExportInterrupt). It must
+// be called with the runningSubProcessesMutex locked.
+func forwardStopSignals() {
+	if forwardedStopSignals != nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, stopSignals...)
+	forwardedStopSignals = signals
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		runningSubProcessesMutex.Lock()
+		for cmd := range runningSubProcesses {
+			Logger.Debugf("Sending %s to: %s", sig, cmd.String())
+			syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+		}
+		if forwardedStopSignals == signals {
+			stopForwardingStopSignals()
+		}
+		runningSubProcessesMutex.Unlock()
+		syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+	}()
+}
+
+// stopForwardingStopSignals stops forwarding the signals started by
+// forwardStopSignals. It must be called with the runningSubProcessesMutex
+// locked.
+func stopForwardingStopSignals() {
+	if forwardedStopSignals == nil {
+		return
+	}
+	signal.Stop(forwardedStopSignals)
+	close(forwardedStopSignals)
+	forwardedStopSignals = nil
 }
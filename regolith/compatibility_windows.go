@@ -5,7 +5,10 @@ package regolith
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"golang.org/x/sys/windows"
 )
@@ -45,125 +48,159 @@ func copyFileSecurityInfo(source string, target string) error {
 	return nil
 }
 
-// DirWatcher is a struct that provides easy to use methods for watching a
-// directory for changes. It uses FindFirstChangeNotification instead of
-// ReadDirectoryChanges so it doesn't provide any information about the
-// changes, only the fact that something changed.
-//
-// Useful links:
-// https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-findfirstchangenotificationa
-//
-// https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-findnextchangenotification
-//
-// https://pkg.go.dev/golang.org/x/sys@v0.0.0-20220412211240-33da011f77ad/windows
-//
-// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-readdirectorychangesw
-type DirWatcher struct {
-	handle windows.Handle
+// inheritParentPermissions copies the DACL of the parent directory of the
+// target path to the target.
+func inheritParentPermissions(target string) error {
+	parent := filepath.Dir(target)
+	Logger.Debugf("Copying the ACL of %q to %q.", parent, target)
+	err := copyFileSecurityInfo(parent, target)
+	if err != nil {
+		return WrapErrorf(err, copyFileSecurityInfoError, parent, target)
+	}
+	return nil
+}
+
+// FindMojangDir returns path to the com.mojang folder.
+func FindMojangDir() (string, error) {
+	return FindMojangDirOfBuild("")
+}
+
+// FindPreviewDir returns path to the com.mojang folder of Minecraft Preview.
+func FindPreviewDir() (string, error) {
+	return FindMojangDirOfBuild("preview")
 }
 
-// NewDirWatcher creates a new DirWatcher for the given path. It filters out
-// some of the less interesting events like FILE_NOTIFY_CHANGE_LAST_ACCESS.
-func NewDirWatcher(path string) (*DirWatcher, error) {
-	var notifyFilter uint32 = (windows.FILE_NOTIFY_CHANGE_FILE_NAME |
-		windows.FILE_NOTIFY_CHANGE_DIR_NAME |
-		// windows.FILE_NOTIFY_CHANGE_ATTRIBUTES |
-		// windows.FILE_NOTIFY_CHANGE_SIZE |
-		windows.FILE_NOTIFY_CHANGE_LAST_WRITE |
-		// windows.FILE_NOTIFY_CHANGE_LAST_ACCESS |
-		// windows.FILE_NOTIFY_CHANGE_SECURITY |
-		windows.FILE_NOTIFY_CHANGE_CREATION)
-	handle, err := windows.FindFirstChangeNotification(
-		path, true, notifyFilter)
+// FindMojangDirOfBuild returns path to the com.mojang folder of a build of
+// Minecraft (see ExportTarget.Build). The builds that can be installed in
+// multiple ways are searched in all of the possible locations.
+func FindMojangDirOfBuild(build string) (string, error) {
+	candidates, err := mojangDirCandidates(build)
 	if err != nil {
-		return nil, err
+		return "", PassError(err)
 	}
-	return &DirWatcher{handle: handle}, nil
+	for _, candidate := range candidates {
+		_, err := os.Stat(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", WrapErrorf(err, osStatErrorAny, candidate)
+		}
+	}
+	return "", WrappedErrorf(
+		"Failed to find the \"com.mojang\" folder of Minecraft.\n"+
+			"Build: %s\nChecked paths:\n%s",
+		build, strings.Join(candidates, "\n"))
 }
 
-// WaitForChange locks the goroutine until a single change is detected. Note
-// that some changes are reported multiple times, for example saving a file
-// will cause a change to the file and a change to the directory. If you want
-// to report cases like that as one event, see WaitForChangeGroup.
-func (d *DirWatcher) WaitForChange() error {
-	_, err := windows.WaitForSingleObject(d.handle, windows.INFINITE)
-	if err != nil {
-		return err
+// mojangDirCandidates returns the possible paths to the com.mojang folder of
+// a build of Minecraft in the order in which they should be checked.
+func mojangDirCandidates(build string) ([]string, error) {
+	uwpPath := func(packageName string) string {
+		return filepath.Join(
+			os.Getenv("LOCALAPPDATA"), "Packages", packageName, "LocalState",
+			"games", "com.mojang")
 	}
-	err = windows.FindNextChangeNotification(d.handle)
-	if err != nil {
-		return err
+	gdkPath := func(dirName string) string {
+		return filepath.Join(
+			os.Getenv("APPDATA"), dirName, "Users", "Shared", "games",
+			"com.mojang")
 	}
-	return nil
+	educationPath := func(dirName string) string {
+		return filepath.Join(
+			os.Getenv("APPDATA"), dirName, "games", "com.mojang")
+	}
+	switch build {
+	case "", "standard":
+		return []string{
+			uwpPath("Microsoft.MinecraftUWP_8wekyb3d8bbwe"),
+			gdkPath("Minecraft Bedrock"),
+		}, nil
+	case "preview":
+		return []string{
+			uwpPath("Microsoft.MinecraftWindowsBeta_8wekyb3d8bbwe"),
+			gdkPath("Minecraft Bedrock Preview"),
+		}, nil
+	case "gdk":
+		return []string{gdkPath("Minecraft Bedrock")}, nil
+	case "gdk-preview":
+		return []string{gdkPath("Minecraft Bedrock Preview")}, nil
+	case "education":
+		return []string{
+			uwpPath("Microsoft.MinecraftEducationEdition_8wekyb3d8bbwe"),
+			educationPath("Minecraft Education Edition"),
+		}, nil
+	case "education-preview":
+		return []string{
+			uwpPath("Microsoft.MinecraftEducationPreview_8wekyb3d8bbwe"),
+			educationPath("Minecraft Education Preview"),
+		}, nil
+	}
+	return nil, WrappedErrorf(
+		unknownMinecraftBuildError, build,
+		"standard, preview, gdk, gdk-preview, education, education-preview, "+
+			"android")
 }
 
-// WaitForChangeGroup locks a goroutine until it recives a change notification.
-// When that happens it sends the interruptionMessage to the
-// interruptionChannel.
-// Then it continues locking as long as other changes keep coming with
-// intercals less than the given timeout, to group notifications that come
-// in short intervals together.
-func (d *DirWatcher) WaitForChangeGroup(
-	groupTimeout uint32, interruptionChannel chan string,
-	interruptionMessage string,
-) error {
-	err := d.WaitForChange()
+// createDirectoryLink creates a junction to the target directory. Unlike the
+// symbolic links, the junctions don't require administrator privileges or
+// the developer mode.
+func createDirectoryLink(target, link string) error {
+	output, err := exec.Command(
+		"cmd", "/c", "mklink", "/J", link, target).CombinedOutput()
 	if err != nil {
-		return err
-	}
-	// Instantly report the change
-	interruptionChannel <- interruptionMessage
-	// Consume all changes for groupDelay duration
-	for {
-		event, err := windows.WaitForSingleObject(d.handle, groupTimeout)
-		if err != nil {
-			return err
-		}
-		// Possible options: WAIT_OBJECT_0, WAIT_ABANDONED, WAIT_TIMEOUT,
-		// WAIT_FAILED
-		if event == uint32(windows.WAIT_TIMEOUT) ||
-			event == uint32(windows.WAIT_ABANDONED) {
-			break
-		}
-		err = windows.FindNextChangeNotification(d.handle)
-		if err != nil {
-			return err
-		}
+		return WrapErrorf(
+			err, execCommandError+"\nOutput:\n%s", "mklink",
+			strings.TrimSpace(string(output)))
 	}
 	return nil
 }
 
-// Close closes DirWatcher handle.
-func (d *DirWatcher) Close() error {
-	return windows.CloseHandle(d.handle)
+// sameFileSystem returns true if both of the paths are on the same volume, so
+// the files can be moved between them without copying.
+func sameFileSystem(a, b string) bool {
+	a, err := filepath.Abs(a)
+	if err != nil {
+		return false
+	}
+	b, err = filepath.Abs(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(filepath.VolumeName(a), filepath.VolumeName(b))
 }
 
-// FindMojangDir returns path to the com.mojang folder.
-func FindMojangDir() (string, error) {
-	result := filepath.Join(
-		os.Getenv("LOCALAPPDATA"), "Packages",
-		"Microsoft.MinecraftUWP_8wekyb3d8bbwe", "LocalState", "games",
-		"com.mojang")
-	if _, err := os.Stat(result); err != nil {
-		if os.IsNotExist(err) {
-			return "", WrapErrorf(err, osStatErrorIsNotExist, result)
-		}
-		return "", WrapErrorf(err, osStatErrorAny, result)
+// isHardlinked returns true if the file has more than one hardlink. The info
+// from os.Stat doesn't have the number of the links on Windows, so the file
+// is opened to read it.
+func isHardlinked(path string, info os.FileInfo) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
 	}
-	return result, nil
+	defer file.Close()
+	var data windows.ByHandleFileInformation
+	err = windows.GetFileInformationByHandle(windows.Handle(file.Fd()), &data)
+	return err == nil && data.NumberOfLinks > 1
 }
 
-func FindPreviewDir() (string, error) {
-	result := filepath.Join(
-		os.Getenv("LOCALAPPDATA"), "Packages",
-		"Microsoft.MinecraftWindowsBeta_8wekyb3d8bbwe", "LocalState", "games",
-		"com.mojang")
-	if _, err := os.Stat(result); err != nil {
-		if os.IsNotExist(err) {
-			return "", WrapErrorf(err, osStatErrorIsNotExist, result)
-		}
-		return "", WrapErrorf(
-			err, osStatErrorAny, result)
-	}
-	return result, nil
+// setProcessGroup is a placeholder for a function which is necessary only on
+// other operating systems. On Windows the process tree is killed with
+// taskkill.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// forwardStopSignals is a placeholder for a function which is necessary only
+// on other operating systems. On Windows the sub-processes share the console
+// with Regolith, so they get Ctrl+C themselves.
+func forwardStopSignals() {}
+
+// stopForwardingStopSignals is a placeholder for a function which is
+// necessary only on other operating systems.
+func stopForwardingStopSignals() {}
+
+// killProcessTree kills the process of the command and all of its child
+// processes.
+func killProcessTree(cmd *exec.Cmd) error {
+	return exec.Command(
+		"taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
 }
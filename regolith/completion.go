@@ -0,0 +1,182 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// completionShells are the shells supported by the "regolith completion"
+// command, in the order of the keys of completionScripts.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionScripts are the completion scripts of the shells. All of them
+// run Regolith with the "--generate-bash-completion" flag of urfave/cli,
+// which prints the subcommands, the flags and the values that can follow the
+// arguments (see CompleteProfiles and CompleteFilters), so the scripts
+// don't have to be updated with the new versions of Regolith.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for regolith
+_regolith_complete() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null )
+  else
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null )
+  fi
+  COMPREPLY=( $(compgen -W "${opts}" -- "${cur}") )
+  return 0
+}
+
+complete -o bashdefault -o default -F _regolith_complete regolith
+`,
+	"zsh": `#compdef regolith
+# zsh completion for regolith
+_regolith() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} "$cur" --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _regolith regolith
+`,
+	"fish": `# fish completion for regolith
+function __regolith_complete
+    set -l args (commandline -opc)
+    set -l current (commandline -ct)
+    if string match -q -- '-*' $current
+        $args $current --generate-bash-completion 2>/dev/null
+    else
+        $args --generate-bash-completion 2>/dev/null
+    end
+end
+
+complete -c regolith -f -a '(__regolith_complete)'
+`,
+	"powershell": `# PowerShell completion for regolith
+Register-ArgumentCompleter -Native -CommandName regolith, regolith.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $arguments = @($commandAst.CommandElements | Select-Object -Skip 1 |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '' -and -not $wordToComplete.StartsWith('-')) {
+        $arguments = @($arguments | Select-Object -SkipLast 1)
+    }
+    & regolith @arguments --generate-bash-completion 2>$null |
+        Where-Object { $_ -like "$wordToComplete*" } |
+        ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new(
+                $_, $_, 'ParameterValue', $_)
+        }
+}
+`,
+}
+
+// completionScript returns the completion script of the shell.
+func completionScript(shell string) (string, error) {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return "", WrappedErrorf(
+			"Unknown shell %q. The supported shells are: %s.",
+			shell, strings.Join(completionShells, ", "))
+	}
+	return script, nil
+}
+
+// CompleteShells prints the names of the shells supported by the
+// "regolith completion" command, for the completion of its argument.
+func CompleteShells(c *cli.Context) {
+	if completeFlags(c) || c.NArg() > 0 {
+		return
+	}
+	for _, shell := range completionShells {
+		fmt.Fprintln(c.App.Writer, shell)
+	}
+}
+
+// CompleteProfiles prints the names of the profiles of the project in the
+// working directory, for the completion of the commands that take the name of
+// a profile as their first argument.
+func CompleteProfiles(c *cli.Context) {
+	if completeFlags(c) || c.NArg() > 0 {
+		return
+	}
+	config := completionConfig()
+	regolith, _ := config["regolith"].(map[string]interface{})
+	profiles, _ := regolith["profiles"].(map[string]interface{})
+	printCompletions(c, profiles, nil)
+}
+
+// CompleteFilters prints the names of the filters from the filterDefinitions
+// list of the project in the working directory, without the filters that are
+// already in the arguments, for the completion of the commands that take the
+// names of the filters as their arguments.
+func CompleteFilters(c *cli.Context) {
+	if completeFlags(c) {
+		return
+	}
+	filterDefinitions, err := filterDefinitionsFromConfigMap(
+		completionConfig())
+	if err != nil {
+		return
+	}
+	printCompletions(c, filterDefinitions, c.Args().Slice())
+}
+
+// completeFlags prints the flags of the command if the completed argument is
+// a flag, like the default completion of urfave/cli. It returns true if the
+// argument is a flag.
+func completeFlags(c *cli.Context) bool {
+	if len(os.Args) > 2 && strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+		cli.DefaultCompleteWithFlags(c.Command)(c)
+		return true
+	}
+	return false
+}
+
+// completionConfig returns the config of the project in the working
+// directory with the local config merged, or nil if it can't be loaded. The
+// completions can't print any messages, because their output is read by the
+// shell, so the errors are ignored.
+func completionConfig() map[string]interface{} {
+	config, err := loadSharedConfigAsMap()
+	if err != nil {
+		return nil
+	}
+	config, err = mergeLocalConfig(config, ".")
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// printCompletions prints the sorted keys of the map, except for the keys
+// from the skipped list.
+func printCompletions(
+	c *cli.Context, values map[string]interface{}, skipped []string,
+) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !stringInSlice(name, skipped) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(c.App.Writer, name)
+	}
+}
@@ -1,8 +1,13 @@
 package regolith
 
+import (
+	"fmt"
+	"strings"
+)
+
 const StandardLibraryUrl = "github.com/Bedrock-OSS/regolith-filters"
 const ConfigFilePath = "config.json"
-const GitIgnore = "/build\n/.regolith"
+const GitIgnore = "/build\n/.regolith\n/config.local.json"
 
 // Config represents the full configuration file of Regolith, as saved in
 // "config.json".
@@ -16,12 +21,48 @@ type Config struct {
 // ExportTarget is a part of "config.json" that contains export information
 // for a profile, which denotes where compiled files will go.
 type ExportTarget struct {
-	Target    string `json:"target,omitempty"` // The mode of exporting. "develop" or "exact"
-	RpPath    string `json:"rpPath,omitempty"` // Relative or absolute path to resource pack for "exact" export target
-	BpPath    string `json:"bpPath,omitempty"` // Relative or absolute path to resource pack for "exact" export target
-	WorldName string `json:"worldName,omitempty"`
-	WorldPath string `json:"worldPath,omitempty"`
-	ReadOnly  bool   `json:"readOnly"` // Whether the exported files should be read-only
+	Target     string   `json:"target,omitempty"` // The mode of exporting, e.g. "development", "preview" or "exact"
+	RpPath     string   `json:"rpPath,omitempty"` // Relative or absolute path to resource pack for "exact" export target
+	BpPath     string   `json:"bpPath,omitempty"` // Relative or absolute path to resource pack for "exact" export target
+	WorldName  string   `json:"worldName,omitempty"`
+	WorldPath  string   `json:"worldPath,omitempty"`
+	Build      string   `json:"build,omitempty"`      // The build of Minecraft used for finding the "com.mojang" folder
+	MountPath  string   `json:"mountPath,omitempty"`  // The mount point of the Android device for the "android" build
+	ReadOnly   bool     `json:"readOnly"`             // Whether the exported files should be read-only
+	Symlink    bool     `json:"symlink,omitempty"`    // Whether the export paths should be links to the "build" folder
+	Exclude    []string `json:"exclude,omitempty"`    // Glob patterns of the files that shouldn't be exported
+	PreExport  []string `json:"preExport,omitempty"`  // Commands that run before the export
+	PostExport []string `json:"postExport,omitempty"` // Commands that run after the export
+	Report     bool     `json:"report,omitempty"`     // Whether to print the changes of the exported files
+	ReportPath string   `json:"reportPath,omitempty"` // The file for saving the changes of the exported files
+	// What happens when the names of the exported files don't work on every
+	// platform, one of the fileNameChecks ("warn" if empty)
+	FileNameCheck string `json:"fileNameCheck,omitempty"`
+	// What happens when the names of the exported files use different
+	// Unicode normalization forms, one of the unicodeNormalizationModes
+	// ("warn" if empty)
+	UnicodeNormalization string `json:"unicodeNormalization,omitempty"`
+	// How the permissions of the exported packs are set, one of the
+	// exportAclModes ("inherit" if empty)
+	Acl string `json:"acl,omitempty"`
+
+	// Properties of the "sftp" export target
+	Host         string `json:"host,omitempty"`         // The address of the server
+	Port         int    `json:"port,omitempty"`         // The SSH port of the server, 22 by default
+	User         string `json:"user,omitempty"`         // The name of the user on the server
+	IdentityFile string `json:"identityFile,omitempty"` // The private key, the SSH agent is used by default
+	Path         string `json:"path,omitempty"`         // The directory on the server with the development pack folders
+
+	// Properties of the "adb" export target (also uses "path" for the
+	// com.mojang folder on the device)
+	Device string `json:"device,omitempty"` // The serial number of the device, required if multiple devices are connected
+
+	// Properties of the "s3" export target
+	Endpoint string `json:"endpoint,omitempty"` // The URL of an S3-compatible service, AWS is used by default
+	Region   string `json:"region,omitempty"`   // The region of the bucket
+	Bucket   string `json:"bucket,omitempty"`   // The name of the bucket
+	Prefix   string `json:"prefix,omitempty"`   // The prefix of the keys of the uploaded archives
+	Archive  string `json:"archive,omitempty"`  // The archive export target used for packing the packs
 }
 
 // Packs is a part of "config.json" that points to the source behavior and
@@ -34,14 +75,41 @@ type Packs struct {
 // RegolithProject is a part of "config.json" whith the regolith namespace
 // within the Minecraft Project Schema
 type RegolithProject struct {
+	FormatVersion     string                     `json:"formatVersion,omitempty"`
 	Profiles          map[string]Profile         `json:"profiles,omitempty"`
 	FilterDefinitions map[string]FilterInstaller `json:"filterDefinitions"`
 	DataPath          string                     `json:"dataPath,omitempty"`
 	UseAppData        bool                       `json:"useAppData,omitempty"`
+	LogLevel          string                     `json:"logLevel,omitempty"`
+	AdditionalPacks   map[string]Packs           `json:"additionalPacks,omitempty"`
+	WatchIgnore       []string                   `json:"watchIgnore,omitempty"`
+	WatchDelay        int                        `json:"watchDelay,omitempty"`
+	TmpSetup          string                     `json:"tmpSetup,omitempty"`
+	Webhooks          []Webhook                  `json:"webhooks,omitempty"`
+	Symlinks          string                     `json:"symlinks,omitempty"`
+	Sandbox           string                     `json:"sandbox,omitempty"`
 }
 
-// ConfigFromObject creates a "Config" object from map[string]interface{}
+// ConfigFromObject creates a "Config" object from map[string]interface{}.
+// The environment variables are expanded first (see expandEnvVariables), the
+// configs in the older formats are upgraded (see migrateConfig), the
+// profiles that extend other profiles are merged with them (see
+// resolveProfileInheritance), and the result is validated against the schema
+// of config.json (see validateConfigSchema).
 func ConfigFromObject(obj map[string]interface{}) (*Config, error) {
+	obj = expandEnvVariablesInConfig(obj)
+	_, err := migrateConfig(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	err = resolveProfileInheritance(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	err = validateConfigSchema(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
 	result := &Config{}
 	// Name
 	name, ok := obj["name"].(string)
@@ -164,6 +232,109 @@ func RegolithProjectFromObject(
 		}
 	}
 	result.UseAppData = useAppData
+	// FormatVersion (optional, the configs are upgraded by migrateConfig)
+	formatVersion, _ := obj["formatVersion"].(string)
+	result.FormatVersion = formatVersion
+	// LogLevel (optional, set by the --debug flag by default)
+	if logLevelObj, ok := obj["logLevel"]; ok {
+		logLevel, ok := logLevelObj.(string)
+		if !ok || !stringInSlice(logLevel, logLevels) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "logLevel",
+				strings.Join(logLevels, ", "))
+		}
+		result.LogLevel = logLevel
+	}
+	// AdditionalPacks (optional)
+	if additionalPacksObj, ok := obj["additionalPacks"]; ok {
+		additionalPacksMap, ok := additionalPacksObj.(map[string]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "additionalPacks", "object")
+		}
+		additionalPacks, err := AdditionalPacksFromObject(additionalPacksMap)
+		if err != nil {
+			return result, WrapErrorf(
+				err, jsonPropertyParseError, "additionalPacks")
+		}
+		result.AdditionalPacks = additionalPacks
+	}
+	// WatchIgnore (optional)
+	if watchIgnoreObj, ok := obj["watchIgnore"]; ok {
+		watchIgnore, ok := watchIgnoreObj.([]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "watchIgnore", "array")
+		}
+		result.WatchIgnore = make([]string, len(watchIgnore))
+		for i, pattern := range watchIgnore {
+			pattern, ok := pattern.(string)
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError,
+					fmt.Sprintf("watchIgnore->%d", i), "string")
+			}
+			if err := validateGlobPattern(pattern); err != nil {
+				return result, PassError(err)
+			}
+			result.WatchIgnore[i] = pattern
+		}
+	}
+	// WatchDelay (optional, defaultWatchDelay by default)
+	result.WatchDelay = defaultWatchDelay
+	if watchDelayObj, ok := obj["watchDelay"]; ok {
+		watchDelay, ok := watchDelayObj.(float64)
+		if !ok || watchDelay < 0 || watchDelay != float64(int(watchDelay)) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "watchDelay", "non-negative integer")
+		}
+		result.WatchDelay = int(watchDelay)
+	}
+	// TmpSetup (optional, tmpSetupCopy by default)
+	result.TmpSetup = tmpSetupCopy
+	if tmpSetupObj, ok := obj["tmpSetup"]; ok {
+		tmpSetup, ok := tmpSetupObj.(string)
+		if !ok || !stringInSlice(tmpSetup, tmpSetupStrategies) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "tmpSetup",
+				strings.Join(tmpSetupStrategies, ", "))
+		}
+		result.TmpSetup = tmpSetup
+	}
+	// Symlinks (optional, symlinkCopyAsLink by default)
+	result.Symlinks = symlinkCopyAsLink
+	if symlinksObj, ok := obj["symlinks"]; ok {
+		symlinks, ok := symlinksObj.(string)
+		if !ok || !stringInSlice(symlinks, symlinkPolicies) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "symlinks",
+				strings.Join(symlinkPolicies, ", "))
+		}
+		result.Symlinks = symlinks
+	}
+	// Sandbox (optional, "off" by default)
+	if sandboxObj, ok := obj["sandbox"]; ok {
+		sandbox, ok := sandboxObj.(string)
+		if !ok || !stringInSlice(sandbox, sandboxModes) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "sandbox",
+				strings.Join(sandboxModes, ", "))
+		}
+		result.Sandbox = sandbox
+	}
+	// Webhooks (optional)
+	if webhooksObj, ok := obj["webhooks"]; ok {
+		webhooksList, ok := webhooksObj.([]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "webhooks", "array")
+		}
+		webhooks, err := WebhooksFromObject(webhooksList)
+		if err != nil {
+			return result, PassError(err)
+		}
+		result.Webhooks = webhooks
+	}
 	return result, nil
 }
 
@@ -194,8 +365,124 @@ func ExportTargetFromObject(obj map[string]interface{}) (ExportTarget, error) {
 	// WorldPath - can be empty
 	worldPath, _ := obj["worldPath"].(string)
 	result.WorldPath = worldPath
+	// Build - can be empty
+	build, _ := obj["build"].(string)
+	result.Build = build
+	// MountPath - can be empty
+	mountPath, _ := obj["mountPath"].(string)
+	result.MountPath = mountPath
+	// Host - can be empty
+	host, _ := obj["host"].(string)
+	result.Host = host
+	// Port - can be empty
+	if port, ok := obj["port"]; ok {
+		port, ok := port.(float64)
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "port", "integer")
+		}
+		result.Port = int(port)
+	}
+	// User - can be empty
+	user, _ := obj["user"].(string)
+	result.User = user
+	// IdentityFile - can be empty
+	identityFile, _ := obj["identityFile"].(string)
+	result.IdentityFile = identityFile
+	// Path - can be empty
+	path, _ := obj["path"].(string)
+	result.Path = path
+	// Device - can be empty
+	device, _ := obj["device"].(string)
+	result.Device = device
+	// Endpoint - can be empty
+	endpoint, _ := obj["endpoint"].(string)
+	result.Endpoint = endpoint
+	// Region - can be empty
+	region, _ := obj["region"].(string)
+	result.Region = region
+	// Bucket - can be empty
+	bucket, _ := obj["bucket"].(string)
+	result.Bucket = bucket
+	// Prefix - can be empty
+	prefix, _ := obj["prefix"].(string)
+	result.Prefix = prefix
+	// Archive - can be empty
+	archive, _ := obj["archive"].(string)
+	result.Archive = archive
 	// ReadOnly - can be empty
 	readOnly, _ := obj["readOnly"].(bool)
 	result.ReadOnly = readOnly
+	// Symlink - can be empty
+	symlink, _ := obj["symlink"].(bool)
+	result.Symlink = symlink
+	// Exclude - can be empty
+	if excludeObj, ok := obj["exclude"]; ok {
+		exclude, ok := excludeObj.([]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "exclude", "array")
+		}
+		result.Exclude = make([]string, len(exclude))
+		for i, pattern := range exclude {
+			pattern, ok := pattern.(string)
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError,
+					fmt.Sprintf("exclude->%d", i), "string")
+			}
+			if err := validateGlobPattern(pattern); err != nil {
+				return result, PassError(err)
+			}
+			result.Exclude[i] = pattern
+		}
+	}
+	// PreExport - can be empty
+	preExport, err := exportHookFromObject(obj, "preExport")
+	if err != nil {
+		return result, PassError(err)
+	}
+	result.PreExport = preExport
+	// PostExport - can be empty
+	postExport, err := exportHookFromObject(obj, "postExport")
+	if err != nil {
+		return result, PassError(err)
+	}
+	result.PostExport = postExport
+	// Report - can be empty
+	report, _ := obj["report"].(bool)
+	result.Report = report
+	// ReportPath - can be empty
+	reportPath, _ := obj["reportPath"].(string)
+	result.ReportPath = reportPath
+	// FileNameCheck - can be empty
+	if fileNameCheckObj, ok := obj["fileNameCheck"]; ok {
+		fileNameCheck, ok := fileNameCheckObj.(string)
+		if !ok || !stringInSlice(fileNameCheck, fileNameChecks) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "fileNameCheck",
+				strings.Join(fileNameChecks, ", "))
+		}
+		result.FileNameCheck = fileNameCheck
+	}
+	// UnicodeNormalization - can be empty
+	if normalizationObj, ok := obj["unicodeNormalization"]; ok {
+		normalization, ok := normalizationObj.(string)
+		if !ok || !stringInSlice(normalization, unicodeNormalizationModes) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "unicodeNormalization",
+				strings.Join(unicodeNormalizationModes, ", "))
+		}
+		result.UnicodeNormalization = normalization
+	}
+	// Acl - can be empty
+	if aclObj, ok := obj["acl"]; ok {
+		acl, ok := aclObj.(string)
+		if !ok || !stringInSlice(acl, exportAclModes) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "acl", strings.Join(exportAclModes, ", "))
+		}
+		result.Acl = acl
+	}
 	return result, nil
 }
@@ -0,0 +1,238 @@
+package regolith
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configRootProperties are the properties of the root of config.json. The
+// paths used by the "regolith config" command that don't start with one of
+// them are relative to the regolith namespace.
+var configRootProperties = []string{
+	"$schema", "name", "author", "packs", "regolith"}
+
+// parseConfigPath splits the path used by the "regolith config" command into
+// the property names and array indices. The path can use dots
+// ("profiles.default.export.target") or the JSON Pointer syntax
+// ("/regolith/profiles/default/export/target"), which supports the names
+// with dots ("~1" is "/" and "~0" is "~"). The dotted paths that don't start
+// with a property of the root of config.json are relative to the regolith
+// namespace.
+func parseConfigPath(path string) ([]string, error) {
+	var keys []string
+	if strings.HasPrefix(path, "/") {
+		for _, key := range strings.Split(path[1:], "/") {
+			key = strings.ReplaceAll(key, "~1", "/")
+			keys = append(keys, strings.ReplaceAll(key, "~0", "~"))
+		}
+	} else {
+		keys = strings.Split(path, ".")
+		if !stringInSlice(keys[0], configRootProperties) {
+			keys = append([]string{"regolith"}, keys...)
+		}
+	}
+	for _, key := range keys {
+		if key == "" {
+			return nil, WrappedErrorf(
+				"Invalid path of a config value.\nPath: %s\n"+
+					"The names of the properties can't be empty.", path)
+		}
+	}
+	return keys, nil
+}
+
+// getConfigValue returns the value from the path (see parseConfigPath).
+func getConfigValue(value interface{}, keys []string) (interface{}, error) {
+	for i, key := range keys {
+		switch container := value.(type) {
+		case map[string]interface{}:
+			item, ok := container[key]
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPathMissingError, strings.Join(keys[:i+1], "->"))
+			}
+			value = item
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil, WrappedErrorf(
+					jsonPathMissingError, strings.Join(keys[:i+1], "->"))
+			}
+			value = container[index]
+		default:
+			return nil, WrappedErrorf(
+				jsonPathTypeError, strings.Join(keys[:i], "->"),
+				"object or array")
+		}
+	}
+	return value, nil
+}
+
+// setConfigValue returns the value with the value from the path (see
+// parseConfigPath) replaced by the new value. The missing objects on the
+// path are created. The index of an array can be equal to its length, which
+// adds the new value at the end. If the new value is nil, the value is
+// removed from the path instead.
+func setConfigValue(
+	value interface{}, keys []string, newValue interface{}, path []string,
+) (interface{}, error) {
+	if len(keys) == 0 {
+		return newValue, nil
+	}
+	key := keys[0]
+	path = append(path[:len(path):len(path)], key)
+	switch container := value.(type) {
+	case nil:
+		if newValue == nil {
+			return nil, WrappedErrorf(
+				jsonPathMissingError, strings.Join(path, "->"))
+		}
+		item, err := setConfigValue(nil, keys[1:], newValue, path)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		return map[string]interface{}{key: item}, nil
+	case map[string]interface{}:
+		if newValue == nil && len(keys) == 1 {
+			if _, ok := container[key]; !ok {
+				return nil, WrappedErrorf(
+					jsonPathMissingError, strings.Join(path, "->"))
+			}
+			delete(container, key)
+			return container, nil
+		}
+		item, err := setConfigValue(container[key], keys[1:], newValue, path)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		container[key] = item
+		return container, nil
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(container) ||
+			(index == len(container) && (newValue == nil || len(keys) > 1)) {
+			return nil, WrappedErrorf(
+				"Invalid index of an array.\nJSON Path: %s\n"+
+					"The array has %d items.",
+				strings.Join(path, "->"), len(container))
+		}
+		if newValue == nil && len(keys) == 1 {
+			return append(container[:index], container[index+1:]...), nil
+		}
+		if index == len(container) {
+			return append(container, newValue), nil
+		}
+		item, err := setConfigValue(container[index], keys[1:], newValue, path)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		container[index] = item
+		return container, nil
+	}
+	return nil, WrappedErrorf(
+		jsonPathTypeError, strings.Join(path[:len(path)-1], "->"),
+		"object or array")
+}
+
+// parseConfigValue parses the value passed to "regolith config set". The
+// values that are valid JSON (like "true", "5", "[1, 2]" or "\"text\"") are
+// parsed, and the other values are used as strings.
+func parseConfigValue(text string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err == nil && value != nil {
+		return value
+	}
+	return text
+}
+
+// editConfigFile changes the value from the path (see parseConfigPath) in
+// config.json or, if "local" is true, in config.local.json. If the new value
+// is nil, the value is removed. The file is saved only if the changed config
+// (merged like in LoadConfigAsMap) is still valid. Only the changed value is
+// modified in the file (see editJsonc), so the comments and the order of the
+// properties are preserved.
+func editConfigFile(path string, newValue interface{}, local bool) error {
+	keys, err := parseConfigPath(path)
+	if err != nil {
+		return PassError(err)
+	}
+	shared, err := loadSharedConfigAsMap()
+	if err != nil {
+		return PassError(err)
+	}
+	localConfig := map[string]interface{}{}
+	var localData []byte
+	if local {
+		data, err := ioutil.ReadFile(LocalConfigFilePath)
+		if err == nil {
+			localData = data
+			err = unmarshalJsonc(data, &localConfig)
+			if err != nil {
+				return WrapErrorf(err, jsonUnmarshalError, LocalConfigFilePath)
+			}
+		} else if !os.IsNotExist(err) {
+			return WrapErrorf(err, fileReadError, LocalConfigFilePath)
+		}
+	}
+	filePath, edited := ConfigFilePath, shared
+	if local {
+		filePath, edited = LocalConfigFilePath, localConfig
+	}
+	result, err := setConfigValue(edited, keys, newValue, nil)
+	if err != nil {
+		return WrapErrorf(err, "Failed to change the value.\nFile: %s", filePath)
+	}
+	edited, _ = result.(map[string]interface{}) // root is always an object
+	if local {
+		localConfig = edited
+	} else {
+		shared = edited
+	}
+
+	// Validate the config before saving it
+	merged, err := mergeUserProjectDefaults(shared)
+	if err != nil {
+		return PassError(err)
+	}
+	if !local {
+		merged, err = mergeLocalConfig(merged, ".")
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to load the local config.\nPath: %s",
+				LocalConfigFilePath)
+		}
+	} else {
+		merged, _ = mergeConfigValues(merged, localConfig, nil).(map[string]interface{})
+	}
+	if _, err := ConfigFromObject(merged); err != nil {
+		return WrapErrorf(
+			err, "The changed config is invalid. The %s file wasn't saved.",
+			filePath)
+	}
+	// The new local config is created from scratch
+	var jsonBytes []byte
+	if local && localData == nil {
+		jsonBytes, _ = json.MarshalIndent(edited, "", "\t")
+	} else {
+		data := localData
+		if !local {
+			data, err = ioutil.ReadFile(ConfigFilePath)
+			if err != nil {
+				return WrapErrorf(err, fileReadError, ConfigFilePath)
+			}
+		}
+		jsonBytes, err = editJsonc(data, keys, newValue)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to change the value.\nFile: %s", filePath)
+		}
+	}
+	err = ioutil.WriteFile(filePath, jsonBytes, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, filePath)
+	}
+	return nil
+}
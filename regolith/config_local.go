@@ -0,0 +1,62 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LocalConfigFilePath is the path to the optional file with the settings of
+// the current computer, like the export paths. The file is merged over
+// config.json (see mergeLocalConfig) and shouldn't be committed to the
+// repository of the project.
+const LocalConfigFilePath = "config.local.json"
+
+// mergeLocalConfig returns the config.json file (parsed to a map) with the
+// config.local.json file from the root of the project merged over it (see
+// mergeConfigValues). If the project doesn't have the local config, the
+// config is returned unchanged.
+func mergeLocalConfig(
+	config map[string]interface{}, projectRoot string,
+) (map[string]interface{}, error) {
+	path := filepath.Join(projectRoot, LocalConfigFilePath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	var localConfig map[string]interface{}
+	err = unmarshalJsonc(data, &localConfig)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	merged, _ := mergeConfigValues(config, localConfig, nil).(map[string]interface{})
+	return merged, nil
+}
+
+// mergeConfigValues deep-merges the value from the local config over the
+// value from config.json. The objects are merged recursively, the filters of
+// the profiles are merged like the filters of the profiles that extend other
+// profiles (see mergeProfileFilters) and the other values are replaced. The
+// path is the JSON path of the value.
+func mergeConfigValues(base, override interface{}, path []string) interface{} {
+	baseMap, ok1 := base.(map[string]interface{})
+	overrideMap, ok2 := override.(map[string]interface{})
+	if ok1 && ok2 {
+		result := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+		for key, value := range baseMap {
+			result[key] = value
+		}
+		for key, value := range overrideMap {
+			result[key] = mergeConfigValues(
+				baseMap[key], value, append(path[:len(path):len(path)], key))
+		}
+		return result
+	}
+	if len(path) == 4 && path[0] == "regolith" && path[1] == "profiles" &&
+		path[3] == "filters" {
+		return mergeProfileFilters(base, override)
+	}
+	return override
+}
@@ -0,0 +1,183 @@
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// LatestConfigFormatVersion is the format version of the config.json files
+// created by this version of Regolith. The older configs are upgraded with
+// the configMigrations.
+const LatestConfigFormatVersion = "1.1.0"
+
+// defaultConfigFormatVersion is the format version of the config.json files
+// without the "formatVersion" property, created before it was added.
+const defaultConfigFormatVersion = "1.0.0"
+
+// configMigration upgrades config.json (parsed to a map) from one format
+// version to the next one. The migrations modify the config in place.
+type configMigration struct {
+	From        string
+	To          string
+	Description string
+	Migrate     func(config map[string]interface{})
+}
+
+// configMigrations are the migrations of config.json, sorted by their
+// versions. Every breaking change of the config format must add a migration
+// and update LatestConfigFormatVersion.
+var configMigrations = []configMigration{
+	{
+		From:        "1.0.0",
+		To:          "1.1.0",
+		Description: "Rename the deprecated \"script\" property of the Java filters to \"path\".",
+		Migrate:     migrateJavaScriptProperty,
+	},
+}
+
+// migrateJavaScriptProperty renames the "script" property of the Java filter
+// definitions to "path".
+func migrateJavaScriptProperty(config map[string]interface{}) {
+	regolith, _ := config["regolith"].(map[string]interface{})
+	filterDefinitions, _ := regolith["filterDefinitions"].(map[string]interface{})
+	for _, definitionObj := range filterDefinitions {
+		definition, ok := definitionObj.(map[string]interface{})
+		if !ok || definition["runWith"] != "java" {
+			continue
+		}
+		script, ok := definition["script"]
+		if !ok {
+			continue
+		}
+		if _, ok := definition["path"]; !ok {
+			definition["path"] = script
+		}
+		delete(definition, "script")
+	}
+}
+
+// configFormatVersion returns the format version of config.json (parsed to
+// a map), from the "formatVersion" property of the regolith namespace.
+func configFormatVersion(config map[string]interface{}) (string, error) {
+	regolith, ok := config["regolith"].(map[string]interface{})
+	if !ok {
+		return defaultConfigFormatVersion, nil
+	}
+	versionObj, ok := regolith["formatVersion"]
+	if !ok {
+		return defaultConfigFormatVersion, nil
+	}
+	version, ok := versionObj.(string)
+	if !ok || !semver.IsValid("v"+version) {
+		return "", WrappedErrorf(
+			"Invalid format version of the config.\nJSON Path: "+
+				"regolith->formatVersion\nValue: %v", versionObj)
+	}
+	return version, nil
+}
+
+// migrateConfig upgrades config.json (parsed to a map) to
+// LatestConfigFormatVersion. It returns the migrations that were applied,
+// which is an empty list if the config is already up to date. The config is
+// modified in place. The configs created by newer versions of Regolith
+// return an error.
+func migrateConfig(config map[string]interface{}) ([]configMigration, error) {
+	version, err := configFormatVersion(config)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	if semver.Compare("v"+version, "v"+LatestConfigFormatVersion) > 0 {
+		return nil, WrappedErrorf(
+			"The config uses a format version newer than the versions "+
+				"supported by this version of Regolith.\n"+
+				"Format version: %s\nLatest supported format version: %s\n"+
+				"Please update Regolith.",
+			version, LatestConfigFormatVersion)
+	}
+	var applied []configMigration
+	for _, migration := range configMigrations {
+		if semver.Compare("v"+version, "v"+migration.To) >= 0 {
+			continue
+		}
+		migration.Migrate(config)
+		applied = append(applied, migration)
+		version = migration.To
+	}
+	if len(applied) > 0 {
+		if regolith, ok := config["regolith"].(map[string]interface{}); ok {
+			regolith["formatVersion"] = version
+		}
+	}
+	return applied, nil
+}
+
+// configDiff returns the lines of the config changed by the migrations, in
+// a format similar to the unified diff, without the line numbers. The
+// configs are compared as indented JSON.
+func configDiff(before, after map[string]interface{}) string {
+	beforeJson, _ := json.MarshalIndent(before, "", "\t") // no error
+	afterJson, _ := json.MarshalIndent(after, "", "\t")   // no error
+	a := strings.Split(string(beforeJson), "\n")
+	b := strings.Split(string(afterJson), "\n")
+	// The longest common subsequence of the lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	const context = 2
+	type diffLine struct {
+		prefix string
+		text   string
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{" ", a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{"-", a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{"+", b[j]})
+			j++
+		}
+	}
+	// Print the changed lines with a few unchanged lines around them
+	var result strings.Builder
+	lastPrinted := -1
+	for index, line := range lines {
+		near := false
+		for k := index - context; k <= index+context; k++ {
+			if k >= 0 && k < len(lines) && lines[k].prefix != " " {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if lastPrinted != -1 && index != lastPrinted+1 {
+			result.WriteString("...\n")
+		}
+		fmt.Fprintf(&result, "%s %s\n", line.prefix, line.text)
+		lastPrinted = index
+	}
+	return result.String()
+}
@@ -0,0 +1,380 @@
+package regolith
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configSchemaJson is the JSON Schema of the config.json file. It's checked
+// before parsing the config, so the errors point to the exact property
+// instead of failing somewhere deep in the parsing functions.
+//
+//go:embed config_schema.json
+var configSchemaJson []byte
+
+// jsonSchema is the subset of JSON Schema used by config_schema.json. The
+// "$ref" property can only point to the "definitions" of the root schema.
+// The descriptions are only used by the language server (see Lsp).
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum"`
+	Maximum              *float64               `json:"maximum"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+	Description          string                 `json:"description"`
+}
+
+// loadConfigSchema parses the embedded schema of config.json.
+func loadConfigSchema() (*jsonSchema, error) {
+	var schema jsonSchema
+	err := json.Unmarshal(configSchemaJson, &schema)
+	if err != nil {
+		return nil, WrapError(err, "Failed to load the schema of config.json.")
+	}
+	return &schema, nil
+}
+
+// configSchemaError is a single problem found by validateConfigSchema.
+type configSchemaError struct {
+	path       string
+	message    string
+	suggestion string
+}
+
+func (e configSchemaError) String() string {
+	result := fmt.Sprintf("JSON Path: %s\n%s", e.path, e.message)
+	if e.suggestion != "" {
+		result += "\nSuggestion: " + e.suggestion
+	}
+	return result
+}
+
+// validateConfigSchema checks the config.json file (parsed to a map) against
+// the embedded schema. The error lists all of the problems found in the
+// file, with their JSON paths and suggestions how to fix them.
+func validateConfigSchema(config map[string]interface{}) error {
+	schema, err := loadConfigSchema()
+	if err != nil {
+		return PassError(err)
+	}
+	var problems []configSchemaError
+	schema.validate(schema, config, "", &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].path < problems[j].path
+	})
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.String()
+	}
+	return WrappedErrorf(
+		"The %s file doesn't match its schema.\n\n%s",
+		ConfigFilePath, strings.Join(messages, "\n\n"))
+}
+
+// validate adds the problems found in the value to the list. The root is the
+// schema that contains the definitions used by "$ref".
+func (s *jsonSchema) validate(
+	root *jsonSchema, value interface{}, path string,
+	problems *[]configSchemaError,
+) {
+	if s.Ref != "" {
+		if definition := s.resolve(root); definition != nil {
+			definition.validate(root, value, path, problems)
+		}
+		return
+	}
+	report := func(message, suggestion string) {
+		*problems = append(*problems, configSchemaError{
+			path: path, message: message, suggestion: suggestion})
+	}
+	types := s.types()
+	if len(types) > 0 && !matchesJsonTypes(value, types) {
+		report(
+			fmt.Sprintf(
+				"Expected type: %s\nFound type: %s",
+				strings.Join(types, " or "), jsonTypeName(value)),
+			jsonTypeSuggestion(value, types))
+		return
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(root, value, path, problems)
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range value {
+				s.Items.validate(
+					root, item, joinJsonPath(path, strconv.Itoa(i)), problems)
+			}
+		}
+	case string:
+		if len(s.Enum) > 0 && !stringInSlice(value, s.Enum) {
+			suggestion := ""
+			if closest := closestString(value, s.Enum); closest != "" {
+				suggestion = fmt.Sprintf("Did you mean %q?", closest)
+			}
+			report(
+				fmt.Sprintf(
+					"Invalid value: %q\nValid values: %s",
+					value, strings.Join(s.Enum, ", ")),
+				suggestion)
+		}
+	case float64:
+		switch {
+		case s.Minimum != nil && value < *s.Minimum:
+			report(fmt.Sprintf(
+				"The value must be at least %v.\nFound: %v", *s.Minimum, value),
+				"")
+		case s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum:
+			report(fmt.Sprintf(
+				"The value must be greater than %v.\nFound: %v",
+				*s.ExclusiveMinimum, value), "")
+		case s.Maximum != nil && value > *s.Maximum:
+			report(fmt.Sprintf(
+				"The value must be at most %v.\nFound: %v", *s.Maximum, value),
+				"")
+		}
+	}
+}
+
+// resolve returns the definition from the root schema that the "$ref" of the
+// schema points to, or the schema itself if it doesn't have a "$ref". It
+// returns nil if the definition doesn't exist.
+func (s *jsonSchema) resolve(root *jsonSchema) *jsonSchema {
+	if s.Ref == "" {
+		return s
+	}
+	return root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+}
+
+// property returns the schema of a property of the objects or an item of the
+// arrays described by the schema, or nil if it's unknown.
+func (s *jsonSchema) property(root *jsonSchema, key string) *jsonSchema {
+	s = s.resolve(root)
+	switch {
+	case s == nil:
+		return nil
+	case s.Properties[key] != nil:
+		return s.Properties[key]
+	case s.AdditionalProperties != nil:
+		return s.AdditionalProperties
+	}
+	if _, err := strconv.Atoi(key); err == nil {
+		return s.Items
+	}
+	return nil
+}
+
+// validateObject validates the properties of a JSON object.
+func (s *jsonSchema) validateObject(
+	root *jsonSchema, value map[string]interface{}, path string,
+	problems *[]configSchemaError,
+) {
+	for _, required := range s.Required {
+		if _, ok := value[required]; ok {
+			continue
+		}
+		// Suggest renaming a misspelled property
+		var unknown []string
+		for key := range value {
+			if _, ok := s.Properties[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		suggestion := fmt.Sprintf("Add the %q property.", required)
+		if closest := closestString(required, unknown); closest != "" {
+			suggestion = fmt.Sprintf(
+				"Rename the %q property to %q.", closest, required)
+		}
+		*problems = append(*problems, configSchemaError{
+			path:       joinJsonPath(path, required),
+			message:    "Required property is missing.",
+			suggestion: suggestion,
+		})
+	}
+	for key, propertyValue := range value {
+		propertySchema, ok := s.Properties[key]
+		if !ok {
+			propertySchema = s.AdditionalProperties
+		}
+		if propertySchema != nil {
+			propertySchema.validate(
+				root, propertyValue, joinJsonPath(path, key), problems)
+		}
+	}
+}
+
+// types returns the types allowed by the "type" property of the schema,
+// which can be a string or a list of strings.
+func (s *jsonSchema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		result := make([]string, 0, len(t))
+		for _, item := range t {
+			if item, ok := item.(string); ok {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// jsonTypeName returns the JSON Schema type of a value parsed from JSON.
+func jsonTypeName(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// matchesJsonTypes returns true if the value has one of the JSON Schema
+// types. Integers are also numbers.
+func matchesJsonTypes(value interface{}, types []string) bool {
+	name := jsonTypeName(value)
+	for _, t := range types {
+		if t == name || (t == "number" && name == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeSuggestion returns a suggestion for fixing a value with a wrong
+// type, or an empty string if there is no simple fix.
+func jsonTypeSuggestion(value interface{}, types []string) string {
+	encoded, _ := json.Marshal(value) // no error
+	for _, t := range types {
+		switch t {
+		case "string":
+			switch value.(type) {
+			case float64, bool:
+				return fmt.Sprintf("Put the value in quotes: \"%s\"", encoded)
+			}
+		case "number", "integer":
+			text, ok := value.(string)
+			if !ok {
+				break
+			}
+			if number, err := strconv.ParseFloat(text, 64); err == nil {
+				if t == "number" || number == float64(int64(number)) {
+					return fmt.Sprintf("Remove the quotes: %s", text)
+				}
+			}
+		case "boolean":
+			if value == "true" || value == "false" {
+				return fmt.Sprintf("Remove the quotes: %s", value)
+			}
+		case "array":
+			switch value.(type) {
+			case string, float64, bool:
+				return fmt.Sprintf("Put the value in a list: [%s]", encoded)
+			}
+		}
+	}
+	if _, ok := value.(float64); ok && stringInSlice("integer", types) {
+		return "Use a whole number."
+	}
+	return ""
+}
+
+// joinJsonPath appends a property name or an array index to the JSON path,
+// using the "->" separator used in the other errors of Regolith.
+func joinJsonPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "->" + key
+}
+
+// stringInSlice returns true if the slice contains the string.
+func stringInSlice(s string, slice []string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// closestString returns the option most similar to the string (using the
+// case-insensitive edit distance), or an empty string if none of the
+// options is similar enough to be a likely typo.
+func closestString(s string, options []string) string {
+	best, bestDistance := "", -1
+	for _, option := range options {
+		distance := editDistance(strings.ToLower(s), strings.ToLower(option))
+		if bestDistance == -1 || distance < bestDistance {
+			best, bestDistance = option, distance
+		}
+	}
+	maxDistance := len(s) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	if bestDistance == -1 || bestDistance > maxDistance {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(
+				previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -7,12 +7,34 @@ package regolith
 
 import (
 	"io/ioutil"
-
-	"muzzammil.xyz/jsonc"
 )
 
-// LoadConfigAsMap loads the config.json file as map[string]interface{}
+// LoadConfigAsMap loads the config.json file as map[string]interface{}, with
+// the optional config.local.json file merged over it (see
+// mergeLocalConfig). The functions that save the config file use
+// loadSharedConfigAsMap instead, so the local settings don't end up in
+// config.json.
 func LoadConfigAsMap() (map[string]interface{}, error) {
+	configJson, err := loadSharedConfigAsMap()
+	if err != nil {
+		return nil, PassError(err)
+	}
+	configJson, err = mergeUserProjectDefaults(configJson)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	configJson, err = mergeLocalConfig(configJson, ".")
+	if err != nil {
+		return nil, WrapErrorf(
+			err, "Failed to load the local config.\nPath: %s",
+			LocalConfigFilePath)
+	}
+	return configJson, nil
+}
+
+// loadSharedConfigAsMap loads the config.json file as
+// map[string]interface{}, without the local config.
+func loadSharedConfigAsMap() (map[string]interface{}, error) {
 	file, err := ioutil.ReadFile(ConfigFilePath)
 	if err != nil {
 		return nil, WrappedError( // We don't need to pass OS error. It's confusing.
@@ -21,7 +43,7 @@ func LoadConfigAsMap() (map[string]interface{}, error) {
 				"If you want to create new Regolith project here, use \"regolith init\".")
 	}
 	var configJson map[string]interface{}
-	err = jsonc.Unmarshal(file, &configJson)
+	err = unmarshalJsonc(file, &configJson)
 	if err != nil {
 		return nil, WrapErrorf(err, jsonUnmarshalError, ConfigFilePath)
 	}
@@ -29,7 +51,8 @@ func LoadConfigAsMap() (map[string]interface{}, error) {
 }
 
 // dataPathFromConfigMap returns the value of the data path from the config
-// file map, without parsing it to a Config object.
+// file map, without parsing it to a Config object. The environment variables
+// in the path are expanded (see expandEnvVariables).
 func dataPathFromConfigMap(config map[string]interface{}) (string, error) {
 	regolith, ok := config["regolith"].(map[string]interface{})
 	if !ok {
@@ -39,7 +62,7 @@ func dataPathFromConfigMap(config map[string]interface{}) (string, error) {
 	if !ok {
 		return "", WrappedErrorf(jsonPathMissingError, "regolith->dataPath")
 	}
-	return dataPath, nil
+	return expandEnvVariables(dataPath), nil
 }
 
 // filterDefinitionFromConfigMap returns the filter definitions as map from
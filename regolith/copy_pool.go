@@ -0,0 +1,75 @@
+package regolith
+
+import (
+	"runtime"
+	"sync"
+)
+
+// copyPoolWorkers is the number of the goroutines of a copyPool. Copying many
+// small files is limited by the latency of the file system rather than by
+// its bandwidth, so it uses more goroutines than there are CPU cores.
+var copyPoolWorkers = 4 * runtime.NumCPU()
+
+// copyFileBuffers are the reusable buffers of CopyFile, so copying many
+// small files in parallel doesn't allocate a new buffer for every file.
+var copyFileBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyFileBufferSize)
+		return &buf
+	},
+}
+
+// copyPool runs the file operations (usually copying files) with a bounded
+// number of goroutines. The operations are added with Go, and Wait returns
+// the first error. After an error, the remaining operations are skipped.
+type copyPool struct {
+	tasks chan func() error
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+	err   error
+}
+
+// newCopyPool creates a copyPool and starts its goroutines.
+func newCopyPool() *copyPool {
+	p := &copyPool{tasks: make(chan func() error)}
+	p.wg.Add(copyPoolWorkers)
+	for i := 0; i < copyPoolWorkers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.tasks {
+				if p.failed() {
+					continue
+				}
+				if err := task(); err != nil {
+					p.mutex.Lock()
+					if p.err == nil {
+						p.err = err
+					}
+					p.mutex.Unlock()
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// failed returns true if any of the operations of the pool failed.
+func (p *copyPool) failed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err != nil
+}
+
+// Go adds an operation to the pool. It blocks until one of the goroutines is
+// free.
+func (p *copyPool) Go(task func() error) {
+	p.tasks <- task
+}
+
+// Wait waits for all of the operations to finish and returns the first
+// error. The pool can't be used after calling Wait.
+func (p *copyPool) Wait() error {
+	close(p.tasks)
+	p.wg.Wait()
+	return p.err
+}
@@ -0,0 +1,202 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchIgnore are the patterns of the paths that are never watched:
+// the folders of the version control systems and the temporary files of the
+// operating systems and the popular editors. The "watchIgnore" patterns of
+// the project are added to them.
+var defaultWatchIgnore = []string{
+	".git", ".svn", ".DS_Store", "Thumbs.db", "*.swp", "*.tmp", "*~",
+}
+
+// defaultWatchDelay is the default number of milliseconds without changes
+// after which the watch mode reruns the profile (see
+// DirWatcher.WaitForChangeGroup).
+const defaultWatchDelay = 100
+
+// dirWatcherClosedError is used when the events of the DirWatcher stop,
+// because it was closed.
+const dirWatcherClosedError = "The file system watcher was closed."
+
+// DirWatcher is a struct that provides easy to use methods for watching a
+// directory and its subdirectories for changes. It uses fsnotify, which
+// doesn't watch the directories recursively, so the new subdirectories are
+// added to the watcher when they're created. The changes of the paths that
+// match the ignore patterns (see matchesGlobPatterns) are skipped and the
+// ignored directories aren't watched at all.
+type DirWatcher struct {
+	watcher *fsnotify.Watcher
+	// ignore are the glob patterns of the ignored paths, matched against the
+	// paths relative to ignoreRoot.
+	ignore     []string
+	ignoreRoot string
+	// onChange is called with the path of every change that isn't ignored,
+	// if it's not nil (see changedFilesTracker).
+	onChange func(path string)
+}
+
+// NewDirWatcher creates a new DirWatcher for the given path. The ignore
+// patterns are matched against the paths relative to the current working
+// directory (the root of the project), for example "packs/RP/**/*.psd".
+func NewDirWatcher(path string, ignore []string) (*DirWatcher, error) {
+	ignoreRoot, err := os.Getwd()
+	if err != nil {
+		return nil, WrapError(err, osGetwdError)
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, WrapErrorf(err, filepathAbsError, path)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, WrapError(err, "Failed to create the file system watcher.")
+	}
+	d := &DirWatcher{
+		watcher:    watcher,
+		ignore:     append(append([]string{}, defaultWatchIgnore...), ignore...),
+		ignoreRoot: ignoreRoot,
+	}
+	err = d.addRecursive(path)
+	if err != nil {
+		watcher.Close()
+		return nil, PassError(err)
+	}
+	return d, nil
+}
+
+// isIgnored returns true if the path matches the ignore patterns. The paths
+// outside of the project are matched only by the patterns without slashes,
+// which match the names of the files.
+func (d *DirWatcher) isIgnored(path string) bool {
+	relPath, err := filepath.Rel(d.ignoreRoot, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		relPath = filepath.Base(path)
+	}
+	return matchesGlobPatterns(relPath, d.ignore)
+}
+
+// addRecursive adds the directory and its subdirectories, except for the
+// ignored ones, to the watcher.
+func (d *DirWatcher) addRecursive(root string) error {
+	err := filepath.WalkDir(root, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p != root {
+				return nil // Removed while walking
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if p != root && d.isIgnored(p) {
+			return filepath.SkipDir
+		}
+		err = d.watcher.Add(p)
+		if err != nil && !os.IsNotExist(err) {
+			return WrapErrorf(err, "Failed to watch the directory.\nPath: %s", p)
+		}
+		return nil
+	})
+	if err != nil {
+		return WrapErrorf(err, osWalkError, root)
+	}
+	return nil
+}
+
+// handleEvent returns true if the event is a change that should be reported.
+// The new directories are added to the watcher.
+func (d *DirWatcher) handleEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod || d.isIgnored(event.Name) {
+		return false
+	}
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := d.addRecursive(event.Name); err != nil {
+				Logger.Warnf(
+					"Failed to watch the new directory.\nPath: %s\n%s",
+					event.Name, err.Error())
+			}
+		}
+	}
+	return true
+}
+
+// nextChange locks the goroutine until a change that isn't ignored is
+// detected or the timeout channel receives a value. It returns false in the
+// second case. The nil timeout channel never receives a value.
+func (d *DirWatcher) nextChange(timeout <-chan time.Time) (bool, error) {
+	for {
+		select {
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return false, WrappedError(dirWatcherClosedError)
+			}
+			if d.handleEvent(event) {
+				Logger.Debugf("Detected a change: %s", event.String())
+				if d.onChange != nil {
+					d.onChange(event.Name)
+				}
+				return true, nil
+			}
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return false, WrappedError(dirWatcherClosedError)
+			}
+			return false, WrapError(err, "The file system watcher failed.")
+		case <-timeout:
+			return false, nil
+		}
+	}
+}
+
+// WaitForChange locks the goroutine until a single change is detected. Note
+// that some changes are reported multiple times, for example saving a file
+// will cause a change to the file and a change to the directory. If you want
+// to report cases like that as one event, see WaitForChangeGroup.
+func (d *DirWatcher) WaitForChange() error {
+	_, err := d.nextChange(nil)
+	return err
+}
+
+// WaitForChangeGroup locks a goroutine until it recives a change notification.
+// Then it continues locking as long as other changes keep coming with
+// intervals less than the given timeout (in milliseconds), to group
+// notifications that come in short intervals together. When the changes
+// settle, it sends the interruptionMessage to the interruptionChannel, so a
+// burst of changes (like saving many files at once) causes only one
+// interruption.
+func (d *DirWatcher) WaitForChangeGroup(
+	groupTimeout uint32, interruptionChannel chan string,
+	interruptionMessage string,
+) error {
+	err := d.WaitForChange()
+	if err != nil {
+		return err
+	}
+	// Consume all changes until there are no changes for groupTimeout
+	for {
+		changed, err := d.nextChange(
+			time.After(time.Duration(groupTimeout) * time.Millisecond))
+		if err != nil {
+			return err
+		}
+		if !changed {
+			break
+		}
+	}
+	interruptionChannel <- interruptionMessage
+	return nil
+}
+
+// Close stops watching the directory.
+func (d *DirWatcher) Close() error {
+	return d.watcher.Close()
+}
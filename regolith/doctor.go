@@ -0,0 +1,353 @@
+package regolith
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The statuses of the checks of the "regolith doctor" command.
+const (
+	doctorOk      = "ok"
+	doctorWarning = "warning"
+	doctorError   = "error"
+)
+
+// doctorCheck is the result of a single check of the "regolith doctor"
+// command.
+type doctorCheck struct {
+	// Status is doctorOk, doctorWarning or doctorError
+	Status  string
+	Message string
+	// Fix tells the user how to fix the problem. It's empty for the checks
+	// that passed.
+	Fix string
+}
+
+// doctorTool is a program used by Regolith or by the filters, checked by
+// doctorToolChecks.
+type doctorTool struct {
+	Name string
+	// Command returns the command that runs the program
+	Command func() (string, error)
+	// RunWith are the "runWith" values of the filters that need the program.
+	// The program is always required if it's "*", and it's required by the
+	// remote filters if it's "remote".
+	RunWith []string
+	// Fix tells the user how to install the program
+	Fix string
+}
+
+// doctorTools are the programs checked by the "regolith doctor" command.
+var doctorTools = []doctorTool{
+	{
+		Name:    "Git",
+		Command: func() (string, error) { return "git", nil },
+		RunWith: []string{"remote"},
+		Fix: "Download and install Git from https://git-scm.com/downloads " +
+			"and make sure that it's added to the PATH.",
+	},
+	{
+		Name:    "Python",
+		Command: findPython,
+		RunWith: []string{"python"},
+		Fix: "Download and install Python from " +
+			"https://www.python.org/downloads/ and make sure that it's " +
+			"added to the PATH.",
+	},
+	{
+		Name:    "NodeJS",
+		Command: func() (string, error) { return "node", nil },
+		RunWith: []string{"nodejs"},
+		Fix:     "Download and install NodeJS from https://nodejs.org/en/.",
+	},
+	{
+		Name:    "Deno",
+		Command: func() (string, error) { return "deno", nil },
+		RunWith: []string{"deno"},
+		Fix:     "Download and install Deno from https://deno.land/.",
+	},
+}
+
+// doctor runs the checks of the "regolith doctor" command and prints their
+// results. It returns an error if any of the checks failed, so the command
+// can be used in the scripts.
+func doctor() error {
+	var checks []doctorCheck
+	configChecks, config, dotRegolithPath := doctorConfigChecks()
+	runWith := map[string]bool{}
+	if config != nil {
+		runWith = usedFilterRunners(config, dotRegolithPath)
+	}
+	checks = append(checks, doctorToolChecks(runWith)...)
+	checks = append(checks, configChecks...)
+	var exportPaths []string
+	if config != nil {
+		var exportChecks []doctorCheck
+		exportChecks, exportPaths = doctorExportChecks(config)
+		checks = append(checks, exportChecks...)
+	}
+	projectPath, _ := filepath.Abs(".")
+	checks = append(checks, doctorPlatformChecks(projectPath, exportPaths)...)
+
+	problems := 0
+	for _, check := range checks {
+		switch check.Status {
+		case doctorOk:
+			Logger.Infof("[OK] %s", check.Message)
+		case doctorWarning:
+			Logger.Warnf("%s\n    Fix: %s", check.Message, check.Fix)
+		case doctorError:
+			problems++
+			Logger.Errorf("%s\n    Fix: %s", check.Message, check.Fix)
+		}
+	}
+	if problems > 0 {
+		return WrappedErrorf(
+			"Found %d problems that prevent Regolith from running the "+
+				"project.", problems)
+	}
+	Logger.Info("No problems found.")
+	return nil
+}
+
+// doctorToolChecks checks if the doctorTools are installed and prints their
+// versions. The missing tools are errors only if they're required by the
+// filters of the project (the runWith set, see usedFilterRunners).
+func doctorToolChecks(runWith map[string]bool) []doctorCheck {
+	var checks []doctorCheck
+	for _, tool := range doctorTools {
+		required := false
+		for _, r := range tool.RunWith {
+			required = required || runWith[r]
+		}
+		version, err := toolVersion(tool.Command)
+		if err == nil {
+			checks = append(checks, doctorCheck{
+				Status:  doctorOk,
+				Message: tool.Name + ": " + version,
+			})
+			continue
+		}
+		status := doctorWarning
+		message := tool.Name + " not found. It's needed only by some of the " +
+			"filters."
+		if required {
+			status = doctorError
+			message = tool.Name + " not found. It's needed by the filters " +
+				"of the project."
+		}
+		Logger.Debugf("%s check failed:\n%s", tool.Name, err.Error())
+		checks = append(checks, doctorCheck{
+			Status: status, Message: message, Fix: tool.Fix})
+	}
+	return checks
+}
+
+// toolVersion runs the command returned by the command function with the
+// "--version" argument and returns the first line of its output.
+func toolVersion(command func() (string, error)) (string, error) {
+	name, err := command()
+	if err != nil {
+		return "", PassError(err)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return "", WrapErrorf(err, "Failed to find %q.", name)
+	}
+	output, err := exec.Command(name, "--version").CombinedOutput()
+	if err != nil {
+		return "", WrapErrorf(err, execCommandError, name+" --version")
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(version), nil
+}
+
+// doctorConfigChecks loads and validates the config of the project in the
+// working directory, and checks the filters of every profile. It returns
+// the config and the path to the .regolith directory, or nil if the config
+// can't be loaded.
+func doctorConfigChecks() ([]doctorCheck, *Config, string) {
+	configJson, err := LoadConfigAsMap()
+	if err != nil {
+		return []doctorCheck{{
+			Status: doctorError,
+			Message: "Failed to load \"config.json\".\n" +
+				PassError(err).Error(),
+			Fix: "Run the command in the root folder of a Regolith " +
+				"project, or create a project with \"regolith init\".",
+		}}, nil, ""
+	}
+	config, err := ConfigFromObject(configJson)
+	if err != nil {
+		return []doctorCheck{{
+			Status:  doctorError,
+			Message: "Invalid \"config.json\".\n" + PassError(err).Error(),
+			Fix: "Fix the property from the error message. The editors " +
+				"with the JSON schema of the config (or \"regolith lsp\") " +
+				"show the errors while editing the file.",
+		}}, nil, ""
+	}
+	checks := []doctorCheck{{Status: doctorOk, Message: "config.json is valid"}}
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, true, ".")
+	if err != nil {
+		return append(checks, doctorCheck{
+			Status: doctorError,
+			Message: "Unable to get the path to regolith cache folder.\n" +
+				PassError(err).Error(),
+			Fix: "Set the \"useAppData\" property of the config to false to " +
+				"keep the cache in the project.",
+		}), config, ""
+	}
+	profileNames := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		err := CheckProfileImpl(
+			config.Profiles[name], name, *config, nil, dotRegolithPath)
+		if err != nil {
+			checks = append(checks, doctorCheck{
+				Status: doctorError,
+				Message: "The filters of profile \"" + name + "\" can't " +
+					"run.\n" + PassError(err).Error(),
+				Fix: "Run \"regolith install-all\" to install the missing " +
+					"filters and their dependencies.",
+			})
+			continue
+		}
+		checks = append(checks, doctorCheck{
+			Status:  doctorOk,
+			Message: "Profile \"" + name + "\" is ready to run",
+		})
+	}
+	return checks, config, dotRegolithPath
+}
+
+// usedFilterRunners returns the set of the "runWith" values of the filters
+// from the filterDefinitions of the config and of the installed remote
+// filters. The set has the "remote" value if the config has remote filters.
+func usedFilterRunners(
+	config *Config, dotRegolithPath string,
+) map[string]bool {
+	result := map[string]bool{}
+	filterDefinitions := withNestedFilters(
+		config.FilterDefinitions, dotRegolithPath)
+	for _, filterDefinition := range filterDefinitions {
+		switch f := filterDefinition.(type) {
+		case *PythonFilterDefinition:
+			result["python"] = true
+		case *NodeJSFilterDefinition:
+			result["nodejs"] = true
+		case *DenoFilterDefinition:
+			result["deno"] = true
+		case *RemoteFilterDefinition:
+			result["remote"] = true
+			filterJson, err := f.LoadFilterJson(dotRegolithPath)
+			if err != nil {
+				continue // Not installed, reported by the profile checks
+			}
+			filters, _ := filterJson["filters"].([]interface{})
+			for _, filter := range filters {
+				filter, _ := filter.(map[string]interface{})
+				if runWith, ok := filter["runWith"].(string); ok {
+					result[runWith] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+// doctorExportChecks checks if the export paths of the profiles can be
+// found and if they're writable. The export paths don't have to exist before
+// the first export, so for the missing paths their parent directories are
+// checked. It returns the checks and the export paths.
+func doctorExportChecks(config *Config) ([]doctorCheck, []string) {
+	var checks []doctorCheck
+	var exportPaths []string
+	profileNames := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	checked := map[string]bool{}
+	for _, name := range profileNames {
+		exportTarget := config.Profiles[name].ExportTarget
+		bpPath, rpPath, err := GetExportPaths(exportTarget, config.Name)
+		if err != nil {
+			checks = append(checks, doctorCheck{
+				Status: doctorError,
+				Message: "Failed to find the export paths of profile \"" +
+					name + "\".\n" + PassError(err).Error(),
+				Fix: "Install the build of Minecraft used by the export " +
+					"target, or change the export target of the profile " +
+					"(for example to \"local\" or \"exact\").",
+			})
+			continue
+		}
+		for _, path := range []string{bpPath, rpPath} {
+			if path == "" {
+				continue
+			}
+			if fullPath, err := filepath.Abs(path); err == nil {
+				path = fullPath
+			}
+			if checked[path] {
+				continue
+			}
+			checked[path] = true
+			exportPaths = append(exportPaths, path)
+			checks = append(checks, doctorExportPathCheck(
+				name, path, usesLocalExportPaths(exportTarget.Target)))
+		}
+	}
+	return checks, exportPaths
+}
+
+// doctorExportPathCheck checks if the export path of the profile (or its
+// parent directory, if the path doesn't exist yet) is a writable directory.
+// The "local" paths are in the "build" folder, which is created by the first
+// export, so for them the first existing directory of the path is checked.
+func doctorExportPathCheck(profileName, path string, local bool) doctorCheck {
+	dir := path
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		dir = filepath.Dir(path)
+	}
+	for local && dir != filepath.Dir(dir) {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	stat, err := os.Stat(dir)
+	if err != nil || !stat.IsDir() {
+		return doctorCheck{
+			Status: doctorError,
+			Message: "The folder of the export path of profile \"" +
+				profileName + "\" doesn't exist: " + dir,
+			Fix: "Create the folder or change the export target of the " +
+				"profile.",
+		}
+	}
+	file, err := os.CreateTemp(dir, ".regolith-doctor-*")
+	if err != nil {
+		return doctorCheck{
+			Status: doctorError,
+			Message: "The export path of profile \"" + profileName +
+				"\" isn't writable: " + dir + "\n" + err.Error(),
+			Fix: "Give your user the permission to write to the folder, or " +
+				"close the programs that lock it (like Minecraft or an " +
+				"editor).",
+		}
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return doctorCheck{
+		Status:  doctorOk,
+		Message: "Export path of profile \"" + profileName + "\": " + path,
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package regolith
+
+// doctorPlatformChecks is a placeholder for a function which is necessary
+// only on Windows, where OneDrive and the antivirus programs lock the files
+// of the projects.
+func doctorPlatformChecks(
+	projectPath string, exportPaths []string,
+) []doctorCheck {
+	return nil
+}
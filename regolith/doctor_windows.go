@@ -0,0 +1,103 @@
+//go:build windows
+// +build windows
+
+package regolith
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// oneDriveEnvVars are the environment variables with the paths to the
+// folders synchronized by OneDrive.
+var oneDriveEnvVars = []string{
+	"OneDrive", "OneDriveConsumer", "OneDriveCommercial"}
+
+// doctorPlatformChecks checks the problems specific to Windows: the project
+// or the export paths synchronized by OneDrive, and the real-time protection
+// of Microsoft Defender scanning the project. Both lock the files copied by
+// Regolith, which makes the runs slow and the exports fail at random.
+func doctorPlatformChecks(
+	projectPath string, exportPaths []string,
+) []doctorCheck {
+	var checks []doctorCheck
+	paths := append([]string{projectPath}, exportPaths...)
+	for _, envVar := range oneDriveEnvVars {
+		oneDrivePath := os.Getenv(envVar)
+		if oneDrivePath == "" {
+			continue
+		}
+		for _, path := range paths {
+			if path != oneDrivePath && !isInDirectory(path, oneDrivePath) {
+				continue
+			}
+			checks = append(checks, doctorCheck{
+				Status: doctorWarning,
+				Message: "The path is synchronized by OneDrive: " + path +
+					"\nOneDrive locks the files while uploading them, " +
+					"which can make the runs and the exports fail.",
+				Fix: "Move the project out of the OneDrive folder, or pause " +
+					"the synchronization while working on the project.",
+			})
+		}
+	}
+	if check, ok := defenderCheck(projectPath); ok {
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// defenderCheck checks if the real-time protection of Microsoft Defender is
+// scanning the project. The second value is false if the state of Microsoft
+// Defender can't be read (for example because another antivirus replaced
+// it).
+func defenderCheck(projectPath string) (doctorCheck, bool) {
+	enabled, err := powershellOutput(
+		"(Get-MpComputerStatus).RealTimeProtectionEnabled")
+	if err != nil {
+		Logger.Debugf("Failed to check Microsoft Defender:\n%s", err.Error())
+		return doctorCheck{}, false
+	}
+	if !strings.EqualFold(enabled, "True") {
+		return doctorCheck{}, false
+	}
+	// Reading the exclusions requires the administrator privileges, without
+	// them the command prints a message instead of the paths
+	exclusions, err := powershellOutput("(Get-MpPreference).ExclusionPath")
+	if err == nil {
+		for _, exclusion := range strings.Split(exclusions, "\n") {
+			exclusion = filepath.Clean(strings.TrimSpace(exclusion))
+			if projectPath == exclusion ||
+				isInDirectory(projectPath, exclusion) {
+				return doctorCheck{
+					Status: doctorOk,
+					Message: "The project is excluded from the scans of " +
+						"Microsoft Defender",
+				}, true
+			}
+		}
+	}
+	return doctorCheck{
+		Status: doctorWarning,
+		Message: "The real-time protection of Microsoft Defender may be " +
+			"scanning the project. It scans every file copied by Regolith, " +
+			"which slows down the runs and can lock the exported files.",
+		Fix: "Add the project to the exclusions of Microsoft Defender with " +
+			"this command (in PowerShell started as administrator):\n" +
+			"    Add-MpPreference -ExclusionPath \"" + projectPath + "\"",
+	}, true
+}
+
+// powershellOutput runs the PowerShell command and returns its output
+// without the surrounding whitespace.
+func powershellOutput(command string) (string, error) {
+	output, err := exec.Command(
+		"powershell", "-NoProfile", "-NonInteractive", "-Command",
+		command).Output()
+	if err != nil {
+		return "", WrapErrorf(err, execCommandError, command)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
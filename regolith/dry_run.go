@@ -0,0 +1,306 @@
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DryRun handles the "regolith run --dry-run" command. It loads the config,
+// checks the filters of the profile and prints the execution plan and the
+// export paths, without running the filters or touching the tmp directory
+// and the export target. The defines are used by the "when" expressions and
+// the variables of the filters. The filters and skipExport are the values of
+// the "--filter" and "--no-export" flags, which limit the plan to the chosen
+// filters and skip the export plan.
+func DryRun(
+	profileName string, defines, filters []string, skipExport, debug bool,
+) error {
+	InitLogging(debug)
+	if profileName == "" {
+		profileName = "default"
+	}
+	context, err := prepareRunContext(profileName, defines)
+	if err != nil {
+		return PassError(err)
+	}
+	context.Filters = filters
+	context.SkipExport = skipExport
+	Logger.Infof("Execution plan of the %q profile:", profileName)
+	err = printExecutionPlan(context, "  ")
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to create execution plan of profile %q",
+			profileName)
+	}
+	if context.SkipExport {
+		Logger.Infof(
+			"The export would be skipped, the files would stay in %q.",
+			filepath.Join(context.DotRegolithPath, "tmp"))
+		Logger.Info("Dry run finished. No files were changed.")
+		return nil
+	}
+	profile, err := context.GetProfile()
+	if err != nil {
+		return WrapErrorf(err, runContextGetProfileError)
+	}
+	err = printExportPlan(profile, *context.Config, context.DotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	Logger.Info("Dry run finished. No files were changed.")
+	return nil
+}
+
+// printExecutionPlan prints the filters of the profile from the context in
+// the order of their execution. Profiles that run the filters in parallel
+// (see Profile.runsInParallel) are printed in stages of the filters that can
+// run at the same time. With the "--filter" flag, only the chosen filters
+// are printed, in the order of the profile (see WatchProfileImpl).
+func printExecutionPlan(context RunContext, indent string) error {
+	profile, err := context.GetProfile()
+	if err != nil {
+		return WrapErrorf(err, runContextGetProfileError)
+	}
+	if len(context.Filters) != 0 && context.Parent == nil {
+		filters, err := selectFilters(profile.Filters, context.Filters)
+		if err != nil {
+			return PassError(err)
+		}
+		for i, filter := range filters {
+			err := printPlannedFilter(filter, context, indent, i+1)
+			if err != nil {
+				return PassError(err)
+			}
+		}
+		return nil
+	}
+	if !profile.runsInParallel() {
+		for i, filter := range profile.Filters {
+			err := printPlannedFilter(filter, context, indent, i+1)
+			if err != nil {
+				return PassError(err)
+			}
+		}
+		return nil
+	}
+	dependencies, err := filterDependencies(profile.Filters)
+	if err != nil {
+		return PassError(err)
+	}
+	// The stage of a filter is one more than the latest stage of the filters
+	// that it needs
+	stages := make([]int, len(profile.Filters))
+	lastStage := 0
+	for done := false; !done; {
+		done = true
+		for i, deps := range dependencies {
+			for _, dep := range deps {
+				if stages[i] <= stages[dep] {
+					stages[i] = stages[dep] + 1
+					done = false
+				}
+			}
+			if stages[i] > lastStage {
+				lastStage = stages[i]
+			}
+		}
+	}
+	Logger.Infof("%sThe filters run in parallel, in stages.", indent)
+	for stage := 0; stage <= lastStage; stage++ {
+		Logger.Infof("%sStage %d:", indent, stage+1)
+		for i, filter := range profile.Filters {
+			if stages[i] != stage {
+				continue
+			}
+			err := printPlannedFilter(filter, context, indent+"  ", i+1)
+			if err != nil {
+				return PassError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// printPlannedFilter prints a single filter of the execution plan with its
+// resolved settings and arguments. The nested profiles are printed
+// recursively.
+func printPlannedFilter(
+	filter FilterRunner, context RunContext, indent string, number int,
+) error {
+	disabled, err := filter.IsDisabled(context)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to check if the filter is disabled.\nFilter: %s",
+			filter.GetId())
+	}
+	suffix := ""
+	if disabled {
+		suffix = " (disabled, skipped)"
+	}
+	if profileFilter, ok := filter.(*ProfileFilter); ok {
+		Logger.Infof(
+			"%s%d. Nested profile %q%s", indent, number, profileFilter.Profile,
+			suffix)
+		if disabled {
+			return nil
+		}
+		return printExecutionPlan(RunContext{
+			Profile:          profileFilter.Profile,
+			AbsoluteLocation: context.AbsoluteLocation,
+			Config:           context.Config,
+			Parent:           &context,
+			DotRegolithPath:  context.DotRegolithPath,
+			Defines:          context.Defines,
+		}, indent+"   ")
+	}
+	Logger.Infof("%s%d. %s%s", indent, number, filter.GetId(), suffix)
+	if disabled {
+		return nil
+	}
+	details, err := plannedFilterDetails(filter, context)
+	if err != nil {
+		return PassError(err)
+	}
+	for _, detail := range details {
+		Logger.Infof("%s   %s", indent, detail)
+	}
+	return nil
+}
+
+// plannedFilterDetails returns the lines with the properties of the filter
+// printed in the execution plan, with the variables expanded.
+func plannedFilterDetails(
+	filter FilterRunner, context RunContext,
+) ([]string, error) {
+	filterJson, err := json.Marshal(filter)
+	if err != nil {
+		return nil, WrapError(err, "Failed to encode the filter as JSON.")
+	}
+	var properties map[string]interface{}
+	err = json.Unmarshal(filterJson, &properties)
+	if err != nil {
+		return nil, WrapError(err, "Failed to decode the filter from JSON.")
+	}
+	var details []string
+	if remoteFilter, ok := filter.(*RemoteFilter); ok {
+		details = append(details, fmt.Sprintf(
+			"remote filter: %s (version: %s)",
+			remoteFilter.Definition.Url, remoteFilter.Definition.Version))
+	}
+	for _, name := range []string{
+		"when", "needs", "inputs", "outputs", "timeout", "retries",
+	} {
+		if value, ok := properties[name]; ok {
+			details = append(details, fmt.Sprintf("%s: %v", name, value))
+		}
+	}
+	if filter.UsesCache() {
+		details = append(details, "cache: enabled")
+	}
+	for _, name := range []string{"settings", "arguments"} {
+		value, ok := properties[name]
+		if !ok {
+			continue
+		}
+		// The secrets are masked, so they aren't printed or resolved
+		expanded, _ := expandVariablesInValue(
+			mapStringsInValue(value, maskSecrets), context)
+		resolved, _ := json.Marshal(expanded)
+		details = append(details, fmt.Sprintf("%s: %s", name, resolved))
+	}
+	return details, nil
+}
+
+// printExportPlan prints the paths that the profile would export to.
+func printExportPlan(
+	profile Profile, config Config, dotRegolithPath string,
+) error {
+	exportTarget := profile.ExportTarget
+	bpPath, rpPath, err := GetExportPaths(exportTarget, config.Name)
+	if err != nil {
+		return WrapError(err, "Failed to get generate export paths.")
+	}
+	Logger.Infof("Export target: %s", exportTarget.Target)
+	if exportTarget.Symlink {
+		Logger.Infof(
+			"  The behavior pack path would be a link: %s -> %s",
+			bpPath, linkedExportBpPath)
+		Logger.Infof(
+			"  The resource pack path would be a link: %s -> %s",
+			rpPath, linkedExportRpPath)
+		bpPath, rpPath = linkedExportBpPath, linkedExportRpPath
+	}
+	Logger.Infof("  The behavior pack would replace: %s", bpPath)
+	Logger.Infof("  The resource pack would replace: %s", rpPath)
+	if err := checkAdditionalPacksExport(
+		exportTarget, config.AdditionalPacks); err != nil {
+		return PassError(err)
+	}
+	for _, pack := range listAdditionalPacks(config.AdditionalPacks) {
+		Logger.Infof(
+			"  The %s pack would replace: %s", pack.TmpDir,
+			pack.ExportPath(bpPath, rpPath))
+	}
+	Logger.Infof(
+		"  The data folder would be updated: %s",
+		filepath.Clean(config.DataPath))
+	if len(exportTarget.Exclude) > 0 {
+		Logger.Infof(
+			"  The files matching these patterns would be excluded: %s",
+			strings.Join(exportTarget.Exclude, ", "))
+	}
+	if exportTarget.ReportPath != "" {
+		Logger.Infof(
+			"  The export report would be saved to: %s",
+			exportTarget.ReportPath)
+	} else if exportTarget.Report {
+		Logger.Info("  The export report would be printed.")
+	}
+	for _, command := range exportTarget.PreExport {
+		Logger.Infof("  This command would run before the export: %s", command)
+	}
+	for _, command := range exportTarget.PostExport {
+		Logger.Infof("  This command would run after the export: %s", command)
+	}
+	if IsArchiveExportTarget(exportTarget.Target) {
+		Logger.Infof(
+			"  The packs would be packed into archives in: %s",
+			archiveExportPath)
+	}
+	if exportTarget.Target == "sftp" {
+		Logger.Infof(
+			"  The packs would be uploaded over SFTP to: %s",
+			sftpDestination(exportTarget))
+	}
+	if exportTarget.Target == "s3" {
+		Logger.Infof(
+			"  The archives would be uploaded to: s3://%s/%s",
+			exportTarget.Bucket, exportTarget.Prefix)
+	}
+	if exportTarget.Target == "adb" {
+		device, mojangDir := exportTarget.Device, exportTarget.Path
+		if device == "" {
+			device = "the connected device"
+		}
+		if mojangDir == "" {
+			mojangDir = "the \"com.mojang\" folder"
+		}
+		Logger.Infof(
+			"  The packs would be pushed with ADB to %s of %s",
+			mojangDir, device)
+	}
+	if exportTarget.ReadOnly {
+		Logger.Info("  The exported files would be read-only.")
+	}
+	// The safety check doesn't modify the files
+	editedFiles := LoadEditedFiles(dotRegolithPath)
+	err = editedFiles.CheckDeletionSafety(rpPath, bpPath)
+	if err != nil {
+		Logger.Warnf(
+			"  The export would be stopped by the safety mechanism:\n%s",
+			strings.TrimSpace(PassError(err).Error()))
+	}
+	return nil
+}
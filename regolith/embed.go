@@ -0,0 +1,57 @@
+package regolith
+
+import (
+	"context"
+)
+
+// RunEmbedded runs the profile of the project in the current working
+// directory, like the "regolith run" command, for the programs that embed
+// Regolith (see the pkg/regolith package). Unlike runOrWatch, it never sends
+// the run to the daemon of the project (see Serve) and it doesn't initialize
+// the logger (see SetLogger).
+//
+// The run stops with an error when the ctx is cancelled. The cancellation is
+// checked before every filter and before the export, so the filter that is
+// running when the ctx is cancelled finishes first.
+//
+// The "defines", "filters", "skipExport" and "paths" parameters are the
+// values of the "--define", "--filter", "--no-export", "--rp", "--bp" and
+// "--out" flags of the "regolith run" command.
+func RunEmbedded(
+	ctx context.Context, profileName string, defines, filters []string,
+	skipExport, recycled bool, paths RunPaths,
+) error {
+	if err := ctx.Err(); err != nil {
+		return WrappedError(runCancelledError)
+	}
+	if profileName == "" {
+		profileName = "default"
+	}
+	runContext, err := prepareRunContext(profileName, defines)
+	if err != nil {
+		return PassError(err)
+	}
+	runContext.Filters = filters
+	runContext.SkipExport = skipExport
+	runContext.done = ctx.Done()
+	runContext, err = applyRunPaths(runContext, paths)
+	if err != nil {
+		return PassError(err)
+	}
+	projectLock, err := acquireProjectLock(runContext.DotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	defer StopPersistentProcesses()
+	rp := RunProfile
+	if recycled {
+		rp = RecycledRunProfile
+	}
+	err = rp(runContext)
+	if err != nil {
+		return WrapErrorf(err, "Failed to run profile %q", profileName)
+	}
+	Logger.Infof("Successfully ran the %q profile.", profileName)
+	return nil
+}
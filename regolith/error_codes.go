@@ -0,0 +1,481 @@
+package regolith
+
+import (
+	"regexp"
+	"strings"
+)
+
+// errorCode assigns a stable code to an error constant from errors.go. The
+// code is printed before the message of the error, so the errors can be
+// searched for and handled by the tools that read the output of Regolith,
+// and "regolith explain" prints their causes and fixes.
+type errorCode struct {
+	Code    string
+	Message string
+	Causes  string
+	Fixes   string
+}
+
+// errorCodes are the codes of the error constants. The codes never change,
+// and the codes of the removed errors aren't reused, so the new errors are
+// always added at the end of the list. The osRelError has the same message
+// as the filepathRelError, so it uses its code.
+var errorCodes = []errorCode{
+	{
+		Code:    "R0001",
+		Message: assertEmptyOrNewDirError,
+		Causes: "Regolith tried to create files in a directory that already " +
+			"has other files, for example when initializing a project in a " +
+			"directory that isn't empty.",
+		Fixes: "Use an empty directory, or remove the files from the " +
+			"directory.",
+	},
+	{
+		Code:    "R0002",
+		Message: filepathAbsError,
+		Causes: "The path can't be converted to an absolute path, usually " +
+			"because the working directory was removed.",
+		Fixes: "Open a new terminal in the project and run the command " +
+			"again.",
+	},
+	{
+		Code:    "R0003",
+		Message: osStatErrorAny,
+		Causes: "Regolith can't read the information about the file, because " +
+			"it doesn't exist, the user doesn't have the permissions to " +
+			"access it, or the path is invalid.",
+		Fixes: "Check the path, and the permissions of the file and of its " +
+			"parent directories.",
+	},
+	{
+		Code:    "R0004",
+		Message: osStatErrorIsNotExist,
+		Causes: "A path from the config (like the path to a pack, to the " +
+			"data folder or to a filter) or a path required by Regolith " +
+			"doesn't exist.",
+		Fixes: "Create the file or the directory, or fix the path in the " +
+			"config.",
+	},
+	{
+		Code:    "R0005",
+		Message: osStatExistsError,
+		Causes: "Regolith tried to create a file or a directory that already " +
+			"exists.",
+		Fixes: "Remove or rename the existing file, if it's not needed.",
+	},
+	{
+		Code:    "R0006",
+		Message: osRenameError,
+		Causes: "The file is open in another program (Minecraft, an editor, " +
+			"an antivirus or a synchronization tool like OneDrive), the user " +
+			"doesn't have the permissions to move it, or the target is on " +
+			"another drive.",
+		Fixes: "Close the programs that use the files and run Regolith " +
+			"again. Run \"regolith doctor\" to find the programs that " +
+			"commonly lock the files.",
+	},
+	{
+		Code:    "R0007",
+		Message: osCopyError,
+		Causes: "The source can't be read or the target can't be written, " +
+			"because of the permissions, a full drive, or a program that " +
+			"locks the files.",
+		Fixes: "Check the free space and the permissions of the target, and " +
+			"close the programs that use the files.",
+	},
+	{
+		Code:    "R0008",
+		Message: osMkdirError,
+		Causes: "The user doesn't have the permissions to create the " +
+			"directory, a file with the same name exists, or the path is too " +
+			"long.",
+		Fixes: "Check the permissions of the parent directory, or use a " +
+			"shorter path.",
+	},
+	{
+		Code:    "R0009",
+		Message: osGetwdError,
+		Causes:  "The working directory was removed or can't be accessed.",
+		Fixes: "Open a new terminal in the project and run the command " +
+			"again.",
+	},
+	{
+		Code:    "R0010",
+		Message: isDirEmptyError,
+		Causes: "Regolith can't list the files of the directory because of " +
+			"its permissions.",
+		Fixes: "Check the permissions of the directory.",
+	},
+	{
+		Code:    "R0011",
+		Message: isDirEmptyNotEmptyError,
+		Causes: "Regolith expected a directory with files, but the directory " +
+			"is empty.",
+		Fixes: "Check if the path points to the right directory.",
+	},
+	{
+		Code:    "R0012",
+		Message: copyFileSecurityInfoError,
+		Causes: "The permissions (ACL) of the exported files can't be " +
+			"copied, usually because the target is on a file system that " +
+			"doesn't support them, like a network share or a FAT drive.",
+		Fixes: "Set the \"acl\" property of the export target to \"none\".",
+	},
+	{
+		Code:    "R0013",
+		Message: revertableFsOperationsDeleteError,
+		Causes: "Regolith can't move the old files to the backup before " +
+			"replacing them, usually because they're open in another " +
+			"program.",
+		Fixes: "Close the programs that use the exported files (like " +
+			"Minecraft) and run Regolith again.",
+	},
+	{
+		Code:    "R0014",
+		Message: filepathRelError,
+		Causes: "The paths are on different drives, or one of them is " +
+			"relative and the other one is absolute.",
+		Fixes: "Keep the paths used by the project on the same drive.",
+	},
+	{
+		Code:    "R0015",
+		Message: osRemoveError,
+		Causes: "The file is open in another program, or the user doesn't " +
+			"have the permissions to remove it.",
+		Fixes: "Close the programs that use the file and run Regolith again.",
+	},
+	{
+		Code:    "R0016",
+		Message: moveOrCopyError,
+		Causes: "The file can't be moved or copied, because it's open in " +
+			"another program, the drive is full, or the user doesn't have " +
+			"the permissions to write to the target.",
+		Fixes: "Close the programs that use the files, and check the free " +
+			"space and the permissions of the target.",
+	},
+	{
+		Code:    "R0017",
+		Message: isDirNotADirError,
+		Causes: "The path from the config points to a file instead of a " +
+			"directory.",
+		Fixes: "Fix the path in the config.",
+	},
+	{
+		Code:    "R0018",
+		Message: cloneFileError,
+		Causes: "The file system doesn't support the copy-on-write clones of " +
+			"the files, or the source and the target are on different " +
+			"drives.",
+		Fixes: "Regolith copies the files instead. If the error stops the " +
+			"run, keep the project and the .regolith directory on the same " +
+			"drive.",
+	},
+	{
+		Code:    "R0019",
+		Message: osOpenError,
+		Causes: "The file doesn't exist, or the user doesn't have the " +
+			"permissions to read it.",
+		Fixes: "Check the path and the permissions of the file.",
+	},
+	{
+		Code:    "R0020",
+		Message: osCreateError,
+		Causes: "The user doesn't have the permissions to write to the " +
+			"directory, or the file is open in another program.",
+		Fixes: "Check the permissions of the directory and close the " +
+			"programs that use the file.",
+	},
+	{
+		Code:    "R0021",
+		Message: osWalkError,
+		Causes: "A file or a directory can't be listed because of its " +
+			"permissions, or it was removed while Regolith was reading the " +
+			"directory.",
+		Fixes: "Check the permissions of the files, and don't change them " +
+			"while Regolith is running.",
+	},
+	{
+		Code:    "R0022",
+		Message: fileReadError,
+		Causes: "The file can't be read, because it doesn't exist, it's open " +
+			"in another program, or the user doesn't have the permissions to " +
+			"read it.",
+		Fixes: "Check the path and the permissions of the file.",
+	},
+	{
+		Code:    "R0023",
+		Message: fileWriteError,
+		Causes: "The file can't be written, because the drive is full, the " +
+			"file is open in another program, or the user doesn't have the " +
+			"permissions to write it.",
+		Fixes: "Check the free space, the permissions of the file, and close " +
+			"the programs that use it.",
+	},
+	{
+		Code:    "R0024",
+		Message: jsonUnmarshalError,
+		Causes: "The JSON file has a syntax error, like a missing comma, " +
+			"quote or bracket.",
+		Fixes: "Fix the syntax of the file. The editors with the JSON " +
+			"support show the errors.",
+	},
+	{
+		Code:    "R0025",
+		Message: jsonPropertyParseError,
+		Causes: "A property of the config or of the filter.json file has an " +
+			"invalid value.",
+		Fixes: "Fix the value of the property. The editors with the JSON " +
+			"schema of the config (or \"regolith lsp\") show the errors.",
+	},
+	{
+		Code:    "R0026",
+		Message: jsonPropertyMissingError,
+		Causes: "A required property of the config or of the filter.json " +
+			"file is missing.",
+		Fixes: "Add the property. The documentation of the config lists the " +
+			"required properties.",
+	},
+	{
+		Code:    "R0027",
+		Message: jsonPropertyTypeError,
+		Causes: "A property of the config or of the filter.json file has a " +
+			"value of the wrong type, for example a number instead of a " +
+			"string.",
+		Fixes: "Change the value of the property to the expected type.",
+	},
+	{
+		Code:    "R0028",
+		Message: jsonPathParseError,
+		Causes: "A part of the config or of the filter.json file has an " +
+			"invalid value.",
+		Fixes: "Fix the value at the JSON path from the error message.",
+	},
+	{
+		Code:    "R0029",
+		Message: jsonPathMissingError,
+		Causes: "A required part of the config or of the filter.json file is " +
+			"missing.",
+		Fixes: "Add the value at the JSON path from the error message.",
+	},
+	{
+		Code:    "R0030",
+		Message: jsonPathTypeError,
+		Causes: "A part of the config or of the filter.json file has a value " +
+			"of the wrong type.",
+		Fixes: "Change the value at the JSON path from the error message to " +
+			"the expected type.",
+	},
+	{
+		Code:    "R0031",
+		Message: runSubProcessError,
+		Causes:  "The program of the filter failed or couldn't be started.",
+		Fixes: "Read the output of the filter above the error. Run " +
+			"\"regolith doctor\" to check if the programs used by the " +
+			"filters are installed.",
+	},
+	{
+		Code:    "R0032",
+		Message: safeModeEnabledError,
+		Causes: "The project uses the filters that run code on your " +
+			"computer, and the safe mode of Regolith is enabled.",
+		Fixes: "Run \"regolith unlock\" if you trust the filters of the " +
+			"project.",
+	},
+	{
+		Code:    "R0033",
+		Message: remoteFilterSubfilterCollectionError,
+		Causes: "The remote filter isn't installed, or its filter.json file " +
+			"is invalid.",
+		Fixes: "Run \"regolith install-all\" to install the filters.",
+	},
+	{
+		Code:    "R0034",
+		Message: getRemoteFilterDownloadRefError,
+		Causes: "The version of the remote filter can't be found in its " +
+			"repository, or the repository can't be accessed.",
+		Fixes: "Check the \"url\" and the \"version\" of the filter, and the " +
+			"internet connection.",
+	},
+	{
+		Code:    "R0035",
+		Message: createFilterRunnerError,
+		Causes: "The filter from the profile has invalid properties, or its " +
+			"definition doesn't match its type.",
+		Fixes: "Check the properties of the filter in the profile.",
+	},
+	{
+		Code:    "R0036",
+		Message: gitNotInstalledWarning,
+		Causes:  "Git isn't installed or isn't in the PATH.",
+		Fixes: "Install Git from https://git-scm.com/downloads and open a " +
+			"new terminal.",
+	},
+	{
+		Code:    "R0037",
+		Message: filterFromObjectError,
+		Causes:  "A filter of a profile has invalid properties.",
+		Fixes:   "Fix the properties of the filter from the error message.",
+	},
+	{
+		Code:    "R0038",
+		Message: remoteFilterDownloadError,
+		Causes: "The filter can't be downloaded, because the repository or " +
+			"the version doesn't exist, or there's no internet connection.",
+		Fixes: "Check the \"url\" and the \"version\" of the filter, and the " +
+			"internet connection.",
+	},
+	{
+		Code:    "R0039",
+		Message: execCommandError,
+		Causes: "The program can't be started, because it isn't installed or " +
+			"isn't in the PATH, or it failed.",
+		Fixes: "Install the program and open a new terminal. Run \"regolith " +
+			"doctor\" to check the programs used by the filters.",
+	},
+	{
+		Code:    "R0040",
+		Message: filterRunnerCheckError,
+		Causes: "The requirements of the filter aren't met, for example the " +
+			"filter isn't installed or the program that runs it is missing.",
+		Fixes: "Run \"regolith install-all\" to install the filters, and " +
+			"\"regolith doctor\" to check the programs used by the filters.",
+	},
+	{
+		Code:    "R0041",
+		Message: notImplementedOnThisSystemError,
+		Causes:  "The feature isn't supported on this operating system.",
+		Fixes:   "Use another option, or run Regolith on a supported system.",
+	},
+	{
+		Code:    "R0042",
+		Message: unknownMinecraftBuildError,
+		Causes: "The \"build\" property of the export target isn't a build " +
+			"of Minecraft supported on this system.",
+		Fixes: "Use one of the builds listed in the error message.",
+	},
+	{
+		Code:    "R0043",
+		Message: clearCachedStatesError,
+		Causes: "The cached states of the files used by the \"--recycled\" " +
+			"mode can't be removed.",
+		Fixes: "Run \"regolith clean --path-states\".",
+	},
+	{
+		Code:    "R0044",
+		Message: setupTmpFilesError,
+		Causes: "The files of the project can't be copied to the tmp " +
+			"directory, because a pack or the data folder doesn't exist, or " +
+			"the files are locked.",
+		Fixes: "Check the paths of the packs and of the data folder in the " +
+			"config, and run \"regolith clean --tmp\".",
+	},
+	{
+		Code:    "R0045",
+		Message: exportProjectError,
+		Causes: "The packs can't be exported, usually because the export " +
+			"paths don't exist, aren't writable, or the files are open in " +
+			"Minecraft.",
+		Fixes: "Close Minecraft and run Regolith again. Run \"regolith " +
+			"doctor\" to check the export paths.",
+	},
+	{
+		Code:    "R0046",
+		Message: runContextGetProfileError,
+		Causes:  "The profile doesn't exist in the config.",
+		Fixes: "Check the name of the profile. \"regolith list\" prints the " +
+			"profiles of the project.",
+	},
+	{
+		Code:    "R0047",
+		Message: filterRunnerRunError,
+		Causes:  "The filter failed.",
+		Fixes:   "Read the output of the filter above the error.",
+	},
+	{
+		Code:    "R0048",
+		Message: getRegolithConfigPathError,
+		Causes:  "The app data folder of the user can't be found.",
+		Fixes: "Check the environment variables of the user (APPDATA on " +
+			"Windows, HOME on the other systems).",
+	},
+	{
+		Code:    "R0049",
+		Message: osUserCacheDirError,
+		Causes:  "The cache folder of the user can't be found.",
+		Fixes: "Check the environment variables of the user (LOCALAPPDATA on " +
+			"Windows, HOME or XDG_CACHE_HOME on the other systems).",
+	},
+	{
+		Code:    "R0050",
+		Message: runCancelledError,
+		Causes:  "The program that embeds Regolith cancelled the run.",
+		Fixes:   "Nothing, the run can be started again.",
+	},
+	{
+		Code:    "R0051",
+		Message: exportInterruptedError,
+		Causes: "Ctrl+C was pressed during the export. The files changed by " +
+			"the export were restored.",
+		Fixes: "Nothing, the export can be started again.",
+	},
+}
+
+// errorCodePattern matches the error codes in the error messages.
+var errorCodePattern = regexp.MustCompile(`\bR[0-9]{4}\b`)
+
+// findErrorCode returns the code of the error constant that starts the
+// message (the constants can be extended with additional lines), or nil.
+func findErrorCode(message string) *errorCode {
+	var result *errorCode
+	for i, code := range errorCodes {
+		if strings.HasPrefix(message, code.Message) &&
+			(result == nil || len(code.Message) > len(result.Message)) {
+			result = &errorCodes[i]
+		}
+	}
+	return result
+}
+
+// withErrorCode adds the code of the error constant to the message (see
+// errorCodes). The messages that don't start with a constant are returned
+// without changes.
+func withErrorCode(message string) string {
+	if code := findErrorCode(message); code != nil {
+		return code.Code + ": " + message
+	}
+	return message
+}
+
+// ErrorCodes returns the codes of the errors from the message of the error,
+// from the outermost error to its cause.
+func ErrorCodes(err error) []string {
+	return errorCodePattern.FindAllString(err.Error(), -1)
+}
+
+// explainErrorCode prints the message, the causes and the fixes of the
+// error with the code. Without the code, it prints the list of the codes.
+func explainErrorCode(code string) error {
+	if code == "" {
+		for _, c := range errorCodes {
+			title, _, _ := strings.Cut(c.Message, "\n")
+			Logger.Infof("%s: %s", c.Code, title)
+		}
+		return nil
+	}
+	code = strings.ToUpper(code)
+	for _, c := range errorCodes {
+		if c.Code != code {
+			continue
+		}
+		// The placeholders of the values are replaced to make the message
+		// readable
+		message := strings.ReplaceAll(c.Message, "%s", "<value>")
+		Logger.Infof(
+			"%s: %s\n\nCauses:\n%s\n\nFixes:\n%s",
+			c.Code, message, c.Causes, c.Fixes)
+		return nil
+	}
+	return WrappedErrorf(
+		"Unknown error code %q. Run \"regolith explain\" to list the codes.",
+		code)
+}
@@ -56,6 +56,10 @@ const (
 	// Error used when expecting a directory but it's not
 	isDirNotADirError = "Path is not a directory.\nPath: %s"
 
+	// Error used when the copy-on-write clone of a file (see cloneFile)
+	// can't be created
+	cloneFileError = "Failed to clone file.\nSource: %s\nTarget: %s"
+
 	// Error used when os.Open fails
 	osOpenError = "Failed to open.\nPath: %s"
 
@@ -141,6 +145,10 @@ const (
 	// Error used when certain function is not implemented on this system
 	notImplementedOnThisSystemError = "Not implemented for this system."
 
+	// Error used when the "build" property of the export target is invalid
+	unknownMinecraftBuildError = "Unknown build of Minecraft.\nBuild: %s\n" +
+		"Valid builds on this system: %s"
+
 	// Error used when recycled copy ClearCachedStates function fails
 	clearCachedStatesError = "Failed to clear cached file path states."
 
@@ -156,6 +164,14 @@ const (
 
 	filterRunnerRunError = "Failed to run filter.\nFilter: %s"
 
+	// Error used when the run is cancelled by the program that embeds
+	// Regolith (see RunEmbedded)
+	runCancelledError = "The run was cancelled."
+
+	// Error used when Ctrl+C is pressed during the export (see
+	// checkExportInterrupt)
+	exportInterruptedError = "Regolith was interrupted during the export."
+
 	// Error used when GetRegolithConfigPath fails
 	getRegolithConfigPathError = "Failed to get path to Regolith's app data folder."
 )
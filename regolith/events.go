@@ -0,0 +1,171 @@
+package regolith
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventsOutput is the target of the stream of the events of the runs, set
+// with the "--events" flag (see OpenEventStream).
+var EventsOutput = ""
+
+// runEvent is a line of the stream of the events. The events of the runs
+// are "run-start" and "run-done", "setup-start" and "setup-done",
+// "filter-start" and "filter-done", "filter-diagnostic", "export-start" and
+// "export-done". The watch mode also sends "watch-iteration" before every
+// rerun of the profile.
+type runEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Profile is the name of the profile. The events of the nested
+	// profiles have the names of all of the profiles, separated by " > ".
+	Profile string `json:"profile,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+	// Duration is the duration of the phase or the run in seconds, sent
+	// with the "-done" events.
+	Duration float64 `json:"duration,omitempty"`
+	// Error is the error of the failed run ("run-done").
+	Error      string            `json:"error,omitempty"`
+	Diagnostic *FilterDiagnostic `json:"diagnostic,omitempty"`
+	// Iteration is the number of the rerun in the watch mode and Source
+	// is the source of the changes that caused it ("rp", "bp", "data",
+	// the name of an additional pack, or "rebuild" if the rerun was
+	// requested with the "r" key, see watchControls).
+	Iteration int    `json:"iteration,omitempty"`
+	Source    string `json:"source,omitempty"`
+}
+
+var (
+	eventStreamMutex sync.Mutex
+	// eventStream is the writer of the events, nil if the events aren't
+	// sent.
+	eventStream io.Writer
+)
+
+// OpenEventStream opens the EventsOutput target for the events of the runs,
+// if it's set. The events are written as JSON objects, one per line
+// (NDJSON), so the editors and other tools can follow the runs without
+// parsing the log messages. The target can be:
+//   - "fd:<number>" - a file descriptor inherited from the parent process,
+//   - "unix:<path>" - a Unix domain socket,
+//   - "tcp:<host>:<port>" - a TCP socket,
+//   - any other value is the path to a file, which is overwritten.
+func OpenEventStream() error {
+	if EventsOutput == "" {
+		return nil
+	}
+	var stream io.Writer
+	switch target := EventsOutput; {
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.ParseUint(strings.TrimPrefix(target, "fd:"), 10, 32)
+		if err != nil {
+			return WrappedErrorf(
+				"Invalid file descriptor of the events.\nTarget: %s", target)
+		}
+		stream = os.NewFile(uintptr(fd), target)
+	case strings.HasPrefix(target, "unix:"), strings.HasPrefix(target, "tcp:"):
+		network, address, _ := strings.Cut(target, ":")
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to connect to the socket of the events.\n"+
+					"Target: %s", target)
+		}
+		stream = conn
+	default:
+		file, err := os.Create(target)
+		if err != nil {
+			return WrapErrorf(err, fileWriteError, target)
+		}
+		stream = file
+	}
+	eventStreamMutex.Lock()
+	eventStream = stream
+	eventStreamMutex.Unlock()
+	return nil
+}
+
+// CloseEventStream closes the stream opened by OpenEventStream.
+func CloseEventStream() {
+	eventStreamMutex.Lock()
+	defer eventStreamMutex.Unlock()
+	if closer, ok := eventStream.(io.Closer); ok {
+		closer.Close()
+	}
+	eventStream = nil
+}
+
+// setEventStream replaces the writer of the events and returns the previous
+// one. The daemon uses it for sending the events to the CLI (see
+// sendToDaemon).
+func setEventStream(stream io.Writer) io.Writer {
+	eventStreamMutex.Lock()
+	defer eventStreamMutex.Unlock()
+	previous := eventStream
+	eventStream = stream
+	return previous
+}
+
+// eventsEnabled returns true if the events of the runs are sent.
+func eventsEnabled() bool {
+	eventStreamMutex.Lock()
+	defer eventStreamMutex.Unlock()
+	return eventStream != nil
+}
+
+// emitEvent sends the event to the stream of the events, if it's open. If
+// the stream can't be written (for example because the tool reading it
+// exited), the events are disabled with a warning, and the run continues.
+func emitEvent(event runEvent) {
+	eventStreamMutex.Lock()
+	defer eventStreamMutex.Unlock()
+	if eventStream == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	data, _ := json.Marshal(event) // no error
+	if _, err := eventStream.Write(append(data, '\n')); err != nil {
+		Logger.Warnf("Failed to send the events, they're disabled: %s", err)
+		eventStream = nil
+	}
+}
+
+// emitWatchIteration sends the "watch-iteration" event before the rerun of
+// the profile of the context caused by the changes of the source.
+func emitWatchIteration(context RunContext, iteration int, source string) {
+	emitEvent(runEvent{
+		Type:      "watch-iteration",
+		Profile:   context.Profile,
+		Iteration: iteration,
+		Source:    source,
+	})
+}
+
+// emitPhaseEvent sends the "-start" or the "-done" event of the phase of
+// the run (see startPhase). The stack is the same as the Stack of the
+// timedPhase.
+func emitPhaseEvent(stack []string, done bool, duration time.Duration) {
+	name := stack[len(stack)-1]
+	event := runEvent{
+		Type:    name + "-start",
+		Profile: strings.Join(stack[:len(stack)-1], " > "),
+	}
+	// The phases of the filters are named "filter <id>"
+	if filterId := strings.TrimPrefix(name, "filter "); filterId != name {
+		event.Type = "filter-start"
+		event.Filter = filterId
+	}
+	if done {
+		event.Type = strings.TrimSuffix(event.Type, "-start") + "-done"
+		event.Duration = duration.Seconds()
+	}
+	emitEvent(event)
+}
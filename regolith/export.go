@@ -2,6 +2,7 @@ package regolith
 
 import (
 	"os"
+	"os/signal"
 	"path/filepath"
 )
 
@@ -11,8 +12,13 @@ import (
 func GetExportPaths(
 	exportTarget ExportTarget, name string,
 ) (bpPath string, rpPath string, err error) {
+	if exportTarget.Symlink && usesLocalExportPaths(exportTarget.Target) {
+		return "", "", WrappedErrorf(
+			"The \"symlink\" property can't be used with the %q export "+
+				"target", exportTarget.Target)
+	}
 	if exportTarget.Target == "development" {
-		comMojang, err := FindMojangDir()
+		comMojang, err := findMojangDirOfTarget(exportTarget)
 		if err != nil {
 			return "", "", WrapError(
 				err, "Failed to find \"com.mojang\" directory.")
@@ -37,40 +43,39 @@ func GetExportPaths(
 		bpPath = exportTarget.BpPath
 		rpPath = exportTarget.RpPath
 	} else if exportTarget.Target == "world" {
-		if exportTarget.WorldPath != "" {
-			if exportTarget.WorldName != "" {
-				return "", "", WrappedError(
-					"Using both \"worldName\" and \"worldPath\" is not" +
-						" allowed.")
-			}
-			bpPath = filepath.Join(
-				exportTarget.WorldPath, "behavior_packs", name+"_bp")
-			rpPath = filepath.Join(
-				exportTarget.WorldPath, "resource_packs", name+"_rp")
-		} else if exportTarget.WorldName != "" {
-			dir, err := FindMojangDir()
-			if err != nil {
-				return "", "", WrapError(
-					err, "Failed to find \"com.mojang\" directory.")
+		worldPath, err := FindWorldPath(exportTarget)
+		if err != nil {
+			return "", "", PassError(err)
+		}
+		bpPath = filepath.Join(worldPath, "behavior_packs", name+"_bp")
+		rpPath = filepath.Join(worldPath, "resource_packs", name+"_rp")
+	} else if usesLocalExportPaths(exportTarget.Target) {
+		// Some of the targets publish the files exported to the local paths
+		if isWorldArchiveExportTarget(exportTarget.Target) {
+			if _, err := FindWorldPath(exportTarget); err != nil {
+				return "", "", PassError(err)
 			}
-			worlds, err := ListWorlds(dir)
-			if err != nil {
-				return "", "", WrapError(err, "Failed to list worlds.")
+		}
+		if exportTarget.Target == "sftp" &&
+			(exportTarget.Host == "" || exportTarget.Path == "") {
+			return "", "", WrappedError(
+				"The \"sftp\" export target requires the \"host\" and " +
+					"\"path\" properties")
+		}
+		if exportTarget.Target == "s3" {
+			if exportTarget.Bucket == "" {
+				return "", "", WrappedError(
+					"The \"s3\" export target requires the \"bucket\" " +
+						"property")
 			}
-			for _, world := range worlds {
-				if world.Name == exportTarget.WorldName {
-					bpPath = filepath.Join(
-						world.Path, "behavior_packs", name+"_bp")
-					rpPath = filepath.Join(
-						world.Path, "resource_packs", name+"_rp")
-				}
+			if exportTarget.Archive != "" &&
+				!IsArchiveExportTarget(exportTarget.Archive) {
+				return "", "", WrappedErrorf(
+					"The \"archive\" property of the \"s3\" export target "+
+						"must be the name of an archive export target.\n"+
+						"Archive: %s", exportTarget.Archive)
 			}
-		} else {
-			err = WrappedError(
-				"The \"world\" export target requires either a " +
-					"\"worldName\" or \"worldPath\" property")
 		}
-	} else if exportTarget.Target == "local" {
 		bpPath = "build/BP/"
 		rpPath = "build/RP/"
 	} else {
@@ -80,22 +85,168 @@ func GetExportPaths(
 	return
 }
 
+// usesLocalExportPaths returns true if the export target exports the packs
+// to the "build" folder of the project.
+func usesLocalExportPaths(target string) bool {
+	return target == "local" || target == "sftp" || target == "s3" ||
+		target == "adb" || IsArchiveExportTarget(target)
+}
+
+// publishLocalExport runs the additional steps of the export targets that
+// publish the packs exported to the "build" folder (see
+// usesLocalExportPaths), like packing them into archives or uploading them to
+// a server.
+func publishLocalExport(
+	exportTarget ExportTarget, name, bpPath, rpPath, dotRegolithPath string,
+) error {
+	if IsArchiveExportTarget(exportTarget.Target) {
+		_, err := CreatePackArchives(exportTarget, name, bpPath, rpPath)
+		if err != nil {
+			return WrapError(err, "Failed to create archives of the packs.")
+		}
+	} else if exportTarget.Target == "sftp" {
+		err := UploadPacksSftp(
+			exportTarget, name, bpPath, rpPath, dotRegolithPath)
+		if err != nil {
+			return WrapError(err, "Failed to upload the packs over SFTP.")
+		}
+	} else if exportTarget.Target == "s3" {
+		err := UploadPacksS3(exportTarget, name, bpPath, rpPath)
+		if err != nil {
+			return WrapError(err, "Failed to upload the packs to S3.")
+		}
+	} else if exportTarget.Target == "adb" {
+		err := PushPacksAdb(exportTarget, name, bpPath, rpPath)
+		if err != nil {
+			return WrapError(
+				err, "Failed to push the packs to the Android device.")
+		}
+	}
+	return nil
+}
+
+// FindWorldPath returns the path to the world selected by the "worldName" or
+// "worldPath" property of the export target. The worlds selected by name are
+// searched in the "com.mojang" directory of the Minecraft build from the
+// "build" property.
+func FindWorldPath(exportTarget ExportTarget) (string, error) {
+	if exportTarget.WorldPath != "" {
+		if exportTarget.WorldName != "" {
+			return "", WrappedError(
+				"Using both \"worldName\" and \"worldPath\" is not" +
+					" allowed.")
+		}
+		return exportTarget.WorldPath, nil
+	} else if exportTarget.WorldName != "" {
+		dir, err := findMojangDirOfTarget(exportTarget)
+		if err != nil {
+			return "", WrapError(
+				err, "Failed to find \"com.mojang\" directory.")
+		}
+		worlds, err := ListWorlds(dir)
+		if err != nil {
+			return "", WrapError(err, "Failed to list worlds.")
+		}
+		for _, world := range worlds {
+			if world.Name == exportTarget.WorldName {
+				return world.Path, nil
+			}
+		}
+		return "", WrappedErrorf(
+			"Failed to find the world.\nWorld name: %s",
+			exportTarget.WorldName)
+	}
+	return "", WrappedErrorf(
+		"The %q export target requires either a \"worldName\" or "+
+			"\"worldPath\" property", exportTarget.Target)
+}
+
 // RecycledExportProject copies files from the tmp paths (tmp/BP and tmp/RP)
 // into the project's export target. The paths are generated with
 // GetExportPaths. The function uses cached data about the state of the project
 // files to reduce the number of file system operations.
 func RecycledExportProject(
 	profile Profile, name, dataPath, dotRegolithPath string,
+	additionalPacks map[string]Packs, symlinks string,
 ) error {
-	exportTarget := profile.ExportTarget
+	exportTarget, err := resolveExportTargetSecrets(profile.ExportTarget)
+	if err != nil {
+		return PassError(err)
+	}
+	profile.ExportTarget = exportTarget
 	bpPath, rpPath, err := GetExportPaths(exportTarget, name)
 	if err != nil {
 		return WrapError(
 			err, "Failed to get generate export paths.")
 	}
+	err = checkAdditionalPacksExport(exportTarget, additionalPacks)
+	if err != nil {
+		return PassError(err)
+	}
+	packs := listAdditionalPacks(additionalPacks)
+	err = ExcludeExportedFiles(exportTarget.Exclude, dotRegolithPath)
+	if err != nil {
+		return WrapError(err, "Failed to exclude files from the export.")
+	}
+	err = applyExportSymlinkPolicy(symlinks, dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to handle the symbolic links of the packs.")
+	}
+	err = checkExportedFileNames(exportTarget.FileNameCheck, dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	err = checkUnicodeNormalization(
+		exportTarget.UnicodeNormalization, dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to check the Unicode normalization of the packs.")
+	}
+	err = RunExportHooks(
+		"preExport", exportTarget.PreExport, exportTarget, name, bpPath,
+		rpPath)
+	if err != nil {
+		return WrapError(err, "Failed to run the preExport commands.")
+	}
+	var report *exportReport
+	if usesExportReport(exportTarget) {
+		report, err = prepareExportReport(dotRegolithPath)
+		if err != nil {
+			return WrapError(err, "Failed to prepare the export report.")
+		}
+	}
 
+	// The recycled export can't be undone, the journal only marks the
+	// export as unfinished, so the next run exports all of the files again.
+	// When the export stops with an error, the next export is made a full
+	// export right away and the journal is removed.
+	journal, err := openExportJournal(
+		filepath.Join(dotRegolithPath, exportJournalPath), "")
+	if err != nil {
+		return WrapError(err, "Failed to create the journal of the export.")
+	}
+	journalClosed := false
+	defer func() {
+		if journalClosed {
+			return
+		}
+		if err := forceFullExport(dotRegolithPath); err != nil {
+			journal.Close() // The next run recovers the export
+			return
+		}
+		closeExportJournal(journal)
+	}()
 	// Loading edited_files.json or creating empty object
 	editedFiles := LoadEditedFiles(dotRegolithPath)
+	bpLink, rpLink := bpPath, rpPath
+	if exportTarget.Symlink {
+		bpPath, rpPath, err = prepareLinkedExport(
+			nil, editedFiles, bpLink, rpLink)
+		if err != nil {
+			return PassError(err)
+		}
+	}
 	err = editedFiles.CheckDeletionSafety(rpPath, bpPath)
 	if err != nil {
 		return WrapErrorf(
@@ -108,17 +259,29 @@ func RecycledExportProject(
 				"Behavior pack export path: %s",
 			rpPath, bpPath)
 	}
+	for _, pack := range packs {
+		err = editedFiles.checkPackDeletionSafety(
+			pack.Kind, pack.ExportPath(bpPath, rpPath))
+		if err != nil {
+			return WrapErrorf(
+				err,
+				"Safety mechanism stopped Regolith to protect unexpected "+
+					"files from the export path of an additional pack.\n"+
+					"Pack: %s", pack.TmpDir)
+		}
+	}
 
+	exportedFiles := countTmpPackFiles(dotRegolithPath)
 	Logger.Infof("Exporting behavior pack to \"%s\".", bpPath)
 	err = FullRecycledMoveOrCopy(
 		filepath.Join(dotRegolithPath, "tmp/BP"), bpPath,
 		RecycledMoveOrCopySettings{
-			canMove:                 true,
-			saveSourceHashes:        true,
-			saveTargetHashes:        true,
-			makeTargetReadOnly:      exportTarget.ReadOnly,
-			copyTargetAclFromParent: true,
-			reloadSourceHashes:      true,
+			canMove:            true,
+			saveSourceHashes:   true,
+			saveTargetHashes:   true,
+			makeTargetReadOnly: exportTarget.ReadOnly,
+			targetAcl:          exportAcl(exportTarget),
+			reloadSourceHashes: true,
 		})
 	if err != nil {
 		return WrapError(err, "Failed to export behavior pack.")
@@ -127,25 +290,45 @@ func RecycledExportProject(
 	err = FullRecycledMoveOrCopy(
 		filepath.Join(dotRegolithPath, "tmp/RP"), rpPath,
 		RecycledMoveOrCopySettings{
-			canMove:                 true,
-			saveSourceHashes:        true,
-			saveTargetHashes:        true,
-			makeTargetReadOnly:      exportTarget.ReadOnly,
-			copyTargetAclFromParent: true,
-			reloadSourceHashes:      true,
+			canMove:            true,
+			saveSourceHashes:   true,
+			saveTargetHashes:   true,
+			makeTargetReadOnly: exportTarget.ReadOnly,
+			targetAcl:          exportAcl(exportTarget),
+			reloadSourceHashes: true,
 		})
 	if err != nil {
 		return WrapError(err, "Failed to export resource pack.")
 	}
+	for _, pack := range packs {
+		packPath := pack.ExportPath(bpPath, rpPath)
+		Logger.Infof("Exporting %s to \"%s\".", pack.TmpDir, packPath)
+		err = FullRecycledMoveOrCopy(
+			filepath.Join(dotRegolithPath, "tmp", pack.TmpDir), packPath,
+			RecycledMoveOrCopySettings{
+				canMove:            true,
+				saveSourceHashes:   true,
+				saveTargetHashes:   true,
+				makeTargetReadOnly: exportTarget.ReadOnly,
+				targetAcl:          exportAcl(exportTarget),
+				reloadSourceHashes: true,
+			})
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to export an additional pack.\nPack: %s",
+				pack.TmpDir)
+		}
+	}
+	recordExportedFiles(exportedFiles)
 	err = FullRecycledMoveOrCopy(
 		filepath.Join(dotRegolithPath, "tmp/data"), dataPath,
 		RecycledMoveOrCopySettings{
-			canMove:                 true,
-			saveSourceHashes:        true,
-			saveTargetHashes:        false,
-			makeTargetReadOnly:      false,
-			copyTargetAclFromParent: false,
-			reloadSourceHashes:      true,
+			canMove:            true,
+			saveSourceHashes:   true,
+			saveTargetHashes:   false,
+			makeTargetReadOnly: false,
+			reloadSourceHashes: true,
+			reloadTargetHashes: true,
 		})
 	if err != nil {
 		return WrapError(
@@ -155,6 +338,15 @@ func RecycledExportProject(
 
 	// Update or create edited_files.json
 	err = editedFiles.UpdateFromPaths(rpPath, bpPath)
+	if err == nil {
+		for _, pack := range packs {
+			err = editedFiles.updateFromPackPath(
+				pack.Kind, pack.ExportPath(bpPath, rpPath))
+			if err != nil {
+				break
+			}
+		}
+	}
 	if err != nil {
 		return WrapError(
 			err,
@@ -166,25 +358,157 @@ func RecycledExportProject(
 			err, "Failed to update the list of the files edited by Regolith."+
 				"This may cause the next run to fail.")
 	}
+	journalClosed = true
+	if err := closeExportJournal(journal); err != nil {
+		return PassError(err)
+	}
+	setExportReproducibleTimes(bpPath, rpPath, packs)
+	if exportTarget.Symlink {
+		err = createExportLinks(bpPath, rpPath, bpLink, rpLink)
+		if err != nil {
+			return WrapError(err, "Failed to link the export paths.")
+		}
+	}
+	err = publishLocalExport(exportTarget, name, bpPath, rpPath, dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	if report != nil {
+		err = report.Finish(exportTarget, dotRegolithPath)
+		if err != nil {
+			Logger.Warnf(
+				"Failed to create the export report.\n%s",
+				PassError(err).Error())
+		}
+	}
+	err = RunExportHooks(
+		"postExport", exportTarget.PostExport, exportTarget, name, bpLink,
+		rpLink)
+	if err != nil {
+		return WrapError(err, "Failed to run the postExport commands.")
+	}
 	return nil
 }
 
 // ExportProject copies files from the tmp paths (tmp/BP and tmp/RP) into
 // the project's export target. The paths are generated with GetExportPaths.
+// The changes of the export paths and the data path are journaled, so if the
+// export fails or is interrupted with Ctrl+C, the previous version of the
+// files is restored. The journal is also saved to a file, so the files can be
+// restored by the next run if Regolith is killed during the export (see
+// recoverInterruptedExport). If the packs and the export target didn't change since
+// the previous export (see exportHash), only the data is moved back to the
+// data path.
 func ExportProject(
 	profile Profile, name, dataPath, dotRegolithPath string,
+	additionalPacks map[string]Packs, symlinks string,
 ) error {
-	exportTarget := profile.ExportTarget
+	exportTarget, err := resolveExportTargetSecrets(profile.ExportTarget)
+	if err != nil {
+		return PassError(err)
+	}
+	profile.ExportTarget = exportTarget
 	bpPath, rpPath, err := GetExportPaths(exportTarget, name)
 	if err != nil {
 		return WrapError(
 			err, "Failed to get generate export paths.")
 	}
+	err = checkAdditionalPacksExport(exportTarget, additionalPacks)
+	if err != nil {
+		return PassError(err)
+	}
+	packs := listAdditionalPacks(additionalPacks)
+	err = ExcludeExportedFiles(exportTarget.Exclude, dotRegolithPath)
+	if err != nil {
+		return WrapError(err, "Failed to exclude files from the export.")
+	}
+	err = applyExportSymlinkPolicy(symlinks, dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to handle the symbolic links of the packs.")
+	}
+	err = checkExportedFileNames(exportTarget.FileNameCheck, dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	err = checkUnicodeNormalization(
+		exportTarget.UnicodeNormalization, dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to check the Unicode normalization of the packs.")
+	}
+	// Skip exporting the packs if they didn't change since the previous
+	// export
+	hash, err := exportHash(
+		exportTarget, name, bpPath, rpPath, dotRegolithPath, packs)
+	if err != nil {
+		Logger.Warnf(
+			"Failed to check if the packs changed since the previous "+
+				"export.\n%s", PassError(err).Error())
+		hash = ""
+	}
+	exportPaths := []string{bpPath, rpPath}
+	for _, pack := range packs {
+		exportPaths = append(exportPaths, pack.ExportPath(bpPath, rpPath))
+	}
+	if hash != "" && isExportUnchanged(hash, dotRegolithPath, exportPaths) {
+		Logger.Info(
+			"The packs didn't change since the previous export, skipping " +
+				"the export.")
+		return exportDataOnly(dataPath, dotRegolithPath)
+	}
+	saveExportHash("", dotRegolithPath)
+	err = unlinkTmpFiles(dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to replace the hardlinks to the source files in "+
+				"the temporary directory.")
+	}
+	err = RunExportHooks(
+		"preExport", exportTarget.PreExport, exportTarget, name, bpPath,
+		rpPath)
+	if err != nil {
+		return WrapError(err, "Failed to run the preExport commands.")
+	}
+	var report *exportReport
+	if usesExportReport(exportTarget) {
+		report, err = prepareExportReport(dotRegolithPath)
+		if err != nil {
+			return WrapError(err, "Failed to prepare the export report.")
+		}
+	}
 
 	// Loading edited_files.json or creating empty object
 	editedFiles := LoadEditedFiles(dotRegolithPath)
+	backupPath := filepath.Join(dotRegolithPath, ".dataBackup")
+	revertibleOps, err := NewRevertableFsOperaitons(backupPath)
+	if err != nil {
+		return WrapErrorf(err, "Failed to prepare backup path for revertable"+
+			" file system operations.\n"+
+			"Path that Regolith tried to use: %s", backupPath)
+	}
+	err = revertibleOps.startJournal(
+		filepath.Join(dotRegolithPath, exportJournalPath))
+	if err != nil {
+		revertibleOps.Close()
+		return PassError(err)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	bpLink, rpLink := bpPath, rpPath
+	if exportTarget.Symlink {
+		bpPath, rpPath, err = prepareLinkedExport(
+			revertibleOps, editedFiles, bpLink, rpLink)
+		if err != nil {
+			revertExport(revertibleOps)
+			return PassError(err)
+		}
+	}
 	err = editedFiles.CheckDeletionSafety(rpPath, bpPath)
 	if err != nil {
+		revertExport(revertibleOps)
 		return WrapErrorf(
 			err,
 			"Safety mechanism stopped Regolith to protect unexpected files "+
@@ -195,21 +519,140 @@ func ExportProject(
 				"Behavior pack export path: %s",
 			rpPath, bpPath)
 	}
+	for _, pack := range packs {
+		err = editedFiles.checkPackDeletionSafety(
+			pack.Kind, pack.ExportPath(bpPath, rpPath))
+		if err != nil {
+			revertExport(revertibleOps)
+			return WrapErrorf(
+				err,
+				"Safety mechanism stopped Regolith to protect unexpected "+
+					"files from the export path of an additional pack.\n"+
+					"Pack: %s", pack.TmpDir)
+		}
+	}
 
-	// Clearing output locations
-	// Spooky, I hope file protection works, and it won't do any damage
-	err = os.RemoveAll(bpPath)
+	err = clearDataPath(revertibleOps, dataPath)
 	if err != nil {
-		return WrapErrorf(
-			err, "Failed to clear behavior pack from build path %q.\n"+
-				"Are user permissions correct?", bpPath)
+		revertExport(revertibleOps)
+		return PassError(err)
+	}
+	if err := checkExportInterrupt(interrupt, revertibleOps); err != nil {
+		return PassError(err)
 	}
-	err = os.RemoveAll(rpPath)
+
+	exportedFiles := countTmpPackFiles(dotRegolithPath)
+	Logger.Infof("Exporting behavior pack to \"%s\".", bpPath)
+	err = ExportPack(
+		revertibleOps, filepath.Join(dotRegolithPath, "tmp/BP"), bpPath,
+		dotRegolithPath, exportTarget.ReadOnly, exportAcl(exportTarget))
 	if err != nil {
-		return WrapErrorf(
-			err, "Failed to clear resource pack from build path %q.\n"+
-				"Are user permissions correct?", rpPath)
+		revertExport(revertibleOps)
+		return WrapError(err, "Failed to export behavior pack.")
+	}
+	if err := checkExportInterrupt(interrupt, revertibleOps); err != nil {
+		return PassError(err)
+	}
+	Logger.Infof("Exporting project to \"%s\".", filepath.Clean(rpPath))
+	err = ExportPack(
+		revertibleOps, filepath.Join(dotRegolithPath, "tmp/RP"), rpPath,
+		dotRegolithPath, exportTarget.ReadOnly, exportAcl(exportTarget))
+	if err != nil {
+		revertExport(revertibleOps)
+		return WrapError(err, "Failed to export resource pack.")
+	}
+	if err := checkExportInterrupt(interrupt, revertibleOps); err != nil {
+		return PassError(err)
+	}
+	for _, pack := range packs {
+		packPath := pack.ExportPath(bpPath, rpPath)
+		Logger.Infof("Exporting %s to \"%s\".", pack.TmpDir, packPath)
+		err = ExportPack(
+			revertibleOps, filepath.Join(dotRegolithPath, "tmp", pack.TmpDir),
+			packPath, dotRegolithPath, exportTarget.ReadOnly,
+			exportAcl(exportTarget))
+		if err != nil {
+			revertExport(revertibleOps)
+			return WrapErrorf(
+				err, "Failed to export an additional pack.\nPack: %s",
+				pack.TmpDir)
+		}
+		if err := checkExportInterrupt(interrupt, revertibleOps); err != nil {
+			return PassError(err)
+		}
+	}
+	recordExportedFiles(exportedFiles)
+	err = revertibleOps.MoveoOrCopyDir(
+		filepath.Join(dotRegolithPath, "tmp/data"), dataPath)
+	if err != nil {
+		revertExport(revertibleOps)
+		return WrapError(
+			err, "Failed to move the filter data back to the project's "+
+				"data folder.")
+	}
+	if err := checkExportInterrupt(interrupt, revertibleOps); err != nil {
+		return PassError(err)
+	}
+
+	// Update or create edited_files.json
+	err = editedFiles.UpdateFromPaths(rpPath, bpPath)
+	if err == nil {
+		for _, pack := range packs {
+			err = editedFiles.updateFromPackPath(
+				pack.Kind, pack.ExportPath(bpPath, rpPath))
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		revertExport(revertibleOps)
+		return WrapError(
+			err,
+			"Failed to create a list of files edited by this 'regolith run'")
+	}
+	err = editedFiles.Dump(dotRegolithPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to update the list of the files edited by Regolith."+
+				"This may cause the next run to fail.")
+	}
+	if err := revertibleOps.Close(); err != nil {
+		return PassError(err)
+	}
+	setExportReproducibleTimes(bpPath, rpPath, packs)
+	if exportTarget.Symlink {
+		err = createExportLinks(bpPath, rpPath, bpLink, rpLink)
+		if err != nil {
+			return WrapError(err, "Failed to link the export paths.")
+		}
+	}
+	err = publishLocalExport(exportTarget, name, bpPath, rpPath, dotRegolithPath)
+	if err != nil {
+		return PassError(err)
 	}
+	if report != nil {
+		err = report.Finish(exportTarget, dotRegolithPath)
+		if err != nil {
+			Logger.Warnf(
+				"Failed to create the export report.\n%s",
+				PassError(err).Error())
+		}
+	}
+	err = RunExportHooks(
+		"postExport", exportTarget.PostExport, exportTarget, name, bpLink,
+		rpLink)
+	if err != nil {
+		return WrapError(err, "Failed to run the postExport commands.")
+	}
+	saveExportHash(hash, dotRegolithPath)
+	return nil
+}
+
+// clearDataPath removes the files from the data path before the data from the
+// tmp directory is moved back to it. The changes are recorded in the
+// revertible operations (r).
+func clearDataPath(r *RevertableFsOperations, dataPath string) error {
 	// The root of the data path cannot be deleted because the
 	// "regolith watch" function would stop watching the file changes
 	// (due to Windows API limitation).
@@ -225,18 +668,10 @@ func ExportProject(
 				dataPath)
 		}
 	}
-	backupPath := filepath.Join(dotRegolithPath, ".dataBackup")
-	revertibleOps, err := NewRevertableFsOperaitons(backupPath)
-	if err != nil {
-		return WrapErrorf(err, "Failed to prepare backup path for revertable"+
-			" file system operations.\n"+
-			"Path that Regolith tried to use: %s", backupPath)
-	}
 	for _, path := range paths {
 		path := filepath.Join(dataPath, path.Name())
-		err = revertibleOps.DeleteDir(path)
+		err = r.DeleteDir(path)
 		if err != nil {
-			revertibleOps.Undo()
 			return WrapError(
 				err, "Failed clear filters data before replacing it with "+
 					"updated version of the files.\n"+
@@ -254,41 +689,52 @@ func ExportProject(
 					"Make sure that you don't open it inside the filters data path.")
 		}
 	}
+	return nil
+}
 
-	Logger.Infof("Exporting behavior pack to \"%s\".", bpPath)
-	err = MoveOrCopy(filepath.Join(dotRegolithPath, "tmp/BP"), bpPath, exportTarget.ReadOnly, true)
+// revertExport restores the export paths and the data path from the backups
+// of the revertible operations, after a failed export. If the files can't be
+// restored, the backups are kept, so they can be restored manually.
+func revertExport(r *RevertableFsOperations) {
+	Logger.Warn("Reverting the changes made by the export.")
+	err := r.Undo()
 	if err != nil {
-		return WrapError(err, "Failed to export behavior pack.")
+		Logger.Errorf(
+			"Failed to revert the export. The backups of the files are in "+
+				"\"%s\".\n%s", r.backupPath, PassError(err).Error())
+		return
 	}
-	Logger.Infof("Exporting project to \"%s\".", filepath.Clean(rpPath))
-	err = MoveOrCopy(filepath.Join(dotRegolithPath, "tmp/RP"), rpPath, exportTarget.ReadOnly, true)
-	if err != nil {
-		return WrapError(err, "Failed to export resource pack.")
-	}
-	err = revertibleOps.MoveoOrCopyDir(
-		filepath.Join(dotRegolithPath, "tmp/data"), dataPath)
-	if err != nil {
-		revertibleOps.Undo()
-		return WrapError(
-			err, "Failed to move the filter data back to the project's "+
-				"data folder.")
+	if err := r.Close(); err != nil {
+		Logger.Warn(PassError(err).Error())
 	}
+}
 
-	// Update or create edited_files.json
-	err = editedFiles.UpdateFromPaths(rpPath, bpPath)
-	if err != nil {
-		return WrapError(
-			err,
-			"Failed to create a list of files edited by this 'regolith run'")
-	}
-	err = editedFiles.Dump(dotRegolithPath)
-	if err != nil {
-		return WrapError(
-			err, "Failed to update the list of the files edited by Regolith."+
-				"This may cause the next run to fail.")
+// checkExportInterrupt reverts the export and returns an error, if Ctrl+C
+// was pressed since the start of the export. The signal is caught during the
+// export, so the export is only interrupted between its steps. The error
+// stops Regolith (see IsExportInterruptedError), after the callers release
+// the lock of the project and stop the persistent processes.
+func checkExportInterrupt(
+	interrupt chan os.Signal, r *RevertableFsOperations,
+) error {
+	select {
+	case <-interrupt:
+	default:
+		return nil
 	}
-	if err := revertibleOps.Close(); err != nil {
-		return PassError(err)
+	Logger.Warn("The export was interrupted.")
+	revertExport(r)
+	return WrappedError(exportInterruptedError)
+}
+
+// IsExportInterruptedError returns true if the error was caused by pressing
+// Ctrl+C during the export (see checkExportInterrupt).
+func IsExportInterruptedError(err error) bool {
+	code := findErrorCode(exportInterruptedError).Code
+	for _, c := range ErrorCodes(err) {
+		if c == code {
+			return true
+		}
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,58 @@
+package regolith
+
+import "os"
+
+// The modes of setting the permissions of the exported packs, selected with
+// the "acl" property of the export target.
+const (
+	// exportAclInherit gives the exported packs the permissions of the
+	// folder they're exported to. On Windows, the DACL of the parent folder
+	// is copied to the pack. On the other systems, the permission bits are
+	// reset to the defaults of the new files (see the umask), and the group
+	// of the parent folder is used if it has the setgid bit. It's the
+	// default mode.
+	exportAclInherit = "inherit"
+	// exportAclPreserve keeps the permissions of the files from the tmp
+	// directory. On Windows, the DACL of the tmp directory is copied to the
+	// packs that couldn't be moved.
+	exportAclPreserve = "preserve"
+	// exportAclNone doesn't change the permissions, for the file systems
+	// that don't support them, like the network shares and the FAT drives.
+	exportAclNone = "none"
+)
+
+// exportAclModes are the valid values of the "acl" property.
+var exportAclModes = []string{
+	exportAclInherit, exportAclPreserve, exportAclNone}
+
+// exportAcl returns the mode of the permissions of the export target, with
+// the default mode for the targets without the "acl" property.
+func exportAcl(target ExportTarget) string {
+	if target.Acl == "" {
+		return exportAclInherit
+	}
+	return target.Acl
+}
+
+// applyExportAcl sets the permissions of the target path, which was moved or
+// copied from the source path, using the mode (see exportAclModes). The
+// source may not exist anymore if it was moved.
+func applyExportAcl(mode, source, target string) error {
+	switch mode {
+	case exportAclInherit:
+		err := inheritParentPermissions(target)
+		if err != nil {
+			return PassError(err)
+		}
+	case exportAclPreserve:
+		if _, err := os.Stat(source); err != nil {
+			// The files were moved with their permissions
+			return nil
+		}
+		err := copyFileSecurityInfo(source, target)
+		if err != nil {
+			return WrapErrorf(err, copyFileSecurityInfoError, source, target)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package regolith
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// adbMojangDirs are the possible paths to the com.mojang folder on an
+// Android device, in the order in which they are checked by the "adb" export
+// target.
+var adbMojangDirs = []string{
+	"/sdcard/Android/data/com.mojang.minecraftpe/files/games/com.mojang",
+	"/sdcard/games/com.mojang",
+}
+
+// PushPacksAdb pushes the packs exported to bpPath and rpPath to the
+// development pack folders of an Android device connected with ADB (the
+// "adb" export target). The packs on the device are replaced. The device is
+// selected with the "device" property of the export target, which is
+// required only if multiple devices are connected.
+func PushPacksAdb(exportTarget ExportTarget, name, bpPath, rpPath string) error {
+	mojangDir := exportTarget.Path
+	if mojangDir == "" {
+		var err error
+		mojangDir, err = findAdbMojangDir(exportTarget)
+		if err != nil {
+			return PassError(err)
+		}
+	}
+	packs := [][2]string{
+		{bpPath, path.Join(
+			mojangDir, "development_behavior_packs", name+"_bp")},
+		{rpPath, path.Join(
+			mojangDir, "development_resource_packs", name+"_rp")},
+	}
+	for _, pack := range packs {
+		if _, err := os.Stat(pack[0]); os.IsNotExist(err) {
+			continue
+		}
+		Logger.Infof("Pushing \"%s\" to \"%s\" on the device.", pack[0], pack[1])
+		_, err := runAdb(
+			exportTarget, "shell", "rm -rf "+adbShellQuote(pack[1])+
+				" && mkdir -p "+adbShellQuote(path.Dir(pack[1])))
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to clear the pack on the device.\nPath: %s",
+				pack[1])
+		}
+		// The pack directory doesn't exist, so its content is pushed to the
+		// target path instead of a subdirectory
+		_, err = runAdb(
+			exportTarget, "push", strings.TrimRight(pack[0], "/"), pack[1])
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to push the pack to the device.\nPath: %s",
+				pack[0])
+		}
+	}
+	return nil
+}
+
+// findAdbMojangDir returns the path to the com.mojang folder on the device
+// of the "adb" export target.
+func findAdbMojangDir(exportTarget ExportTarget) (string, error) {
+	for _, dir := range adbMojangDirs {
+		output, err := runAdb(
+			exportTarget, "shell",
+			"if [ -d "+adbShellQuote(dir)+" ]; then echo found; fi")
+		if err != nil {
+			return "", PassError(err)
+		}
+		if strings.TrimSpace(output) == "found" {
+			return dir, nil
+		}
+	}
+	return "", WrappedErrorf(
+		"Failed to find the \"com.mojang\" folder on the Android device.\n"+
+			"Checked paths:\n%s\n"+
+			"Make sure that Minecraft was started at least once, or set the "+
+			"path to the folder with the \"path\" property.",
+		strings.Join(adbMojangDirs, "\n"))
+}
+
+// runAdb runs the "adb" command on the device of the export target and
+// returns its output.
+func runAdb(exportTarget ExportTarget, args ...string) (string, error) {
+	if exportTarget.Device != "" {
+		args = append([]string{"-s", exportTarget.Device}, args...)
+	}
+	Logger.Debugf(
+		"Running adb with arguments: %s", hideSecrets(strings.Join(args, " ")))
+	output, err := exec.Command("adb", args...).CombinedOutput()
+	if err != nil {
+		return "", WrapErrorf(
+			err, execCommandError+"\nOutput:\n%s", "adb",
+			strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// adbShellQuote quotes a path for the shell of the Android device.
+func adbShellQuote(p string) string {
+	return "'" + strings.ReplaceAll(p, "'", `'\''`) + "'"
+}
@@ -0,0 +1,355 @@
+package regolith
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archiveExportPath is the directory where the archive export targets save
+// the archives.
+const archiveExportPath = "build"
+
+// IsArchiveExportTarget returns true if the export target packs the exported
+// files into archives ("mcpack", "mcaddon", "mcworld" or "mctemplate").
+func IsArchiveExportTarget(target string) bool {
+	return target == "mcpack" || target == "mcaddon" ||
+		isWorldArchiveExportTarget(target)
+}
+
+// isWorldArchiveExportTarget returns true if the export target packs the
+// exported files into a world ("mcworld" or "mctemplate").
+func isWorldArchiveExportTarget(target string) bool {
+	return target == "mcworld" || target == "mctemplate"
+}
+
+// archiveSource is a directory packed into an archive.
+type archiveSource struct {
+	// Prefix is the path of the directory inside the archive. An empty string
+	// is the root of the archive.
+	Prefix string
+	// Path is the path to the directory with the files to pack.
+	Path string
+}
+
+// CreatePackArchives packs the exported behavior pack and resource pack into
+// archives. The "mcpack" target creates a separate .mcpack file for each of
+// the packs, and the "mcaddon" target creates a single .mcaddon file with
+// both of them. The "mcworld" and "mctemplate" targets embed the packs into
+// the world selected with the "worldName" or "worldPath" property. The names
+// of the archives are based on the name of the project and the version from
+// the manifest of the behavior pack (or the resource pack if there is no
+// behavior pack). It returns the paths to the created archives.
+func CreatePackArchives(
+	exportTarget ExportTarget, name, bpPath, rpPath string,
+) ([]string, error) {
+	bpExists := packExists(bpPath)
+	rpExists := packExists(rpPath)
+	baseName := name
+	version := packVersion(bpPath)
+	if version == "" {
+		version = packVersion(rpPath)
+	}
+	if version != "" {
+		baseName += "-" + version
+	}
+	err := os.MkdirAll(archiveExportPath, 0755)
+	if err != nil {
+		return nil, WrapErrorf(err, osMkdirError, archiveExportPath)
+	}
+	var archives []string
+	switch exportTarget.Target {
+	case "mcpack":
+		if bpExists {
+			path := filepath.Join(archiveExportPath, baseName+"_bp.mcpack")
+			Logger.Infof("Packing behavior pack to \"%s\".", path)
+			err = createZipArchive(
+				path, nil, []archiveSource{{"", bpPath}})
+			if err != nil {
+				return nil, WrapError(err, "Failed to pack behavior pack.")
+			}
+			archives = append(archives, path)
+		}
+		if rpExists {
+			path := filepath.Join(archiveExportPath, baseName+"_rp.mcpack")
+			Logger.Infof("Packing resource pack to \"%s\".", path)
+			err = createZipArchive(
+				path, nil, []archiveSource{{"", rpPath}})
+			if err != nil {
+				return nil, WrapError(err, "Failed to pack resource pack.")
+			}
+			archives = append(archives, path)
+		}
+	case "mcaddon":
+		// The packs are placed in separate directories in the .mcaddon file
+		var sources []archiveSource
+		if bpExists {
+			sources = append(sources, archiveSource{name + "_bp", bpPath})
+		}
+		if rpExists {
+			sources = append(sources, archiveSource{name + "_rp", rpPath})
+		}
+		path := filepath.Join(archiveExportPath, baseName+".mcaddon")
+		Logger.Infof("Packing add-on to \"%s\".", path)
+		err = createZipArchive(path, nil, sources)
+		if err != nil {
+			return nil, WrapError(err, "Failed to pack add-on.")
+		}
+		archives = append(archives, path)
+	case "mcworld", "mctemplate":
+		worldPath, err := FindWorldPath(exportTarget)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		if exportTarget.Target == "mctemplate" {
+			_, err := os.Stat(filepath.Join(worldPath, "manifest.json"))
+			if err != nil {
+				Logger.Warnf(
+					"The world doesn't have a \"manifest.json\" file, "+
+						"Minecraft won't recognize it as a world template."+
+						"\nWorld path: %s", worldPath)
+			}
+		}
+		files := make(map[string][]byte)
+		var sources []archiveSource
+		packTypes := []struct {
+			exists   bool
+			path     string
+			dir      string
+			listFile string
+		}{
+			{bpExists, bpPath, "behavior_packs/" + name + "_bp",
+				"world_behavior_packs.json"},
+			{rpExists, rpPath, "resource_packs/" + name + "_rp",
+				"world_resource_packs.json"},
+		}
+		for _, packType := range packTypes {
+			if !packType.exists {
+				continue
+			}
+			sources = append(
+				sources, archiveSource{packType.dir, packType.path})
+			packList, err := worldPackList(
+				filepath.Join(worldPath, packType.listFile), packType.path)
+			if err != nil {
+				return nil, PassError(err)
+			}
+			files[packType.listFile] = packList
+		}
+		// The world is added last, so the exported packs and the pack lists
+		// replace its files
+		sources = append(sources, archiveSource{"", worldPath})
+		path := filepath.Join(
+			archiveExportPath, baseName+"."+exportTarget.Target)
+		Logger.Infof("Packing world to \"%s\".", path)
+		err = createZipArchive(path, files, sources)
+		if err != nil {
+			return nil, WrapError(err, "Failed to pack world.")
+		}
+		archives = append(archives, path)
+	}
+	return archives, nil
+}
+
+// worldPackList returns the content of a "world_behavior_packs.json" or
+// "world_resource_packs.json" file, that adds the pack to the list of the
+// packs from listPath. The list doesn't need to exist.
+func worldPackList(listPath, packPath string) ([]byte, error) {
+	var packList []map[string]interface{}
+	data, err := os.ReadFile(listPath)
+	if err == nil {
+		err = json.Unmarshal(data, &packList)
+		if err != nil {
+			return nil, WrapErrorf(err, jsonUnmarshalError, listPath)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, WrapErrorf(err, fileReadError, listPath)
+	}
+	uuid, version := packHeader(packPath)
+	if uuid == "" || version == nil {
+		return nil, WrappedErrorf(
+			"The manifest of the pack doesn't have a valid header.\n"+
+				"Path: %s", filepath.Join(packPath, "manifest.json"))
+	}
+	// Replace the pack if it's already on the list
+	result := []map[string]interface{}{}
+	for _, pack := range packList {
+		if pack["pack_id"] != uuid {
+			result = append(result, pack)
+		}
+	}
+	result = append(result, map[string]interface{}{
+		"pack_id": uuid,
+		"version": version,
+	})
+	data, err = json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		return nil, WrapError(err, "Failed to encode the pack list as JSON.")
+	}
+	return data, nil
+}
+
+// packExists returns true if the path is a pack with a manifest.
+func packExists(packPath string) bool {
+	_, err := os.Stat(filepath.Join(packPath, "manifest.json"))
+	return err == nil
+}
+
+// packHeader returns the UUID and the version from the header of the
+// manifest of the pack. It returns empty values if the manifest can't be
+// read.
+func packHeader(packPath string) (string, interface{}) {
+	data, err := os.ReadFile(filepath.Join(packPath, "manifest.json"))
+	if err != nil {
+		return "", nil
+	}
+	var manifest struct {
+		Header struct {
+			Uuid    string      `json:"uuid"`
+			Version interface{} `json:"version"`
+		} `json:"header"`
+	}
+	if json.Unmarshal(data, &manifest) != nil {
+		return "", nil
+	}
+	return manifest.Header.Uuid, manifest.Header.Version
+}
+
+// packVersion returns the version from the header of the manifest of the
+// pack in the "major.minor.patch" format. It returns an empty string if the
+// version can't be read.
+func packVersion(packPath string) string {
+	_, version := packHeader(packPath)
+	switch version := version.(type) {
+	case string:
+		return version
+	case []interface{}:
+		parts := make([]string, len(version))
+		for i, part := range version {
+			parts[i] = fmt.Sprint(part)
+		}
+		return strings.Join(parts, ".")
+	}
+	return ""
+}
+
+// createZipArchive creates a zip archive at path, with the files (a map of
+// the paths inside the archive to their content) and the content of the
+// source directories. If multiple sources (or files) provide the same path,
+// the first one is used. The files from the directories of the previous
+// sources are skipped. The archive is written to a temporary file first, so
+// a failed export doesn't leave a broken archive behind.
+func createZipArchive(
+	path string, files map[string][]byte, sources []archiveSource,
+) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return WrapErrorf(err, osCreateError, tmpPath)
+	}
+	writer := zip.NewWriter(file)
+	err = writeZipEntries(writer, files, sources)
+	if err1 := writer.Close(); err == nil && err1 != nil {
+		err = WrapErrorf(err1, "Failed to write archive.\nPath: %s", tmpPath)
+	}
+	if err1 := file.Close(); err == nil && err1 != nil {
+		err = WrapErrorf(err1, "Failed to close file.\nPath: %s", tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return PassError(err)
+	}
+	os.Remove(path)
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to move archive.\nSource: %s\nTarget: %s",
+			tmpPath, path)
+	}
+	return nil
+}
+
+// writeZipEntries writes the files and the content of the sources to the zip
+// writer. See createZipArchive for details.
+func writeZipEntries(
+	writer *zip.Writer, files map[string][]byte, sources []archiveSource,
+) error {
+	written := make(map[string]struct{})
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry, err := createZipEntry(writer, name)
+		if err == nil {
+			_, err = entry.Write(files[name])
+		}
+		if err != nil {
+			return WrapErrorf(err, "Failed to write file to archive.\n"+
+				"Path: %s", name)
+		}
+		written[name] = struct{}{}
+	}
+	var skippedDirs []string
+	for _, source := range sources {
+		err := filepath.WalkDir(
+			source.Path, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(source.Path, path)
+				if err != nil {
+					return err
+				}
+				name := filepath.ToSlash(filepath.Join(source.Prefix, relPath))
+				if d.IsDir() {
+					for _, dir := range skippedDirs {
+						if name == dir {
+							return filepath.SkipDir
+						}
+					}
+					return nil
+				}
+				if _, ok := written[name]; ok {
+					return nil
+				}
+				written[name] = struct{}{}
+				entry, err := createZipEntry(writer, name)
+				if err != nil {
+					return err
+				}
+				file, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				_, err = io.Copy(entry, file)
+				return err
+			})
+		if err != nil {
+			return WrapErrorf(err, osWalkError, source.Path)
+		}
+		if source.Prefix != "" {
+			skippedDirs = append(skippedDirs, source.Prefix)
+		}
+	}
+	return nil
+}
+
+// createZipEntry adds a compressed file to the zip writer. In the
+// reproducible mode, the modification time of the file is set to the
+// reproducibleTime. Otherwise it's left empty, like in zip.Writer.Create.
+func createZipEntry(writer *zip.Writer, name string) (io.Writer, error) {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if Reproducible {
+		header.Modified = reproducibleTime()
+	}
+	return writer.CreateHeader(header)
+}
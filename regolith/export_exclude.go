@@ -0,0 +1,120 @@
+package regolith
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExcludeExportedFiles removes the files that match the "exclude" patterns of
+// the export target from the packs in the tmp directory, so they are not
+// exported. The patterns are matched against the paths relative to the tmp
+// directory (for example "RP/textures/blocks/dirt.psd", or
+// "RP_<name>/textures/blocks/dirt.psd" for the additional packs). Patterns
+// without a slash match the names of the files in any directory.
+func ExcludeExportedFiles(exclude []string, dotRegolithPath string) error {
+	if len(exclude) == 0 {
+		return nil
+	}
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	excluded := 0
+	for _, pack := range tmpPackDirs(tmpPath) {
+		packPath := filepath.Join(tmpPath, pack)
+		if _, err := os.Stat(packPath); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(
+			packPath, func(p string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(tmpPath, p)
+				if err != nil {
+					return WrapErrorf(err, osRelError, tmpPath, p)
+				}
+				if p == packPath || !matchesGlobPatterns(relPath, exclude) {
+					return nil
+				}
+				Logger.Debugf("Excluding \"%s\" from the export.", relPath)
+				err = os.RemoveAll(p)
+				if err != nil {
+					return WrapErrorf(err, osRemoveError, p)
+				}
+				excluded++
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+		if err != nil {
+			return WrapErrorf(err, osWalkError, packPath)
+		}
+	}
+	if excluded > 0 {
+		Logger.Infof("Excluded %d paths from the export.", excluded)
+	}
+	return nil
+}
+
+// matchesGlobPatterns returns true if the relative path matches any of the
+// glob patterns, like the "exclude" patterns of the export targets or the
+// "watchIgnore" patterns of the project. Patterns without a slash match the
+// names of the files and directories at any depth.
+func matchesGlobPatterns(relPath string, patterns []string) bool {
+	relPath = filepath.ToSlash(relPath)
+	name := path.Base(relPath)
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+			continue
+		}
+		if matchGlobSegments(
+			strings.Split(strings.Trim(pattern, "/"), "/"),
+			strings.Split(relPath, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobSegments matches the segments of a path against the segments of a
+// glob pattern. The "**" segment matches any number of directories, the
+// other segments are matched with path.Match.
+func matchGlobSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlobSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// validateGlobPattern returns an error if the pattern (see
+// matchesGlobPatterns) is not a valid glob pattern.
+func validateGlobPattern(pattern string) error {
+	if pattern == "" {
+		return WrappedError("The glob pattern is empty.")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return WrapErrorf(
+				err, "Invalid glob pattern.\nPattern: %s", pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileNameChecks are the valid values of the "fileNameCheck" property of the
+// export targets, which decide what happens when the exported packs have
+// names of files that don't work on every platform (see
+// checkExportedFileNames).
+var fileNameChecks = []string{"warn", "error", "off"}
+
+// windowsReservedNames are the names of the devices on Windows, which can't
+// be used as the names of the files, even with an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// checkExportedFileNames checks the names of the files of the packs in the
+// tmp directory before the export. The packs built on Linux can contain
+// files whose paths differ only in the case of the letters, and names with
+// characters that are invalid on Windows and Android. Such packs break
+// silently when they're copied to these systems, so the problems are
+// reported with the mode of the "fileNameCheck" property: "warn" (also used
+// if the mode is empty) prints them, "error" stops the export and "off"
+// skips the check.
+func checkExportedFileNames(mode, dotRegolithPath string) error {
+	if mode == "off" {
+		return nil
+	}
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	var problems []string
+	// The lowercase paths of the checked files, mapped to their paths
+	paths := make(map[string]string)
+	for _, pack := range tmpPackDirs(tmpPath) {
+		packPath := filepath.Join(tmpPath, pack)
+		if _, err := os.Stat(packPath); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(
+			packPath, func(p string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(tmpPath, p)
+				if err != nil {
+					return WrapErrorf(err, osRelError, tmpPath, p)
+				}
+				relPath = filepath.ToSlash(relPath)
+				if p == packPath {
+					return nil
+				}
+				if problem := invalidFileName(d.Name()); problem != "" {
+					problems = append(problems, relPath+": "+problem)
+				}
+				lowerPath := strings.ToLower(relPath)
+				if other, ok := paths[lowerPath]; ok {
+					problems = append(problems, relPath+
+						": differs only in the case of the letters from "+
+						other)
+				} else {
+					paths[lowerPath] = relPath
+				}
+				return nil
+			})
+		if err != nil {
+			return WrapErrorf(err, osWalkError, packPath)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	message := "The names of these files don't work on every platform:\n" +
+		strings.Join(problems, "\n")
+	if mode == "error" {
+		return WrappedError(message + "\nRename the files or set " +
+			"\"fileNameCheck\" of the export target to \"warn\".")
+	}
+	Logger.Warn(message)
+	return nil
+}
+
+// invalidFileName returns the description of the problem with the name of
+// a file on Windows and Android, or an empty string if the name is valid.
+func invalidFileName(name string) string {
+	if i := strings.IndexAny(name, `<>:"\|?*`); i != -1 {
+		return "contains the invalid character " + name[i:i+1]
+	}
+	for _, r := range name {
+		if r < 32 {
+			return "contains a control character"
+		}
+	}
+	if strings.HasSuffix(name, " ") || strings.HasSuffix(name, ".") {
+		return "ends with a space or a dot"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(base)] {
+		return "uses the reserved name " + base
+	}
+	return ""
+}
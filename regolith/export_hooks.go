@@ -0,0 +1,108 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// exportHookEnvironment returns the environment variables of the
+// "preExport" and "postExport" commands of the export target.
+func exportHookEnvironment(
+	exportTarget ExportTarget, name, bpPath, rpPath string,
+) ([]string, error) {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return nil, WrapErrorf(err, osGetwdError)
+	}
+	absBpPath, err := filepath.Abs(bpPath)
+	if err != nil {
+		return nil, WrapErrorf(err, filepathAbsError, bpPath)
+	}
+	absRpPath, err := filepath.Abs(rpPath)
+	if err != nil {
+		return nil, WrapErrorf(err, filepathAbsError, rpPath)
+	}
+	return append(
+		os.Environ(),
+		fmt.Sprintf("ROOT_DIR=%s", projectDir),
+		fmt.Sprintf("REGOLITH_PROJECT_NAME=%s", name),
+		fmt.Sprintf("REGOLITH_EXPORT_TARGET=%s", exportTarget.Target),
+		fmt.Sprintf("REGOLITH_BP_PATH=%s", absBpPath),
+		fmt.Sprintf("REGOLITH_RP_PATH=%s", absRpPath),
+	), nil
+}
+
+// RunExportHooks runs the "preExport" or "postExport" commands (hook) of the
+// export target in the shell used by the shell filters. The commands run in
+// the project's root directory, one after another, and the first command
+// that fails stops the export. The paths to the packs are available in the
+// REGOLITH_BP_PATH and REGOLITH_RP_PATH environment variables.
+func RunExportHooks(
+	hook string, commands []string, exportTarget ExportTarget,
+	name, bpPath, rpPath string,
+) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	shell, arg, err := findShell()
+	if err != nil {
+		return WrapError(err, "Unable to find a valid shell.")
+	}
+	env, err := exportHookEnvironment(exportTarget, name, bpPath, rpPath)
+	if err != nil {
+		return WrapError(
+			err, "Failed to create the environment variables of the commands.")
+	}
+	for _, command := range commands {
+		Logger.Infof("Running %s command: %s", hook, hideSecrets(command))
+		cmd := exec.Command(shell, arg, command)
+		cmd.Env = env
+		out, _ := cmd.StdoutPipe()
+		errOut, _ := cmd.StderrPipe()
+		go LogStd(out, Logger.Infof, hook)
+		go LogStd(errOut, Logger.Errorf, hook)
+		if err := startTrackedSubProcess(cmd, nil); err != nil {
+			return WrapErrorf(err, execCommandError, hideSecrets(command))
+		}
+		err = cmd.Wait()
+		untrackSubProcess(cmd)
+		if err != nil {
+			return WrapErrorf(
+				err, "The %s command failed.\nCommand: %s", hook,
+				hideSecrets(command))
+		}
+	}
+	return nil
+}
+
+// exportHookFromObject parses the "preExport" or "postExport" property of
+// the export target, which is a command or a list of commands.
+func exportHookFromObject(
+	obj map[string]interface{}, property string,
+) ([]string, error) {
+	hookObj, ok := obj[property]
+	if !ok {
+		return nil, nil
+	}
+	if command, ok := hookObj.(string); ok {
+		return []string{command}, nil
+	}
+	commands, ok := hookObj.([]interface{})
+	if !ok {
+		return nil, WrappedErrorf(
+			jsonPropertyTypeError, property, "string or array")
+	}
+	result := make([]string, len(commands))
+	for i, command := range commands {
+		command, ok := command.(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError,
+				fmt.Sprintf("%s->%d", property, i), "string")
+		}
+		result[i] = command
+	}
+	return result, nil
+}
@@ -0,0 +1,275 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exportStatePath is the path to the file with the states of the packs
+// exported by ExportPack, relative to the .regolith directory.
+const exportStatePath = "cache/export-state.json"
+
+// exportedFile is the state of a file exported by ExportPack. The size and
+// the modification time of the exported file are used for detecting the
+// changes made outside of Regolith.
+type exportedFile struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ExportPack exports a pack from the tmp directory (source) to the export
+// path (target). If the target is on the same file system as the source, the
+// target is replaced by moving the source, which is the fastest method.
+// Otherwise, only the files that changed since the previous export are
+// copied, and the files that no longer exist in the source are removed.
+//
+// All of the changes of the target are recorded in the revertible operations
+// (r), so the previous version of the pack can be restored with r.Undo if
+// the export fails.
+//
+// The target must be checked with the safety mechanism (see
+// EditedFiles.CheckDeletionSafety) before calling this function.
+func ExportPack(
+	r *RevertableFsOperations, source, target, dotRegolithPath string,
+	makeReadOnly bool, acl string,
+) error {
+	statePath := filepath.Join(dotRegolithPath, exportStatePath)
+	states := loadExportStates(statePath)
+	if sameFileSystem(source, existingAncestor(target)) {
+		delete(states, target)
+		if info, err := os.Lstat(target); err == nil {
+			if isDirectoryLink(info) {
+				// The link from the export with the "symlink" property
+				err = r.Delete(target)
+			} else {
+				err = r.DeleteDir(target)
+			}
+			if err != nil {
+				return WrapErrorf(
+					err, "Failed to clear the export path %q.\n"+
+						"Are user permissions correct?", target)
+			}
+		}
+		if err := r.onUndoRemove(target); err != nil {
+			return PassError(err)
+		}
+		err := MoveOrCopy(source, target, makeReadOnly, acl)
+		if err != nil {
+			return PassError(err)
+		}
+	} else {
+		files, err := incrementalCopy(
+			r, source, target, states[target], makeReadOnly)
+		if err != nil {
+			// The state of the target is unknown
+			delete(states, target)
+			saveExportStates(statePath, states)
+			return PassError(err)
+		}
+		states[target] = files
+	}
+	err := saveExportStates(statePath, states)
+	if err != nil {
+		Logger.Warnf(
+			"Failed to save the state of the exported files. The next export "+
+				"will copy all of the files.\n%s", PassError(err).Error())
+	}
+	return nil
+}
+
+// incrementalCopy updates the target directory to match the source
+// directory. The files are compared using their hashes and the states of the
+// previously exported files. It returns the states of the exported files.
+// The replaced and removed files are backed up by the revertible operations
+// (r).
+func incrementalCopy(
+	r *RevertableFsOperations, source, target string,
+	previous map[string]exportedFile, makeReadOnly bool,
+) (map[string]exportedFile, error) {
+	current := make(map[string]exportedFile)
+	hash := sha1.New()
+	copied := 0
+	err := r.MkdirAll(target)
+	if err != nil {
+		return nil, WrapErrorf(err, osMkdirError, target)
+	}
+	err = filepath.WalkDir(
+		source, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(source, path)
+			if err != nil {
+				return WrapErrorf(err, osRelError, source, path)
+			}
+			if relPath == "." {
+				return nil
+			}
+			targetPath := filepath.Join(target, relPath)
+			targetInfo, targetErr := os.Lstat(targetPath)
+			if d.IsDir() {
+				if targetErr == nil && !targetInfo.IsDir() {
+					if err := r.Delete(targetPath); err != nil {
+						return PassError(err)
+					}
+				}
+				err = r.MkdirAll(targetPath)
+				if err != nil {
+					return WrapErrorf(err, osMkdirError, targetPath)
+				}
+				return nil
+			}
+			sourceHash, err := getPathHash(path, hash)
+			if err != nil {
+				return PassError(err)
+			}
+			key := filepath.ToSlash(relPath)
+			if state, ok := previous[key]; ok && targetErr == nil &&
+				state.Hash == sourceHash &&
+				state.Size == targetInfo.Size() &&
+				state.ModTime.Equal(targetInfo.ModTime()) {
+				current[key] = state
+				return nil
+			}
+			if targetErr == nil {
+				if err := r.DeleteDir(targetPath); err != nil {
+					return PassError(err)
+				}
+			}
+			if err := r.onUndoRemove(targetPath); err != nil {
+				return PassError(err)
+			}
+			err = CopyFile(path, targetPath)
+			if err != nil {
+				return WrapErrorf(err, osCopyError, path, targetPath)
+			}
+			if makeReadOnly {
+				os.Chmod(targetPath, 0444)
+			}
+			// The state must have the time set by the reproducible mode, or
+			// the file would be copied again by the next export
+			if Reproducible {
+				modTime := reproducibleTime()
+				os.Chtimes(targetPath, modTime, modTime)
+			}
+			targetInfo, err = os.Stat(targetPath)
+			if err != nil {
+				return WrapErrorf(err, osStatErrorAny, targetPath)
+			}
+			current[key] = exportedFile{
+				Hash:    sourceHash,
+				Size:    targetInfo.Size(),
+				ModTime: targetInfo.ModTime(),
+			}
+			copied++
+			return nil
+		})
+	if err != nil {
+		return nil, WrapErrorf(err, osWalkError, source)
+	}
+	// Remove the files that are not a part of the pack anymore
+	removed := 0
+	err = PostorderWalkDir(
+		target, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(target, path)
+			if err != nil {
+				return WrapErrorf(err, osRelError, target, path)
+			}
+			if info.IsDir() {
+				_, err := os.Stat(filepath.Join(source, relPath))
+				if os.IsNotExist(err) {
+					// The directory is already empty
+					return r.removeEmptyDir(path)
+				}
+				return nil
+			}
+			if _, ok := current[filepath.ToSlash(relPath)]; !ok {
+				removed++
+				traceFilef("Removing %s", path)
+				return r.Delete(path)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, WrapErrorf(err, osWalkError, target)
+	}
+	Logger.Infof(
+		"Copied %d changed files and removed %d old files.", copied, removed)
+	return current, nil
+}
+
+// removeExportedPath removes a file or directory from the export path,
+// including the files made read-only by the "readOnly" property.
+func removeExportedPath(path string) error {
+	traceFilef("Removing %s", path)
+	filepath.WalkDir(path, func(s string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			os.Chmod(s, 0644)
+		}
+		return nil
+	})
+	err := os.RemoveAll(path)
+	if err != nil {
+		return WrapErrorf(err, osRemoveError, path)
+	}
+	return nil
+}
+
+// existingAncestor returns the path or its closest ancestor that exists.
+func existingAncestor(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// loadExportStates loads the states of the exported packs. The keys of the
+// map are the export paths, and the values map the paths of the files
+// relative to the export paths to their states.
+func loadExportStates(statePath string) map[string]map[string]exportedFile {
+	states := make(map[string]map[string]exportedFile)
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return states
+	}
+	if json.Unmarshal(data, &states) != nil {
+		return make(map[string]map[string]exportedFile)
+	}
+	return states
+}
+
+// saveExportStates saves the states of the exported packs.
+func saveExportStates(
+	statePath string, states map[string]map[string]exportedFile,
+) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return WrapError(err, "Failed to encode the states of the exports.")
+	}
+	err = os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(statePath))
+	}
+	err = os.WriteFile(statePath, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, statePath)
+	}
+	return nil
+}
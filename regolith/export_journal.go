@@ -0,0 +1,218 @@
+package regolith
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exportJournalPath is the path to the journal of the export in progress,
+// relative to the .regolith directory. The journal exists only during the
+// export, so if it's found before a run, the previous export was stopped
+// before it could finish or revert its changes (see
+// recoverInterruptedExport).
+const exportJournalPath = "cache/export-journal"
+
+// journalEntry is a line of the export journal. The entries are written
+// before the operations they describe, so the operations of the entries
+// might not have happened, and undoing them must be safe in that case.
+type journalEntry struct {
+	// Op is the type of the operation:
+	//   - "start" - the first entry, Path is the backup directory of the
+	//     export (empty for the exports that don't make backups),
+	//   - "delete" - Path is moved to the Backup,
+	//   - "move" - Source is moved to Path,
+	//   - "create" - Path is created,
+	//   - "rmdir" - the empty directory Path is removed.
+	Op     string `json:"op"`
+	Path   string `json:"path,omitempty"`
+	Source string `json:"source,omitempty"`
+	Backup string `json:"backup,omitempty"`
+}
+
+// openExportJournal creates the export journal at the path and writes its
+// "start" entry with the backup path.
+func openExportJournal(path, backupPath string) (*os.File, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, WrapErrorf(err, osMkdirError, filepath.Dir(path))
+	}
+	journal, err := os.Create(path)
+	if err != nil {
+		return nil, WrapErrorf(err, fileWriteError, path)
+	}
+	err = writeJournalEntry(journal, journalEntry{Op: "start", Path: backupPath})
+	if err != nil {
+		journal.Close()
+		os.Remove(path)
+		return nil, WrapErrorf(err, fileWriteError, path)
+	}
+	return journal, nil
+}
+
+// writeJournalEntry appends the entry to the journal. The journal isn't
+// buffered, and the entry is synced to the disk before the function
+// returns, so it's kept even if Regolith is killed or the computer shuts
+// down right after writing it, before the operation it describes.
+func writeJournalEntry(journal *os.File, entry journalEntry) error {
+	for _, path := range []*string{&entry.Path, &entry.Source} {
+		if *path != "" {
+			if fullPath, err := filepath.Abs(*path); err == nil {
+				*path = fullPath
+			}
+		}
+	}
+	data, _ := json.Marshal(entry) // no error
+	_, err := journal.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+	return journal.Sync()
+}
+
+// closeExportJournal closes and removes the journal of a finished export.
+func closeExportJournal(journal *os.File) error {
+	journal.Close()
+	err := os.Remove(journal.Name())
+	if err != nil && !os.IsNotExist(err) {
+		return WrapErrorf(err, osRemoveError, journal.Name())
+	}
+	return nil
+}
+
+// recoverInterruptedExport checks if the previous export was interrupted by
+// Regolith being killed or the computer shutting down, and repairs the
+// export paths and the data path. Without the repair, the export paths
+// would contain a mix of the old and the new files, and the data path would
+// be missing the files that were moved to the backup directory.
+//
+// The operations from the journal are undone in the reverse order, which
+// restores the files from before the export. The next export is a full
+// export, because the hashes and the states of the previous exports no
+// longer match the files. If some of the backups weren't restored (because
+// the last entries of the journal were lost), they're moved to a
+// "recovered-*" directory in the .regolith directory, so they can be
+// restored manually. If the files can't be restored, the journal is kept
+// and the error is returned, so the repair is retried by the next run.
+func recoverInterruptedExport(dotRegolithPath string) error {
+	journalPath := filepath.Join(dotRegolithPath, exportJournalPath)
+	file, err := os.Open(journalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return WrapErrorf(err, fileReadError, journalPath)
+	}
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		// The last line can be incomplete if Regolith was killed while
+		// writing it
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	file.Close()
+	Logger.Warn(
+		"The previous export was interrupted before it could finish. " +
+			"Restoring the files from before the export.")
+	backupPath := ""
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Op == "start" {
+			backupPath = entry.Path
+			continue
+		}
+		traceFilef("Undoing %q of %s", entry.Op, entry.Path)
+		if err := undoJournalEntry(entry); err != nil {
+			return WrapErrorf(
+				err,
+				"Failed to restore the files changed by the interrupted "+
+					"export.\nJournal: %s\nBackups: %s\n"+
+					"Close the programs that use the export paths or the "+
+					"data path and run Regolith again.",
+				journalPath, backupPath)
+		}
+	}
+	if backupPath != "" {
+		empty, err := IsDirEmpty(backupPath)
+		if err == nil && !empty {
+			recoveredPath := filepath.Join(
+				dotRegolithPath,
+				"recovered-"+time.Now().Format("20060102-150405"))
+			err = os.Rename(backupPath, recoveredPath)
+			if err != nil {
+				return WrapErrorf(err, osRenameError, backupPath, recoveredPath)
+			}
+			Logger.Warnf(
+				"Some of the files from before the interrupted export "+
+					"couldn't be restored. Their backups are in %q.",
+				recoveredPath)
+		} else if err := os.RemoveAll(backupPath); err != nil {
+			return WrapErrorf(err, osRemoveError, backupPath)
+		}
+	}
+	if err := forceFullExport(dotRegolithPath); err != nil {
+		return PassError(err)
+	}
+	err = os.Remove(journalPath)
+	if err != nil {
+		return WrapErrorf(err, osRemoveError, journalPath)
+	}
+	Logger.Info("Restored the files from before the interrupted export.")
+	return nil
+}
+
+// forceFullExport removes the hashes and the states of the previous exports,
+// so the next export is a full export.
+func forceFullExport(dotRegolithPath string) error {
+	saveExportHash("", dotRegolithPath)
+	os.Remove(filepath.Join(dotRegolithPath, exportStatePath))
+	if err := ClearCachedStates(); err != nil {
+		return WrapError(err, clearCachedStatesError)
+	}
+	return nil
+}
+
+// undoJournalEntry undoes the operation of the entry of the export journal.
+// The entries of the operations that didn't happen are ignored.
+func undoJournalEntry(entry journalEntry) error {
+	switch entry.Op {
+	case "delete":
+		if _, err := os.Lstat(entry.Backup); err != nil {
+			return nil
+		}
+		// The path wasn't moved completely, the backup is a partial copy
+		if _, err := os.Lstat(entry.Path); err == nil {
+			return nil
+		}
+		err := ForceMoveFile(entry.Backup, entry.Path)
+		if err != nil {
+			return WrapErrorf(err, osRenameError, entry.Backup, entry.Path)
+		}
+	case "move":
+		if _, err := os.Lstat(entry.Path); err != nil {
+			return nil
+		}
+		err := os.MkdirAll(filepath.Dir(entry.Source), 0755)
+		if err != nil {
+			return WrapErrorf(err, osMkdirError, filepath.Dir(entry.Source))
+		}
+		err = os.Rename(entry.Path, entry.Source)
+		if err != nil {
+			return WrapErrorf(err, osRenameError, entry.Path, entry.Source)
+		}
+	case "create":
+		return removeExportedPath(entry.Path)
+	case "rmdir":
+		err := os.MkdirAll(entry.Path, 0755)
+		if err != nil {
+			return WrapErrorf(err, osMkdirError, entry.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// The paths to the stable output folders of the export targets with the
+// "symlink" property. The packs are exported to these folders, and the export
+// paths of the target are links to them.
+const (
+	linkedExportBpPath = "build/BP/"
+	linkedExportRpPath = "build/RP/"
+)
+
+// prepareLinkedExport prepares the export of a target with the "symlink"
+// property. It returns the paths to the stable output folders, that replace
+// the export paths of the target (bpLink and rpLink). The packs exported to
+// the export paths before the "symlink" property was used are removed, if
+// the safety mechanism allows it. The removal is done with the revertible
+// operations (r), unless r is nil.
+func prepareLinkedExport(
+	r *RevertableFsOperations, editedFiles EditedFiles, bpLink, rpLink string,
+) (string, string, error) {
+	packs := []struct {
+		link  string
+		files filesList
+	}{
+		{bpLink, editedFiles.Bp[bpLink]},
+		{rpLink, editedFiles.Rp[rpLink]},
+	}
+	for _, pack := range packs {
+		info, err := os.Lstat(pack.link)
+		if err != nil || isDirectoryLink(info) {
+			continue
+		}
+		// The path is a regular directory with the pack from the previous
+		// exports
+		err = checkDeletionSafety(pack.link, pack.files)
+		if err != nil {
+			return "", "", WrapErrorf(
+				err,
+				"Safety mechanism stopped Regolith from replacing the "+
+					"exported pack with a link.\n"+
+					"Please clear your export path and try again.\n"+
+					"Export path: %s", pack.link)
+		}
+		if r != nil {
+			err = r.DeleteDir(pack.link)
+		} else {
+			err = os.RemoveAll(pack.link)
+		}
+		if err != nil {
+			return "", "", WrapErrorf(
+				err, "Failed to clear the export path.\nPath: %s", pack.link)
+		}
+	}
+	return linkedExportBpPath, linkedExportRpPath, nil
+}
+
+// createExportLinks makes the export paths of a target with the "symlink"
+// property (bpLink and rpLink) links to the stable output folders (bpPath
+// and rpPath). The links that already point to the output folders are not
+// changed.
+func createExportLinks(bpPath, rpPath, bpLink, rpLink string) error {
+	for _, paths := range [][2]string{{bpPath, bpLink}, {rpPath, rpLink}} {
+		target, err := filepath.Abs(paths[0])
+		if err != nil {
+			return WrapErrorf(err, filepathAbsError, paths[0])
+		}
+		link := filepath.Clean(paths[1])
+		if info, err := os.Lstat(link); err == nil {
+			if !isDirectoryLink(info) {
+				return WrappedErrorf(
+					"The export path is not a link.\nPath: %s", link)
+			}
+			current, err := os.Readlink(link)
+			if err == nil && filepath.Clean(current) == target {
+				continue
+			}
+			err = os.Remove(link)
+			if err != nil {
+				return WrapErrorf(
+					err, "Failed to remove the old link.\nPath: %s", link)
+			}
+		}
+		err = os.MkdirAll(filepath.Dir(link), 0755)
+		if err != nil {
+			return WrapErrorf(err, osMkdirError, filepath.Dir(link))
+		}
+		Logger.Infof("Linking \"%s\" to \"%s\".", link, target)
+		err = createDirectoryLink(target, link)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to create a link.\nLink: %s\nTarget: %s",
+				link, target)
+		}
+	}
+	return nil
+}
+
+// isDirectoryLink returns true if the file info describes a symbolic link or
+// a Windows junction (reported as an irregular file by the newer versions of
+// Go).
+func isDirectoryLink(info os.FileInfo) bool {
+	return info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0
+}
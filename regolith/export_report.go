@@ -0,0 +1,140 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// exportReportStatePath is the path to the file with the hashes of the files
+// from the previous export, used for creating the export reports. The path is
+// relative to the .regolith directory.
+const exportReportStatePath = "cache/export-report.json"
+
+// exportReport is a list of the files added, changed and removed by the
+// export, compared to the previous export. The paths start with the name of
+// the directory of the pack in the tmp directory (like "BP" or "RP_<name>").
+type exportReport struct {
+	Added   []string
+	Changed []string
+	Removed []string
+
+	// files maps the paths of the exported files to their hashes
+	files map[string]string
+}
+
+// usesExportReport returns true if the export target should create an
+// export report.
+func usesExportReport(exportTarget ExportTarget) bool {
+	return exportTarget.Report || exportTarget.ReportPath != ""
+}
+
+// prepareExportReport compares the packs in the tmp directory with the
+// packs from the previous export. It must be called before the packs are
+// moved from the tmp directory.
+func prepareExportReport(dotRegolithPath string) (*exportReport, error) {
+	report := &exportReport{files: make(map[string]string)}
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	hash := sha1.New()
+	for _, pack := range tmpPackDirs(tmpPath) {
+		packPath := filepath.Join(tmpPath, pack)
+		if _, err := os.Stat(packPath); os.IsNotExist(err) {
+			continue
+		}
+		state, err := GetStateFromPath(packPath, hash)
+		if err != nil {
+			return nil, WrapErrorf(
+				err, "Failed to get the state of the path.\nPath: %s",
+				packPath)
+		}
+		for e := state.Front(); e != nil; e = e.Next() {
+			pair := e.Value.(PathHashPair)
+			if pair.Hash == "" {
+				continue // Directory
+			}
+			report.files[pack+"/"+filepath.ToSlash(pair.Path)] = pair.Hash
+		}
+	}
+	previous := make(map[string]string)
+	statePath := filepath.Join(dotRegolithPath, exportReportStatePath)
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &previous)
+	}
+	for path, hash := range report.files {
+		if previousHash, ok := previous[path]; !ok {
+			report.Added = append(report.Added, path)
+		} else if previousHash != hash {
+			report.Changed = append(report.Changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := report.files[path]; !ok {
+			report.Removed = append(report.Removed, path)
+		}
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Changed)
+	sort.Strings(report.Removed)
+	return report, nil
+}
+
+// Finish saves the state of the export for the next report and prints the
+// report or writes it to the "reportPath" file of the export target. It must
+// be called after a successful export.
+func (r *exportReport) Finish(
+	exportTarget ExportTarget, dotRegolithPath string,
+) error {
+	statePath := filepath.Join(dotRegolithPath, exportReportStatePath)
+	data, err := json.Marshal(r.files)
+	if err != nil {
+		return WrapError(err, "Failed to encode the state of the export.")
+	}
+	err = os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(statePath))
+	}
+	err = os.WriteFile(statePath, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, statePath)
+	}
+	Logger.Infof(
+		"Export report: %d added, %d changed and %d removed files.",
+		len(r.Added), len(r.Changed), len(r.Removed))
+	lines := r.lines()
+	if exportTarget.ReportPath != "" {
+		path := exportTarget.ReportPath
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return WrapErrorf(err, osMkdirError, filepath.Dir(path))
+		}
+		err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+		if err != nil {
+			return WrapErrorf(err, fileWriteError, path)
+		}
+		Logger.Infof("Saved the export report to \"%s\".", path)
+	} else {
+		for _, line := range lines {
+			Logger.Infof("  %s", line)
+		}
+	}
+	return nil
+}
+
+// lines returns the lines of the report. The added files are marked with
+// "+", the changed files with "~" and the removed files with "-".
+func (r *exportReport) lines() []string {
+	var lines []string
+	for _, path := range r.Added {
+		lines = append(lines, "+ "+path)
+	}
+	for _, path := range r.Changed {
+		lines = append(lines, "~ "+path)
+	}
+	for _, path := range r.Removed {
+		lines = append(lines, "- "+path)
+	}
+	return lines
+}
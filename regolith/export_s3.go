@@ -0,0 +1,94 @@
+package regolith
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// defaultS3ExportArchive is the archive export target used by the "s3"
+// export target when the "archive" property is not set.
+const defaultS3ExportArchive = "mcaddon"
+
+// defaultS3Region is the region used by the "s3" export target when the
+// region is not set in the export target or in the AWS configuration. Most
+// of the S3-compatible services ignore the region.
+const defaultS3Region = "us-east-1"
+
+// UploadPacksS3 packs the packs exported to bpPath and rpPath into archives
+// (see CreatePackArchives) and uploads them to the bucket from the "s3"
+// export target. The type of the archives is selected with the "archive"
+// property. The credentials are loaded from the environment variables or
+// the shared configuration files of AWS.
+func UploadPacksS3(
+	exportTarget ExportTarget, name, bpPath, rpPath string,
+) error {
+	archiveTarget := exportTarget
+	archiveTarget.Target = exportTarget.Archive
+	if archiveTarget.Target == "" {
+		archiveTarget.Target = defaultS3ExportArchive
+	}
+	archives, err := CreatePackArchives(archiveTarget, name, bpPath, rpPath)
+	if err != nil {
+		return WrapError(err, "Failed to create archives of the packs.")
+	}
+	config := aws.Config{}
+	if exportTarget.Region != "" {
+		config.Region = aws.String(exportTarget.Region)
+	}
+	if exportTarget.Endpoint != "" {
+		// The S3-compatible services usually don't support the virtual
+		// hosted-style URLs
+		config.Endpoint = aws.String(exportTarget.Endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return WrapError(err, "Failed to create the S3 session.")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(defaultS3Region)
+	}
+	uploader := s3manager.NewUploader(sess)
+	for _, archive := range archives {
+		key := path.Join(exportTarget.Prefix, filepath.Base(archive))
+		Logger.Infof(
+			"Uploading \"%s\" to \"s3://%s/%s\".",
+			archive, exportTarget.Bucket, key)
+		err = uploadFileS3(uploader, archive, exportTarget.Bucket, key)
+		if err != nil {
+			return PassError(err)
+		}
+	}
+	return nil
+}
+
+// uploadFileS3 uploads a file to the bucket.
+func uploadFileS3(
+	uploader *s3manager.Uploader, filePath, bucket, key string,
+) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return WrapErrorf(err, osOpenError, filePath)
+	}
+	defer file.Close()
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        file,
+		ContentType: aws.String("application/zip"),
+	})
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to upload file to S3.\nPath: %s\nBucket: %s\nKey: %s",
+			filePath, bucket, key)
+	}
+	return nil
+}
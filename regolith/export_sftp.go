@@ -0,0 +1,239 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sftpExportStatePath is the path to the file with the hashes of the files
+// uploaded by the "sftp" export target, relative to the .regolith directory.
+const sftpExportStatePath = "cache/sftp-export.json"
+
+// sftpExportState is the content of the file with the state of the last
+// upload of the "sftp" export target.
+type sftpExportState struct {
+	// Destination identifies the server and the directory of the upload. The
+	// state is ignored if the destination changes.
+	Destination string `json:"destination"`
+	// Files maps the paths relative to the "path" property of the export
+	// target to the hashes of the uploaded files. The directories have empty
+	// hashes.
+	Files map[string]string `json:"files"`
+}
+
+// UploadPacksSftp uploads the packs exported to bpPath and rpPath to the
+// server from the "sftp" export target, using the "sftp" command of OpenSSH.
+// The packs are uploaded to the development pack folders in the "path"
+// directory on the server. Only the files that changed since the last upload
+// are uploaded, and the files that no longer exist are removed from the
+// server.
+//
+// The command runs in batch mode, so the server must accept the key from the
+// "identityFile" property or a key from the SSH agent.
+func UploadPacksSftp(
+	exportTarget ExportTarget, name, bpPath, rpPath, dotRegolithPath string,
+) error {
+	destination := sftpDestination(exportTarget)
+	statePath := filepath.Join(dotRegolithPath, sftpExportStatePath)
+	previous := loadSftpExportState(statePath, destination)
+	current := sftpExportState{
+		Destination: destination,
+		Files:       make(map[string]string),
+	}
+	packs := map[string]string{
+		"development_behavior_packs/" + name + "_bp": bpPath,
+		"development_resource_packs/" + name + "_rp": rpPath,
+	}
+	for remotePath, localPath := range packs {
+		if _, err := os.Stat(localPath); os.IsNotExist(err) {
+			continue
+		}
+		current.Files[path.Dir(remotePath)] = ""
+		current.Files[remotePath] = ""
+		state, err := GetStateFromPath(localPath, sha1.New())
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to get the state of the path.\nPath: %s",
+				localPath)
+		}
+		for e := state.Front(); e != nil; e = e.Next() {
+			pair := e.Value.(PathHashPair)
+			current.Files[path.Join(remotePath, filepath.ToSlash(pair.Path))] =
+				pair.Hash
+		}
+	}
+	commands := sftpExportCommands(
+		exportTarget.Path, previous.Files, current.Files, packs)
+	if len(commands) == 0 {
+		Logger.Info("The packs on the server are up to date.")
+		return nil
+	}
+	Logger.Infof(
+		"Uploading the packs to \"%s\" (%d operations).",
+		destination, len(commands))
+	err := runSftpBatch(exportTarget, commands)
+	if err != nil {
+		// The state of the files on the server is unknown, so the next
+		// upload uploads all of the files
+		os.Remove(statePath)
+		return PassError(err)
+	}
+	return saveSftpExportState(statePath, current)
+}
+
+// sftpDestination returns a string that identifies the server and the
+// directory of the "sftp" export target.
+func sftpDestination(exportTarget ExportTarget) string {
+	host := exportTarget.Host
+	if exportTarget.User != "" {
+		host = exportTarget.User + "@" + host
+	}
+	if exportTarget.Port != 0 {
+		host += ":" + strconv.Itoa(exportTarget.Port)
+	}
+	return host + ":" + exportTarget.Path
+}
+
+// sftpExportCommands returns the commands of the "sftp" batch file, that
+// update the files on the server from the previous state to the current
+// state. The local paths of the files are found using the packs map (remote
+// pack directory to local pack directory).
+func sftpExportCommands(
+	root string, previous, current map[string]string,
+	packs map[string]string,
+) []string {
+	remote := func(p string) string {
+		return sftpQuote(path.Join(root, p))
+	}
+	var paths []string
+	for p := range current {
+		paths = append(paths, p)
+	}
+	// Sorting makes sure that the parent directories are created first
+	sort.Strings(paths)
+	var commands []string
+	for _, p := range paths {
+		hash := current[p]
+		previousHash, existed := previous[p]
+		if hash == "" {
+			if !existed {
+				// The directory may already exist on the server
+				commands = append(commands, "-mkdir "+remote(p))
+			}
+			continue
+		}
+		if existed && previousHash == hash {
+			continue
+		}
+		local := ""
+		for remotePack, localPack := range packs {
+			if strings.HasPrefix(p, remotePack+"/") {
+				local = filepath.Join(
+					localPack, filepath.FromSlash(p[len(remotePack)+1:]))
+			}
+		}
+		commands = append(
+			commands, "put "+sftpQuote(local)+" "+remote(p))
+	}
+	// Removing in reverse order removes the files before their directories
+	var removed []string
+	for p := range previous {
+		if _, ok := current[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	for _, p := range removed {
+		if previous[p] == "" {
+			commands = append(commands, "-rmdir "+remote(p))
+		} else {
+			commands = append(commands, "-rm "+remote(p))
+		}
+	}
+	return commands
+}
+
+// sftpQuote quotes a path for the "sftp" batch file.
+func sftpQuote(p string) string {
+	p = filepath.ToSlash(p)
+	p = strings.ReplaceAll(p, `\`, `\\`)
+	p = strings.ReplaceAll(p, `"`, `\"`)
+	return `"` + p + `"`
+}
+
+// runSftpBatch runs the "sftp" command with the commands in a batch file.
+func runSftpBatch(exportTarget ExportTarget, commands []string) error {
+	batchFile, err := os.CreateTemp("", "regolith-sftp-*.txt")
+	if err != nil {
+		return WrapError(err, "Failed to create the sftp batch file.")
+	}
+	defer os.Remove(batchFile.Name())
+	_, err = batchFile.WriteString(strings.Join(commands, "\n") + "\n")
+	if err1 := batchFile.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, batchFile.Name())
+	}
+	args := []string{"-b", batchFile.Name()}
+	if exportTarget.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(exportTarget.Port))
+	}
+	if exportTarget.IdentityFile != "" {
+		args = append(args, "-i", exportTarget.IdentityFile)
+	}
+	host := exportTarget.Host
+	if exportTarget.User != "" {
+		host = exportTarget.User + "@" + host
+	}
+	args = append(args, host)
+	Logger.Debugf(
+		"Running sftp with arguments: %s",
+		hideSecrets(strings.Join(args, " ")))
+	output, err := exec.Command("sftp", args...).CombinedOutput()
+	if err != nil {
+		return WrapErrorf(
+			err, execCommandError+"\nOutput:\n%s", "sftp",
+			strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// loadSftpExportState loads the state of the last upload to the destination.
+// It returns an empty state if the file doesn't exist or if the last upload
+// was to a different destination.
+func loadSftpExportState(statePath, destination string) sftpExportState {
+	var state sftpExportState
+	data, err := os.ReadFile(statePath)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil || state.Destination != destination {
+		return sftpExportState{Files: make(map[string]string)}
+	}
+	return state
+}
+
+// saveSftpExportState saves the state of the last upload.
+func saveSftpExportState(statePath string, state sftpExportState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return WrapError(err, "Failed to encode the state of the upload.")
+	}
+	err = os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(statePath))
+	}
+	err = os.WriteFile(statePath, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, statePath)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportHashPath is the path to the file with the hash of the previous
+// export (see exportHash), relative to the .regolith directory.
+const exportHashPath = "cache/export-hash"
+
+// exportHash returns a hash of the packs in the tmp directory and of the
+// settings of the export. If the hash didn't change since the previous
+// export, the packs don't have to be exported again.
+func exportHash(
+	exportTarget ExportTarget, name, bpPath, rpPath, dotRegolithPath string,
+	packs []additionalPack,
+) (string, error) {
+	hash := sha256.New()
+	targetJson, err := json.Marshal(exportTarget)
+	if err != nil {
+		return "", WrapError(err, "Failed to encode the export target as JSON.")
+	}
+	hash.Write(targetJson)
+	io.WriteString(hash, strings.Join([]string{name, bpPath, rpPath}, "\n"))
+	workingDir := filepath.Join(dotRegolithPath, "tmp")
+	fileHash := sha1.New()
+	for _, dir := range tmpPackDirs(workingDir) {
+		io.WriteString(hash, "\n"+dir+"\n")
+		_, err := writeStateToHash(
+			hash, filepath.Join(workingDir, dir), fileHash)
+		if err != nil {
+			return "", PassError(err)
+		}
+	}
+	for _, pack := range packs {
+		io.WriteString(hash, "\n"+pack.ExportPath(bpPath, rpPath))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isExportUnchanged returns true if the hash is the same as the hash of the
+// previous export and the export paths still exist.
+func isExportUnchanged(hash, dotRegolithPath string, paths []string) bool {
+	previous, err := os.ReadFile(filepath.Join(dotRegolithPath, exportHashPath))
+	if err != nil || string(previous) != hash {
+		return false
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// saveExportHash saves the hash of a successful export. The hash is removed
+// with an empty string, before the export starts, so an export that fails
+// is never skipped.
+func saveExportHash(hash, dotRegolithPath string) {
+	path := filepath.Join(dotRegolithPath, exportHashPath)
+	if hash == "" {
+		os.Remove(path)
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(hash), 0644)
+	}
+	if err != nil {
+		Logger.Warnf(
+			"Failed to save the hash of the exported packs. The next export "+
+				"can't be skipped.\n%s",
+			WrapErrorf(err, fileWriteError, path).Error())
+	}
+}
+
+// exportDataOnly moves the data from the tmp directory back to the data path,
+// without exporting the packs. It's used when the packs didn't change since
+// the previous export.
+func exportDataOnly(dataPath, dotRegolithPath string) error {
+	backupPath := filepath.Join(dotRegolithPath, ".dataBackup")
+	revertibleOps, err := NewRevertableFsOperaitons(backupPath)
+	if err != nil {
+		return WrapErrorf(err, "Failed to prepare backup path for revertable"+
+			" file system operations.\n"+
+			"Path that Regolith tried to use: %s", backupPath)
+	}
+	err = revertibleOps.startJournal(
+		filepath.Join(dotRegolithPath, exportJournalPath))
+	if err != nil {
+		revertibleOps.Close()
+		return PassError(err)
+	}
+	err = clearDataPath(revertibleOps, dataPath)
+	if err == nil {
+		err = revertibleOps.MoveoOrCopyDir(
+			filepath.Join(dotRegolithPath, "tmp/data"), dataPath)
+	}
+	if err != nil {
+		revertExport(revertibleOps)
+		return WrapError(
+			err, "Failed to move the filter data back to the project's "+
+				"data folder.")
+	}
+	if err := revertibleOps.Close(); err != nil {
+		return PassError(err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package regolith
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// unicodeNormalizationModes are the valid values of the
+// "unicodeNormalization" property of the export targets (see
+// checkUnicodeNormalization).
+var unicodeNormalizationModes = []string{"warn", "nfc", "off"}
+
+// jsonStringPattern matches the strings in the JSON files, including the
+// escaped quotes.
+var jsonStringPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+// checkUnicodeNormalization checks the Unicode normalization of the names of
+// the files of the packs in the tmp directory, before the export. The same
+// name with accented letters can be written with precomposed characters
+// (NFC, used by Windows, Linux and most of the editors) or with the base
+// letters followed by the combining accents (NFD, used by the files from
+// macOS and the archives created there). Minecraft compares the paths byte
+// by byte, so a texture whose name uses a different form than the reference
+// to it in a JSON file works on macOS but is missing on the other platforms.
+//
+// The problems are handled with the mode of the "unicodeNormalization"
+// property: "warn" (also used if the mode is empty) prints the names of the
+// files that aren't in NFC, the references that match a file only after the
+// normalization, and the files whose names differ only in the normalization.
+// "nfc" renames the files to NFC and normalizes the content of the JSON files
+// with such references, and "off" skips the check.
+func checkUnicodeNormalization(mode, dotRegolithPath string) error {
+	if mode == "off" {
+		return nil
+	}
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	var problems []string
+	fixed := 0
+	for _, pack := range tmpPackDirs(tmpPath) {
+		packPath := filepath.Join(tmpPath, pack)
+		if _, err := os.Stat(packPath); os.IsNotExist(err) {
+			continue
+		}
+		packProblems, packFixed, err := checkPackUnicodeNormalization(
+			mode, packPath, pack)
+		if err != nil {
+			return PassError(err)
+		}
+		problems = append(problems, packProblems...)
+		fixed += packFixed
+	}
+	if fixed > 0 {
+		Logger.Infof(
+			"Normalized %d file names and JSON files to the NFC form of "+
+				"Unicode.", fixed)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	message := "These files may be missing on some platforms because of " +
+		"the different forms of the Unicode characters in their names:\n" +
+		strings.Join(problems, "\n")
+	if mode != "nfc" {
+		message += "\nSet \"unicodeNormalization\" of the export target to " +
+			"\"nfc\" to normalize the names."
+	}
+	Logger.Warn(message)
+	return nil
+}
+
+// checkPackUnicodeNormalization checks the pack in the packPath for
+// checkUnicodeNormalization. The pack is the name of its directory in the tmp
+// directory, used in the paths of the problems. It returns the problems and
+// the number of the fixed files.
+func checkPackUnicodeNormalization(
+	mode, packPath, pack string,
+) ([]string, int, error) {
+	var problems []string
+	// The NFC paths of the files relative to the pack, mapped to their
+	// paths. The paths of the files are also added without the extensions,
+	// because the JSON files of the packs reference the textures and the
+	// sounds without them.
+	references := make(map[string]string)
+	// The NFC paths of the files and the directories, for finding the
+	// collisions
+	paths := make(map[string]string)
+	// The paths that aren't in NFC, in the order of the walk
+	var renames []string
+	var jsonFiles []string
+	err := filepath.WalkDir(
+		packPath, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == packPath {
+				return nil
+			}
+			relPath, err := filepath.Rel(packPath, p)
+			if err != nil {
+				return WrapErrorf(err, osRelError, packPath, p)
+			}
+			relPath = filepath.ToSlash(relPath)
+			nfcPath := norm.NFC.String(relPath)
+			if other, ok := paths[nfcPath]; ok {
+				problems = append(problems, pack+"/"+relPath+
+					": differs only in the Unicode normalization from "+
+					pack+"/"+other)
+				return nil
+			}
+			paths[nfcPath] = relPath
+			if !norm.NFC.IsNormalString(d.Name()) {
+				renames = append(renames, relPath)
+			}
+			if d.IsDir() {
+				return nil
+			}
+			references[nfcPath] = relPath
+			ext := path.Ext(nfcPath)
+			references[strings.TrimSuffix(nfcPath, ext)] =
+				strings.TrimSuffix(relPath, ext)
+			if strings.EqualFold(ext, ".json") {
+				jsonFiles = append(jsonFiles, relPath)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, 0, WrapErrorf(err, osWalkError, packPath)
+	}
+	fixed := 0
+	for _, jsonFile := range jsonFiles {
+		filePath := filepath.Join(packPath, filepath.FromSlash(jsonFile))
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, 0, WrapErrorf(err, fileReadError, filePath)
+		}
+		mismatches := unicodeReferenceMismatches(data, references)
+		if len(mismatches) == 0 {
+			continue
+		}
+		if mode == "nfc" {
+			// The file can be a hardlink to the source file
+			err = writeTmpFile(filePath, norm.NFC.Bytes(data))
+			if err != nil {
+				return nil, 0, PassError(err)
+			}
+			fixed++
+			continue
+		}
+		for _, mismatch := range mismatches {
+			problems = append(problems, pack+"/"+jsonFile+
+				": the reference \""+mismatch+"\" matches the file "+
+				pack+"/"+references[norm.NFC.String(mismatch)]+
+				" only after the Unicode normalization")
+		}
+	}
+	if mode != "nfc" {
+		for _, relPath := range renames {
+			problems = append(problems,
+				pack+"/"+relPath+": the name isn't in the NFC form")
+		}
+		return problems, fixed, nil
+	}
+	// The walk visits the directories before their content, so the files
+	// are renamed in the reverse order, before their directories
+	for i := len(renames) - 1; i >= 0; i-- {
+		oldPath := filepath.Join(packPath, filepath.FromSlash(renames[i]))
+		newPath := filepath.Join(
+			filepath.Dir(oldPath), norm.NFC.String(filepath.Base(oldPath)))
+		err := os.Rename(oldPath, newPath)
+		if err != nil {
+			return nil, 0, WrapErrorf(err, osRenameError, oldPath, newPath)
+		}
+		fixed++
+	}
+	return problems, fixed, nil
+}
+
+// unicodeReferenceMismatches returns the strings from the JSON data that
+// match the paths of the references only after the Unicode normalization.
+// The strings with only the ASCII characters are skipped, because they're
+// the same in every normalization form.
+func unicodeReferenceMismatches(
+	data []byte, references map[string]string,
+) []string {
+	if isAscii(data) {
+		return nil
+	}
+	var mismatches []string
+	for _, match := range jsonStringPattern.FindAllSubmatch(data, -1) {
+		value := match[1]
+		if isAscii(value) {
+			continue
+		}
+		reference, ok := references[norm.NFC.String(string(value))]
+		if ok && reference != string(value) {
+			mismatches = append(mismatches, string(value))
+		}
+	}
+	return mismatches
+}
+
+// isAscii returns true if the data contains only the ASCII characters.
+func isAscii(data []byte) bool {
+	for _, b := range data {
+		if b >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
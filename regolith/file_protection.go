@@ -94,6 +94,40 @@ func (f *EditedFiles) UpdateFromPaths(rpPath string, bpPath string) error {
 	return nil
 }
 
+// checkPackDeletionSafety checks whether it's safe to delete the files from
+// the export path of a single pack. The kind of the pack is "BP" or "RP".
+func (f *EditedFiles) checkPackDeletionSafety(kind, path string) error {
+	files, ok := f.packFiles(kind)[path]
+	if !ok {
+		files = make([]string, 0)
+	}
+	err := checkDeletionSafety(path, files)
+	if err != nil {
+		return WrapErrorf(err, "Deletion safety check failed.\nPath: %s", path)
+	}
+	return nil
+}
+
+// updateFromPackPath updates the edited files data based on the export path
+// of a single pack. The kind of the pack is "BP" or "RP".
+func (f *EditedFiles) updateFromPackPath(kind, path string) error {
+	files, err := listFiles(path)
+	if err != nil {
+		return WrapErrorf(err, "Failed to list pack files.\nPath: %s", path)
+	}
+	f.packFiles(kind)[path] = files
+	return nil
+}
+
+// packFiles returns the lists of the exported files of the packs of the kind
+// ("BP" or "RP").
+func (f *EditedFiles) packFiles(kind string) map[string]filesList {
+	if kind == "RP" {
+		return f.Rp
+	}
+	return f.Bp
+}
+
 // NewEditedFiles creates new EditedFiles object with lists of the files from
 // rpPath and bpPath.
 func NewEditedFiles() EditedFiles {
@@ -10,8 +10,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/otiai10/copy"
 )
 
 const copyFileBufferSize = 1_000_000 // 1 MB
@@ -28,6 +26,10 @@ type RevertableFsOperations struct {
 
 	// The counter used for naming the backup files
 	backupFileCounter int
+
+	// journal is the export journal (see startJournal), nil if the
+	// operations aren't journaled
+	journal *os.File
 }
 
 // NewRevertableFsOperaitons creates a new FsOperationBatch struct.
@@ -53,6 +55,15 @@ func NewRevertableFsOperaitons(backupPath string) (*RevertableFsOperations, erro
 // Close deletes temporary files of FsOperationBatch. At this point the
 // FsOperationBatch should not be used anymore.
 func (r *RevertableFsOperations) Close() error {
+	// The journal is removed first, because it can't be undone without
+	// the backups
+	if r.journal != nil {
+		err := closeExportJournal(r.journal)
+		if err != nil {
+			return PassError(err)
+		}
+		r.journal = nil
+	}
 	// Clean the backup directory
 	err := os.RemoveAll(r.backupPath)
 	if err != nil {
@@ -88,6 +99,62 @@ func (r *RevertableFsOperations) Undo() error {
 	return nil
 }
 
+// OnUndo adds a custom operation to the undo stack. It's used for reverting
+// the operations that are not performed by the RevertableFsOperations.
+func (r *RevertableFsOperations) OnUndo(undo func() error) {
+	r.undoOperations = append(r.undoOperations, undo)
+}
+
+// startJournal writes the operations to the export journal at the path, so
+// they can be undone even if Regolith is killed before it can undo them
+// (see recoverInterruptedExport). The journal is removed by Close.
+func (r *RevertableFsOperations) startJournal(path string) error {
+	journal, err := openExportJournal(path, r.backupPath)
+	if err != nil {
+		return WrapError(err, "Failed to create the journal of the export.")
+	}
+	r.journal = journal
+	return nil
+}
+
+// record writes the entry of the operation to the journal, if the
+// operations are journaled. It must be called before the operation.
+func (r *RevertableFsOperations) record(entry journalEntry) error {
+	if r.journal == nil {
+		return nil
+	}
+	err := writeJournalEntry(r.journal, entry)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, r.journal.Name())
+	}
+	return nil
+}
+
+// onUndoRemove adds the removal of the path to the undo stack. It's used
+// for the paths created by the operations that are not performed by the
+// RevertableFsOperations, and must be called before creating the path.
+func (r *RevertableFsOperations) onUndoRemove(path string) error {
+	err := r.record(journalEntry{Op: "create", Path: path})
+	if err != nil {
+		return PassError(err)
+	}
+	r.OnUndo(func() error { return removeExportedPath(path) })
+	return nil
+}
+
+// removeEmptyDir removes the directory if it's empty. The undo operation
+// creates the directory again.
+func (r *RevertableFsOperations) removeEmptyDir(path string) error {
+	err := r.record(journalEntry{Op: "rmdir", Path: path})
+	if err != nil {
+		return PassError(err)
+	}
+	if os.Remove(path) == nil {
+		r.OnUndo(func() error { return os.MkdirAll(path, 0755) })
+	}
+	return nil
+}
+
 // Delete removes a file or directory.
 // For deleting entire directories, check out the DeleteDir.
 func (r *RevertableFsOperations) Delete(path string) error {
@@ -98,7 +165,11 @@ func (r *RevertableFsOperations) Delete(path string) error {
 		return WrapErrorf(err, osStatErrorAny, path)
 	}
 	tmpPath := r.getTempFilePath(path)
-	err := ForceMoveFile(path, tmpPath)
+	err := r.record(journalEntry{Op: "delete", Path: path, Backup: tmpPath})
+	if err != nil {
+		return PassError(err)
+	}
+	err = ForceMoveFile(path, tmpPath)
 	if err != nil {
 		return WrapErrorf(
 			err,
@@ -235,6 +306,10 @@ func (r *RevertableFsOperations) MkdirAll(path string) error {
 	}
 
 	if found {
+		err = r.record(journalEntry{Op: "create", Path: undoPath})
+		if err != nil {
+			return PassError(err)
+		}
 		err = os.MkdirAll(fullPath, 0755)
 		if err != nil {
 			return PassError(err)
@@ -347,6 +422,10 @@ func (r *RevertableFsOperations) move(source, target string) error {
 		return WrapErrorf(
 			err, osMkdirError, target)
 	}
+	err = r.record(journalEntry{Op: "move", Path: target, Source: source})
+	if err != nil {
+		return PassError(err)
+	}
 	err = os.Rename(source, target)
 	if err != nil {
 		return WrapErrorf(
@@ -360,7 +439,11 @@ func (r *RevertableFsOperations) move(source, target string) error {
 
 // copy handles the Copy method
 func (r *RevertableFsOperations) copy(source, target string) error {
-	err := CopyFile(source, target)
+	err := r.record(journalEntry{Op: "create", Path: target})
+	if err != nil {
+		return PassError(err)
+	}
+	err = CopyFile(source, target)
 	if err != nil {
 		// PasseError copy function shouldn't say that copy failed, the
 		// error messages like that are handled outside of the function
@@ -377,6 +460,7 @@ func (r *RevertableFsOperations) copy(source, target string) error {
 // applied (before calling Close()).
 func (r *RevertableFsOperations) getTempFilePath(base string) string {
 	_, file := filepath.Split(base)
+	r.backupFileCounter++
 	return filepath.Join(
 		r.backupPath, strconv.Itoa(r.backupFileCounter)+"_"+file)
 }
@@ -528,15 +612,25 @@ func AreFilesEqual(a, b string) (bool, error) {
 }
 
 // CopyFile copies a file from source to target. If it's necessary it creates
-// the target directory.
+// the target directory. On the file systems with the copy-on-write support
+// (APFS, ReFS, Btrfs, XFS) the file is cloned instead (see cloneFile).
 func CopyFile(source, target string) error {
+	traceFilef("Copying %s to %s", source, target)
 	// Make parent directory of target
 	err := os.MkdirAll(filepath.Dir(target), 0755)
 	if err != nil {
 		return WrapErrorf(
 			err, osMkdirError, target)
 	}
-	buf := make([]byte, copyFileBufferSize)
+	// The copy-on-write clone is almost instant, but only some of the file
+	// systems support it
+	if cloneFile(source, target) == nil {
+		countCopiedFile(target)
+		return nil
+	}
+	bufPtr := copyFileBuffers.Get().(*[]byte)
+	defer copyFileBuffers.Put(bufPtr)
+	buf := *bufPtr
 	// Open source for reading
 	sourceF, err := os.Open(source)
 	if err != nil {
@@ -552,6 +646,7 @@ func CopyFile(source, target string) error {
 	}
 	defer targetF.Close()
 	// Copy the file
+	var copied int64
 	for {
 		n, err := sourceF.Read(buf)
 		if err != nil && err != io.EOF {
@@ -564,8 +659,10 @@ func CopyFile(source, target string) error {
 		if _, err := targetF.Write(buf[:n]); err != nil {
 			return WrapErrorf(err, fileWriteError, target)
 		}
+		copied += int64(n)
 	}
 	targetF.Sync()
+	countFileIO(1, copied)
 	return nil
 }
 
@@ -677,6 +774,7 @@ func move(source, destination string) error {
 		for _, file := range files {
 			src := filepath.Join(source, file.Name())
 			dst := filepath.Join(destination, file.Name())
+			traceFilef("Moving %s to %s", src, dst)
 			errMoving = os.Rename(src, dst)
 			if errMoving != nil {
 				errMoving = WrapErrorf(
@@ -729,6 +827,7 @@ func move(source, destination string) error {
 	}
 	// Either source or destination is not a directory,
 	// use normal os.Rename
+	traceFilef("Moving %s to %s", source, destination)
 	err := os.Rename(source, destination)
 	if err != nil {
 		return WrapErrorf(err, osRenameError, source, destination)
@@ -737,35 +836,27 @@ func move(source, destination string) error {
 }
 
 // MoveOrCopy tries to move the the source to destination first and in case
-// of failore it copies the files instead.
+// of failore it copies the files instead. The permissions of the destination
+// are set with the acl mode (see applyExportAcl). If they can't be set, for
+// example on a network share, only a warning is printed.
 func MoveOrCopy(
-	source string, destination string, makeReadOnly bool, copyParentAcl bool,
+	source string, destination string, makeReadOnly bool, acl string,
 ) error {
 	if err := move(source, destination); err != nil {
 		Logger.Warnf(
 			"Failed to move files.\n\tSource: %s\n\tTarget: %s\n"+
 				"This error is not critical. Trying to copy files instead...",
 			filepath.Clean(source), filepath.Clean(destination))
-		copyOptions := copy.Options{PreserveTimes: false, Sync: false}
-		err := copy.Copy(source, destination, copyOptions)
+		err := copyDir(source, destination, false, symlinkCopyAsLink)
 		if err != nil {
 			return WrapErrorf(err, osCopyError, source, destination)
 		}
-	} else if copyParentAcl { // No errors with moving files but needs ACL copy
-		// TODO - this entire code block should be moved into the. copyFileSecurityInfo
-		// printing this Info message below on Linux makes no sense.
-		parent := filepath.Dir(destination)
-		Logger.Infof(
-			"Copying ACL from parent directory.\n\tSource: %s\n\tTarget: %s",
-			parent, destination)
-		if _, err := os.Stat(parent); os.IsNotExist(err) {
-			return WrapErrorf(err, osStatErrorIsNotExist, parent)
-		}
-		err = copyFileSecurityInfo(parent, destination)
-		if err != nil {
-			return WrapErrorf(
-				err, copyFileSecurityInfoError, source, destination)
-		}
+	}
+	if err := applyExportAcl(acl, source, destination); err != nil {
+		Logger.Warnf(
+			"Failed to set the permissions of the exported files. Use the "+
+				"\"acl\" property of the export target to change how "+
+				"they're set.\n%s", PassError(err).Error())
 	}
 	// Make files read only if this option is selected
 	if makeReadOnly {
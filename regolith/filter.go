@@ -1,15 +1,47 @@
 package regolith
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type FilterDefinition struct {
 	Id string `json:"-"`
+	// Protocol is the protocol used for communication with the filter
+	// process. Empty string means that the settings and arguments are passed
+	// as command line arguments. See filter_protocol.go for other options.
+	Protocol string `json:"protocol,omitempty"`
 }
 
 type Filter struct {
 	Id          string                 `json:"filter,omitempty"`
 	Description string                 `json:"name,omitempty"`
 	Disabled    bool                   `json:"disabled,omitempty"`
+	When        string                 `json:"when,omitempty"`
 	Arguments   []string               `json:"arguments,omitempty"`
 	Settings    map[string]interface{} `json:"settings,omitempty"`
+
+	// Timeout is the maximal time of running the filter in seconds. Zero
+	// means that there is no limit.
+	Timeout float64 `json:"timeout,omitempty"`
+	// Retries is the number of times the filter is rerun if it fails.
+	Retries int `json:"retries,omitempty"`
+	// Needs is a list of the ids of the filters from the same profile that
+	// must finish before this filter runs. Profiles that use it run their
+	// filters in parallel.
+	Needs []string `json:"needs,omitempty"`
+	// Cache enables caching the output of the filter. See filter_cache.go.
+	Cache bool `json:"cache,omitempty"`
+	// Inputs and Outputs are the glob patterns of the files that the filter
+	// reads and writes, relative to the working directory. They're used to
+	// order the filters that run in parallel. See filter_graph.go.
+	Inputs  []string `json:"inputs,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+	// AllowedPaths are the glob patterns of the files outside of the tmp
+	// directory that the filter may change, relative to the project. See
+	// filter_sandbox.go.
+	AllowedPaths []string `json:"allowedPaths,omitempty"`
 }
 
 type RunContext struct {
@@ -18,6 +50,15 @@ type RunContext struct {
 	Profile          string
 	Parent           *RunContext
 	DotRegolithPath  string
+	// Defines are the custom values passed with the "--define" flag, used
+	// by the "when" expressions of the filters.
+	Defines map[string]string
+	// Filters are the IDs of the filters passed with the "--filter" flag.
+	// If they aren't empty, only these filters of the profile run.
+	Filters []string
+	// SkipExport makes the run leave the results in the tmp directory
+	// instead of exporting them ("--no-export" flag).
+	SkipExport bool
 
 	// interruptionChannel is a channel that is used to notify about changes
 	// in the sourec files, in order to trigger a restart of the program in
@@ -25,6 +66,23 @@ type RunContext struct {
 	// of the change ("rp", "bp" or "data"), which may be used to handle
 	// some interuptions differently.
 	interruptionChannel chan string
+
+	// changedFiles collects the changes of the source files in the watch
+	// mode (see changedFiles).
+	changedFiles *changedFilesTracker
+
+	// resume stores the checkpoints used for restarting the watch mode
+	// from the first filter affected by the changes (see filterResumeState).
+	resume *filterResumeState
+
+	// done is closed when the run is cancelled by the program that embeds
+	// Regolith (see RunEmbedded).
+	done <-chan struct{}
+
+	// processOwner is the execution of the filter with a timeout that runs
+	// in this context, which owns the sub-processes started in it (see
+	// subProcessOwner). It's nil outside of such filters.
+	processOwner *subProcessOwner
 }
 
 // GetProfile returns the Profile structure from the context.
@@ -37,10 +95,10 @@ func (c *RunContext) GetProfile() (Profile, error) {
 	return profile, nil
 }
 
-// IsWatchMode returns a value that shows whether the context is in the
+// IsInWatchMode returns a value that shows whether the context is in the
 // watch mode.
 func (c *RunContext) IsInWatchMode() bool {
-	return c.interruptionChannel == nil
+	return c.interruptionChannel != nil
 }
 
 // StartWatchingSourceFiles causes the Context to start goroutines that watch
@@ -53,25 +111,46 @@ func (c *RunContext) StartWatchingSrouceFiles() error {
 	if c.interruptionChannel != nil {
 		return WrappedError("Files are already being watched.")
 	}
-	rpWatcher, err := NewDirWatcher(c.Config.ResourceFolder)
+	rpWatcher, err := NewDirWatcher(c.Config.ResourceFolder, c.Config.WatchIgnore)
 	if err != nil {
 		return WrapError(err, "Could not create resource pack watcher.")
 	}
-	bpWatcher, err := NewDirWatcher(c.Config.BehaviorFolder)
+	bpWatcher, err := NewDirWatcher(c.Config.BehaviorFolder, c.Config.WatchIgnore)
 	if err != nil {
 		return WrapError(err, "Could not create behavior pack watcher.")
 	}
-	dataWatcher, err := NewDirWatcher(c.Config.DataPath)
+	dataWatcher, err := NewDirWatcher(c.Config.DataPath, c.Config.WatchIgnore)
 	if err != nil {
 		return WrapError(err, "Could not create data watcher.")
 	}
+	packWatchers := make(map[string]*DirWatcher)
+	for _, pack := range listAdditionalPacks(c.Config.AdditionalPacks) {
+		packWatcher, err := NewDirWatcher(pack.Source, c.Config.WatchIgnore)
+		if err != nil {
+			return WrapErrorf(
+				err, "Could not create the watcher of the additional pack.\n"+
+					"Path: %s", pack.Source)
+		}
+		packWatchers[strings.ToLower(pack.TmpDir)] = packWatcher
+	}
+	c.changedFiles = newChangedFilesTracker()
+	c.resume = newFilterResumeState(c.DotRegolithPath)
+	c.changedFiles.watch(rpWatcher, c.Config.ResourceFolder, "RP")
+	c.changedFiles.watch(bpWatcher, c.Config.BehaviorFolder, "BP")
+	c.changedFiles.watch(dataWatcher, c.Config.DataPath, "data")
+	for _, pack := range listAdditionalPacks(c.Config.AdditionalPacks) {
+		c.changedFiles.watch(
+			packWatchers[strings.ToLower(pack.TmpDir)], pack.Source,
+			pack.TmpDir)
+	}
 	c.interruptionChannel = make(chan string)
 	yieldChanges := func(
 		watcher *DirWatcher, sourceName string,
 	) {
 		for {
 			err := watcher.WaitForChangeGroup(
-				100, c.interruptionChannel, sourceName)
+				uint32(c.Config.WatchDelay), c.interruptionChannel,
+				sourceName)
 			if err != nil {
 				return
 			}
@@ -80,6 +159,9 @@ func (c *RunContext) StartWatchingSrouceFiles() error {
 	go yieldChanges(rpWatcher, "rp")
 	go yieldChanges(bpWatcher, "bp")
 	go yieldChanges(dataWatcher, "data")
+	for source, packWatcher := range packWatchers {
+		go yieldChanges(packWatcher, source)
+	}
 	return nil
 }
 
@@ -109,6 +191,20 @@ func (c *RunContext) IsInterrupted(ignoredSourece ...string) bool {
 	}
 }
 
+// isCancelled returns true if the run was cancelled by the program that
+// embeds Regolith (see RunEmbedded). This function does not block.
+func (c *RunContext) isCancelled() bool {
+	if c.done == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
 func FilterDefinitionFromObject(id string) *FilterDefinition {
 	return &FilterDefinition{Id: id}
 }
@@ -121,6 +217,42 @@ func filterFromObject(obj map[string]interface{}) (*Filter, error) {
 	// Disabled
 	disabled, _ := obj["disabled"].(bool)
 	filter.Disabled = disabled
+	// When
+	when, err := whenFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.When = when
+	// Timeout and retries
+	timeout, retries, err := runPolicyFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Timeout = timeout
+	filter.Retries = retries
+	// Needs
+	needs, err := needsFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Needs = needs
+	// Cache
+	cache, _ := obj["cache"].(bool)
+	filter.Cache = cache
+	// Inputs and outputs
+	filter.Inputs, err = filterFilesFromObject(obj, "inputs")
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Outputs, err = filterFilesFromObject(obj, "outputs")
+	if err != nil {
+		return nil, PassError(err)
+	}
+	// Allowed paths
+	filter.AllowedPaths, err = filterFilesFromObject(obj, "allowedPaths")
+	if err != nil {
+		return nil, PassError(err)
+	}
 	// Arguments
 	arguments, ok := obj["arguments"].([]interface{})
 	if !ok {
@@ -165,12 +297,41 @@ type FilterRunner interface {
 	// disabled it always returns false.
 	Run(context RunContext) (bool, error)
 
-	// IsDisabled returns whether the filter is disabled.
-	IsDisabled() bool
+	// IsDisabled returns whether the filter is disabled, either with the
+	// "disabled" property or because its "when" expression is false.
+	IsDisabled(context RunContext) (bool, error)
 
 	// GetId returns the id of the filter.
 	GetId() string
 
+	// GetTimeout returns the maximal time of running the filter. Zero means
+	// that there is no limit.
+	GetTimeout() time.Duration
+
+	// GetRetries returns the number of times the filter is rerun if it
+	// fails.
+	GetRetries() int
+
+	// GetNeeds returns the ids of the filters that must finish before this
+	// filter runs.
+	GetNeeds() []string
+
+	// UsesCache returns whether the filter can be skipped if its inputs
+	// didn't change since the previous run.
+	UsesCache() bool
+
+	// GetInputs returns the glob patterns of the files that the filter
+	// reads. Empty list means that they're not declared.
+	GetInputs() []string
+
+	// GetOutputs returns the glob patterns of the files that the filter
+	// writes. Empty list means that they're not declared.
+	GetOutputs() []string
+
+	// GetAllowedPaths returns the glob patterns of the files outside of the
+	// tmp directory that the filter may change.
+	GetAllowedPaths() []string
+
 	// Check checks whether the requirements of the filter are met. For
 	// example, a Python filter requires Python to be installed.
 	Check(context RunContext) error
@@ -193,11 +354,117 @@ func (f *Filter) GetId() string {
 	return f.Id
 }
 
-func (f *Filter) IsDisabled() bool {
-	return f.Disabled
+func (f *Filter) GetTimeout() time.Duration {
+	return time.Duration(f.Timeout * float64(time.Second))
+}
+
+func (f *Filter) GetRetries() int {
+	return f.Retries
+}
+
+func (f *Filter) GetNeeds() []string {
+	return f.Needs
+}
+
+func (f *Filter) UsesCache() bool {
+	return f.Cache
+}
+
+func (f *Filter) GetInputs() []string {
+	return f.Inputs
+}
+
+func (f *Filter) GetOutputs() []string {
+	return f.Outputs
+}
+
+func (f *Filter) GetAllowedPaths() []string {
+	return f.AllowedPaths
+}
+
+func (f *Filter) IsDisabled(context RunContext) (bool, error) {
+	if f.Disabled {
+		return true, nil
+	}
+	if f.When == "" {
+		return false, nil
+	}
+	enabled, err := EvaluateWhenExpression(f.When, WhenEnvironment{
+		Profile: context.Profile,
+		Defines: context.Defines,
+	})
+	if err != nil {
+		return false, PassError(err)
+	}
+	return !enabled, nil
+}
+
+// runPolicyFromObject reads the optional "timeout" (in seconds) and
+// "retries" properties of a filter.
+func runPolicyFromObject(
+	obj map[string]interface{},
+) (float64, int, error) {
+	timeout := 0.0
+	if timeoutObj, ok := obj["timeout"]; ok {
+		seconds, ok := timeoutObj.(float64)
+		if !ok || seconds <= 0 {
+			return 0, 0, WrappedErrorf(
+				jsonPropertyTypeError, "timeout", "positive number")
+		}
+		timeout = seconds
+	}
+	retries := 0
+	if retriesObj, ok := obj["retries"]; ok {
+		value, ok := retriesObj.(float64)
+		if !ok || value < 0 || value != float64(int(value)) {
+			return 0, 0, WrappedErrorf(
+				jsonPropertyTypeError, "retries", "non-negative integer")
+		}
+		retries = int(value)
+	}
+	return timeout, retries, nil
+}
+
+// needsFromObject reads the optional "needs" property of a filter.
+func needsFromObject(obj map[string]interface{}) ([]string, error) {
+	needsObj, ok := obj["needs"]
+	if !ok {
+		return nil, nil
+	}
+	needsArray, ok := needsObj.([]interface{})
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyTypeError, "needs", "array")
+	}
+	needs := make([]string, len(needsArray))
+	for i, need := range needsArray {
+		needs[i], ok = need.(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, fmt.Sprintf("needs->%d", i), "string")
+		}
+	}
+	return needs, nil
+}
+
+// whenFromObject reads the optional "when" property of a filter.
+func whenFromObject(obj map[string]interface{}) (string, error) {
+	whenObj, ok := obj["when"]
+	if !ok {
+		return "", nil
+	}
+	when, ok := whenObj.(string)
+	if !ok {
+		return "", WrappedErrorf(jsonPropertyTypeError, "when", "string")
+	}
+	return when, nil
 }
 
 func FilterInstallerFromObject(id string, obj map[string]interface{}) (FilterInstaller, error) {
+	obj, err := applyPlatformOverrides(obj)
+	if err != nil {
+		return nil, WrapErrorf(
+			err, "Invalid platforms of the %q filter definition.", id)
+	}
 	runWith, _ := obj["runWith"].(string)
 	switch runWith {
 	case "java":
@@ -266,6 +533,23 @@ func FilterInstallerFromObject(id string, obj map[string]interface{}) (FilterIns
 				"Unable to create exe filter from %q filter definition.", id)
 		}
 		return filter, nil
+	case "lua":
+		filter, err := LuaFilterDefinitionFromObject(id, obj)
+		if err != nil {
+			return nil, WrapErrorf(
+				err,
+				"Unable to create Lua filter from %q filter definition.", id)
+		}
+		return filter, nil
+	case "docker":
+		filter, err := DockerFilterDefinitionFromObject(id, obj)
+		if err != nil {
+			return nil, WrapErrorf(
+				err,
+				"Unable to create Docker filter from %q filter definition.",
+				id)
+		}
+		return filter, nil
 	case "":
 		filter, err := RemoteFilterDefinitionFromObject(id, obj)
 		if err != nil {
@@ -280,16 +564,42 @@ func FilterInstallerFromObject(id string, obj map[string]interface{}) (FilterIns
 		"Invalid runWith value filter definition.\n"+
 			"Filter: %s\n"+
 			"Value: %s\n"+
-			"Valid values: java, dotnet, nim, deno, nodejs, python, shell, exe",
+			"Valid values: java, dotnet, nim, deno, nodejs, python, shell, exe, docker, lua",
 		runWith, id)
 }
 
 func FilterRunnerFromObjectAndDefinitions(
 	obj map[string]interface{}, filterDefinitions map[string]FilterInstaller,
 ) (FilterRunner, error) {
+	obj, err := applyPlatformOverrides(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
 	profile, ok := obj["profile"].(string)
 	if ok {
-		return &ProfileFilter{Profile: profile}, nil
+		when, err := whenFromObject(obj)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		timeout, retries, err := runPolicyFromObject(obj)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		needs, err := needsFromObject(obj)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		disabled, _ := obj["disabled"].(bool)
+		return &ProfileFilter{
+			Filter: Filter{
+				Disabled: disabled,
+				When:     when,
+				Timeout:  timeout,
+				Retries:  retries,
+				Needs:    needs,
+			},
+			Profile: profile,
+		}, nil
 	}
 	filterObj, ok := obj["filter"]
 	if !ok {
@@ -0,0 +1,114 @@
+package regolith
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// archiveExtensions are the extensions of the archives that can be used as
+// the sources of the remote filters.
+var archiveExtensions = []string{".zip", ".tar.gz", ".tgz"}
+
+// archiveChecksumTypes are the hash functions accepted in the checksums of
+// the archive filters.
+var archiveChecksumTypes = []string{"sha256", "sha512"}
+
+// splitArchiveUrl splits the URL of an archive filter into the URL of the
+// archive and the path to the filter inside of the archive, separated by
+// "//" (for example "https://example.com/filters.zip//my_filter"). The
+// subdirectory is empty if the filter is in the root of the archive.
+func splitArchiveUrl(rawUrl string) (archiveUrl, subdir string) {
+	scheme, rest, ok := strings.Cut(rawUrl, "://")
+	if !ok {
+		return rawUrl, ""
+	}
+	archivePath, subdir, ok := strings.Cut(rest, "//")
+	if !ok {
+		return rawUrl, ""
+	}
+	// The query belongs to the URL of the archive
+	subdir, query, hasQuery := strings.Cut(subdir, "?")
+	archiveUrl = scheme + "://" + archivePath
+	if hasQuery {
+		archiveUrl += "?" + query
+	}
+	return archiveUrl, subdir
+}
+
+// isArchiveUrl returns true if the URL of a remote filter points to a zip or
+// tar.gz archive on an HTTP(S) server, instead of a git repository.
+func isArchiveUrl(rawUrl string) bool {
+	if !strings.HasPrefix(rawUrl, "https://") &&
+		!strings.HasPrefix(rawUrl, "http://") {
+		return false
+	}
+	archiveUrl, _ := splitArchiveUrl(rawUrl)
+	archivePath, _, _ := strings.Cut(archiveUrl, "?")
+	archivePath = strings.ToLower(archivePath)
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(archivePath, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArchiveChecksum returns an error if the checksum of an archive filter
+// doesn't have the "<type>:<hex>" format, with one of the
+// archiveChecksumTypes.
+func checkArchiveChecksum(checksum string) error {
+	checksumType, value, _ := strings.Cut(checksum, ":")
+	for _, allowed := range archiveChecksumTypes {
+		if checksumType == allowed && value != "" {
+			return nil
+		}
+	}
+	return WrappedErrorf(
+		"Invalid checksum of the archive filter.\nChecksum: %s\n"+
+			"The checksum must have the \"<type>:<hex>\" format, where the "+
+			"type is one of: %s.",
+		checksum, strings.Join(archiveChecksumTypes, ", "))
+}
+
+// IsArchive returns true if the remote filter is downloaded from an archive
+// instead of a git repository. The archive filters don't have versions
+// resolved from git, their version is the checksum of the archive.
+func (f *RemoteFilterDefinition) IsArchive() bool {
+	return isArchiveUrl(f.Url)
+}
+
+// downloadArchive downloads the archive of the remote filter, verifies its
+// checksum and extracts the filter to the download path.
+func (f *RemoteFilterDefinition) downloadArchive(dotRegolithPath string) error {
+	downloadPath := f.GetDownloadPath(dotRegolithPath)
+	err := tryMirrors(f.Url, func(url string) error {
+		// go-getter verifies the checksum and extracts the subdirectory
+		separator := "?"
+		if strings.Contains(url, "?") {
+			separator = "&"
+		}
+		getterUrl := url + separator + "checksum=" + f.Checksum
+		err := getter.Get(downloadPath, getterUrl)
+		if err != nil {
+			f.Uninstall(dotRegolithPath)
+			return WrapErrorf(
+				err, "Failed to download the archive.\nURL: %s", url)
+		}
+		return nil
+	})
+	if err != nil {
+		return WrapErrorf(
+			err, "Could not download filter %q from the archive.", f.Id)
+	}
+	// Save the version of the filter we downloaded
+	err = f.SaveVerssionInfo(f.Version, dotRegolithPath)
+	if err != nil {
+		f.Uninstall(dotRegolithPath)
+		return WrapErrorf(
+			err, "The archive doesn't contain a remote filter.\nURL: %s",
+			f.Url)
+	}
+	Logger.Infof("Filter \"%s\" downloaded successfully.", f.Id)
+	return nil
+}
@@ -0,0 +1,490 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildCachePath is the path to the content-addressable cache of the outputs
+// of the filters, relative to the .regolith directory. The files of the
+// outputs are stored once in the objects directory, under their hashes, so
+// the outputs that differ only in a few files don't take much space.
+const buildCachePath = "cache/build"
+
+// filterCachePath is the path to the directory with the manifests of the
+// cached outputs of the filters, relative to the .regolith directory. Every
+// filter has a directory with a manifest for each cached output, named after
+// the cache key (see filterCacheKey).
+const filterCachePath = buildCachePath + "/outputs"
+
+// buildCacheObjectsPath is the path to the directory with the files of the
+// cached outputs, relative to the .regolith directory.
+const buildCacheObjectsPath = buildCachePath + "/objects"
+
+// legacyFilterCachePath is the path to the cached outputs of the filters
+// used by the older versions of Regolith, which kept only a copy of the
+// latest output. It's removed by the garbage collection (see cacheGc).
+const legacyFilterCachePath = "cache/filter-outputs"
+
+// maxCachedFilterOutputs is the number of the cached outputs kept for every
+// filter. The least recently used outputs are removed first. Keeping
+// multiple outputs makes switching between the branches of the project
+// fast, because the outputs of both branches stay in the cache.
+const maxCachedFilterOutputs = 16
+
+// filterOutputManifest lists the files of a cached output of a filter. The
+// keys are the directories from filterCacheDirs and the values are their
+// states (see GetStateFromPath), with the paths using slashes. The hashes of
+// the files are the names of the objects with their content, and the
+// directories have empty hashes.
+type filterOutputManifest map[string][]PathHashPair
+
+// filterCacheDirs returns the directories of the tmp directory (or of the
+// saved output of a filter), that are used as the inputs of the cached
+// filters, and stored as their outputs: the packs (see tmpPackDirs) and the
+// data folder.
+func filterCacheDirs(path string) []string {
+	return append(tmpPackDirs(path), "data")
+}
+
+// runFilterWithCache runs a filter that uses the "cache" property. If the
+// filter was already run with the same inputs, the filter isn't run, and the
+// tmp directory is restored from the output of that run instead. Otherwise
+// the filter is run, and its output is saved.
+//
+// The inputs of the filter are its configuration (including the expanded
+// variables), its filter definition, the files of the remote filters, the
+// code of the local filters (see localFilterSource) and the content of the
+// tmp directory.
+func runFilterWithCache(
+	filter FilterRunner, context RunContext,
+) (bool, error) {
+	workingDir := GetAbsoluteWorkingDirectory(context.DotRegolithPath)
+	key, inputs, err := filterCacheKey(filter, context, workingDir)
+	if err != nil {
+		return false, WrapError(err, "Failed to calculate cache key.")
+	}
+	filterCacheDir := filepath.Join(
+		context.DotRegolithPath, filterCachePath,
+		filterCacheDirName(filter.GetId()))
+	manifestPath := filepath.Join(filterCacheDir, key+".json")
+	if manifest, err := loadFilterOutputManifest(manifestPath); err == nil {
+		Logger.Infof(
+			"Inputs of filter \"%s\" didn't change, using cached output.",
+			filter.GetId())
+		err = restoreFilterOutput(
+			context.DotRegolithPath, manifest, inputs, workingDir)
+		if err == nil {
+			// Mark the output as recently used (see pruneFilterOutputs)
+			now := time.Now()
+			os.Chtimes(manifestPath, now, now)
+			return context.IsInterrupted(), nil
+		}
+		// Fall back to running the filter
+		Logger.Warnf(
+			"Failed to restore cached output of filter \"%s\":\n%s",
+			filter.GetId(), PassError(err).Error())
+		os.Remove(manifestPath)
+	}
+	interrupted, err := RunFilterWithPolicy(filter, context)
+	if err != nil || interrupted {
+		return interrupted, err
+	}
+	err = saveFilterOutput(context.DotRegolithPath, workingDir, manifestPath)
+	if err != nil {
+		os.Remove(manifestPath)
+		Logger.Warnf(
+			"Failed to save output of filter \"%s\" in cache:\n%s",
+			filter.GetId(), PassError(err).Error())
+	}
+	pruneFilterOutputs(context.DotRegolithPath, filterCacheDir)
+	return false, nil
+}
+
+// filterCacheKey returns a hash of the inputs of the filter, and the states
+// of the directories of the tmp directory (see filterOutputManifest), which
+// are a part of the inputs.
+func filterCacheKey(
+	filter FilterRunner, context RunContext, workingDir string,
+) (string, filterOutputManifest, error) {
+	key := sha256.New()
+	// The configuration of the filter
+	filterJson, err := json.Marshal(filter)
+	if err != nil {
+		return "", nil, WrapError(err, "Failed to encode the filter as JSON.")
+	}
+	io.WriteString(key, ExpandVariables(string(filterJson), context))
+	// The filter definition
+	if context.Config != nil {
+		definition, ok := context.Config.FilterDefinitions[filter.GetId()]
+		if ok {
+			definitionJson, err := json.Marshal(definition)
+			if err != nil {
+				return "", nil, WrapError(
+					err, "Failed to encode the filter definition as JSON.")
+			}
+			key.Write(definitionJson)
+		}
+	}
+	// The files of the remote filter
+	fileHash := sha1.New()
+	if remoteFilter, ok := filter.(*RemoteFilter); ok {
+		path := remoteFilter.GetDownloadPath(context.DotRegolithPath)
+		_, err = writeStateToHash(key, path, fileHash)
+		if err != nil {
+			return "", nil, PassError(err)
+		}
+	}
+	// The code of the local filter
+	if source := localFilterSource(filter, context); source != "" {
+		io.WriteString(key, "source\n")
+		info, err := os.Stat(source)
+		if err != nil {
+			return "", nil, WrapErrorf(
+				err, "Failed to access the code of the filter.\nPath: %s",
+				source)
+		}
+		if info.IsDir() {
+			_, err = writeStateToHash(key, source, fileHash)
+		} else {
+			var sourceHash string
+			sourceHash, err = getPathHash(source, fileHash)
+			io.WriteString(key, sourceHash+"\n")
+		}
+		if err != nil {
+			return "", nil, PassError(err)
+		}
+	}
+	// The content of the tmp directory
+	inputs := make(filterOutputManifest)
+	for _, dir := range filterCacheDirs(workingDir) {
+		io.WriteString(key, dir+"\n")
+		state, err := writeStateToHash(
+			key, filepath.Join(workingDir, dir), fileHash)
+		if err != nil {
+			return "", nil, PassError(err)
+		}
+		inputs[dir] = state
+	}
+	return hex.EncodeToString(key.Sum(nil)), inputs, nil
+}
+
+// localFilterSource returns the path to the code of a local filter, which is
+// a part of the inputs of the cached filters: the directory with the script
+// or the executable of its filter definition, or only the file if it's in
+// the root directory of the project. It returns an empty string for the
+// filters that don't run code from the project (like the remote filters,
+// whose files are checked separately, and the shell filters).
+func localFilterSource(filter FilterRunner, context RunContext) string {
+	var source string
+	switch f := filter.(type) {
+	case *PythonFilter:
+		source = f.Definition.Script
+	case *NodeJSFilter:
+		source = f.Definition.Script
+	case *DenoFilter:
+		source = f.Definition.Script
+	case *JavaFilter:
+		source = f.Definition.Script
+	case *NimFilter:
+		source = f.Definition.Script
+	case *LuaFilter:
+		source = f.Definition.Script
+	case *DotNetFilter:
+		source = f.Definition.Path
+	case *ExeFilter:
+		source, _ = f.Definition.resolveExe()
+	}
+	if source == "" {
+		return ""
+	}
+	root := filepath.Clean(context.AbsoluteLocation)
+	path := filepath.Join(root, source)
+	if dir := filepath.Dir(path); dir != root && isInDirectory(dir, root) {
+		return dir
+	}
+	return path
+}
+
+// writeStateToHash writes the state of the path (see GetStateFromPath) to
+// the hash and returns it, with the paths using slashes. Paths that don't
+// exist are skipped.
+func writeStateToHash(
+	target io.Writer, path string, fileHash hash.Hash,
+) ([]PathHashPair, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	state, err := GetStateFromPath(path, fileHash)
+	if err != nil {
+		return nil, WrapErrorf(err, "Failed to get the state of the path.\n"+
+			"Path: %s", path)
+	}
+	result := make([]PathHashPair, 0, state.Len())
+	for e := state.Front(); e != nil; e = e.Next() {
+		pair := e.Value.(PathHashPair)
+		pair.Path = filepath.ToSlash(pair.Path)
+		io.WriteString(target, pair.Path+"\x00"+pair.Hash+"\n")
+		result = append(result, pair)
+	}
+	return result, nil
+}
+
+// buildCacheObjectPath returns the path to the object with the content of a
+// file with the given hash.
+func buildCacheObjectPath(dotRegolithPath, hash string) string {
+	return filepath.Join(
+		dotRegolithPath, buildCacheObjectsPath, hash[:2], hash)
+}
+
+// loadFilterOutputManifest loads the manifest of a cached output of a
+// filter.
+func loadFilterOutputManifest(path string) (filterOutputManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	var manifest filterOutputManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	return manifest, nil
+}
+
+// saveFilterOutput stores the files of the tmp directory that aren't in the
+// cache yet as objects and saves the manifest of the output.
+func saveFilterOutput(dotRegolithPath, workingDir, manifestPath string) error {
+	manifest := make(filterOutputManifest)
+	fileHash := sha1.New()
+	for _, dir := range filterCacheDirs(workingDir) {
+		dirPath := filepath.Join(workingDir, dir)
+		state, err := writeStateToHash(io.Discard, dirPath, fileHash)
+		if err != nil {
+			return PassError(err)
+		}
+		for _, pair := range state {
+			if pair.Hash == "" { // Directory
+				continue
+			}
+			objectPath := buildCacheObjectPath(dotRegolithPath, pair.Hash)
+			if _, err := os.Stat(objectPath); err == nil {
+				continue
+			}
+			// Copy to a temporary file first, so an interrupted copy never
+			// leaves a broken object
+			source := filepath.Join(dirPath, filepath.FromSlash(pair.Path))
+			tmpPath := objectPath + ".tmp"
+			err = CopyFile(source, tmpPath)
+			if err == nil {
+				err = os.Rename(tmpPath, objectPath)
+			}
+			if err != nil {
+				os.Remove(tmpPath)
+				return WrapErrorf(err, osCopyError, source, objectPath)
+			}
+		}
+		manifest[dir] = state
+	}
+	err := os.MkdirAll(filepath.Dir(manifestPath), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(manifestPath))
+	}
+	data, _ := json.Marshal(manifest) // no error
+	err = os.WriteFile(manifestPath, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, manifestPath)
+	}
+	return nil
+}
+
+// restoreFilterOutput changes the content of the tmp directory to the cached
+// output of a filter. The inputs are the current states of the directories
+// of the tmp directory (see filterCacheKey). Only the files that differ from
+// the cached output are removed or copied from the objects.
+func restoreFilterOutput(
+	dotRegolithPath string, manifest, inputs filterOutputManifest,
+	workingDir string,
+) error {
+	dirs := filterCacheDirs(workingDir)
+	for dir := range manifest {
+		if !stringInSlice(dir, dirs) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, dir := range dirs {
+		dirPath := filepath.Join(workingDir, dir)
+		wanted := make(map[string]string, len(manifest[dir]))
+		for _, pair := range manifest[dir] {
+			wanted[pair.Path] = pair.Hash
+		}
+		current := make(map[string]string, len(inputs[dir]))
+		for _, pair := range inputs[dir] {
+			current[pair.Path] = pair.Hash
+		}
+		// Remove the paths that aren't in the output or changed between a
+		// file and a directory. The state lists the parents before their
+		// children, so it's checked in reverse.
+		state := inputs[dir]
+		for i := len(state) - 1; i >= 0; i-- {
+			hash, ok := wanted[state[i].Path]
+			if ok && (hash == "") == (state[i].Hash == "") {
+				continue
+			}
+			path := filepath.Join(dirPath, filepath.FromSlash(state[i].Path))
+			err := os.RemoveAll(path)
+			if err != nil {
+				return WrapErrorf(err, osRemoveError, path)
+			}
+			delete(current, state[i].Path)
+		}
+		if _, ok := manifest[dir]; !ok {
+			err := os.RemoveAll(dirPath)
+			if err != nil {
+				return WrapErrorf(err, osRemoveError, dirPath)
+			}
+			continue
+		}
+		err := os.MkdirAll(dirPath, 0755)
+		if err != nil {
+			return WrapErrorf(err, osMkdirError, dirPath)
+		}
+		// Add the missing and changed paths
+		for _, pair := range manifest[dir] {
+			path := filepath.Join(dirPath, filepath.FromSlash(pair.Path))
+			if pair.Hash == "" {
+				err = os.MkdirAll(path, 0755)
+				if err != nil {
+					return WrapErrorf(err, osMkdirError, path)
+				}
+				continue
+			}
+			if hash, ok := current[pair.Path]; ok && hash == pair.Hash {
+				continue
+			}
+			objectPath := buildCacheObjectPath(dotRegolithPath, pair.Hash)
+			err = CopyFile(objectPath, path)
+			if err != nil {
+				return WrapErrorf(err, osCopyError, objectPath, path)
+			}
+		}
+	}
+	return nil
+}
+
+// pruneFilterOutputs removes the least recently used outputs of a filter,
+// so only maxCachedFilterOutputs of them are kept, and the objects that are
+// no longer used by any output.
+func pruneFilterOutputs(dotRegolithPath, filterCacheDir string) {
+	entries := readDirOrEmpty(filterCacheDir)
+	if len(entries) <= maxCachedFilterOutputs {
+		return
+	}
+	lastUsed := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			lastUsed[entry.Name()] = info.ModTime()
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return lastUsed[entries[i].Name()].After(lastUsed[entries[j].Name()])
+	})
+	for _, entry := range entries[maxCachedFilterOutputs:] {
+		os.Remove(filepath.Join(filterCacheDir, entry.Name()))
+	}
+	removed, err := removeUnusedBuildCacheObjects(dotRegolithPath)
+	if err != nil {
+		Logger.Warnf(
+			"Failed to remove unused objects from the filter cache:\n%s",
+			PassError(err).Error())
+		return
+	}
+	Logger.Debugf("Removed %d unused objects from the filter cache.", removed)
+}
+
+// removeUnusedBuildCacheObjects removes the objects of the filter cache that
+// aren't used by any of the cached outputs, the checkpoints of the watch
+// mode or the saved tmp directories of the retried filters, and returns their
+// number. The manifests that can't be loaded are
+// removed as well.
+func removeUnusedBuildCacheObjects(dotRegolithPath string) (int, error) {
+	used := make(map[string]struct{})
+	markUsed := func(path string) {
+		manifest, err := loadFilterOutputManifest(path)
+		if err != nil {
+			os.Remove(path)
+			return
+		}
+		for _, state := range manifest {
+			for _, pair := range state {
+				used[pair.Hash] = struct{}{}
+			}
+		}
+	}
+	outputsPath := filepath.Join(dotRegolithPath, filterCachePath)
+	for _, filterDir := range readDirOrEmpty(outputsPath) {
+		filterPath := filepath.Join(outputsPath, filterDir.Name())
+		for _, output := range readDirOrEmpty(filterPath) {
+			markUsed(filepath.Join(filterPath, output.Name()))
+		}
+	}
+	checkpointsPath := filepath.Join(dotRegolithPath, filterCheckpointsPath)
+	for _, checkpoint := range readDirOrEmpty(checkpointsPath) {
+		markUsed(filepath.Join(checkpointsPath, checkpoint.Name()))
+	}
+	retriesPath := filepath.Join(dotRegolithPath, filterRetriesPath)
+	for _, retry := range readDirOrEmpty(retriesPath) {
+		markUsed(filepath.Join(retriesPath, retry.Name()))
+	}
+	removed := 0
+	objectsPath := filepath.Join(dotRegolithPath, buildCacheObjectsPath)
+	for _, prefixDir := range readDirOrEmpty(objectsPath) {
+		prefixPath := filepath.Join(objectsPath, prefixDir.Name())
+		for _, object := range readDirOrEmpty(prefixPath) {
+			if _, ok := used[object.Name()]; ok {
+				continue
+			}
+			path := filepath.Join(prefixPath, object.Name())
+			err := os.Remove(path)
+			if err != nil {
+				return removed, WrapErrorf(err, osRemoveError, path)
+			}
+			removed++
+		}
+		if len(readDirOrEmpty(prefixPath)) == 0 {
+			os.Remove(prefixPath)
+		}
+	}
+	return removed, nil
+}
+
+// filterCacheDirName returns a name of the directory with the cached output
+// of the filter, which is safe to use on every operating system.
+func filterCacheDirName(filterId string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, filterId)
+}
+
+// usesFilterCache returns true if any of the filters uses the "cache"
+// property.
+func usesFilterCache(filters []FilterRunner) bool {
+	for _, filter := range filters {
+		if filter.UsesCache() {
+			return true
+		}
+	}
+	return false
+}
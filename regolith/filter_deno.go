@@ -2,6 +2,7 @@ package regolith
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -10,8 +11,16 @@ import (
 type DenoFilterDefinition struct {
 	FilterDefinition
 	Script string `json:"script,omitempty"`
+	// Permissions is a list of flags passed to "deno run" before the script
+	// path (like "--allow-read" or "--allow-net=example.com"). By default
+	// the filter gets access to reading and writing files only.
+	Permissions []string `json:"permissions,omitempty"`
 }
 
+// defaultDenoPermissions is a list of permissions used by Deno filters that
+// don't specify the "permissions" property.
+var defaultDenoPermissions = []string{"--allow-read", "--allow-write"}
+
 type DenoFilter struct {
 	Filter
 	Definition DenoFilterDefinition `json:"-"`
@@ -29,43 +38,75 @@ func DenoFilterDefinitionFromObject(id string, obj map[string]interface{}) (*Den
 			jsonPropertyTypeError, "script", "string")
 	}
 	filter.Script = script
+	// Permissions (optional)
+	filter.Permissions = defaultDenoPermissions
+	if permissionsObj, ok := obj["permissions"]; ok {
+		permissions, ok := permissionsObj.([]interface{})
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "permissions", "array")
+		}
+		filter.Permissions = make([]string, len(permissions))
+		for i, permission := range permissions {
+			permission, ok := permission.(string)
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPropertyTypeError,
+					fmt.Sprintf("permissions->%d", i), "string")
+			}
+			if !strings.HasPrefix(permission, "--allow-") &&
+				!strings.HasPrefix(permission, "-A") {
+				return nil, WrappedErrorf(
+					"Invalid Deno permission flag.\n"+
+						"Flag: %s\n"+
+						"Deno permission flags start with \"--allow-\", "+
+						"for example \"--allow-read\" or \"--allow-net\".",
+					permission)
+			}
+			filter.Permissions[i] = permission
+		}
+	}
+	protocol, err := filterProtocolFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Protocol = protocol
 	return filter, nil
 }
 
 func (f *DenoFilter) run(context RunContext) error {
 	// Run filter
-	if len(f.Settings) == 0 {
-		err := RunSubProcess(
-			"deno",
-			append([]string{
-				"run",
-				context.AbsoluteLocation + string(os.PathSeparator) +
-					f.Definition.Script},
-				f.Arguments...,
-			),
-			context.AbsoluteLocation,
-			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
-			ShortFilterName(f.Id),
-		)
-		if err != nil {
-			return WrapError(err, runSubProcessError)
-		}
-	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
-		err := RunSubProcess(
-			"deno",
-			append([]string{
-				"run",
-				context.AbsoluteLocation + string(os.PathSeparator) +
-					f.Definition.Script,
-				string(jsonSettings)}, f.Arguments...),
-			context.AbsoluteLocation,
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	args := append([]string{"run"}, f.Definition.Permissions...)
+	args = append(
+		args,
+		context.AbsoluteLocation+string(os.PathSeparator)+f.Definition.Script)
+	if f.Definition.Protocol == stdioFilterProtocol {
+		err := RunProtocolSubProcess(
+			context, "deno", args, context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
-			ShortFilterName(f.Id),
-		)
+			ShortFilterName(f.Id), settings, arguments)
 		if err != nil {
-			return WrapError(err, runSubProcessError)
+			return PassError(err)
 		}
+		return nil
+	}
+	if len(settings) != 0 {
+		jsonSettings, _ := json.Marshal(settings)
+		args = append(args, string(jsonSettings))
+	}
+	err = runFilterSubProcess(
+		context, "deno",
+		append(args, arguments...),
+		context.AbsoluteLocation,
+		GetAbsoluteWorkingDirectory(context.DotRegolithPath),
+		ShortFilterName(f.Id),
+	)
+	if err != nil {
+		return WrapError(err, runSubProcessError)
 	}
 	return nil
 }
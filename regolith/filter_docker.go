@@ -0,0 +1,218 @@
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// dockerWorkingDir is the path inside of the container where the
+// [dotRegolithPath]/tmp directory is mounted.
+const dockerWorkingDir = "/regolith/tmp"
+
+// dockerFilterDir is the path inside of the container where the directory
+// of the filter is mounted (see DockerFilter.filterDir).
+const dockerFilterDir = "/regolith/filter"
+
+// dockerContainerCount is the number of the containers started by this
+// process of Regolith, used for their unique names.
+var dockerContainerCount int64
+
+type DockerFilterDefinition struct {
+	FilterDefinition
+	Image   string   `json:"image,omitempty"`
+	Command []string `json:"command,omitempty"`
+	// Path is the directory with the files of the filter, relative to the
+	// project for the local filters, or to the directory of the remote
+	// filter (see DockerFilter.filterDir).
+	Path string `json:"path,omitempty"`
+}
+
+type DockerFilter struct {
+	Filter
+	Definition DockerFilterDefinition `json:"-"`
+}
+
+func DockerFilterDefinitionFromObject(
+	id string, obj map[string]interface{},
+) (*DockerFilterDefinition, error) {
+	filter := &DockerFilterDefinition{
+		FilterDefinition: *FilterDefinitionFromObject(id)}
+	imageObj, ok := obj["image"]
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyMissingError, "image")
+	}
+	image, ok := imageObj.(string)
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyTypeError, "image", "string")
+	}
+	filter.Image = image
+	// Command (optional, uses the entrypoint of the image by default)
+	if commandObj, ok := obj["command"]; ok {
+		command, ok := commandObj.([]interface{})
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "command", "array")
+		}
+		filter.Command = make([]string, len(command))
+		for i, arg := range command {
+			arg, ok := arg.(string)
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPropertyTypeError,
+					fmt.Sprintf("command->%d", i), "string")
+			}
+			filter.Command[i] = arg
+		}
+	}
+	// Path (optional)
+	if pathObj, ok := obj["path"]; ok {
+		path, ok := pathObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyTypeError, "path", "string")
+		}
+		filter.Path = path
+	}
+	return filter, nil
+}
+
+// filterDir returns the directory mounted in the container as the
+// dockerFilterDir: the "path" of the filter, or the directory of the remote
+// filter if it's not set. The location of the local filters is the whole
+// project, so without the "path" they don't mount any directory. An empty
+// string is returned if nothing should be mounted.
+func (f *DockerFilter) filterDir(context RunContext) (string, error) {
+	if f.Definition.Path != "" {
+		return filepath.Join(context.AbsoluteLocation, f.Definition.Path), nil
+	}
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return "", WrapErrorf(err, osGetwdError)
+	}
+	if filepath.Clean(context.AbsoluteLocation) == filepath.Clean(projectDir) {
+		return "", nil
+	}
+	return context.AbsoluteLocation, nil
+}
+
+func (f *DockerFilter) run(context RunContext) error {
+	// Run filter
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	// The container has a unique name, so it can be stopped after killing
+	// the "docker run" command (see runFilterWithTimeout), which doesn't
+	// stop the container by itself
+	containerName := fmt.Sprintf(
+		"regolith-%d-%d", os.Getpid(),
+		atomic.AddInt64(&dockerContainerCount, 1))
+	args := []string{
+		"run", "--rm", "--name", containerName,
+		"-v", GetAbsoluteWorkingDirectory(context.DotRegolithPath) + ":" +
+			dockerWorkingDir,
+		"-w", dockerWorkingDir,
+		"-e", fmt.Sprintf("DEBUG=%t", Debug),
+	}
+	filterDir, err := f.filterDir(context)
+	if err != nil {
+		return PassError(err)
+	}
+	if filterDir != "" {
+		args = append(args,
+			"-v", filterDir+":"+dockerFilterDir+":ro",
+			"-e", "FILTER_DIR="+dockerFilterDir)
+	}
+	// The files created in the mounted tmp directory are owned by the user
+	// of the container, so on Linux and macOS it runs as the current user.
+	// The files owned by root couldn't be removed by Regolith. On Windows
+	// (where the user ID is -1) Docker handles the permissions of the mounts.
+	if uid := os.Getuid(); uid != -1 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+	}
+	args = append(args, f.Definition.Image)
+	args = append(args, f.Definition.Command...)
+	if len(settings) != 0 {
+		jsonSettings, _ := json.Marshal(settings)
+		args = append(args, string(jsonSettings))
+	}
+	err = runFilterSubProcess(
+		context, "docker",
+		append(args, arguments...),
+		context.AbsoluteLocation,
+		GetAbsoluteWorkingDirectory(context.DotRegolithPath),
+		ShortFilterName(f.Id),
+	)
+	if err != nil {
+		// Fails if the container already stopped
+		exec.Command("docker", "kill", containerName).Run()
+		return WrapErrorf(
+			err, "Failed to run Docker container.\nImage: %s",
+			f.Definition.Image)
+	}
+	return nil
+}
+
+func (f *DockerFilter) Run(context RunContext) (bool, error) {
+	if err := f.run(context); err != nil {
+		return false, PassError(err)
+	}
+	return context.IsInterrupted(), nil
+}
+
+func (f *DockerFilterDefinition) CreateFilterRunner(
+	runConfiguration map[string]interface{},
+) (FilterRunner, error) {
+	basicFilter, err := filterFromObject(runConfiguration)
+	if err != nil {
+		return nil, WrapError(err, filterFromObjectError)
+	}
+	filter := &DockerFilter{
+		Filter:     *basicFilter,
+		Definition: *f,
+	}
+	return filter, nil
+}
+
+// InstallDependencies pulls the image of the filter, so the first run of
+// the filter doesn't have to wait for the download.
+func (f *DockerFilterDefinition) InstallDependencies(
+	parent *RemoteFilterDefinition, dotRegolithPath string,
+) error {
+	Logger.Infof("Pulling Docker image for %s...", f.Id)
+	err := RunSubProcess(
+		"docker", []string{"pull", f.Image}, "", "", ShortFilterName(f.Id))
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to pull Docker image.\nImage: %s", f.Image)
+	}
+	Logger.Infof("Dependencies for %s installed successfully.", f.Id)
+	return nil
+}
+
+func (f *DockerFilterDefinition) Check(context RunContext) error {
+	_, err := exec.LookPath("docker")
+	if err != nil {
+		return WrapError(
+			err, "Docker not found, download and install it from"+
+				" https://docs.docker.com/get-docker/")
+	}
+	cmd, err := exec.Command(
+		"docker", "version", "--format", "{{.Server.Version}}").Output()
+	if err != nil {
+		return WrapError(
+			err, "Failed to check Docker version. Is the Docker daemon "+
+				"running?")
+	}
+	a := strings.Trim(string(cmd), " \n\t")
+	Logger.Debugf("Found Docker version %s", a)
+	return nil
+}
+
+func (f *DockerFilter) Check(context RunContext) error {
+	return f.Definition.Check(context)
+}
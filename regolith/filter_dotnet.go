@@ -38,15 +38,19 @@ func (f *DotNetFilter) Run(context RunContext) (bool, error) {
 
 func (f *DotNetFilter) run(context RunContext) error {
 	// Run the filter
-	if len(f.Settings) == 0 {
-		err := RunSubProcess(
-			"dotnet",
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	if len(settings) == 0 {
+		err := runFilterSubProcess(
+			context, "dotnet",
 			append(
 				[]string{
 					context.AbsoluteLocation + string(os.PathSeparator) +
 						f.Definition.Path,
 				},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
@@ -56,14 +60,14 @@ func (f *DotNetFilter) run(context RunContext) error {
 			return WrapError(err, "Failed to run .Net filter")
 		}
 	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
-		err := RunSubProcess(
-			"dotnet",
+		jsonSettings, _ := json.Marshal(settings)
+		err := runFilterSubProcess(
+			context, "dotnet",
 			append(
 				[]string{
 					context.AbsoluteLocation + string(os.PathSeparator) +
 						f.Definition.Path, string(jsonSettings)},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
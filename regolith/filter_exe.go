@@ -3,11 +3,19 @@ package regolith
 import (
 	"encoding/json"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 )
 
 type ExeFilterDefinition struct {
 	FilterDefinition
 	Exe string `json:"exe,omitempty"`
+	// PlatformExes maps platforms to the executables that should be used on
+	// them. The keys are in "<os>-<arch>" or "<os>" format (using GOOS and
+	// GOARCH names, like "windows-amd64" or "linux"). It's used when the
+	// "exe" property is an object instead of a string.
+	PlatformExes map[string]string `json:"-"`
 }
 
 type ExeFilter struct {
@@ -24,18 +32,64 @@ func ExeFilterDefinitionFromObject(
 	if !ok {
 		return nil, WrappedErrorf(jsonPropertyMissingError, "exe")
 	}
-	exe, ok := exeObj.(string)
-	if !ok {
+	switch exe := exeObj.(type) {
+	case string:
+		filter.Exe = exe
+	case map[string]interface{}:
+		filter.PlatformExes = make(map[string]string, len(exe))
+		for platform, pathObj := range exe {
+			path, ok := pathObj.(string)
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPropertyTypeError, "exe->"+platform, "string")
+			}
+			filter.PlatformExes[platform] = path
+		}
+	default:
 		return nil, WrappedErrorf(
-			jsonPropertyTypeError, "exe", "string")
+			jsonPropertyTypeError, "exe", "string or object")
 	}
-
-	filter.Exe = exe
+	protocol, err := filterProtocolFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Protocol = protocol
 	return filter, nil
 }
 
+// resolveExe returns the path to the executable of the filter for the
+// current platform. If the filter defines executables for multiple platforms,
+// the "<os>-<arch>" key is preferred over the "<os>" key.
+func (f *ExeFilterDefinition) resolveExe() (string, error) {
+	if f.PlatformExes == nil {
+		return f.Exe, nil
+	}
+	for _, platform := range []string{
+		runtime.GOOS + "-" + runtime.GOARCH, runtime.GOOS,
+	} {
+		if exe, ok := f.PlatformExes[platform]; ok {
+			return exe, nil
+		}
+	}
+	platforms := make([]string, 0, len(f.PlatformExes))
+	for platform := range f.PlatformExes {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	return "", WrappedErrorf(
+		"The filter doesn't provide an executable for this platform.\n"+
+			"Filter: %s\n"+
+			"Platform: %s-%s\n"+
+			"Supported platforms: %s",
+		f.Id, runtime.GOOS, runtime.GOARCH, strings.Join(platforms, ", "))
+}
+
 func (f *ExeFilter) Run(context RunContext) (bool, error) {
-	if err := f.run(f.Settings, context); err != nil {
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return false, PassError(err)
+	}
+	if err := f.run(settings, arguments, context); err != nil {
 		return false, PassError(err)
 	}
 	return context.IsInterrupted(), nil
@@ -62,6 +116,10 @@ func (f *ExeFilterDefinition) InstallDependencies(
 }
 
 func (f *ExeFilterDefinition) Check(context RunContext) error {
+	_, err := f.resolveExe()
+	if err != nil {
+		return PassError(err)
+	}
 	return nil
 }
 
@@ -71,35 +129,45 @@ func (f *ExeFilter) Check(context RunContext) error {
 
 func (f *ExeFilter) run(
 	settings map[string]interface{},
+	arguments []string,
 	context RunContext,
 ) error {
-	var err error = nil
-	if len(settings) == 0 {
-		err = executeExeFile(f.Id,
-			f.Definition.Exe,
-			f.Arguments, context.AbsoluteLocation,
+	exe, err := f.Definition.resolveExe()
+	if err != nil {
+		return PassError(err)
+	}
+	if f.Definition.Protocol == stdioFilterProtocol {
+		exePath := filepath.Join(context.AbsoluteLocation, exe)
+		err = RunProtocolSubProcess(
+			context, exePath, []string{}, context.AbsoluteLocation,
+			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
+			ShortFilterName(f.Id), settings, arguments)
+	} else if len(settings) == 0 {
+		err = executeExeFile(context, f.Id,
+			exe,
+			arguments, context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath))
 	} else {
 		jsonSettings, _ := json.Marshal(settings)
-		err = executeExeFile(f.Id,
-			f.Definition.Exe,
-			append([]string{string(jsonSettings)}, f.Arguments...),
+		err = executeExeFile(context, f.Id,
+			exe,
+			append([]string{string(jsonSettings)}, arguments...),
 			context.AbsoluteLocation, GetAbsoluteWorkingDirectory(
 				context.DotRegolithPath))
 	}
 	if err != nil {
 		return WrapErrorf(
-			err, "Failed to run exe file.\nPath: %s", f.Definition.Exe)
+			err, "Failed to run exe file.\nPath: %s", exe)
 	}
 	return nil
 }
 
-func executeExeFile(id string,
+func executeExeFile(context RunContext, id string,
 	exe string, args []string, filterDir string, workingDir string,
 ) error {
 	exe = filepath.Join(filterDir, exe)
 	Logger.Debugf("Running exe file %s:", exe)
-	err := RunSubProcess(exe, args, filterDir, workingDir, id)
+	err := runFilterSubProcess(context, exe, args, filterDir, workingDir, id)
 	if err != nil {
 		return WrapErrorf(err, runSubProcessError)
 	}
@@ -0,0 +1,82 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// fetchFilter downloads the remote filter from the subdirectory of the
+// repository at the git reference (a tag, a branch or a commit SHA) to the
+// download path. The filters are usually stored in repositories with many
+// other filters, so only the last commit of the reference and the files of
+// the filter are fetched (see sparseFetchFilter). If that fails, for example
+// because the git version or the server doesn't support partial clones, the
+// filter is downloaded with go-getter, using a shallow clone when possible.
+func fetchFilter(repoUrl, name, ref, downloadPath string) error {
+	err := sparseFetchFilter(repoUrl, name, ref, downloadPath)
+	if err == nil {
+		return nil
+	}
+	Logger.Debugf(
+		"Sparse fetch of the %q filter failed, using a full download:\n%s",
+		name, err)
+	url := remoteFilterGetterUrl(repoUrl, name, ref)
+	// The shallow clones can't check out commits that aren't the tips of
+	// the branches or tags
+	if !isCommitSha(ref) {
+		url += "&depth=1"
+	}
+	err = getter.Get(downloadPath, url)
+	if err != nil {
+		return gitError(repoUrl, "download", err.Error())
+	}
+	return nil
+}
+
+// sparseFetchFilter downloads the remote filter with a shallow, partial
+// fetch of the git reference into a temporary repository with a sparse
+// checkout of the filter's subdirectory. Only the files of the filter and
+// the trees of a single commit are downloaded. The subdirectory is moved to
+// the download path, which must not exist.
+func sparseFetchFilter(repoUrl, name, ref, downloadPath string) error {
+	parent := filepath.Dir(downloadPath)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, parent)
+	}
+	// The temporary repository is created next to the download path, so the
+	// filter can be moved instead of copied
+	repoPath, err := os.MkdirTemp(parent, ".tmp-"+name+"-")
+	if err != nil {
+		return WrapError(err, "Failed to create a temporary directory.")
+	}
+	defer os.RemoveAll(repoPath)
+	commands := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", remoteFilterGitUrl(repoUrl)},
+		{"sparse-checkout", "init", "--cone"},
+		{"sparse-checkout", "set", name},
+		{"fetch", "--quiet", "--depth=1", "--filter=blob:none", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+	for _, command := range commands {
+		_, err := runGitCommand(
+			repoUrl, append([]string{"-C", repoPath}, command...)...)
+		if err != nil {
+			return PassError(err)
+		}
+	}
+	filterPath := filepath.Join(repoPath, name)
+	if _, err := os.Stat(filterPath); err != nil {
+		return WrappedErrorf(
+			"The repository doesn't contain the filter.\n"+
+				"Repository: %s\nFilter: %s\nVersion: %s", repoUrl, name, ref)
+	}
+	err = os.Rename(filterPath, downloadPath)
+	if err != nil {
+		return WrapErrorf(err, osRenameError, filterPath, downloadPath)
+	}
+	return nil
+}
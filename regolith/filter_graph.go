@@ -0,0 +1,270 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The filters can declare the files that they read and write with the
+// "inputs" and "outputs" properties. They're lists of glob patterns (see
+// matchesGlobPatterns) relative to the working directory of the filters, for
+// example "RP/textures/**/*.png". Regolith uses them to build a graph of the
+// filters of the profile, which is used to:
+//   - run the filters in parallel, when they don't use the same files (see
+//     filterDependencies),
+//   - skip the filters whose inputs don't match any files,
+//   - print the graph with the "regolith graph" command.
+
+// The formats of the "regolith graph" command.
+const (
+	graphFormatDot     = "dot"
+	graphFormatMermaid = "mermaid"
+)
+
+// filterFilesFromObject reads the optional "inputs" or "outputs" property of
+// a filter.
+func filterFilesFromObject(
+	obj map[string]interface{}, property string,
+) ([]string, error) {
+	filesObj, ok := obj[property]
+	if !ok {
+		return nil, nil
+	}
+	filesArray, ok := filesObj.([]interface{})
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyTypeError, property, "array")
+	}
+	files := make([]string, len(filesArray))
+	for i, fileObj := range filesArray {
+		name := fmt.Sprintf("%s->%d", property, i)
+		file, ok := fileObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyTypeError, name, "string")
+		}
+		if err := validateGlobPattern(file); err != nil {
+			return nil, WrapErrorf(err, jsonPropertyParseError, name)
+		}
+		files[i] = file
+	}
+	return files, nil
+}
+
+// declaresFiles returns true if any of the filters of the profile declares
+// its inputs or outputs.
+func (p *Profile) declaresFiles() bool {
+	for _, filter := range p.Filters {
+		if filterDeclaresFiles(filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDeclaresFiles returns true if the filter declares its inputs or
+// outputs.
+func filterDeclaresFiles(filter FilterRunner) bool {
+	return len(filter.GetInputs()) > 0 || len(filter.GetOutputs()) > 0
+}
+
+// filterFiles returns the inputs and the outputs of the filter. The filters
+// that don't declare them may read and write any file. The filters that
+// declare only their inputs or only their outputs don't write or read any
+// other files.
+func filterFiles(filter FilterRunner) ([]string, []string) {
+	if !filterDeclaresFiles(filter) {
+		return []string{"**"}, []string{"**"}
+	}
+	return filter.GetInputs(), filter.GetOutputs()
+}
+
+// filesDependency returns true if the later filter must wait for the earlier
+// filter, because it reads the files written by the earlier filter, writes
+// the files that it reads or writes the same files.
+func filesDependency(earlier, later FilterRunner) bool {
+	earlierInputs, earlierOutputs := filterFiles(earlier)
+	laterInputs, laterOutputs := filterFiles(later)
+	return globPatternsOverlap(earlierOutputs, laterInputs) ||
+		globPatternsOverlap(earlierOutputs, laterOutputs) ||
+		globPatternsOverlap(earlierInputs, laterOutputs)
+}
+
+// globPatternsOverlap returns true if any of the patterns from the first list
+// may match the same path as any of the patterns from the second list.
+func globPatternsOverlap(a, b []string) bool {
+	for _, patternA := range a {
+		for _, patternB := range b {
+			if globPatternOverlap(patternA, patternB) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globPatternOverlap returns true if both of the patterns may match the same
+// path. The check is conservative, it returns false only if it's sure that
+// the patterns match different paths.
+func globPatternOverlap(a, b string) bool {
+	return globSegmentsOverlap(globPatternSegments(a), globPatternSegments(b))
+}
+
+// globPatternSegments splits the pattern into segments. The patterns without
+// slashes match the names of the files in any directory (see
+// matchesGlobPatterns), so they start with the "**" segment.
+func globPatternSegments(pattern string) []string {
+	if !strings.Contains(pattern, "/") {
+		return []string{"**", pattern}
+	}
+	return strings.Split(strings.Trim(pattern, "/"), "/")
+}
+
+// globSegmentsOverlap compares the segments of two glob patterns for
+// globPatternOverlap. The patterns where one is a prefix of the other
+// overlap, because the pattern may match a directory.
+func globSegmentsOverlap(a, b []string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] == "**" || b[0] == "**" {
+			return true
+		}
+		aLiteral := !strings.ContainsAny(a[0], `*?[\`)
+		bLiteral := !strings.ContainsAny(b[0], `*?[\`)
+		switch {
+		case aLiteral && bLiteral:
+			if a[0] != b[0] {
+				return false
+			}
+		case aLiteral:
+			if ok, _ := path.Match(b[0], a[0]); !ok {
+				return false
+			}
+		case bLiteral:
+			if ok, _ := path.Match(a[0], b[0]); !ok {
+				return false
+			}
+		}
+		a, b = a[1:], b[1:]
+	}
+	return true
+}
+
+// hasInputFiles returns true if any of the files in the working directory
+// matches the inputs of the filter. The filters that don't declare their
+// inputs always have them.
+func hasInputFiles(filter FilterRunner, workingDir string) bool {
+	inputs := filter.GetInputs()
+	if len(inputs) == 0 {
+		return true
+	}
+	found := false
+	for _, dir := range filterCacheDirs(workingDir) {
+		filepath.WalkDir(
+			filepath.Join(workingDir, dir),
+			func(p string, d os.DirEntry, err error) error {
+				if err != nil || found {
+					return filepath.SkipDir
+				}
+				if d.IsDir() {
+					return nil
+				}
+				relPath, err := filepath.Rel(workingDir, p)
+				if err == nil && matchesGlobPatterns(relPath, inputs) {
+					found = true
+				}
+				return nil
+			})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// reduceFilterDependencies returns the dependencies between the filters (see
+// filterDependencies) without the ones that are implied by the other
+// dependencies, so the graph of a sequential profile is a single chain.
+func reduceFilterDependencies(dependencies [][]int) [][]int {
+	// reachable[i][j] is true if the filter i must wait for the filter j
+	reachable := make([][]bool, len(dependencies))
+	var visit func(i, from int)
+	visit = func(i, from int) {
+		for _, dependency := range dependencies[from] {
+			if !reachable[i][dependency] {
+				reachable[i][dependency] = true
+				visit(i, dependency)
+			}
+		}
+	}
+	for i := range dependencies {
+		reachable[i] = make([]bool, len(dependencies))
+		visit(i, i)
+	}
+	result := make([][]int, len(dependencies))
+	for i, deps := range dependencies {
+	dependencyLoop:
+		for _, dependency := range deps {
+			for _, other := range deps {
+				if other != dependency && reachable[other][dependency] {
+					continue dependencyLoop
+				}
+			}
+			result[i] = append(result[i], dependency)
+		}
+	}
+	return result
+}
+
+// filterGraphLabel returns the label of the filter in the graph of the
+// profile.
+func filterGraphLabel(filter FilterRunner) string {
+	if profileFilter, ok := filter.(*ProfileFilter); ok {
+		return "profile: " + profileFilter.Profile
+	}
+	return filter.GetId()
+}
+
+// renderFilterGraph returns the graph of the filters of the profile in the
+// DOT or Mermaid format. The edges point from the filters to the filters
+// that wait for them.
+func renderFilterGraph(
+	profileName string, filters []FilterRunner, format string,
+) (string, error) {
+	dependencies, err := filterDependencies(filters)
+	if err != nil {
+		return "", PassError(err)
+	}
+	dependencies = reduceFilterDependencies(dependencies)
+	var builder strings.Builder
+	switch format {
+	case graphFormatDot:
+		fmt.Fprintf(&builder, "digraph %q {\n", profileName)
+		for i, filter := range filters {
+			fmt.Fprintf(
+				&builder, "\tf%d [label=%q];\n", i+1, filterGraphLabel(filter))
+		}
+		for i, deps := range dependencies {
+			for _, dependency := range deps {
+				fmt.Fprintf(&builder, "\tf%d -> f%d;\n", dependency+1, i+1)
+			}
+		}
+		builder.WriteString("}\n")
+	case graphFormatMermaid:
+		builder.WriteString("flowchart TD\n")
+		for i, filter := range filters {
+			label := strings.ReplaceAll(filterGraphLabel(filter), "\"", "#quot;")
+			fmt.Fprintf(&builder, "\tf%d[\"%s\"]\n", i+1, label)
+		}
+		for i, deps := range dependencies {
+			for _, dependency := range deps {
+				fmt.Fprintf(&builder, "\tf%d --> f%d\n", dependency+1, i+1)
+			}
+		}
+	default:
+		return "", WrappedErrorf(
+			"Unknown graph format.\nFormat: %s\nValid values: %s, %s",
+			format, graphFormatDot, graphFormatMermaid)
+	}
+	return builder.String(), nil
+}
@@ -50,15 +50,19 @@ func (f *JavaFilter) Run(context RunContext) (bool, error) {
 
 func (f *JavaFilter) run(context RunContext) error {
 	// Run the filter
-	if len(f.Settings) == 0 {
-		err := RunSubProcess(
-			"java",
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	if len(settings) == 0 {
+		err := runFilterSubProcess(
+			context, "java",
 			append(
 				[]string{
 					"-jar", context.AbsoluteLocation + string(os.PathSeparator) +
 						f.Definition.Script,
 				},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
@@ -68,14 +72,14 @@ func (f *JavaFilter) run(context RunContext) error {
 			return WrapError(err, "Failed to run Java filter")
 		}
 	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
-		err := RunSubProcess(
-			"java",
+		jsonSettings, _ := json.Marshal(settings)
+		err := runFilterSubProcess(
+			context, "java",
 			append(
 				[]string{
 					"-jar", context.AbsoluteLocation + string(os.PathSeparator) +
 						f.Definition.Script, string(jsonSettings)},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
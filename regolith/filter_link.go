@@ -0,0 +1,121 @@
+package regolith
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// filterLinksPath is the path to the file with the remote filters linked to
+// their local working copies by the "regolith link" command, relative to the
+// .regolith directory. The file maps the names of the filters to the
+// absolute paths of the working copies.
+const filterLinksPath = "cache/filter-links.json"
+
+// LoadFilterLinks returns the remote filters of the project linked to their
+// local working copies.
+func LoadFilterLinks(dotRegolithPath string) (map[string]string, error) {
+	result := make(map[string]string)
+	path := filepath.Join(dotRegolithPath, filterLinksPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	return result, nil
+}
+
+// saveFilterLinks saves the list of the linked remote filters.
+func saveFilterLinks(links map[string]string, dotRegolithPath string) error {
+	path := filepath.Join(dotRegolithPath, filterLinksPath)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(path))
+	}
+	data, _ := json.MarshalIndent(links, "", "\t") // no error
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	return nil
+}
+
+// IsLinked returns true if the remote filter is linked to a local working
+// copy. Such filters are never downloaded, updated or locked.
+func (f *RemoteFilterDefinition) IsLinked(dotRegolithPath string) bool {
+	links, err := LoadFilterLinks(dotRegolithPath)
+	if err != nil {
+		Logger.Debugf("Unable to load the linked filters: %s", err)
+		return false
+	}
+	_, ok := links[f.Id]
+	return ok
+}
+
+// linkFilter replaces the downloaded remote filter with a link to its local
+// working copy.
+func linkFilter(
+	filter *RemoteFilterDefinition, workingCopy, dotRegolithPath string,
+) error {
+	target, err := filepath.Abs(workingCopy)
+	if err != nil {
+		return WrapErrorf(err, filepathAbsError, workingCopy)
+	}
+	filterJsonPath := filepath.Join(target, "filter.json")
+	if _, err := os.Stat(filterJsonPath); err != nil {
+		return WrappedErrorf(
+			"The directory doesn't contain a remote filter.\n"+
+				"Path: %s\nMissing file: %s", target, filterJsonPath)
+	}
+	links, err := LoadFilterLinks(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	// The downloaded filter is removed, it can be downloaded again after
+	// unlinking
+	downloadPath := filter.GetDownloadPath(dotRegolithPath)
+	err = os.RemoveAll(downloadPath)
+	if err != nil {
+		return WrapErrorf(err, osRemoveError, downloadPath)
+	}
+	err = os.MkdirAll(filepath.Dir(downloadPath), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(downloadPath))
+	}
+	Logger.Infof("Linking \"%s\" to \"%s\".", downloadPath, target)
+	err = createDirectoryLink(target, downloadPath)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to create a link.\nLink: %s\nTarget: %s",
+			downloadPath, target)
+	}
+	links[filter.Id] = target
+	return saveFilterLinks(links, dotRegolithPath)
+}
+
+// unlinkFilter removes the link of the remote filter to its local working
+// copy. The filter must be installed again to use its remote version.
+func unlinkFilter(filter *RemoteFilterDefinition, dotRegolithPath string) error {
+	links, err := LoadFilterLinks(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	if _, ok := links[filter.Id]; !ok {
+		return WrappedErrorf("The filter is not linked.\nFilter: %s", filter.Id)
+	}
+	downloadPath := filter.GetDownloadPath(dotRegolithPath)
+	if info, err := os.Lstat(downloadPath); err == nil && isDirectoryLink(info) {
+		err = os.Remove(downloadPath)
+		if err != nil {
+			return WrapErrorf(err, osRemoveError, downloadPath)
+		}
+	}
+	delete(links, filter.Id)
+	return saveFilterLinks(links, dotRegolithPath)
+}
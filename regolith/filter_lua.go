@@ -0,0 +1,286 @@
+package regolith
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+type LuaFilterDefinition struct {
+	FilterDefinition
+	Script string `json:"script,omitempty"`
+}
+
+type LuaFilter struct {
+	Filter
+	Definition LuaFilterDefinition `json:"-"`
+}
+
+func LuaFilterDefinitionFromObject(
+	id string, obj map[string]interface{},
+) (*LuaFilterDefinition, error) {
+	filter := &LuaFilterDefinition{
+		FilterDefinition: *FilterDefinitionFromObject(id)}
+	scriptObj, ok := obj["script"]
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyMissingError, "script")
+	}
+	script, ok := scriptObj.(string)
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyTypeError, "script", "string")
+	}
+	filter.Script = script
+	return filter, nil
+}
+
+func (f *LuaFilter) run(context RunContext) error {
+	scriptPath := filepath.Join(context.AbsoluteLocation, f.Definition.Script)
+	workingDir := GetAbsoluteWorkingDirectory(context.DotRegolithPath)
+	outputLabel := ShortFilterName(f.Id)
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	Logger.Debugf("Running Lua script %s", scriptPath)
+
+	L := lua.NewState()
+	defer L.Close()
+	// The script runs inside of Regolith, so it's stopped with the context
+	// instead of killing its process after the timeout
+	if context.processOwner != nil {
+		ctx, cancel := context.processOwner.context()
+		defer cancel()
+		L.SetContext(ctx)
+	}
+	// Let the script "require" the modules from the filter directory
+	packageTable, ok := L.GetGlobal("package").(*lua.LTable)
+	if ok {
+		packagePath := filepath.Join(context.AbsoluteLocation, "?.lua")
+		L.SetField(
+			packageTable, "path",
+			lua.LString(packagePath+";"+lua.LuaPathDefault))
+	}
+	// Redirect the output of "print" to the logger
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		Logger.Infof("[%s] %s", outputLabel, strings.Join(parts, "\t"))
+		return 0
+	}))
+	L.PreloadModule("regolith", func(L *lua.LState) int {
+		module := L.SetFuncs(
+			L.NewTable(), luaRegolithModuleFunctions(workingDir))
+		settingsTable := L.NewTable()
+		for k, v := range settings {
+			settingsTable.RawSetString(k, luaValueFromJson(L, v))
+		}
+		L.SetField(module, "settings", settingsTable)
+		argumentsTable := L.NewTable()
+		for _, arg := range arguments {
+			argumentsTable.Append(lua.LString(arg))
+		}
+		L.SetField(module, "arguments", argumentsTable)
+		L.SetField(module, "filter_dir", lua.LString(context.AbsoluteLocation))
+		L.SetField(module, "working_dir", lua.LString(workingDir))
+		L.SetField(module, "debug", lua.LBool(Debug))
+		L.Push(module)
+		return 1
+	})
+	err = L.DoFile(scriptPath)
+	if err != nil {
+		return WrapErrorf(err, "Failed to run Lua script.\nPath: %s", scriptPath)
+	}
+	return nil
+}
+
+func (f *LuaFilter) Run(context RunContext) (bool, error) {
+	if err := f.run(context); err != nil {
+		return false, PassError(err)
+	}
+	return context.IsInterrupted(), nil
+}
+
+func (f *LuaFilterDefinition) CreateFilterRunner(
+	runConfiguration map[string]interface{},
+) (FilterRunner, error) {
+	basicFilter, err := filterFromObject(runConfiguration)
+	if err != nil {
+		return nil, WrapError(err, filterFromObjectError)
+	}
+	filter := &LuaFilter{
+		Filter:     *basicFilter,
+		Definition: *f,
+	}
+	return filter, nil
+}
+
+func (f *LuaFilterDefinition) InstallDependencies(
+	*RemoteFilterDefinition, string,
+) error {
+	return nil
+}
+
+// Check always succeeds because the Lua interpreter is built into Regolith.
+func (f *LuaFilterDefinition) Check(context RunContext) error {
+	return nil
+}
+
+func (f *LuaFilter) Check(context RunContext) error {
+	return f.Definition.Check(context)
+}
+
+// luaRegolithModuleFunctions returns the functions of the "regolith" Lua
+// module. The relative paths passed to the functions are resolved from the
+// workingDir.
+func luaRegolithModuleFunctions(workingDir string) map[string]lua.LGFunction {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(workingDir, path)
+	}
+	return map[string]lua.LGFunction{
+		"read_file": func(L *lua.LState) int {
+			path := resolve(L.CheckString(1))
+			data, err := os.ReadFile(path)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, fileReadError, path))
+				return 0
+			}
+			L.Push(lua.LString(data))
+			return 1
+		},
+		"write_file": func(L *lua.LState) int {
+			path := resolve(L.CheckString(1))
+			data := L.CheckString(2)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, osMkdirError, path))
+				return 0
+			}
+			err = writeTmpFile(path, []byte(data))
+			if err != nil {
+				L.RaiseError("%s", err)
+			}
+			return 0
+		},
+		"read_json": func(L *lua.LState) int {
+			path := resolve(L.CheckString(1))
+			data, err := os.ReadFile(path)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, fileReadError, path))
+				return 0
+			}
+			var value interface{}
+			err = json.Unmarshal(data, &value)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, jsonUnmarshalError, path))
+				return 0
+			}
+			L.Push(luaValueFromJson(L, value))
+			return 1
+		},
+		"write_json": func(L *lua.LState) int {
+			path := resolve(L.CheckString(1))
+			value := jsonFromLuaValue(L.CheckAny(2))
+			data, err := json.MarshalIndent(value, "", "\t")
+			if err != nil {
+				L.RaiseError(
+					"%s", WrapErrorf(err, "Failed to encode JSON.\nPath: %s",
+						path))
+				return 0
+			}
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, osMkdirError, path))
+				return 0
+			}
+			err = writeTmpFile(path, data)
+			if err != nil {
+				L.RaiseError("%s", err)
+			}
+			return 0
+		},
+		"exists": func(L *lua.LState) int {
+			_, err := os.Stat(resolve(L.CheckString(1)))
+			L.Push(lua.LBool(err == nil))
+			return 1
+		},
+		"list_files": func(L *lua.LState) int {
+			root := resolve(L.CheckString(1))
+			files, err := listFiles(root)
+			if err != nil {
+				L.RaiseError("%s", WrapErrorf(err, osWalkError, root))
+				return 0
+			}
+			result := L.NewTable()
+			for _, file := range files {
+				result.Append(lua.LString(filepath.ToSlash(file)))
+			}
+			L.Push(result)
+			return 1
+		},
+	}
+}
+
+// luaValueFromJson converts a value decoded with json.Unmarshal to a Lua
+// value.
+func luaValueFromJson(L *lua.LState, value interface{}) lua.LValue {
+	switch value := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(value)
+	case float64:
+		return lua.LNumber(value)
+	case string:
+		return lua.LString(value)
+	case []interface{}:
+		result := L.NewTable()
+		for _, item := range value {
+			result.Append(luaValueFromJson(L, item))
+		}
+		return result
+	case map[string]interface{}:
+		result := L.NewTable()
+		for k, v := range value {
+			result.RawSetString(k, luaValueFromJson(L, v))
+		}
+		return result
+	}
+	return lua.LNil
+}
+
+// jsonFromLuaValue converts a Lua value to a value that can be encoded with
+// json.Marshal. Tables with continuous integer keys starting from 1 are
+// converted to arrays, other tables are converted to objects.
+func jsonFromLuaValue(value lua.LValue) interface{} {
+	switch value := value.(type) {
+	case lua.LBool:
+		return bool(value)
+	case lua.LNumber:
+		return float64(value)
+	case lua.LString:
+		return string(value)
+	case *lua.LTable:
+		length := value.MaxN()
+		if length > 0 {
+			result := make([]interface{}, 0, length)
+			for i := 1; i <= length; i++ {
+				result = append(result, jsonFromLuaValue(value.RawGetInt(i)))
+			}
+			return result
+		}
+		result := make(map[string]interface{})
+		value.ForEach(func(k, v lua.LValue) {
+			result[k.String()] = jsonFromLuaValue(v)
+		})
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package regolith
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// nestedFilterInstalls tracks the remote filters installed by installFilters,
+// including the nested remote filters required by the filter.json files of
+// the other remote filters. Every filter is installed only once, even if
+// multiple filters require it.
+type nestedFilterInstalls struct {
+	mutex sync.Mutex
+	// claimed are the filters that are installed or being installed. The
+	// values are the remote filter definitions (nil for the other types of
+	// filters) and the names of the filters that required them (empty for
+	// the filters from config.json).
+	claimed map[string]nestedFilterClaim
+}
+
+type nestedFilterClaim struct {
+	filter     *RemoteFilterDefinition
+	requiredBy string
+}
+
+// newNestedFilterInstalls creates a nestedFilterInstalls with the filters
+// from config.json already claimed.
+func newNestedFilterInstalls(
+	filterDefinitions map[string]FilterInstaller,
+) *nestedFilterInstalls {
+	result := &nestedFilterInstalls{
+		claimed: make(map[string]nestedFilterClaim)}
+	for name, filterDefinition := range filterDefinitions {
+		remoteFilter, _ := filterDefinition.(*RemoteFilterDefinition)
+		result.claimed[name] = nestedFilterClaim{filter: remoteFilter}
+	}
+	return result
+}
+
+// claim returns true if the nested remote filter should be installed by the
+// caller. It returns false if the filter is already installed by another
+// filter, and an error if another filter with the same name but a different
+// URL or version is used.
+func (n *nestedFilterInstalls) claim(
+	name string, filter *RemoteFilterDefinition, requiredBy string,
+) (bool, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	claimed, ok := n.claimed[name]
+	if !ok {
+		n.claimed[name] = nestedFilterClaim{
+			filter: filter, requiredBy: requiredBy}
+		return true, nil
+	}
+	if claimed.filter != nil && claimed.filter.Url == filter.Url &&
+		claimed.filter.Version == filter.Version {
+		return false, nil
+	}
+	source := "the config.json file"
+	if claimed.requiredBy != "" {
+		source = fmt.Sprintf("the %q filter", claimed.requiredBy)
+	}
+	return false, WrappedErrorf(
+		"Conflicting definitions of a nested remote filter.\n"+
+			"Filter: %s\nRequired by: %s (URL: %s, version: %s)\n"+
+			"Conflicts with the filter defined by %s.",
+		name, requiredBy, filter.Url, filter.Version, source)
+}
+
+// isNestedRemoteFilter returns true if the subfilter from the filter.json
+// file of a remote filter is another remote filter. The nested remote
+// filters don't have the "runWith" property, and their "filter" property is
+// the name of the filter in its repository, also after applying the
+// properties of the current platform (see applyPlatformOverrides).
+func isNestedRemoteFilter(obj map[string]interface{}) bool {
+	// The invalid "platforms" are reported when the subfilter is parsed
+	if resolved, err := applyPlatformOverrides(obj); err == nil {
+		obj = resolved
+	}
+	_, ok := obj["runWith"]
+	return !ok
+}
+
+// subfilterId returns the ID of the subfilter of a remote filter. The nested
+// remote filters use their names (from the "filter" property), because
+// they're installed like the filters from config.json. The other subfilters
+// use "<parent>:subfilter<index>".
+func subfilterId(
+	parentId string, i int, obj map[string]interface{},
+) (string, error) {
+	if !isNestedRemoteFilter(obj) {
+		return fmt.Sprintf("%v:subfilter%v", parentId, i), nil
+	}
+	name, ok := obj["filter"].(string)
+	if !ok || name == "" || strings.ContainsAny(name, `/\:`) {
+		return "", WrappedErrorf(
+			"The nested remote filter must have the \"filter\" property "+
+				"with the name of the filter.\nJSON path: filters->%d", i)
+	}
+	return name, nil
+}
+
+// nestedFilters returns the nested remote filters required by the installed
+// remote filter (see isNestedRemoteFilter).
+func (f *RemoteFilterDefinition) nestedFilters(
+	dotRegolithPath string,
+) (map[string]*RemoteFilterDefinition, error) {
+	filterJson, err := f.LoadFilterJson(dotRegolithPath)
+	if err != nil {
+		return nil, WrapErrorf(
+			err, "Could not load filter.json for %q filter.", f.Id)
+	}
+	filters, _ := filterJson["filters"].([]interface{})
+	result := make(map[string]*RemoteFilterDefinition)
+	for i, filterObj := range filters {
+		obj, ok := filterObj.(map[string]interface{})
+		if !ok || !isNestedRemoteFilter(obj) {
+			continue
+		}
+		name, err := subfilterId(f.Id, i, obj)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		nested, err := RemoteFilterDefinitionFromObject(name, obj)
+		if err != nil {
+			return nil, WrapErrorf(
+				err, jsonPathParseError, fmt.Sprintf("filters->%d", i))
+		}
+		result[name] = nested
+	}
+	return result, nil
+}
+
+// installNestedFilters installs the nested remote filters required by the
+// remote filter, and their nested filters, using installFilter.
+func installNestedFilters(
+	name string, remoteFilter *RemoteFilterDefinition, force, offline bool,
+	dataPath, dotRegolithPath string, lockFile *LockFile,
+	nested *nestedFilterInstalls,
+) error {
+	nestedFilters, err := remoteFilter.nestedFilters(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	names := make([]string, 0, len(nestedFilters))
+	for nestedName := range nestedFilters {
+		names = append(names, nestedName)
+	}
+	sort.Strings(names)
+	for _, nestedName := range names {
+		nestedFilter := nestedFilters[nestedName]
+		claimed, err := nested.claim(nestedName, nestedFilter, name)
+		if err != nil {
+			return PassError(err)
+		}
+		if !claimed {
+			continue
+		}
+		Logger.Infof(
+			"Installing %q filter required by %q...", nestedName, name)
+		err = installFilter(
+			nestedName, nestedFilter, force, offline, dataPath,
+			dotRegolithPath, lockFile, nested)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to install a nested remote filter.\n"+
+					"Filter: %s\nRequired by: %s", nestedName, name)
+		}
+	}
+	return nil
+}
+
+// withNestedFilters returns the filter definitions together with the nested
+// remote filters required by the installed remote filters (recursively).
+// The filters that can't be loaded are skipped.
+func withNestedFilters(
+	filterDefinitions map[string]FilterInstaller, dotRegolithPath string,
+) map[string]FilterInstaller {
+	result := make(map[string]FilterInstaller, len(filterDefinitions))
+	var queue []*RemoteFilterDefinition
+	for name, filterDefinition := range filterDefinitions {
+		result[name] = filterDefinition
+		if remoteFilter, ok := filterDefinition.(*RemoteFilterDefinition); ok {
+			queue = append(queue, remoteFilter)
+		}
+	}
+	for len(queue) > 0 {
+		remoteFilter := queue[0]
+		queue = queue[1:]
+		nestedFilters, err := remoteFilter.nestedFilters(dotRegolithPath)
+		if err != nil {
+			Logger.Debugf("Unable to load the nested filters: %s", err)
+			continue
+		}
+		for name, nestedFilter := range nestedFilters {
+			if _, ok := result[name]; !ok {
+				result[name] = nestedFilter
+				queue = append(queue, nestedFilter)
+			}
+		}
+	}
+	return result
+}
+
+// checkNestedFilterCycles returns an error if the nested remote filters of
+// the installed filters require each other in a cycle, which would run the
+// filters forever.
+func checkNestedFilterCycles(
+	filterDefinitions map[string]FilterInstaller, dotRegolithPath string,
+) error {
+	all := withNestedFilters(filterDefinitions, dotRegolithPath)
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visiting:
+			return WrappedErrorf(
+				"The nested remote filters require each other in a cycle.\n"+
+					"Cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		remoteFilter, ok := all[name].(*RemoteFilterDefinition)
+		if !ok {
+			return nil
+		}
+		states[name] = visiting
+		nestedFilters, err := remoteFilter.nestedFilters(dotRegolithPath)
+		if err == nil {
+			for nestedName := range nestedFilters {
+				err := visit(nestedName, append(path, name))
+				if err != nil {
+					return PassError(err)
+				}
+			}
+		}
+		states[name] = visited
+		return nil
+	}
+	names := make([]string, 0, len(filterDefinitions))
+	for name := range filterDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return PassError(err)
+		}
+	}
+	return nil
+}
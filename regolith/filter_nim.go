@@ -37,13 +37,17 @@ func NimFilterDefinitionFromObject(
 
 func (f *NimFilter) run(context RunContext) error {
 	// Run filter
-	if len(f.Settings) == 0 {
-		err := RunSubProcess(
-			"nim",
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	if len(settings) == 0 {
+		err := runFilterSubProcess(
+			context, "nim",
 			append([]string{
 				"-r", "c", "--hints:off", "--warnings:off",
 				context.AbsoluteLocation + string(os.PathSeparator) + f.Definition.Script},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
@@ -53,15 +57,15 @@ func (f *NimFilter) run(context RunContext) error {
 			return PassError(err)
 		}
 	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
-		err := RunSubProcess(
-			"nim",
+		jsonSettings, _ := json.Marshal(settings)
+		err := runFilterSubProcess(
+			context, "nim",
 			append([]string{
 				"-r", "c", "--hints:off", "--warnings:off",
 				context.AbsoluteLocation + string(os.PathSeparator) +
 					f.Definition.Script,
 				string(jsonSettings)},
-				f.Arguments...),
+				arguments...),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
 			ShortFilterName(f.Id),
@@ -1,17 +1,48 @@
 package regolith
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
+// nodePackageManagers are the package managers that can install the
+// dependencies of the Node.js filters, with the arguments of their install
+// commands.
+var nodePackageManagers = map[string][]string{
+	"npm":  {"i", "--no-fund", "--no-audit"},
+	"pnpm": {"install"},
+	"yarn": {"install"},
+}
+
+// nodeDependencyFiles are the files that define the dependencies of a
+// Node.js filter. Their hash decides if the dependencies must be installed
+// again.
+var nodeDependencyFiles = []string{
+	"package.json", "package-lock.json", "pnpm-lock.yaml", "yarn.lock",
+}
+
+// nodeInstallHashPath is the path to the file with the hash of the
+// dependency files from the last install of the dependencies, relative to
+// the directory of the filter (see nodeDependenciesHash).
+const nodeInstallHashPath = "node_modules/.regolith-install-hash"
+
 type NodeJSFilterDefinition struct {
 	FilterDefinition
 	Script string `json:"script,omitempty"`
+	// PackageManager is the package manager used for installing the
+	// dependencies of the filter ("npm", "pnpm" or "yarn"). If it's empty,
+	// the package manager from the user config is used.
+	PackageManager string `json:"packageManager,omitempty"`
+	// Persistent enables running the script as a persistent process (see
+	// RunPersistentSubProcess).
+	Persistent bool `json:"persistent,omitempty"`
 }
 
 type NodeJSFilter struct {
@@ -31,18 +62,59 @@ func NodeJSFilterDefinitionFromObject(id string, obj map[string]interface{}) (*N
 			jsonPropertyTypeError, "script", "string")
 	}
 	filter.Script = script
+	// PackageManager - can be empty
+	if packageManagerObj, ok := obj["packageManager"]; ok {
+		packageManager, ok := packageManagerObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "packageManager", "string")
+		}
+		err := checkNodePackageManager(packageManager)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		filter.PackageManager = packageManager
+	}
+	filter.Persistent, _ = obj["persistent"].(bool)
+	protocol, err := filterProtocolFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Protocol = protocol
 	return filter, nil
 }
 
 func (f *NodeJSFilter) run(context RunContext) error {
 	// Run filter
-	if len(f.Settings) == 0 {
-		err := RunSubProcess(
-			"node",
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	if f.Definition.Persistent || f.Definition.Protocol == stdioFilterProtocol {
+		runSubProcess := RunProtocolSubProcess
+		if f.Definition.Persistent {
+			runSubProcess = RunPersistentSubProcess
+		}
+		err := runSubProcess(
+			context, "node",
+			[]string{
+				context.AbsoluteLocation + string(os.PathSeparator) +
+					f.Definition.Script},
+			context.AbsoluteLocation,
+			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
+			ShortFilterName(f.Id), settings, arguments)
+		if err != nil {
+			return PassError(err)
+		}
+		return nil
+	}
+	if len(settings) == 0 {
+		err := runFilterSubProcess(
+			context, "node",
 			append([]string{
 				context.AbsoluteLocation + string(os.PathSeparator) +
 					f.Definition.Script},
-				f.Arguments...,
+				arguments...,
 			),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
@@ -52,13 +124,13 @@ func (f *NodeJSFilter) run(context RunContext) error {
 			return PassError(err)
 		}
 	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
-		err := RunSubProcess(
-			"node",
+		jsonSettings, _ := json.Marshal(settings)
+		err := runFilterSubProcess(
+			context, "node",
 			append([]string{
 				context.AbsoluteLocation + string(os.PathSeparator) +
 					f.Definition.Script,
-				string(jsonSettings)}, f.Arguments...),
+				string(jsonSettings)}, arguments...),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
 			ShortFilterName(f.Id),
@@ -104,12 +176,36 @@ func (f *NodeJSFilterDefinition) InstallDependencies(parent *RemoteFilterDefinit
 
 	filterPath := filepath.Dir(scriptPath)
 	if hasPackageJson(filterPath) {
-		Logger.Info("Installing npm dependencies...")
-		err := RunSubProcess("npm", []string{"i", "--no-fund", "--no-audit"}, filterPath, filterPath, ShortFilterName(f.Id))
+		packageManager, err := f.packageManager()
+		if err != nil {
+			return PassError(err)
+		}
+		// Skip the install if the dependencies didn't change
+		hashPath := filepath.Join(filterPath, nodeInstallHashPath)
+		installedHash, err := os.ReadFile(hashPath)
+		if err == nil &&
+			string(installedHash) == nodeDependenciesHash(filterPath, packageManager) {
+			Logger.Infof(
+				"Dependencies for %s didn't change since the last install.",
+				f.Id)
+			return nil
+		}
+		Logger.Infof("Installing %s dependencies...", packageManager)
+		err = RunSubProcess(
+			packageManager, nodePackageManagers[packageManager], filterPath,
+			filterPath, ShortFilterName(f.Id))
 		if err != nil {
 			return WrapErrorf(
-				err, "Failed to run npm and install dependencies."+
-					"\nFilter name: %s", f.Id)
+				err, "Failed to run %s and install dependencies."+
+					"\nFilter name: %s", packageManager, f.Id)
+		}
+		// The hash is calculated after the install, because the package
+		// managers can create or update the lock files
+		err = os.WriteFile(
+			hashPath, []byte(nodeDependenciesHash(filterPath, packageManager)),
+			0644)
+		if err != nil {
+			Logger.Warnf("%s", WrapErrorf(err, fileWriteError, hashPath))
 		}
 	}
 	Logger.Infof("Dependencies for %s installed successfully", f.Id)
@@ -140,3 +236,57 @@ func hasPackageJson(filterPath string) bool {
 	_, err := os.Stat(path.Join(filterPath, "package.json"))
 	return err == nil
 }
+
+// packageManager returns the package manager used for installing the
+// dependencies of the filter. The package manager from the filter definition
+// has priority over the one from the user config. The default package
+// manager is npm.
+func (f *NodeJSFilterDefinition) packageManager() (string, error) {
+	if f.PackageManager != "" {
+		return f.PackageManager, nil
+	}
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		return "", WrapError(err, "Failed to load the user config.")
+	}
+	if userConfig.NodePackageManager != "" {
+		return userConfig.NodePackageManager, nil
+	}
+	return "npm", nil
+}
+
+// checkNodePackageManager returns an error if the package manager isn't one
+// of the nodePackageManagers.
+func checkNodePackageManager(packageManager string) error {
+	if _, ok := nodePackageManagers[packageManager]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(nodePackageManagers))
+	for name := range nodePackageManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return WrappedErrorf(
+		"Unknown Node.js package manager.\nPackage manager: %s\n"+
+			"Supported package managers: %s",
+		packageManager, strings.Join(names, ", "))
+}
+
+// nodeDependenciesHash returns the hash of the nodeDependencyFiles of the
+// filter and the name of the package manager that installs them. The files
+// that don't exist are skipped.
+func nodeDependenciesHash(filterPath, packageManager string) string {
+	hash := sha256.New()
+	hash.Write([]byte(packageManager + "\x00"))
+	for _, name := range nodeDependencyFiles {
+		data, err := os.ReadFile(filepath.Join(filterPath, name))
+		if err != nil {
+			continue
+		}
+		// The null bytes separate the names and the contents of the files
+		hash.Write([]byte(name + "\x00"))
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+	return "sha256-" + hex.EncodeToString(hash.Sum(nil))
+}
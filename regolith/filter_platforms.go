@@ -0,0 +1,50 @@
+package regolith
+
+import (
+	"runtime"
+)
+
+// applyPlatformOverrides returns the filter definition or the filter from a
+// profile with the properties from its "platforms" property that match the
+// current platform merged over it. The keys of "platforms" use the same
+// "<os>" and "<os>-<arch>" format as the "exe" property of the exe filters
+// (see ExeFilterDefinition). The "<os>" properties are applied first, so the
+// "<os>-<arch>" properties override them. The result doesn't have the
+// "platforms" property, and the object is returned unchanged if it doesn't
+// have it.
+func applyPlatformOverrides(
+	obj map[string]interface{},
+) (map[string]interface{}, error) {
+	platformsObj, ok := obj["platforms"]
+	if !ok {
+		return obj, nil
+	}
+	platforms, ok := platformsObj.(map[string]interface{})
+	if !ok {
+		return nil, WrappedErrorf(
+			jsonPropertyTypeError, "platforms", "object")
+	}
+	result := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		if key != "platforms" {
+			result[key] = value
+		}
+	}
+	for _, platform := range []string{
+		runtime.GOOS, runtime.GOOS + "-" + runtime.GOARCH,
+	} {
+		overrideObj, ok := platforms[platform]
+		if !ok {
+			continue
+		}
+		override, ok := overrideObj.(map[string]interface{})
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "platforms->"+platform, "object")
+		}
+		for key, value := range override {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,274 @@
+package regolith
+
+import (
+	"context"
+	"crypto/sha1"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// filterTimeoutKillGracePeriod is the time that the filter has to finish
+// after its sub-processes are killed due to a timeout. If the filter doesn't
+// finish in this time (for example because it doesn't use sub-processes),
+// Regolith stops waiting for it.
+const filterTimeoutKillGracePeriod = 5 * time.Second
+
+// subProcessOwner is a single execution of a filter, which owns the
+// sub-processes started by it (see RunContext.processOwner). The same filter
+// can run multiple times at once, for example in the nested profiles used by
+// the parallel filters, so the processes are killed by the owner instead of
+// the ID of the filter. The parent is the execution of the filter that runs
+// the nested profile of this execution.
+type subProcessOwner struct {
+	parent *subProcessOwner
+	// killed is closed when the processes of the owner are killed, which
+	// also stops the filters that run inside of Regolith (see
+	// subProcessOwner.context).
+	killed     chan struct{}
+	killedOnce sync.Once
+}
+
+// newSubProcessOwner creates a new execution of a filter with the parent
+// execution, nil outside of the nested profiles.
+func newSubProcessOwner(parent *subProcessOwner) *subProcessOwner {
+	return &subProcessOwner{parent: parent, killed: make(chan struct{})}
+}
+
+// context returns a context that is cancelled when the processes of the
+// owner or of one of its parents are killed (see killRunningSubProcesses).
+// It's used by the filters that don't use sub-processes, like the Lua
+// filters. The cancel function must be called after the filter finishes.
+func (o *subProcessOwner) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	for owner := o; owner != nil; owner = owner.parent {
+		go func(killed chan struct{}) {
+			select {
+			case <-killed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}(owner.killed)
+	}
+	return ctx, cancel
+}
+
+// owns returns true if the sub-processes of the owner belong to the other
+// owner, because it's the same owner or one of its parents.
+func (o *subProcessOwner) owns(other *subProcessOwner) bool {
+	for ; other != nil; other = other.parent {
+		if o == other {
+			return true
+		}
+	}
+	return false
+}
+
+// runningSubProcesses is a registry of the sub-processes that are currently
+// running a filter. The values are the executions of the filters that
+// started the processes, nil for the processes that don't belong to a filter
+// with a timeout. It's used for killing the processes of the filters that
+// exceed their timeout.
+var runningSubProcesses = make(map[*exec.Cmd]*subProcessOwner)
+var runningSubProcessesMutex sync.Mutex
+
+// startTrackedSubProcess starts the command in a new process group and adds
+// it to the runningSubProcesses registry. The owner is the execution of the
+// filter that starts the process. The process must be removed from the
+// registry with untrackSubProcess after it finishes.
+//
+// The processes in their own groups don't get the signals sent by the
+// terminal (like Ctrl+C), so while they're in the registry, the signals
+// that stop Regolith are sent to them as well (see forwardStopSignals).
+func startTrackedSubProcess(cmd *exec.Cmd, owner *subProcessOwner) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	trackSubProcess(cmd, owner)
+	return nil
+}
+
+func trackSubProcess(cmd *exec.Cmd, owner *subProcessOwner) {
+	runningSubProcessesMutex.Lock()
+	if len(runningSubProcesses) == 0 {
+		forwardStopSignals()
+	}
+	runningSubProcesses[cmd] = owner
+	runningSubProcessesMutex.Unlock()
+}
+
+func untrackSubProcess(cmd *exec.Cmd) {
+	runningSubProcessesMutex.Lock()
+	delete(runningSubProcesses, cmd)
+	if len(runningSubProcesses) == 0 {
+		stopForwardingStopSignals()
+	}
+	runningSubProcessesMutex.Unlock()
+}
+
+// killRunningSubProcesses kills the running sub-processes of an execution of
+// a filter, including the processes of the filters of its nested profiles,
+// together with their child processes.
+func killRunningSubProcesses(owner *subProcessOwner) {
+	owner.killedOnce.Do(func() { close(owner.killed) })
+	runningSubProcessesMutex.Lock()
+	defer runningSubProcessesMutex.Unlock()
+	for cmd, processOwner := range runningSubProcesses {
+		if !owner.owns(processOwner) {
+			continue
+		}
+		Logger.Debugf("Killing process tree of: %s", cmd.String())
+		if err := killProcessTree(cmd); err != nil {
+			Logger.Warnf("Failed to kill process %d: %s", cmd.Process.Pid, err)
+		}
+		delete(runningSubProcesses, cmd)
+	}
+	if len(runningSubProcesses) == 0 {
+		stopForwardingStopSignals()
+	}
+}
+
+// filterRetriesPath is the path to the directory with the manifests of the
+// content of the tmp directory saved before running the filters that use
+// the "retries" property, relative to the .regolith directory. Like the
+// checkpoints of the watch mode, the files are stored in the objects of the
+// build cache.
+const filterRetriesPath = buildCachePath + "/retries"
+
+// RunFilterWithPolicy runs the filter, enforcing its "timeout" and "retries"
+// settings. If the filter fails, the tmp directory is restored to its
+// content from before the first attempt, and the filter is rerun up to
+// "retries" times. If it doesn't finish before the timeout, its
+// sub-processes are killed and the run is treated as a failure. A filter
+// that still runs after killing its processes isn't retried, because it
+// could change the files of the next attempt.
+func RunFilterWithPolicy(
+	filter FilterRunner, context RunContext,
+) (bool, error) {
+	retries := filter.GetRetries()
+	if retries <= 0 {
+		interrupted, _, err := runFilterWithTimeout(filter, context)
+		return interrupted, err
+	}
+	// The name of the manifest is unique, because the filters of the nested
+	// profiles can use retries as well
+	retriesPath := filepath.Join(context.DotRegolithPath, filterRetriesPath)
+	err := os.MkdirAll(retriesPath, 0755)
+	if err != nil {
+		return false, WrapErrorf(err, osMkdirError, retriesPath)
+	}
+	manifestFile, err := os.CreateTemp(
+		retriesPath, filterCacheDirName(filter.GetId())+"-*.json")
+	if err != nil {
+		return false, WrapErrorf(err, osCreateError, retriesPath)
+	}
+	manifestPath := manifestFile.Name()
+	manifestFile.Close()
+	defer os.Remove(manifestPath)
+	workingDir := GetAbsoluteWorkingDirectory(context.DotRegolithPath)
+	err = saveFilterOutput(context.DotRegolithPath, workingDir, manifestPath)
+	if err != nil {
+		return false, WrapError(
+			err, "Failed to save the content of the tmp directory for the "+
+				"retries of the filter.")
+	}
+	for attempt := 0; ; attempt++ {
+		interrupted, running, err := runFilterWithTimeout(filter, context)
+		if err == nil || attempt >= retries {
+			return interrupted, err
+		}
+		if running {
+			return interrupted, WrapError(
+				err, "The filter can't be retried, because it didn't stop.")
+		}
+		Logger.Warnf(
+			"Filter \"%s\" failed, retrying (%d/%d):\n%s",
+			filter.GetId(), attempt+1, retries, PassError(err).Error())
+		if err := restoreTmpForRetry(manifestPath, context); err != nil {
+			return false, WrapError(
+				err, "Failed to restore the content of the tmp directory "+
+					"for the retry of the filter.")
+		}
+	}
+}
+
+// restoreTmpForRetry changes the content of the tmp directory back to the
+// one saved in the manifest before the first attempt of the filter (see
+// RunFilterWithPolicy).
+func restoreTmpForRetry(manifestPath string, context RunContext) error {
+	manifest, err := loadFilterOutputManifest(manifestPath)
+	if err != nil {
+		return PassError(err)
+	}
+	workingDir := GetAbsoluteWorkingDirectory(context.DotRegolithPath)
+	current := make(filterOutputManifest)
+	fileHash := sha1.New() // The same hash as the objects of the build cache
+	for _, dir := range filterCacheDirs(workingDir) {
+		state, err := writeStateToHash(
+			io.Discard, filepath.Join(workingDir, dir), fileHash)
+		if err != nil {
+			return PassError(err)
+		}
+		current[dir] = state
+	}
+	return restoreFilterOutput(
+		context.DotRegolithPath, manifest, current, workingDir)
+}
+
+// usesFilterRetries returns true if any of the filters uses the "retries"
+// property.
+func usesFilterRetries(filters []FilterRunner) bool {
+	for _, filter := range filters {
+		if filter.GetRetries() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runFilterWithTimeout runs the filter and stops it if it doesn't finish in
+// the time specified in its "timeout" setting. The sub-processes started by
+// this execution of the filter are killed, but not the processes of the
+// other executions of the same filter. The second value is true if the
+// filter still runs after killing its processes.
+func runFilterWithTimeout(
+	filter FilterRunner, context RunContext,
+) (bool, bool, error) {
+	timeout := filter.GetTimeout()
+	if timeout <= 0 {
+		interrupted, err := filter.Run(context)
+		return interrupted, false, err
+	}
+	owner := newSubProcessOwner(context.processOwner)
+	context.processOwner = owner
+	type runResult struct {
+		interrupted bool
+		err         error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		interrupted, err := filter.Run(context)
+		done <- runResult{interrupted, err}
+	}()
+	select {
+	case result := <-done:
+		return result.interrupted, false, result.err
+	case <-time.After(timeout):
+	}
+	killRunningSubProcesses(owner)
+	running := false
+	select {
+	case <-done:
+	case <-time.After(filterTimeoutKillGracePeriod):
+		Logger.Warnf(
+			"Filter \"%s\" didn't stop after killing its processes.",
+			filter.GetId())
+		running = true
+	}
+	return false, running, WrappedErrorf(
+		"The filter didn't finish in the time limit.\nTimeout: %s", timeout)
+}
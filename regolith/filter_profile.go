@@ -14,6 +14,9 @@ func (f *ProfileFilter) Run(context RunContext) (bool, error) {
 		Parent:              &context,
 		interruptionChannel: context.interruptionChannel,
 		DotRegolithPath:     context.DotRegolithPath,
+		Defines:             context.Defines,
+		done:                context.done,
+		processOwner:        context.processOwner,
 	})
 }
 
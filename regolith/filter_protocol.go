@@ -0,0 +1,281 @@
+package regolith
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// The filters that opt into the "stdio" protocol communicate with Regolith
+// using JSON objects sent over their standard input and output (one object
+// per line).
+//
+// Regolith sends a run request to the stdin of the filter:
+//
+//	{
+//		"type": "run",
+//		"settings": {...},
+//		"arguments": [...],
+//		"changedFiles": [...],
+//		"workingDir": "..."
+//	}
+//
+// The filter responds with any number of log and diagnostic messages
+// followed by a single "done" or "error" message:
+//
+//	{"type": "log", "level": "info", "message": "..."}
+//	{"type": "diagnostic", "severity": "error", "message": "...",
+//		"file": "BP/entities/x.json", "line": 1, "column": 2}
+//	{"type": "done"}
+//	{"type": "error", "message": "..."}
+//
+// The lines from stdout that aren't valid messages are printed to the log,
+// just like the output of the filters that don't use the protocol.
+const (
+	// stdioFilterProtocol is the value of the "protocol" property of filter
+	// definition, used to enable the protocol.
+	stdioFilterProtocol = "stdio"
+
+	// maxFilterProtocolMessageSize is the maximal size of a line of the
+	// output of a filter that uses the protocol. The diagnostics and the
+	// logs can include large parts of the files, so it's much larger than
+	// the default limit of bufio.Scanner.
+	maxFilterProtocolMessageSize = 16 * 1024 * 1024
+)
+
+// filterProtocolRequest is a run request sent to the filter.
+type filterProtocolRequest struct {
+	Type      string                 `json:"type"`
+	Settings  map[string]interface{} `json:"settings"`
+	Arguments []string               `json:"arguments"`
+	// ChangedFiles is a list of the files changed since the previous run,
+	// relative to the working directory. It's nil if Regolith doesn't know
+	// which files changed, in which case the filter should process all of
+	// the files.
+	ChangedFiles []string `json:"changedFiles"`
+	// WorkingDir is the absolute path to the working directory of the
+	// filter (the tmp directory). The persistent processes don't run in it,
+	// because it's removed between the runs (see RunPersistentSubProcess).
+	WorkingDir string `json:"workingDir"`
+}
+
+// filterProtocolMessage is a message sent by the filter. Not all of the
+// fields are used by every message type.
+type filterProtocolMessage struct {
+	Type     string `json:"type"`
+	Level    string `json:"level,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// FilterDiagnostic is a problem reported by a filter that uses the "stdio"
+// protocol, optionally pointing at a location in one of the files.
+type FilterDiagnostic struct {
+	Filter   string `json:"filter"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// File is the path to the file. The files from the tmp directory are
+	// mapped back to their source files (see tmpSourceMap.sourcePath).
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// String returns the diagnostic in the "file:line:column: message" format.
+func (d FilterDiagnostic) String() string {
+	location := d.File
+	if location == "" {
+		return d.Message
+	}
+	if d.Line > 0 {
+		location += fmt.Sprintf(":%d", d.Line)
+		if d.Column > 0 {
+			location += fmt.Sprintf(":%d", d.Column)
+		}
+	}
+	return location + ": " + d.Message
+}
+
+// filterProtocolFromObject reads the optional "protocol" property of a filter
+// definition.
+func filterProtocolFromObject(obj map[string]interface{}) (string, error) {
+	protocolObj, ok := obj["protocol"]
+	if !ok {
+		return "", nil
+	}
+	protocol, ok := protocolObj.(string)
+	if !ok {
+		return "", WrappedErrorf(jsonPropertyTypeError, "protocol", "string")
+	}
+	if protocol != stdioFilterProtocol {
+		return "", WrappedErrorf(
+			"Unknown filter protocol.\nProtocol: %s\nValid values: %s",
+			protocol, stdioFilterProtocol)
+	}
+	return protocol, nil
+}
+
+// RunProtocolSubProcess runs a sub-process of the filter that runs in the
+// context, that uses the "stdio" protocol. It sends a run request with the
+// settings and arguments to the process and handles the messages sent back
+// until the process exits. The command, args, filterDir, workingDir and
+// outputLabel have the same meaning as in RunSubProcess.
+func RunProtocolSubProcess(
+	context RunContext,
+	command string, args []string, filterDir, workingDir, outputLabel string,
+	settings map[string]interface{}, arguments []string,
+) error {
+	Logger.Debugf(
+		"Exec: %s %s", command, hideSecrets(strings.Join(args, " ")))
+	cmd := exec.Command(command, args...)
+	cmd.Dir = workingDir
+	env, err := CreateEnvironmentVariables(filterDir)
+	if err != nil {
+		return WrapErrorf(
+			err,
+			"Failed to create FILTER_DIR and ROOT_DIR environment variables.")
+	}
+	cmd.Env = append(env, "REGOLITH_PROTOCOL="+stdioFilterProtocol)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return WrapError(err, "Failed to open stdin of the process.")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return WrapError(err, "Failed to open stdout of the process.")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return WrapError(err, "Failed to open stderr of the process.")
+	}
+	go LogStd(stderr, outputLogger(outputLabel).Errorf, outputLabel)
+	if err := startTrackedSubProcess(cmd, context.processOwner); err != nil {
+		return WrapErrorf(err, execCommandError, command)
+	}
+	defer untrackSubProcess(cmd)
+	runErr := sendFilterProtocolRequest(
+		stdin, newFilterProtocolScanner(stdout), outputLabel, workingDir, settings,
+		arguments)
+	stdin.Close()
+	// Drain the output, so the process doesn't block on writing
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	if runErr != nil {
+		return PassError(runErr)
+	}
+	if err != nil {
+		return WrapError(err, runSubProcessError)
+	}
+	return nil
+}
+
+// newFilterProtocolScanner creates a scanner of the lines of the output of a
+// filter that uses the protocol, limited to maxFilterProtocolMessageSize.
+func newFilterProtocolScanner(stdout io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxFilterProtocolMessageSize)
+	return scanner
+}
+
+// sendFilterProtocolRequest sends a run request to the stdin of a process and
+// handles the messages from its stdout until the run is finished.
+func sendFilterProtocolRequest(
+	stdin io.Writer, stdout *bufio.Scanner, outputLabel, workingDir string,
+	settings map[string]interface{}, arguments []string,
+) error {
+	request, _ := json.Marshal(filterProtocolRequest{
+		Type:         "run",
+		Settings:     settings,
+		Arguments:    arguments,
+		ChangedFiles: changedFiles,
+		WorkingDir:   workingDir,
+	})
+	_, err := stdin.Write(append(request, '\n'))
+	if err != nil {
+		return WrapError(err, "Failed to send run request to the process.")
+	}
+	// The output is printed with the label of the filter and captured for
+	// the reports of the run
+	output := func(level, text string) {
+		captureFilterOutput(outputLabel, text)
+		logFilterProtocolMessage(level, "[%s] %s", outputLabel, text)
+	}
+	errorDiagnostics := 0
+	for stdout.Scan() {
+		line := stdout.Text()
+		var message filterProtocolMessage
+		if json.Unmarshal([]byte(line), &message) != nil {
+			output("info", mapTmpPaths(line))
+			continue
+		}
+		switch message.Type {
+		case "done":
+			if errorDiagnostics > 0 {
+				return WrappedErrorf(
+					"The filter reported %d error(s).", errorDiagnostics)
+			}
+			return nil
+		case "error":
+			return WrappedErrorf(
+				"The filter reported an error.\nMessage: %s",
+				mapTmpPaths(message.Message))
+		case "log":
+			output(message.Level, mapTmpPaths(message.Message))
+		case "diagnostic":
+			diagnostic := FilterDiagnostic{
+				Filter:   outputLabel,
+				Severity: message.Severity,
+				Message:  mapTmpPaths(message.Message),
+				File:     message.File,
+				Line:     message.Line,
+				Column:   message.Column,
+			}
+			if m := currentTmpSources(); m != nil && diagnostic.File != "" {
+				// The file is relative to the working directory of the
+				// filter, the tmp directory
+				diagnostic.File = m.sourcePath(diagnostic.File)
+			}
+			if diagnostic.Severity == "" {
+				diagnostic.Severity = "error"
+			}
+			if diagnostic.Severity == "error" {
+				errorDiagnostics++
+			}
+			recordDiagnostic(diagnostic)
+			output(diagnostic.Severity, diagnostic.String())
+		default:
+			output("info", mapTmpPaths(line))
+		}
+	}
+	if err := stdout.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return WrappedErrorf(
+				"A line of the output of the process is longer than the "+
+					"limit of %d bytes.", maxFilterProtocolMessageSize)
+		}
+		return WrapError(err, "Failed to read the output of the process.")
+	}
+	return WrappedError(
+		"The filter process exited without finishing the run.")
+}
+
+// logFilterProtocolMessage logs a message with the logger function that
+// matches the level (or severity) sent by the filter.
+func logFilterProtocolMessage(level, template string, args ...interface{}) {
+	switch level {
+	case "debug":
+		Logger.Debugf(template, args...)
+	case "warn", "warning":
+		Logger.Warnf(template, args...)
+	case "error":
+		Logger.Errorf(template, args...)
+	default:
+		Logger.Infof(template, args...)
+	}
+}
@@ -7,12 +7,37 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// filterVenvsPath is the path to the directory with the venvs of the Python
+// filters that don't use the "venvSlot" property, relative to the .regolith
+// directory. Every filter has its own venv, named after the filter, so the
+// filters with conflicting requirements don't break each other. The
+// subfilters of a remote filter share the venv of the remote filter.
+const filterVenvsPath = "cache/filter-venvs"
+
+// venvMutexes prevent installing the dependencies of multiple filters into the
+// same venv at the same time, when the filters are installed in parallel. The
+// keys are the paths to the venvs.
+var venvMutexes = make(map[string]*sync.Mutex)
+var venvMutexesMutex sync.Mutex
+
 type PythonFilterDefinition struct {
 	FilterDefinition
 	Script   string `json:"script,omitempty"`
 	VenvSlot int    `json:"venvSlot,omitempty"`
+	// sharedVenv is true if the "venvSlot" property is set. Such filters
+	// share the venv of the slot with the other filters that use it, instead
+	// of using their own venvs.
+	sharedVenv bool
+	// Persistent enables running the script as a persistent process (see
+	// RunPersistentSubProcess).
+	Persistent bool `json:"persistent,omitempty"`
+	// PythonVersion is the range of the Python versions required by the
+	// filter (see parsePythonVersionRange). If it's empty, any version is
+	// accepted.
+	PythonVersion string `json:"pythonVersion,omitempty"`
 }
 
 type PythonFilter struct {
@@ -31,13 +56,40 @@ func PythonFilterDefinitionFromObject(id string, obj map[string]interface{}) (*P
 		return nil, WrappedErrorf(jsonPropertyTypeError, "script", "string")
 	}
 	filter.Script = script
-	filter.VenvSlot, _ = obj["venvSlot"].(int) // default venvSlot is 0
+	// VenvSlot - can be empty, the filter has its own venv
+	venvSlot, sharedVenv, err := venvSlotFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.VenvSlot, filter.sharedVenv = venvSlot, sharedVenv
+	filter.Persistent, _ = obj["persistent"].(bool)
+	// PythonVersion - can be empty
+	if pythonVersionObj, ok := obj["pythonVersion"]; ok {
+		pythonVersion, ok := pythonVersionObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "pythonVersion", "string")
+		}
+		if _, err := parsePythonVersionRange(pythonVersion); err != nil {
+			return nil, PassError(err)
+		}
+		filter.PythonVersion = pythonVersion
+	}
+	protocol, err := filterProtocolFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	filter.Protocol = protocol
 	return filter, nil
 }
 
 func (f *PythonFilter) run(context RunContext) error {
 	// Run filter
-	pythonCommand, err := findPython()
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return PassError(err)
+	}
+	pythonCommand, err := f.Definition.pythonCommand(context.DotRegolithPath)
 	if err != nil {
 		return PassError(err)
 	}
@@ -50,19 +102,40 @@ func (f *PythonFilter) run(context RunContext) error {
 		Logger.Debug("Running Python filter using venv: ", venvPath)
 		pythonCommand = filepath.Join(
 			venvPath, venvScriptsPath, "python"+exeSuffix)
+		if _, err := os.Stat(pythonCommand); err != nil {
+			return WrappedErrorf(
+				"The venv of the filter doesn't exist.\nVenv: %s\n"+
+					"You can install the dependencies of the filter using "+
+					"command:\nregolith install-all", venvPath)
+		}
+	}
+	if f.Definition.Persistent || f.Definition.Protocol == stdioFilterProtocol {
+		runSubProcess := RunProtocolSubProcess
+		if f.Definition.Persistent {
+			runSubProcess = RunPersistentSubProcess
+		}
+		err = runSubProcess(
+			context, pythonCommand, []string{"-u", scriptPath},
+			context.AbsoluteLocation,
+			GetAbsoluteWorkingDirectory(context.DotRegolithPath),
+			ShortFilterName(f.Id), settings, arguments)
+		if err != nil {
+			return WrapError(err, "Failed to run Python script.")
+		}
+		return nil
 	}
 	var args []string
-	if len(f.Settings) == 0 {
-		args = append([]string{"-u", scriptPath}, f.Arguments...)
+	if len(settings) == 0 {
+		args = append([]string{"-u", scriptPath}, arguments...)
 	} else {
-		jsonSettings, _ := json.Marshal(f.Settings)
+		jsonSettings, _ := json.Marshal(settings)
 		args = append(
 			[]string{"-u", scriptPath, string(jsonSettings)},
-			f.Arguments...,
+			arguments...,
 		)
 	}
-	err = RunSubProcess(
-		pythonCommand, args, context.AbsoluteLocation,
+	err = runFilterSubProcess(
+		context, pythonCommand, args, context.AbsoluteLocation,
 		GetAbsoluteWorkingDirectory(context.DotRegolithPath),
 		ShortFilterName(f.Id))
 	if err != nil {
@@ -97,6 +170,9 @@ func (f *PythonFilterDefinition) InstallDependencies(
 	// Install dependencies
 	if parent != nil {
 		installLocation = parent.GetDownloadPath(dotRegolithPath)
+		// The subfilters use the venv of their parent, just like when
+		// they're running (see CopyArguments)
+		f.VenvSlot, f.sharedVenv = parent.VenvSlot, parent.sharedVenv
 	}
 	Logger.Infof("Downloading dependencies for %s...", f.Id)
 	joinedPath := filepath.Join(installLocation, f.Script)
@@ -105,6 +181,20 @@ func (f *PythonFilterDefinition) InstallDependencies(
 		return WrapErrorf(err, filepathAbsError, joinedPath)
 	}
 
+	// Find the interpreter that matches the required Python version and
+	// remember it for running the filter
+	pythonCommand := ""
+	if f.PythonVersion != "" {
+		pythonCommand, err = findMatchingPython(f.PythonVersion, f.Id)
+		if err != nil {
+			return PassError(err)
+		}
+		err = recordPythonInterpreter(dotRegolithPath, f.Id, pythonCommand)
+		if err != nil {
+			return PassError(err)
+		}
+	}
+
 	// Install the filter dependencies
 	filterPath := filepath.Dir(scriptPath)
 	if needsVenv(filterPath) {
@@ -112,10 +202,30 @@ func (f *PythonFilterDefinition) InstallDependencies(
 		if err != nil {
 			return WrapError(err, "Failed to resolve venv path.")
 		}
+		unlock := lockVenv(venvPath)
+		defer unlock()
+		// The venv created by an interpreter with a wrong version must be
+		// created again
+		venvPythonCommand := filepath.Join(
+			venvPath, venvScriptsPath, "python"+exeSuffix)
+		if f.PythonVersion != "" {
+			versions, _ := parsePythonVersionRange(f.PythonVersion) // checked
+			if _, err := os.Stat(venvPythonCommand); err == nil &&
+				!versions.Contains(pythonVersion(venvPythonCommand)) {
+				Logger.Infof(
+					"The venv uses a wrong version of Python, removing it.")
+				err = os.RemoveAll(venvPath)
+				if err != nil {
+					return WrapErrorf(err, osRemoveError, venvPath)
+				}
+			}
+		}
 		Logger.Info("Creating venv...")
-		pythonCommand, err := findPython()
-		if err != nil {
-			return PassError(err)
+		if pythonCommand == "" {
+			pythonCommand, err = findPython()
+			if err != nil {
+				return PassError(err)
+			}
 		}
 		// Create the "venv"
 		err = RunSubProcess(
@@ -124,8 +234,6 @@ func (f *PythonFilterDefinition) InstallDependencies(
 			return WrapError(err, "Failed to create venv.")
 		}
 		// Update pip of the venv
-		venvPythonCommand := filepath.Join(
-			venvPath, venvScriptsPath, "python"+exeSuffix)
 		err = RunSubProcess(
 			venvPythonCommand,
 			[]string{"-m", "pip", "install", "--upgrade", "pip"},
@@ -149,7 +257,7 @@ func (f *PythonFilterDefinition) InstallDependencies(
 }
 
 func (f *PythonFilterDefinition) Check(context RunContext) error {
-	pythonCommand, err := findPython()
+	pythonCommand, err := f.pythonCommand(context.DotRegolithPath)
 	if err != nil {
 		return PassError(err)
 	}
@@ -170,11 +278,42 @@ func (f *PythonFilter) CopyArguments(parent *RemoteFilter) {
 	f.Arguments = append(f.Arguments, parent.Arguments...)
 	f.Settings = parent.Settings
 	f.Definition.VenvSlot = parent.Definition.VenvSlot
+	f.Definition.sharedVenv = parent.Definition.sharedVenv
+}
+
+// pythonCommand returns the Python interpreter that runs the filter (outside
+// of its venv). The filters with the "pythonVersion" property use the
+// interpreter selected while installing them, if it still matches the
+// version, or the newest matching interpreter.
+func (f *PythonFilterDefinition) pythonCommand(dotRegolithPath string) (string, error) {
+	if f.PythonVersion == "" {
+		return findPython()
+	}
+	versions, err := parsePythonVersionRange(f.PythonVersion)
+	if err != nil {
+		return "", PassError(err)
+	}
+	interpreters, err := loadPythonInterpreters(dotRegolithPath)
+	if err != nil {
+		Logger.Debugf("Unable to load the Python interpreters: %s", err)
+	} else if command, ok := interpreters[f.Id]; ok &&
+		versions.Contains(pythonVersion(command)) {
+		return command, nil
+	}
+	return findMatchingPython(f.PythonVersion, f.Id)
 }
 
+// resolveVenvPath returns the path to the venv of the filter. The filters
+// with the "venvSlot" property use the venv of the slot, and the other ones
+// use their own venvs (see filterVenvsPath).
 func (f *PythonFilterDefinition) resolveVenvPath(dotRegolithPath string) (string, error) {
-	resolvedPath, err := filepath.Abs(
-		filepath.Join(filepath.Join(dotRegolithPath, "cache/venvs"), strconv.Itoa(f.VenvSlot)))
+	venvPath := filepath.Join(
+		dotRegolithPath, filterVenvsPath, ShortFilterName(f.Id))
+	if f.sharedVenv {
+		venvPath = filepath.Join(
+			dotRegolithPath, "cache/venvs", strconv.Itoa(f.VenvSlot))
+	}
+	resolvedPath, err := filepath.Abs(venvPath)
 	if err != nil {
 		return "", WrapErrorf(
 			err, "Unable to create venv for VenvSlot %v.", f.VenvSlot)
@@ -182,6 +321,35 @@ func (f *PythonFilterDefinition) resolveVenvPath(dotRegolithPath string) (string
 	return resolvedPath, nil
 }
 
+// venvSlotFromObject returns the value of the optional "venvSlot" property
+// of a filter definition, and whether the property is set.
+func venvSlotFromObject(obj map[string]interface{}) (int, bool, error) {
+	venvSlotObj, ok := obj["venvSlot"]
+	if !ok {
+		return 0, false, nil
+	}
+	venvSlot, ok := venvSlotObj.(float64)
+	if !ok || venvSlot != float64(int(venvSlot)) {
+		return 0, false, WrappedErrorf(
+			jsonPropertyTypeError, "venvSlot", "integer")
+	}
+	return int(venvSlot), true, nil
+}
+
+// lockVenv waits until no other filter installs its dependencies into the
+// venv and returns the function that unlocks the venv.
+func lockVenv(venvPath string) func() {
+	venvMutexesMutex.Lock()
+	mutex, ok := venvMutexes[venvPath]
+	if !ok {
+		mutex = &sync.Mutex{}
+		venvMutexes[venvPath] = mutex
+	}
+	venvMutexesMutex.Unlock()
+	mutex.Lock()
+	return mutex.Unlock
+}
+
 func needsVenv(filterPath string) bool {
 	stats, err := os.Stat(filepath.Join(filterPath, "requirements.txt"))
 	if err == nil {
@@ -9,7 +9,6 @@ import (
 	"path"
 	"path/filepath"
 
-	"github.com/hashicorp/go-getter"
 	"github.com/otiai10/copy"
 )
 
@@ -20,6 +19,20 @@ type RemoteFilterDefinition struct {
 	// RemoteFilters can propagate some of the properties unique to other types
 	// of filers (like Python's venvSlot).
 	VenvSlot int `json:"venvSlot,omitempty"`
+	// sharedVenv is true if the "venvSlot" property is set (see
+	// PythonFilterDefinition).
+	sharedVenv bool
+	// Checksum is the checksum of the archive of the archive filters (see
+	// IsArchive), in the "<type>:<hex>" format.
+	Checksum string `json:"checksum,omitempty"`
+
+	// resolvedVersion is the exact version of the filter from the lock file.
+	// If it's set, it's downloaded instead of the Version.
+	resolvedVersion string
+	// urlFromRegistry is true if the definition doesn't have the URL, and the
+	// URL was resolved from the name of the filter with the filter
+	// registries.
+	urlFromRegistry bool
 }
 
 type RemoteFilter struct {
@@ -31,10 +44,34 @@ func RemoteFilterDefinitionFromObject(id string, obj map[string]interface{}) (*R
 	result := &RemoteFilterDefinition{FilterDefinition: *FilterDefinitionFromObject(id)}
 	url, ok := obj["url"].(string)
 	if !ok {
-		result.Url = StandardLibraryUrl
+		result.urlFromRegistry = true
+		result.resolveRegistryUrl()
 	} else {
 		result.Url = url
 	}
+	// VenvSlot - can be empty, the filter has its own venv
+	venvSlot, sharedVenv, err := venvSlotFromObject(obj)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	result.VenvSlot, result.sharedVenv = venvSlot, sharedVenv
+	if result.IsArchive() {
+		checksumObj, ok := obj["checksum"]
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyMissingError, "checksum")
+		}
+		checksum, ok := checksumObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyTypeError, "checksum", "string")
+		}
+		if err := checkArchiveChecksum(checksum); err != nil {
+			return nil, PassError(err)
+		}
+		result.Checksum = checksum
+		// The archive filters change only with their checksums
+		result.Version = checksum
+		return result, nil
+	}
 	versionObj, ok := obj["version"]
 	if !ok {
 		return nil, WrappedErrorf(jsonPropertyMissingError, "version")
@@ -44,10 +81,22 @@ func RemoteFilterDefinitionFromObject(id string, obj map[string]interface{}) (*R
 		return nil, WrappedErrorf(jsonPropertyTypeError, "version", "string")
 	}
 	result.Version = version
-	result.VenvSlot, _ = obj["venvSlot"].(int) // default venvSlot is 0
 	return result, nil
 }
 
+// resolveRegistryUrl sets the URL of the filter defined only by its name,
+// using the downloaded indices of the filter registries. If the filter isn't
+// in any of the registries, the standard library is used.
+func (f *RemoteFilterDefinition) resolveRegistryUrl() {
+	url, err := ResolveUrl(f.Id)
+	if err != nil {
+		Logger.Debugf(
+			"Using the standard library URL for the %q filter: %s", f.Id, err)
+		url = StandardLibraryUrl
+	}
+	f.Url = url
+}
+
 func (f *RemoteFilter) run(context RunContext) error {
 	Logger.Debugf("RunRemoteFilter \"%s\"", f.Definition.Url)
 	// All other filters require safe mode to be turned off
@@ -61,6 +110,11 @@ func (f *RemoteFilter) run(context RunContext) error {
 				"regolith install %s", f.Id)
 	}
 
+	if f.Definition.IsLinked(context.DotRegolithPath) {
+		// The linked filters don't have versions
+		Logger.Debugf("Running the linked %q filter.", f.Id)
+		return f.runSubfilters(context)
+	}
 	version, err := f.GetCachedVersion(context.DotRegolithPath)
 	if err != nil {
 		return WrapErrorf(
@@ -81,6 +135,12 @@ func (f *RemoteFilter) run(context RunContext) error {
 			*version, f.Definition.Version, f.Id)
 	}
 
+	return f.runSubfilters(context)
+}
+
+// runSubfilters runs the filters from the filter.json file of the remote
+// filter.
+func (f *RemoteFilter) runSubfilters(context RunContext) error {
 	path := f.GetDownloadPath(context.DotRegolithPath)
 	absolutePath, _ := filepath.Abs(path)
 	filterCollection, err := f.subfilterCollection(context.DotRegolithPath)
@@ -89,7 +149,13 @@ func (f *RemoteFilter) run(context RunContext) error {
 	}
 	for i, filter := range filterCollection.Filters {
 		// Disabled filters are skipped
-		if filter.IsDisabled() {
+		disabled, err := filter.IsDisabled(context)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to check if the filter is disabled.\nFilter: %s",
+				NiceSubfilterName(f.Id, i))
+		}
+		if disabled {
 			Logger.Infof(
 				"The %s subfilter of \"%s\" filter is disabled, skipping.",
 				nth(i), f.Id)
@@ -98,12 +164,14 @@ func (f *RemoteFilter) run(context RunContext) error {
 		// Overwrite the venvSlot with the parent value
 		// TODO - remote filters can contain multiple filters, the interruption
 		// chceck should be performed after every subfilter
-		_, err := filter.Run(RunContext{
+		_, err = filter.Run(RunContext{
 			Config:           context.Config,
 			AbsoluteLocation: absolutePath,
 			Profile:          context.Profile,
 			Parent:           context.Parent,
 			DotRegolithPath:  context.DotRegolithPath,
+			Defines:          context.Defines,
+			processOwner:     context.processOwner,
 		})
 		if err != nil {
 			return WrapErrorf(
@@ -144,7 +212,7 @@ func (f *RemoteFilterDefinition) InstallDependencies(_ *RemoteFilterDefinition,
 	}
 
 	var filterCollection map[string]interface{}
-	err = json.Unmarshal(file, &filterCollection)
+	err = unmarshalJsonc(file, &filterCollection)
 	if err != nil {
 		return WrapErrorf(err, jsonUnmarshalError, path)
 	}
@@ -167,6 +235,10 @@ func (f *RemoteFilterDefinition) InstallDependencies(_ *RemoteFilterDefinition,
 			return extraFilterJsonErrorInfo(
 				path, WrappedErrorf(jsonPathTypeError, jsonPath, "object"))
 		}
+		if isNestedRemoteFilter(filter) {
+			// The nested remote filters are installed by installFilters
+			continue
+		}
 		filterInstaller, err := FilterInstallerFromObject(
 			fmt.Sprintf("%v:subfilter%v", f.Id, i), filter)
 		if err != nil {
@@ -285,7 +357,7 @@ func (f *RemoteFilter) GetCachedVersion(dotRegolithPath string) (*string, error)
 	}
 
 	var filterCollection map[string]interface{}
-	err = json.Unmarshal(file, &filterCollection)
+	err = unmarshalJsonc(file, &filterCollection)
 	if err != nil {
 		return nil, WrapErrorf(err, jsonUnmarshalError, file)
 	}
@@ -311,8 +383,8 @@ func FilterDefinitionFromTheInternet(
 	if version == "" { // "" locks the version to the latest
 		version, err = GetRemoteFilterDownloadRef(url, name, version)
 		if err != nil {
-			return nil, WrappedErrorf(
-				getRemoteFilterDownloadRefError, url, name, version)
+			return nil, WrapErrorf(
+				err, getRemoteFilterDownloadRefError, url, name, version)
 		}
 		version = trimFilterPrefix(version, name)
 	}
@@ -342,28 +414,50 @@ func (i *RemoteFilterDefinition) Download(
 
 	Logger.Infof("Downloading filter %s...", i.Id)
 
+	if i.IsArchive() {
+		return i.downloadArchive(dotRegolithPath)
+	}
 	// Download the filter using Git Getter
 	if !hasGit() {
 		return WrappedError(gitNotInstalledWarning)
 	}
-	repoVersion, err := GetRemoteFilterDownloadRef(i.Url, i.Id, i.Version)
+	version := i.Version
+	if i.resolvedVersion != "" {
+		version = i.resolvedVersion
+	}
+	repoVersion, err := GetRemoteFilterDownloadRef(i.Url, i.Id, version)
 	if err != nil {
 		return WrapErrorf(
-			err, getRemoteFilterDownloadRefError, i.Url, i.Id, i.Version)
+			err, getRemoteFilterDownloadRefError, i.Url, i.Id, version)
+	}
+	// Use the filter installed by another project if possible
+	restored, err := i.restoreFromSharedCache(repoVersion, dotRegolithPath)
+	if err != nil {
+		Logger.Warnf("Failed to use the shared filter cache:\n%s", err)
+	} else if restored {
+		return nil
 	}
-	url := fmt.Sprintf("%s//%s?ref=%s", i.Url, i.Id, repoVersion)
 	downloadPath := i.GetDownloadPath(dotRegolithPath)
 
 	_, err = os.Stat(downloadPath)
 	downloadPathIsNew := os.IsNotExist(err)
-	err = getter.Get(downloadPath, url)
-	if err != nil {
-		if downloadPathIsNew { // Remove the path created by getter
-			os.Remove(downloadPath)
+	// Git must fail instead of asking for the credentials of private
+	// repositories in the terminal
+	if _, ok := os.LookupEnv("GIT_TERMINAL_PROMPT"); !ok {
+		os.Setenv("GIT_TERMINAL_PROMPT", "0")
+	}
+	err = tryMirrors(i.Url, func(repoUrl string) error {
+		err := fetchFilter(repoUrl, i.Id, repoVersion, downloadPath)
+		if err != nil && downloadPathIsNew {
+			// Remove the path created by the failed download
+			os.RemoveAll(downloadPath)
 		}
+		return err
+	})
+	if err != nil {
 		return WrapErrorf(
-			err, "Could not download filter from %s.\n"+
-				"Does that filter exist?", url)
+			err, "Could not download filter %q at %q.\n"+
+				"Does that filter exist?", i.Id, repoVersion)
 	}
 	// Save the version of the filter we downloaded
 	i.SaveVerssionInfo(trimFilterPrefix(repoVersion, i.Id), dotRegolithPath)
@@ -402,7 +496,7 @@ func (f *RemoteFilterDefinition) LoadFilterJson(dotRegolithPath string) (map[str
 	filterJsonPath := path.Join(downloadPath, "filter.json")
 	filterJson, err1 := ioutil.ReadFile(filterJsonPath)
 	var filterJsonMap map[string]interface{}
-	err2 := json.Unmarshal(filterJson, &filterJsonMap)
+	err2 := unmarshalJsonc(filterJson, &filterJsonMap)
 	if err := firstErr(err1, err2); err != nil {
 		return nil, PassError(err)
 	}
@@ -432,12 +526,15 @@ func (f *RemoteFilterDefinition) Update(dotRegolithPath string) error {
 	if err != nil {
 		Logger.Warnf("Unable to get installed version of filter %q.", f.Id)
 	}
-	version, err := GetRemoteFilterDownloadRef(f.Url, f.Id, f.Version)
-	if err != nil {
-		return WrapErrorf(
-			err, getRemoteFilterDownloadRefError, f.Url, f.Id, f.Version)
+	version := f.Version // The version of the archive filters is fixed
+	if !f.IsArchive() {
+		version, err = GetRemoteFilterDownloadRef(f.Url, f.Id, f.Version)
+		if err != nil {
+			return WrapErrorf(
+				err, getRemoteFilterDownloadRefError, f.Url, f.Id, f.Version)
+		}
+		version = trimFilterPrefix(version, f.Id)
 	}
-	version = trimFilterPrefix(version, f.Id)
 	if installedVersion != version {
 		Logger.Infof(
 			"Updating filter %q to new version: %q->%q.",
@@ -450,6 +547,12 @@ func (f *RemoteFilterDefinition) Update(dotRegolithPath string) error {
 		if err != nil {
 			return PassError(err)
 		}
+		err = f.saveToSharedCache(dotRegolithPath)
+		if err != nil {
+			Logger.Warnf(
+				"Failed to save the filter in the shared filter cache:\n%s",
+				err)
+		}
 		Logger.Infof("Filter %q updated successfully.", f.Id)
 	} else {
 		Logger.Infof(
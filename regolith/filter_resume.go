@@ -0,0 +1,215 @@
+package regolith
+
+import (
+	"crypto/sha1"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filterCheckpointsPath is the path to the directory with the manifests of
+// the checkpoints of the watch mode (see filterResumeState), relative to the
+// .regolith directory. The files of the checkpoints are stored in the objects
+// of the build cache, like the cached outputs of the filters.
+const filterCheckpointsPath = buildCachePath + "/checkpoints"
+
+// filterResumeState lets the watch mode restart the profile from the first
+// filter affected by the changed files, instead of running all of the
+// filters again. After the filters at the beginning of the profile that
+// declare their inputs and outputs, the content of the tmp directory is saved
+// as a checkpoint. When the source files change, the filters before the
+// first filter that reads or writes the changed files are skipped, and the
+// tmp directory is restored from the last checkpoint before that filter,
+// with the changed files taken from the new sources.
+type filterResumeState struct {
+	dotRegolithPath string
+	// profile and config are the profile and the config that created the
+	// checkpoints. The checkpoints are removed when they change.
+	profile string
+	config  *Config
+	// checkpoints are the manifests of the checkpoints, in the order of the
+	// filters, saved after the filters with the same indices. The filters
+	// that run in parallel with other filters have no checkpoints (""),
+	// because their output can't be separated.
+	checkpoints []string
+	// stale are the paths of the source files changed after the
+	// checkpoints with the same indices were saved. The filters before the
+	// checkpoints don't use these files, so they're taken from the sources.
+	stale []map[string]struct{}
+}
+
+// newFilterResumeState creates a filterResumeState without checkpoints. The
+// checkpoints of the previous watch modes are removed.
+func newFilterResumeState(dotRegolithPath string) *filterResumeState {
+	os.RemoveAll(filepath.Join(dotRegolithPath, filterCheckpointsPath))
+	return &filterResumeState{dotRegolithPath: dotRegolithPath}
+}
+
+// truncate removes the checkpoints starting from the one with the index.
+func (s *filterResumeState) truncate(index int) {
+	for _, manifestPath := range s.checkpoints[index:] {
+		if manifestPath != "" {
+			os.Remove(manifestPath)
+		}
+	}
+	s.checkpoints = s.checkpoints[:index]
+	s.stale = s.stale[:index]
+}
+
+// affectsFilter returns true if the filter may read or write any of the
+// changed files. The filters that don't declare their files affect all of
+// them.
+func affectsFilter(filter FilterRunner, changed []string) bool {
+	if !filterDeclaresFiles(filter) {
+		return true
+	}
+	inputs, outputs := filterFiles(filter)
+	patterns := append(append([]string{}, inputs...), outputs...)
+	for _, path := range changed {
+		if matchesGlobPatterns(path, patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// resume returns the index of the filter of the profile from which the run
+// starts. If it isn't 0, the tmp directory is restored from the checkpoint of
+// the previous filter. It must be called after setting up the tmp directory.
+func (s *filterResumeState) resume(
+	profile Profile, context RunContext,
+) (int, error) {
+	if s.profile != context.Profile || s.config != context.Config {
+		s.truncate(0)
+		s.profile = context.Profile
+		s.config = context.Config
+	}
+	// The changed files are unknown before the first successful run
+	if changedFiles == nil {
+		s.truncate(0)
+		return 0, nil
+	}
+	start := 0
+	for start < len(s.checkpoints) &&
+		!affectsFilter(profile.Filters[start], changedFiles) {
+		start++
+	}
+	for start > 0 && s.checkpoints[start-1] == "" {
+		start--
+	}
+	s.truncate(start)
+	if start == 0 {
+		return 0, nil
+	}
+	for _, stale := range s.stale {
+		for _, path := range changedFiles {
+			stale[path] = struct{}{}
+		}
+	}
+	err := s.restore(start - 1)
+	if err != nil {
+		s.truncate(0)
+		return 0, PassError(err)
+	}
+	Logger.Infof(
+		"The changed files don't affect the first %d filters, reusing "+
+			"their output.", start)
+	return start, nil
+}
+
+// restore changes the content of the tmp directory to the checkpoint with
+// the index, except for the stale files of the checkpoint, which keep their
+// current content.
+func (s *filterResumeState) restore(index int) error {
+	manifest, err := loadFilterOutputManifest(s.checkpoints[index])
+	if err != nil {
+		return PassError(err)
+	}
+	workingDir := GetAbsoluteWorkingDirectory(s.dotRegolithPath)
+	current := make(filterOutputManifest)
+	fileHash := sha1.New() // The same hash as the objects of the build cache
+	for _, dir := range filterCacheDirs(workingDir) {
+		state, err := writeStateToHash(
+			io.Discard, filepath.Join(workingDir, dir), fileHash)
+		if err != nil {
+			return PassError(err)
+		}
+		current[dir] = state
+	}
+	// The stale files and their parent directories are taken from the
+	// current state, so restoreFilterOutput keeps them
+	for dir, pairs := range manifest {
+		wanted := make(map[string]string, len(pairs))
+		for _, pair := range pairs {
+			wanted[pair.Path] = pair.Hash
+		}
+		currentHashes := make(map[string]string, len(current[dir]))
+		for _, pair := range current[dir] {
+			currentHashes[pair.Path] = pair.Hash
+		}
+		for stalePath := range s.stale[index] {
+			if !strings.HasPrefix(stalePath, dir+"/") {
+				continue
+			}
+			relPath := strings.TrimPrefix(stalePath, dir+"/")
+			hash, ok := currentHashes[relPath]
+			if !ok { // Removed file
+				delete(wanted, relPath)
+				continue
+			}
+			wanted[relPath] = hash
+			parent := path.Dir(relPath)
+			for ; parent != "."; parent = path.Dir(parent) {
+				wanted[parent] = ""
+			}
+		}
+		pairs = make([]PathHashPair, 0, len(wanted))
+		for relPath, hash := range wanted {
+			pairs = append(pairs, PathHashPair{Path: relPath, Hash: hash})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return compareFilePaths(pairs[i].Path, pairs[j].Path) < 0
+		})
+		manifest[dir] = pairs
+	}
+	return restoreFilterOutput(s.dotRegolithPath, manifest, current, workingDir)
+}
+
+// save saves the content of the tmp directory as the checkpoint after the
+// filter with the index, if the filter and all of the filters before it
+// declare their files. It must be called when the filters before it are
+// finished and the filters after it didn't start.
+func (s *filterResumeState) save(profile Profile, index int) {
+	if index < len(s.checkpoints) {
+		return
+	}
+	for i := len(s.checkpoints); i <= index; i++ {
+		if !filterDeclaresFiles(profile.Filters[i]) {
+			return
+		}
+	}
+	manifestPath := filepath.Join(
+		s.dotRegolithPath, filterCheckpointsPath,
+		strconv.Itoa(index)+".json")
+	err := saveFilterOutput(
+		s.dotRegolithPath, GetAbsoluteWorkingDirectory(s.dotRegolithPath),
+		manifestPath)
+	if err != nil {
+		os.Remove(manifestPath)
+		Logger.Warnf(
+			"Failed to save the checkpoint of the watch mode after filter "+
+				"\"%s\":\n%s",
+			profile.Filters[index].GetId(), PassError(err).Error())
+		return
+	}
+	for len(s.checkpoints) < index {
+		s.checkpoints = append(s.checkpoints, "")
+		s.stale = append(s.stale, make(map[string]struct{}))
+	}
+	s.checkpoints = append(s.checkpoints, manifestPath)
+	s.stale = append(s.stale, make(map[string]struct{}))
+}
@@ -0,0 +1,203 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sandboxModes are the valid values of the "sandbox" property of the config,
+// which decides what happens when a filter changes the files of the project
+// outside of the tmp directory (see startFilterSandbox).
+var sandboxModes = []string{"off", "warn", "error"}
+
+// sandboxIgnoredNames are the names of the directories that are never
+// checked by the sandbox. The "__pycache__" directories are created by
+// Python next to the modules of the local filters.
+var sandboxIgnoredNames = []string{".git", "__pycache__"}
+
+// sandboxReportLimit is the maximal number of the changed paths listed in
+// the messages of the sandbox.
+const sandboxReportLimit = 20
+
+// sandboxFileState is the state of a file of the project, used for finding
+// the files changed by the filters.
+type sandboxFileState struct {
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// filterSandbox checks the changes of the files of the project made while a
+// filter was running.
+type filterSandbox struct {
+	mode     string
+	filterId string
+	root     string
+	// allowedPaths are the "allowedPaths" of the filter, the glob patterns
+	// of the paths relative to the root that the filter may change.
+	allowedPaths    []string
+	dotRegolithPath string
+	before          map[string]sandboxFileState
+}
+
+// startFilterSandbox takes the snapshot of the files of the project before
+// running the filter, if the "sandbox" property of the config enables it.
+// The filters should only change the files in the tmp directory, which is
+// their working directory. A filter that changes the other files (for
+// example a filter with a bug that removes the data folder of the project
+// instead of its copy) is reported after it finishes by the check method.
+//
+// The .regolith directory and the directories from the sandboxIgnoredNames
+// aren't checked. The sandbox can only find the files that were created,
+// changed or removed, it can't find out which files the filter read. It
+// returns nil if the sandbox is disabled, or for the nested profiles, whose
+// filters are checked separately.
+func startFilterSandbox(
+	filter FilterRunner, context RunContext,
+) (*filterSandbox, error) {
+	if context.Config == nil || filter.GetId() == "" {
+		return nil, nil
+	}
+	mode := context.Config.Sandbox
+	if mode == "" || mode == "off" {
+		return nil, nil
+	}
+	root := context.AbsoluteLocation
+	if root == "" {
+		root = "."
+	}
+	dotRegolithPath, err := filepath.Abs(context.DotRegolithPath)
+	if err != nil {
+		return nil, WrapErrorf(err, filepathAbsError, context.DotRegolithPath)
+	}
+	sandbox := &filterSandbox{
+		mode:            mode,
+		filterId:        filter.GetId(),
+		root:            root,
+		allowedPaths:    filter.GetAllowedPaths(),
+		dotRegolithPath: dotRegolithPath,
+	}
+	sandbox.before, err = sandbox.snapshot()
+	if err != nil {
+		return nil, WrapError(
+			err, "Failed to list the files of the project for the sandbox.")
+	}
+	return sandbox, nil
+}
+
+// snapshot returns the states of the files of the project, mapped to their
+// paths relative to the root.
+func (s *filterSandbox) snapshot() (map[string]sandboxFileState, error) {
+	states := make(map[string]sandboxFileState)
+	err := filepath.WalkDir(
+		s.root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				// The files removed during the walk
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if path == s.root {
+				return nil
+			}
+			if d.IsDir() {
+				fullPath, _ := filepath.Abs(path)
+				if fullPath == s.dotRegolithPath ||
+					stringInSlice(d.Name(), sandboxIgnoredNames) {
+					return filepath.SkipDir
+				}
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			relPath, err := filepath.Rel(s.root, path)
+			if err != nil {
+				return WrapErrorf(err, osRelError, s.root, path)
+			}
+			states[filepath.ToSlash(relPath)] = sandboxFileState{
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+				IsDir:   d.IsDir(),
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, WrapErrorf(err, osWalkError, s.root)
+	}
+	return states, nil
+}
+
+// check compares the files of the project with the snapshot taken before
+// running the filter, and reports the paths that were changed, except for
+// the allowed paths. In the "warn" mode, the paths are printed as a warning,
+// and in the "error" mode, the error stops the run before the export.
+func (s *filterSandbox) check() error {
+	after, err := s.snapshot()
+	if err != nil {
+		return WrapError(
+			err, "Failed to list the files of the project for the sandbox.")
+	}
+	var changes []string
+	for path, state := range after {
+		if s.isAllowed(path) {
+			continue
+		}
+		previous, ok := s.before[path]
+		switch {
+		case !ok:
+			changes = append(changes, "created: "+path)
+		case state.IsDir:
+			// The modification times of the directories change with their
+			// files, which are listed separately
+		case state != previous:
+			changes = append(changes, "modified: "+path)
+		}
+	}
+	for path := range s.before {
+		if _, ok := after[path]; !ok && !s.isAllowed(path) {
+			changes = append(changes, "removed: "+path)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return sandboxChangePath(changes[i]) < sandboxChangePath(changes[j])
+	})
+	if len(changes) > sandboxReportLimit {
+		more := len(changes) - sandboxReportLimit
+		changes = append(
+			changes[:sandboxReportLimit], fmt.Sprintf("and %d more", more))
+	}
+	message := fmt.Sprintf(
+		"Files outside of the tmp directory were changed while the filter "+
+			"%q was running:\n%s\n"+
+			"Filters should only change the files in their working "+
+			"directory. If the filter is supposed to change these files, "+
+			"add them to its \"allowedPaths\" property.",
+		s.filterId, strings.Join(changes, "\n"))
+	if s.mode == "error" {
+		return WrappedError(message)
+	}
+	Logger.Warn(message)
+	return nil
+}
+
+// isAllowed returns true if the path matches the allowed paths of the
+// filter.
+func (s *filterSandbox) isAllowed(path string) bool {
+	return len(s.allowedPaths) > 0 &&
+		matchesGlobPatterns(path, s.allowedPaths)
+}
+
+// sandboxChangePath returns the path from the line of the list of changes.
+func sandboxChangePath(change string) string {
+	_, path, _ := strings.Cut(change, ": ")
+	return path
+}
@@ -0,0 +1,135 @@
+package regolith
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/otiai10/copy"
+	"golang.org/x/mod/semver"
+)
+
+// sharedFilterCachePath is a path to the cache of the remote filters shared by
+// all of the projects of the user, relative to regolithCacheDir(). The cache
+// contains the installed filters, including the dependencies installed into
+// their directories (like node_modules), so the projects that use the same
+// versions of the filters don't download and install them again.
+const sharedFilterCachePath = "filter-cache"
+
+// sharedFilterCacheRoot is the path to the shared filter cache used instead
+// of the default one (see sharedFilterCachePath), if it's not empty. It's set
+// by the workspaces with the "filterCache" property.
+var sharedFilterCacheRoot = ""
+
+// sharedFilterCacheDir returns the path to the directory of the filter in the
+// shared cache. The directories are identified by the URL, the name and the
+// git reference of the filter.
+func sharedFilterCacheDir(url, name, ref string) (string, error) {
+	hash := md5.Sum([]byte(url + "//" + name + "?ref=" + ref))
+	if sharedFilterCacheRoot != "" {
+		return filepath.Join(
+			sharedFilterCacheRoot, hex.EncodeToString(hash[:])), nil
+	}
+	userCache, err := regolithCacheDir()
+	if err != nil {
+		return "", PassError(err)
+	}
+	return filepath.Join(
+		userCache, sharedFilterCachePath, hex.EncodeToString(hash[:])), nil
+}
+
+// isExactFilterRef returns true if the git reference of the remote filter
+// always points to the same files (it's a commit SHA or a version tag of the
+// filter). Only such references can be cached.
+func isExactFilterRef(name, ref string) bool {
+	return isCommitSha(ref) || trimFilterPrefix(ref, name) != ref
+}
+
+// installedFilterRef returns the git reference of the installed remote
+// filter, based on the version from its filter.json file.
+func (f *RemoteFilterDefinition) installedFilterRef(
+	dotRegolithPath string,
+) (string, error) {
+	version, err := f.InstalledVersion(dotRegolithPath)
+	if err != nil {
+		return "", PassError(err)
+	}
+	if semver.IsValid("v" + version) {
+		return f.Id + "-" + version, nil
+	}
+	return version, nil
+}
+
+// restoreFromSharedCache copies the filter in the version of the git
+// reference from the shared cache to the download path. It returns false if
+// the filter isn't in the shared cache.
+func (f *RemoteFilterDefinition) restoreFromSharedCache(
+	ref, dotRegolithPath string,
+) (bool, error) {
+	if !isExactFilterRef(f.Id, ref) {
+		return false, nil
+	}
+	cacheDir, err := sharedFilterCacheDir(f.Url, f.Id, ref)
+	if err != nil {
+		return false, PassError(err)
+	}
+	if _, err := os.Stat(cacheDir); err != nil {
+		return false, nil
+	}
+	downloadPath := f.GetDownloadPath(dotRegolithPath)
+	err = copy.Copy(
+		cacheDir, downloadPath, copy.Options{PreserveTimes: false, Sync: false})
+	if err != nil {
+		os.RemoveAll(downloadPath)
+		return false, WrapErrorf(err, osCopyError, cacheDir, downloadPath)
+	}
+	Logger.Infof(
+		"Filter \"%s\" copied from the shared filter cache: %s", f.Id,
+		cacheDir)
+	return true, nil
+}
+
+// saveToSharedCache copies the installed filter to the shared cache, unless
+// it's already there or its version can't be cached. The filter is copied to
+// a temporary directory first, so other Regolith processes never see
+// partially copied filters.
+func (f *RemoteFilterDefinition) saveToSharedCache(dotRegolithPath string) error {
+	ref, err := f.installedFilterRef(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	if !isExactFilterRef(f.Id, ref) {
+		return nil
+	}
+	cacheDir, err := sharedFilterCacheDir(f.Url, f.Id, ref)
+	if err != nil {
+		return PassError(err)
+	}
+	if _, err := os.Stat(cacheDir); err == nil {
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(cacheDir), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(cacheDir))
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(cacheDir), ".tmp-")
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(cacheDir))
+	}
+	defer os.RemoveAll(tmpDir)
+	downloadPath := f.GetDownloadPath(dotRegolithPath)
+	err = copy.Copy(
+		downloadPath, tmpDir, copy.Options{PreserveTimes: false, Sync: false})
+	if err != nil {
+		return WrapErrorf(err, osCopyError, downloadPath, tmpDir)
+	}
+	err = os.Rename(tmpDir, cacheDir)
+	if err != nil {
+		if _, statErr := os.Stat(cacheDir); statErr == nil {
+			return nil // Saved by another process in the meantime
+		}
+		return WrapErrorf(err, osRenameError, tmpDir, cacheDir)
+	}
+	return nil
+}
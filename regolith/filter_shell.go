@@ -34,7 +34,11 @@ func ShellFilterDefinitionFromObject(
 }
 
 func (f *ShellFilter) Run(context RunContext) (bool, error) {
-	if err := f.run(f.Settings, context); err != nil {
+	settings, arguments, err := f.expandVariables(context)
+	if err != nil {
+		return false, PassError(err)
+	}
+	if err := f.run(settings, arguments, context); err != nil {
 		return false, PassError(err)
 	}
 	return context.IsInterrupted(), nil
@@ -76,19 +80,20 @@ var shells = [][]string{
 
 func (f *ShellFilter) run(
 	settings map[string]interface{},
+	arguments []string,
 	context RunContext,
 ) error {
 	var err error = nil
 	if len(settings) == 0 {
-		err = executeCommand(f.Id,
+		err = executeCommand(context, f.Id,
 			f.Definition.Command,
-			f.Arguments, context.AbsoluteLocation,
+			arguments, context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath))
 	} else {
 		jsonSettings, _ := json.Marshal(settings)
-		err = executeCommand(f.Id,
+		err = executeCommand(context, f.Id,
 			f.Definition.Command,
-			append([]string{string(jsonSettings)}, f.Arguments...),
+			append([]string{string(jsonSettings)}, arguments...),
 			context.AbsoluteLocation,
 			GetAbsoluteWorkingDirectory(context.DotRegolithPath))
 	}
@@ -98,16 +103,16 @@ func (f *ShellFilter) run(
 	return nil
 }
 
-func executeCommand(id string,
+func executeCommand(context RunContext, id string,
 	command string, args []string, filterDir string, workingDir string,
 ) error {
 	joined := strings.Join(append([]string{command}, args...), " ")
-	Logger.Debugf("Executing command: %s", joined)
+	Logger.Debugf("Executing command: %s", hideSecrets(joined))
 	shell, arg, err := findShell()
 	if err != nil {
 		return WrapError(err, "Unable to find a valid shell.")
 	}
-	err = RunSubProcess(shell, []string{arg, joined}, filterDir, workingDir, ShortFilterName(id))
+	err = runFilterSubProcess(context, shell, []string{arg, joined}, filterDir, workingDir, ShortFilterName(id))
 	if err != nil {
 		return WrapError(err, runSubProcessError)
 	}
@@ -0,0 +1,173 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/otiai10/copy"
+	"golang.org/x/mod/semver"
+)
+
+// VendorPath is the path to the directory with the vendored copies of the
+// remote filters, relative to the project's root directory. The vendored
+// filters are used by the offline mode of the "regolith install-all"
+// command.
+const VendorPath = "filters_vendored"
+
+// offlineDependencyEnv are the environment variables that prevent the
+// package managers used by the filters from accessing the network, in the
+// offline mode. The packages must be available in the local caches of the
+// package managers (or, for pip, in the directories listed in the
+// PIP_FIND_LINKS environment variable).
+var offlineDependencyEnv = map[string]string{
+	"PIP_NO_INDEX":       "1",
+	"npm_config_offline": "true",
+}
+
+// GetVendorPath returns the path to the vendored copy of the filter.
+func (f *RemoteFilterDefinition) GetVendorPath() string {
+	return filepath.Join(VendorPath, f.Id)
+}
+
+// vendorFilters copies the installed remote filters from the list to the
+// vendor directory and removes the vendored filters which aren't on the list
+// anymore.
+func vendorFilters(
+	filterDefinitions map[string]FilterInstaller, dotRegolithPath string,
+) error {
+	vendored := make(map[string]struct{})
+	for name, filterDefinition := range filterDefinitions {
+		remoteFilter, ok := filterDefinition.(*RemoteFilterDefinition)
+		if !ok {
+			continue
+		}
+		if remoteFilter.IsLinked(dotRegolithPath) {
+			return WrappedErrorf(
+				"The filter is linked to its local working copy and can't "+
+					"be vendored.\nFilter: %s\n"+
+					"You can unlink the filter using command:\n"+
+					"regolith unlink %s", name, name)
+		}
+		downloadPath := remoteFilter.GetDownloadPath(dotRegolithPath)
+		if _, err := os.Stat(downloadPath); err != nil {
+			return WrappedErrorf(
+				"The filter is not installed.\nFilter: %s\n"+
+					"You can install the filters using command:\n"+
+					"regolith install-all", name)
+		}
+		vendorPath := remoteFilter.GetVendorPath()
+		Logger.Infof("Vendoring %q filter to \"%s\"...", name, vendorPath)
+		err := os.RemoveAll(vendorPath)
+		if err != nil {
+			return WrapErrorf(err, osRemoveError, vendorPath)
+		}
+		err = copy.Copy(
+			downloadPath, vendorPath,
+			copy.Options{PreserveTimes: false, Sync: false})
+		if err != nil {
+			return WrapErrorf(err, osCopyError, downloadPath, vendorPath)
+		}
+		vendored[name] = struct{}{}
+	}
+	entries, err := os.ReadDir(VendorPath)
+	if err != nil && !os.IsNotExist(err) {
+		return WrapErrorf(err, "Failed to list the directory.\nPath: %s",
+			VendorPath)
+	}
+	for _, entry := range entries {
+		if _, ok := vendored[entry.Name()]; ok || !entry.IsDir() {
+			continue
+		}
+		stalePath := filepath.Join(VendorPath, entry.Name())
+		Logger.Infof(
+			"Removing \"%s\", the filter is not used by the project "+
+				"anymore.", stalePath)
+		err = os.RemoveAll(stalePath)
+		if err != nil {
+			return WrapErrorf(err, osRemoveError, stalePath)
+		}
+	}
+	return nil
+}
+
+// installOffline installs the filter without accessing the network, from
+// its vendored copy or, if the filter isn't vendored, from the filters
+// already downloaded to the cache of the project or to the shared filter
+// cache. If the filter is in the lock file, the installed version must match
+// the locked version.
+func (f *RemoteFilterDefinition) installOffline(
+	dotRegolithPath string, lockFile *LockFile,
+) error {
+	downloadPath := f.GetDownloadPath(dotRegolithPath)
+	vendorPath := f.GetVendorPath()
+	_, vendorErr := os.Stat(vendorPath)
+	_, downloadErr := os.Stat(downloadPath)
+	switch {
+	case vendorErr == nil:
+		Logger.Infof("Copying %q filter from \"%s\"...", f.Id, vendorPath)
+		f.Uninstall(dotRegolithPath)
+		err := copy.Copy(
+			vendorPath, downloadPath,
+			copy.Options{PreserveTimes: false, Sync: false})
+		if err != nil {
+			return WrapErrorf(err, osCopyError, vendorPath, downloadPath)
+		}
+	case downloadErr == nil:
+		Logger.Infof("Using the downloaded %q filter.", f.Id)
+	case !f.restoreLockedFromSharedCache(dotRegolithPath, lockFile):
+		return WrappedErrorf(
+			"The filter is neither vendored nor downloaded, so it can't be "+
+				"installed in the offline mode.\n"+
+				"Filter: %s\nVendor path: %s\n"+
+				"You can vendor the filters using command:\n"+
+				"regolith vendor", f.Id, vendorPath)
+	}
+	if resolved, ok := lockFile.Resolved(f.Id, f); ok {
+		installedVersion, err := f.InstalledVersion(dotRegolithPath)
+		if err != nil {
+			return PassError(err)
+		}
+		if trimFilterPrefix(installedVersion, f.Id) != resolved {
+			return WrappedErrorf(
+				"The available version of the filter doesn't match the lock "+
+					"file and it can't be downloaded in the offline mode.\n"+
+					"Filter: %s\nAvailable version: %s\nLocked version: %s\n"+
+					"You can update the vendored filters using command:\n"+
+					"regolith vendor",
+				f.Id, installedVersion, resolved)
+		}
+	}
+	return nil
+}
+
+// restoreLockedFromSharedCache copies the filter in the version from the lock
+// file from the shared filter cache. It returns false if the filter isn't
+// locked or isn't in the shared cache.
+func (f *RemoteFilterDefinition) restoreLockedFromSharedCache(
+	dotRegolithPath string, lockFile *LockFile,
+) bool {
+	resolved, ok := lockFile.Resolved(f.Id, f)
+	if !ok {
+		return false
+	}
+	ref := resolved
+	if semver.IsValid("v" + ref) {
+		ref = f.Id + "-" + ref
+	}
+	restored, err := f.restoreFromSharedCache(ref, dotRegolithPath)
+	if err != nil {
+		Logger.Warnf("Failed to use the shared filter cache:\n%s", err)
+	}
+	return restored
+}
+
+// disableDependencyDownloads sets the environment variables which prevent
+// the package managers from accessing the network while installing the
+// dependencies of the filters.
+func disableDependencyDownloads() {
+	for name, value := range offlineDependencyEnv {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+}
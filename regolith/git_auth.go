@@ -0,0 +1,148 @@
+package regolith
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The environment variables used for the authentication with the private
+// repositories of the remote filters.
+const (
+	// gitTokenEnv is the personal access token used for the repositories
+	// accessed with HTTPS.
+	gitTokenEnv = "REGOLITH_GIT_TOKEN"
+
+	// gitProtocolEnv selects the protocol used for the repositories. If it's
+	// "ssh", the repositories are accessed with SSH, which uses the keys from
+	// the SSH agent.
+	gitProtocolEnv = "REGOLITH_GIT_PROTOCOL"
+)
+
+// gitAuthFailureMessages are the parts of the error messages of git that
+// mean that the access to the repository was denied.
+var gitAuthFailureMessages = []string{
+	"authentication failed",
+	"could not read username",
+	"could not read password",
+	"terminal prompts disabled",
+	"permission denied (publickey",
+	"repository not found",
+	"access denied",
+	"http basic: access denied",
+	"returned error: 403",
+	"returned error: 401",
+}
+
+// remoteFilterGitUrl returns the URL used by the git commands for the
+// repository of a remote filter. The URLs of the remote filters don't have a
+// scheme (for example "github.com/Bedrock-OSS/regolith-filters"), so HTTPS is
+// used, unless the protocol is changed to SSH with the REGOLITH_GIT_PROTOCOL
+// environment variable. The URLs that use SSH explicitly ("ssh://..." or
+// "git@host:path") are also supported. The personal access token from the
+// REGOLITH_GIT_TOKEN environment variable is added to the HTTPS URLs.
+func remoteFilterGitUrl(repoUrl string) string {
+	if strings.HasPrefix(repoUrl, "git@") {
+		// The scp-like syntax isn't supported by go-getter
+		return "ssh://" + strings.Replace(repoUrl, ":", "/", 1)
+	}
+	if strings.Contains(repoUrl, "://") {
+		return repoUrl
+	}
+	if os.Getenv(gitProtocolEnv) == "ssh" {
+		host, path, _ := strings.Cut(repoUrl, "/")
+		return "ssh://git@" + host + "/" + strings.TrimSuffix(path, ".git") +
+			".git"
+	}
+	if token := os.Getenv(gitTokenEnv); token != "" {
+		// GitLab requires the "oauth2" user name, GitHub and most of the
+		// other services accept any user name
+		user := "x-access-token"
+		if strings.Contains(repoUrl, "gitlab") {
+			user = "oauth2"
+		}
+		return "https://" + user + ":" + url.QueryEscape(token) + "@" + repoUrl
+	}
+	return "https://" + repoUrl
+}
+
+// remoteFilterGetterUrl returns the URL used by go-getter for downloading a
+// filter from the subdirectory of a repository, at the git reference.
+func remoteFilterGetterUrl(repoUrl, name, ref string) string {
+	gitUrl := remoteFilterGitUrl(repoUrl)
+	if strings.HasPrefix(gitUrl, "https://") &&
+		os.Getenv(gitTokenEnv) == "" {
+		// Let go-getter detect the URL, like it always did
+		return fmt.Sprintf("%s//%s?ref=%s", repoUrl, name, ref)
+	}
+	return fmt.Sprintf("git::%s//%s?ref=%s", gitUrl, name, ref)
+}
+
+// gitCommand creates a git command that fails instead of asking for the
+// credentials in the terminal. The credentials can still be provided by the
+// credential helpers of git, the .netrc file or the SSH agent.
+func gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+// runGitCommand runs a git command for the repository of a remote filter
+// and returns its output. If the access to the repository is denied, the
+// error explains how to authenticate.
+func runGitCommand(repoUrl string, args ...string) ([]byte, error) {
+	output, err := gitCommand(args...).Output()
+	if err != nil {
+		details := err.Error()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			details = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return nil, gitError(repoUrl, "git "+args[0], details)
+	}
+	return output, nil
+}
+
+// gitError creates the error of a failed git operation on the repository of
+// a remote filter. The token from the REGOLITH_GIT_TOKEN environment
+// variable is removed from the error message.
+func gitError(repoUrl, operation, details string) error {
+	if token := os.Getenv(gitTokenEnv); token != "" {
+		details = strings.ReplaceAll(details, url.QueryEscape(token), "***")
+		details = strings.ReplaceAll(details, token, "***")
+	}
+	if isGitAuthFailure(details) {
+		return WrappedErrorf(
+			"Access to the filter repository was denied.\n"+
+				"Repository: %s\n"+
+				"Details: %s\n"+
+				"If the repository is private, Regolith needs credentials to "+
+				"access it. You can:\n"+
+				"  - set the %s environment variable to a personal access "+
+				"token with read access to the repository,\n"+
+				"  - add the credentials for the host to your .netrc file or "+
+				"to a git credential helper,\n"+
+				"  - set the %s environment variable to \"ssh\" to use the "+
+				"keys from your SSH agent.",
+			repoUrl, details, gitTokenEnv, gitProtocolEnv)
+	}
+	return WrappedErrorf(
+		"Failed to access the filter repository.\n"+
+			"Repository: %s\nOperation: %s\nDetails: %s",
+		repoUrl, operation, details)
+}
+
+// isGitAuthFailure returns true if the error message of git means that the
+// access to the repository was denied.
+func isGitAuthFailure(message string) bool {
+	message = strings.ToLower(message)
+	for _, failure := range gitAuthFailureMessages {
+		if strings.Contains(message, failure) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// githubSummaryEnv is the environment variable with the path to the file
+// with the step summary of the GitHub Actions job. Markdown appended to the
+// file is displayed on the page of the workflow run.
+const githubSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// githubLogs returns true if Regolith prints the workflow commands of GitHub
+// Actions ("github" LogFormat). The log messages use the text format.
+func githubLogs() bool {
+	return LogFormat == "github"
+}
+
+// printGithubCommand prints a workflow command of GitHub Actions, like
+// "::error file=BP/x.json,line=1::Message", with the escaped properties and
+// message.
+func printGithubCommand(
+	command string, properties map[string]string, message string,
+) {
+	names := make([]string, 0, len(properties))
+	for name, value := range properties {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + escapeGithubProperty(properties[name])
+	}
+	line := "::" + command
+	if len(names) > 0 {
+		line += " " + strings.Join(names, ",")
+	}
+	fmt.Fprintln(color.Output, line+"::"+escapeGithubData(message))
+}
+
+// printGithubDiagnostic prints the diagnostic of a filter as the annotation
+// of its file.
+func printGithubDiagnostic(diagnostic FilterDiagnostic) {
+	command := "error"
+	switch diagnostic.Severity {
+	case "warn", "warning":
+		command = "warning"
+	case "info", "debug":
+		command = "notice"
+	}
+	properties := map[string]string{"title": diagnostic.Filter}
+	if diagnostic.File != "" {
+		properties["file"] = githubWorkspacePath(diagnostic.File)
+		if diagnostic.Line > 0 {
+			properties["line"] = fmt.Sprint(diagnostic.Line)
+		}
+		if diagnostic.Column > 0 {
+			properties["col"] = fmt.Sprint(diagnostic.Column)
+		}
+	}
+	printGithubCommand(command, properties, diagnostic.Message)
+}
+
+// githubWorkspacePath returns the path relative to the root of the
+// repository (the GITHUB_WORKSPACE), because the annotations use such paths.
+// The path is returned unchanged if it's outside of the repository.
+func githubWorkspacePath(path string) string {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	absPath, err := filepath.Abs(path)
+	if workspace == "" || err != nil || !isInDirectory(absPath, workspace) {
+		return filepath.ToSlash(path)
+	}
+	relPath, _ := filepath.Rel(workspace, absPath) // no error
+	return filepath.ToSlash(relPath)
+}
+
+func escapeGithubData(s string) string {
+	return strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGithubProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C",
+	).Replace(s)
+}
+
+// writeGithubSummary appends the report of the run of the profile to the
+// step summary of the GitHub Actions job: the result of the run, the
+// durations of its phases and the diagnostics of the filters. It does
+// nothing outside of GitHub Actions.
+func writeGithubSummary(profileName string, runErr error) error {
+	path := os.Getenv(githubSummaryEnv)
+	if path == "" {
+		return nil
+	}
+	var summary strings.Builder
+	total := time.Since(timedRunStart).Round(time.Millisecond)
+	if runErr == nil {
+		fmt.Fprintf(
+			&summary, "### ✅ Regolith: the %q profile succeeded in %s\n\n",
+			profileName, total)
+	} else {
+		fmt.Fprintf(
+			&summary, "### ❌ Regolith: the %q profile failed after %s\n\n"+
+				"```\n%s\n```\n\n",
+			profileName, total, PassError(runErr).Error())
+	}
+	if phases := currentRunPhases(); len(phases) > 0 {
+		summary.WriteString("| Phase | Duration |\n| --- | ---: |\n")
+		for _, phase := range phases {
+			fmt.Fprintf(
+				&summary, "| %s | %s |\n",
+				escapeMarkdownCell(strings.Join(phase.Stack, " > ")),
+				phase.Duration.Round(time.Millisecond))
+		}
+		summary.WriteString("\n")
+	}
+	if diagnostics := currentRunDiagnostics(); len(diagnostics) > 0 {
+		summary.WriteString(
+			"| Severity | Filter | Location | Message |\n" +
+				"| --- | --- | --- | --- |\n")
+		for _, diagnostic := range diagnostics {
+			location := diagnostic.File
+			if location != "" && diagnostic.Line > 0 {
+				location += fmt.Sprintf(":%d", diagnostic.Line)
+			}
+			fmt.Fprintf(
+				&summary, "| %s | %s | %s | %s |\n",
+				diagnostic.Severity, escapeMarkdownCell(diagnostic.Filter),
+				escapeMarkdownCell(location),
+				escapeMarkdownCell(diagnostic.Message))
+		}
+		summary.WriteString("\n")
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(summary.String()); err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	return nil
+}
+
+// escapeMarkdownCell escapes the text for a cell of a Markdown table.
+func escapeMarkdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\r", "", "\n", "<br>").Replace(s)
+}
@@ -0,0 +1,204 @@
+package regolith
+
+import (
+	"crypto/rand"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// builtInTemplates are the project templates shipped with Regolith. Every
+// directory in "templates" is a template with the same name.
+//
+//go:embed all:templates
+var builtInTemplates embed.FS
+
+// bareTemplate is the name of the template that creates the project with
+// the bare "regolith init" (see Init).
+const bareTemplate = "bare"
+
+// namespaceInvalidChars matches the characters that can't be used in the
+// namespaces of the projects.
+var namespaceInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// templateVariables are the values of the placeholders in the files of a
+// project template (see expandTemplateVariables).
+type templateVariables struct {
+	ProjectName string
+	Namespace   string
+	// uuids are the UUIDs generated for the "${uuid:KEY}" placeholders. The
+	// same key always gets the same UUID, so the manifests of the packs can
+	// refer to each other.
+	uuids map[string]string
+}
+
+// listTemplates returns the names of the built-in templates and the
+// templates from the user config.
+func listTemplates() []string {
+	var result []string
+	entries, _ := builtInTemplates.ReadDir("templates") // no error
+	for _, entry := range entries {
+		result = append(result, entry.Name())
+	}
+	if userConfig, err := LoadUserConfig(); err == nil {
+		for name := range userConfig.Templates {
+			result = append(result, name)
+		}
+	}
+	result = append(result, bareTemplate)
+	sort.Strings(result)
+	return result
+}
+
+// resolveTemplate returns the files of the project template. The template
+// can be the name of a built-in template, the name of a template from the
+// "templates" of the user config or a URL supported by go-getter, like
+// "github.com/user/repo//template?ref=main". The returned function removes
+// the downloaded files.
+func resolveTemplate(template string) (fs.FS, func(), error) {
+	noCleanup := func() {}
+	if _, err := fs.Stat(builtInTemplates, "templates/"+template); err == nil {
+		files, _ := fs.Sub(builtInTemplates, "templates/"+template) // no error
+		return files, noCleanup, nil
+	}
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		return nil, noCleanup, WrapError(err, "Failed to load the user config.")
+	}
+	url, ok := userConfig.Templates[template]
+	if !ok {
+		if !strings.ContainsAny(template, "/:") {
+			return nil, noCleanup, WrappedErrorf(
+				"Unknown project template.\nTemplate: %s\n"+
+					"Available templates: %s\n"+
+					"Use the URL of a template repository or add the template "+
+					"to the \"templates\" of the user config.",
+				template, strings.Join(listTemplates(), ", "))
+		}
+		url = template
+	}
+	tmpPath, err := os.MkdirTemp("", "regolith-template-")
+	if err != nil {
+		return nil, noCleanup, WrapError(
+			err, "Failed to create a temporary directory.")
+	}
+	cleanup := func() { os.RemoveAll(tmpPath) }
+	downloadPath := filepath.Join(tmpPath, "template")
+	Logger.Infof("Downloading the project template from %s...", url)
+	err = getter.Get(downloadPath, url)
+	if err != nil {
+		cleanup()
+		return nil, noCleanup, WrapErrorf(
+			err, "Failed to download the project template.\nURL: %s", url)
+	}
+	return os.DirFS(downloadPath), cleanup, nil
+}
+
+// applyTemplate copies the files of the project template to the current
+// directory, with the placeholders in their paths and in the contents of the
+// text files replaced (see expandTemplateVariables). The ".git" directory of
+// the template repository isn't copied.
+func applyTemplate(files fs.FS, variables *templateVariables) error {
+	if _, err := fs.Stat(files, ConfigFilePath); err != nil {
+		return WrappedErrorf(
+			"The project template doesn't have the %s file.", ConfigFilePath)
+	}
+	return fs.WalkDir(files, ".", func(
+		sourcePath string, entry fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			return WrapError(err, "Failed to read the project template.")
+		}
+		if sourcePath == "." {
+			return nil
+		}
+		if entry.IsDir() && path.Base(sourcePath) == ".git" {
+			return fs.SkipDir
+		}
+		target := filepath.FromSlash(
+			expandTemplateVariables(sourcePath, variables))
+		if entry.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return WrapErrorf(err, osMkdirError, target)
+			}
+			return nil
+		}
+		data, err := fs.ReadFile(files, sourcePath)
+		if err != nil {
+			return WrapErrorf(err, fileReadError, sourcePath)
+		}
+		// The binary files, like the textures, are copied unchanged
+		if utf8.Valid(data) && !strings.ContainsRune(string(data), 0) {
+			data = []byte(expandTemplateVariables(string(data), variables))
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return WrapErrorf(err, fileWriteError, target)
+		}
+		return nil
+	})
+}
+
+// expandTemplateVariables replaces the placeholders in the text from a
+// project template. Supported placeholders are:
+//   - ${PROJECT_NAME} - the name of the project,
+//   - ${NAMESPACE} - the namespace of the project, for the identifiers of
+//     the entities, items, functions, etc.,
+//   - ${uuid:KEY} - a new random UUID, the same for every use of the key.
+//
+// Unknown placeholders are left unchanged, so the templates can use the
+// variables of the filters (see ExpandVariables).
+func expandTemplateVariables(text string, variables *templateVariables) string {
+	if !strings.Contains(text, "${") {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		name, argument := groups[1], groups[2]
+		if strings.Contains(match, ":") {
+			if name != "uuid" {
+				return match
+			}
+			if _, ok := variables.uuids[argument]; !ok {
+				variables.uuids[argument] = newUuid()
+			}
+			return variables.uuids[argument]
+		}
+		switch name {
+		case "PROJECT_NAME":
+			return variables.ProjectName
+		case "NAMESPACE":
+			return variables.Namespace
+		}
+		return match
+	})
+}
+
+// defaultNamespace returns the namespace created from the name of the
+// project, for example "my_project" for "My Project".
+func defaultNamespace(projectName string) string {
+	namespace := namespaceInvalidChars.ReplaceAllString(
+		strings.ToLower(projectName), "_")
+	namespace = strings.Trim(namespace, "_")
+	if namespace == "" {
+		return "project"
+	}
+	return namespace
+}
+
+// newUuid returns a random (version 4) UUID.
+func newUuid() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
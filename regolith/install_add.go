@@ -2,8 +2,10 @@
 package regolith
 
 import (
-	"os/exec"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"golang.org/x/mod/semver"
@@ -22,10 +24,20 @@ type parsedInstallFilterArg struct {
 
 // installFilters installs the filters from the list and their dependencies,
 // and copies their data to the data path. If the filter is already installed,
-// it returns an error unless the force flag is set.
+// it returns an error unless the force flag is set. The remote filters are
+// installed in the versions from the lock file, and the versions of the
+// filters that aren't locked yet are added to the lock file. In the offline
+// mode, the remote filters are installed from the vendor directory or the
+// cache, without accessing the network.
+//
+// The filters are installed in parallel, using a pool of workers. If any of
+// the filters fails, no new installations are started and the function waits
+// for the running ones to finish. The nested remote filters required by the
+// filter.json files of the remote filters are installed by the workers of
+// the filters that require them (see installNestedFilters).
 func installFilters(
-	filterDefinitions map[string]FilterInstaller, force bool,
-	dataPath, dotRegolithPath string,
+	filterDefinitions map[string]FilterInstaller, force, offline bool,
+	dataPath, dotRegolithPath string, lockFile *LockFile,
 ) error {
 	joinedPath := filepath.Join(dotRegolithPath, "cache/filters")
 	err := CreateDirectoryIfNotExists(joinedPath, true)
@@ -38,43 +50,187 @@ func installFilters(
 		return WrapErrorf(err, osMkdirError, "cache/venvs")
 	}
 
-	// Download all of the remote filters
-	resolverUpdated := false
-	for name, filterDefinition := range filterDefinitions {
-		Logger.Infof("Downloading %q filter...", name)
-		if remoteFilter, ok := filterDefinition.(*RemoteFilterDefinition); ok {
-			// Download resolver once if remote filter is found
-			if !resolverUpdated {
+	if offline {
+		disableDependencyDownloads()
+	} else {
+		// Download resolver once if remote filter is found
+		for _, filterDefinition := range filterDefinitions {
+			if _, ok := filterDefinition.(*RemoteFilterDefinition); ok {
 				err = DownloadResolverMap()
 				if err != nil {
 					Logger.Warn("Failed to download resolver map.")
 				}
-				resolverUpdated = true
+				break
 			}
-			// Download the remote filter
-			err := remoteFilter.Download(force, dotRegolithPath)
-			if err != nil {
-				return WrapErrorf(err, remoteFilterDownloadError, name)
+		}
+	}
+
+	// Sorted names make the order of starting the installations predictable
+	names := make([]string, 0, len(filterDefinitions))
+	for name := range filterDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nested := newNestedFilterInstalls(filterDefinitions)
+	workers := runtime.NumCPU()
+	results := make(chan error)
+	running := 0
+	var installErr error
+	for _, name := range names {
+		if running == workers {
+			if err := <-results; err != nil && installErr == nil {
+				installErr = err
 			}
-			// Copy the data of the remote filter to the data path
-			remoteFilter.CopyFilterData(dataPath, dotRegolithPath)
+			running--
+		}
+		if installErr != nil {
+			break
+		}
+		running++
+		go func(name string) {
+			results <- installFilter(
+				name, filterDefinitions[name], force, offline, dataPath,
+				dotRegolithPath, lockFile, nested)
+		}(name)
+	}
+	for ; running > 0; running-- {
+		if err := <-results; err != nil && installErr == nil {
+			installErr = err
 		}
-		// Install the dependencies of the filter
-		Logger.Infof("Installing %q filter dependencies...", name)
-		err = filterDefinition.InstallDependencies(nil, dotRegolithPath)
+	}
+	if installErr != nil {
+		return installErr
+	}
+	return checkNestedFilterCycles(filterDefinitions, dotRegolithPath)
+}
+
+// installFilter installs a single filter for installFilters, and the nested
+// remote filters that it requires.
+func installFilter(
+	name string, filterDefinition FilterInstaller, force, offline bool,
+	dataPath, dotRegolithPath string, lockFile *LockFile,
+	nested *nestedFilterInstalls,
+) error {
+	remoteFilter, isRemote := filterDefinition.(*RemoteFilterDefinition)
+	linked := isRemote && remoteFilter.IsLinked(dotRegolithPath)
+	if linked {
+		Logger.Infof(
+			"Filter %q is linked to its local working copy, skipping the "+
+				"download.", name)
+	} else if isRemote && offline {
+		err := remoteFilter.installOffline(dotRegolithPath, lockFile)
 		if err != nil {
 			return WrapErrorf(
-				err,
-				"Failed to install dependencies of the filter.\nFilter: %s.",
-				name)
+				err, "Failed to install the filter in the offline mode.\n"+
+					"Filter: %s", name)
+		}
+		err = verifyFilterIntegrity(
+			name, remoteFilter, dotRegolithPath, lockFile)
+		if err != nil {
+			return PassError(err)
+		}
+		remoteFilter.CopyFilterData(dataPath, dotRegolithPath)
+	} else if isRemote {
+		Logger.Infof("Downloading %q filter...", name)
+		if remoteFilter.urlFromRegistry {
+			remoteFilter.resolveRegistryUrl()
+		}
+		// Download the remote filter in the locked version
+		filterForce := force
+		if resolved, ok := lockFile.Resolved(name, remoteFilter); ok {
+			remoteFilter.resolvedVersion = resolved
+			installedVersion, err := remoteFilter.InstalledVersion(
+				dotRegolithPath)
+			if err == nil &&
+				trimFilterPrefix(installedVersion, name) != resolved {
+				Logger.Infof(
+					"The installed version of %q doesn't match the lock "+
+						"file: %q->%q.", name, installedVersion, resolved)
+				filterForce = true
+			}
+		} else if remoteFilter.IsArchive() {
+			// The archive filters change only with their checksums
+			installedVersion, err := remoteFilter.InstalledVersion(
+				dotRegolithPath)
+			if err == nil && installedVersion != remoteFilter.Version {
+				filterForce = true
+			}
+		}
+		// The integrity of the filter is verified only after downloading,
+		// the dependencies can add files to the installed filters
+		_, err := os.Stat(remoteFilter.GetDownloadPath(dotRegolithPath))
+		downloaded := filterForce || err != nil
+		err = remoteFilter.Download(filterForce, dotRegolithPath)
+		if err != nil {
+			return WrapErrorf(err, remoteFilterDownloadError, name)
 		}
+		if _, ok := lockFile.Resolved(name, remoteFilter); !ok {
+			err = lockFile.Lock(name, remoteFilter, dotRegolithPath)
+			if err != nil {
+				return WrapErrorf(
+					err, "Failed to lock the version of the filter.\n"+
+						"Filter: %s", name)
+			}
+		} else if downloaded {
+			err = verifyFilterIntegrity(
+				name, remoteFilter, dotRegolithPath, lockFile)
+			if err != nil {
+				return PassError(err)
+			}
+		}
+		// Copy the data of the remote filter to the data path
+		remoteFilter.CopyFilterData(dataPath, dotRegolithPath)
+	}
+	// Install the dependencies of the filter
+	Logger.Infof("Installing %q filter dependencies...", name)
+	err := filterDefinition.InstallDependencies(nil, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(
+			err,
+			"Failed to install dependencies of the filter.\nFilter: %s.",
+			name)
+	}
+	if isRemote && !linked {
+		err = remoteFilter.saveToSharedCache(dotRegolithPath)
+		if err != nil {
+			Logger.Warnf(
+				"Failed to save the filter in the shared filter cache:\n%s",
+				err)
+		}
+	}
+	if isRemote {
+		return installNestedFilters(
+			name, remoteFilter, force, offline, dataPath, dotRegolithPath,
+			lockFile, nested)
 	}
 	return nil
 }
 
-// updateFilters updates the filters from the list.
+// verifyFilterIntegrity checks if the files of the installed remote filter
+// match the integrity hash from the lock file. If they don't, the filter is
+// uninstalled.
+func verifyFilterIntegrity(
+	name string, remoteFilter *RemoteFilterDefinition, dotRegolithPath string,
+	lockFile *LockFile,
+) error {
+	if _, ok := lockFile.Resolved(name, remoteFilter); !ok {
+		return nil
+	}
+	err := lockFile.Verify(name, remoteFilter, dotRegolithPath)
+	if err != nil {
+		remoteFilter.Uninstall(dotRegolithPath)
+		return WrapErrorf(
+			err, "Failed to verify the integrity of the filter.\n"+
+				"Filter: %s", name)
+	}
+	return nil
+}
+
+// updateFilters updates the filters from the list and saves their new
+// versions in the lock file.
 func updateFilters(
 	remoteFilterDefinitions map[string]FilterInstaller, dotRegolithPath string,
+	lockFile *LockFile,
 ) error {
 	joinedPath := filepath.Join(dotRegolithPath, "cache/filters")
 	err := CreateDirectoryIfNotExists(joinedPath, true)
@@ -91,6 +247,12 @@ func updateFilters(
 	for name, filterDefinition := range remoteFilterDefinitions {
 		Logger.Infof("Updating %q filter...", name)
 		if remoteFilter, ok := filterDefinition.(*RemoteFilterDefinition); ok {
+			if remoteFilter.IsLinked(dotRegolithPath) {
+				Logger.Infof(
+					"Filter %q is linked to its local working copy, "+
+						"skipping the update.", name)
+				continue
+			}
 			// Download resolver once if remote filter is found
 			if !resolverUpdated {
 				err = DownloadResolverMap()
@@ -99,12 +261,21 @@ func updateFilters(
 				}
 				resolverUpdated = true
 			}
+			if remoteFilter.urlFromRegistry {
+				remoteFilter.resolveRegistryUrl()
+			}
 			// Update the filter
 			err := remoteFilter.Update(dotRegolithPath)
 			if err != nil {
 				return WrapErrorf(
 					err, "Failed to update filter.\nFilter: %s", name)
 			}
+			err = lockFile.Lock(name, remoteFilter, dotRegolithPath)
+			if err != nil {
+				return WrapErrorf(
+					err, "Failed to lock the version of the filter.\n"+
+						"Filter: %s", name)
+			}
 		}
 	}
 	return nil
@@ -145,6 +316,15 @@ func parseInstallFilterArgs(
 		} else {
 			url = arg
 		}
+		if isArchiveUrl(url) {
+			return nil, WrappedErrorf(
+				"The archive filters can't be installed with this command.\n"+
+					"URL: %s\n"+
+					"Add the filter with its \"url\" and \"checksum\" to the "+
+					"\"filterDefinitions\" in the config.json file, and install "+
+					"it using command:\n"+
+					"regolith install-all", url)
+		}
 		// Check if identifier is an URL. The last part of the URL is the name
 		// of the filter
 		if strings.Contains(url, "/") {
@@ -200,20 +380,28 @@ func GetRemoteFilterDownloadRef(url, name, version string) (string, error) {
 		versionGetters = vg{GetLatestRemoteFilterTag}
 	} else if version == "HEAD" {
 		versionGetters = vg{GetHeadSha}
+	} else if isVersionRange(version) {
+		tag, err := GetMatchingRemoteFilterTag(url, name, version)
+		if err != nil {
+			return "", PassError(err)
+		}
+		return tag, nil
 	} else {
 		if semver.IsValid("v" + version) {
 			version = name + "-" + version
 		}
 		return version, nil
 	}
+	var err error
 	for _, versionGetter := range versionGetters {
-		version, err := versionGetter(url, name)
+		var version string
+		version, err = versionGetter(url, name)
 		if err == nil {
 			return version, nil
 		}
 	}
-	return "", WrappedError(
-		"Unable to find version of the filter that satisfies the " +
+	return "", WrapError(
+		err, "Unable to find version of the filter that satisfies the "+
 			"specified constraints.")
 }
 
@@ -235,11 +423,15 @@ func GetLatestRemoteFilterTag(url, name string) (string, error) {
 // ListRemoteFilterTags returns the list tags of the remote filter specified by the
 // filter name and URL.
 func ListRemoteFilterTags(url, name string) ([]string, error) {
-	commandArgs := []string{"ls-remote", "--tags", "https://" + url}
-	output, err := exec.Command("git", commandArgs...).Output()
+	var output []byte
+	err := tryMirrors(url, func(url string) error {
+		var err error
+		output, err = runGitCommand(
+			url, "ls-remote", "--tags", remoteFilterGitUrl(url))
+		return err
+	})
 	if err != nil {
-		command := "git " + strings.Join(commandArgs, " ")
-		return nil, WrapErrorf(err, execCommandError, command)
+		return nil, PassError(err)
 	}
 	// Go line by line though the output
 	var tags []string
@@ -264,11 +456,15 @@ func ListRemoteFilterTags(url, name string) ([]string, error) {
 // filter URL. This function does not check whether the filter actually exists
 // in the repository.
 func GetHeadSha(url, name string) (string, error) {
-	commandArgs := []string{
-		"ls-remote", "--symref", "https://" + url, "HEAD"}
-	output, err := exec.Command("git", commandArgs...).Output()
+	var output []byte
+	err := tryMirrors(url, func(url string) error {
+		var err error
+		output, err = runGitCommand(
+			url, "ls-remote", "--symref", remoteFilterGitUrl(url), "HEAD")
+		return err
+	})
 	if err != nil {
-		return "", WrapErrorf(err, execCommandError, name)
+		return "", PassError(err)
 	}
 	// The result is on the second line.
 	lines := strings.Split(string(output), "\n")
@@ -0,0 +1,83 @@
+package regolith
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// utf8Bom is the byte order mark added to the beginning of the files by some
+// Windows editors.
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+// unmarshalJsonc parses the JSON with comments and trailing commas (see
+// jsoncToJson). It's used for the files edited by the users, like
+// config.json and filter.json.
+func unmarshalJsonc(data []byte, v interface{}) error {
+	return json.Unmarshal(jsoncToJson(data), v)
+}
+
+// jsoncToJson converts the JSON with comments to JSON. It removes the
+// single-line ("//" and "#") and block ("/* */") comments, the commas before
+// the closing brackets and the UTF-8 byte order mark. The comments are
+// replaced with spaces, so the positions in the syntax errors of the JSON
+// parser still match the original file.
+func jsoncToJson(data []byte) []byte {
+	data = bytes.TrimPrefix(data, utf8Bom)
+	result := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			result = append(result, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			result = append(result, c)
+		case c == '#', c == '/' && i+1 < len(data) && data[i+1] == '/':
+			// The new line character ends the comment and stays in the
+			// result
+			for ; i < len(data) && data[i] != '\n'; i++ {
+				result = append(result, ' ')
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := len(data)
+			if j := bytes.Index(data[i+2:], []byte("*/")); j != -1 {
+				end = i + 2 + j + 2
+			}
+			for ; i < end; i++ {
+				if data[i] == '\n' {
+					result = append(result, '\n')
+				} else {
+					result = append(result, ' ')
+				}
+			}
+			i--
+		case c == '}', c == ']':
+			// Remove the trailing comma
+			for j := len(result) - 1; j >= 0; j-- {
+				if bytes.IndexByte([]byte(" \t\r\n"), result[j]) != -1 {
+					continue
+				}
+				if result[j] == ',' {
+					result[j] = ' '
+				}
+				break
+			}
+			result = append(result, c)
+		default:
+			result = append(result, c)
+		}
+	}
+	return result
+}
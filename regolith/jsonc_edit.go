@@ -0,0 +1,337 @@
+package regolith
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// jsoncItem is a property of an object or an item of an array in the JSON
+// with comments, found by jsoncScanner. The positions are the indices of
+// the bytes of the file.
+type jsoncItem struct {
+	key        string // Empty for the items of the arrays
+	start      int    // The start of the key, or of the value in arrays
+	valueStart int
+	valueEnd   int
+	comma      int // The position of the comma after the item, or -1
+}
+
+// jsoncScanner finds the positions of the values in the JSON with comments
+// (see jsoncToJson), so they can be edited without changing the rest of the
+// file (see editJsonc).
+type jsoncScanner struct {
+	data []byte
+	pos  int
+}
+
+// invalid returns the error for the JSON that can't be parsed at the
+// current position.
+func (s *jsoncScanner) invalid() error {
+	return WrappedErrorf("Invalid JSON at the position %d.", s.pos)
+}
+
+// skipSpace moves the position past the whitespace and the comments.
+func (s *jsoncScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		next := byte(0)
+		if s.pos+1 < len(s.data) {
+			next = s.data[s.pos+1]
+		}
+		switch {
+		case c == ' ', c == '\t', c == '\r', c == '\n':
+			s.pos++
+		case c == '#', c == '/' && next == '/':
+			for s.pos < len(s.data) && s.data[s.pos] != '\n' {
+				s.pos++
+			}
+		case c == '/' && next == '*':
+			end := bytes.Index(s.data[s.pos+2:], []byte("*/"))
+			if end == -1 {
+				s.pos = len(s.data)
+			} else {
+				s.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// readString reads the string at the position.
+func (s *jsoncScanner) readString() (string, error) {
+	start := s.pos
+	if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+		return "", s.invalid()
+	}
+	for s.pos++; s.pos < len(s.data); s.pos++ {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			var result string
+			if err := json.Unmarshal(s.data[start:s.pos], &result); err != nil {
+				return "", s.invalid()
+			}
+			return result, nil
+		}
+	}
+	return "", s.invalid()
+}
+
+// skipValue moves the position past the value at the position.
+func (s *jsoncScanner) skipValue() error {
+	if s.pos >= len(s.data) {
+		return s.invalid()
+	}
+	switch s.data[s.pos] {
+	case '"':
+		_, err := s.readString()
+		return err
+	case '{', '[':
+		_, _, err := s.items()
+		return err
+	}
+	start := s.pos
+	for s.pos < len(s.data) &&
+		!bytes.ContainsRune([]byte(",:{}[]\" \t\r\n/#"), rune(s.data[s.pos])) {
+		s.pos++
+	}
+	if s.pos == start {
+		return s.invalid()
+	}
+	return nil
+}
+
+// items reads the object or the array at the position and returns its items
+// and the position of its closing bracket.
+func (s *jsoncScanner) items() ([]jsoncItem, int, error) {
+	isObject := s.data[s.pos] == '{'
+	closing := byte(']')
+	if isObject {
+		closing = '}'
+	}
+	s.pos++
+	var result []jsoncItem
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return nil, 0, s.invalid()
+		}
+		if s.data[s.pos] == closing {
+			s.pos++
+			return result, s.pos - 1, nil
+		}
+		item := jsoncItem{start: s.pos, comma: -1}
+		if isObject {
+			key, err := s.readString()
+			if err != nil {
+				return nil, 0, PassError(err)
+			}
+			item.key = key
+			s.skipSpace()
+			if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+				return nil, 0, s.invalid()
+			}
+			s.pos++
+			s.skipSpace()
+		}
+		item.valueStart = s.pos
+		if err := s.skipValue(); err != nil {
+			return nil, 0, PassError(err)
+		}
+		item.valueEnd = s.pos
+		s.skipSpace()
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			item.comma = s.pos
+			s.pos++
+		} else if s.pos >= len(s.data) || s.data[s.pos] != closing {
+			return nil, 0, s.invalid()
+		}
+		result = append(result, item)
+	}
+}
+
+// editJsonc returns the JSON with comments with the value from the path
+// (see parseConfigPath) replaced by the new value. The missing objects on
+// the path are created, and the index of an array can be equal to its
+// length, which adds the new value at the end. If the new value is nil, the
+// value is removed instead. Only the text of the changed value is
+// modified, so the comments and the order of the properties are preserved.
+func editJsonc(
+	data []byte, keys []string, newValue interface{},
+) ([]byte, error) {
+	s := &jsoncScanner{
+		data: data, pos: len(data) - len(bytes.TrimPrefix(data, utf8Bom))}
+	indent := jsoncIndent(data)
+	s.skipSpace()
+	for i, key := range keys {
+		if s.pos >= len(data) || data[s.pos] != '{' && data[s.pos] != '[' {
+			return nil, WrappedErrorf(
+				jsonPathTypeError, strings.Join(keys[:i], "->"),
+				"object or array")
+		}
+		isArray := data[s.pos] == '['
+		opening := s.pos
+		items, closing, err := s.items()
+		if err != nil {
+			return nil, PassError(err)
+		}
+		last := i == len(keys)-1
+		index := -1
+		if isArray {
+			index, err = strconv.Atoi(key)
+			if err != nil || index < 0 || index > len(items) ||
+				index == len(items) && (newValue == nil || !last) {
+				return nil, WrappedErrorf(
+					"Invalid index of an array.\nJSON Path: %s\n"+
+						"The array has %d items.",
+					strings.Join(keys[:i+1], "->"), len(items))
+			}
+			if index == len(items) {
+				index = -1
+			}
+		} else {
+			// The last duplicate is used, like by the JSON parser
+			for j, item := range items {
+				if item.key == key {
+					index = j
+				}
+			}
+		}
+		switch {
+		case index == -1 && newValue == nil:
+			return nil, WrappedErrorf(
+				jsonPathMissingError, strings.Join(keys[:i+1], "->"))
+		case index == -1:
+			value, err := setConfigValue(nil, keys[i+1:], newValue, nil)
+			if err != nil {
+				return nil, PassError(err)
+			}
+			itemKey := key
+			if isArray {
+				itemKey = ""
+			}
+			return insertJsoncItem(
+				data, items, opening, closing, itemKey, value, indent), nil
+		case last && newValue == nil:
+			return removeJsoncItem(data, items, index), nil
+		case last:
+			item := items[index]
+			value, _ := json.MarshalIndent(
+				newValue, jsoncLineIndent(data, item.start), indent)
+			return jsoncReplace(
+				data, item.valueStart, item.valueEnd, string(value)), nil
+		}
+		s.pos = items[index].valueStart
+	}
+	return nil, WrappedError("The path of the value is empty.")
+}
+
+// insertJsoncItem returns the JSON with the item added at the end of the
+// object or the array. The key is empty for the arrays.
+func insertJsoncItem(
+	data []byte, items []jsoncItem, opening, closing int, key string,
+	value interface{}, indent string,
+) []byte {
+	prefix := ""
+	if key != "" {
+		keyJson, _ := json.Marshal(key)
+		prefix = string(keyJson) + ": "
+	}
+	// Items added to the empty objects and arrays are on their own lines
+	if len(items) == 0 {
+		itemIndent := jsoncLineIndent(data, opening) + indent
+		valueJson, _ := json.MarshalIndent(value, itemIndent, indent)
+		text := "\n" + itemIndent + prefix + string(valueJson)
+		if len(bytes.TrimSpace(data[opening+1:closing])) == 0 {
+			return jsoncReplace(
+				data, opening+1, closing,
+				text+"\n"+jsoncLineIndent(data, opening))
+		}
+		return jsoncReplace(data, opening+1, opening+1, text)
+	}
+	lastItem := items[len(items)-1]
+	// The objects and arrays written in a single line stay in a single line
+	if !bytes.Contains(data[opening:lastItem.start], []byte("\n")) {
+		valueJson, _ := json.Marshal(value)
+		return jsoncReplace(
+			data, lastItem.valueEnd, lastItem.valueEnd,
+			", "+prefix+string(valueJson))
+	}
+	itemIndent := jsoncLineIndent(data, lastItem.start)
+	valueJson, _ := json.MarshalIndent(value, itemIndent, indent)
+	text := itemIndent + prefix + string(valueJson)
+	if lastItem.comma != -1 { // Keep the trailing comma
+		return jsoncReplace(
+			data, lastItem.comma+1, lastItem.comma+1, "\n"+text+",")
+	}
+	return jsoncReplace(
+		data, lastItem.valueEnd, lastItem.valueEnd, ",\n"+text)
+}
+
+// removeJsoncItem returns the JSON without the item with the index. The line
+// of the item is removed too, if it doesn't have anything else.
+func removeJsoncItem(data []byte, items []jsoncItem, index int) []byte {
+	item := items[index]
+	start, end := item.start, item.valueEnd
+	if item.comma != -1 {
+		end = item.comma + 1
+	} else if index > 0 {
+		// The comma of the previous item becomes a trailing comma
+		comma := items[index-1].comma
+		data = jsoncReplace(data, comma, comma+1, "")
+		start, end = start-1, end-1
+	}
+	lineStart, lineEnd := start, end
+	for lineStart > 0 && (data[lineStart-1] == ' ' || data[lineStart-1] == '\t') {
+		lineStart--
+	}
+	for lineEnd < len(data) && bytes.IndexByte([]byte(" \t\r"), data[lineEnd]) != -1 {
+		lineEnd++
+	}
+	if (lineStart == 0 || data[lineStart-1] == '\n') &&
+		lineEnd < len(data) && data[lineEnd] == '\n' {
+		start, end = lineStart, lineEnd+1
+	} else if item.comma != -1 {
+		end = lineEnd
+	} else {
+		start = lineStart
+	}
+	return jsoncReplace(data, start, end, "")
+}
+
+// jsoncReplace returns a copy of the data with the bytes between the start
+// and the end replaced by the text.
+func jsoncReplace(data []byte, start, end int, text string) []byte {
+	result := make([]byte, 0, len(data)-(end-start)+len(text))
+	result = append(result, data[:start]...)
+	result = append(result, text...)
+	return append(result, data[end:]...)
+}
+
+// jsoncLineIndent returns the whitespace at the beginning of the line with
+// the position.
+func jsoncLineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < pos && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// jsoncIndent returns the indentation used by the file: the whitespace at
+// the beginning of its first indented line, or a tab.
+func jsoncIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "\t"
+}
@@ -0,0 +1,131 @@
+package regolith
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root element of the JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is the run of a profile in the JUnit XML report.
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is the execution of a filter in the JUnit XML report.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJunitReport saves the results of the filters of the run of the
+// profile to the JunitOutput file. Every execution of a filter is a test
+// case, with the captured output of the filter. If the run failed outside of
+// the filters (for example during the export), the error is reported as an
+// additional test case.
+func writeJunitReport(profileName string, runErr error) error {
+	total := time.Since(timedRunStart)
+	suite := junitTestSuite{
+		Name:      profileName,
+		Time:      junitSeconds(total),
+		Timestamp: timedRunStart.Format("2006-01-02T15:04:05"),
+		Cases:     []junitTestCase{},
+	}
+	for _, result := range currentFilterResults() {
+		testCase := junitTestCase{
+			Name:      result.Filter,
+			ClassName: strings.Join(result.Profiles, "."),
+			Time:      junitSeconds(result.Duration),
+			SystemOut: strings.Join(result.Output, "\n"),
+		}
+		switch result.Status {
+		case "failed":
+			testCase.Failure = &junitMessage{
+				Message: firstLine(result.Message), Text: result.Message}
+			suite.Failures++
+		case "skipped":
+			testCase.Skipped = &junitMessage{Message: result.Message}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	if runErr != nil && suite.Failures == 0 {
+		message := PassError(runErr).Error()
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "run",
+			ClassName: profileName,
+			Time:      junitSeconds(total),
+			Error: &junitMessage{
+				Message: firstLine(message), Text: message},
+		})
+		suite.Errors++
+	}
+	suite.Tests = len(suite.Cases)
+	report := junitTestSuites{
+		Name:     "regolith",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	data, err := xml.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return WrapError(err, "Failed to encode the JUnit report.")
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	err = os.MkdirAll(filepath.Dir(JunitOutput), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(JunitOutput))
+	}
+	err = os.WriteFile(JunitOutput, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, JunitOutput)
+	}
+	Logger.Infof("Saved the JUnit report to %q.", JunitOutput)
+	return nil
+}
+
+// junitSeconds formats the duration in seconds, as used by the "time"
+// attributes of the JUnit XML report.
+func junitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+// firstLine returns the first line of the text.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
@@ -0,0 +1,140 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// listProject prints the profiles of the project with their filters, and
+// the filter definitions that aren't used by any of the profiles, for the
+// "regolith list" command.
+func listProject(
+	config *Config, dotRegolithPath string, lockFile *LockFile,
+) {
+	profileNames := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	used := make(map[string]FilterInstaller)
+	Logger.Infof("Profiles: %d", len(profileNames))
+	for _, name := range profileNames {
+		profile := config.Profiles[name]
+		Logger.Infof(
+			"  %s (export target: %s)", name, profile.ExportTarget.Target)
+		if len(profile.Filters) == 0 {
+			Logger.Info("    no filters")
+		}
+		for i, filter := range profile.Filters {
+			status := "enabled"
+			disabled, err := filter.IsDisabled(
+				RunContext{Config: config, Profile: name})
+			if err != nil {
+				status = "invalid \"when\" expression"
+			} else if disabled {
+				status = "disabled"
+			}
+			if profileFilter, ok := filter.(*ProfileFilter); ok {
+				Logger.Infof(
+					"    %d. profile: %s (%s)", i+1, profileFilter.Profile,
+					status)
+				continue
+			}
+			definition, ok := config.FilterDefinitions[filter.GetId()]
+			if !ok {
+				Logger.Infof(
+					"    %d. %s (not defined, %s)", i+1, filter.GetId(), status)
+				continue
+			}
+			used[filter.GetId()] = definition
+			Logger.Infof(
+				"    %d. %s (%s, %s)", i+1, filter.GetId(),
+				describeFilterDefinition(
+					filter.GetId(), definition, dotRegolithPath, lockFile),
+				status)
+		}
+	}
+	// The remote filters can use other remote filters, which don't have to
+	// be in the profiles
+	used = withNestedFilters(used, dotRegolithPath)
+	var unused []string
+	for name := range config.FilterDefinitions {
+		if _, ok := used[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	sort.Strings(unused)
+	Logger.Infof("Filter definitions not used by any profile: %d", len(unused))
+	for _, name := range unused {
+		Logger.Infof(
+			"  %s (%s)", name,
+			describeFilterDefinition(
+				name, config.FilterDefinitions[name], dotRegolithPath,
+				lockFile))
+	}
+}
+
+// describeFilterDefinition returns the type of the filter definition and,
+// for the remote filters, the version from the config and the resolved
+// version: the locked version, the installed version if the filter isn't
+// locked, or "not installed".
+func describeFilterDefinition(
+	name string, definition FilterInstaller, dotRegolithPath string,
+	lockFile *LockFile,
+) string {
+	remoteFilter, ok := definition.(*RemoteFilterDefinition)
+	if !ok {
+		return filterDefinitionType(definition)
+	}
+	resolved := "not installed"
+	downloadPath := remoteFilter.GetDownloadPath(dotRegolithPath)
+	if _, err := os.Stat(downloadPath); err == nil {
+		if version, ok := lockFile.Resolved(name, remoteFilter); ok {
+			resolved = version
+		} else if version, err := remoteFilter.InstalledVersion(
+			dotRegolithPath); err == nil {
+			resolved = version
+		} else {
+			resolved = "unknown version"
+		}
+	}
+	version := remoteFilter.Version
+	if remoteFilter.IsArchive() {
+		// The version of the archive filters is their checksum
+		version = "archive"
+	}
+	return fmt.Sprintf("remote, %s -> %s", version, resolved)
+}
+
+// filterDefinitionType returns the "runWith" value of the local filter
+// definition.
+func filterDefinitionType(definition FilterInstaller) string {
+	switch definition.(type) {
+	case *JavaFilterDefinition:
+		return "java"
+	case *DotNetFilterDefinition:
+		return "dotnet"
+	case *NimFilterDefinition:
+		return "nim"
+	case *DenoFilterDefinition:
+		return "deno"
+	case *NodeJSFilterDefinition:
+		return "nodejs"
+	case *PythonFilterDefinition:
+		return "python"
+	case *ShellFilterDefinition:
+		return "shell"
+	case *ExeFilterDefinition:
+		return "exe"
+	case *LuaFilterDefinition:
+		return "lua"
+	case *DockerFilterDefinition:
+		return "docker"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", definition), "*regolith.")
+}
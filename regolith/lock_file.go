@@ -0,0 +1,281 @@
+package regolith
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// LockFilePath is the path to the file with the exact versions of the
+// installed remote filters.
+const LockFilePath = "regolith-lock.json"
+
+// integrityExcludedDirs are the names of the directories ignored by the
+// integrity hashes of the filters. They're created in the directories of the
+// filters while installing their dependencies or running them.
+var integrityExcludedDirs = []string{".git", "node_modules", "__pycache__"}
+
+// LockFile is the content of "regolith-lock.json". It records the exact
+// versions of the remote filters, so "regolith install-all" installs the same
+// versions on every machine, even if the filter definitions use versions that
+// change over time (like "HEAD", "latest" or branch names). Only the
+// "regolith update" and "regolith update-all" commands change the versions of
+// the filters that are already in the lock file.
+type LockFile struct {
+	Filters map[string]LockedFilter `json:"filters"`
+
+	// mutex protects the Filters map, when the filters are installed in
+	// parallel
+	mutex sync.Mutex
+}
+
+// LockedFilter is the exact version of a remote filter from the lock file.
+type LockedFilter struct {
+	// Url and Version are the properties of the filter definition used for
+	// resolving the version. The locked version is ignored if they change.
+	Url     string `json:"url"`
+	Version string `json:"version"`
+	// Resolved is the exact version of the filter, a semver version of a
+	// tag, a commit SHA or the checksum of an archive filter.
+	Resolved string `json:"resolved"`
+	// Integrity is the hash of the files of the filter, used for detecting
+	// the changes of the downloaded files (see filterIntegrity).
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// LoadLockFile loads the lock file of the project. If the file doesn't
+// exist, it returns an empty lock file.
+func LoadLockFile() (*LockFile, error) {
+	return loadLockFileFrom(LockFilePath)
+}
+
+// loadLockFileFrom loads the lock file from the path. It's used for reading
+// the lock files of the other projects.
+func loadLockFileFrom(path string) (*LockFile, error) {
+	result := &LockFile{Filters: make(map[string]LockedFilter)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	if result.Filters == nil {
+		result.Filters = make(map[string]LockedFilter)
+	}
+	return result, nil
+}
+
+// Save saves the lock file in the project's root directory.
+func (l *LockFile) Save() error {
+	data, _ := json.MarshalIndent(l, "", "\t") // no error
+	err := os.WriteFile(LockFilePath, append(data, '\n'), 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, LockFilePath)
+	}
+	return nil
+}
+
+// Resolved returns the locked version of the remote filter. It returns false
+// if the filter is not in the lock file or if its definition changed after
+// locking.
+func (l *LockFile) Resolved(name string, filter *RemoteFilterDefinition) (string, bool) {
+	l.mutex.Lock()
+	locked, ok := l.Filters[name]
+	l.mutex.Unlock()
+	if !ok || locked.Resolved == "" {
+		return "", false
+	}
+	if locked.Url != filter.Url || locked.Version != filter.Version {
+		Logger.Infof(
+			"The definition of the %q filter changed since it was locked. "+
+				"Resolving its version again.", name)
+		return "", false
+	}
+	return locked.Resolved, true
+}
+
+// Lock saves the exact version of the installed remote filter in the lock
+// file. The version is read from the filter.json file of the filter. The
+// versions that aren't exact (branches and tags that aren't versions of the
+// filter) are resolved to commit SHAs.
+func (l *LockFile) Lock(
+	name string, filter *RemoteFilterDefinition, dotRegolithPath string,
+) error {
+	installedVersion, err := filter.InstalledVersion(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	resolved := trimFilterPrefix(installedVersion, name)
+	if resolved == installedVersion && !filter.IsArchive() {
+		resolved = resolveFilterCommit(filter.Url, name, installedVersion)
+	}
+	integrity, err := filterIntegrity(filter.GetDownloadPath(dotRegolithPath))
+	if err != nil {
+		return PassError(err)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.Filters[name] = LockedFilter{
+		Url:       filter.Url,
+		Version:   filter.Version,
+		Resolved:  resolved,
+		Integrity: integrity,
+	}
+	return nil
+}
+
+// Verify checks if the files of the installed remote filter match the
+// integrity hash from the lock file. The filters locked without the hash
+// (by older versions of Regolith) get the hash of their current files.
+func (l *LockFile) Verify(
+	name string, filter *RemoteFilterDefinition, dotRegolithPath string,
+) error {
+	l.mutex.Lock()
+	locked, ok := l.Filters[name]
+	l.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	integrity, err := filterIntegrity(filter.GetDownloadPath(dotRegolithPath))
+	if err != nil {
+		return PassError(err)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if locked.Integrity == "" {
+		locked.Integrity = integrity
+		l.Filters[name] = locked
+		return nil
+	}
+	if locked.Integrity != integrity {
+		return WrappedErrorf(
+			"The files of the filter don't match the integrity hash from "+
+				"the lock file.\n"+
+				"Filter: %s\nVersion: %s\n"+
+				"Expected hash: %s\nActual hash: %s\n"+
+				"The repository of the filter might have been modified after "+
+				"the filter was locked, for example by a force push. If you "+
+				"trust the new files, you can lock them using command:\n"+
+				"regolith update %s",
+			name, locked.Resolved, locked.Integrity, integrity, name)
+	}
+	return nil
+}
+
+// filterIntegrity returns the hash of the files of a filter, in the
+// "sha256-<hex>" format. The hash includes the paths and the contents of the
+// files, and ignores the integrityExcludedDirs directories.
+func filterIntegrity(filterPath string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(filterPath, func(
+		path string, d fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, excluded := range integrityExcludedDirs {
+				if d.Name() == excluded {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", WrapErrorf(err, osWalkError, filterPath)
+	}
+	sort.Strings(paths)
+	hash := sha256.New()
+	for _, path := range paths {
+		relPath, err := filepath.Rel(filterPath, path)
+		if err != nil {
+			return "", WrapErrorf(err, osRelError, filterPath, path)
+		}
+		// The null bytes separate the paths and the contents of the files
+		relPath = filepath.ToSlash(relPath)
+		hash.Write([]byte(relPath + "\x00"))
+		file, err := os.Open(path)
+		if err != nil {
+			return "", WrapErrorf(err, fileReadError, path)
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", WrapErrorf(err, fileReadError, path)
+		}
+		hash.Write([]byte{0})
+	}
+	return "sha256-" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isCommitSha returns true if the version is a full SHA of a git commit.
+func isCommitSha(version string) bool {
+	if len(version) != 40 {
+		return false
+	}
+	for _, c := range version {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveFilterCommit returns the SHA of the commit that the git reference
+// (a branch or a tag) of the remote filter points to, so the filter can be
+// locked to the exact version. The references that already are exact
+// versions (commit SHAs and version tags of the filter) are returned
+// unchanged. If the reference can't be resolved, it's also returned
+// unchanged.
+func resolveFilterCommit(url, name, ref string) string {
+	if isCommitSha(ref) || trimFilterPrefix(ref, name) != ref ||
+		semver.IsValid("v"+ref) {
+		return ref
+	}
+	var output []byte
+	err := tryMirrors(url, func(url string) error {
+		var err error
+		output, err = gitCommand(
+			"ls-remote", remoteFilterGitUrl(url), ref).Output()
+		return err
+	})
+	if err != nil {
+		Logger.Debugf("Failed to resolve %q reference of %q: %s", ref, url, err)
+		return ref
+	}
+	tagSha := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[1] {
+		case "refs/heads/" + ref, "refs/tags/" + ref + "^{}":
+			// Branch or the commit of an annotated tag
+			return fields[0]
+		case "refs/tags/" + ref:
+			tagSha = fields[0]
+		}
+	}
+	if tagSha != "" {
+		return tagSha
+	}
+	return ref
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
@@ -13,6 +14,17 @@ import (
 
 var printStackTraces = true
 
+// LogFormat is the format of the log messages, set by the "--log-format"
+// flag: "text" (default) for the colored messages, "json" for one JSON
+// object per line, with the fields describing the events, like "filter",
+// "phase" and "duration", or "github" for the colored messages with the
+// workflow commands of GitHub Actions (see githubLogs).
+var LogFormat = "text"
+
+// traceFileOperations makes Regolith log every file that it copies, moves
+// or removes ("-vv" flag, see Verbosity).
+var traceFileOperations = false
+
 var Logger *zap.SugaredLogger
 var LoggerLevel zap.AtomicLevel
 
@@ -42,9 +54,53 @@ func InitLogging(dev bool) {
 		fmt.Printf("%s", err.Error())
 	}
 	LoggerLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
-	if dev {
+	if dev || Verbosity > 0 {
 		LoggerLevel.SetLevel(zap.DebugLevel)
+	} else if Verbosity < 0 {
+		LoggerLevel.SetLevel(zap.WarnLevel)
+	}
+	traceFileOperations = Verbosity > 1
+	if jsonLogs() {
+		color.NoColor = true // The messages can't contain the color codes
+		logger, _ := zap.Config{
+			Level:             LoggerLevel,
+			Encoding:          "json",
+			OutputPaths:       []string{"stdout"},
+			ErrorOutputPaths:  []string{"stderr"},
+			DisableStacktrace: true,
+			DisableCaller:     true,
+			EncoderConfig: zapcore.EncoderConfig{
+				TimeKey:        "time",
+				LevelKey:       "level",
+				MessageKey:     "message",
+				LineEnding:     zapcore.DefaultLineEnding,
+				EncodeLevel:    zapcore.LowercaseLevelEncoder,
+				EncodeTime:     zapcore.ISO8601TimeEncoder,
+				EncodeDuration: zapcore.SecondsDurationEncoder,
+			},
+		}.Build(zap.Hooks(countWarnings))
+		Logger = logger.Sugar()
+	} else {
+		Logger = newTextLogger(dev)
 	}
+	// The default level from the user config, config.json can override it
+	if userConfig, err := LoadUserConfig(); err == nil {
+		applyLogLevel(userConfig.LogLevel)
+	}
+}
+
+// SetLogger replaces the logger of Regolith, for the programs that embed it.
+// The level decides which messages are sent to the logger. InitLogging
+// doesn't change the logger afterwards, and the errors don't include the
+// stack traces.
+func SetLogger(logger *zap.SugaredLogger, level zap.AtomicLevel) {
+	printStackTraces = false
+	Logger = logger
+	LoggerLevel = level
+}
+
+// newTextLogger creates the logger that prints the colored messages.
+func newTextLogger(dev bool) *zap.SugaredLogger {
 	logger, _ := zap.Config{
 		Development:       dev,
 		Level:             LoggerLevel,
@@ -92,7 +148,65 @@ func InitLogging(dev bool) {
 
 			},
 		},
-	}.Build()
+	}.Build(zap.Hooks(countWarnings))
 	defer logger.Sync() // flushes buffer, if any
-	Logger = logger.Sugar()
+	return logger.Sugar()
+}
+
+// countWarnings is the hook of the loggers created by InitLogging, which
+// counts the warnings for the summary of the run (see printRunSummary).
+func countWarnings(entry zapcore.Entry) error {
+	if entry.Level == zap.WarnLevel {
+		atomic.AddInt64(&runWarnings, 1)
+	}
+	return nil
+}
+
+// logLevels are the valid values of the "logLevel" property of config.json.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// applyLogLevel changes the level of the logger to the "logLevel" from
+// config.json or the user config. The level is ignored when Regolith runs with the "--debug"
+// flag or the verbosity flags (see Verbosity), so the flags always win.
+func applyLogLevel(level string) {
+	// printStackTraces means --debug
+	if level == "" || printStackTraces || Verbosity != 0 {
+		return
+	}
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(level)); err == nil {
+		LoggerLevel.SetLevel(zapLevel)
+	}
+}
+
+// traceFilef logs a debug message about an operation on a single file, if
+// the file operations are traced (see traceFileOperations).
+func traceFilef(template string, args ...interface{}) {
+	if traceFileOperations {
+		Logger.Debugf(template, args...)
+	}
+}
+
+// jsonLogs returns true if the log messages use the JSON format (see
+// LogFormat).
+func jsonLogs() bool {
+	return LogFormat == "json"
+}
+
+// logFields returns the key-value pairs of the fields of a log message,
+// if the messages use the JSON format. The text messages don't print them.
+func logFields(keysAndValues ...interface{}) []interface{} {
+	if !jsonLogs() {
+		return nil
+	}
+	return keysAndValues
+}
+
+// outputLogger returns the logger for the output of a process run by the
+// filter with the label, which adds the "filter" field to the JSON messages.
+func outputLogger(label string) *zap.SugaredLogger {
+	if !jsonLogs() {
+		return Logger
+	}
+	return Logger.With("filter", label)
 }
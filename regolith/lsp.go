@@ -0,0 +1,846 @@
+package regolith
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The codes of the errors of the JSON-RPC protocol used by the language
+// server.
+const (
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+	lspInternalError  = -32603
+)
+
+// The severities of the diagnostics and the kinds of the completion items of
+// the Language Server Protocol.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+	lspKindProperty    = 10
+	lspKindValue       = 12
+)
+
+// lspRequest is a request or a notification (without the ID) sent by the
+// editor to the language server.
+type lspRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// lspResponse is the successful response to a request.
+type lspResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+// lspErrorResponse is the response to a request that failed.
+type lspErrorResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Error   lspError        `json:"error"`
+}
+
+// lspNotification is a notification sent by the language server to the
+// editor.
+type lspNotification struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// lspError is the error of a request, sent to the editor.
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return e.Message
+}
+
+// lspParams are the parameters of the requests and the notifications about
+// the text documents handled by the language server.
+type lspParams struct {
+	TextDocument struct {
+		Uri  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	Position       lspPosition `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Documentation string `json:"documentation,omitempty"`
+	InsertText    string `json:"insertText,omitempty"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+}
+
+// lspDocument is a config.json or a filter.json file opened in the editor.
+type lspDocument struct {
+	// path is the path to the file, used for finding the project root and
+	// the installed filters.
+	path string
+	// schema is the schema of the file, the schema of config.json or of
+	// filter.json.
+	schema *jsonSchema
+	// text is the text of the file in the editor.
+	text []byte
+	// data is the text converted to JSON (see jsoncToJson), and shift is the
+	// number of the bytes removed from the beginning of the text (the byte
+	// order mark).
+	data  []byte
+	shift int
+	// value is the parsed file. If the text isn't valid JSON, it's the
+	// value from the last time it was.
+	value interface{}
+}
+
+// lspServer is the language server of the config.json and filter.json
+// files, started by Lsp.
+type lspServer struct {
+	reader       *bufio.Reader
+	writer       io.Writer
+	configSchema *jsonSchema
+	filterSchema *jsonSchema
+	documents    map[string]*lspDocument
+	shutdown     bool
+}
+
+// Lsp runs the language server of Regolith, which communicates with the
+// editor over the standard input and output using the Language Server
+// Protocol. It provides the completion, the hover documentation and the
+// diagnostics of the config.json files of the projects and the filter.json
+// files of the filters. The settings of the remote filters are checked with
+// the "settingsSchema" from the filter.json files of the installed filters.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Lsp(debug bool) error {
+	// The standard output is used by the protocol, so the log messages are
+	// printed to the standard error output, which editors show in their
+	// logs
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	if debug {
+		level.SetLevel(zap.DebugLevel)
+	}
+	SetLogger(zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+			LevelKey:    "level",
+			MessageKey:  "message",
+			EncodeLevel: zapcore.CapitalLevelEncoder,
+		}),
+		zapcore.Lock(os.Stderr), level)).Sugar(), level)
+	configSchema, err := loadConfigSchema()
+	if err != nil {
+		return PassError(err)
+	}
+	server := &lspServer{
+		reader:       bufio.NewReader(os.Stdin),
+		writer:       os.Stdout,
+		configSchema: configSchema,
+		filterSchema: filterJsonSchema(configSchema),
+		documents:    make(map[string]*lspDocument),
+	}
+	return server.serve()
+}
+
+// filterJsonSchema returns the schema of the filter.json files. The
+// subfilters can use the properties of the filters and of the filter
+// definitions from config.json.
+func filterJsonSchema(configSchema *jsonSchema) *jsonSchema {
+	subfilter := &jsonSchema{
+		Type: "object", Properties: make(map[string]*jsonSchema)}
+	for _, name := range []string{"filterDefinition", "filter"} {
+		for key, property := range configSchema.Definitions[name].Properties {
+			subfilter.Properties[key] = property
+		}
+	}
+	return &jsonSchema{
+		Type:     "object",
+		Required: []string{"filters"},
+		Properties: map[string]*jsonSchema{
+			"description": {
+				Type:        "string",
+				Description: "The description of the filter, displayed on the website.",
+			},
+			"filters": {
+				Type:        "array",
+				Items:       subfilter,
+				Description: "The subfilters run by the filter, in order.",
+			},
+			"version": {
+				Type:        "string",
+				Description: "The installed version of the filter, added by Regolith.",
+			},
+			"settingsSchema": {
+				Type:        "object",
+				Description: "The JSON Schema of the settings of the filter, used by the editors for checking the settings in config.json.",
+			},
+		},
+		Definitions: configSchema.Definitions,
+	}
+}
+
+// serve handles the messages from the editor until the "exit" notification
+// or the end of the input.
+func (s *lspServer) serve() error {
+	for {
+		request, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return WrapError(err, "Failed to read the message from the editor.")
+		}
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return WrappedError(
+					"The editor stopped the language server without " +
+						"shutting it down.")
+			}
+			return nil
+		}
+		result, err := s.handle(request)
+		if request.Id == nil {
+			// Notifications don't have responses
+			if err != nil {
+				Logger.Warnf("Failed to handle %q: %s", request.Method, err)
+			}
+			continue
+		}
+		if err != nil {
+			responseError, ok := err.(*lspError)
+			if !ok {
+				responseError = &lspError{lspInternalError, err.Error()}
+			}
+			err = s.write(lspErrorResponse{"2.0", request.Id, *responseError})
+		} else {
+			err = s.write(lspResponse{"2.0", request.Id, result})
+		}
+		if err != nil {
+			return WrapError(err, "Failed to send the message to the editor.")
+		}
+	}
+}
+
+// read reads a message with the "Content-Length" header from the editor.
+func (s *lspServer) read() (*lspRequest, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value := strings.TrimPrefix(line, "Content-Length:"); value != line {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, WrapErrorf(err, "Invalid header: %s", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, WrappedError("The message doesn't have the Content-Length header.")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return nil, err
+	}
+	var request lspRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, WrapError(err, "Invalid message.")
+	}
+	return &request, nil
+}
+
+// write sends a message to the editor.
+func (s *lspServer) write(message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// handle handles a request or a notification and returns the result of the
+// request.
+func (s *lspServer) handle(request *lspRequest) (interface{}, error) {
+	var params lspParams
+	if request.Params != nil {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.Uri
+	switch request.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": 1, // The full text on every change
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"\""},
+				},
+				"hoverProvider": true,
+			},
+			"serverInfo": map[string]interface{}{"name": "regolith"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.updateDocument(uri, []byte(params.TextDocument.Text))
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		s.updateDocument(
+			uri, []byte(params.ContentChanges[len(params.ContentChanges)-1].Text))
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		if _, ok := s.documents[uri]; !ok {
+			return nil, nil
+		}
+		delete(s.documents, uri)
+		return nil, s.write(lspNotification{
+			"2.0", "textDocument/publishDiagnostics", map[string]interface{}{
+				"uri": uri, "diagnostics": []lspDiagnostic{}}})
+	case "textDocument/completion":
+		document, ok := s.documents[uri]
+		if !ok {
+			return nil, nil
+		}
+		return s.complete(document, params.Position), nil
+	case "textDocument/hover":
+		document, ok := s.documents[uri]
+		if !ok {
+			return nil, nil
+		}
+		return s.hover(document, params.Position), nil
+	}
+	if request.Id != nil {
+		return nil, &lspError{
+			lspMethodNotFound,
+			fmt.Sprintf("Unsupported method: %s", request.Method)}
+	}
+	return nil, nil
+}
+
+// updateDocument saves the new text of the document. Only the config.json
+// and filter.json files are handled, the other documents are ignored.
+func (s *lspServer) updateDocument(uri string, text []byte) {
+	document, ok := s.documents[uri]
+	if !ok {
+		path := uriToPath(uri)
+		var schema *jsonSchema
+		switch filepath.Base(path) {
+		case ConfigFilePath:
+			schema = s.configSchema
+		case "filter.json":
+			schema = s.filterSchema
+		default:
+			return
+		}
+		document = &lspDocument{path: path, schema: schema}
+		s.documents[uri] = document
+	}
+	document.text = text
+	document.data = jsoncToJson(text)
+	document.shift = 0
+	if bytes.HasPrefix(text, utf8Bom) {
+		document.shift = len(utf8Bom)
+	}
+	var value interface{}
+	if json.Unmarshal(document.data, &value) == nil {
+		document.value = value
+	}
+}
+
+// uriToPath converts the "file://" URI of a document to its path.
+func uriToPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	path := parsed.Path
+	if runtime.GOOS == "windows" {
+		// "/C:/project/config.json"
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// isConfig returns true if the document is a config.json file.
+func (s *lspServer) isConfig(document *lspDocument) bool {
+	return document.schema == s.configSchema
+}
+
+// publishDiagnostics sends the problems found in the document to the editor.
+func (s *lspServer) publishDiagnostics(uri string) error {
+	document, ok := s.documents[uri]
+	if !ok {
+		return nil
+	}
+	return s.write(lspNotification{
+		"2.0", "textDocument/publishDiagnostics", map[string]interface{}{
+			"uri": uri, "diagnostics": s.diagnostics(document)}})
+}
+
+// diagnostics returns the syntax errors of the document and the problems
+// found by the schema. The config.json files are also checked for the
+// references to the filters and the profiles that don't exist, and for the
+// settings that don't match the settings schemas of the installed filters.
+func (s *lspServer) diagnostics(document *lspDocument) []lspDiagnostic {
+	result := []lspDiagnostic{}
+	var value interface{}
+	if err := json.Unmarshal(document.data, &value); err != nil {
+		offset := 0
+		if syntaxError, ok := err.(*json.SyntaxError); ok {
+			offset = int(syntaxError.Offset) - 1
+		}
+		return append(result, s.diagnostic(
+			document, jsonSpan{offset, offset + 1}, lspSeverityError,
+			err.Error()))
+	}
+	spans := locateJsonValues(document.data)
+	report := func(problems []configSchemaError, severity int) {
+		for _, problem := range problems {
+			message := problem.message
+			if problem.suggestion != "" {
+				message += "\n" + problem.suggestion
+			}
+			result = append(result, s.diagnostic(
+				document, spanOfJsonPath(spans, problem.path), severity,
+				message))
+		}
+	}
+	var problems []configSchemaError
+	config, ok := value.(map[string]interface{})
+	if !s.isConfig(document) || !ok {
+		document.schema.validate(document.schema, value, "", &problems)
+		report(problems, lspSeverityError)
+		return result
+	}
+	// The config is validated with the user defaults and the local config
+	// merged, like when running Regolith
+	merged := s.mergedConfig(document, config)
+	s.configSchema.validate(s.configSchema, merged, "", &problems)
+	problems = append(problems, s.referenceProblems(config, merged)...)
+	report(problems, lspSeverityError)
+	report(s.settingsProblems(document, config, merged), lspSeverityWarning)
+	return result
+}
+
+// diagnostic creates the diagnostic of the problem at the span of the
+// document.
+func (s *lspServer) diagnostic(
+	document *lspDocument, span jsonSpan, severity int, message string,
+) lspDiagnostic {
+	return lspDiagnostic{
+		Range: lspRange{
+			Start: offsetToPosition(document.text, span.start+document.shift),
+			End:   offsetToPosition(document.text, span.end+document.shift),
+		},
+		Severity: severity,
+		Source:   "regolith",
+		Message:  message,
+	}
+}
+
+// spanOfJsonPath returns the location of the value at the JSON path, or the
+// location of the closest parent value if the value isn't in the document
+// (for example, if it's missing or it comes from the local config).
+func spanOfJsonPath(spans map[string]jsonSpan, path string) jsonSpan {
+	for {
+		if span, ok := spans[path]; ok {
+			return span
+		}
+		i := strings.LastIndex(path, "->")
+		if i == -1 {
+			return spans[""]
+		}
+		path = path[:i]
+	}
+}
+
+// projectRoot returns the root of the project of the config.json document.
+func (s *lspServer) projectRoot(document *lspDocument) string {
+	return filepath.Dir(document.path)
+}
+
+// mergedConfig returns the config with the user defaults and the local
+// config of the project merged (see LoadConfigAsMap). If they can't be
+// loaded, the config is returned unchanged.
+func (s *lspServer) mergedConfig(
+	document *lspDocument, config map[string]interface{},
+) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	merged, err := mergeUserProjectDefaults(config)
+	if err != nil {
+		Logger.Debugf("Failed to merge the user defaults: %s", err)
+		merged = config
+	}
+	withLocal, err := mergeLocalConfig(merged, s.projectRoot(document))
+	if err != nil {
+		Logger.Debugf("Failed to merge the local config: %s", err)
+		return merged
+	}
+	return withLocal
+}
+
+// profileFilters calls the function for every filter of the profiles of
+// config.json, with its JSON path.
+func profileFilters(
+	config map[string]interface{},
+	f func(path string, filter map[string]interface{}),
+) {
+	profiles, _ := jsonValueAt(config, []string{"regolith", "profiles"}).(map[string]interface{})
+	for profileName, profile := range profiles {
+		profile, _ := profile.(map[string]interface{})
+		filters, _ := profile["filters"].([]interface{})
+		for i, filter := range filters {
+			if filter, ok := filter.(map[string]interface{}); ok {
+				f(fmt.Sprintf(
+					"regolith->profiles->%s->filters->%d", profileName, i),
+					filter)
+			}
+		}
+	}
+}
+
+// referenceProblems finds the filters and the profiles used by the profiles
+// of the config that don't exist in the merged config.
+func (s *lspServer) referenceProblems(
+	config, merged map[string]interface{},
+) []configSchemaError {
+	var result []configSchemaError
+	filterNames := jsonObjectKeys(merged, "regolith", "filterDefinitions")
+	profileNames := jsonObjectKeys(merged, "regolith", "profiles")
+	check := func(path, kind, name string, names []string) {
+		if stringInSlice(name, names) {
+			return
+		}
+		problem := configSchemaError{
+			path:    path,
+			message: fmt.Sprintf("The %q %s doesn't exist.", name, kind),
+		}
+		if closest := closestString(name, names); closest != "" {
+			problem.suggestion = fmt.Sprintf("Did you mean %q?", closest)
+		}
+		result = append(result, problem)
+	}
+	profiles, _ := jsonValueAt(config, []string{"regolith", "profiles"}).(map[string]interface{})
+	for profileName, profile := range profiles {
+		profile, _ := profile.(map[string]interface{})
+		if extends, ok := profile["extends"].(string); ok {
+			check(
+				"regolith->profiles->"+profileName+"->extends", "profile",
+				extends, profileNames)
+		}
+	}
+	profileFilters(config, func(path string, filter map[string]interface{}) {
+		if name, ok := filter["profile"].(string); ok {
+			check(path+"->profile", "profile", name, profileNames)
+		} else if name, ok := filter["filter"].(string); ok {
+			check(path+"->filter", "filter", name, filterNames)
+		}
+	})
+	return result
+}
+
+// settingsProblems checks the settings of the filters of the config with the
+// settings schemas of the installed filters.
+func (s *lspServer) settingsProblems(
+	document *lspDocument, config, merged map[string]interface{},
+) []configSchemaError {
+	var result []configSchemaError
+	profileFilters(config, func(path string, filter map[string]interface{}) {
+		settings, ok := filter["settings"]
+		if !ok {
+			return
+		}
+		name, _ := filter["filter"].(string)
+		schema := s.settingsSchema(document, merged, name)
+		if schema != nil {
+			schema.validate(schema, settings, path+"->settings", &result)
+		}
+	})
+	return result
+}
+
+// settingsSchema returns the "settingsSchema" from the filter.json file of
+// the installed remote filter, or nil if the filter isn't installed or it
+// doesn't have the schema.
+func (s *lspServer) settingsSchema(
+	document *lspDocument, config map[string]interface{}, name string,
+) *jsonSchema {
+	definition, _ := jsonValueAt(
+		config, []string{"regolith", "filterDefinitions", name},
+	).(map[string]interface{})
+	if definition == nil {
+		return nil
+	}
+	if _, ok := definition["runWith"]; ok {
+		// Only the remote filters have the filter.json files
+		return nil
+	}
+	root := s.projectRoot(document)
+	dotRegolithPath := filepath.Join(root, ".regolith")
+	if useAppData, _ := jsonValueAt(
+		config, []string{"regolith", "useAppData"}).(bool); useAppData {
+		path, err := GetDotRegolith(true, true, root)
+		if err != nil {
+			Logger.Debugf("Failed to find the .regolith folder: %s", err)
+			return nil
+		}
+		dotRegolithPath = path
+	}
+	remoteFilter := &RemoteFilterDefinition{
+		FilterDefinition: *FilterDefinitionFromObject(name)}
+	filterJson, err := remoteFilter.LoadFilterJson(dotRegolithPath)
+	if err != nil {
+		Logger.Debugf("Failed to load the filter.json of %q: %s", name, err)
+		return nil
+	}
+	settingsSchema, ok := filterJson["settingsSchema"]
+	if !ok {
+		return nil
+	}
+	settingsSchemaJson, _ := json.Marshal(settingsSchema) // no error
+	var schema jsonSchema
+	if err := json.Unmarshal(settingsSchemaJson, &schema); err != nil {
+		Logger.Debugf("Invalid settingsSchema of %q: %s", name, err)
+		return nil
+	}
+	return &schema
+}
+
+// schemaAt returns the schema of the value at the path of the document and
+// the root schema with its definitions, or nil if the value isn't described
+// by the schemas. The schema isn't resolved (see jsonSchema.resolve), so it
+// keeps the description of the property with the "$ref".
+func (s *lspServer) schemaAt(
+	document *lspDocument, path []string,
+) (*jsonSchema, *jsonSchema) {
+	config, _ := document.value.(map[string]interface{})
+	schema, root := document.schema, document.schema
+	for i, key := range path {
+		if s.isConfig(document) && key == "settings" && i >= 2 &&
+			path[i-2] == "filters" {
+			filter, _ := jsonValueAt(config, path[:i]).(map[string]interface{})
+			name, _ := filter["filter"].(string)
+			schema = s.settingsSchema(
+				document, s.mergedConfig(document, config), name)
+			root = schema
+		} else {
+			schema = schema.property(root, key)
+		}
+		if schema == nil {
+			return nil, nil
+		}
+	}
+	return schema, root
+}
+
+// cursorAt returns the place of the position in the document.
+func cursorAt(document *lspDocument, position lspPosition) jsonCursor {
+	offset := positionToOffset(document.text, position) - document.shift
+	if offset < 0 {
+		offset = 0
+	}
+	return jsonCursorAt(document.data, offset)
+}
+
+// complete returns the completion items at the position of the document: the
+// names of the properties, the values from the enums of the schemas, and the
+// names of the filters and the profiles of the project.
+func (s *lspServer) complete(
+	document *lspDocument, position lspPosition,
+) []lspCompletionItem {
+	cursor := cursorAt(document, position)
+	result := []lspCompletionItem{}
+	quote := func(text string) string {
+		if cursor.inString {
+			return text
+		}
+		return strconv.Quote(text)
+	}
+	schema, root := s.schemaAt(document, cursor.path)
+	if schema != nil {
+		schema = schema.resolve(root)
+	}
+	if cursor.inKey {
+		if schema == nil {
+			return result
+		}
+		existing, _ := jsonValueAt(document.value, cursor.path).(map[string]interface{})
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			if _, ok := existing[name]; !ok || name == cursor.text {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result = append(result, lspCompletionItem{
+				Label:         name,
+				Kind:          lspKindProperty,
+				Documentation: schema.Properties[name].Description,
+				InsertText:    quote(name),
+			})
+		}
+		return result
+	}
+	var values []string
+	if schema != nil {
+		values = append(values, schema.Enum...)
+	}
+	if s.isConfig(document) && len(cursor.path) >= 2 {
+		config, _ := document.value.(map[string]interface{})
+		merged := s.mergedConfig(document, config)
+		key := cursor.path[len(cursor.path)-1]
+		inFilter := len(cursor.path) >= 3 &&
+			cursor.path[len(cursor.path)-3] == "filters"
+		switch {
+		case key == "filter" && inFilter:
+			values = append(values, jsonObjectKeys(
+				merged, "regolith", "filterDefinitions")...)
+		case key == "profile" && inFilter, key == "extends":
+			values = append(values, jsonObjectKeys(
+				merged, "regolith", "profiles")...)
+		}
+	}
+	for _, value := range values {
+		result = append(result, lspCompletionItem{
+			Label: value, Kind: lspKindValue, InsertText: quote(value)})
+	}
+	if schema != nil && !cursor.inString &&
+		stringInSlice("boolean", schema.types()) {
+		for _, value := range []string{"true", "false"} {
+			result = append(result, lspCompletionItem{
+				Label: value, Kind: lspKindValue})
+		}
+	}
+	return result
+}
+
+// hover returns the documentation of the property at the position of the
+// document, or nil if it's unknown.
+func (s *lspServer) hover(
+	document *lspDocument, position lspPosition,
+) *lspHover {
+	cursor := cursorAt(document, position)
+	path := cursor.path
+	if cursor.inKey {
+		if !cursor.inString {
+			return nil
+		}
+		path = append(path, cursor.text)
+	}
+	if len(path) == 0 {
+		return nil
+	}
+	schema, root := s.schemaAt(document, path)
+	if schema == nil {
+		return nil
+	}
+	description := schema.Description
+	resolved := schema.resolve(root)
+	if resolved == nil {
+		return nil
+	}
+	if description == "" {
+		description = resolved.Description
+	}
+	text := fmt.Sprintf("**%s**", path[len(path)-1])
+	if description != "" {
+		text += "\n\n" + description
+	}
+	if types := resolved.types(); len(types) > 0 {
+		text += fmt.Sprintf("\n\nType: `%s`", strings.Join(types, " | "))
+	}
+	if len(resolved.Enum) > 0 {
+		text += fmt.Sprintf(
+			"\n\nValues: `%s`", strings.Join(resolved.Enum, "`, `"))
+	}
+	return &lspHover{Contents: lspMarkupContent{"markdown", text}}
+}
+
+// jsonValueAt returns the value at the path (the property names and the
+// array indices) of the parsed JSON, or nil if it doesn't exist.
+func jsonValueAt(value interface{}, path []string) interface{} {
+	for _, key := range path {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(current) {
+				return nil
+			}
+			value = current[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// jsonObjectKeys returns the sorted property names of the object at the
+// path of the parsed JSON.
+func jsonObjectKeys(value interface{}, path ...string) []string {
+	object, _ := jsonValueAt(value, path).(map[string]interface{})
+	result := make([]string, 0, len(object))
+	for key := range object {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
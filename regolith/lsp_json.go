@@ -0,0 +1,291 @@
+package regolith
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsonSpan is the location of a part of a JSON document, as the byte offsets
+// of its start and its end.
+type jsonSpan struct {
+	start, end int
+}
+
+// jsonLocator finds the locations of the values of a JSON document.
+type jsonLocator struct {
+	data  []byte
+	pos   int
+	spans map[string]jsonSpan
+}
+
+// locateJsonValues returns the locations of the values of the JSON document
+// (without comments, see jsoncToJson), keyed by their JSON paths (see
+// joinJsonPath). The root value has the empty path. The properties of the
+// objects are located by their names, so the problems found in them point
+// to the names. It returns nil if the document isn't valid JSON.
+func locateJsonValues(data []byte) map[string]jsonSpan {
+	locator := jsonLocator{data: data, spans: make(map[string]jsonSpan)}
+	if !locator.value("") {
+		return nil
+	}
+	return locator.spans
+}
+
+func (l *jsonLocator) skipSpace() {
+	for l.pos < len(l.data) && bytes.IndexByte(
+		[]byte(" \t\r\n"), l.data[l.pos]) != -1 {
+		l.pos++
+	}
+}
+
+// next skips the white space and returns true if the next character is c,
+// skipping it as well.
+func (l *jsonLocator) next(c byte) bool {
+	l.skipSpace()
+	if l.pos < len(l.data) && l.data[l.pos] == c {
+		l.pos++
+		return true
+	}
+	return false
+}
+
+// value reads the value at the path and saves its location.
+func (l *jsonLocator) value(path string) bool {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return false
+	}
+	start := l.pos
+	switch l.data[l.pos] {
+	case '{':
+		l.pos++
+		if !l.next('}') {
+			for {
+				l.skipSpace()
+				keyStart := l.pos
+				key, ok := l.string()
+				if !ok {
+					return false
+				}
+				keyEnd := l.pos
+				propertyPath := joinJsonPath(path, key)
+				if !l.next(':') || !l.value(propertyPath) {
+					return false
+				}
+				l.spans[propertyPath] = jsonSpan{keyStart, keyEnd}
+				if l.next('}') {
+					break
+				}
+				if !l.next(',') {
+					return false
+				}
+			}
+		}
+	case '[':
+		l.pos++
+		if !l.next(']') {
+			for i := 0; ; i++ {
+				if !l.value(joinJsonPath(path, strconv.Itoa(i))) {
+					return false
+				}
+				if l.next(']') {
+					break
+				}
+				if !l.next(',') {
+					return false
+				}
+			}
+		}
+	case '"':
+		if _, ok := l.string(); !ok {
+			return false
+		}
+	default:
+		for l.pos < len(l.data) && bytes.IndexByte(
+			[]byte(",]} \t\r\n"), l.data[l.pos]) == -1 {
+			l.pos++
+		}
+	}
+	l.spans[path] = jsonSpan{start, l.pos}
+	return true
+}
+
+// string reads a JSON string and returns its value.
+func (l *jsonLocator) string() (string, bool) {
+	if l.pos >= len(l.data) || l.data[l.pos] != '"' {
+		return "", false
+	}
+	start := l.pos
+	for l.pos++; l.pos < len(l.data); l.pos++ {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '"':
+			l.pos++
+			var result string
+			err := json.Unmarshal(l.data[start:l.pos], &result)
+			return result, err == nil
+		}
+	}
+	return "", false
+}
+
+// jsonCursor describes the place of an offset in a JSON document, which may
+// be incomplete because the user is still typing it.
+type jsonCursor struct {
+	// path are the property names and the array indices of the value at the
+	// offset. If the offset is at the name of a property, it's the path of
+	// the object.
+	path []string
+	// inKey is true if the offset is at the name of a property (or where
+	// the name of the next property should be).
+	inKey bool
+	// inString is true if the offset is inside of a string.
+	inString bool
+	// text is the value of the string at the offset.
+	text string
+}
+
+// jsonFrame is an object or an array that contains the offset, used by
+// jsonCursorAt.
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+	index     int
+}
+
+// jsonCursorAt finds the place of the offset in the JSON document (without
+// comments, see jsoncToJson). Unlike locateJsonValues, it works with
+// incomplete documents.
+func jsonCursorAt(data []byte, offset int) jsonCursor {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	var frames []*jsonFrame
+	inString, escaped, stringStart := false, false, 0
+	for i := 0; i < offset; i++ {
+		c := data[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if len(frames) > 0 && frames[len(frames)-1].expectKey {
+					json.Unmarshal(data[stringStart:i+1], &frames[len(frames)-1].key)
+				}
+			}
+			continue
+		}
+		var top *jsonFrame
+		if len(frames) > 0 {
+			top = frames[len(frames)-1]
+		}
+		switch c {
+		case '"':
+			inString, stringStart = true, i
+		case '{':
+			frames = append(frames, &jsonFrame{object: true, expectKey: true})
+		case '[':
+			frames = append(frames, &jsonFrame{})
+		case '}', ']':
+			if top != nil {
+				frames = frames[:len(frames)-1]
+			}
+		case ':':
+			if top != nil && top.object {
+				top.expectKey = false
+			}
+		case ',':
+			if top != nil && top.object {
+				top.expectKey, top.key = true, ""
+			} else if top != nil {
+				top.index++
+			}
+		}
+	}
+	result := jsonCursor{inString: inString}
+	for i, frame := range frames {
+		last := i == len(frames)-1
+		switch {
+		case !frame.object:
+			result.path = append(result.path, strconv.Itoa(frame.index))
+		case last && frame.expectKey:
+			result.inKey = true
+		default:
+			result.path = append(result.path, frame.key)
+		}
+	}
+	if inString {
+		// The string ends at the closing quote or at the end of the line
+		end := offset
+		for escaped := false; end < len(data); end++ {
+			if escaped {
+				escaped = false
+				continue
+			}
+			if data[end] == '\\' {
+				escaped = true
+			} else if data[end] == '"' || data[end] == '\n' {
+				break
+			}
+		}
+		var text string
+		if json.Unmarshal(append(
+			append([]byte{}, data[stringStart:end]...), '"'), &text) == nil {
+			result.text = text
+		}
+	}
+	return result
+}
+
+// lspPosition is a position in a text document, as used by the Language
+// Server Protocol. The character is counted in UTF-16 code units.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// offsetToPosition converts a byte offset in the text to a position.
+func offsetToPosition(text []byte, offset int) lspPosition {
+	var position lspPosition
+	for i := 0; i < offset && i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if r == '\n' {
+			position.Line++
+			position.Character = 0
+		} else {
+			position.Character += len(utf16.Encode([]rune{r}))
+		}
+		i += size
+	}
+	return position
+}
+
+// positionToOffset converts a position to a byte offset in the text.
+func positionToOffset(text []byte, position lspPosition) int {
+	line, character := 0, 0
+	for i := 0; i < len(text); {
+		if line == position.Line && character >= position.Character {
+			return i
+		}
+		r, size := utf8.DecodeRune(text[i:])
+		if r == '\n' {
+			if line == position.Line {
+				return i
+			}
+			line++
+			character = 0
+		} else if line == position.Line {
+			character += len(utf16.Encode([]rune{r}))
+		}
+		i += size
+	}
+	return len(text)
+}
@@ -1,10 +1,15 @@
 package regolith
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // Install handles the "regolith install" command. It installs specific filters
@@ -14,11 +19,13 @@ import (
 // The "filters" parameter is a list of filters to install in the format
 // <filter-url>==<filter-version> or <filter-url>.
 // "filter-url" is the URL of the filter to install.
-// "filter-version" is the version of the filter. It can be semver, git commit
-// hash, "HEAD", or "latest". "HEAD" means that the filter will be
-// updated to lastest SHA commit and "latest" updates the filter to the latest
-// version tag. If "filter-version" is not specified, the filter will be
-// installed with the latest version or HEAD if there is no valid version tags.
+// "filter-version" is the version of the filter. It can be semver, a range of
+// semver versions (like "^1.2.0" or "~2.1"), git commit hash, "HEAD", or
+// "latest". "HEAD" means that the filter will be updated to lastest SHA commit
+// and "latest" updates the filter to the latest version tag. The ranges update
+// the filter to the latest version tag in the range. If "filter-version" is
+// not specified, the filter will be installed with the latest version or HEAD
+// if there is no valid version tags.
 //
 // The "force" parameter is a boolean that determines if the installation
 // should be forced even if the filter is already installed.
@@ -36,12 +43,20 @@ func Install(filters []string, force, debug bool) error {
 	if err != nil {
 		return WrapError(err, "Failed to parse arguments.")
 	}
-	config, err := LoadConfigAsMap()
+	// The filters are added to the shared config, the local config only
+	// affects the paths
+	config, err := loadSharedConfigAsMap()
 	if err != nil {
 		return WrapError(err, "Unable to load config file.")
 	}
+	mergedConfig, err := mergeLocalConfig(config, ".")
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to load the local config.\nPath: %s",
+			LocalConfigFilePath)
+	}
 	// Get parts of config file required for installation
-	dataPath, err := dataPathFromConfigMap(config)
+	dataPath, err := dataPathFromConfigMap(mergedConfig)
 	if err != nil {
 		return WrapError(err, "Failed to get data path from config file.")
 	}
@@ -51,7 +66,7 @@ func Install(filters []string, force, debug bool) error {
 			err,
 			"Failed to get the list of filter definitions from config file.")
 	}
-	useAppData, err := useAppDataFromConfigMap(config)
+	useAppData, err := useAppDataFromConfigMap(mergedConfig)
 	if err != nil {
 		return WrapError(
 			err, "Failed to get the value of useAppData property from the "+
@@ -100,8 +115,22 @@ func Install(filters []string, force, debug bool) error {
 		return WrapError(
 			err, "Unable to get the path to regolith cache folder.")
 	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	// The installed filters are locked to their new versions
+	lockFile, err := LoadLockFile()
+	if err != nil {
+		return WrapError(err, "Failed to load the lock file.")
+	}
+	for name := range filterInstallers {
+		delete(lockFile.Filters, name)
+	}
 	// Download the filter definitions
-	err = installFilters(filterInstallers, force, dataPath, dotRegolithPath)
+	err = installFilters(
+		filterInstallers, force, false, dataPath, dotRegolithPath, lockFile)
 	if err != nil {
 		return WrapError(err, "Failed to install filters.")
 	}
@@ -121,6 +150,10 @@ func Install(filters []string, force, debug bool) error {
 				"Run \"regolith clean\" to fix invalid cache state.",
 			len(parsedArgs))
 	}
+	err = lockFile.Save()
+	if err != nil {
+		return WrapError(err, "Failed to save the lock file.")
+	}
 	Logger.Info("Successfully installed the filters.")
 	return nil
 }
@@ -132,12 +165,16 @@ func Install(filters []string, force, debug bool) error {
 // The "force" parameter is a boolean that determines if the installation
 // should be forced even if the filter is already installed.
 //
+// The "offline" parameter is a boolean that determines if the remote filters
+// should be installed from the vendor directory (see VendorPath) and the
+// cache, without accessing the network.
+//
 // The "debug" parameter is a boolean that determines if the debug messages
 // should be printed.
-func InstallAll(force, debug bool) error {
+func InstallAll(force, offline, debug bool) error {
 	InitLogging(debug)
 	Logger.Info("Installing filters...")
-	if !hasGit() {
+	if !offline && !hasGit() {
 		Logger.Warn(gitNotInstalledWarning)
 	}
 	configMap, err1 := LoadConfigAsMap()
@@ -152,11 +189,25 @@ func InstallAll(force, debug bool) error {
 		return WrapError(
 			err, "Unable to get the path to regolith cache folder.")
 	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	lockFile, err := LoadLockFile()
+	if err != nil {
+		return WrapError(err, "Failed to load the lock file.")
+	}
 	err = installFilters(
-		config.FilterDefinitions, force, config.DataPath, dotRegolithPath)
+		config.FilterDefinitions, force, offline, config.DataPath,
+		dotRegolithPath, lockFile)
 	if err != nil {
 		return WrapError(err, "Could not install filters.")
 	}
+	err = lockFile.Save()
+	if err != nil {
+		return WrapError(err, "Failed to save the lock file.")
+	}
 	Logger.Info("Successfully installed the filters.")
 	return nil
 }
@@ -200,113 +251,814 @@ func Update(filters []string, debug bool) error {
 		return WrapError(
 			err, "Unable to get the path to regolith cache folder.")
 	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	lockFile, err := LoadLockFile()
+	if err != nil {
+		return WrapError(err, "Failed to load the lock file.")
+	}
 	// Update the filters from the list
-	err = updateFilters(filterInstallers, dotRegolithPath)
+	err = updateFilters(filterInstallers, dotRegolithPath, lockFile)
 	if err != nil {
 		return WrapError(err, "Could not update filters.")
 	}
+	err = lockFile.Save()
+	if err != nil {
+		return WrapError(err, "Failed to save the lock file.")
+	}
 	Logger.Info("Successfully updated the filters.")
 	return nil
 }
 
+// UpdateInteractive handles the "regolith update --interactive" command. It
+// lists the remote filters with newer versions, prints the commits that
+// changed them since the installed versions and asks which of them should be
+// updated. The pinned versions of the selected filters are updated in the
+// config.json file and the new versions are saved in the lock file.
+//
+// The "filters" parameter is a list of the names of the filters to check. If
+// it's empty, all of the filters are checked.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func UpdateInteractive(filters []string, debug bool) error {
+	InitLogging(debug)
+	Logger.Info("Checking filters for updates...")
+	if !hasGit() {
+		return WrappedError(gitNotInstalledWarning)
+	}
+	// The pinned versions are updated in the shared config
+	configMap, err1 := loadSharedConfigAsMap()
+	mergedConfigMap, err2 := mergeLocalConfig(configMap, ".")
+	config, err3 := ConfigFromObject(mergedConfigMap)
+	if err := firstErr(err1, err2, err3); err != nil {
+		return WrapError(err, "Failed to load config.json.")
+	}
+	filterDefinitionsMap, err := filterDefinitionsFromConfigMap(configMap)
+	if err != nil {
+		return WrapError(
+			err,
+			"Failed to get the list of filter definitions from config file.")
+	}
+	filterInstallers := config.FilterDefinitions
+	if len(filters) > 0 {
+		filterInstallers = make(map[string]FilterInstaller, 0)
+		for _, filterName := range filters {
+			filterInstaller, ok := config.FilterDefinitions[filterName]
+			if !ok {
+				Logger.Warnf(
+					"Filter %q is not installed and therefore cannot be "+
+						"updated.", filterName)
+				continue
+			}
+			filterInstallers[filterName] = filterInstaller
+		}
+	}
+	// Get dotRegolithPath
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, false, ".")
+	if err != nil {
+		return WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	lockFile, err := LoadLockFile()
+	if err != nil {
+		return WrapError(err, "Failed to load the lock file.")
+	}
+	updates, err := findFilterUpdates(
+		filterInstallers, dotRegolithPath, lockFile)
+	if err != nil {
+		return WrapError(err, "Failed to check the filters for updates.")
+	}
+	if len(updates) == 0 {
+		Logger.Info("All of the filters are up to date.")
+		return nil
+	}
+	Logger.Infof("Found updates of %d filters.", len(updates))
+	updates = selectFilterUpdates(updates)
+	if len(updates) == 0 {
+		Logger.Info("No filters selected for the update.")
+		return nil
+	}
+	for _, update := range updates {
+		Logger.Infof(
+			"Updating filter %q to new version: %q->%q.", update.name,
+			update.current, update.candidate)
+		err = applyFilterUpdate(
+			update, filterDefinitionsMap, dotRegolithPath, lockFile)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to update filter.\nFilter: %s", update.name)
+		}
+	}
+	jsonBytes, _ := json.MarshalIndent(configMap, "", "\t")
+	err = ioutil.WriteFile(ConfigFilePath, jsonBytes, 0644)
+	if err != nil {
+		return WrapErrorf(
+			err, "Successfully updated %v filters but failed to update the "+
+				"config file.", len(updates))
+	}
+	err = lockFile.Save()
+	if err != nil {
+		return WrapError(err, "Failed to save the lock file.")
+	}
+	Logger.Info("Successfully updated the filters.")
+	return nil
+}
+
+// Migrate handles the "regolith migrate" command. It upgrades the config.json
+// file from an older format version to LatestConfigFormatVersion (see
+// migrateConfig) and prints the changes. The config.local.json file isn't
+// changed.
+//
+// The "dryRun" parameter is a boolean that determines if the changes should
+// only be printed, without saving the config file.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Migrate(dryRun, debug bool) error {
+	InitLogging(debug)
+	configMap, err := loadSharedConfigAsMap()
+	if err != nil {
+		return WrapError(err, "Failed to load the config file.")
+	}
+	before, _ := mapStringsInValue(
+		configMap, func(s string) string { return s },
+	).(map[string]interface{})
+	migrations, err := migrateConfig(configMap)
+	if err != nil {
+		return WrapError(err, "Failed to migrate the config file.")
+	}
+	if len(migrations) == 0 {
+		Logger.Infof(
+			"The config file is up to date.\nFormat version: %s",
+			LatestConfigFormatVersion)
+		return nil
+	}
+	for _, migration := range migrations {
+		Logger.Infof(
+			"Migration %s -> %s: %s", migration.From, migration.To,
+			migration.Description)
+	}
+	Logger.Infof("Changes of the config file:\n%s", configDiff(before, configMap))
+	if dryRun {
+		Logger.Info("Dry run finished. The config file wasn't changed.")
+		return nil
+	}
+	jsonBytes, _ := json.MarshalIndent(configMap, "", "\t")
+	err = ioutil.WriteFile(ConfigFilePath, jsonBytes, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, ConfigFilePath)
+	}
+	Logger.Infof(
+		"Successfully migrated the config file to format version %s.",
+		LatestConfigFormatVersion)
+	return nil
+}
+
+// ConfigGet handles the "regolith config get" command. It prints the value
+// from the path (see parseConfigPath) of the config, with the local config
+// and the project defaults from the user config merged (see
+// LoadConfigAsMap). The strings are printed without quotes and the other
+// values as JSON.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func ConfigGet(path string, debug bool) error {
+	InitLogging(debug)
+	keys, err := parseConfigPath(path)
+	if err != nil {
+		return PassError(err)
+	}
+	configMap, err := LoadConfigAsMap()
+	if err != nil {
+		return WrapError(err, "Failed to load the config file.")
+	}
+	value, err := getConfigValue(configMap, keys)
+	if err != nil {
+		return WrapErrorf(err, "Failed to get the value.\nPath: %s", path)
+	}
+	if text, ok := value.(string); ok {
+		fmt.Println(text)
+		return nil
+	}
+	jsonBytes, _ := json.MarshalIndent(value, "", "\t")
+	fmt.Println(string(jsonBytes))
+	return nil
+}
+
+// ConfigSet handles the "regolith config set" command. It changes the value
+// from the path (see parseConfigPath) of config.json. The value is parsed as
+// JSON, or used as a string if it's not valid JSON. The file isn't saved if
+// the changed config is invalid.
+//
+// The "local" parameter is a boolean that determines if the value should be
+// changed in config.local.json instead of config.json.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func ConfigSet(path, value string, local, debug bool) error {
+	InitLogging(debug)
+	err := editConfigFile(path, parseConfigValue(value), local)
+	if err != nil {
+		return WrapErrorf(err, "Failed to set the config value.\nPath: %s", path)
+	}
+	Logger.Infof("Set the %q config value.", path)
+	return nil
+}
+
+// ConfigUnset handles the "regolith config unset" command. It removes the
+// value from the path (see parseConfigPath) of config.json. The file isn't
+// saved if the changed config is invalid.
+//
+// The "local" parameter is a boolean that determines if the value should be
+// removed from config.local.json instead of config.json.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func ConfigUnset(path string, local, debug bool) error {
+	InitLogging(debug)
+	err := editConfigFile(path, nil, local)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to remove the config value.\nPath: %s", path)
+	}
+	Logger.Infof("Removed the %q config value.", path)
+	return nil
+}
+
+// Graph handles the "regolith graph" command. It prints the graph of the
+// filters of the profile in the DOT or Mermaid format. The edges of the
+// graph show which filters must finish before the other filters start, based
+// on their "needs", "inputs" and "outputs" properties (see
+// filterDependencies).
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Graph(profileName, format string, debug bool) error {
+	InitLogging(debug)
+	if profileName == "" {
+		profileName = "default"
+	}
+	configJson, err := LoadConfigAsMap()
+	if err != nil {
+		return WrapError(err, "Could not load \"config.json\".")
+	}
+	config, err := ConfigFromObject(configJson)
+	if err != nil {
+		return WrapError(err, "Could not load \"config.json\".")
+	}
+	profile, ok := config.Profiles[profileName]
+	if !ok {
+		return WrappedErrorf(
+			"Profile %q does not exist in the configuration.", profileName)
+	}
+	graph, err := renderFilterGraph(profileName, profile.Filters, format)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to create the graph of profile %q.", profileName)
+	}
+	fmt.Print(graph)
+	return nil
+}
+
 // UpdateAll handles the "regolith update-all" command. It updates all of the
 // filters from the filtersDefinitions list in the config.json file which
 // aren't version locked.
 //
 // The "debug" parameter is a boolean that determines if the debug messages
 // should be printed.
-func UpdateAll(debug bool) error {
+func UpdateAll(debug bool) error {
+	InitLogging(debug)
+	Logger.Info("Updating filters...")
+	if !hasGit() {
+		Logger.Warn(gitNotInstalledWarning)
+	}
+	configMap, err1 := LoadConfigAsMap()
+	config, err2 := ConfigFromObject(configMap)
+	if err := firstErr(err1, err2); err != nil {
+		return WrapError(err, "Failed to load config.json.")
+	}
+	// Get dotRegolithPath
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, false, ".")
+	if err != nil {
+		return WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	lockFile, err := LoadLockFile()
+	if err != nil {
+		return WrapError(err, "Failed to load the lock file.")
+	}
+	err = updateFilters(config.FilterDefinitions, dotRegolithPath, lockFile)
+	if err != nil {
+		return WrapError(err, "Could not install filters.")
+	}
+	err = lockFile.Save()
+	if err != nil {
+		return WrapError(err, "Failed to save the lock file.")
+	}
+	Logger.Info("Successfully installed the filters.")
+	return nil
+}
+
+// Link handles the "regolith link" command. It makes a remote filter of the
+// project use its local working copy from the "path" directory instead of the
+// downloaded files, until it's unlinked with the "regolith unlink" command.
+//
+// The "name" parameter is the name of the filter in the filtersDefinitions
+// list in the config.json file. If it's empty, the name of the "path"
+// directory is used.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Link(path, name string, debug bool) error {
+	InitLogging(debug)
+	if path == "" {
+		return WrappedError(
+			"No path specified.\n" +
+				"Please specify the path to the local copy of the filter.")
+	}
+	if name == "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return WrapErrorf(err, filepathAbsError, path)
+		}
+		name = filepath.Base(absPath)
+	}
+	remoteFilter, dotRegolithPath, err := remoteFilterFromConfig(name)
+	if err != nil {
+		return PassError(err)
+	}
+	err = linkFilter(remoteFilter, path, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(err, "Failed to link the filter.\nFilter: %s", name)
+	}
+	Logger.Infof("Successfully linked the %q filter.", name)
+	return nil
+}
+
+// Unlink handles the "regolith unlink" command. It removes the link of a
+// remote filter to its local working copy created by the "regolith link"
+// command.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Unlink(name string, debug bool) error {
+	InitLogging(debug)
+	if name == "" {
+		return WrappedError("No filter specified.")
+	}
+	remoteFilter, dotRegolithPath, err := remoteFilterFromConfig(name)
+	if err != nil {
+		return PassError(err)
+	}
+	err = unlinkFilter(remoteFilter, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(err, "Failed to unlink the filter.\nFilter: %s", name)
+	}
+	Logger.Infof(
+		"Successfully unlinked the %q filter. You can download it again "+
+			"using command:\nregolith install-all", name)
+	return nil
+}
+
+// remoteFilterFromConfig returns the definition of the remote filter from
+// the config.json file and the path to the .regolith directory of the
+// project.
+func remoteFilterFromConfig(
+	name string,
+) (*RemoteFilterDefinition, string, error) {
+	configMap, err1 := LoadConfigAsMap()
+	config, err2 := ConfigFromObject(configMap)
+	if err := firstErr(err1, err2); err != nil {
+		return nil, "", WrapError(err, "Failed to load config.json.")
+	}
+	filterDefinition, ok := config.FilterDefinitions[name]
+	if !ok {
+		return nil, "", WrappedErrorf(
+			"The filter is not on the filter definitions list.\n"+
+				"Filter: %s", name)
+	}
+	remoteFilter, ok := filterDefinition.(*RemoteFilterDefinition)
+	if !ok {
+		return nil, "", WrappedErrorf(
+			"Only the remote filters can be linked.\nFilter: %s", name)
+	}
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, false, ".")
+	if err != nil {
+		return nil, "", WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	return remoteFilter, dotRegolithPath, nil
+}
+
+// Search handles the "regolith search" command. It searches the filter
+// registries from the user config for the filters whose names or
+// descriptions contain the "term" and prints them.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Search(term string, debug bool) error {
+	InitLogging(debug)
+	err := DownloadResolverMap()
+	if err != nil {
+		Logger.Warnf(
+			"Failed to download the filter registries. Searching the "+
+				"previously downloaded registries.\n%s", err)
+	}
+	results, err := SearchRegistries(term)
+	if err != nil {
+		return WrapError(err, "Failed to search the filter registries.")
+	}
+	if len(results) == 0 {
+		Logger.Infof("No filters found for %q.", term)
+		return nil
+	}
+	Logger.Infof("Found %d filters:", len(results))
+	for _, result := range results {
+		Logger.Infof("%s - %s", result.Name, result.Url)
+		if result.Description != "" {
+			Logger.Infof("\t%s", result.Description)
+		}
+		if len(result.Versions) > 0 {
+			Logger.Infof("\tVersions: %s", strings.Join(result.Versions, ", "))
+		}
+	}
+	return nil
+}
+
+// Vendor handles the "regolith vendor" command. It copies the installed
+// remote filters from the filtersDefinitions list in the config.json file to
+// the vendor directory of the project (see VendorPath), so they can be
+// installed with the "regolith install-all --offline" command.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Vendor(debug bool) error {
+	InitLogging(debug)
+	Logger.Info("Vendoring filters...")
+	configMap, err1 := LoadConfigAsMap()
+	config, err2 := ConfigFromObject(configMap)
+	if err := firstErr(err1, err2); err != nil {
+		return WrapError(err, "Failed to load config.json.")
+	}
+	// Get dotRegolithPath
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, false, ".")
+	if err != nil {
+		return WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	err = vendorFilters(
+		withNestedFilters(config.FilterDefinitions, dotRegolithPath),
+		dotRegolithPath)
+	if err != nil {
+		return WrapError(err, "Failed to vendor the filters.")
+	}
+	Logger.Info("Successfully vendored the filters.")
+	return nil
+}
+
+// Doctor handles the "regolith doctor" command. It checks the environment
+// of the project: the programs used by the filters, the config, the filters
+// of the profiles and the export paths, and on Windows the problems caused by
+// OneDrive and Microsoft Defender. Every problem is printed with the
+// instructions for fixing it.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Doctor(debug bool) error {
+	InitLogging(debug)
+	return doctor()
+}
+
+// Completion handles the "regolith completion" command. It prints the
+// completion script of the shell, which completes the commands, the flags,
+// and the names of the profiles and the filters of the project.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Completion(shell string, debug bool) error {
+	InitLogging(debug)
+	script, err := completionScript(shell)
+	if err != nil {
+		return PassError(err)
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// List handles the "regolith list" command. It prints the profiles of the
+// project with their filters, the resolved versions of the remote filters and
+// whether the filters are enabled, and the filter definitions that aren't
+// used by any profile.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func List(debug bool) error {
 	InitLogging(debug)
-	Logger.Info("Updating filters...")
-	if !hasGit() {
-		Logger.Warn(gitNotInstalledWarning)
-	}
 	configMap, err1 := LoadConfigAsMap()
 	config, err2 := ConfigFromObject(configMap)
 	if err := firstErr(err1, err2); err != nil {
 		return WrapError(err, "Failed to load config.json.")
 	}
-	// Get dotRegolithPath
 	dotRegolithPath, err := GetDotRegolith(
-		config.RegolithProject.UseAppData, false, ".")
+		config.RegolithProject.UseAppData, true, ".")
 	if err != nil {
 		return WrapError(
 			err, "Unable to get the path to regolith cache folder.")
 	}
-	err = updateFilters(config.FilterDefinitions, dotRegolithPath)
+	lockFile, err := LoadLockFile()
 	if err != nil {
-		return WrapError(err, "Could not install filters.")
+		return WrapError(err, "Failed to load the lock file.")
 	}
-	Logger.Info("Successfully installed the filters.")
+	listProject(config, dotRegolithPath, lockFile)
 	return nil
 }
 
-// runOrWatch handles both 'regolith run' and 'regolith watch' commands based
-// on the 'watch' parameter. It runs/watches the profile named after
-// 'profileName' parameter. The 'debug' argument determines if the debug
-// messages should be printed or not.
-func runOrWatch(profileName string, recycled, debug, watch bool) error {
+// Explain handles the "regolith explain" command. It prints the message, the
+// causes and the fixes of the error with the code (see errorCodes), or the
+// list of the codes if the code is empty.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Explain(code string, debug bool) error {
 	InitLogging(debug)
-	// Select the run profile function based on the recycled flag
-	rp := RunProfile
-	if recycled {
-		rp = RecycledRunProfile
-	}
-	if profileName == "" {
-		profileName = "default"
+	return explainErrorCode(code)
+}
+
+// prepareRunContext loads the config, checks the filters of the profile
+// named after 'profileName' and returns the context for running it. The
+// 'defines' are the values of the "--define" flag, in the "name=value" format.
+func prepareRunContext(
+	profileName string, defines []string,
+) (RunContext, error) {
+	parsedDefines, err := ParseDefines(defines)
+	if err != nil {
+		return RunContext{}, PassError(err)
 	}
 	// Load the Config and the profile
 	configJson, err := LoadConfigAsMap()
 	if err != nil {
-		return WrapError(err, "Could not load \"config.json\".")
+		return RunContext{}, WrapError(err, "Could not load \"config.json\".")
 	}
 	config, err := ConfigFromObject(configJson)
 	if err != nil {
-		return WrapError(err, "Could not load \"config.json\".")
+		return RunContext{}, WrapError(err, "Could not load \"config.json\".")
 	}
+	applyLogLevel(config.LogLevel)
 	profile, ok := config.Profiles[profileName]
 	if !ok {
-		return WrappedErrorf(
+		return RunContext{}, WrappedErrorf(
 			"Profile %q does not exist in the configuration.", profileName)
 	}
 	// Get dotRegolithPath
 	dotRegolithPath, err := GetDotRegolith(
 		config.RegolithProject.UseAppData, false, ".")
 	if err != nil {
-		return WrapError(
+		return RunContext{}, WrapError(
 			err, "Unable to get the path to regolith cache folder.")
 	}
 	// Check the filters of the profile
 	err = CheckProfileImpl(profile, profileName, *config, nil, dotRegolithPath)
 	if err != nil {
-		return err
+		return RunContext{}, err
 	}
 	path, _ := filepath.Abs(".")
-	context := RunContext{
+	return RunContext{
 		AbsoluteLocation: path,
 		Config:           config,
 		Parent:           nil,
 		Profile:          profileName,
 		DotRegolithPath:  dotRegolithPath,
+		Defines:          parsedDefines,
+	}, nil
+}
+
+// RunPaths are the paths passed with the "--rp", "--bp" and "--out" flags
+// of "regolith run". They replace the paths of the packs from config.json for
+// a single run, so the profile can be applied to packs from outside of the
+// project. Empty paths don't replace anything.
+type RunPaths struct {
+	// Rp and Bp replace the source resource pack and behavior pack
+	Rp string `json:"rp,omitempty"`
+	Bp string `json:"bp,omitempty"`
+	// Out replaces the export target of the profile with the "exact" target
+	// that exports the packs to the "RP" and "BP" subdirectories of this
+	// path.
+	Out string `json:"out,omitempty"`
+}
+
+// isEmpty returns true if none of the paths is replaced.
+func (p RunPaths) isEmpty() bool {
+	return p.Rp == "" && p.Bp == "" && p.Out == ""
+}
+
+// absolute returns the paths converted to absolute paths.
+func (p RunPaths) absolute() RunPaths {
+	for _, path := range []*string{&p.Rp, &p.Bp, &p.Out} {
+		if *path != "" {
+			*path, _ = filepath.Abs(*path)
+		}
+	}
+	return p
+}
+
+// applyRunPaths returns the context with the paths of the packs replaced by
+// the paths (see RunPaths). The config of the context is copied, so the
+// config shared with other contexts (see daemon.context) isn't changed.
+func applyRunPaths(context RunContext, paths RunPaths) (RunContext, error) {
+	if paths.isEmpty() {
+		return context, nil
+	}
+	for _, path := range []string{paths.Rp, paths.Bp} {
+		if path == "" {
+			continue
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return context, WrapErrorf(err, osStatErrorAny, path)
+		}
+		if !stat.IsDir() {
+			return context, WrappedErrorf(isDirNotADirError, path)
+		}
+	}
+	config := *context.Config
+	if paths.Rp != "" {
+		config.ResourceFolder = paths.Rp
+	}
+	if paths.Bp != "" {
+		config.BehaviorFolder = paths.Bp
+	}
+	if paths.Out != "" {
+		profile, err := context.GetProfile()
+		if err != nil {
+			return context, WrapErrorf(err, runContextGetProfileError)
+		}
+		profile.ExportTarget = ExportTarget{
+			Target:   "exact",
+			RpPath:   filepath.Join(paths.Out, "RP"),
+			BpPath:   filepath.Join(paths.Out, "BP"),
+			ReadOnly: profile.ExportTarget.ReadOnly,
+			Exclude:  profile.ExportTarget.Exclude,
+		}
+		config.Profiles = make(map[string]Profile, len(context.Config.Profiles))
+		for name, p := range context.Config.Profiles {
+			config.Profiles[name] = p
+		}
+		config.Profiles[context.Profile] = profile
+	}
+	context.Config = &config
+	return context, nil
+}
+
+// runOrWatch handles both 'regolith run' and 'regolith watch' commands based
+// on the 'watch' parameter. It runs/watches the profile named after
+// 'profileName' parameter. The 'debug' argument determines if the debug
+// messages should be printed or not. The 'defines' are the values of the
+// "--define" flag, in the "name=value" format. The 'filters' and
+// 'skipExport' are the values of the "--filter" and "--no-export" flags (see
+// RunContext), and the 'paths' replace the paths of the packs (see
+// RunPaths).
+func runOrWatch(
+	profileName string, defines []string, recycled, debug, watch bool,
+	reloadPort int, filters []string, skipExport bool, paths RunPaths,
+) error {
+	InitLogging(debug)
+	// Select the run profile function based on the recycled flag
+	rp := RunProfile
+	if recycled {
+		rp = RecycledRunProfile
 	}
+	if profileName == "" {
+		profileName = "default"
+	}
+	// The daemon of the project (see Serve) runs the profile, if it's running
+	request := serveRequest{
+		Command: "run", Profile: profileName, Defines: defines,
+		Recycled: recycled, ReloadPort: reloadPort, Timings: Timings,
+		Filters: filters, SkipExport: skipExport,
+	}
+	if watch {
+		request.Command = "watch"
+	}
+	if TimingsOutput != "" {
+		request.TimingsOutput, _ = filepath.Abs(TimingsOutput)
+	}
+	if JunitOutput != "" {
+		request.JunitOutput, _ = filepath.Abs(JunitOutput)
+	}
+	if SarifOutput != "" {
+		request.SarifOutput, _ = filepath.Abs(SarifOutput)
+	}
+	request.Reproducible = Reproducible
+	request.Events = eventsEnabled()
+	// The daemon runs in its own working directory
+	request.Paths = paths.absolute()
+	if sent, err := sendToDaemon(request); sent {
+		if err != nil {
+			return PassError(err)
+		}
+		return nil
+	}
+	context, err := prepareRunContext(profileName, defines)
+	if err != nil {
+		return PassError(err)
+	}
+	context.Filters = filters
+	context.SkipExport = skipExport
+	context, err = applyRunPaths(context, paths)
+	if err != nil {
+		return PassError(err)
+	}
+	// Watch mode holds the lock until it's stopped
+	projectLock, err := acquireProjectLock(context.DotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	// Stop the filters that run as persistent processes
+	defer StopPersistentProcesses()
 	if watch { // Loop until program termination (CTRL+C)
-		context.StartWatchingSrouceFiles()
-		for {
+		var reloadServer *MinecraftWebSocketServer
+		if reloadPort != 0 {
+			reloadServer, err = StartMinecraftWebSocketServer(reloadPort)
+			if err != nil {
+				return PassError(err)
+			}
+			defer reloadServer.Close()
+		}
+		err = context.StartWatchingSrouceFiles()
+		if err != nil {
+			return WrapError(err, "Failed to start watching the source files.")
+		}
+		controls := startWatchControls()
+		if controls != nil {
+			defer controls.restore()
+		}
+		for iteration := 1; ; iteration++ {
+			resetRunReport(context)
 			err = rp(context)
+			if err := finishRunReport(context, err, iteration); err != nil {
+				Logger.Warnf("%s", PassError(err).Error())
+			}
+			if IsExportInterruptedError(err) {
+				return WrapErrorf(err, "Failed to run profile %q", profileName)
+			}
 			if err != nil {
 				Logger.Errorf(
 					"Failed to run profile %q: %s",
 					profileName, PassError(err).Error())
 			} else {
 				Logger.Infof("Successfully ran the %q profile.", profileName)
+				context.changedFiles.commit()
+				if reloadServer != nil {
+					reloadServer.Reload()
+				}
+			}
+			var source string
+			if controls == nil {
+				Logger.Info("Press Ctrl+C to stop watching.")
+				source = context.AwaitInterruption()
+			} else {
+				Logger.Info(watchControlsHelp)
+				source = controls.await(context)
+			}
+			if source == "" {
+				Logger.Info("Stopped watching.")
+				// Save the states of the tmp files for the next recycled
+				// run
+				if recycled {
+					if err := saveTmpStates(context); err != nil {
+						return PassError(err)
+					}
+				}
+				return nil
 			}
-			Logger.Info("Press Ctrl+C to stop watching.")
-			context.AwaitInterruption()
 			Logger.Warn("Restarting...")
+			emitWatchIteration(context, iteration, source)
 		}
-		// return nil // Unreachable code
 	}
+	resetRunReport(context)
 	err = rp(context)
+	if err := finishRunReport(context, err, 0); err != nil {
+		Logger.Warnf("%s", PassError(err).Error())
+	}
 	if err != nil {
 		return WrapErrorf(err, "Failed to run profile %q", profileName)
 	}
@@ -315,16 +1067,85 @@ func runOrWatch(profileName string, recycled, debug, watch bool) error {
 }
 
 // Run handles the "regolith run" command. It runs selected profile and exports
-// created resource pack and behvaiour pack to the target destination.
-func Run(profileName string, recycled, debug bool) error {
-	return runOrWatch(profileName, recycled, debug, false)
+// created resource pack and behvaiour pack to the target destination. The
+// defines are used by the "when" expressions of the filters.
+func Run(profileName string, defines []string, recycled, debug bool) error {
+	return runOrWatch(
+		profileName, defines, recycled, debug, false, 0, nil, false,
+		RunPaths{})
+}
+
+// RunFilters handles the "regolith run" command with the "--filter",
+// "--no-export", "--rp", "--bp" and "--out" flags. It runs only the filters
+// of the selected profile with the IDs from the "filters" list (all of them
+// if it's empty), in the order of the profile, on a fresh copy of the project
+// files. If "skipExport" is true, the results are left in the
+// ".regolith/tmp" directory instead of being exported. The "paths" replace
+// the source and the export paths of the packs from the config (see
+// RunPaths).
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func RunFilters(
+	profileName string, filters, defines []string,
+	recycled, skipExport bool, paths RunPaths, debug bool,
+) error {
+	return runOrWatch(
+		profileName, defines, recycled, debug, false, 0, filters, skipExport,
+		paths)
 }
 
 // Watch handles the "regolith watch" command. It watches the project
 // directories and it runs selected profile and exports created resource pack
 // and behvaiour pack to the target destination when the project changes.
-func Watch(profileName string, recycled, debug bool) error {
-	return runOrWatch(profileName, recycled, debug, true)
+// The defines are used by the "when" expressions of the filters. If the
+// reloadPort isn't 0, Regolith starts a WebSocket server on this port and
+// sends the "reload" command to the connected Minecraft clients after each
+// successful run. In the terminal, the watching is controlled with the keys
+// (see watchControls).
+func Watch(
+	profileName string, defines []string, recycled, debug bool,
+	reloadPort int,
+) error {
+	return runOrWatch(
+		profileName, defines, recycled, debug, true, reloadPort, nil, false,
+		RunPaths{})
+}
+
+// RunWorkspace handles the "regolith run" command with the "--all" and
+// "--project" flags. It runs the profile in every project of the workspace
+// (see FindWorkspace) in the dependency order. If the "project" isn't empty,
+// it only runs the project and the projects it depends on. If "dryRun" is
+// true, it prints the execution plans of the projects instead (see DryRun).
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func RunWorkspace(
+	project, profileName string, defines []string,
+	recycled, dryRun, debug bool,
+) error {
+	InitLogging(debug)
+	return forEachWorkspaceProject(project, func(string) error {
+		if dryRun {
+			return DryRun(profileName, defines, nil, false, debug)
+		}
+		return Run(profileName, defines, recycled, debug)
+	})
+}
+
+// InstallAllWorkspace handles the "regolith install-all" command with the
+// "--all" and "--project" flags. It installs the filters of every project of
+// the workspace (see FindWorkspace), or of the selected project and the
+// projects it depends on. The projects share the filter cache, so the
+// filters used by multiple projects are downloaded only once.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func InstallAllWorkspace(project string, force, offline, debug bool) error {
+	InitLogging(debug)
+	return forEachWorkspaceProject(project, func(string) error {
+		return InstallAll(force, offline, debug)
+	})
 }
 
 // Init handles the "regolith init" command. It initializes a new Regolith
@@ -335,20 +1156,9 @@ func Watch(profileName string, recycled, debug bool) error {
 func Init(debug bool) error {
 	InitLogging(debug)
 	Logger.Info("Initializing Regolith project...")
-
-	wd, err := os.Getwd()
+	_, err := checkInitDirectory()
 	if err != nil {
-		return WrapError(
-			err, osGetwdError)
-	}
-	if isEmpty, err := IsDirEmpty(wd); err != nil {
-		return WrapErrorf(
-			err, "Failed to check if %s is an empty directory.", wd)
-	} else if !isEmpty {
-		return WrappedErrorf(
-			"Cannot initialze the project, because %s is not an empty "+
-				"directory.\n\"regolith init\" can be used only in empty "+
-				"directories.", wd)
+		return PassError(err)
 	}
 	ioutil.WriteFile(".gitignore", []byte(GitIgnore), 0644)
 	// Create new default configuration
@@ -360,6 +1170,7 @@ func Init(debug bool) error {
 			ResourceFolder: "./packs/RP",
 		},
 		RegolithProject: RegolithProject{
+			FormatVersion:     LatestConfigFormatVersion,
 			DataPath:          "./packs/data",
 			FilterDefinitions: map[string]FilterInstaller{},
 			Profiles: map[string]Profile{
@@ -404,34 +1215,193 @@ func Init(debug bool) error {
 	return nil
 }
 
+// InitFromTemplate handles the "regolith init" command with the "--template"
+// flag. It creates the project in the current directory from the files of
+// the template (see resolveTemplate), with the name and the namespace of the
+// project inserted into them (see applyTemplate). Without the template, the
+// "initTemplate" from the user config is used. If there's no template, or
+// the template is "bare", the bare project is created (see Init).
+//
+// The "name" parameter is the name of the project. If it's empty, the name
+// of the current directory is used. The "namespace" parameter is the
+// namespace of the project. If it's empty, it's created from the name.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func InitFromTemplate(template, name, namespace string, debug bool) error {
+	InitLogging(debug)
+	if template == "" {
+		userConfig, err := LoadUserConfig()
+		if err != nil {
+			return WrapError(err, "Failed to load the user config.")
+		}
+		template = userConfig.InitTemplate
+	}
+	if template == "" || template == bareTemplate {
+		return Init(debug)
+	}
+	Logger.Infof(
+		"Initializing Regolith project from the %q template...", template)
+	wd, err := checkInitDirectory()
+	if err != nil {
+		return PassError(err)
+	}
+	if name == "" {
+		name = filepath.Base(wd)
+	}
+	if namespace == "" {
+		namespace = defaultNamespace(name)
+	} else if namespaceInvalidChars.MatchString(namespace) {
+		return WrappedErrorf(
+			"Invalid namespace.\nNamespace: %s\nThe namespace can only "+
+				"contain lowercase letters, digits and underscores.",
+			namespace)
+	}
+	files, cleanup, err := resolveTemplate(template)
+	defer cleanup()
+	if err != nil {
+		return PassError(err)
+	}
+	err = applyTemplate(files, &templateVariables{
+		ProjectName: name,
+		Namespace:   namespace,
+		uuids:       make(map[string]string),
+	})
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to create the project from the template.\n"+
+				"Template: %s", template)
+	}
+	if _, err := os.Stat(".gitignore"); os.IsNotExist(err) {
+		ioutil.WriteFile(".gitignore", []byte(GitIgnore), 0644)
+	}
+	Logger.Infof(
+		"Regolith project initialized.\nName: %s\nNamespace: %s",
+		name, namespace)
+	return nil
+}
+
+// checkInitDirectory returns the path to the current directory or an error
+// if the directory isn't empty, because "regolith init" can't create the
+// project there.
+func checkInitDirectory() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", WrapError(err, osGetwdError)
+	}
+	if isEmpty, err := IsDirEmpty(wd); err != nil {
+		return "", WrapErrorf(
+			err, "Failed to check if %s is an empty directory.", wd)
+	} else if !isEmpty {
+		return "", WrappedErrorf(
+			"Cannot initialze the project, because %s is not an empty "+
+				"directory.\n\"regolith init\" can be used only in empty "+
+				"directories.", wd)
+	}
+	return wd, nil
+}
+
+// CleanScope selects the files removed by the "regolith clean" command. If
+// none of the fields is set, the whole cache folder of the project is
+// removed.
+type CleanScope struct {
+	// Tmp removes the files of the last run from the "tmp" folder
+	Tmp bool
+	// PathStates removes the cached states of the paths used by the
+	// recycled mode
+	PathStates bool
+	// Filters removes the downloaded filters and their virtual environments
+	Filters bool
+	// Exported removes the packs exported by the profiles with the
+	// "development" and "preview" export targets
+	Exported bool
+}
+
+// wholeCache returns true if the scope doesn't select any part of the cache,
+// which means that the whole cache folder should be removed.
+func (s CleanScope) wholeCache() bool {
+	return !s.Tmp && !s.PathStates && !s.Filters && !s.Exported
+}
+
 // Cleans the cache folder of regolith (.regolith in normal mode or a path in
 // AppData). The path to clean is determined by the dotRegolithPath parameter.
-// leaveEmptyPath determines if regolith should leave an empty folder at
-// dotRegolithPath
-func clean(cachedStatesOnly bool, dotRegolithPath string) error {
-	if cachedStatesOnly {
+// The scope selects the parts of the cache to remove.
+func clean(scope CleanScope, dotRegolithPath string) error {
+	if scope.wholeCache() {
+		err := os.RemoveAll(dotRegolithPath)
+		if err != nil {
+			return WrapErrorf(err, "failed to remove %q folder", dotRegolithPath)
+		}
+		return nil
+	}
+	if scope.PathStates {
 		err := ClearCachedStates()
 		if err != nil {
 			return WrapError(err, clearCachedStatesError)
 		}
-	} else {
-		err := os.RemoveAll(dotRegolithPath)
+	}
+	var paths []string
+	if scope.Tmp {
+		paths = append(paths, "tmp")
+	}
+	if scope.Filters {
+		paths = append(paths, "cache/filters", "cache/venvs", filterVenvsPath)
+	}
+	if scope.Exported {
+		// Without the state files, the next export is a full export
+		paths = append(paths, gcStateFiles...)
+	}
+	for _, path := range paths {
+		path = filepath.Join(dotRegolithPath, path)
+		err := os.RemoveAll(path)
 		if err != nil {
-			return WrapErrorf(err, "failed to remove %q folder", dotRegolithPath)
+			return WrapErrorf(err, osRemoveError, path)
 		}
 	}
+	return nil
+}
 
+// cleanExportedPacks removes the packs exported by the profiles of the
+// config with the "development" and "preview" export targets.
+func cleanExportedPacks(config *Config) error {
+	removed := make(map[string]struct{})
+	for _, profile := range config.Profiles {
+		target := profile.ExportTarget.Target
+		if target != "development" && target != "preview" {
+			continue
+		}
+		bpPath, rpPath, err := GetExportPaths(profile.ExportTarget, config.Name)
+		if err != nil { // Nothing could be exported there
+			Logger.Warnf(
+				"Failed to get the export paths:\n%s", PassError(err).Error())
+			continue
+		}
+		for _, path := range []string{bpPath, rpPath} {
+			if _, ok := removed[path]; ok {
+				continue
+			}
+			removed[path] = struct{}{}
+			if _, err := os.Lstat(path); err != nil {
+				continue
+			}
+			Logger.Infof("Removing the exported pack %s...", path)
+			err := removeExportedPath(path)
+			if err != nil {
+				return PassError(err)
+			}
+		}
+	}
 	return nil
 }
 
-func CleanCurrentProject(cachedStatesOnly bool) error {
+func CleanCurrentProject(scope CleanScope) error {
 	Logger.Infof("Cleaning cache...")
 	// Load the useAppData property form config
-	config, err := LoadConfigAsMap()
+	configJson, err := LoadConfigAsMap()
 	if err != nil {
 		return WrapError(err, "Unable to load config file.")
 	}
-	useAppData, err := useAppDataFromConfigMap(config)
+	useAppData, err := useAppDataFromConfigMap(configJson)
 	if err != nil {
 		return WrapError(
 			err, "Failed to get the value of useAppData property from the "+
@@ -449,7 +1419,7 @@ func CleanCurrentProject(cachedStatesOnly bool) error {
 	if useAppData {
 		// Can fail
 		Logger.Infof("Trying to clean \".regolith\" if it exists...")
-		clean(cachedStatesOnly, ".regolith")
+		clean(scope, ".regolith")
 		// Can't fail
 		Logger.Infof("Cleaning the cache in application data folder...")
 		dotRegolithPath, err := GetDotRegolith(true, true, ".")
@@ -458,7 +1428,7 @@ func CleanCurrentProject(cachedStatesOnly bool) error {
 				err, "Unable to get the path to regolith cache folder.")
 		}
 		Logger.Infof("Regolith cache folder is: %s", dotRegolithPath)
-		err = clean(cachedStatesOnly, dotRegolithPath)
+		err = clean(scope, dotRegolithPath)
 		if err != nil {
 			return WrapErrorf(
 				err, "Failed to clean the cache from %q.", dotRegolithPath)
@@ -469,26 +1439,40 @@ func CleanCurrentProject(cachedStatesOnly bool) error {
 			"Trying to clean the Regolith cache from app data folder if it exists...")
 		dotRegolithPath, err := GetDotRegolith(true, true, ".")
 		if err != nil {
-			clean(cachedStatesOnly, dotRegolithPath)
+			clean(scope, dotRegolithPath)
 		}
 		// Can't fail
 		Logger.Infof("Cleaning \".regolith\"...")
-		clean(cachedStatesOnly, ".regolith")
+		clean(scope, ".regolith")
 		if err != nil {
 			return WrapErrorf(
 				err, "Failed to clean the cache from \".regolith\".")
 		}
 	}
+	if scope.Exported {
+		config, err := ConfigFromObject(configJson)
+		if err != nil {
+			return WrapError(err, "Could not load \"config.json\".")
+		}
+		err = cleanExportedPacks(config)
+		if err != nil {
+			return WrapError(err, "Failed to remove the exported packs.")
+		}
+	}
 	Logger.Infof("Cache cleaned.")
+	if scope.wholeCache() || scope.Filters {
+		Logger.Infof(
+			"Run \"regolith install-all\" to download the filters again.")
+	}
 	return nil
 }
 
 func CleanUserCache() error {
 	Logger.Infof("Cleaning all Regolith cache files from user app data...")
 	// App data enabled - use user cache dir
-	userCache, err := os.UserCacheDir()
+	userCache, err := regolithCacheDir()
 	if err != nil {
-		return WrappedError(osUserCacheDirError)
+		return PassError(err)
 	}
 	regolithCacheFiles := filepath.Join(userCache, appDataCachePath)
 	Logger.Infof("Regolith cache files are located in: %s", regolithCacheFiles)
@@ -497,26 +1481,134 @@ func CleanUserCache() error {
 		return WrapErrorf(err, "failed to remove %q folder", regolithCacheFiles)
 	}
 	os.MkdirAll(regolithCacheFiles, 0755)
+	sharedFilterCache := filepath.Join(userCache, sharedFilterCachePath)
+	Logger.Infof("Regolith shared filter cache is located in: %s", sharedFilterCache)
+	err = os.RemoveAll(sharedFilterCache)
+	if err != nil {
+		return WrapErrorf(err, "failed to remove %q folder", sharedFilterCache)
+	}
 	Logger.Infof("All regolith files cached in user app data cleaned.")
 	return nil
 }
 
 // Clean handles the "regolith clean" command. It cleans the cache from the
-// dotRegolithPath directory.
+// dotRegolithPath directory. The scope selects the parts of the cache to
+// remove (see CleanScope).
 //
 // The "debug" parameter is a boolean that determines if the debug messages
 // should be printed.
-func Clean(debug, userCache, cachedStatesOnly bool) error {
+func Clean(debug, userCache bool, scope CleanScope) error {
 	InitLogging(debug)
 	if userCache {
-		if cachedStatesOnly {
+		if !scope.wholeCache() {
 			return WrappedError(
-				"Cannot mix --user-cache and --cached-states-only flags.")
+				"Cannot mix --user-cache with the flags that select the " +
+					"files to clean.")
 		}
 		return CleanUserCache()
 	} else {
-		return CleanCurrentProject(cachedStatesOnly)
+		return CleanCurrentProject(scope)
+	}
+}
+
+// CacheGc handles the "regolith cache gc" command. It removes the cached
+// filters, venvs, filter outputs and state files that aren't used by any
+// known project, or weren't used for "retentionDays" days (see cacheGc).
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func CacheGc(retentionDays int, debug bool) error {
+	InitLogging(debug)
+	if retentionDays < 0 {
+		return WrappedErrorf(
+			"The retention period can't be negative.\nDays: %d", retentionDays)
+	}
+	Logger.Infof(
+		"Removing unused Regolith cache files (retention period: %d days)...",
+		retentionDays)
+	freed, err := cacheGc(time.Duration(retentionDays) * 24 * time.Hour)
+	if err != nil {
+		return WrapError(err, "Failed to clean up the Regolith cache.")
+	}
+	Logger.Infof("Freed %s.", formatSize(freed))
+	return nil
+}
+
+// CacheInfo handles the "regolith cache info" command. It prints the
+// locations and the sizes of the caches of the known projects and the shared
+// filter cache.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func CacheInfo(debug bool) error {
+	InitLogging(debug)
+	return cacheInfo()
+}
+
+// SecretSet handles the "regolith secret set" command. It saves the secret in
+// the credential store of the operating system, so it can be used with the
+// "${secret:NAME}" placeholder. If the value is empty, it's read from the
+// standard input, so it doesn't end up in the history of the shell.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func SecretSet(name, value string, debug bool) error {
+	InitLogging(debug)
+	if err := checkSecretName(name); err != nil {
+		return PassError(err)
+	}
+	if value == "" {
+		fmt.Printf("Value of the %q secret: ", name)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return WrapError(err, "Failed to read the value of the secret.")
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if value == "" {
+		return WrappedError("The value of the secret can't be empty.")
+	}
+	if err := writeSecret(name, value); err != nil {
+		return WrapErrorf(
+			err, "Failed to save the secret in the credential store.\n"+
+				"Secret: %s", name)
+	}
+	Logger.Infof("Saved the %q secret.", name)
+	return nil
+}
+
+// SecretGet handles the "regolith secret get" command. It prints the value
+// of the secret (see lookupSecret) to the standard output.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func SecretGet(name string, debug bool) error {
+	InitLogging(debug)
+	value, err := lookupSecret(name)
+	if err != nil {
+		return PassError(err)
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// SecretDelete handles the "regolith secret delete" command. It removes the
+// secret from the credential store of the operating system.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func SecretDelete(name string, debug bool) error {
+	InitLogging(debug)
+	if err := checkSecretName(name); err != nil {
+		return PassError(err)
+	}
+	if err := deleteSecret(name); err != nil {
+		return WrapErrorf(
+			err, "Failed to delete the secret from the credential store.\n"+
+				"Secret: %s", name)
 	}
+	Logger.Infof("Deleted the %q secret.", name)
+	return nil
 }
 
 // Unlock handles the "regolith unlock". It unlocks safe mode, by signing the
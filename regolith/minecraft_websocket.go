@@ -0,0 +1,284 @@
+package regolith
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// webSocketGuid is the GUID used for calculating the Sec-WebSocket-Accept
+// header of the WebSocket handshake (RFC 6455).
+const webSocketGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketFrameSize is the maximal size of the payload of the frames
+// received from Minecraft. Minecraft only sends small JSON messages.
+const maxWebSocketFrameSize = 16 * 1024 * 1024
+
+// The opcodes of the WebSocket frames.
+const (
+	webSocketText  = 0x1
+	webSocketClose = 0x8
+	webSocketPing  = 0x9
+	webSocketPong  = 0xA
+)
+
+// MinecraftWebSocketServer is a WebSocket server that Minecraft can connect
+// to with the "/connect" command. It's used for sending commands to the game,
+// for example for reloading the packs after an export in watch mode.
+type MinecraftWebSocketServer struct {
+	server      *http.Server
+	connections map[*minecraftConnection]struct{}
+	mutex       sync.Mutex
+}
+
+// minecraftConnection is a connection to a Minecraft client.
+type minecraftConnection struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+}
+
+// StartMinecraftWebSocketServer starts a WebSocket server on the port, that
+// accepts the connections from Minecraft.
+func StartMinecraftWebSocketServer(port int) (*MinecraftWebSocketServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, WrapErrorf(
+			err, "Failed to start the WebSocket server.\nPort: %d", port)
+	}
+	s := &MinecraftWebSocketServer{
+		connections: make(map[*minecraftConnection]struct{}),
+	}
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handleConnection)}
+	go s.server.Serve(listener)
+	Logger.Infof(
+		"Started the WebSocket server. Type \"/connect localhost:%d\" in "+
+			"Minecraft to reload the game after each export.", port)
+	return s, nil
+}
+
+// Close stops the server and closes the connections.
+func (s *MinecraftWebSocketServer) Close() {
+	s.server.Close()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for connection := range s.connections {
+		connection.conn.Close()
+		delete(s.connections, connection)
+	}
+}
+
+// Reload sends the "reload" command to all of the connected Minecraft
+// clients.
+func (s *MinecraftWebSocketServer) Reload() {
+	s.RunCommand("reload")
+}
+
+// RunCommand sends a command to all of the connected Minecraft clients.
+func (s *MinecraftWebSocketServer) RunCommand(command string) {
+	message, err := minecraftCommandRequest(command)
+	if err != nil {
+		Logger.Warnf(
+			"Failed to create the command request:\n%s",
+			PassError(err).Error())
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for connection := range s.connections {
+		err := connection.writeFrame(webSocketText, message)
+		if err != nil {
+			Logger.Warnf("Failed to send command to Minecraft: %s", err)
+			connection.conn.Close()
+			delete(s.connections, connection)
+			continue
+		}
+		Logger.Infof("Sent \"/%s\" command to Minecraft.", command)
+	}
+}
+
+// handleConnection handles the WebSocket handshake and reads the messages
+// from the connected Minecraft client until the connection is closed.
+func (s *MinecraftWebSocketServer) handleConnection(
+	w http.ResponseWriter, r *http.Request,
+) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "Expected a WebSocket connection.", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Unsupported connection.", http.StatusInternalServerError)
+		return
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		Logger.Warnf("Failed to accept WebSocket connection: %s", err)
+		return
+	}
+	acceptHash := sha1.Sum([]byte(key + webSocketGuid))
+	_, err = fmt.Fprintf(
+		conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(acceptHash[:]))
+	if err != nil {
+		conn.Close()
+		return
+	}
+	connection := &minecraftConnection{conn: conn}
+	s.mutex.Lock()
+	s.connections[connection] = struct{}{}
+	s.mutex.Unlock()
+	Logger.Info("Minecraft connected to the WebSocket server.")
+	defer func() {
+		s.mutex.Lock()
+		delete(s.connections, connection)
+		s.mutex.Unlock()
+		conn.Close()
+		Logger.Info("Minecraft disconnected from the WebSocket server.")
+	}()
+	for {
+		opcode, payload, err := readWebSocketFrame(buffer.Reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case webSocketClose:
+			connection.writeFrame(webSocketClose, nil)
+			return
+		case webSocketPing:
+			connection.writeFrame(webSocketPong, payload)
+		case webSocketText:
+			logMinecraftMessage(payload)
+		}
+	}
+}
+
+// logMinecraftMessage logs the responses to the commands sent to Minecraft.
+func logMinecraftMessage(payload []byte) {
+	var message struct {
+		Header struct {
+			MessagePurpose string `json:"messagePurpose"`
+		} `json:"header"`
+		Body struct {
+			StatusCode    int    `json:"statusCode"`
+			StatusMessage string `json:"statusMessage"`
+		} `json:"body"`
+	}
+	if json.Unmarshal(payload, &message) != nil ||
+		message.Header.MessagePurpose != "commandResponse" {
+		return
+	}
+	if message.Body.StatusCode < 0 {
+		Logger.Warnf(
+			"Minecraft failed to run the command: %s",
+			message.Body.StatusMessage)
+	} else {
+		Logger.Debugf("Minecraft: %s", message.Body.StatusMessage)
+	}
+}
+
+// minecraftCommandRequest returns a message that runs the command in
+// Minecraft.
+func minecraftCommandRequest(command string) ([]byte, error) {
+	requestId := make([]byte, 16)
+	if _, err := rand.Read(requestId); err != nil {
+		return nil, WrapError(err, "Failed to generate request ID.")
+	}
+	requestId[6] = requestId[6]&0x0f | 0x40 // Version 4
+	requestId[8] = requestId[8]&0x3f | 0x80 // Variant 10
+	request := map[string]interface{}{
+		"header": map[string]interface{}{
+			"version": 1,
+			"requestId": fmt.Sprintf(
+				"%x-%x-%x-%x-%x", requestId[0:4], requestId[4:6],
+				requestId[6:8], requestId[8:10], requestId[10:]),
+			"messageType":    "commandRequest",
+			"messagePurpose": "commandRequest",
+		},
+		"body": map[string]interface{}{
+			"version":     1,
+			"commandLine": command,
+			"origin":      map[string]interface{}{"type": "player"},
+		},
+	}
+	return json.Marshal(request)
+}
+
+// writeFrame sends a WebSocket frame. The frames sent by the server are not
+// masked.
+func (c *minecraftConnection) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode} // FIN bit and opcode
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// readWebSocketFrame reads a WebSocket frame and returns its opcode and
+// unmasked payload.
+func readWebSocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxWebSocketFrameSize {
+		return 0, nil, WrappedErrorf(
+			"WebSocket frame is too large.\nSize: %d", length)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,87 @@
+package regolith
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// proxyEnvVars are the environment variables used by Go, git and the package
+// managers for the proxies. Both the lowercase and uppercase variants are
+// set, because some programs (like curl, used by git) accept only the
+// lowercase variant of "http_proxy".
+var proxyEnvVars = [][2]string{
+	{"http_proxy", "HTTP_PROXY"},
+	{"https_proxy", "HTTPS_PROXY"},
+	{"all_proxy", "ALL_PROXY"},
+}
+
+// ApplyUserProxy sets the proxy environment variables to the proxy from the
+// user config, unless they're already set. The variables are used by every
+// download of Regolith and the programs that it runs, so the function must
+// be called before any network access (Go reads the variables only once).
+// The errors are ignored, because the logger isn't initialized yet, and the
+// invalid user config is reported by the commands that use it.
+func ApplyUserProxy() {
+	userConfig, err := LoadUserConfig()
+	if err != nil || userConfig.Proxy == "" {
+		return
+	}
+	for _, names := range proxyEnvVars {
+		if os.Getenv(names[0]) != "" || os.Getenv(names[1]) != "" {
+			continue
+		}
+		os.Setenv(names[0], userConfig.Proxy)
+		os.Setenv(names[1], userConfig.Proxy)
+	}
+}
+
+// mirrorUrls returns the URLs of the mirrors of the URL, based on the
+// "mirrors" property of the user config, followed by the URL itself. The
+// mirrors replace the longest matching prefix of the URL.
+func mirrorUrls(url string) []string {
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		Logger.Warnf("Unable to load the mirrors from the user config:\n%s", err)
+		return []string{url}
+	}
+	prefixes := make([]string, 0, len(userConfig.Mirrors))
+	for prefix := range userConfig.Mirrors {
+		prefixes = append(prefixes, prefix)
+	}
+	// The longest prefix is the most specific one
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	var result []string
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
+		for _, mirror := range userConfig.Mirrors[prefix] {
+			result = append(result, mirror+strings.TrimPrefix(url, prefix))
+		}
+		break
+	}
+	return append(result, url)
+}
+
+// tryMirrors calls the function with the mirrors of the URL and the URL
+// itself (see mirrorUrls), until it succeeds. It returns the error of the
+// last attempt if all of them fail.
+func tryMirrors(url string, f func(url string) error) error {
+	urls := mirrorUrls(url)
+	var err error
+	for i, mirrorUrl := range urls {
+		err = f(mirrorUrl)
+		if err == nil {
+			return nil
+		}
+		if i < len(urls)-1 {
+			Logger.Warnf(
+				"Failed to access \"%s\", trying \"%s\".\n%s",
+				mirrorUrl, urls[i+1], err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package regolith
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persistentProcess is a filter process that is started once and handles
+// multiple runs. Regolith sends the run requests to the process using the
+// "stdio" filter protocol (see filter_protocol.go). The process is expected
+// to handle the requests one after another until its stdin is closed.
+type persistentProcess struct {
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	stdout      *bufio.Scanner
+	outputLabel string
+	// mutex is held during a request, because the filters that run in
+	// parallel can use the same process (see runFiltersInParallel)
+	mutex sync.Mutex
+}
+
+// persistentProcessStopTimeout is the time that the persistent process has
+// to exit after closing its stdin, before it's killed.
+const persistentProcessStopTimeout = 5 * time.Second
+
+// persistentProcesses is a registry of the running persistent processes. The
+// keys are the commands used for starting the processes.
+var persistentProcesses = make(map[string]*persistentProcess)
+var persistentProcessesMutex sync.Mutex
+
+// RunPersistentSubProcess sends a run request of the filter that runs in the
+// context to a persistent filter process started with the command and args,
+// and waits until the run is finished. If the process isn't running yet,
+// it's started first. The filterDir, workingDir and outputLabel have the
+// same meaning as in RunSubProcess, but the process runs in the filterDir.
+// The working directory is removed and created again by every run (see
+// SetupTmpFiles), so the process would be left in a deleted directory on
+// Linux, and it would prevent removing the directory on Windows. The
+// process gets the path to the working directory in every request instead.
+func RunPersistentSubProcess(
+	context RunContext,
+	command string, args []string, filterDir, workingDir, outputLabel string,
+	settings map[string]interface{}, arguments []string,
+) error {
+	key := strings.Join(append([]string{command}, args...), " ")
+	persistentProcessesMutex.Lock()
+	process, ok := persistentProcesses[key]
+	if !ok {
+		var err error
+		process, err = startPersistentProcess(
+			command, args, filterDir, outputLabel)
+		if err != nil {
+			persistentProcessesMutex.Unlock()
+			return WrapErrorf(err, "Failed to start persistent process.\n"+
+				"Command: %s", key)
+		}
+		persistentProcesses[key] = process
+	}
+	persistentProcessesMutex.Unlock()
+
+	err := process.run(context, workingDir, settings, arguments)
+	if err != nil {
+		// The process might be in a broken state, start a new one next time.
+		// Another request could have replaced it already.
+		persistentProcessesMutex.Lock()
+		if persistentProcesses[key] == process {
+			delete(persistentProcesses, key)
+		}
+		persistentProcessesMutex.Unlock()
+		process.stop()
+		return PassError(err)
+	}
+	return nil
+}
+
+// StopPersistentProcesses stops all of the running persistent processes.
+func StopPersistentProcesses() {
+	persistentProcessesMutex.Lock()
+	defer persistentProcessesMutex.Unlock()
+	for key, process := range persistentProcesses {
+		Logger.Debugf("Stopping persistent process: %s", key)
+		process.stop()
+		delete(persistentProcesses, key)
+	}
+}
+
+// startPersistentProcess starts a new persistent process in the filterDir.
+func startPersistentProcess(
+	command string, args []string, filterDir, outputLabel string,
+) (*persistentProcess, error) {
+	Logger.Debugf("Starting persistent process: %s %s",
+		command, hideSecrets(strings.Join(args, " ")))
+	cmd := exec.Command(command, args...)
+	cmd.Dir = filterDir
+	env, err := CreateEnvironmentVariables(filterDir)
+	if err != nil {
+		return nil, WrapErrorf(
+			err,
+			"Failed to create FILTER_DIR and ROOT_DIR environment variables.")
+	}
+	cmd.Env = append(
+		env, "REGOLITH_PERSISTENT=1",
+		"REGOLITH_PROTOCOL="+stdioFilterProtocol)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, WrapError(err, "Failed to open stdin of the process.")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, WrapError(err, "Failed to open stdout of the process.")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, WrapError(err, "Failed to open stderr of the process.")
+	}
+	go LogStd(stderr, outputLogger(outputLabel).Errorf, outputLabel)
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, WrapErrorf(err, execCommandError, command)
+	}
+	return &persistentProcess{
+		cmd:         cmd,
+		stdin:       stdin,
+		stdout:      newFilterProtocolScanner(stdout),
+		outputLabel: outputLabel,
+	}, nil
+}
+
+// run sends a run request of the filter that runs in the context to the
+// process and waits for the response. The requests sent at the same time
+// are handled one after another.
+func (p *persistentProcess) run(
+	context RunContext, workingDir string, settings map[string]interface{},
+	arguments []string,
+) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// The process is tracked only while it handles the request, so the
+	// timeout of one filter doesn't kill the idle processes of other filters
+	trackSubProcess(p.cmd, context.processOwner)
+	defer untrackSubProcess(p.cmd)
+	return sendFilterProtocolRequest(
+		p.stdin, p.stdout, p.outputLabel, workingDir, settings, arguments)
+}
+
+// stop closes the stdin of the process, which should make it exit, and
+// waits for it. If the process doesn't exit in persistentProcessStopTimeout,
+// it's killed.
+func (p *persistentProcess) stop() {
+	exited := make(chan struct{})
+	go func() {
+		p.cmd.Wait()
+		close(exited)
+	}()
+	p.stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(persistentProcessStopTimeout):
+		p.cmd.Process.Kill()
+		<-exited
+	}
+}
@@ -1,14 +1,12 @@
 package regolith
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/otiai10/copy"
 )
 
 // RecycledSetupTmpFiles set up the workspace for the filters. The function
@@ -27,11 +25,11 @@ func RecycledSetupTmpFiles(config Config, profile Profile, dotRegolithPath strin
 		err = FullRecycledMoveOrCopy(
 			config.ResourceFolder, filepath.Join(tmpPath, "RP"),
 			RecycledMoveOrCopySettings{
-				canMove:                 false,
-				saveSourceHashes:        false,
-				saveTargetHashes:        false,
-				copyTargetAclFromParent: false,
-				reloadSourceHashes:      true,
+				canMove:            false,
+				saveSourceHashes:   true,
+				saveTargetHashes:   true,
+				reloadSourceHashes: true,
+				reloadTargetHashes: true,
 			})
 		if err != nil {
 			return WrapErrorf(
@@ -42,26 +40,48 @@ func RecycledSetupTmpFiles(config Config, profile Profile, dotRegolithPath strin
 		err = FullRecycledMoveOrCopy(
 			config.BehaviorFolder, filepath.Join(tmpPath, "BP"),
 			RecycledMoveOrCopySettings{
-				canMove:                 false,
-				saveSourceHashes:        false,
-				saveTargetHashes:        false,
-				copyTargetAclFromParent: false,
-				reloadSourceHashes:      true,
+				canMove:            false,
+				saveSourceHashes:   true,
+				saveTargetHashes:   true,
+				reloadSourceHashes: true,
+				reloadTargetHashes: true,
 			})
 		if err != nil {
 			return WrapErrorf(
 				err, "Failed to setup BP folder in the temporary directory.")
 		}
 	}
+	err = removeUnusedPackDirs(tmpPath, config.AdditionalPacks)
+	if err != nil {
+		return WrapError(
+			err, "Failed to remove the unused packs from the temporary "+
+				"directory.")
+	}
+	for _, pack := range listAdditionalPacks(config.AdditionalPacks) {
+		err = FullRecycledMoveOrCopy(
+			pack.Source, filepath.Join(tmpPath, pack.TmpDir),
+			RecycledMoveOrCopySettings{
+				canMove:            false,
+				saveSourceHashes:   true,
+				saveTargetHashes:   true,
+				reloadSourceHashes: true,
+				reloadTargetHashes: true,
+			})
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to setup %s folder in the temporary directory.",
+				pack.TmpDir)
+		}
+	}
 	if config.DataPath != "" {
 		err = FullRecycledMoveOrCopy(
 			config.DataPath, filepath.Join(tmpPath, "data"),
 			RecycledMoveOrCopySettings{
-				canMove:                 false,
-				saveSourceHashes:        false,
-				saveTargetHashes:        false,
-				copyTargetAclFromParent: false,
-				reloadSourceHashes:      true,
+				canMove:            false,
+				saveSourceHashes:   true,
+				saveTargetHashes:   true,
+				reloadSourceHashes: true,
+				reloadTargetHashes: true,
 			})
 		if err != nil {
 			return WrapErrorf(
@@ -69,6 +89,7 @@ func RecycledSetupTmpFiles(config Config, profile Profile, dotRegolithPath strin
 		}
 	}
 
+	recordTmpSources(config, dotRegolithPath, false)
 	Logger.Debug("Setup done in ", time.Since(start))
 	return nil
 }
@@ -89,6 +110,17 @@ func SetupTmpFiles(config Config, profile Profile, dotRegolithPath string) error
 		return WrapErrorf(err, osMkdirError, tmpPath)
 	}
 
+	// Hardlinks would make the source files read-only with the read-only
+	// export
+	linkFiles := config.TmpSetup == tmpSetupHardlink
+	if linkFiles && profile.ExportTarget.ReadOnly {
+		Logger.Warn(
+			"The \"hardlink\" tmpSetup can't be used with the read-only " +
+				"export, because it would make the source files read-only. " +
+				"Copying the files instead.")
+		linkFiles = false
+	}
+
 	// Copy the contents of the 'regolith' folder to '[dotRegolithPath]/tmp'
 	Logger.Debugf("Copying project files to \"%s\"", tmpPath)
 	// Avoid repetetive code of preparing ResourceFolder, BehaviorFolder
@@ -109,10 +141,10 @@ func SetupTmpFiles(config Config, profile Profile, dotRegolithPath string) error
 					}
 				}
 			} else if stats.IsDir() {
-				err = copy.Copy(
-					path,
-					p,
-					copy.Options{PreserveTimes: false, Sync: false})
+				// The data is moved back to the data path after the run, so
+				// it's always copied
+				err = copyDir(
+					path, p, linkFiles && shortName != "data", config.Symlinks)
 				if err != nil {
 					return WrapErrorf(err, osCopyError, path, p)
 				}
@@ -138,12 +170,21 @@ func SetupTmpFiles(config Config, profile Profile, dotRegolithPath string) error
 		return WrapErrorf(
 			err, "Failed to setup BP folder in the temporary directory.")
 	}
+	for _, pack := range listAdditionalPacks(config.AdditionalPacks) {
+		err = setup_tmp_directory(pack.Source, pack.TmpDir, "additional pack")
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to setup %s folder in the temporary directory.",
+				pack.TmpDir)
+		}
+	}
 	err = setup_tmp_directory(config.DataPath, "data", "data folder")
 	if err != nil {
 		return WrapErrorf(
 			err, "Failed to setup data folder in the temporary directory.")
 	}
 
+	recordTmpSources(config, dotRegolithPath, linkFiles)
 	Logger.Debug("Setup done in ", time.Since(start))
 	return nil
 }
@@ -164,6 +205,53 @@ func CheckProfileImpl(
 			return WrapErrorf(err, filterRunnerCheckError, f.GetId())
 		}
 	}
+	// Check the dependencies between the filters
+	if profile.runsInParallel() {
+		if _, err := filterDependencies(profile.Filters); err != nil {
+			return WrapErrorf(
+				err, "Invalid \"needs\" property in profile.\nProfile: %s",
+				profileName)
+		}
+		// The cached filters restore the whole tmp directory, which would
+		// overwrite the output of the filters running at the same time
+		if usesFilterCache(profile.Filters) {
+			return WrappedErrorf(
+				"The \"cache\" property can't be used in profiles that "+
+					"run the filters in parallel (use \"needs\", \"inputs\" "+
+					"or \"outputs\").\n"+
+					"Profile: %s", profileName)
+		}
+		// The retried filters restore the tmp directory in the same way
+		if usesFilterRetries(profile.Filters) {
+			return WrappedErrorf(
+				"The \"retries\" property can't be used in profiles that "+
+					"run the filters in parallel (use \"needs\", \"inputs\" "+
+					"or \"outputs\").\n"+
+					"Profile: %s", profileName)
+		}
+	}
+	return nil
+}
+
+// saveTmpStates saves the states of the tmp files of the context in the
+// default cache (see SaveStateInDefaultCache), so the next recycled run
+// copies only the files that changed.
+func saveTmpStates(context RunContext) error {
+	err1 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/RP"))
+	err2 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/BP"))
+	err3 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/data"))
+	for _, pack := range listAdditionalPacks(context.Config.AdditionalPacks) {
+		err := SaveStateInDefaultCache(
+			filepath.Join(context.DotRegolithPath, "tmp", pack.TmpDir))
+		err3 = firstErr(err3, err)
+	}
+	if err := firstErr(err1, err2, err3); err != nil {
+		err1 := ClearCachedStates() // Just to be safe - clear cached states
+		if err1 != nil {
+			err = WrapError(err1, clearCachedStatesError)
+		}
+		return WrapError(err, "Failed to save file path states in cache.")
+	}
 	return nil
 }
 
@@ -176,17 +264,12 @@ func RecycledRunProfile(context RunContext) error {
 	// saveTmp saves the state of the tmp files. This is useful only if runnig
 	// in the watch mode.
 	saveTmp := func() error {
-		err1 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/RP"))
-		err2 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/BP"))
-		err3 := SaveStateInDefaultCache(filepath.Join(context.DotRegolithPath, "tmp/data"))
-		if err := firstErr(err1, err2, err3); err != nil {
-			err1 := ClearCachedStates() // Just to be safe - clear cached states
-			if err1 != nil {
-				err = WrapError(err1, clearCachedStatesError)
-			}
-			return WrapError(err, "Failed to save file path states in cache.")
-		}
-		return nil
+		return saveTmpStates(context)
+	}
+	applyReproducibleEnv()
+	err := recoverInterruptedExport(context.DotRegolithPath)
+	if err != nil {
+		return PassError(err)
 	}
 	// The label and goto can be easily changed to a loop with continue and
 	// break but I find this more readable. If you want to change it, because
@@ -197,7 +280,13 @@ start:
 	if err != nil {
 		return WrapErrorf(err, runContextGetProfileError)
 	}
+	err = context.prepareChangedFiles()
+	if err != nil {
+		return WrapError(err, "Failed to save the list of changed files.")
+	}
+	endSetup := startPhase(context, "setup")
 	err = RecycledSetupTmpFiles(*context.Config, profile, context.DotRegolithPath)
+	endSetup()
 	if err != nil {
 		err1 := ClearCachedStates() // Just to be safe clear cached states
 		if err1 != nil {
@@ -222,11 +311,23 @@ start:
 		}
 		goto start
 	}
+	if context.SkipExport {
+		Logger.Infof(
+			"Skipping the export, the files are in %q.",
+			filepath.Join(context.DotRegolithPath, "tmp"))
+		return saveTmp()
+	}
+	if context.isCancelled() {
+		return WrappedError(runCancelledError)
+	}
 	// Export files
 	Logger.Info("Moving files to target directory.")
 	start := time.Now()
+	endExport := startPhase(context, "export")
 	err = RecycledExportProject(
-		profile, context.Config.Name, context.Config.DataPath, context.DotRegolithPath)
+		profile, context.Config.Name, context.Config.DataPath, context.DotRegolithPath,
+		context.Config.AdditionalPacks, context.Config.Symlinks)
+	endExport()
 	if err != nil {
 		err1 := ClearCachedStates() // Just to be safe clear cached states
 		if err1 != nil {
@@ -251,13 +352,24 @@ func RunProfile(context RunContext) error {
 	// Clear states to not conflict with recycled mode, error handling not
 	// important
 	ClearCachedStates()
+	applyReproducibleEnv()
+	err := recoverInterruptedExport(context.DotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
 start:
 	// Prepare tmp files
 	profile, err := context.GetProfile()
 	if err != nil {
 		return WrapErrorf(err, runContextGetProfileError)
 	}
+	err = context.prepareChangedFiles()
+	if err != nil {
+		return WrapError(err, "Failed to save the list of changed files.")
+	}
+	endSetup := startPhase(context, "setup")
 	err = SetupTmpFiles(*context.Config, profile, context.DotRegolithPath)
+	endSetup()
 	if err != nil {
 		return WrapErrorf(err, setupTmpFilesError, context.DotRegolithPath)
 	}
@@ -272,11 +384,23 @@ start:
 	if interrupted {
 		goto start
 	}
+	if context.SkipExport {
+		Logger.Infof(
+			"Skipping the export, the files are in %q.",
+			filepath.Join(context.DotRegolithPath, "tmp"))
+		return nil
+	}
+	if context.isCancelled() {
+		return WrappedError(runCancelledError)
+	}
 	// Export files
 	Logger.Info("Moving files to target directory.")
 	start := time.Now()
+	endExport := startPhase(context, "export")
 	err = ExportProject(
-		profile, context.Config.Name, context.Config.DataPath, context.DotRegolithPath)
+		profile, context.Config.Name, context.Config.DataPath, context.DotRegolithPath,
+		context.Config.AdditionalPacks, context.Config.Symlinks)
+	endExport()
 	if err != nil {
 		return WrapError(err, exportProjectError)
 	}
@@ -294,37 +418,152 @@ func WatchProfileImpl(context RunContext) (bool, error) {
 	if err != nil {
 		return false, WrapErrorf(err, runContextGetProfileError)
 	}
-	// Run the filters!
-	for filter := range profile.Filters {
-		filter := profile.Filters[filter]
-		// Disabled filters are skipped
-		if filter.IsDisabled() {
-			Logger.Infof("Filter \"%s\" is disabled, skipping.", filter.GetId())
-			continue
-		}
-		// Skip printing if the filter ID is empty (most likely a nested profile)
-		if filter.GetId() != "" {
-			Logger.Infof("Running filter %s", filter.GetId())
-		}
-		// Run the filter in watch mode
-		start := time.Now()
-		interrupted, err := filter.Run(context)
-		Logger.Debugf("Executed in %s", time.Since(start))
+	// The "--filter" flag runs only the chosen filters, one after another
+	if len(context.Filters) != 0 && context.Parent == nil {
+		filters, err := selectFilters(profile.Filters, context.Filters)
 		if err != nil {
-			err1 := ClearCachedStates() // Just to be safe clear cached states
-			if err1 != nil {
-				err = WrapError(err1, clearCachedStatesError)
+			return false, PassError(err)
+		}
+		for _, filter := range filters {
+			interrupted, err := runProfileFilter(filter, context)
+			if err != nil {
+				return false, PassError(err)
+			}
+			if interrupted {
+				return true, nil
 			}
-			return false, WrapErrorf(
-				err, filterRunnerRunError, filter.GetId())
+		}
+		return false, nil
+	}
+	// The watch mode skips the filters not affected by the changed files
+	start := 0
+	if context.resume != nil {
+		start, err = context.resume.resume(profile, context)
+		if err != nil {
+			return false, WrapError(
+				err, "Failed to restore the output of the unaffected filters.")
+		}
+	}
+	// Profiles that declare the dependencies between the filters or the
+	// files that they use can run them in parallel
+	if profile.runsInParallel() {
+		return runFiltersInParallel(profile, start, context)
+	}
+	// Run the filters!
+	for i := start; i < len(profile.Filters); i++ {
+		interrupted, err := runProfileFilter(profile.Filters[i], context)
+		if err != nil {
+			return false, PassError(err)
 		}
 		if interrupted {
 			return true, nil
 		}
+		if context.resume != nil {
+			context.resume.save(profile, i)
+		}
 	}
 	return false, nil
 }
 
+// selectFilters returns the filters with the given IDs, in the order of the
+// profile. It returns an error if any of the IDs doesn't match a filter.
+func selectFilters(filters []FilterRunner, ids []string) ([]FilterRunner, error) {
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[strings.TrimSpace(id)] = false
+	}
+	var result []FilterRunner
+	for _, filter := range filters {
+		if _, ok := selected[filter.GetId()]; ok && filter.GetId() != "" {
+			selected[filter.GetId()] = true
+			result = append(result, filter)
+		}
+	}
+	for id, found := range selected {
+		if !found {
+			return nil, WrappedErrorf(
+				"The profile doesn't have a filter with this ID.\n"+
+					"Filter: %s", id)
+		}
+	}
+	return result, nil
+}
+
+// runProfileFilter runs a single filter of a profile, unless it's disabled,
+// and returns true if the execution was interrupted.
+func runProfileFilter(filter FilterRunner, context RunContext) (bool, error) {
+	if context.isCancelled() {
+		return false, WrappedError(runCancelledError)
+	}
+	// Disabled filters are skipped
+	disabled, err := filter.IsDisabled(context)
+	if err != nil {
+		return false, WrapErrorf(
+			err, "Failed to check if the filter is disabled.\nFilter: %s",
+			filter.GetId())
+	}
+	if disabled {
+		Logger.Infof("Filter \"%s\" is disabled, skipping.", filter.GetId())
+		recordFilterResult(
+			context, filter.GetId(), "skipped", 0, "The filter is disabled.")
+		return false, nil
+	}
+	// Filters with declared inputs are skipped if there is nothing to do
+	workingDir := GetAbsoluteWorkingDirectory(context.DotRegolithPath)
+	if !hasInputFiles(filter, workingDir) {
+		Logger.Infof(
+			"None of the files matches the inputs of filter \"%s\", "+
+				"skipping.", filter.GetId())
+		recordFilterResult(
+			context, filter.GetId(), "skipped", 0,
+			"None of the files matches the inputs of the filter.")
+		return false, nil
+	}
+	// Skip printing if the filter ID is empty (most likely a nested profile)
+	if filter.GetId() != "" {
+		Logger.Infow(
+			fmt.Sprintf("Running filter %s", filter.GetId()),
+			logFields("filter", filter.GetId())...)
+	}
+	// Run the filter in watch mode. The nested profiles measure their own
+	// filters.
+	endFilter := func() {}
+	if filter.GetId() != "" {
+		endFilter = startPhase(context, "filter "+filter.GetId())
+	}
+	start := time.Now()
+	sandbox, err := startFilterSandbox(filter, context)
+	if err != nil {
+		endFilter()
+		return false, PassError(err)
+	}
+	var interrupted bool
+	if filter.UsesCache() {
+		interrupted, err = runFilterWithCache(filter, context)
+	} else {
+		interrupted, err = RunFilterWithPolicy(filter, context)
+	}
+	if err == nil && sandbox != nil {
+		err = sandbox.check()
+	}
+	endFilter()
+	Logger.Debugf("Executed in %s", time.Since(start))
+	if err != nil {
+		recordFilterResult(
+			context, filter.GetId(), "failed", time.Since(start),
+			PassError(err).Error())
+		err1 := ClearCachedStates() // Just to be safe clear cached states
+		if err1 != nil {
+			err = WrapError(err1, clearCachedStatesError)
+		}
+		return false, WrapErrorf(
+			err, filterRunnerRunError, filter.GetId())
+	}
+	recordFilterResult(
+		context, filter.GetId(), "passed", time.Since(start), "")
+	return interrupted, nil
+}
+
 // subfilterCollection returns a collection of filters from a
 // "filter.json" file of a remote filter.
 func (f *RemoteFilter) subfilterCollection(dotRegolithPath string) (*FilterCollection, error) {
@@ -344,7 +583,7 @@ func (f *RemoteFilter) subfilterCollection(dotRegolithPath string) (*FilterColle
 	}
 
 	var filterCollection map[string]interface{}
-	err = json.Unmarshal(file, &filterCollection)
+	err = unmarshalJsonc(file, &filterCollection)
 	if err != nil {
 		return nil, WrapErrorf(err, jsonUnmarshalError, path)
 	}
@@ -368,7 +607,15 @@ func (f *RemoteFilter) subfilterCollection(dotRegolithPath string) (*FilterColle
 		}
 		// Using the same JSON data to create both the filter
 		// definiton (installer) and the filter (runner)
-		filterId := fmt.Sprintf("%v:subfilter%v", f.Id, i)
+		filter, err := applyPlatformOverrides(filter)
+		if err != nil {
+			return nil, extraFilterJsonErrorInfo(
+				path, WrapErrorf(err, jsonPathParseError, jsonPath))
+		}
+		filterId, err := subfilterId(f.Id, i, filter)
+		if err != nil {
+			return nil, extraFilterJsonErrorInfo(path, PassError(err))
+		}
 		filterInstaller, err := FilterInstallerFromObject(filterId, filter)
 		if err != nil {
 			return nil, extraFilterJsonErrorInfo(
@@ -386,17 +633,8 @@ func (f *RemoteFilter) subfilterCollection(dotRegolithPath string) (*FilterColle
 			return nil, WrapErrorf(
 				err, createFilterRunnerError, filterName)
 		}
-		if _, ok := filterRunner.(*RemoteFilter); ok {
-			// TODO - we could possibly implement recursive filters here
-			return nil, WrappedErrorf(
-				"Regolith detected a reference to a remote filter inside "+
-					"another remote filter.\n"+
-					"This feature is not supported.\n"+
-					"Filter name: %s"+
-					"Filter configuration file: %s\n"+
-					"JSON path to remote filter reference: filters->%d",
-				f.Id, path, i)
-		}
+		// The nested remote filters run like the filters from config.json,
+		// they're installed by installFilters (see installNestedFilters)
 		filterRunner.CopyArguments(f)
 		result.Filters = append(result.Filters, filterRunner)
 	}
@@ -0,0 +1,197 @@
+package regolith
+
+import (
+	"sort"
+	"strings"
+)
+
+// resolveProfileInheritance replaces the profiles of the config.json file
+// (parsed to a map) that use the "extends" property with the result of
+// merging them into the profiles they extend (see mergeProfiles). The
+// profiles can extend the profiles that extend other profiles. The config
+// is modified in place.
+func resolveProfileInheritance(config map[string]interface{}) error {
+	// The missing properties and wrong types are reported by
+	// validateConfigSchema
+	regolith, ok := config["regolith"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	profiles, ok := regolith["profiles"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	resolved := make(map[string]map[string]interface{})
+	var resolve func(
+		name string, chain []string) (map[string]interface{}, error)
+	resolve = func(
+		name string, chain []string,
+	) (map[string]interface{}, error) {
+		if profile, ok := resolved[name]; ok {
+			return profile, nil
+		}
+		for _, other := range chain {
+			if other == name {
+				return nil, WrappedErrorf(
+					"The profiles extend each other in a cycle.\nCycle: %s",
+					strings.Join(append(chain, name), " -> "))
+			}
+		}
+		profile, ok := profiles[name].(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		parentObj, ok := profile["extends"]
+		if !ok {
+			resolved[name] = profile
+			return profile, nil
+		}
+		path := "regolith->profiles->" + name + "->extends"
+		parentName, ok := parentObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(jsonPathTypeError, path, "string")
+		}
+		if _, ok := profiles[parentName]; !ok {
+			return nil, WrappedErrorf(
+				"The profile extends a profile that doesn't exist.\n"+
+					"JSON Path: %s\nProfile: %s", path, parentName)
+		}
+		parent, err := resolve(parentName, append(chain, name))
+		if err != nil {
+			return nil, PassError(err)
+		}
+		if parent == nil {
+			return nil, nil
+		}
+		merged := mergeProfiles(parent, profile)
+		resolved[name] = merged
+		return merged, nil
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		profile, err := resolve(name, nil)
+		if err != nil {
+			return PassError(err)
+		}
+		if profile != nil {
+			profiles[name] = profile
+		}
+	}
+	return nil
+}
+
+// mergeProfiles returns the profile that extends the parent profile. The
+// properties of the profile override the properties of the parent, except:
+//   - "export" - the properties of the export target are merged,
+//   - "filters" - the filters are merged with mergeProfileFilters.
+func mergeProfiles(
+	parent, profile map[string]interface{},
+) map[string]interface{} {
+	result := make(map[string]interface{}, len(parent)+len(profile))
+	for key, value := range parent {
+		result[key] = value
+	}
+	for key, value := range profile {
+		switch key {
+		case "extends":
+			continue
+		case "export":
+			result[key] = mergeJsonObjects(parent[key], value)
+		case "filters":
+			result[key] = mergeProfileFilters(parent[key], value)
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// mergeProfileFilters merges the filters of a profile into the filters of
+// the profile it extends. A filter that is already used by the parent
+// profile overrides its properties (the n-th use of a filter overrides the
+// n-th use of the same filter in the parent). The other filters are added
+// at the end.
+func mergeProfileFilters(parent, filters interface{}) interface{} {
+	parentFilters, ok := parent.([]interface{})
+	if !ok {
+		return filters
+	}
+	childFilters, ok := filters.([]interface{})
+	if !ok {
+		return filters
+	}
+	result := make([]interface{}, len(parentFilters))
+	copy(result, parentFilters)
+	uses := make(map[string]int)
+	for _, filter := range childFilters {
+		key := profileFilterKey(filter)
+		index := -1
+		if key != "" {
+			index = nthProfileFilter(parentFilters, key, uses[key])
+			uses[key]++
+		}
+		if index == -1 {
+			result = append(result, filter)
+		} else {
+			result[index] = mergeJsonObjects(result[index], filter)
+		}
+	}
+	return result
+}
+
+// profileFilterKey returns the key that identifies the filter in the list of
+// the filters of a profile, or an empty string if the filter is invalid.
+func profileFilterKey(filter interface{}) string {
+	filterMap, ok := filter.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if profile, ok := filterMap["profile"].(string); ok {
+		return "profile:" + profile
+	}
+	if name, ok := filterMap["filter"].(string); ok {
+		return "filter:" + name
+	}
+	return ""
+}
+
+// nthProfileFilter returns the index of the n-th (counting from 0) filter
+// with the key (see profileFilterKey), or -1 if there is no such filter.
+func nthProfileFilter(filters []interface{}, key string, n int) int {
+	for i, filter := range filters {
+		if profileFilterKey(filter) != key {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+// mergeJsonObjects returns the properties of both objects, with the
+// properties of the override replacing the properties of the base. If any
+// of the values isn't an object, the override is returned.
+func mergeJsonObjects(base, override interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	overrideMap, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	result := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		result[key] = value
+	}
+	for key, value := range overrideMap {
+		result[key] = value
+	}
+	return result
+}
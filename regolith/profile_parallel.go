@@ -0,0 +1,201 @@
+package regolith
+
+import (
+	"runtime"
+	"strings"
+)
+
+// usesNeeds returns true if any of the filters of the profile declares its
+// dependencies with the "needs" property. Such profiles run their filters in
+// parallel, preserving the order only where it's declared.
+func (p *Profile) usesNeeds() bool {
+	for _, filter := range p.Filters {
+		if len(filter.GetNeeds()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runsInParallel returns true if the filters of the profile run in parallel,
+// because they declare their dependencies with the "needs" property or the
+// files that they use with the "inputs" and "outputs" properties.
+func (p *Profile) runsInParallel() bool {
+	return p.usesNeeds() || p.declaresFiles()
+}
+
+// filterDependencies returns the indices of the filters that must finish
+// before each of the filters runs, based on their "needs" properties and the
+// files that they use (see filesDependency). A filter waits for the earlier
+// filters that use the same files. The filters that don't declare their
+// files use all of them, unless the profile uses "needs", in which case they
+// wait only for the filters that they need. It returns an error if a filter
+// needs an unknown filter, an id used by multiple filters or if the
+// dependencies are circular.
+func filterDependencies(filters []FilterRunner) ([][]int, error) {
+	indices := make(map[string]int)
+	for i, filter := range filters {
+		id := filter.GetId()
+		if id == "" {
+			continue
+		}
+		if _, ok := indices[id]; ok {
+			indices[id] = -1 // Ambiguous
+			continue
+		}
+		indices[id] = i
+	}
+	dependencies := make([][]int, len(filters))
+	for i, filter := range filters {
+		for _, need := range filter.GetNeeds() {
+			j, ok := indices[need]
+			if !ok {
+				return nil, WrappedErrorf(
+					"Filter needs a filter that isn't a part of the "+
+						"profile.\nFilter: %s\nNeeds: %s",
+					filter.GetId(), need)
+			}
+			if j == -1 {
+				return nil, WrappedErrorf(
+					"Filter needs a filter that is used multiple times in "+
+						"the profile.\nFilter: %s\nNeeds: %s",
+					filter.GetId(), need)
+			}
+			dependencies[i] = append(dependencies[i], j)
+		}
+	}
+	usesNeeds := false
+	for _, filter := range filters {
+		usesNeeds = usesNeeds || len(filter.GetNeeds()) > 0
+	}
+	for i, filter := range filters {
+		needed := make(map[int]struct{}, len(dependencies[i]))
+		for _, j := range dependencies[i] {
+			needed[j] = struct{}{}
+		}
+		for j := 0; j < i; j++ {
+			if _, ok := needed[j]; ok {
+				continue
+			}
+			if usesNeeds && (!filterDeclaresFiles(filters[j]) ||
+				!filterDeclaresFiles(filter)) {
+				continue
+			}
+			if filesDependency(filters[j], filter) {
+				dependencies[i] = append(dependencies[i], j)
+			}
+		}
+	}
+	// Check for circular dependencies by sorting the filters topologically
+	finished := make([]bool, len(filters))
+	for progress := true; progress; {
+		progress = false
+		for i := range filters {
+			if !finished[i] && dependenciesFinished(dependencies[i], finished) {
+				finished[i] = true
+				progress = true
+			}
+		}
+	}
+	var circular []string
+	for i, filter := range filters {
+		if !finished[i] {
+			circular = append(circular, filter.GetId())
+		}
+	}
+	if len(circular) > 0 {
+		return nil, WrappedErrorf(
+			"Found circular dependency between the filters.\nFilters: %s",
+			strings.Join(circular, ", "))
+	}
+	return dependencies, nil
+}
+
+// dependenciesFinished returns true if all of the filters with the given
+// indices are finished.
+func dependenciesFinished(dependencies []int, finished []bool) bool {
+	for _, dependency := range dependencies {
+		if !finished[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+// runFiltersInParallel runs the filters of the profile, starting from the
+// one with the start index, using a pool of workers. Each filter starts as
+// soon as all of the filters that it needs are finished. If any of the
+// filters fails or is interrupted, no new filters are started and the
+// function waits for the running ones to finish. It returns true if the
+// execution was interrupted.
+func runFiltersInParallel(
+	profile Profile, start int, context RunContext,
+) (bool, error) {
+	filters := profile.Filters
+	dependencies, err := filterDependencies(filters)
+	if err != nil {
+		return false, PassError(err)
+	}
+	type filterResult struct {
+		index       int
+		interrupted bool
+		err         error
+	}
+	workers := runtime.NumCPU()
+	results := make(chan filterResult)
+	started := make([]bool, len(filters))
+	finished := make([]bool, len(filters))
+	for i := 0; i < start; i++ {
+		started[i] = true
+		finished[i] = true
+	}
+	running := 0
+	interrupted := false
+	var runErr error
+	for {
+		for i := 0; i < len(filters) && running < workers; i++ {
+			if runErr != nil || interrupted {
+				break
+			}
+			if started[i] || !dependenciesFinished(dependencies[i], finished) {
+				continue
+			}
+			started[i] = true
+			running++
+			go func(i int) {
+				interrupted, err := runProfileFilter(filters[i], context)
+				results <- filterResult{i, interrupted, err}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+		result := <-results
+		running--
+		finished[result.index] = true
+		if result.err != nil && runErr == nil {
+			runErr = result.err
+		}
+		interrupted = interrupted || result.interrupted
+		// The watch mode saves a checkpoint when nothing runs and only the
+		// filters at the beginning of the profile are finished
+		if context.resume != nil && running == 0 && runErr == nil &&
+			!interrupted {
+			prefix := 0
+			for prefix < len(filters) && finished[prefix] {
+				prefix++
+			}
+			onlyPrefix := true
+			for i := prefix; i < len(filters); i++ {
+				onlyPrefix = onlyPrefix && !started[i]
+			}
+			if prefix > start && onlyPrefix {
+				context.resume.save(profile, prefix-1)
+			}
+		}
+	}
+	if runErr != nil {
+		return false, PassError(runErr)
+	}
+	return interrupted, nil
+}
@@ -0,0 +1,75 @@
+package regolith
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// projectLockPath is the path to the file locked by the Regolith process
+// that runs a profile or installs the filters of the project, relative to the
+// .regolith directory.
+const projectLockPath = "project.lock"
+
+// errProjectLocked is returned by lockProjectFile when the file is already
+// locked by another process.
+var errProjectLocked = errors.New("the file is locked by another process")
+
+// Error used when the project is locked by another Regolith process
+const projectLockedError = "Another Regolith process is using this project " +
+	"(process ID: %s).\n" +
+	"Wait until it finishes, or stop it before running this command again.\n" +
+	"Lock file: %s"
+
+// projectLock is an advisory lock of the .regolith directory, which prevents
+// multiple Regolith processes from using the tmp directory and the caches of
+// the same project at the same time.
+type projectLock struct {
+	file *os.File
+}
+
+// acquireProjectLock locks the .regolith directory. It fails immediately if
+// another Regolith process holds the lock. The lock is released with the
+// Release method or when the process exits.
+func acquireProjectLock(dotRegolithPath string) (*projectLock, error) {
+	err := os.MkdirAll(dotRegolithPath, 0755)
+	if err != nil {
+		return nil, WrapErrorf(err, osMkdirError, dotRegolithPath)
+	}
+	path := filepath.Join(dotRegolithPath, projectLockPath)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, WrapErrorf(err, osOpenError, path)
+	}
+	err = lockProjectFile(file)
+	if err == errProjectLocked {
+		file.Close()
+		// The lock file contains the ID of the process that holds the lock
+		pid := "unknown"
+		if data, err := os.ReadFile(path); err == nil &&
+			strings.TrimSpace(string(data)) != "" {
+			pid = strings.TrimSpace(string(data))
+		}
+		return nil, WrappedErrorf(projectLockedError, pid, path)
+	}
+	if err != nil {
+		file.Close()
+		return nil, WrapErrorf(err, "Failed to lock the project.\nPath: %s", path)
+	}
+	// The ID of the process is only informative, so the lock doesn't fail if
+	// it can't be saved
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &projectLock{file: file}, nil
+}
+
+// Release releases the lock. The lock file isn't removed, because another
+// process may already be waiting for it.
+func (l *projectLock) Release() {
+	l.file.Truncate(0)
+	unlockProjectFile(l.file)
+	l.file.Close()
+}
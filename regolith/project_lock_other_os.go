@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package regolith
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockProjectFile takes an exclusive flock lock of the file without waiting.
+// It returns errProjectLocked if another process holds the lock.
+func lockProjectFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errProjectLocked
+	}
+	return err
+}
+
+// unlockProjectFile releases the lock taken by lockProjectFile.
+func unlockProjectFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
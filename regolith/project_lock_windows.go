@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package regolith
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// projectLockOffsetHigh is the high part of the offset of the locked byte of the lock file. The
+// locked range is past the end of the file, so other processes can still read
+// the ID of the process that holds the lock.
+const projectLockOffsetHigh = 1
+
+// lockProjectFile takes an exclusive lock of the file with LockFileEx,
+// without waiting. It returns errProjectLocked if another process holds the
+// lock.
+func lockProjectFile(file *os.File) error {
+	err := windows.LockFileEx(
+		windows.Handle(file.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, &windows.Overlapped{OffsetHigh: projectLockOffsetHigh})
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errProjectLocked
+	}
+	return err
+}
+
+// unlockProjectFile releases the lock taken by lockProjectFile.
+func unlockProjectFile(file *os.File) error {
+	return windows.UnlockFileEx(
+		windows.Handle(file.Fd()), 0, 1, 0,
+		&windows.Overlapped{OffsetHigh: projectLockOffsetHigh})
+}
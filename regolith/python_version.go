@@ -0,0 +1,265 @@
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// pythonInterpretersPath is the path to the file with the Python interpreters
+// selected for the filters with the "pythonVersion" property, relative to the
+// .regolith directory. The file maps the IDs of the filters to the paths of
+// the interpreters.
+const pythonInterpretersPath = "cache/python-interpreters.json"
+
+// pythonInterpretersMutex protects the file with the selected interpreters,
+// when the filters are installed in parallel.
+var pythonInterpretersMutex sync.Mutex
+
+// pythonExecutableName matches the names of the Python executables found in
+// the directories from the PATH.
+var pythonExecutableName = regexp.MustCompile(`^python(3(\.\d+)?)?(\.exe)?$`)
+
+// pythonVersions caches the versions of the interpreters (see
+// pythonVersion), the keys are the commands. The empty version means that
+// the command isn't a working interpreter.
+var pythonVersions = make(map[string]string)
+var pythonVersionsMutex sync.Mutex
+
+// pythonVersionConstraint is a single condition of a pythonVersionRange,
+// like ">=3.8". The version uses the "v" prefix required by the semver
+// package.
+type pythonVersionConstraint struct {
+	operator string
+	version  string
+}
+
+// pythonVersionRange is a range of the Python versions required by a filter.
+type pythonVersionRange []pythonVersionConstraint
+
+// parsePythonVersionRange parses the "pythonVersion" property of a Python
+// filter. The range is a list of conditions separated by commas, like
+// ">=3.8,<3.12", using the ">=", ">", "<=", "<" and "==" operators. The "=="
+// operator matches all versions with the same prefix ("==3.11" matches
+// "3.11.4"). The npm-like ranges used by the versions of the filters (like
+// "^3.10" or "~3.11", see parseVersionRange) are also accepted.
+func parsePythonVersionRange(spec string) (pythonVersionRange, error) {
+	if isVersionRange(spec) {
+		versions, err := parseVersionRange(spec)
+		if err != nil {
+			return nil, PassError(err)
+		}
+		return pythonVersionRange{
+			{operator: ">=", version: versions.min},
+			{operator: "<", version: versions.max},
+		}, nil
+	}
+	var result pythonVersionRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		operator := ""
+		for _, op := range []string{">=", "<=", "==", ">", "<"} {
+			if strings.HasPrefix(part, op) {
+				operator = op
+				break
+			}
+		}
+		version := "v" + strings.TrimSpace(strings.TrimPrefix(part, operator))
+		if operator == "" || !semver.IsValid(version) {
+			return nil, WrappedErrorf(
+				"Invalid Python version range.\nRange: %s\n"+
+					"The range must be a list of conditions separated by "+
+					"commas, for example \">=3.8,<3.12\", or a range like "+
+					"\"^3.10\".", spec)
+		}
+		result = append(result, pythonVersionConstraint{
+			operator: operator, version: version})
+	}
+	return result, nil
+}
+
+// Contains returns true if the Python version (without the "v" prefix) is
+// in the range.
+func (r pythonVersionRange) Contains(version string) bool {
+	v := "v" + version
+	if !semver.IsValid(v) {
+		return false
+	}
+	for _, constraint := range r {
+		compared := semver.Compare(v, constraint.version)
+		var ok bool
+		switch constraint.operator {
+		case ">=":
+			ok = compared >= 0
+		case ">":
+			ok = compared > 0
+		case "<=":
+			ok = compared <= 0
+		case "<":
+			ok = compared < 0
+		case "==":
+			ok = v == constraint.version ||
+				strings.HasPrefix(v, constraint.version+".")
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pythonVersion returns the version of the Python interpreter, or an empty
+// string if the command doesn't run a Python interpreter.
+func pythonVersion(command string) string {
+	pythonVersionsMutex.Lock()
+	defer pythonVersionsMutex.Unlock()
+	if version, ok := pythonVersions[command]; ok {
+		return version
+	}
+	output, err := exec.Command(
+		command, "-c",
+		"import sys; print('%d.%d.%d' % sys.version_info[:3])").Output()
+	version := ""
+	if err == nil {
+		version = strings.TrimSpace(string(output))
+	}
+	pythonVersions[command] = version
+	return version
+}
+
+// pythonCandidates returns the Python interpreters installed on the
+// computer: the ones listed by the "py" launcher on Windows, the versions
+// installed with pyenv and the Python executables from the PATH.
+func pythonCandidates() []string {
+	var result []string
+	seen := make(map[string]struct{})
+	add := func(command string) {
+		if _, ok := seen[command]; !ok {
+			seen[command] = struct{}{}
+			result = append(result, command)
+		}
+	}
+	// The py launcher lists the paths of the interpreters, one per line,
+	// like " -V:3.11 *        C:\Python311\python.exe"
+	if output, err := exec.Command("py", "-0p").Output(); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			if i := strings.Index(line, ":\\"); i > 0 {
+				add(strings.TrimSpace(line[i-1:]))
+			}
+		}
+	}
+	// The versions installed with pyenv (or pyenv-win)
+	if output, err := exec.Command("pyenv", "root").Output(); err == nil {
+		versions := filepath.Join(strings.TrimSpace(string(output)), "versions")
+		for _, pattern := range []string{"*/bin/python", "*/python.exe"} {
+			matches, _ := filepath.Glob(filepath.Join(versions, pattern))
+			for _, match := range matches {
+				add(match)
+			}
+		}
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if pythonExecutableName.MatchString(strings.ToLower(entry.Name())) {
+				add(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return result
+}
+
+// findMatchingPython returns the newest Python interpreter installed on the
+// computer, whose version is in the range (see parsePythonVersionRange). The
+// error lists the found interpreters.
+func findMatchingPython(spec, filterId string) (string, error) {
+	versions, err := parsePythonVersionRange(spec)
+	if err != nil {
+		return "", PassError(err)
+	}
+	best, bestVersion := "", ""
+	var found []string
+	for _, candidate := range pythonCandidates() {
+		version := pythonVersion(candidate)
+		if version == "" {
+			continue
+		}
+		found = append(found, fmt.Sprintf("  %s (%s)", version, candidate))
+		if !versions.Contains(version) {
+			continue
+		}
+		if best == "" || semver.Compare("v"+version, "v"+bestVersion) > 0 {
+			best, bestVersion = candidate, version
+		}
+	}
+	if best != "" {
+		Logger.Debugf(
+			"Using Python %s (%s) for the %q filter.", bestVersion, best,
+			filterId)
+		return best, nil
+	}
+	sort.Strings(found)
+	foundText := "  none"
+	if len(found) > 0 {
+		foundText = strings.Join(found, "\n")
+	}
+	return "", WrappedErrorf(
+		"No Python interpreter matches the version required by the filter.\n"+
+			"Filter: %s\nRequired version: %s\nFound interpreters:\n%s\n"+
+			"Install a matching version of Python from "+
+			"https://www.python.org/downloads/ or with pyenv, then install the "+
+			"filter again.",
+		filterId, spec, foundText)
+}
+
+// loadPythonInterpreters loads the interpreters selected for the filters.
+func loadPythonInterpreters(dotRegolithPath string) (map[string]string, error) {
+	result := make(map[string]string)
+	path := filepath.Join(dotRegolithPath, pythonInterpretersPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, fileReadError, path)
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	return result, nil
+}
+
+// recordPythonInterpreter saves the interpreter selected for the filter, so
+// the filter runs with the same interpreter that installed it.
+func recordPythonInterpreter(dotRegolithPath, filterId, command string) error {
+	pythonInterpretersMutex.Lock()
+	defer pythonInterpretersMutex.Unlock()
+	interpreters, err := loadPythonInterpreters(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	interpreters[filterId] = command
+	path := filepath.Join(dotRegolithPath, pythonInterpretersPath)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(path))
+	}
+	data, _ := json.MarshalIndent(interpreters, "", "\t") // no error
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	return nil
+}
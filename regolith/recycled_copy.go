@@ -5,43 +5,51 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"hash"
-	"hash/crc32"
 	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 const defaultHashPairsPath = ".regolith/cache/dir_hash_pairs.json"
 
 // PathHashPair is a single entry in the list that represents the state of the
 // file path. It contains the path and the hash of the file/directory.
+//
+// The states created for the recycled mode also contain the size and the
+// modification time (in nanoseconds) of the file, which let the next state of
+// the same path reuse the hash if the file didn't change (see
+// getRecycledState). The ModTime is 0 if the hash can't be reused.
 type PathHashPair struct {
-	Path string `json:"path"`
-	Hash string `json:"hash"`
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
+	Size    int64  `json:"size,omitempty"`
+	ModTime int64  `json:"modTime,omitempty"`
 }
 
 // RecycledMoveOrCopySettings is a structure that defines the settings of the
 // FullRecycledMoveOrCopy function.
 type RecycledMoveOrCopySettings struct {
-	sourceState             *list.List // Preloaded file hashes of source path
-	targetState             *list.List // Preloaded target hashes of target path
-	hashPairsPath           string     // Path to the file that contains cached hashes
-	canMove                 bool       // Whether the files can be moved out of source
-	reloadSourceHashes      bool       // Whether the source hashes should be reloaded from file system instead of using cache
-	reloadTargetHashes      bool       // Whether the target hashes should be reloaded from file system instead of using cache
-	saveSourceHashes        bool       // Whether the source hashes should be saved in the cache
-	saveTargetHashes        bool       // Whether the target hashes should be saved in the cache
-	hash                    hash.Hash  // Hash object for getting file hash values
-	makeTargetReadOnly      bool       // Whether the target files should be made read-only
-	copyTargetAclFromParent bool       // Whether the target should copy the security info from it's parent
+	sourceState        *list.List       // Preloaded file hashes of source path
+	targetState        *list.List       // Preloaded target hashes of target path
+	hashPairsPath      string           // Path to the file that contains cached hashes
+	canMove            bool             // Whether the files can be moved out of source
+	reloadSourceHashes bool             // Whether the source hashes should be reloaded from file system instead of using cache
+	reloadTargetHashes bool             // Whether the target hashes should be reloaded from file system instead of using cache
+	saveSourceHashes   bool             // Whether the source hashes should be saved in the cache
+	saveTargetHashes   bool             // Whether the target hashes should be saved in the cache
+	newHash            func() hash.Hash // Creates the hash objects for getting file hash values
+	makeTargetReadOnly bool             // Whether the target files should be made read-only
+	targetAcl          string           // How the permissions of the target are set (see applyExportAcl), not set if empty
 }
 
 func (s *RecycledMoveOrCopySettings) loadDefaults() {
-	if s.hash == nil {
-		s.hash = crc32.NewIEEE()
+	if s.newHash == nil {
+		s.newHash = NewXxh64
 	}
 	if s.hashPairsPath == "" {
 		s.hashPairsPath = defaultHashPairsPath
@@ -66,12 +74,15 @@ func FullRecycledMoveOrCopy(
 	}
 	// Load source state
 	if settings.sourceState == nil {
+		// The cached state is also used for skipping the hashing of the
+		// unchanged files, when the state is reloaded
+		cachedState, _ := LoadStateFromCache(settings.hashPairsPath, sourcePath)
 		if !settings.reloadSourceHashes {
-			settings.sourceState, _ = LoadStateFromCache(
-				settings.hashPairsPath, sourcePath)
+			settings.sourceState = cachedState
 		}
 		if settings.sourceState == nil {
-			settings.sourceState, err = GetStateFromPath(sourcePath, settings.hash)
+			settings.sourceState, err = getRecycledState(
+				sourcePath, settings.newHash, cachedState)
 			if err != nil {
 				return WrapErrorf(
 					err, "Failed to load the state of the path %s",
@@ -81,12 +92,13 @@ func FullRecycledMoveOrCopy(
 	}
 	// Load target state
 	if settings.targetState == nil {
+		cachedState, _ := LoadStateFromCache(settings.hashPairsPath, targetPath)
 		if !settings.reloadTargetHashes {
-			settings.targetState, _ = LoadStateFromCache(
-				settings.hashPairsPath, targetPath)
+			settings.targetState = cachedState
 		}
 		if settings.targetState == nil {
-			settings.targetState, err = GetStateFromPath(targetPath, settings.hash)
+			settings.targetState, err = getRecycledState(
+				targetPath, settings.newHash, cachedState)
 			if err != nil {
 				return WrapErrorf(
 					err, "Failed to load the state of the path %s",
@@ -119,13 +131,12 @@ func FullRecycledMoveOrCopy(
 		}
 	}
 	// Set the ACL of the target
-	if settings.copyTargetAclFromParent {
-		parent := filepath.Dir(targetPath)
-		err = copyFileSecurityInfo(parent, targetPath)
+	if settings.targetAcl != "" {
+		err = applyExportAcl(settings.targetAcl, sourcePath, targetPath)
 		if err != nil {
 			Logger.Warnf(
-				"Failed to copy the security info from %s to %s.",
-				parent, targetPath)
+				"Failed to set the permissions of %s.\n%s",
+				targetPath, PassError(err).Error())
 		}
 	}
 	// Set the read-only flag of the target
@@ -183,7 +194,9 @@ func FullRecycledMoveOrCopy(
 //        - END - end of the list
 // It also handles the situations where "mv" fails to move and copies file
 // instead or when S or T is a directory and the function needs to handle the
-// removed children (but this is not described in the pseudocode)
+// removed children (but this is not described in the pseudocode). The files
+// are copied in parallel (see copyPool), after the algorithm creates their
+// directories.
 func RecycledMoveOrCopy(
 	sourcePath, targetPath string,
 	sourceState, targetState *list.List,
@@ -199,6 +212,31 @@ func RecycledMoveOrCopy(
 	deletedFiles := 0
 	skippedFiles := 0
 
+	pool := newCopyPool()
+	err := recycledMoveOrCopy(
+		sourcePath, targetPath, sourceState, targetState, canMove, pool,
+		&movedFiles, &copiedFiles, &deletedFiles, &skippedFiles)
+	if poolErr := pool.Wait(); err == nil {
+		err = poolErr
+	}
+	if err != nil {
+		return PassError(err)
+	}
+	Logger.Debugf(
+		"Target: %s; Moved %d; Copied %d; Deleted %d; Skipped (already in target) %d;",
+		targetPath, movedFiles, copiedFiles, deletedFiles, skippedFiles)
+	return nil
+}
+
+// recycledMoveOrCopy implements the algorithm of the RecycledMoveOrCopy. The
+// files that can't be moved are copied by the pool. The counters of the
+// files are updated for the debug messages.
+func recycledMoveOrCopy(
+	sourcePath, targetPath string,
+	sourceState, targetState *list.List,
+	canMove bool, pool *copyPool,
+	movedFiles, copiedFiles, deletedFiles, skippedFiles *int,
+) error {
 	s := sourceState.Front()
 	t := targetState.Front()
 	for s != nil || t != nil {
@@ -207,7 +245,7 @@ func RecycledMoveOrCopy(
 			// target. Copy file from source to the target.
 			fullSPath := filepath.Join(sourcePath, s.Value.(PathHashPair).Path)
 			fullTPath := filepath.Join(targetPath, s.Value.(PathHashPair).Path)
-			moved, err := shallowMoveOrCopy(fullSPath, fullTPath, canMove)
+			moved, err := shallowMoveOrCopy(fullSPath, fullTPath, canMove, pool)
 			if err != nil {
 				return WrapErrorf(
 					err, "Failed to copy \"%s\" to \"%s\".", fullSPath,
@@ -218,7 +256,7 @@ func RecycledMoveOrCopy(
 			addPathToState(targetState, t, s.Value.(PathHashPair))
 			// Remove s from sourceState if necessary and advance 's'
 			if moved {
-				movedFiles++
+				*movedFiles++
 				s, err = removePathFromState(sourceState, s)
 				if err != nil {
 					return WrapErrorf(
@@ -226,7 +264,7 @@ func RecycledMoveOrCopy(
 						fullSPath)
 				}
 			} else { // copied
-				copiedFiles++
+				*copiedFiles++
 				s = s.Next()
 			}
 		} else if s == nil || (t != nil && 1 == compareFilePaths(s.Value.(PathHashPair).Path, t.Value.(PathHashPair).Path)) { // S > T
@@ -234,12 +272,13 @@ func RecycledMoveOrCopy(
 			// doesn't exist in the source so we need to delete it.
 			fullTPath := filepath.Join(targetPath, t.Value.(PathHashPair).Path)
 			// Remove the file
+			traceFilef("Removing %s", fullTPath)
 			err := os.RemoveAll(fullTPath)
 			if err != nil {
 				return WrapErrorf(
 					err, "Failed to remove \"%s\".", fullTPath)
 			}
-			deletedFiles++
+			*deletedFiles++
 			// Remove the element from targetState and advance 't'
 			t, err = removePathFromState(targetState, t)
 			if err != nil {
@@ -255,13 +294,13 @@ func RecycledMoveOrCopy(
 			sHash := s.Value.(PathHashPair).Hash
 			tHash := t.Value.(PathHashPair).Hash
 			if sHash == tHash { // Nothing to do, advance 's' and 't'
-				skippedFiles++
+				*skippedFiles++
 				s = s.Next()
 				t = t.Next()
 			} else {
 				// Copy the file from source to the target overwriting the
 				// the target file.
-				moved, err := shallowMoveOrCopy(fullSPath, fullTPath, canMove)
+				moved, err := shallowMoveOrCopy(fullSPath, fullTPath, canMove, pool)
 				if err != nil {
 					return WrapErrorf(
 						err, "Failed to copy \"%s\" to \"%s\".", fullSPath,
@@ -271,7 +310,7 @@ func RecycledMoveOrCopy(
 				t.Value = s.Value
 				if moved {
 					// Remove from source if necesary and advance 's' and 't'
-					movedFiles++
+					*movedFiles++
 					s, err = removePathFromState(sourceState, s)
 					if err != nil {
 						return WrapErrorf(
@@ -279,16 +318,13 @@ func RecycledMoveOrCopy(
 							fullSPath)
 					}
 				} else {
-					copiedFiles++
+					*copiedFiles++
 					s = s.Next()
 				}
 				t = t.Next()
 			}
 		}
 	}
-	Logger.Debugf(
-		"Target: %s; Moved %d; Copied %d; Deleted %d; Skipped (already in target) %d;",
-		targetPath, movedFiles, copiedFiles, deletedFiles, skippedFiles)
 	return nil
 }
 
@@ -364,7 +400,7 @@ func GetStateFromPath(dirPath string, hash hash.Hash) (*list.List, error) {
 			if err != nil {
 				return WrapErrorf(err, "Failed to get hash for \"%s\".", path)
 			}
-			result.PushBack(PathHashPair{relPath, hashStr})
+			result.PushBack(PathHashPair{Path: relPath, Hash: hashStr})
 			return nil
 		})
 	if err != nil {
@@ -373,6 +409,98 @@ func GetStateFromPath(dirPath string, hash hash.Hash) (*list.List, error) {
 	return result, nil
 }
 
+// racyModTimeWindow is the time after the modification of a file during which
+// its hash isn't reused by getRecycledState. The file systems store the
+// modification times with a limited precision, so a file changed again right
+// after it was hashed could keep the same modification time.
+const racyModTimeWindow = 2 * time.Second
+
+// getRecycledState returns a state for the file path, like GetStateFromPath,
+// but it calculates the hashes of the files in parallel, using a new hash
+// object from newHash for every file. The hashes of the files whose size and
+// modification time are the same as in the previous state of the path are
+// reused without reading the files. The previous state can be nil.
+func getRecycledState(
+	dirPath string, newHash func() hash.Hash, previous *list.List,
+) (*list.List, error) {
+	if stats, err := os.Stat(dirPath); err != nil {
+		return nil, WrapErrorf(err, "Failed to stat \"%s\".", dirPath)
+	} else if !stats.IsDir() {
+		return nil, WrapErrorf(
+			err, "\"%s\" is not a directory.", dirPath)
+	}
+	previousPairs := make(map[string]PathHashPair)
+	if previous != nil {
+		for e := previous.Front(); e != nil; e = e.Next() {
+			pair := e.Value.(PathHashPair)
+			if pair.ModTime != 0 {
+				previousPairs[pair.Path] = pair
+			}
+		}
+	}
+	// The hashes calculated by the pool are added to the pairs by their
+	// indices after the pool finishes, so the order of the pairs is preserved
+	var pairs []PathHashPair
+	hashes := make(map[int]string)
+	var hashesMutex sync.Mutex
+	racyTime := time.Now().Add(-racyModTimeWindow).UnixNano()
+	pool := newCopyPool()
+	err := filepath.WalkDir(
+		dirPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return WrapErrorf(err, osWalkError, path)
+			}
+			if path == dirPath {
+				return nil // skip the root directory
+			}
+			relPath, err := filepath.Rel(dirPath, path) // shouldn't error
+			if err != nil {
+				return WrapErrorf(err, "Failed to walk \"%s\".", path)
+			}
+			stat, err := os.Stat(path)
+			if err != nil {
+				return WrapErrorf(err, "Failed to stat \"%s\".", path)
+			}
+			pairs = append(pairs, PathHashPair{Path: relPath})
+			if stat.IsDir() {
+				return nil
+			}
+			pair := &pairs[len(pairs)-1]
+			pair.Size = stat.Size()
+			if modTime := stat.ModTime().UnixNano(); modTime < racyTime {
+				pair.ModTime = modTime
+			}
+			if old, ok := previousPairs[relPath]; ok &&
+				old.Size == pair.Size && old.ModTime == pair.ModTime {
+				pair.Hash = old.Hash
+				return nil
+			}
+			i := len(pairs) - 1
+			pool.Go(func() error {
+				hashStr, err := getPathHash(path, newHash())
+				if err != nil {
+					return WrapErrorf(
+						err, "Failed to get hash for \"%s\".", path)
+				}
+				hashesMutex.Lock()
+				hashes[i] = hashStr
+				hashesMutex.Unlock()
+				return nil
+			})
+			return nil
+		})
+	if poolErr := pool.Wait(); err == nil {
+		err = poolErr
+	}
+	if err != nil {
+		return nil, WrapErrorf(err, "Failed to walk \"%s\".", dirPath)
+	}
+	for i, hashStr := range hashes {
+		pairs[i].Hash = hashStr
+	}
+	return patHashPairSliceToState(pairs), nil
+}
+
 // SavePathState appends new entry to the cache file of the RecycledMoveOrCopy.
 func SavePathState(cacheFilePath, path string, pairs *list.List) error {
 	file, err := ioutil.ReadFile(cacheFilePath)
@@ -410,13 +538,14 @@ func SavePathState(cacheFilePath, path string, pairs *list.List) error {
 }
 
 // SaveStateInDefaultCache saves a state of a path in the default cache file
-// using the default hash function. If targetPath doesn't exist, it creates
-// it before getting the state.
+// using the default hash function (xxHash). If targetPath doesn't exist, it
+// creates it before getting the state.
 func SaveStateInDefaultCache(path string) error {
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return WrapErrorf(err, "Failed to create directory \"%s\".", path)
 	}
-	state, err := GetStateFromPath(path, crc32.NewIEEE())
+	cachedState, _ := LoadStateFromCache(defaultHashPairsPath, path)
+	state, err := getRecycledState(path, NewXxh64, cachedState)
 	if err != nil {
 		return WrapErrorf(err, "Failed to get state for \"%s\".", path)
 	}
@@ -460,8 +589,11 @@ func DeepCopyAndGetState(
 // moved and false if it was copied.
 // If source is a directory the function will create an empty directory at
 // target (the copy is shallow so the contents of the source directory don't
-// matter).
-func shallowMoveOrCopy(source, target string, canMove bool) (bool, error) {
+// matter). The files are copied by the pool, so the copy may not be finished
+// when the function returns (see copyPool.Wait).
+func shallowMoveOrCopy(
+	source, target string, canMove bool, pool *copyPool,
+) (bool, error) {
 	// Check if source is a directory
 	isDir, err := isDirectory(source)
 	if err != nil {
@@ -490,18 +622,32 @@ func shallowMoveOrCopy(source, target string, canMove bool) (bool, error) {
 	if canMove {
 		err := os.MkdirAll(filepath.Dir(target), 0755)
 		if err == nil {
+			traceFilef("Moving %s to %s", source, target)
 			err = os.Rename(source, target)
 			if err == nil {
+				countFileIO(1, 0)
 				return true, nil
 			}
 		}
 	}
 	// Move failed or not allowed, copy the file
-	err = CopyFile(source, target)
-	if err != nil {
-		return false, WrapErrorf(
-			err, "Failed to copy \"%s\" to \"%s\".", source, target)
-	}
+	pool.Go(func() error {
+		stat, err := os.Stat(source)
+		if err != nil {
+			return WrapErrorf(err, "Failed to stat \"%s\".", source)
+		}
+		err = CopyFile(source, target)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to copy \"%s\" to \"%s\".", source, target)
+		}
+		// The copy keeps the modification time, so the size and the
+		// modification time from the state of the source are also valid
+		// for the target (see getRecycledState). If it fails, the file is
+		// hashed again.
+		os.Chtimes(target, stat.ModTime(), stat.ModTime())
+		return nil
+	})
 	return false, nil
 }
 
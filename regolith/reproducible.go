@@ -0,0 +1,118 @@
+package regolith
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Reproducible enables the reproducible builds ("--reproducible" flag), so
+// two runs of the same project export byte-identical files with the same
+// modification times, which can be compared with checksums.
+//
+// Regolith always walks the directories in the lexical order and writes the
+// JSON objects with sorted keys, so in the reproducible mode it only has to
+// replace the modification times of the exported files and of the entries of
+// the archives with a fixed time (see reproducibleTime), and pass that time
+// to the filters with the SOURCE_DATE_EPOCH environment variable.
+var Reproducible = false
+
+// reproducibleEpoch is the fixed time used in the reproducible mode when the
+// SOURCE_DATE_EPOCH environment variable isn't set. It's the earliest time
+// that the zip archives can store.
+var reproducibleEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// reproducibleEnvSet is true if the SOURCE_DATE_EPOCH environment variable
+// was set by applyReproducibleEnv, so it can be removed when the daemon (see
+// Serve) runs a profile without the reproducible mode.
+var reproducibleEnvSet = false
+
+// reproducibleTime returns the modification time of the exported files in
+// the reproducible mode. It's the time from the SOURCE_DATE_EPOCH
+// environment variable (the Unix timestamp used by the reproducible builds
+// of other tools), or the reproducibleEpoch.
+func reproducibleTime() time.Time {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return reproducibleEpoch
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		Logger.Warnf(
+			"Invalid value of the SOURCE_DATE_EPOCH environment variable, "+
+				"using %s instead.\nValue: %s",
+			reproducibleEpoch.Format(time.RFC3339), epoch)
+		return reproducibleEpoch
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// applyReproducibleEnv sets the SOURCE_DATE_EPOCH environment variable of
+// the filters in the reproducible mode, unless the user set it already. The
+// filters should use it instead of the current time for the dates that end
+// up in the exported files.
+func applyReproducibleEnv() {
+	if !Reproducible {
+		if reproducibleEnvSet {
+			os.Unsetenv("SOURCE_DATE_EPOCH")
+			reproducibleEnvSet = false
+		}
+		return
+	}
+	if _, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		return
+	}
+	os.Setenv(
+		"SOURCE_DATE_EPOCH", strconv.FormatInt(reproducibleEpoch.Unix(), 10))
+	reproducibleEnvSet = true
+}
+
+// setReproducibleTimes sets the modification times of the files and the
+// directories at the path to the reproducibleTime. The symbolic links are
+// skipped, because changing their times would change the times of their
+// targets.
+func setReproducibleTimes(path string) error {
+	modTime := reproducibleTime()
+	err := filepath.WalkDir(
+		path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == path {
+					return nil
+				}
+				return err
+			}
+			if d.Type()&os.ModeSymlink != 0 {
+				return nil
+			}
+			return os.Chtimes(p, modTime, modTime)
+		})
+	if err != nil {
+		return WrapErrorf(err, osWalkError, path)
+	}
+	return nil
+}
+
+// setExportReproducibleTimes sets the modification times of the exported
+// packs in the reproducible mode (see setReproducibleTimes). The export
+// still succeeds if they can't be set, but it's not reproducible.
+func setExportReproducibleTimes(
+	bpPath, rpPath string, packs []additionalPack,
+) {
+	if !Reproducible {
+		return
+	}
+	paths := []string{bpPath, rpPath}
+	for _, pack := range packs {
+		paths = append(paths, pack.ExportPath(bpPath, rpPath))
+	}
+	for _, path := range paths {
+		if err := setReproducibleTimes(path); err != nil {
+			Logger.Warnf(
+				"Failed to set the modification times of the exported "+
+					"files, the export isn't reproducible.\n%s",
+				PassError(err).Error())
+		}
+	}
+}
@@ -1,24 +1,36 @@
 package regolith
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/go-getter"
-	"muzzammil.xyz/jsonc"
 )
 
 const (
 	// regolithConfigPath is a path to the regolith config relative to
 	// UserCacheDir()
 	regolithConfigPath = "regolith"
-	// resolverUrl is an URL to the resolver.json file
+	// resolverUrl is an URL to the resolver.json file. It's the default
+	// filter registry, used when the user config doesn't list any registries.
 	resolverUrl = "https://raw.githubusercontent.com/Bedrock-OSS/regolith-filter-resolver/main/resolver.json"
+	// registriesPath is a path to the downloaded indices of the filter
+	// registries relative to the GetRegolithConfigPath() directory
+	registriesPath = "registries"
 )
 
+// ResolverMap is an entry of a filter in the index of a filter registry.
+// Only the URL is required.
 type ResolverMap struct {
-	Url string `json:"url"`
+	Url         string   `json:"url"`
+	Description string   `json:"description,omitempty"`
+	Versions    []string `json:"versions,omitempty"`
 }
 
 type ResolverJson struct {
@@ -35,26 +47,59 @@ func GetRegolithConfigPath() (string, error) {
 	return filepath.Join(path, regolithConfigPath), nil
 }
 
-// DownloadResolverMap downloads the resolver.json file
-func DownloadResolverMap() error {
-	Logger.Info("Downloading resolver.json")
+// GetRegistryCachePath returns the path to the downloaded index of the filter
+// registry with given URL.
+func GetRegistryCachePath(registryUrl string) (string, error) {
 	path, err := GetRegolithConfigPath()
 	if err != nil {
-		return WrapError(err, getRegolithConfigPathError)
+		return "", WrapError(err, getRegolithConfigPathError)
+	}
+	hash := md5.Sum([]byte(registryUrl))
+	return filepath.Join(
+		path, registriesPath, hex.EncodeToString(hash[:])+".json"), nil
+}
+
+// DownloadResolverMap downloads the indices of all of the filter registries
+// from the user config. The registries that fail to download keep their
+// previously downloaded indices, and the last error is returned after trying
+// all of them.
+func DownloadResolverMap() error {
+	registries, err := GetRegistryUrls()
+	if err != nil {
+		return PassError(err)
+	}
+	var lastErr error
+	for _, registryUrl := range registries {
+		err = downloadRegistry(registryUrl)
+		if err != nil {
+			Logger.Debugf("Failed to download registry %q: %s", registryUrl, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// downloadRegistry downloads the index of a single filter registry.
+func downloadRegistry(registryUrl string) error {
+	Logger.Infof("Downloading filter registry index %q", registryUrl)
+	targetPath, err := GetRegistryCachePath(registryUrl)
+	if err != nil {
+		return PassError(err)
 	}
 	// Download to tmp path first and then move it to the real path,
 	// overwritting the old file is possible only if download is successful
-	tmpPath := filepath.Join(path, ".resolver-tmp.json")
-	targetPath := filepath.Join(path, "resolver.json")
-	err = getter.GetFile(tmpPath, resolverUrl)
+	tmpPath := strings.TrimSuffix(targetPath, ".json") + ".tmp.json"
+	err = tryMirrors(registryUrl, func(url string) error {
+		return getter.GetFile(tmpPath, url)
+	})
 	if err != nil {
 		os.Remove(tmpPath) // I don't think errors matter here
 		return WrapErrorf(
 			err,
-			"Unable to download filter resolver map file."+
-				"Download URL: %s"+
+			"Unable to download filter registry index file.\n"+
+				"Download URL: %s\n"+
 				"Download path (for saving file): %s",
-			resolverUrl, tmpPath)
+			registryUrl, tmpPath)
 	}
 	os.Remove(targetPath)
 	err = os.Rename(tmpPath, targetPath)
@@ -64,25 +109,42 @@ func DownloadResolverMap() error {
 	return nil
 }
 
-func LoadResolverAsMap() (map[string]interface{}, error) {
-	resolverPath, err := GetRegolithConfigPath()
+// LoadResolverAsMap loads the downloaded index of the filter registry with
+// given URL.
+func LoadResolverAsMap(registryUrl string) (map[string]interface{}, error) {
+	resolverPath, err := GetRegistryCachePath(registryUrl)
 	if err != nil {
-		return nil, WrapError(err, getRegolithConfigPathError)
+		return nil, PassError(err)
 	}
-	resolverPath = filepath.Join(resolverPath, "resolver.json")
 	file, err := ioutil.ReadFile(resolverPath)
 	if err != nil {
 		return nil, WrapErrorf(
 			err, fileReadError, resolverPath)
 	}
 	var resolverJson map[string]interface{}
-	err = jsonc.Unmarshal(file, &resolverJson)
+	err = unmarshalJsonc(file, &resolverJson)
 	if err != nil {
 		return nil, WrapErrorf(err, jsonUnmarshalError, resolverPath)
 	}
 	return resolverJson, nil
 }
 
+// LoadResolver loads and parses the downloaded index of the filter registry
+// with given URL.
+func LoadResolver(registryUrl string) (ResolverJson, error) {
+	resolverObj, err := LoadResolverAsMap(registryUrl)
+	if err != nil {
+		return ResolverJson{}, PassError(err)
+	}
+	resolver, err := ResolverFromObject(resolverObj)
+	if err != nil {
+		return ResolverJson{}, WrapErrorf(
+			err, "Failed to parse the filter registry index.\n"+
+				"Registry: %s", registryUrl)
+	}
+	return resolver, nil
+}
+
 func ResolverFromObject(obj map[string]interface{}) (ResolverJson, error) {
 	result := ResolverJson{}
 	// FormatVersion
@@ -136,29 +198,102 @@ func ResolverMapFromObject(obj map[string]interface{}) (ResolverMap, error) {
 		return result, WrappedErrorf(jsonPropertyTypeError, "url", "string")
 	}
 	result.Url = url
+	// Description - can be empty
+	result.Description, _ = obj["description"].(string)
+	// Versions - can be empty
+	if versionsObj, ok := obj["versions"]; ok {
+		versions, ok := versionsObj.([]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "versions", "array")
+		}
+		for i, versionObj := range versions {
+			version, ok := versionObj.(string)
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError, fmt.Sprintf("versions->%d", i),
+					"string")
+			}
+			result.Versions = append(result.Versions, version)
+		}
+	}
 	return result, nil
 }
 
-// ResolveUrl tries to resolve the URL to a filter based on a shortName. If
-// it fails it updates the resolver.json file and tries again
+// ResolveUrl tries to resolve the URL to a filter based on a shortName. The
+// registries from the user config are checked in order, and the first one
+// that has the filter is used. The function uses the downloaded indices of
+// the registries, they're updated with DownloadResolverMap.
 func ResolveUrl(shortName string) (string, error) {
-	const resolverLoadErrror = "Unable to load the name to URL resolver map."
-	resolverObj, err := LoadResolverAsMap()
+	registries, err := GetRegistryUrls()
 	if err != nil {
-		return "", WrapError(err, resolverLoadErrror)
+		return "", PassError(err)
 	}
-	resolver, err := ResolverFromObject(resolverObj)
+	for _, registryUrl := range registries {
+		resolver, err := LoadResolver(registryUrl)
+		if err != nil {
+			Logger.Debugf(
+				"Unable to load the filter registry %q: %s", registryUrl, err)
+			continue
+		}
+		if filterMap, ok := resolver.Filters[shortName]; ok {
+			return filterMap.Url, nil
+		}
+	}
+	return "", WrappedErrorf(
+		"The filter isn't in any of the filter registries.\n"+
+			"Filter name: %s\n"+
+			"Registries:\n%s",
+		shortName, strings.Join(registries, "\n"))
+}
+
+// RegistrySearchResult is a filter found in a filter registry by
+// SearchRegistries.
+type RegistrySearchResult struct {
+	ResolverMap
+	Name     string
+	Registry string
+}
+
+// SearchRegistries returns the filters from the downloaded indices of the
+// filter registries, whose names or descriptions contain the search term
+// (case insensitive). The results are sorted by name. If the same filter name
+// is in multiple registries, only the one that would be used by ResolveUrl
+// is returned.
+func SearchRegistries(term string) ([]RegistrySearchResult, error) {
+	registries, err := GetRegistryUrls()
 	if err != nil {
-		return "", WrapError(err, resolverLoadErrror)
+		return nil, PassError(err)
 	}
-	filterMap, ok := resolver.Filters[shortName]
-	if !ok {
-		return "", WrappedErrorf(
-			"The filter doesn't have known mapping to URL in the URL "+
-				"resolver.\n"+
-				"Filter name: %s\n"+
-				"Resolver URL: %s",
-			shortName, resolverUrl)
-	}
-	return filterMap.Url, nil
+	term = strings.ToLower(term)
+	found := make(map[string]struct{})
+	var result []RegistrySearchResult
+	for _, registryUrl := range registries {
+		resolver, err := LoadResolver(registryUrl)
+		if err != nil {
+			Logger.Warnf(
+				"Unable to load the filter registry %q:\n%s", registryUrl, err)
+			continue
+		}
+		for name, filterMap := range resolver.Filters {
+			if _, ok := found[name]; ok {
+				continue
+			}
+			if !strings.Contains(strings.ToLower(name), term) &&
+				!strings.Contains(
+					strings.ToLower(filterMap.Description), term) {
+				continue
+			}
+			found[name] = struct{}{}
+			result = append(result, RegistrySearchResult{
+				ResolverMap: filterMap,
+				Name:        name,
+				Registry:    registryUrl,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
 }
@@ -0,0 +1,263 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JunitOutput is the path to the JUnit XML file with the results of the
+// filters, saved after every run (see writeJunitReport). It's set with the
+// "--junit" flag.
+var JunitOutput = ""
+
+// filterResult is the result of a single execution of a filter, collected
+// for the reports of the runs.
+type filterResult struct {
+	// Profiles are the names of the profiles that ran the filter, starting
+	// with the profile that was run and ending with the nested profile of
+	// the filter.
+	Profiles []string
+	Filter   string
+	// Status is "passed", "failed" or "skipped".
+	Status   string
+	Duration time.Duration
+	// Message is the error of the failed filter or the reason why the
+	// filter was skipped.
+	Message string
+	// Output are the lines printed by the filter.
+	Output []string
+}
+
+// The diagnostics reported by the filters during the current run (see
+// recordDiagnostic), the results of the filters (see recordFilterResult) and
+// the output of the filters that are running, by their IDs (see
+// captureFilterOutput).
+var runDiagnostics []FilterDiagnostic
+var runFilterResults []filterResult
+var runFilterOutput = make(map[string][]string)
+var runReportMutex sync.Mutex
+
+// The numbers of the filters of the current run by their statuses (see
+// recordFilterResult), of the exported files (see recordExportedFiles) and of
+// the logged warnings (see countWarnings), for the summary of the run (see
+// printRunSummary).
+var runFilterCounts = make(map[string]int)
+var runExportedFiles int
+var runWarnings int64
+
+// resetRunReport starts collecting the report of a new run of the profile of
+// the context: the timings of its phases (see resetTimings) and the
+// diagnostics of its filters. The start of the run is sent to the stream of
+// the events.
+func resetRunReport(context RunContext) {
+	resetTimings()
+	emitEvent(runEvent{Type: "run-start", Profile: context.Profile})
+	runReportMutex.Lock()
+	runDiagnostics = nil
+	runFilterResults = nil
+	runFilterOutput = make(map[string][]string)
+	runFilterCounts = make(map[string]int)
+	runExportedFiles = 0
+	runReportMutex.Unlock()
+	atomic.StoreInt64(&runWarnings, 0)
+}
+
+// finishRunReport prints the timings of the run of the profile of the
+// context (see reportTimings) and its summary (see printRunSummary), and
+// writes the reports of the run, like the step summary of GitHub Actions (see
+// writeGithubSummary), the JUnit XML file (see writeJunitReport) and the
+// SARIF file (see writeSarifReport). The webhooks of the project are
+// notified about the result (see notifyWebhooks), except in the watch mode.
+// The runErr is the error of the run, nil if it succeeded. The iteration is
+// the number of the run in the watch mode, or 0 outside of it.
+func finishRunReport(
+	context RunContext, runErr error, iteration int,
+) error {
+	profileName := context.Profile
+	duration := time.Since(timedRunStart)
+	err1 := reportTimings()
+	printRunSummary(duration, iteration)
+	var err2, err3, err4, err5 error
+	if githubLogs() {
+		if runErr != nil {
+			printGithubCommand(
+				"error", map[string]string{"title": "Regolith"},
+				PassError(runErr).Error())
+		}
+		err2 = writeGithubSummary(profileName, runErr)
+	}
+	if JunitOutput != "" {
+		err3 = writeJunitReport(profileName, runErr)
+	}
+	if SarifOutput != "" {
+		err4 = writeSarifReport()
+	}
+	if !context.IsInWatchMode() {
+		err5 = notifyWebhooks(context.Config, profileName, runErr, duration)
+	}
+	event := runEvent{
+		Type: "run-done", Profile: profileName, Duration: duration.Seconds()}
+	if runErr != nil {
+		event.Error = PassError(runErr).Error()
+	}
+	emitEvent(event)
+	if err := firstErr(err1, err2, err3, err4, err5); err != nil {
+		return PassError(err)
+	}
+	return nil
+}
+
+// recordDiagnostic adds the diagnostic reported by a filter to the report of
+// the run and sends it to the stream of the events. With the "github"
+// LogFormat, it's also printed as an annotation.
+func recordDiagnostic(diagnostic FilterDiagnostic) {
+	runReportMutex.Lock()
+	runDiagnostics = append(runDiagnostics, diagnostic)
+	runReportMutex.Unlock()
+	emitEvent(runEvent{
+		Type:       "filter-diagnostic",
+		Filter:     diagnostic.Filter,
+		Diagnostic: &diagnostic,
+	})
+	if githubLogs() {
+		printGithubDiagnostic(diagnostic)
+	}
+}
+
+// currentRunDiagnostics returns the diagnostics reported during the current
+// run.
+func currentRunDiagnostics() []FilterDiagnostic {
+	runReportMutex.Lock()
+	defer runReportMutex.Unlock()
+	return append([]FilterDiagnostic{}, runDiagnostics...)
+}
+
+// collectFilterResults returns true if the results of the filters are
+// collected for the reports of the runs.
+func collectFilterResults() bool {
+	return JunitOutput != ""
+}
+
+// captureFilterOutput saves a line printed by the filter with the ID, for
+// the result of its execution (see recordFilterResult).
+func captureFilterOutput(filterId, line string) {
+	if !collectFilterResults() || filterId == "" {
+		return
+	}
+	runReportMutex.Lock()
+	defer runReportMutex.Unlock()
+	runFilterOutput[filterId] = append(runFilterOutput[filterId], line)
+}
+
+// recordFilterResult adds the result of the execution of the filter in the
+// context to the report of the run, with the output captured since its last
+// execution. The message is the error of the failed filter or the reason
+// why it was skipped.
+func recordFilterResult(
+	context RunContext, filterId, status string, duration time.Duration,
+	message string,
+) {
+	if filterId == "" {
+		return
+	}
+	runReportMutex.Lock()
+	runFilterCounts[status]++
+	runReportMutex.Unlock()
+	if !collectFilterResults() {
+		return
+	}
+	var profiles []string
+	for c := &context; c != nil; c = c.Parent {
+		profiles = append([]string{c.Profile}, profiles...)
+	}
+	runReportMutex.Lock()
+	defer runReportMutex.Unlock()
+	runFilterResults = append(runFilterResults, filterResult{
+		Profiles: profiles,
+		Filter:   filterId,
+		Status:   status,
+		Duration: duration,
+		Message:  message,
+		Output:   runFilterOutput[filterId],
+	})
+	delete(runFilterOutput, filterId)
+}
+
+// printRunSummary prints the numbers of the filters executed, skipped and
+// failed during the current run, of the logged warnings and of the exported
+// files, and the duration of the run. In the watch mode, the summary also
+// has the number of the iteration.
+func printRunSummary(duration time.Duration, iteration int) {
+	runReportMutex.Lock()
+	passed := runFilterCounts["passed"]
+	failed := runFilterCounts["failed"]
+	skipped := runFilterCounts["skipped"]
+	exportedFiles := runExportedFiles
+	runReportMutex.Unlock()
+	warnings := atomic.LoadInt64(&runWarnings)
+	message := fmt.Sprintf(
+		"Summary: %d filters executed, %d skipped, %d failed, %d warnings, "+
+			"%d files exported in %s",
+		passed+failed, skipped, failed, warnings, exportedFiles,
+		duration.Round(time.Millisecond))
+	if iteration > 0 {
+		message += fmt.Sprintf(" (watch iteration %d)", iteration)
+	}
+	Logger.Infow(message, logFields(
+		"executed", passed+failed, "skipped", skipped, "failed", failed,
+		"warnings", warnings, "exportedFiles", exportedFiles,
+		"duration", duration, "iteration", iteration)...)
+}
+
+// countTmpPackFiles returns the number of the files of the packs in the tmp
+// directory, counted before the export (see recordExportedFiles), because
+// the export moves them.
+func countTmpPackFiles(dotRegolithPath string) int {
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	count := 0
+	for _, pack := range tmpPackDirs(tmpPath) {
+		filepath.WalkDir(
+			filepath.Join(tmpPath, pack),
+			func(_ string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					count++
+				}
+				return nil
+			})
+	}
+	return count
+}
+
+// recordExportedFiles saves the number of the files exported by the current
+// run, for its summary.
+func recordExportedFiles(count int) {
+	runReportMutex.Lock()
+	defer runReportMutex.Unlock()
+	runExportedFiles = count
+}
+
+// currentFilterResults returns the results of the filters of the current
+// run, in the order in which they finished.
+func currentFilterResults() []filterResult {
+	runReportMutex.Lock()
+	defer runReportMutex.Unlock()
+	return append([]filterResult{}, runFilterResults...)
+}
+
+// collectPhases returns true if the phases of the runs are collected by
+// startPhase, for the timings or the reports of the runs.
+func collectPhases() bool {
+	return Timings || githubLogs()
+}
+
+// currentRunPhases returns the phases of the current run collected by
+// startPhase, in the order in which they ended.
+func currentRunPhases() []timedPhase {
+	timedPhasesMutex.Lock()
+	defer timedPhasesMutex.Unlock()
+	return append([]timedPhase{}, timedPhases...)
+}
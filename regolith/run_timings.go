@@ -0,0 +1,203 @@
+package regolith
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timings enables measuring the durations of the phases of the runs (the
+// setup of the tmp directory, the filters and the export) and printing them
+// after every run. It's set with the "--timings" flag.
+var Timings = false
+
+// TimingsOutput is the path to the file to which the timings are saved after
+// every run, set with the "--timings-output" flag. The files with the
+// ".json" extension use JSON, and the other files use the folded stacks
+// format, which can be opened with the flame graph tools (flamegraph.pl,
+// speedscope, etc.).
+var TimingsOutput = ""
+
+// PhaseObserver is called at the start and at the end of every phase of a
+// run (see startPhase), so the programs that embed Regolith can report the
+// progress. The stack is the same as the Stack of the timedPhase, and the
+// duration is zero at the start of the phase.
+var PhaseObserver func(stack []string, done bool, duration time.Duration)
+
+// timedPhase is a measured phase of a run.
+type timedPhase struct {
+	// Stack is the list of the names of the profiles (starting with the
+	// profile that was run and ending with the nested profile of the phase),
+	// followed by the name of the phase.
+	Stack    []string      `json:"stack"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+	// Files and Bytes are the number of the files and the bytes copied,
+	// moved or linked by Regolith during the phase. The files written by the
+	// filters aren't counted. The phases that run at the same time (the
+	// parallel filters) count the files of each other.
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// runTimingsReport is the content of the JSON file with the timings.
+type runTimingsReport struct {
+	Seconds float64      `json:"seconds"`
+	Phases  []timedPhase `json:"phases"`
+}
+
+// The counters of the files and the bytes written by Regolith, used for
+// measuring the phases.
+var timedFiles, timedBytes int64
+
+// The phases of the current run and the time when it started.
+var timedPhases []timedPhase
+var timedPhasesMutex sync.Mutex
+var timedRunStart time.Time
+
+// countFileIO adds the files and the bytes written by Regolith to the
+// counters of the timings, if they're enabled.
+func countFileIO(files, bytes int64) {
+	if !Timings {
+		return
+	}
+	atomic.AddInt64(&timedFiles, files)
+	atomic.AddInt64(&timedBytes, bytes)
+}
+
+// countCopiedFile counts the file copied to the path (see countFileIO).
+func countCopiedFile(path string) {
+	if !Timings {
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		countFileIO(1, info.Size())
+	}
+}
+
+// resetTimings starts measuring a new run.
+func resetTimings() {
+	timedPhasesMutex.Lock()
+	defer timedPhasesMutex.Unlock()
+	timedPhases = nil
+	timedRunStart = time.Now()
+}
+
+// startPhase starts measuring a phase of the run of the profile from the
+// context and returns the function that ends it. The end of the phase is
+// also logged, if the log messages use the JSON format, and reported to the
+// PhaseObserver and the stream of the events. It does nothing if the phases
+// aren't collected (see collectPhases), the log messages use the text
+// format, there is no PhaseObserver and the events aren't sent.
+func startPhase(context RunContext, name string) func() {
+	if !collectPhases() && !jsonLogs() && PhaseObserver == nil &&
+		!eventsEnabled() {
+		return func() {}
+	}
+	stack := []string{name}
+	for c := &context; c != nil; c = c.Parent {
+		stack = append([]string{c.Profile}, stack...)
+	}
+	if PhaseObserver != nil {
+		PhaseObserver(stack, false, 0)
+	}
+	emitPhaseEvent(stack, false, 0)
+	start := time.Now()
+	files := atomic.LoadInt64(&timedFiles)
+	bytes := atomic.LoadInt64(&timedBytes)
+	return func() {
+		duration := time.Since(start)
+		if PhaseObserver != nil {
+			PhaseObserver(stack, true, duration)
+		}
+		emitPhaseEvent(stack, true, duration)
+		if jsonLogs() {
+			logPhase(context, name, duration)
+		}
+		if !collectPhases() {
+			return
+		}
+		timedPhasesMutex.Lock()
+		defer timedPhasesMutex.Unlock()
+		timedPhases = append(timedPhases, timedPhase{
+			Stack:    stack,
+			Duration: duration,
+			Seconds:  duration.Seconds(),
+			Files:    atomic.LoadInt64(&timedFiles) - files,
+			Bytes:    atomic.LoadInt64(&timedBytes) - bytes,
+		})
+	}
+}
+
+// logPhase logs the end of a phase of the run with the fields of the JSON
+// log messages (see LogFormat).
+func logPhase(context RunContext, name string, duration time.Duration) {
+	fields := []interface{}{"phase", name}
+	// The phases of the filters are named "filter <id>"
+	if filterId := strings.TrimPrefix(name, "filter "); filterId != name {
+		fields = []interface{}{"phase", "filter", "filter", filterId}
+	}
+	fields = append(fields, "profile", context.Profile, "duration", duration)
+	Logger.Infow(fmt.Sprintf("Finished %s", name), fields...)
+}
+
+// reportTimings prints the phases of the run sorted by their durations and
+// saves them to the TimingsOutput file. It does nothing if the timings are
+// disabled.
+func reportTimings() error {
+	if !Timings {
+		return nil
+	}
+	timedPhasesMutex.Lock()
+	phases := append([]timedPhase{}, timedPhases...)
+	timedPhasesMutex.Unlock()
+	total := time.Since(timedRunStart)
+	sort.SliceStable(phases, func(i, j int) bool {
+		return phases[i].Duration > phases[j].Duration
+	})
+	Logger.Info("Timings:")
+	Logger.Infof("  %-50s %10s %8s %10s", "Phase", "Duration", "Files", "Size")
+	for _, phase := range phases {
+		Logger.Infof(
+			"  %-50s %10s %8d %10s", strings.Join(phase.Stack, " > "),
+			phase.Duration.Round(time.Millisecond), phase.Files,
+			formatSize(phase.Bytes))
+	}
+	Logger.Infof("  %-50s %10s", "Total", total.Round(time.Millisecond))
+	if TimingsOutput == "" {
+		return nil
+	}
+	var data []byte
+	if strings.EqualFold(filepath.Ext(TimingsOutput), ".json") {
+		data, _ = json.MarshalIndent(runTimingsReport{
+			Seconds: total.Seconds(),
+			Phases:  phases,
+		}, "", "\t") // no error
+	} else {
+		// The folded stacks, one line per phase with the duration in
+		// microseconds
+		var builder strings.Builder
+		for _, phase := range phases {
+			frames := make([]string, len(phase.Stack))
+			for i, frame := range phase.Stack {
+				frames[i] = strings.ReplaceAll(frame, ";", "_")
+			}
+			fmt.Fprintf(
+				&builder, "%s %d\n", strings.Join(frames, ";"),
+				phase.Duration.Microseconds())
+		}
+		data = []byte(builder.String())
+	}
+	err := os.WriteFile(TimingsOutput, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, TimingsOutput)
+	}
+	Logger.Infof("Saved the timings to %q.", TimingsOutput)
+	return nil
+}
@@ -0,0 +1,170 @@
+package regolith
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sarifSchema is the JSON schema of the SARIF files written by Regolith.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRule describes the diagnostics of a filter. Every filter is a rule,
+// because the diagnostics of the filters don't have their own identifiers.
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SarifOutput is the path to the SARIF file with the diagnostics of the
+// filters, saved after every run (see writeSarifReport). It's set with the
+// "--sarif" flag.
+var SarifOutput = ""
+
+// writeSarifReport saves the diagnostics reported by the filters during the
+// current run to the SarifOutput file, for GitHub code scanning and the other
+// tools that read the SARIF format. The file is written even if there are no
+// diagnostics, so the tools know that the previous problems were fixed.
+func writeSarifReport() error {
+	diagnostics := currentRunDiagnostics()
+	rules := []sarifRule{}
+	ruleIds := make(map[string]bool)
+	results := []sarifResult{}
+	for _, diagnostic := range diagnostics {
+		if !ruleIds[diagnostic.Filter] {
+			ruleIds[diagnostic.Filter] = true
+			rules = append(rules, sarifRule{
+				Id: diagnostic.Filter,
+				ShortDescription: sarifMessage{
+					Text: "Problem reported by the " + diagnostic.Filter +
+						" filter"},
+			})
+		}
+		result := sarifResult{
+			RuleId:  diagnostic.Filter,
+			Level:   sarifLevel(diagnostic.Severity),
+			Message: sarifMessage{Text: diagnostic.Message},
+		}
+		if diagnostic.File != "" {
+			location := sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{
+					Uri: sarifUri(diagnostic.File)},
+			}
+			if diagnostic.Line > 0 {
+				location.Region = &sarifRegion{
+					StartLine: diagnostic.Line, StartColumn: diagnostic.Column}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		results = append(results, result)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Id < rules[j].Id
+	})
+	report := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "Regolith",
+				InformationUri: "https://github.com/Bedrock-OSS/regolith",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return WrapError(err, "Failed to encode the SARIF report.")
+	}
+	err = os.MkdirAll(filepath.Dir(SarifOutput), 0755)
+	if err != nil {
+		return WrapErrorf(err, osMkdirError, filepath.Dir(SarifOutput))
+	}
+	err = os.WriteFile(SarifOutput, append(data, '\n'), 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, SarifOutput)
+	}
+	Logger.Infof("Saved the SARIF report to %q.", SarifOutput)
+	return nil
+}
+
+// sarifLevel returns the SARIF level of the severity of a diagnostic.
+func sarifLevel(severity string) string {
+	switch severity {
+	case "warn", "warning":
+		return "warning"
+	case "info", "debug":
+		return "note"
+	}
+	return "error"
+}
+
+// sarifUri returns the URI of the file of a diagnostic. The paths inside of
+// the project are relative, so the tools can match them with the files of
+// the repository. On GitHub Actions, they're relative to the root of the
+// repository (see githubWorkspacePath).
+func sarifUri(path string) string {
+	path = githubWorkspacePath(path)
+	if filepath.IsAbs(path) {
+		if !strings.HasPrefix(path, "/") {
+			// Windows paths like "C:/project/BP/x.json"
+			path = "/" + path
+		}
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	}
+	return (&url.URL{Path: path}).String()
+}
@@ -0,0 +1,182 @@
+package regolith
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// secretService is the name of the service that owns the secrets of Regolith
+// in the credential store of the operating system.
+const secretService = "regolith"
+
+// secretEnvPrefix is the prefix of the environment variables that override
+// the secrets from the credential store. It's useful on the CI servers,
+// which usually don't have a credential store.
+const secretEnvPrefix = "REGOLITH_SECRET_"
+
+// secretNamePattern matches the valid names of the secrets. The names must be
+// valid in the names of the environment variables (see secretEnvPrefix).
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretCache stores the secrets read during this run of Regolith, so the
+// credential store isn't asked for the same secret by every filter.
+var secretCache sync.Map
+
+// resolvedSecrets stores the names of the secrets read during this run of
+// Regolith by their values, so the values can be hidden in the logs (see
+// hideSecrets).
+var resolvedSecrets sync.Map
+
+// checkSecretName returns an error if the name of the secret is invalid.
+func checkSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return WrappedErrorf(
+			"Invalid name of a secret.\nName: %q\n"+
+				"The name can only contain letters, digits and underscores "+
+				"and can't start with a digit.", name)
+	}
+	return nil
+}
+
+// lookupSecret returns the value of the secret from the
+// REGOLITH_SECRET_<NAME> environment variable or, if it's not set, from the
+// credential store of the operating system.
+func lookupSecret(name string) (string, error) {
+	if err := checkSecretName(name); err != nil {
+		return "", PassError(err)
+	}
+	if value, ok := os.LookupEnv(secretEnvPrefix + name); ok {
+		resolvedSecrets.Store(value, name)
+		return value, nil
+	}
+	if value, ok := secretCache.Load(name); ok {
+		return value.(string), nil
+	}
+	value, found, err := readSecret(name)
+	if err != nil {
+		return "", WrapErrorf(
+			err, "Failed to read the secret from the credential store.\n"+
+				"Secret: %s", name)
+	}
+	if !found {
+		return "", WrappedErrorf(
+			"The secret doesn't exist.\nSecret: %s\n"+
+				"Save it with \"regolith secret set %s\" or set the %s%s "+
+				"environment variable.",
+			name, name, secretEnvPrefix, name)
+	}
+	secretCache.Store(name, value)
+	resolvedSecrets.Store(value, name)
+	return value, nil
+}
+
+// expandSecrets replaces the "${secret:NAME}" placeholders in the text with
+// the values of the secrets (see lookupSecret). It's used for the properties
+// of the export targets, which are resolved just before the export.
+func expandSecrets(text string) (string, error) {
+	if !strings.Contains(text, "${secret:") {
+		return text, nil
+	}
+	var err error
+	result := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		name, argument := groups[1], groups[2]
+		if name != "secret" || !strings.Contains(match, ":") || err != nil {
+			return match
+		}
+		value, lookupErr := lookupSecret(argument)
+		if lookupErr != nil {
+			err = lookupErr
+			return match
+		}
+		return value
+	})
+	if err != nil {
+		return "", PassError(err)
+	}
+	return result, nil
+}
+
+// maskSecrets replaces the "${secret:NAME}" placeholders in the text with
+// "<secret:NAME>", so the text can be printed (for example by the dry run)
+// without resolving the secrets.
+func maskSecrets(text string) string {
+	if !strings.Contains(text, "${secret:") {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		if groups[1] != "secret" || !strings.Contains(match, ":") {
+			return match
+		}
+		return "<secret:" + groups[2] + ">"
+	})
+}
+
+// hideSecrets replaces the values of the secrets read during this run of
+// Regolith (see lookupSecret) in the text with "<secret:NAME>", so the
+// commands that use the secrets can be printed.
+func hideSecrets(text string) string {
+	resolvedSecrets.Range(func(value, name interface{}) bool {
+		if value != "" {
+			text = strings.ReplaceAll(
+				text, value.(string), "<secret:"+name.(string)+">")
+		}
+		return true
+	})
+	return text
+}
+
+// resolveExportTargetSecrets returns the export target with the secrets in
+// its properties resolved (see expandSecrets). The secrets aren't resolved
+// when the config is loaded, so the commands that don't export the project
+// don't need access to the credential store.
+func resolveExportTargetSecrets(target ExportTarget) (ExportTarget, error) {
+	fields := map[string]*string{
+		"rpPath":       &target.RpPath,
+		"bpPath":       &target.BpPath,
+		"worldName":    &target.WorldName,
+		"worldPath":    &target.WorldPath,
+		"host":         &target.Host,
+		"user":         &target.User,
+		"identityFile": &target.IdentityFile,
+		"path":         &target.Path,
+		"device":       &target.Device,
+		"endpoint":     &target.Endpoint,
+		"region":       &target.Region,
+		"bucket":       &target.Bucket,
+		"prefix":       &target.Prefix,
+	}
+	for name, field := range fields {
+		value, err := expandSecrets(*field)
+		if err != nil {
+			return target, WrapErrorf(
+				err, "Failed to resolve the secrets of the export target.\n"+
+					"Property: %s", name)
+		}
+		*field = value
+	}
+	hooks := map[string]*[]string{
+		"preExport":  &target.PreExport,
+		"postExport": &target.PostExport,
+	}
+	for name, commands := range hooks {
+		if len(*commands) == 0 {
+			continue
+		}
+		resolved := make([]string, len(*commands))
+		for i, command := range *commands {
+			value, err := expandSecrets(command)
+			if err != nil {
+				return target, WrapErrorf(
+					err, "Failed to resolve the secrets of the export "+
+						"target.\nProperty: %s->%d", name, i)
+			}
+			resolved[i] = value
+		}
+		*commands = resolved
+	}
+	return target, nil
+}
@@ -0,0 +1,122 @@
+//go:build !windows
+// +build !windows
+
+package regolith
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// macOsItemNotFound is the exit code of the "security" command when the
+// item doesn't exist in the keychain.
+const macOsItemNotFound = 44
+
+// secretToolMissingError is used when the "secret-tool" command (from
+// libsecret) isn't installed.
+const secretToolMissingError = "Failed to find the \"secret-tool\" command, " +
+	"which is used for accessing the credential store.\n" +
+	"Install libsecret (for example the \"libsecret-tools\" package) or use " +
+	"the " + secretEnvPrefix + "<NAME> environment variables."
+
+// writeSecret saves the secret in the credential store of the operating
+// system: the keychain on macOS or the Secret Service (with "secret-tool")
+// on the other systems.
+func writeSecret(name, value string) error {
+	var err error
+	if runtime.GOOS == "darwin" {
+		_, _, err = runSecretCommand(
+			"", "security", "add-generic-password", "-U",
+			"-s", secretService, "-a", name, "-w", value)
+	} else {
+		_, _, err = runSecretCommand(
+			value, "secret-tool", "store", "--label=Regolith secret "+name,
+			"service", secretService, "secret", name)
+	}
+	if err != nil {
+		return PassError(err)
+	}
+	return nil
+}
+
+// readSecret reads the secret from the credential store of the operating
+// system. The second value is false if the secret doesn't exist.
+func readSecret(name string) (string, bool, error) {
+	if runtime.GOOS == "darwin" {
+		output, exitCode, err := runSecretCommand(
+			"", "security", "find-generic-password",
+			"-s", secretService, "-a", name, "-w")
+		if exitCode == macOsItemNotFound {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, PassError(err)
+		}
+		return strings.TrimSuffix(output, "\n"), true, nil
+	}
+	output, exitCode, err := runSecretCommand(
+		"", "secret-tool", "lookup", "service", secretService, "secret", name)
+	if exitCode == 1 && output == "" {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, PassError(err)
+	}
+	return output, true, nil
+}
+
+// deleteSecret removes the secret from the credential store of the operating
+// system.
+func deleteSecret(name string) error {
+	var exitCode int
+	var err error
+	if runtime.GOOS == "darwin" {
+		_, exitCode, err = runSecretCommand(
+			"", "security", "delete-generic-password",
+			"-s", secretService, "-a", name)
+		if exitCode == macOsItemNotFound {
+			return WrappedErrorf("The secret doesn't exist.\nSecret: %s", name)
+		}
+	} else {
+		_, _, err = runSecretCommand(
+			"", "secret-tool", "clear", "service", secretService, "secret",
+			name)
+	}
+	if err != nil {
+		return PassError(err)
+	}
+	return nil
+}
+
+// runSecretCommand runs the command of the credential store with the input
+// passed to its standard input. It returns the standard output and the exit
+// code of the command (-1 if the command didn't run).
+func runSecretCommand(
+	input, name string, args ...string,
+) (string, int, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		if name == "secret-tool" {
+			return "", -1, WrappedError(secretToolMissingError)
+		}
+		return "", -1, WrapErrorf(err, "Failed to find the %q command.", name)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		exitCode := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return stdout.String(), exitCode, WrapErrorf(
+			err, "The command of the credential store failed.\n"+
+				"Command: %s %s\nOutput: %s",
+			name, args[0], strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), 0, nil
+}
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+package regolith
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The functions of the Windows Credential Manager
+var (
+	advapi32        = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// credential is the CREDENTIALW structure of the Windows Credential Manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// secretTargetName returns the name of the credential of the secret in the
+// Windows Credential Manager.
+func secretTargetName(name string) (*uint16, error) {
+	target, err := windows.UTF16PtrFromString(secretService + ":" + name)
+	if err != nil {
+		return nil, WrapErrorf(err, "Invalid name of a secret.\nName: %q", name)
+	}
+	return target, nil
+}
+
+// writeSecret saves the secret in the Windows Credential Manager.
+func writeSecret(name, value string) error {
+	target, err := secretTargetName(name)
+	if err != nil {
+		return PassError(err)
+	}
+	userName, _ := windows.UTF16PtrFromString(name) // no error
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	result, _, err := procCredWriteW.Call(
+		uintptr(unsafe.Pointer(&cred)), 0)
+	if result == 0 {
+		return WrapError(err, "Failed to save the credential.")
+	}
+	return nil
+}
+
+// readSecret reads the secret from the Windows Credential Manager. The
+// second value is false if the secret doesn't exist.
+func readSecret(name string) (string, bool, error) {
+	target, err := secretTargetName(name)
+	if err != nil {
+		return "", false, PassError(err)
+	}
+	var cred *credential
+	result, _, err := procCredReadW.Call(
+		uintptr(unsafe.Pointer(target)), credTypeGeneric, 0,
+		uintptr(unsafe.Pointer(&cred)))
+	if result == 0 {
+		if err == windows.ERROR_NOT_FOUND {
+			return "", false, nil
+		}
+		return "", false, WrapError(err, "Failed to read the credential.")
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return "", true, nil
+	}
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), true, nil
+}
+
+// deleteSecret removes the secret from the Windows Credential Manager.
+func deleteSecret(name string) error {
+	target, err := secretTargetName(name)
+	if err != nil {
+		return PassError(err)
+	}
+	result, _, err := procCredDeleteW.Call(
+		uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if result == 0 {
+		if err == windows.ERROR_NOT_FOUND {
+			return WrappedErrorf("The secret doesn't exist.\nSecret: %s", name)
+		}
+		return WrapError(err, "Failed to delete the credential.")
+	}
+	return nil
+}
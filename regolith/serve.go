@@ -0,0 +1,537 @@
+package regolith
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// serveInfoPath is the path to the file with the address of the running
+// daemon (see Serve), relative to the .regolith directory.
+const serveInfoPath = "serve.json"
+
+// serveDialTimeout is the time that the CLI waits for the connection to the
+// daemon before running the command by itself.
+const serveDialTimeout = time.Second
+
+// maxServeResponseSize is the maximal size of a line of the response of the
+// daemon.
+const maxServeResponseSize = 16 * 1024 * 1024
+
+// serveInfo is the content of the serveInfoPath file. The token must be sent
+// with every request, so only the processes that can read the file can use
+// the daemon.
+type serveInfo struct {
+	Address string `json:"address"`
+	Token   string `json:"token"`
+	Pid     int    `json:"pid"`
+	// HttpAddress is the address of the HTTP API (see startHttpApi), empty
+	// if it isn't enabled.
+	HttpAddress string `json:"httpAddress,omitempty"`
+}
+
+// serveRequest is a request sent by the CLI to the daemon.
+type serveRequest struct {
+	Token string `json:"token"`
+	// Command is "run", "watch" or "stop"
+	Command       string   `json:"command"`
+	Profile       string   `json:"profile,omitempty"`
+	Defines       []string `json:"defines,omitempty"`
+	Recycled      bool     `json:"recycled,omitempty"`
+	ReloadPort    int      `json:"reloadPort,omitempty"`
+	Timings       bool     `json:"timings,omitempty"`
+	TimingsOutput string   `json:"timingsOutput,omitempty"`
+	JunitOutput   string   `json:"junitOutput,omitempty"`
+	SarifOutput   string   `json:"sarifOutput,omitempty"`
+	Reproducible  bool     `json:"reproducible,omitempty"`
+	Filters       []string `json:"filters,omitempty"`
+	SkipExport    bool     `json:"skipExport,omitempty"`
+	Paths         RunPaths `json:"paths,omitempty"`
+	// Events makes the daemon send the events of the runs to the CLI,
+	// which writes them to its own stream (see OpenEventStream).
+	Events bool `json:"events,omitempty"`
+}
+
+// serveResponse is a line of the response of the daemon. The daemon sends
+// its log messages with the Level and the Message, the events of the runs
+// with the Event (if the request asked for them), and ends the response with
+// Done and the Error of the command (empty on success).
+type serveResponse struct {
+	Level   string    `json:"level,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Event   *runEvent `json:"event,omitempty"`
+	Done    bool      `json:"done,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// serveEventWriter sends the events of the runs written by emitEvent to the
+// CLI, as the lines of the response of the daemon.
+type serveEventWriter struct {
+	output zapcore.WriteSyncer
+}
+
+func (w serveEventWriter) Write(p []byte) (int, error) {
+	var event runEvent
+	if err := json.Unmarshal(p, &event); err != nil {
+		return 0, err
+	}
+	data, _ := json.Marshal(serveResponse{Event: &event}) // no error
+	if _, err := w.output.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// daemon is the state of the "regolith serve" process kept between the
+// requests.
+type daemon struct {
+	token    string
+	listener net.Listener
+	// mutex makes the daemon handle one request at a time. The requests
+	// sent while the daemon is busy (for example, watching the project) are
+	// rejected.
+	mutex sync.Mutex
+	// contexts are the prepared run contexts (the loaded config and the
+	// checked filters) of the profiles, keyed by the profile name and the
+	// defines. They're prepared again when the config changes.
+	contexts     map[string]RunContext
+	configStamp  string
+	watchContext *RunContext
+
+	// The state of the HTTP API (see startHttpApi)
+	httpServer  *http.Server
+	events      daemonEvents
+	status      httpStatus
+	statusMutex sync.Mutex
+}
+
+// Serve handles the "regolith serve" command. It starts a daemon that keeps
+// the config of the project, the checked filters and the persistent filter
+// processes in memory, and runs the "regolith run" and "regolith watch"
+// commands sent by the CLI over a local TCP socket. The address of the
+// daemon is saved in the .regolith directory, so the CLI finds it
+// automatically. The daemon holds the lock of the project until it's stopped
+// with Ctrl+C or with "regolith serve --stop".
+//
+// If the "httpPort" isn't 0, the daemon also serves the HTTP API on this
+// port of localhost (see startHttpApi), for the editor plugins and the
+// dashboards. The API uses the same token as the CLI.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Serve(httpPort int, debug bool) error {
+	InitLogging(debug)
+	dotRegolithPath, err := loadDotRegolithPath()
+	if err != nil {
+		return PassError(err)
+	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	defer StopPersistentProcesses()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return WrapError(err, "Failed to start the daemon.")
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		listener.Close()
+		return WrapError(err, "Failed to generate the token of the daemon.")
+	}
+	d := &daemon{
+		token:    hex.EncodeToString(token),
+		listener: listener,
+		contexts: make(map[string]RunContext),
+		status:   httpStatus{Pid: os.Getpid()},
+	}
+	var httpAddress string
+	if httpPort != 0 {
+		httpAddress, err = d.startHttpApi(httpPort)
+		if err != nil {
+			listener.Close()
+			return PassError(err)
+		}
+		defer d.httpServer.Close()
+	}
+	infoPath := filepath.Join(dotRegolithPath, serveInfoPath)
+	infoJson, _ := json.Marshal(serveInfo{
+		Address:     listener.Addr().String(),
+		Token:       d.token,
+		Pid:         os.Getpid(),
+		HttpAddress: httpAddress,
+	}) // no error
+	err = os.WriteFile(infoPath, infoJson, 0600)
+	if err != nil {
+		listener.Close()
+		return WrapErrorf(err, fileWriteError, infoPath)
+	}
+	defer os.Remove(infoPath)
+	// Ctrl+C stops the daemon
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		listener.Close()
+	}()
+	Logger.Infof(
+		"The daemon is listening on %s. Press Ctrl+C to stop it.",
+		listener.Addr())
+	if httpAddress != "" {
+		Logger.Infof(
+			"The HTTP API is available at http://%s. The token is in %q.",
+			httpAddress, infoPath)
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break // The listener was closed
+		}
+		go d.handleConnection(conn)
+	}
+	// Wait for the current request
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	Logger.Info("The daemon was stopped.")
+	return nil
+}
+
+// loadDotRegolithPath returns the path to the .regolith directory of the
+// project in the current working directory.
+func loadDotRegolithPath() (string, error) {
+	configMap, err1 := LoadConfigAsMap()
+	config, err2 := ConfigFromObject(configMap)
+	if err := firstErr(err1, err2); err != nil {
+		return "", WrapError(err, "Failed to load config.json.")
+	}
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, true, ".")
+	if err != nil {
+		return "", WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	return dotRegolithPath, nil
+}
+
+// handleConnection reads a request from the connection and sends the log
+// messages and the result of the command back. If the daemon is handling
+// another request, it sends back an error.
+func (d *daemon) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var request serveRequest
+	if err := json.Unmarshal(line, &request); err != nil ||
+		subtle.ConstantTimeCompare(
+			[]byte(request.Token), []byte(d.token)) != 1 {
+		Logger.Warn("The daemon received an invalid request.")
+		return
+	}
+	output := zapcore.Lock(zapcore.AddSync(conn))
+	if request.Command == "stop" {
+		d.respond(output, nil)
+		d.listener.Close()
+		return
+	}
+	if !d.mutex.TryLock() {
+		d.respond(output, WrappedError(
+			"The daemon is busy with another command, like \"regolith "+
+				"watch\". Wait until it finishes, or stop it."))
+		return
+	}
+	defer d.mutex.Unlock()
+	// The log messages are also sent to the client while the command runs
+	logger := Logger
+	Logger = Logger.Desugar().WithOptions(zap.WrapCore(
+		func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, zapcore.NewCore(
+				zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+					LevelKey:    "level",
+					MessageKey:  "message",
+					LineEnding:  zapcore.DefaultLineEnding,
+					EncodeLevel: zapcore.LowercaseLevelEncoder,
+				}), output, LoggerLevel))
+		})).Sugar()
+	defer func() { Logger = logger }()
+	Timings = request.Timings || request.TimingsOutput != ""
+	TimingsOutput = request.TimingsOutput
+	JunitOutput = request.JunitOutput
+	SarifOutput = request.SarifOutput
+	Reproducible = request.Reproducible
+	if request.Events {
+		previous := setEventStream(serveEventWriter{output})
+		defer setEventStream(previous)
+	}
+	switch request.Command {
+	case "run":
+		_, err := d.runWithStatus(request)
+		d.respond(output, err)
+	case "watch":
+		// The client stops watching by closing the connection
+		closed := make(chan struct{})
+		go func() {
+			reader.ReadByte()
+			close(closed)
+		}()
+		d.setStatus(request.Command, request.Profile, nil)
+		err := d.watch(request, closed)
+		d.setStatus("", "", nil)
+		d.respond(output, err)
+	default:
+		d.respond(output, WrappedErrorf(
+			"Unknown command of the daemon.\nCommand: %s", request.Command))
+	}
+}
+
+// respond sends the final line of the response with the error of the
+// command.
+func (d *daemon) respond(output zapcore.WriteSyncer, err error) {
+	response := serveResponse{Done: true}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	data, _ := json.Marshal(response) // no error
+	output.Write(append(data, '\n'))
+}
+
+// context returns the run context of the profile from the request. The
+// contexts are reused until config.json or the local config changes.
+func (d *daemon) context(request serveRequest) (RunContext, error) {
+	var stamp strings.Builder
+	for _, path := range []string{ConfigFilePath, LocalConfigFilePath} {
+		if info, err := os.Stat(path); err == nil {
+			stamp.WriteString(info.ModTime().String())
+		}
+		stamp.WriteString(";")
+	}
+	if stamp.String() != d.configStamp {
+		d.configStamp = stamp.String()
+		d.contexts = make(map[string]RunContext)
+	}
+	key := strings.Join(append([]string{request.Profile}, request.Defines...), "\n")
+	if context, ok := d.contexts[key]; ok {
+		return context, nil
+	}
+	context, err := prepareRunContext(request.Profile, request.Defines)
+	if err != nil {
+		return RunContext{}, PassError(err)
+	}
+	d.contexts[key] = context
+	return context, nil
+}
+
+// runProfile returns the function that runs the profiles, based on the
+// "recycled" flag.
+func (d *daemon) runProfile(request serveRequest) func(RunContext) error {
+	if request.Recycled {
+		return RecycledRunProfile
+	}
+	return RunProfile
+}
+
+// run runs the profile from the request once.
+func (d *daemon) run(request serveRequest) error {
+	context, err := d.context(request)
+	if err != nil {
+		return PassError(err)
+	}
+	context.Filters = request.Filters
+	context.SkipExport = request.SkipExport
+	context, err = applyRunPaths(context, request.Paths)
+	if err != nil {
+		return PassError(err)
+	}
+	resetRunReport(context)
+	err = d.runProfile(request)(context)
+	if err := finishRunReport(context, err, 0); err != nil {
+		Logger.Warnf("%s", PassError(err).Error())
+	}
+	if err != nil {
+		return WrapErrorf(err, "Failed to run profile %q", request.Profile)
+	}
+	Logger.Infof("Successfully ran the %q profile.", request.Profile)
+	return nil
+}
+
+// watch runs the profile from the request every time the source files
+// change, until the closed channel is closed. The source files are watched
+// from the first watch request until the daemon stops, because the watchers
+// can't be stopped (see RunContext.StartWatchingSrouceFiles).
+func (d *daemon) watch(request serveRequest, closed chan struct{}) error {
+	context, err := d.context(request)
+	if err != nil {
+		return PassError(err)
+	}
+	if d.watchContext == nil {
+		watchContext := context
+		err = watchContext.StartWatchingSrouceFiles()
+		if err != nil {
+			return WrapError(err, "Failed to start watching the source files.")
+		}
+		d.watchContext = &watchContext
+	} else if !sameWatchedPaths(*d.watchContext.Config, *context.Config) {
+		return WrappedError(
+			"The paths of the packs changed since the daemon started " +
+				"watching them. Restart the daemon.")
+	}
+	context.interruptionChannel = d.watchContext.interruptionChannel
+	context.changedFiles = d.watchContext.changedFiles
+	context.resume = d.watchContext.resume
+	var reloadServer *MinecraftWebSocketServer
+	if request.ReloadPort != 0 {
+		reloadServer, err = StartMinecraftWebSocketServer(request.ReloadPort)
+		if err != nil {
+			return PassError(err)
+		}
+		defer reloadServer.Close()
+	}
+	for context.IsInterrupted() {
+		// Drop the changes made before this request, the profile runs anyway
+	}
+	rp := d.runProfile(request)
+	for iteration := 1; ; iteration++ {
+		resetRunReport(context)
+		err = rp(context)
+		if err := finishRunReport(context, err, iteration); err != nil {
+			Logger.Warnf("%s", PassError(err).Error())
+		}
+		if err != nil {
+			Logger.Errorf(
+				"Failed to run profile %q: %s",
+				request.Profile, PassError(err).Error())
+		} else {
+			Logger.Infof("Successfully ran the %q profile.", request.Profile)
+			context.changedFiles.commit()
+			if reloadServer != nil {
+				reloadServer.Reload()
+			}
+		}
+		select {
+		case source := <-context.interruptionChannel:
+			Logger.Warn("Restarting...")
+			emitWatchIteration(context, iteration, source)
+		case <-closed:
+			Logger.Info("Stopped watching.")
+			return nil
+		}
+	}
+}
+
+// sameWatchedPaths returns true if the configs use the same paths of the
+// watched source files.
+func sameWatchedPaths(a, b Config) bool {
+	if a.ResourceFolder != b.ResourceFolder ||
+		a.BehaviorFolder != b.BehaviorFolder || a.DataPath != b.DataPath {
+		return false
+	}
+	aPacks := listAdditionalPacks(a.AdditionalPacks)
+	bPacks := listAdditionalPacks(b.AdditionalPacks)
+	if len(aPacks) != len(bPacks) {
+		return false
+	}
+	for i := range aPacks {
+		if aPacks[i].Source != bPacks[i].Source {
+			return false
+		}
+	}
+	return true
+}
+
+// sendToDaemon sends the request to the daemon of the project, if it's
+// running, and prints the log messages received from it. It returns false if
+// there is no daemon, so the CLI must handle the command by itself.
+func sendToDaemon(request serveRequest) (bool, error) {
+	dotRegolithPath, err := loadDotRegolithPath()
+	if err != nil {
+		return false, nil // The command reports the error by itself
+	}
+	infoJson, err := os.ReadFile(filepath.Join(dotRegolithPath, serveInfoPath))
+	if err != nil {
+		return false, nil
+	}
+	var info serveInfo
+	if err := json.Unmarshal(infoJson, &info); err != nil {
+		return false, nil
+	}
+	conn, err := net.DialTimeout("tcp", info.Address, serveDialTimeout)
+	if err != nil {
+		// The daemon was killed without removing the file
+		return false, nil
+	}
+	defer conn.Close()
+	Logger.Debugf("Sending the command to the daemon at %s.", info.Address)
+	request.Token = info.Token
+	requestJson, _ := json.Marshal(request) // no error
+	_, err = conn.Write(append(requestJson, '\n'))
+	if err != nil {
+		return true, WrapError(err, "Failed to send the command to the daemon.")
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxServeResponseSize)
+	for scanner.Scan() {
+		var response serveResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+		if response.Event != nil {
+			emitEvent(*response.Event)
+			continue
+		}
+		if response.Done {
+			if response.Error != "" {
+				return true, WrappedErrorf(
+					"The daemon failed to handle the command:\n%s",
+					response.Error)
+			}
+			return true, nil
+		}
+		switch response.Level {
+		case "debug":
+			Logger.Debug(response.Message)
+		case "warn":
+			Logger.Warn(response.Message)
+		case "error":
+			Logger.Error(response.Message)
+		default:
+			Logger.Info(response.Message)
+		}
+	}
+	return true, WrappedError("The connection to the daemon was closed.")
+}
+
+// StopServe handles the "regolith serve --stop" command. It stops the daemon
+// of the project.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func StopServe(debug bool) error {
+	InitLogging(debug)
+	sent, err := sendToDaemon(serveRequest{Command: "stop"})
+	if err != nil {
+		return PassError(err)
+	}
+	if !sent {
+		Logger.Info("The daemon of this project isn't running.")
+		return nil
+	}
+	Logger.Info("Stopped the daemon.")
+	return nil
+}
@@ -0,0 +1,265 @@
+package regolith
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxHttpRequestSize is the maximal size of the body of a request to the
+// HTTP API of the daemon.
+const maxHttpRequestSize = 1024 * 1024
+
+// httpRunRequest is the body of the "POST /run" request of the HTTP API.
+type httpRunRequest struct {
+	Profile    string   `json:"profile,omitempty"`
+	Defines    []string `json:"defines,omitempty"`
+	Recycled   bool     `json:"recycled,omitempty"`
+	Filters    []string `json:"filters,omitempty"`
+	SkipExport bool     `json:"skipExport,omitempty"`
+	Paths      RunPaths `json:"paths,omitempty"`
+}
+
+// httpRunResult is the result of a run, returned by "POST /run" and
+// "GET /status".
+type httpRunResult struct {
+	Profile  string    `json:"profile"`
+	Finished time.Time `json:"finished"`
+	Seconds  float64   `json:"seconds"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// httpStatus is the response of "GET /status".
+type httpStatus struct {
+	Pid     int            `json:"pid"`
+	Running bool           `json:"running"`
+	Command string         `json:"command,omitempty"`
+	Profile string         `json:"profile,omitempty"`
+	LastRun *httpRunResult `json:"lastRun,omitempty"`
+}
+
+// daemonEvents sends the events of the daemon (the log messages and the
+// changes of its status) to the clients of "GET /events".
+type daemonEvents struct {
+	mutex       sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+// subscribe returns the channel that receives the events, until it's
+// unsubscribed.
+func (e *daemonEvents) subscribe() chan []byte {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.subscribers == nil {
+		e.subscribers = make(map[chan []byte]struct{})
+	}
+	events := make(chan []byte, 256)
+	e.subscribers[events] = struct{}{}
+	return events
+}
+
+func (e *daemonEvents) unsubscribe(events chan []byte) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.subscribers, events)
+}
+
+// send sends the event with the data encoded as JSON to the subscribers.
+// The events are dropped for the clients that don't read them fast enough.
+func (e *daemonEvents) send(kind string, data interface{}) {
+	dataJson, _ := json.Marshal(data) // no error
+	event := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", kind, dataJson))
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for subscriber := range e.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Write implements the zapcore.WriteSyncer for sending the JSON log
+// messages as the "log" events.
+func (e *daemonEvents) Write(p []byte) (int, error) {
+	var message json.RawMessage = bytes.TrimSpace(append([]byte{}, p...))
+	e.send("log", message)
+	return len(p), nil
+}
+
+func (e *daemonEvents) Sync() error {
+	return nil
+}
+
+// startHttpApi starts the HTTP API of the daemon on the port of localhost
+// and returns its address. The requests must have the token of the daemon
+// in the "Authorization: Bearer <token>" header. The log messages of the
+// daemon are sent to the clients of "GET /events" from now on.
+func (d *daemon) startHttpApi(port int) (string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", WrapError(err, "Failed to start the HTTP API.")
+	}
+	Logger = Logger.Desugar().WithOptions(zap.WrapCore(
+		func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, zapcore.NewCore(
+				zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+					LevelKey:    "level",
+					MessageKey:  "message",
+					EncodeLevel: zapcore.LowercaseLevelEncoder,
+				}), &d.events, LoggerLevel))
+		})).Sugar()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", d.handleHttpRun)
+	mux.HandleFunc("/status", d.handleHttpStatus)
+	mux.HandleFunc("/events", d.handleHttpEvents)
+	d.httpServer = &http.Server{Handler: d.authorizeHttp(mux)}
+	go d.httpServer.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// authorizeHttp rejects the requests without the token of the daemon.
+func (d *daemon) authorizeHttp(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) != 1 {
+			http.Error(w, "Invalid token.", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// handleHttpRun handles the "POST /run" request. It runs the profile and
+// responds with the result of the run. The daemon runs one command at a
+// time, so the request fails if another command is running.
+func (d *daemon) handleHttpRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use the POST method.", http.StatusMethodNotAllowed)
+		return
+	}
+	var request httpRunRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHttpRequestSize)).
+		Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body.", http.StatusBadRequest)
+		return
+	}
+	if request.Profile == "" {
+		request.Profile = "default"
+	}
+	if !d.mutex.TryLock() {
+		http.Error(w, "The daemon is busy.", http.StatusConflict)
+		return
+	}
+	defer d.mutex.Unlock()
+	Timings = false
+	TimingsOutput = ""
+	JunitOutput = ""
+	SarifOutput = ""
+	Reproducible = false
+	result, _ := d.runWithStatus(serveRequest{
+		Command:    "run",
+		Profile:    request.Profile,
+		Defines:    request.Defines,
+		Recycled:   request.Recycled,
+		Filters:    request.Filters,
+		SkipExport: request.SkipExport,
+		Paths:      request.Paths.absolute(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleHttpStatus handles the "GET /status" request.
+func (d *daemon) handleHttpStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Use the GET method.", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.currentStatus())
+}
+
+// handleHttpEvents handles the "GET /events" request. It streams the events
+// of the daemon as server-sent events, until the client disconnects.
+func (d *daemon) handleHttpEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Use the GET method.", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", http.StatusInternalServerError)
+		return
+	}
+	events := d.events.subscribe()
+	defer d.events.unsubscribe(events)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	statusJson, _ := json.Marshal(d.currentStatus()) // no error
+	fmt.Fprintf(w, "event: status\ndata: %s\n\n", statusJson)
+	flusher.Flush()
+	for {
+		select {
+		case event := <-events:
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// runWithStatus runs the profile from the request once, like daemon.run,
+// and records the status of the daemon for the HTTP API. It returns the
+// result of the run and its error.
+func (d *daemon) runWithStatus(request serveRequest) (httpRunResult, error) {
+	d.setStatus(request.Command, request.Profile, nil)
+	start := time.Now()
+	err := d.run(request)
+	result := &httpRunResult{
+		Profile:  request.Profile,
+		Finished: time.Now(),
+		Seconds:  time.Since(start).Seconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	d.setStatus("", "", result)
+	return *result, err
+}
+
+// setStatus changes the command running in the daemon (empty if it's idle)
+// and the result of the last run (if it's not nil), and sends the status to
+// the clients of the HTTP API.
+func (d *daemon) setStatus(command, profile string, lastRun *httpRunResult) {
+	d.statusMutex.Lock()
+	d.status.Running = command != ""
+	d.status.Command = command
+	d.status.Profile = profile
+	if lastRun != nil {
+		d.status.LastRun = lastRun
+	}
+	status := d.status
+	d.statusMutex.Unlock()
+	d.events.send("status", status)
+}
+
+// currentStatus returns the status of the daemon.
+func (d *daemon) currentStatus() httpStatus {
+	d.statusMutex.Lock()
+	defer d.statusMutex.Unlock()
+	return d.status
+}
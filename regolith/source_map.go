@@ -0,0 +1,167 @@
+package regolith
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// tmpSourceMap maps the paths of the files in the tmp directory back to the
+// source files that were copied there before running the filters, so the
+// messages of the filters point to the files that the users edit, in the
+// "file:line:column: message" format understood by the problem matchers of
+// the editors.
+type tmpSourceMap struct {
+	// tmpPath is the absolute path to the tmp directory.
+	tmpPath string
+	// folders maps the folders of the tmp directory ("RP", "BP", "data" and
+	// the folders of the additional packs) to the absolute paths of their
+	// source folders.
+	folders map[string]string
+	// linked is true if the files of the packs are hardlinks to the source
+	// files (see tmpSetupHardlink).
+	linked bool
+	// pattern matches the paths to the files in the tmp directory in the
+	// output of the filters: the absolute paths, the paths relative to the
+	// project and the paths relative to the tmp directory (the working
+	// directory of the filters).
+	pattern *regexp.Regexp
+}
+
+var (
+	tmpSourcesMutex sync.Mutex
+	// tmpSources is the map of the tmp directory of the current run, set by
+	// the functions that set up the tmp directory. It's nil before the
+	// first run.
+	tmpSources *tmpSourceMap
+)
+
+// recordTmpSources saves the map of the tmp directory, which was just set up
+// with the source folders from the config. The linked argument is true if
+// the files of the packs were linked instead of copied.
+func recordTmpSources(config Config, dotRegolithPath string, linked bool) {
+	tmpPath, err := filepath.Abs(filepath.Join(dotRegolithPath, "tmp"))
+	if err != nil {
+		Logger.Debugf("Failed to map the tmp directory: %s", err)
+		return
+	}
+	sources := map[string]string{
+		"RP":   config.ResourceFolder,
+		"BP":   config.BehaviorFolder,
+		"data": config.DataPath,
+	}
+	for _, pack := range listAdditionalPacks(config.AdditionalPacks) {
+		sources[pack.TmpDir] = pack.Source
+	}
+	result := &tmpSourceMap{
+		tmpPath: tmpPath, folders: make(map[string]string), linked: linked}
+	var folderPatterns []string
+	for folder, source := range sources {
+		if source == "" {
+			continue
+		}
+		if absSource, err := filepath.Abs(source); err == nil {
+			result.folders[folder] = absSource
+			folderPatterns = append(folderPatterns, regexp.QuoteMeta(folder))
+		}
+	}
+	// The paths relative to the tmp directory must start with the name of
+	// one of its folders, at the start of a word
+	prefixes := folderPatterns
+	for _, path := range []string{tmpPath, displayPath(tmpPath)} {
+		prefixes = append(prefixes,
+			regexp.QuoteMeta(path), regexp.QuoteMeta(filepath.ToSlash(path)))
+	}
+	result.pattern = regexp.MustCompile(
+		`(^|[\s"'(\[=])((?:` + strings.Join(prefixes, "|") +
+			`)[/\\][^\s:"'()\[\],]+)`)
+	tmpSourcesMutex.Lock()
+	tmpSources = result
+	tmpSourcesMutex.Unlock()
+}
+
+// currentTmpSources returns the map of the tmp directory of the current run,
+// or nil if the tmp directory wasn't set up yet.
+func currentTmpSources() *tmpSourceMap {
+	tmpSourcesMutex.Lock()
+	defer tmpSourcesMutex.Unlock()
+	return tmpSources
+}
+
+// sourcePath maps the path to a file in the tmp directory to the path to its
+// source file, relative to the project. The path can be absolute, relative
+// to the project or relative to the tmp directory. If the file was changed
+// by the filters (or created by them), the line numbers in the source file
+// wouldn't match, so the path to the file in the tmp directory is returned
+// instead. The paths outside of the tmp directory are returned unchanged.
+func (m *tmpSourceMap) sourcePath(path string) string {
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(m.tmpPath, path)
+		if fromProject, err := filepath.Abs(path); err == nil &&
+			isInDirectory(fromProject, m.tmpPath) {
+			absPath = fromProject
+		}
+	}
+	if !isInDirectory(absPath, m.tmpPath) {
+		return path
+	}
+	relPath, _ := filepath.Rel(m.tmpPath, absPath) // no error
+	folder, rest, _ := strings.Cut(relPath, string(filepath.Separator))
+	if source, ok := m.folders[folder]; ok && rest != "" {
+		sourceFile := filepath.Join(source, rest)
+		if sameFileContent(absPath, sourceFile) {
+			return displayPath(sourceFile)
+		}
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		// Not a file in the tmp directory, for example a word like "BP/RP"
+		return path
+	}
+	return displayPath(absPath)
+}
+
+// mapTmpPaths replaces the paths to the files in the tmp directory in a line
+// of the output of a filter with the paths to their source files (see
+// sourcePath). The line is returned unchanged before the first run.
+func mapTmpPaths(line string) string {
+	m := currentTmpSources()
+	if m == nil {
+		return line
+	}
+	return m.pattern.ReplaceAllStringFunc(line, func(match string) string {
+		groups := m.pattern.FindStringSubmatch(match)
+		return groups[1] + m.sourcePath(groups[2])
+	})
+}
+
+// displayPath returns the absolute path relative to the project (the working
+// directory), or the absolute path if it's outside of the project.
+func displayPath(path string) string {
+	workingDir, err := os.Getwd()
+	if err != nil || !isInDirectory(path, workingDir) {
+		return path
+	}
+	relPath, _ := filepath.Rel(workingDir, path) // no error
+	return relPath
+}
+
+// sameFileContent returns true if both paths are files with the same content.
+func sameFileContent(a, b string) bool {
+	aInfo, err1 := os.Stat(a)
+	bInfo, err2 := os.Stat(b)
+	if firstErr(err1, err2) != nil || !aInfo.Mode().IsRegular() ||
+		!bInfo.Mode().IsRegular() || aInfo.Size() != bInfo.Size() {
+		return false
+	}
+	if os.SameFile(aInfo, bInfo) {
+		// The hardlinked tmp files (see tmpSetupHardlink)
+		return true
+	}
+	aData, err1 := os.ReadFile(a)
+	bData, err2 := os.ReadFile(b)
+	return firstErr(err1, err2) == nil && bytes.Equal(aData, bData)
+}
@@ -0,0 +1,177 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/otiai10/copy"
+)
+
+// The policies of handling the symbolic links in the source folders (see
+// copyDir) and in the exported packs (see applyExportSymlinkPolicy),
+// selected with the "symlinks" property of the config.
+const (
+	// symlinkCopyAsLink copies the links as links, which point to the same
+	// paths as the original links. It's the default policy.
+	symlinkCopyAsLink = "copy-as-link"
+	// symlinkFollow copies the files and the directories that the links
+	// point to instead of the links.
+	symlinkFollow = "follow"
+	// symlinkSkip leaves the links out.
+	symlinkSkip = "skip"
+	// symlinkError stops the run with an error if there are any links.
+	symlinkError = "error"
+)
+
+// symlinkPolicies are the valid values of the "symlinks" property.
+var symlinkPolicies = []string{
+	symlinkCopyAsLink, symlinkFollow, symlinkSkip, symlinkError}
+
+// symlinkReport collects the paths to the symbolic links handled by a
+// policy, so they can be listed after the copying.
+type symlinkReport struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (r *symlinkReport) add(path string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.paths = append(r.paths, path)
+}
+
+// log prints the handled links. The links copied with the default policy
+// are only printed in the debug mode, because their handling didn't change.
+func (r *symlinkReport) log(policy, source string) {
+	if len(r.paths) == 0 {
+		return
+	}
+	sort.Strings(r.paths)
+	log := Logger.Infof
+	if policy == symlinkCopyAsLink || policy == "" {
+		log = Logger.Debugf
+	}
+	log("Handled %d symbolic links in %q with the %q policy:\n%s",
+		len(r.paths), source, symlinkPolicyName(policy),
+		strings.Join(r.paths, "\n"))
+}
+
+// symlinkPolicyName returns the name of the policy, replacing the empty
+// policy with the default one.
+func symlinkPolicyName(policy string) string {
+	if policy == "" {
+		return symlinkCopyAsLink
+	}
+	return policy
+}
+
+// copySymlink copies the symbolic link at the path to the target, using the
+// policy.
+func copySymlink(path, target, policy string) error {
+	switch policy {
+	case symlinkSkip:
+		return nil
+	case symlinkError:
+		return WrappedErrorf(
+			"Found a symbolic link, which isn't allowed by the \"symlinks\" "+
+				"property of the config.\nLink: %s", path)
+	case symlinkFollow:
+		return copyLinkTarget(path, target)
+	}
+	err := copy.Copy(
+		path, target, copy.Options{PreserveTimes: false, Sync: false})
+	if err != nil {
+		return WrapErrorf(err, osCopyError, path, target)
+	}
+	return nil
+}
+
+// copyLinkTarget copies the file or the directory that the symbolic link at
+// the path points to, to the target. The links in the copied directories
+// are followed as well. The links to the parent directories of the links
+// are rejected, because copying them would never end.
+func copyLinkTarget(path, target string) error {
+	linkTarget, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to follow the symbolic link.\nLink: %s", path)
+	}
+	info, err := os.Stat(linkTarget)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to follow the symbolic link.\nLink: %s", path)
+	}
+	if !info.IsDir() {
+		err = CopyFile(linkTarget, target)
+		if err != nil {
+			return WrapErrorf(err, osCopyError, linkTarget, target)
+		}
+		return nil
+	}
+	linkParent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err == nil &&
+		(linkParent == linkTarget || isInDirectory(linkParent, linkTarget)) {
+		return WrappedErrorf(
+			"The symbolic link points to its own parent directory, so it "+
+				"can't be followed.\nLink: %s\nTarget: %s", path, linkTarget)
+	}
+	return copyDir(linkTarget, target, false, symlinkFollow)
+}
+
+// applyExportSymlinkPolicy handles the symbolic links in the packs in the tmp
+// directory before the export, using the policy. The links in the packs are
+// the links created by the filters, and the links copied from the source
+// folders with the "copy-as-link" policy. The followed links are resolved
+// from their paths in the tmp directory.
+func applyExportSymlinkPolicy(policy, dotRegolithPath string) error {
+	tmpPath := filepath.Join(dotRegolithPath, "tmp")
+	report := &symlinkReport{}
+	for _, pack := range tmpPackDirs(tmpPath) {
+		packPath := filepath.Join(tmpPath, pack)
+		if _, err := os.Stat(packPath); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(
+			packPath, func(p string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type()&os.ModeSymlink == 0 {
+					return nil
+				}
+				report.add(p)
+				switch policy {
+				case symlinkSkip:
+					err = os.Remove(p)
+					if err != nil {
+						return WrapErrorf(err, osRemoveError, p)
+					}
+				case symlinkError:
+					return copySymlink(p, "", policy)
+				case symlinkFollow:
+					copyPath := p + ".regolith-link"
+					err = copyLinkTarget(p, copyPath)
+					if err != nil {
+						return PassError(err)
+					}
+					err = os.Remove(p)
+					if err != nil {
+						return WrapErrorf(err, osRemoveError, p)
+					}
+					err = os.Rename(copyPath, p)
+					if err != nil {
+						return WrapErrorf(err, osRenameError, copyPath, p)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return WrapErrorf(err, osWalkError, packPath)
+		}
+	}
+	report.log(policy, tmpPath)
+	return nil
+}
@@ -0,0 +1,9 @@
+# An example filter. Regolith runs it in a temporary copy of the packs, so it
+# can freely change the files in the "BP" and "RP" folders. Replace it with
+# your own filters or install filters with "regolith install <filter>".
+import os
+
+path = "BP/functions/${NAMESPACE}"
+os.makedirs(path, exist_ok=True)
+with open(os.path.join(path, "hello.mcfunction"), "w") as f:
+    f.write("say Hello from ${PROJECT_NAME}!\n")
@@ -0,0 +1,189 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/otiai10/copy"
+)
+
+// The strategies of copying the source files to the tmp directory (see
+// SetupTmpFiles), selected with the "tmpSetup" property of the config.
+const (
+	// tmpSetupCopy copies the files. It's the default strategy.
+	tmpSetupCopy = "copy"
+	// tmpSetupHardlink creates hardlinks to the source files instead of
+	// copying them, which is much faster for large packs. The linked files
+	// share their contents with the source files, so the filters must replace
+	// the files (remove them and create new ones) instead of modifying them
+	// in place.
+	tmpSetupHardlink = "hardlink"
+)
+
+// tmpSetupStrategies are the valid values of the "tmpSetup" property.
+var tmpSetupStrategies = []string{tmpSetupCopy, tmpSetupHardlink}
+
+// copyDir copies the directory from the source to the target. The files are
+// copied in parallel (see copyPool) with CopyFile, so they're cloned on the
+// file systems that support it. If "link" is true, the files are hardlinks
+// to the source files instead (see tmpSetupHardlink), and only the files
+// that can't be linked, for example because the target is on a different
+// file system, are copied. The symbolic links are handled with the
+// "symlinks" policy (see copySymlink).
+func copyDir(source, target string, link bool, symlinks string) error {
+	var copied int32
+	links := &symlinkReport{}
+	pool := newCopyPool()
+	err := filepath.WalkDir(source, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(source, p)
+		if err != nil {
+			return WrapErrorf(err, osRelError, source, p)
+		}
+		targetPath := filepath.Join(target, relPath)
+		// The directories are created before their files are copied, so
+		// they're never created in parallel
+		if d.IsDir() {
+			err = os.MkdirAll(targetPath, 0755)
+			if err != nil {
+				return WrapErrorf(err, osMkdirError, targetPath)
+			}
+			return nil
+		}
+		// The followed links to the directories are copied by another
+		// copyDir, so they're handled outside of the pool
+		if d.Type()&os.ModeSymlink != 0 {
+			links.add(p)
+			return copySymlink(p, targetPath, symlinks)
+		}
+		pool.Go(func() error {
+			if !d.Type().IsRegular() { // Named pipes, etc.
+				err := copy.Copy(
+					p, targetPath,
+					copy.Options{PreserveTimes: false, Sync: false})
+				if err != nil {
+					return WrapErrorf(err, osCopyError, p, targetPath)
+				}
+				return nil
+			}
+			if link && os.Link(p, targetPath) == nil {
+				countFileIO(1, 0)
+				return nil
+			}
+			atomic.AddInt32(&copied, 1)
+			err := CopyFile(p, targetPath)
+			if err != nil {
+				return WrapErrorf(err, osCopyError, p, targetPath)
+			}
+			if info, err := d.Info(); err == nil {
+				os.Chmod(targetPath, info.Mode().Perm())
+			}
+			return nil
+		})
+		return nil
+	})
+	if poolErr := pool.Wait(); poolErr != nil {
+		err = poolErr
+	}
+	if err != nil {
+		return WrapErrorf(err, osWalkError, source)
+	}
+	links.log(symlinks, source)
+	if link && copied > 0 {
+		Logger.Debugf(
+			"Copied %d files from %q that couldn't be linked.", copied, source)
+	}
+	return nil
+}
+
+// writeTmpFile writes the data to the file in the tmp directory. The old
+// file is removed first, so if it's a hardlink to a source file (see
+// tmpSetupHardlink), the source file isn't changed.
+func writeTmpFile(path string, data []byte) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return WrapErrorf(err, osRemoveError, path)
+	}
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return WrapErrorf(err, fileWriteError, path)
+	}
+	return nil
+}
+
+// unlinkTmpFiles replaces the files of the packs in the tmp directory that
+// are still hardlinks (see tmpSetupHardlink) with their copies. The export
+// moves the files of the tmp directory to the export targets and then
+// changes them (the read-only files, the permissions of the target and the
+// times of the reproducible builds), so the files that the filters didn't
+// replace would change the source files too. It does nothing if the tmp
+// directory was set up by copying the files.
+func unlinkTmpFiles(dotRegolithPath string) error {
+	sources := currentTmpSources()
+	if sources == nil || !sources.linked {
+		return nil
+	}
+	var unlinked int32
+	pool := newCopyPool()
+	for folder := range sources.folders {
+		if folder == "data" { // The data folder is never linked
+			continue
+		}
+		path := filepath.Join(dotRegolithPath, "tmp", folder)
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			pool.Go(func() error {
+				info, err := d.Info()
+				if err != nil {
+					return WrapErrorf(err, osStatErrorAny, p)
+				}
+				if !isHardlinked(p, info) {
+					return nil
+				}
+				atomic.AddInt32(&unlinked, 1)
+				return unlinkFile(p, info)
+			})
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			pool.Wait()
+			return WrapErrorf(err, osWalkError, path)
+		}
+	}
+	err := pool.Wait()
+	if err != nil {
+		return PassError(err)
+	}
+	if unlinked > 0 {
+		Logger.Debugf(
+			"Replaced %d hardlinked files with their copies before the export.",
+			unlinked)
+	}
+	return nil
+}
+
+// unlinkFile replaces the hardlinked file with its copy, which keeps the
+// permissions of the file.
+func unlinkFile(path string, info os.FileInfo) error {
+	copyPath := path + ".regolith-unlink"
+	err := CopyFile(path, copyPath)
+	if err != nil {
+		os.Remove(copyPath)
+		return WrapErrorf(err, osCopyError, path, copyPath)
+	}
+	os.Chmod(copyPath, info.Mode().Perm())
+	err = os.Rename(copyPath, path)
+	if err != nil {
+		os.Remove(copyPath)
+		return WrapErrorf(err, osRenameError, copyPath, path)
+	}
+	return nil
+}
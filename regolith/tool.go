@@ -0,0 +1,221 @@
+package regolith
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// toolBackupsPath is the path to the directory with the backups of the
+// source files changed by the "regolith tool" command, relative to the
+// .regolith directory.
+const toolBackupsPath = "cache/tool_backups"
+
+// toolFolder is a source folder of the project changed by the "regolith
+// tool" command, and its directory in the tmp directory.
+type toolFolder struct {
+	Source string
+	TmpDir string
+}
+
+// Tool handles the "regolith tool" command. It runs a filter from the
+// filterDefinitions list of the config.json file directly on the source
+// files of the project (the RP, the BP, the additional packs and the data
+// folder), for one-off operations that aren't a part of any profile, like
+// renaming or sorting the files. The files are copied to the tmp directory,
+// the filter runs on them, and the result replaces the source files. The
+// original files are backed up to the toolBackupsPath directory first.
+//
+// The "args" parameter is a list of the arguments passed to the filter,
+// after its arguments from the filter definition.
+//
+// The "yes" parameter is a boolean that determines if the confirmation of
+// changing the source files should be skipped.
+//
+// The "debug" parameter is a boolean that determines if the debug messages
+// should be printed.
+func Tool(filterName string, args []string, yes, debug bool) error {
+	InitLogging(debug)
+	configJson, err := LoadConfigAsMap()
+	if err != nil {
+		return WrapError(err, "Could not load \"config.json\".")
+	}
+	config, err := ConfigFromObject(configJson)
+	if err != nil {
+		return WrapError(err, "Could not load \"config.json\".")
+	}
+	applyLogLevel(config.LogLevel)
+	dotRegolithPath, err := GetDotRegolith(
+		config.RegolithProject.UseAppData, false, ".")
+	if err != nil {
+		return WrapError(
+			err, "Unable to get the path to regolith cache folder.")
+	}
+	arguments := make([]interface{}, len(args))
+	for i, arg := range args {
+		arguments[i] = arg
+	}
+	filter, err := FilterRunnerFromObjectAndDefinitions(
+		map[string]interface{}{"filter": filterName, "arguments": arguments},
+		config.FilterDefinitions)
+	if err != nil {
+		return PassError(err)
+	}
+	path, _ := filepath.Abs(".")
+	context := RunContext{
+		AbsoluteLocation: path,
+		Config:           config,
+		DotRegolithPath:  dotRegolithPath,
+	}
+	err = filter.Check(context)
+	if err != nil {
+		return WrapErrorf(err, filterRunnerCheckError, filterName)
+	}
+	folders := listToolFolders(*config)
+	if len(folders) == 0 {
+		return WrappedError(
+			"The project doesn't have any source folders that the filter " +
+				"could change.")
+	}
+	if !yes {
+		fmt.Printf(
+			"The %q filter will change these folders of the project:\n",
+			filterName)
+		for _, folder := range folders {
+			fmt.Printf("  %s\n", folder.Source)
+		}
+		if !askYesNo("Continue?", bufio.NewReader(os.Stdin)) {
+			Logger.Info("The tool was cancelled. No files were changed.")
+			return nil
+		}
+	}
+	projectLock, err := acquireProjectLock(dotRegolithPath)
+	if err != nil {
+		return PassError(err)
+	}
+	defer projectLock.Release()
+	defer StopPersistentProcesses()
+	// Hardlinked files would let the filter change the source files before
+	// they're backed up
+	setupConfig := *config
+	setupConfig.TmpSetup = ""
+	err = SetupTmpFiles(setupConfig, Profile{}, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(err, setupTmpFilesError, dotRegolithPath)
+	}
+	backupPath := filepath.Join(
+		dotRegolithPath, toolBackupsPath,
+		time.Now().Format("2006-01-02_15-04-05"))
+	err = backupToolFolders(folders, backupPath)
+	if err != nil {
+		return WrapError(err, "Failed to back up the source files.")
+	}
+	Logger.Infof("Running filter %s", filterName)
+	_, err = filter.Run(context)
+	if err != nil {
+		return WrapErrorf(err, filterRunnerRunError, filterName)
+	}
+	err = applyToolResults(folders, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to replace the source files with the files "+
+				"changed by the filter.\n"+
+				"The original files are in %q.", backupPath)
+	}
+	Logger.Infof(
+		"The %q filter changed the source files. The original files are "+
+			"in %q.", filterName, backupPath)
+	return nil
+}
+
+// listToolFolders returns the source folders of the project that can be
+// changed by the "regolith tool" command. The folders that don't exist are
+// skipped.
+func listToolFolders(config Config) []toolFolder {
+	folders := []toolFolder{
+		{config.ResourceFolder, "RP"},
+		{config.BehaviorFolder, "BP"},
+	}
+	for _, pack := range listAdditionalPacks(config.AdditionalPacks) {
+		folders = append(folders, toolFolder{pack.Source, pack.TmpDir})
+	}
+	folders = append(folders, toolFolder{config.DataPath, "data"})
+	var result []toolFolder
+	for _, folder := range folders {
+		if folder.Source == "" {
+			continue
+		}
+		if stat, err := os.Stat(folder.Source); err != nil || !stat.IsDir() {
+			continue
+		}
+		result = append(result, folder)
+	}
+	return result
+}
+
+// backupToolFolders copies the source folders to the backup path, to the
+// subdirectories named after their directories in the tmp directory.
+func backupToolFolders(folders []toolFolder, backupPath string) error {
+	for _, folder := range folders {
+		target := filepath.Join(backupPath, folder.TmpDir)
+		Logger.Debugf("Backing up %q to %q.", folder.Source, target)
+		err := copyDir(folder.Source, target, false, symlinkCopyAsLink)
+		if err != nil {
+			return WrapErrorf(err, osCopyError, folder.Source, target)
+		}
+	}
+	return nil
+}
+
+// applyToolResults replaces the files of the source folders with the files
+// from the tmp directory. If it fails, the source folders are restored.
+func applyToolResults(folders []toolFolder, dotRegolithPath string) error {
+	revertibleOps, err := NewRevertableFsOperaitons(
+		filepath.Join(dotRegolithPath, ".toolBackup"))
+	if err != nil {
+		return WrapError(
+			err, "Failed to prepare backup path for revertable file "+
+				"system operations.")
+	}
+	for _, folder := range folders {
+		err = clearToolFolder(revertibleOps, folder.Source)
+		if err == nil {
+			err = revertibleOps.MoveoOrCopyDir(
+				filepath.Join(dotRegolithPath, "tmp", folder.TmpDir),
+				folder.Source)
+		}
+		if err != nil {
+			if err1 := revertibleOps.Undo(); err1 != nil {
+				Logger.Errorf(
+					"Failed to restore the source files.\n%s",
+					PassError(err1).Error())
+				return PassError(err)
+			}
+			revertibleOps.Close()
+			return WrapErrorf(
+				err, "Failed to replace the files.\nPath: %s", folder.Source)
+		}
+	}
+	if err := revertibleOps.Close(); err != nil {
+		return PassError(err)
+	}
+	return nil
+}
+
+// clearToolFolder removes the files from the source folder. The folder
+// itself is kept, so the watchers of the folder keep working.
+func clearToolFolder(r *RevertableFsOperations, path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return WrapErrorf(err, "Failed to list the files.\nPath: %s", path)
+	}
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+		if err := r.DeleteDir(entryPath); err != nil {
+			return WrapErrorf(err, osRemoveError, entryPath)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Functions used for the "regolith update --interactive" command
+package regolith
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// filterUpdate is a newer version of a remote filter found by
+// findFilterUpdates.
+type filterUpdate struct {
+	name   string
+	filter *RemoteFilterDefinition
+	// current is the installed (or locked) version of the filter
+	current string
+	// candidate is the newest version of the filter allowed by its
+	// definition, a semver version or a commit SHA
+	candidate string
+}
+
+// findFilterUpdates returns the remote filters from the list that have newer
+// versions, sorted by their names. The filters with pinned versions (semver
+// versions and commit SHAs) are compared with the newest version tags or
+// commits of their repositories, and the others with the newest versions
+// allowed by their "version" properties.
+func findFilterUpdates(
+	filterDefinitions map[string]FilterInstaller, dotRegolithPath string,
+	lockFile *LockFile,
+) ([]filterUpdate, error) {
+	names := make([]string, 0, len(filterDefinitions))
+	for name := range filterDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []filterUpdate
+	for _, name := range names {
+		remoteFilter, ok := filterDefinitions[name].(*RemoteFilterDefinition)
+		if !ok || remoteFilter.IsLinked(dotRegolithPath) ||
+			remoteFilter.IsArchive() {
+			continue
+		}
+		Logger.Infof("Checking %q filter for updates...", name)
+		current, ok := lockFile.Resolved(name, remoteFilter)
+		if !ok {
+			installedVersion, err := remoteFilter.InstalledVersion(
+				dotRegolithPath)
+			if err != nil {
+				Logger.Warnf(
+					"Filter %q is not installed and therefore cannot be "+
+						"updated.", name)
+				continue
+			}
+			current = trimFilterPrefix(installedVersion, name)
+		}
+		candidate, err := filterUpdateCandidate(remoteFilter)
+		if err != nil {
+			return nil, WrapErrorf(
+				err, "Failed to find the newest version of the filter.\n"+
+					"Filter: %s", name)
+		}
+		if candidate == current {
+			continue
+		}
+		if semver.IsValid("v"+current) && semver.IsValid("v"+candidate) &&
+			semver.Compare("v"+candidate, "v"+current) < 0 {
+			continue
+		}
+		result = append(result, filterUpdate{
+			name:      name,
+			filter:    remoteFilter,
+			current:   current,
+			candidate: candidate,
+		})
+	}
+	return result, nil
+}
+
+// filterUpdateCandidate returns the newest version of the remote filter that
+// can replace the installed version.
+func filterUpdateCandidate(filter *RemoteFilterDefinition) (string, error) {
+	version := filter.Version
+	switch {
+	case version == "latest" || semver.IsValid("v"+version):
+		tag, err := GetLatestRemoteFilterTag(filter.Url, filter.Id)
+		if err != nil {
+			return "", PassError(err)
+		}
+		return trimFilterPrefix(tag, filter.Id), nil
+	case isVersionRange(version):
+		tag, err := GetMatchingRemoteFilterTag(filter.Url, filter.Id, version)
+		if err != nil {
+			return "", PassError(err)
+		}
+		return trimFilterPrefix(tag, filter.Id), nil
+	case version == "HEAD" || isCommitSha(version):
+		return GetHeadSha(filter.Url, filter.Id)
+	default: // Branch or tag
+		sha := resolveFilterCommit(filter.Url, filter.Id, version)
+		if !isCommitSha(sha) {
+			return "", WrappedErrorf(
+				"Unable to resolve the version to a commit.\nVersion: %s",
+				version)
+		}
+		return sha, nil
+	}
+}
+
+// filterVersionRef returns the git reference of a version of the remote
+// filter (the version tag for the semver versions).
+func filterVersionRef(name, version string) string {
+	if semver.IsValid("v" + version) {
+		return name + "-" + version
+	}
+	return version
+}
+
+// filterChangelog returns the commits that changed the files of the filter
+// between two versions, starting with the newest commit. The repositories
+// are cloned (without the file contents) to the temporary directories from
+// the clones map, which is shared between the calls to avoid cloning the
+// same repository multiple times.
+func filterChangelog(
+	url, name, from, to string, clones map[string]string,
+) ([]string, error) {
+	clonePath, ok := clones[url]
+	if !ok {
+		var err error
+		clonePath, err = os.MkdirTemp("", "regolith-changelog-")
+		if err != nil {
+			return nil, WrapError(
+				err, "Failed to create a temporary directory.")
+		}
+		clones[url] = clonePath
+		err = tryMirrors(url, func(url string) error {
+			_, err := runGitCommand(
+				url, "clone", "--quiet", "--bare", "--filter=blob:none",
+				remoteFilterGitUrl(url), clonePath)
+			return err
+		})
+		if err != nil {
+			return nil, PassError(err)
+		}
+	}
+	output, err := runGitCommand(
+		url, "-C", clonePath, "log", "--format=%h %s",
+		filterVersionRef(name, from)+".."+filterVersionRef(name, to),
+		"--", name)
+	if err != nil {
+		return nil, PassError(err)
+	}
+	var result []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+// askYesNo prints the question and reads the answer from the reader. Only
+// "y" and "yes" (case insensitive) are accepted as "yes".
+func askYesNo(question string, reader *bufio.Reader) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// selectFilterUpdates prints the changelogs of the filter updates and asks
+// which of them should be applied.
+func selectFilterUpdates(updates []filterUpdate) []filterUpdate {
+	clones := make(map[string]string)
+	defer func() {
+		for _, clonePath := range clones {
+			os.RemoveAll(clonePath)
+		}
+	}()
+	reader := bufio.NewReader(os.Stdin)
+	var result []filterUpdate
+	for _, update := range updates {
+		Logger.Infof(
+			"Filter %q: %s -> %s", update.name, update.current,
+			update.candidate)
+		changelog, err := filterChangelog(
+			update.filter.Url, update.name, update.current, update.candidate,
+			clones)
+		if err != nil {
+			Logger.Warnf("Unable to get the changelog of the filter:\n%s", err)
+		} else if len(changelog) == 0 {
+			Logger.Info("\tNo commits changed the files of the filter.")
+		} else {
+			for _, commit := range changelog {
+				Logger.Infof("\t%s", commit)
+			}
+		}
+		if askYesNo(fmt.Sprintf("Update %q?", update.name), reader) {
+			result = append(result, update)
+		}
+	}
+	return result
+}
+
+// applyFilterUpdate installs the new version of the remote filter and locks
+// it. The pinned versions in the filter definitions from the config map are
+// replaced with the new version.
+func applyFilterUpdate(
+	update filterUpdate, filterDefinitions map[string]interface{},
+	dotRegolithPath string, lockFile *LockFile,
+) error {
+	filter := update.filter
+	if semver.IsValid("v"+filter.Version) || isCommitSha(filter.Version) {
+		filter.Version = update.candidate
+		if definition, ok := filterDefinitions[update.name].(map[string]interface{}); ok {
+			definition["version"] = update.candidate
+		}
+	}
+	filter.resolvedVersion = update.candidate
+	err := filter.Download(true, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(err, remoteFilterDownloadError, update.name)
+	}
+	err = filter.InstallDependencies(filter, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to install dependencies of the filter.\nFilter: %s.",
+			update.name)
+	}
+	err = filter.saveToSharedCache(dotRegolithPath)
+	if err != nil {
+		Logger.Warnf(
+			"Failed to save the filter in the shared filter cache:\n%s", err)
+	}
+	err = lockFile.Lock(update.name, filter, dotRegolithPath)
+	if err != nil {
+		return WrapErrorf(
+			err, "Failed to lock the version of the filter.\nFilter: %s",
+			update.name)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package regolith
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// userConfigPath is a path to the user config file relative to the
+// "regolith" directory in the user config directory (os.UserConfigDir). The
+// user config contains the settings shared by all of the projects of the
+// user. The older versions of Regolith used the GetRegolithConfigPath()
+// directory, which is still used if the file exists only there.
+const userConfigPath = "user_config.json"
+
+// UserConfig is the content of the user config file.
+type UserConfig struct {
+	// Registries is a list of URLs to the indices of the filter registries,
+	// used for resolving the names of the filters to URLs. The registries
+	// are checked in order. If the list is empty, the default registry
+	// (resolverUrl) is used.
+	Registries []string `json:"registries,omitempty"`
+	// Proxy is the URL of the HTTP(S) or SOCKS5 proxy used for the
+	// downloads (see ApplyUserProxy).
+	Proxy string `json:"proxy,omitempty"`
+	// Mirrors maps the prefixes of the URLs of the filter repositories and
+	// the filter registries to the prefixes of their mirrors (see
+	// mirrorUrls).
+	Mirrors map[string][]string `json:"mirrors,omitempty"`
+	// NodePackageManager is the package manager used for installing the
+	// dependencies of the Node.js filters that don't select one (see
+	// NodeJSFilterDefinition.PackageManager).
+	NodePackageManager string `json:"nodePackageManager,omitempty"`
+	// LogLevel is the default level of the logger, used when config.json
+	// doesn't set the "logLevel" (see applyLogLevel).
+	LogLevel string `json:"logLevel,omitempty"`
+	// CacheDir is the absolute path to the directory used instead of the
+	// "regolith" directory in the user cache directory (see
+	// regolithCacheDir).
+	CacheDir string `json:"cacheDir,omitempty"`
+	// ProjectDefaults are the default values of config.json, used by all of
+	// the projects that don't set them (see mergeUserProjectDefaults).
+	ProjectDefaults map[string]interface{} `json:"projectDefaults,omitempty"`
+	// Templates maps the names of the project templates to their URLs (see
+	// resolveTemplate).
+	Templates map[string]string `json:"templates,omitempty"`
+	// InitTemplate is the template used by "regolith init" without the
+	// "--template" flag.
+	InitTemplate string `json:"initTemplate,omitempty"`
+}
+
+// GetUserConfigPath returns the path to the user config file. The file is in
+// the "regolith" directory of the user config directory, unless it exists
+// only in the directory used by the older versions of Regolith.
+func GetUserConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", WrapError(err, "Failed to get the user config directory.")
+	}
+	path := filepath.Join(configDir, regolithConfigPath, userConfigPath)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	legacyDir, err := GetRegolithConfigPath()
+	if err != nil {
+		return path, nil
+	}
+	legacyPath := filepath.Join(legacyDir, userConfigPath)
+	if _, err := os.Stat(legacyPath); err == nil {
+		return legacyPath, nil
+	}
+	return path, nil
+}
+
+// LoadUserConfig loads the user config. If the file doesn't exist, it returns
+// an empty config.
+func LoadUserConfig() (UserConfig, error) {
+	result := UserConfig{}
+	path, err := GetUserConfigPath()
+	if err != nil {
+		return result, PassError(err)
+	}
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, WrapErrorf(err, fileReadError, path)
+	}
+	var obj map[string]interface{}
+	err = unmarshalJsonc(file, &obj)
+	if err != nil {
+		return result, WrapErrorf(err, jsonUnmarshalError, path)
+	}
+	result, err = UserConfigFromObject(obj)
+	if err != nil {
+		return result, WrapErrorf(
+			err, "Failed to parse the user config.\nPath: %s", path)
+	}
+	return result, nil
+}
+
+func UserConfigFromObject(obj map[string]interface{}) (UserConfig, error) {
+	result := UserConfig{}
+	// Registries - can be empty
+	if registriesObj, ok := obj["registries"]; ok {
+		registries, ok := registriesObj.([]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "registries", "array")
+		}
+		for i, registryObj := range registries {
+			registry, ok := registryObj.(string)
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError, fmt.Sprintf("registries->%d", i),
+					"string")
+			}
+			result.Registries = append(result.Registries, registry)
+		}
+	}
+	// Proxy - can be empty
+	if proxyObj, ok := obj["proxy"]; ok {
+		proxy, ok := proxyObj.(string)
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "proxy", "string")
+		}
+		result.Proxy = proxy
+	}
+	// Mirrors - can be empty
+	if mirrorsObj, ok := obj["mirrors"]; ok {
+		mirrors, ok := mirrorsObj.(map[string]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "mirrors", "object")
+		}
+		result.Mirrors = make(map[string][]string)
+		for prefix, prefixMirrorsObj := range mirrors {
+			prefixMirrors, ok := prefixMirrorsObj.([]interface{})
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError, "mirrors->"+prefix, "array")
+			}
+			for i, mirrorObj := range prefixMirrors {
+				mirror, ok := mirrorObj.(string)
+				if !ok {
+					return result, WrappedErrorf(
+						jsonPropertyTypeError,
+						fmt.Sprintf("mirrors->%s->%d", prefix, i), "string")
+				}
+				result.Mirrors[prefix] = append(result.Mirrors[prefix], mirror)
+			}
+		}
+	}
+	// NodePackageManager - can be empty
+	if packageManagerObj, ok := obj["nodePackageManager"]; ok {
+		packageManager, ok := packageManagerObj.(string)
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "nodePackageManager", "string")
+		}
+		err := checkNodePackageManager(packageManager)
+		if err != nil {
+			return result, PassError(err)
+		}
+		result.NodePackageManager = packageManager
+	}
+	// LogLevel - can be empty
+	if logLevelObj, ok := obj["logLevel"]; ok {
+		logLevel, ok := logLevelObj.(string)
+		if !ok || !stringInSlice(logLevel, logLevels) {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "logLevel",
+				strings.Join(logLevels, ", "))
+		}
+		result.LogLevel = logLevel
+	}
+	// CacheDir - can be empty
+	if cacheDirObj, ok := obj["cacheDir"]; ok {
+		cacheDir, ok := cacheDirObj.(string)
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "cacheDir", "string")
+		}
+		if !filepath.IsAbs(cacheDir) {
+			return result, WrappedErrorf(
+				"The cache directory must be an absolute path.\n"+
+					"Path: %s", cacheDir)
+		}
+		result.CacheDir = cacheDir
+	}
+	// ProjectDefaults - can be empty
+	if projectDefaultsObj, ok := obj["projectDefaults"]; ok {
+		projectDefaults, ok := projectDefaultsObj.(map[string]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "projectDefaults", "object")
+		}
+		result.ProjectDefaults = projectDefaults
+	}
+	// Templates - can be empty
+	if templatesObj, ok := obj["templates"]; ok {
+		templates, ok := templatesObj.(map[string]interface{})
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "templates", "object")
+		}
+		result.Templates = make(map[string]string, len(templates))
+		for name, urlObj := range templates {
+			url, ok := urlObj.(string)
+			if !ok {
+				return result, WrappedErrorf(
+					jsonPropertyTypeError, "templates->"+name, "string")
+			}
+			result.Templates[name] = url
+		}
+	}
+	// InitTemplate - can be empty
+	if initTemplateObj, ok := obj["initTemplate"]; ok {
+		initTemplate, ok := initTemplateObj.(string)
+		if !ok {
+			return result, WrappedErrorf(
+				jsonPropertyTypeError, "initTemplate", "string")
+		}
+		result.InitTemplate = initTemplate
+	}
+	return result, nil
+}
+
+// regolithCacheDir returns the path to the directory with the caches shared
+// by the projects: the caches of the projects that use the "useAppData"
+// option (appDataCachePath) and the shared filter cache
+// (sharedFilterCachePath). It's the "regolith" directory in the user cache
+// directory, unless the user config sets the "cacheDir".
+func regolithCacheDir() (string, error) {
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		return "", WrapError(err, "Failed to load the user config.")
+	}
+	if userConfig.CacheDir != "" {
+		return userConfig.CacheDir, nil
+	}
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", WrappedError(osUserCacheDirError)
+	}
+	return filepath.Join(userCache, regolithConfigPath), nil
+}
+
+// mergeUserProjectDefaults returns the config.json file (parsed to a map)
+// merged over the "projectDefaults" from the user config (see
+// mergeConfigValues), so the values from config.json have priority.
+func mergeUserProjectDefaults(
+	config map[string]interface{},
+) (map[string]interface{}, error) {
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		return nil, WrapError(err, "Failed to load the user config.")
+	}
+	if len(userConfig.ProjectDefaults) == 0 {
+		return config, nil
+	}
+	merged, _ := mergeConfigValues(
+		userConfig.ProjectDefaults, config, nil).(map[string]interface{})
+	return merged, nil
+}
+
+// GetRegistryUrls returns the URLs of the filter registries from the user
+// config, or the URL of the default registry if the user config doesn't list
+// any registries.
+func GetRegistryUrls() ([]string, error) {
+	userConfig, err := LoadUserConfig()
+	if err != nil {
+		return nil, WrapError(err, "Failed to load the user config.")
+	}
+	if len(userConfig.Registries) == 0 {
+		return []string{resolverUrl}, nil
+	}
+	return userConfig.Registries, nil
+}
@@ -17,12 +17,18 @@ import (
 	"github.com/fatih/color"
 )
 
-// appDataCachePath is a path to the cache directory relative to the user's
-// app data
-const appDataCachePath = "regolith/project-cache"
+// appDataCachePath is a path to the cache directory of the projects that use
+// the "useAppData" option, relative to regolithCacheDir()
+const appDataCachePath = "project-cache"
 
 var Debug = false
 
+// Verbosity is the amount of the messages printed by Regolith, set by the
+// "--quiet" (-1), "-v" (1) and "-vv" (2) flags. The default level 0 prints
+// the informational messages, the level 1 adds the debug messages and the
+// level 2 adds the messages about every file copied, moved or removed.
+var Verbosity = 0
+
 func StringArrayContains(arr []string, str string) bool {
 	for _, a := range arr {
 		if a == str {
@@ -63,7 +69,8 @@ func firstErr(errors ...error) error {
 }
 
 // wrapErrorStackTrace is used by other wrapped error functions to add a stack
-// trace to the error message.
+// trace to the error message. The other functions add the codes of the error
+// constants to the messages (see errorCodes).
 func wrapErrorStackTrace(err error, text string) error {
 	text = strings.Replace(text, "\n", color.YellowString("\n   >> "), -1)
 
@@ -122,25 +129,25 @@ func NotImplementedError(text string) error {
 
 // WrappedError creates an error with a stack trace from text.
 func WrappedError(text string) error {
-	return wrapErrorStackTrace(nil, text)
+	return wrapErrorStackTrace(nil, withErrorCode(text))
 }
 
 // WrappedErrorf creates an error with a stack trace from formatted text.
 func WrappedErrorf(text string, args ...interface{}) error {
-	text = fmt.Sprintf(text, args...)
+	text = fmt.Sprintf(withErrorCode(text), args...)
 	return wrapErrorStackTrace(nil, text)
 }
 
 // WrapError wraps an error with a stack trace and adds additional text
 // information.
 func WrapError(err error, text string) error {
-	return wrapErrorStackTrace(err, text)
+	return wrapErrorStackTrace(err, withErrorCode(text))
 }
 
 // WrapErrorf wraps an error with a stack trace and adds additional formatted
 // text information.
 func WrapErrorf(err error, text string, args ...interface{}) error {
-	return wrapErrorStackTrace(err, fmt.Sprintf(text, args...))
+	return wrapErrorStackTrace(err, fmt.Sprintf(withErrorCode(text), args...))
 }
 
 func CreateDirectoryIfNotExists(directory string, mustSucceed bool) error {
@@ -167,25 +174,50 @@ func GetAbsoluteWorkingDirectory(dotRegolithPath string) string {
 	return absoluteWorkingDir
 }
 
+// isInDirectory returns true if the absolute path is inside of the absolute
+// path of the directory.
+func isInDirectory(path, directory string) bool {
+	relPath, err := filepath.Rel(directory, path)
+	return err == nil && relPath != "." && relPath != ".." &&
+		!strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
 // CreateEnvironmentVariables creates an array of environment variables including custom ones
 func CreateEnvironmentVariables(filterDir string) ([]string, error) {
 	projectDir, err := os.Getwd()
 	if err != nil {
 		return nil, WrapErrorf(err, osGetwdError)
 	}
-	return append(os.Environ(), fmt.Sprintf("FILTER_DIR=%s", filterDir), fmt.Sprintf("ROOT_DIR=%s", projectDir), fmt.Sprintf("DEBUG=%t", Debug)), nil
+	env := append(os.Environ(), fmt.Sprintf("FILTER_DIR=%s", filterDir), fmt.Sprintf("ROOT_DIR=%s", projectDir), fmt.Sprintf("DEBUG=%t", Debug))
+	if changedFilesFile != "" {
+		env = append(env, fmt.Sprintf("%s=%s", changedFilesEnv, changedFilesFile))
+	}
+	return env, nil
 }
 
 // RunSubProcess runs a sub-process with specified arguments and working
 // directory
 func RunSubProcess(command string, args []string, filterDir string, workingDir string, outputLabel string) error {
-	Logger.Debugf("Exec: %s %s", command, strings.Join(args, " "))
+	return runFilterSubProcess(
+		RunContext{}, command, args, filterDir, workingDir, outputLabel)
+}
+
+// runFilterSubProcess runs a sub-process of the filter that runs in the
+// context, like RunSubProcess. The process belongs to the execution of the
+// filter (see RunContext.processOwner), so it's killed if the filter exceeds
+// its timeout.
+func runFilterSubProcess(
+	context RunContext, command string, args []string,
+	filterDir, workingDir, outputLabel string,
+) error {
+	Logger.Debugf(
+		"Exec: %s %s", command, hideSecrets(strings.Join(args, " ")))
 	cmd := exec.Command(command, args...)
 	cmd.Dir = workingDir
 	out, _ := cmd.StdoutPipe()
 	err, _ := cmd.StderrPipe()
-	go LogStd(out, Logger.Infof, outputLabel)
-	go LogStd(err, Logger.Errorf, outputLabel)
+	go LogStd(out, outputLogger(outputLabel).Infof, outputLabel)
+	go LogStd(err, outputLogger(outputLabel).Errorf, outputLabel)
 	env, err1 := CreateEnvironmentVariables(filterDir)
 	if err1 != nil {
 		return WrapErrorf(
@@ -194,13 +226,19 @@ func RunSubProcess(command string, args []string, filterDir string, workingDir s
 	}
 	cmd.Env = env
 
-	return cmd.Run()
+	if err := startTrackedSubProcess(cmd, context.processOwner); err != nil {
+		return err
+	}
+	defer untrackSubProcess(cmd)
+	return cmd.Wait()
 }
 
 func LogStd(in io.ReadCloser, logFunc func(template string, args ...interface{}), outputLabel string) {
 	scanner := bufio.NewScanner(in)
 	for scanner.Scan() {
-		logFunc("[%s] %s", outputLabel, scanner.Text())
+		line := mapTmpPaths(scanner.Text())
+		captureFilterOutput(outputLabel, line)
+		logFunc("[%s] %s", outputLabel, line)
 	}
 }
 
@@ -215,12 +253,13 @@ func LogStd(in io.ReadCloser, logFunc func(template string, args ...interface{})
 func GetDotRegolith(useAppData, silent bool, projectRoot string) (string, error) {
 	// App data diabled - use .regolith
 	if !useAppData {
+		recordKnownProject(projectRoot, ".regolith")
 		return ".regolith", nil
 	}
 	// App data enabled - use user cache dir
-	userCache, err := os.UserCacheDir()
+	userCache, err := regolithCacheDir()
 	if err != nil {
-		return "", WrappedError(osUserCacheDirError)
+		return "", PassError(err)
 	}
 	// Make sure that projectsRoot is an absolute path
 	absoluteProjectRoot, err := filepath.Abs(projectRoot)
@@ -240,5 +279,6 @@ func GetDotRegolith(useAppData, silent bool, projectRoot string) (string, error)
 			"Regolith project cache is in:\n\t%s",
 			dotRegolithPath)
 	}
+	recordKnownProject(projectRoot, dotRegolithPath)
 	return dotRegolithPath, nil
 }
@@ -2,7 +2,10 @@ package regolith
 
 import (
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 )
 
 type World struct {
@@ -53,3 +56,45 @@ func ListWorlds(mojangDir string) ([]*World, error) {
 	}
 	return result, nil
 }
+
+// androidMojangDirs are the paths to the com.mojang folder relative to the
+// internal storage of an Android device. The newer versions of Minecraft
+// store the files in the app's directory, the older ones in the "games"
+// folder.
+var androidMojangDirs = []string{
+	"Android/data/com.mojang.minecraftpe/files/games/com.mojang",
+	"games/com.mojang",
+}
+
+// FindAndroidMojangDir returns the path to the com.mojang folder on an
+// Android device, whose internal storage is mounted at the mount path (for
+// example with MTP).
+func FindAndroidMojangDir(mountPath string) (string, error) {
+	if mountPath == "" {
+		return "", WrappedError(
+			"The \"android\" build requires the \"mountPath\" property " +
+				"with the path to the mounted storage of the device.")
+	}
+	var candidates []string
+	for _, dir := range androidMojangDirs {
+		candidate := filepath.Join(mountPath, filepath.FromSlash(dir))
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+		candidates = append(candidates, candidate)
+	}
+	return "", WrappedErrorf(
+		"Failed to find the \"com.mojang\" folder on the Android device.\n"+
+			"Checked paths:\n%s\n"+
+			"Make sure that the device is mounted and that Minecraft was "+
+			"started at least once.", strings.Join(candidates, "\n"))
+}
+
+// findMojangDirOfTarget returns the path to the com.mojang folder of the
+// build of Minecraft selected by the export target.
+func findMojangDirOfTarget(exportTarget ExportTarget) (string, error) {
+	if exportTarget.Build == "android" {
+		return FindAndroidMojangDir(exportTarget.MountPath)
+	}
+	return FindMojangDirOfBuild(exportTarget.Build)
+}
@@ -0,0 +1,217 @@
+package regolith
+
+import (
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// variablePattern matches the "${NAME}" and "${prefix:NAME}" placeholders in
+// the arguments and settings of the filters.
+var variablePattern = regexp.MustCompile(
+	`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// ExpandVariables replaces the placeholders in the text with the values of
+// the variables. Supported placeholders are:
+//   - ${PROJECT_NAME} - the name of the project from config.json,
+//   - ${PROJECT_AUTHOR} - the author of the project from config.json,
+//   - ${PROFILE} - the name of the profile that runs the filter,
+//   - ${OS} - the name of the operating system (like "windows" or "linux"),
+//   - ${ARCH} - the architecture of the processor (like "amd64"),
+//   - ${env:NAME} - the value of an environment variable (see
+//     expandEnvVariable),
+//   - ${define:NAME} - the value of a define passed with "--define" flag,
+//   - ${secret:NAME} - the value of a secret from the credential store (see
+//     lookupSecret).
+//
+// Unknown placeholders are left unchanged. The secrets that can't be
+// resolved are replaced with empty strings, with a warning.
+func ExpandVariables(text string, context RunContext) string {
+	result, err := expandVariablesOrError(text, context)
+	if err != nil {
+		Logger.Warnf("Failed to resolve a secret:\n%s", err)
+	}
+	return result
+}
+
+// expandVariablesOrError is ExpandVariables that returns an error if any of
+// the secrets can't be resolved (see lookupSecret). It's used for the
+// filters, which shouldn't run with the missing secrets.
+func expandVariablesOrError(
+	text string, context RunContext,
+) (string, error) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+	var err error
+	result := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		name, argument := groups[1], groups[2]
+		if strings.Contains(match, ":") {
+			switch name {
+			case "env":
+				value, _ := expandEnvVariable(argument)
+				return value
+			case "define":
+				return context.Defines[argument]
+			case "secret":
+				value, lookupErr := lookupSecret(argument)
+				if lookupErr != nil && err == nil {
+					err = lookupErr
+				}
+				return value
+			}
+			return match
+		}
+		switch name {
+		case "PROJECT_NAME":
+			if context.Config != nil {
+				return context.Config.Name
+			}
+		case "PROJECT_AUTHOR":
+			if context.Config != nil {
+				return context.Config.Author
+			}
+		case "PROFILE":
+			return context.Profile
+		case "OS":
+			return runtime.GOOS
+		case "ARCH":
+			return runtime.GOARCH
+		}
+		return match
+	})
+	if err != nil {
+		return result, PassError(err)
+	}
+	return result, nil
+}
+
+// expandEnvVariable returns the value of the environment variable from the
+// "${env:NAME}" placeholder. The argument can specify a default value, used
+// when the variable is not set or empty, like "NAME:-default". The second
+// value is false if the variable is not set and there is no default.
+func expandEnvVariable(argument string) (string, bool) {
+	name, defaultValue, hasDefault := strings.Cut(argument, ":-")
+	value, ok := os.LookupEnv(name)
+	if ok && (value != "" || !hasDefault) {
+		return value, true
+	}
+	return defaultValue, hasDefault
+}
+
+// expandEnvVariables replaces only the "${env:NAME}" placeholders in the
+// text, leaving the other placeholders for ExpandVariables. It's used when
+// the config is loaded, so the environment variables can be used in all of
+// the values of config.json, like the paths of the export targets. The
+// variables that are not set and don't have default values are replaced
+// with empty strings.
+func expandEnvVariables(text string) string {
+	if !strings.Contains(text, "${env:") {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		name, argument := groups[1], groups[2]
+		if name != "env" || !strings.Contains(match, ":") {
+			return match
+		}
+		value, ok := expandEnvVariable(argument)
+		if !ok {
+			Logger.Warnf(
+				"The environment variable used in config.json is not set.\n"+
+					"Variable: %s\n"+
+					"Set the variable or add a default value, like "+
+					"\"${env:%s:-default}\".", argument, argument)
+		}
+		return value
+	})
+}
+
+// mapStringsInValue returns a copy of a value decoded from JSON with all of
+// its strings replaced by the result of the function. The keys of the
+// objects are not changed.
+func mapStringsInValue(
+	value interface{}, f func(string) string,
+) interface{} {
+	switch value := value.(type) {
+	case string:
+		return f(value)
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = mapStringsInValue(item, f)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = mapStringsInValue(v, f)
+		}
+		return result
+	}
+	return value
+}
+
+// expandVariablesInValue returns a copy of a value decoded from JSON with
+// the placeholders in all of its strings replaced by ExpandVariables. It
+// returns an error if any of the secrets can't be resolved.
+func expandVariablesInValue(
+	value interface{}, context RunContext,
+) (interface{}, error) {
+	var err error
+	result := mapStringsInValue(value, func(text string) string {
+		expanded, expandErr := expandVariablesOrError(text, context)
+		if expandErr != nil && err == nil {
+			err = expandErr
+		}
+		return expanded
+	})
+	if err != nil {
+		return nil, PassError(err)
+	}
+	return result, nil
+}
+
+// expandEnvVariablesInConfig returns a copy of the config.json file (parsed
+// to a map) with the "${env:NAME}" placeholders replaced by
+// expandEnvVariables.
+func expandEnvVariablesInConfig(
+	config map[string]interface{},
+) map[string]interface{} {
+	return mapStringsInValue(
+		config, expandEnvVariables).(map[string]interface{})
+}
+
+// expandVariables returns the settings and arguments of the filter with the
+// placeholders replaced by ExpandVariables. The filter itself is not
+// modified, so the variables are expanded again on every run. It returns an
+// error if any of the secrets can't be resolved, so the filter doesn't run
+// with the empty values.
+func (f *Filter) expandVariables(
+	context RunContext,
+) (map[string]interface{}, []string, error) {
+	var settings map[string]interface{}
+	if f.Settings != nil {
+		expanded, err := expandVariablesInValue(f.Settings, context)
+		if err != nil {
+			return nil, nil, WrapError(
+				err, "Failed to resolve the settings of the filter.")
+		}
+		settings = expanded.(map[string]interface{})
+	}
+	var arguments []string
+	if f.Arguments != nil {
+		arguments = make([]string, len(f.Arguments))
+		for i, arg := range f.Arguments {
+			expanded, err := expandVariablesOrError(arg, context)
+			if err != nil {
+				return nil, nil, WrapError(
+					err, "Failed to resolve the arguments of the filter.")
+			}
+			arguments[i] = expanded
+		}
+	}
+	return settings, arguments, nil
+}
@@ -0,0 +1,117 @@
+package regolith
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// versionRange is a range of the semantic versions of a filter, created from
+// a version like "^1.2.0" or "~2.1". The versions use the "v" prefix required
+// by the semver package.
+type versionRange struct {
+	min string // Inclusive
+	max string // Exclusive
+}
+
+// isVersionRange returns true if the version of a filter is a range of
+// versions rather than an exact version.
+func isVersionRange(version string) bool {
+	return strings.HasPrefix(version, "^") || strings.HasPrefix(version, "~")
+}
+
+// parseVersionRange parses a range of versions. The ranges work like in npm:
+//   - "^1.2.3" allows the changes that don't modify the left-most non-zero
+//     number (">=1.2.3 <2.0.0", "^0.2.3" is ">=0.2.3 <0.3.0").
+//   - "~1.2.3" allows the patch-level changes (">=1.2.3 <1.3.0"), "~1" allows
+//     the minor-level changes (">=1.0.0 <2.0.0").
+//
+// The missing numbers of the version are zeros ("^1.2" is the same as
+// "^1.2.0").
+func parseVersionRange(version string) (versionRange, error) {
+	invalid := WrappedErrorf(
+		"Invalid version range.\nVersion: %s\n"+
+			"The version ranges start with \"^\" or \"~\" followed by 1 to 3 "+
+			"numbers separated by dots, for example \"^1.2.0\" or \"~2.1\".",
+		version)
+	if !isVersionRange(version) {
+		return versionRange{}, invalid
+	}
+	parts := strings.Split(version[1:], ".")
+	if len(parts) > 3 {
+		return versionRange{}, invalid
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return versionRange{}, invalid
+		}
+		numbers[i] = number
+	}
+	major, minor, patch := numbers[0], numbers[1], numbers[2]
+	result := versionRange{
+		min: fmt.Sprintf("v%d.%d.%d", major, minor, patch)}
+	switch {
+	case version[0] == '~' && len(parts) == 1,
+		version[0] == '^' && (major > 0 || len(parts) == 1):
+		result.max = fmt.Sprintf("v%d.0.0", major+1)
+	case version[0] == '~',
+		version[0] == '^' && (minor > 0 || len(parts) == 2):
+		result.max = fmt.Sprintf("v%d.%d.0", major, minor+1)
+	default:
+		result.max = fmt.Sprintf("v%d.%d.%d", major, minor, patch+1)
+	}
+	return result, nil
+}
+
+// Contains returns true if the version (without the "v" prefix) is in the
+// range. The pre-release versions are never in the range.
+func (r versionRange) Contains(version string) bool {
+	v := "v" + version
+	return semver.IsValid(v) && semver.Prerelease(v) == "" &&
+		semver.Compare(v, r.min) >= 0 && semver.Compare(v, r.max) < 0
+}
+
+// VersionInRange returns true if the version (without the "v" prefix) is in
+// the range of versions (see parseVersionRange). It returns an error if the
+// range is invalid.
+func VersionInRange(version, versionRange string) (bool, error) {
+	r, err := parseVersionRange(versionRange)
+	if err != nil {
+		return false, PassError(err)
+	}
+	return r.Contains(version), nil
+}
+
+// GetMatchingRemoteFilterTag returns the tag of the newest version of the
+// remote filter that matches the range of versions (see parseVersionRange).
+func GetMatchingRemoteFilterTag(url, name, version string) (string, error) {
+	versions, err := parseVersionRange(version)
+	if err != nil {
+		return "", PassError(err)
+	}
+	tags, err := ListRemoteFilterTags(url, name)
+	if err != nil {
+		return "", PassError(err)
+	}
+	best := ""
+	for _, tag := range tags {
+		tagVersion := trimFilterPrefix(tag, name)
+		if !versions.Contains(tagVersion) {
+			continue
+		}
+		if best == "" ||
+			semver.Compare("v"+tagVersion, "v"+trimFilterPrefix(best, name)) > 0 {
+			best = tag
+		}
+	}
+	if best == "" {
+		return "", WrappedErrorf(
+			"No version tags of the filter match the version range.\n"+
+				"Filter: %s\nVersion range: %s", name, version)
+	}
+	return best, nil
+}
@@ -0,0 +1,135 @@
+package regolith
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// watchControls reads the keys pressed in the terminal in the watch mode,
+// which control the watching (see watchControls.await).
+type watchControls struct {
+	keys chan byte
+	// restore restores the previous mode of the terminal
+	restore func()
+}
+
+// watchControlsHelp is printed after every run of the watch mode with the key
+// controls.
+const watchControlsHelp = "Press \"r\" to rebuild, \"p\" to pause, \"o\" to " +
+	"open the export folder or \"q\" to stop watching."
+
+// startWatchControls starts reading the keys pressed in the terminal. It
+// returns nil if the keys can't be read, for example if the standard input
+// isn't a terminal, in which case the watch mode can be stopped only with
+// Ctrl+C. The restore function of the controls must be called before
+// Regolith exits.
+func startWatchControls() *watchControls {
+	restore, err := enableKeyInput()
+	if err != nil {
+		Logger.Debugf(
+			"The key controls of the watch mode are disabled.\n%s",
+			PassError(err).Error())
+		return nil
+	}
+	controls := &watchControls{keys: make(chan byte), restore: restore}
+	go func() {
+		buffer := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if err != nil {
+				return
+			}
+			if n == 1 {
+				controls.keys <- buffer[0]
+			}
+		}
+	}()
+	return controls
+}
+
+// await waits for the changes of the source files of the context and for the
+// pressed keys, and returns the source of the changes that should rerun the
+// profile, "rebuild" if the profile should run again without any changes
+// ("r" key), or an empty string if the watching should stop ("q" key). The
+// "p" key pauses the watching: the changes are collected, but the profile
+// runs only after the watching is resumed with "p" again. The "o" key opens
+// the export folders of the profile. The keys pressed during a run are
+// handled after it.
+func (w *watchControls) await(context RunContext) string {
+	paused := false
+	pending := ""
+	for {
+		select {
+		case source := <-context.interruptionChannel:
+			if !paused {
+				return source
+			}
+			pending = source
+		case key := <-w.keys:
+			switch key {
+			case 'r', 'R':
+				// The output of the filters isn't reused, all of them run
+				// again
+				if context.resume != nil {
+					context.resume.truncate(0)
+				}
+				return "rebuild"
+			case 'p', 'P':
+				paused = !paused
+				if paused {
+					Logger.Info(
+						"Paused watching. Press \"p\" to resume it.")
+					continue
+				}
+				Logger.Info("Resumed watching.")
+				if pending != "" {
+					return pending
+				}
+			case 'o', 'O':
+				err := openExportFolders(context)
+				if err != nil {
+					Logger.Warnf(
+						"Failed to open the export folder.\n%s",
+						PassError(err).Error())
+				}
+			case 'q', 'Q':
+				return ""
+			}
+		}
+	}
+}
+
+// openExportFolders opens the export folders of the behavior pack and the
+// resource pack of the profile of the context in the file manager.
+func openExportFolders(context RunContext) error {
+	profile, err := context.GetProfile()
+	if err != nil {
+		return PassError(err)
+	}
+	bpPath, rpPath, err := GetExportPaths(
+		profile.ExportTarget, context.Config.Name)
+	if err != nil {
+		return WrapError(err, "Failed to get the export paths.")
+	}
+	opened := false
+	for _, path := range []string{bpPath, rpPath} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		fullPath, err := filepath.Abs(path)
+		if err != nil {
+			return WrapErrorf(err, filepathAbsError, path)
+		}
+		Logger.Infof("Opening %q.", fullPath)
+		if err := openFolder(fullPath); err != nil {
+			return PassError(err)
+		}
+		opened = true
+	}
+	if !opened {
+		return WrappedError(
+			"The export folders don't exist. The profile wasn't exported " +
+				"yet.")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+// +build darwin
+
+package regolith
+
+import "golang.org/x/sys/unix"
+
+// The requests of ioctl that get and set the mode of the terminal, used by
+// enableKeyInput.
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+// folderOpener is the program that opens a folder in Finder.
+const folderOpener = "open"
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package regolith
+
+import "golang.org/x/sys/unix"
+
+// The requests of ioctl that get and set the mode of the terminal, used by
+// enableKeyInput.
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+// folderOpener is the program that opens a folder in the file manager.
+const folderOpener = "xdg-open"
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package regolith
+
+import "os/exec"
+
+// enableKeyInput isn't supported on this platform, so the watch mode can be
+// stopped only with Ctrl+C.
+func enableKeyInput() (func(), error) {
+	return nil, WrappedError(
+		"Reading the pressed keys isn't supported on this platform.")
+}
+
+// openFolder opens the folder in the file manager of the system.
+func openFolder(path string) error {
+	cmd := exec.Command("xdg-open", path)
+	if err := cmd.Start(); err != nil {
+		return WrapErrorf(err, execCommandError, "xdg-open")
+	}
+	go cmd.Wait()
+	return nil
+}
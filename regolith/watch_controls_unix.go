@@ -0,0 +1,67 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package regolith
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableKeyInput switches the terminal of the standard input to the mode in
+// which the pressed keys can be read one by one, without waiting for Enter
+// and without printing them. It returns the function that restores the
+// previous mode. The mode is also restored when Regolith is stopped with
+// Ctrl+C, which then stops Regolith like in the normal mode of the
+// terminal.
+func enableKeyInput() (func(), error) {
+	fd := int(os.Stdin.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, WrapError(err, "The standard input isn't a terminal.")
+	}
+	previous := *termios
+	termios.Lflag &^= unix.ICANON | unix.ECHO
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+	if err != nil {
+		return nil, WrapError(err, "Failed to change the mode of the terminal.")
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	done := make(chan struct{})
+	var once sync.Once
+	restore := func() {
+		once.Do(func() {
+			signal.Stop(interrupt)
+			close(done)
+			unix.IoctlSetTermios(fd, ioctlSetTermios, &previous)
+		})
+	}
+	go func() {
+		select {
+		case <-interrupt:
+			restore()
+			// Without the handler, the signal stops Regolith, unless it's
+			// handled by the export (see checkExportInterrupt)
+			unix.Kill(os.Getpid(), unix.SIGINT)
+		case <-done:
+		}
+	}()
+	return restore, nil
+}
+
+// openFolder opens the folder in the file manager of the system.
+func openFolder(path string) error {
+	cmd := exec.Command(folderOpener, path)
+	if err := cmd.Start(); err != nil {
+		return WrapErrorf(err, execCommandError, folderOpener)
+	}
+	go cmd.Wait()
+	return nil
+}
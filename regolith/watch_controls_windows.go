@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package regolith
+
+import (
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableKeyInput switches the console of the standard input to the mode in
+// which the pressed keys can be read one by one, without waiting for Enter
+// and without printing them. It returns the function that restores the
+// previous mode. Ctrl+C is still handled by the system.
+func enableKeyInput() (func(), error) {
+	handle := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	err := windows.GetConsoleMode(handle, &mode)
+	if err != nil {
+		return nil, WrapError(err, "The standard input isn't a console.")
+	}
+	err = windows.SetConsoleMode(
+		handle,
+		mode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT))
+	if err != nil {
+		return nil, WrapError(err, "Failed to change the mode of the console.")
+	}
+	return func() { windows.SetConsoleMode(handle, mode) }, nil
+}
+
+// openFolder opens the folder in File Explorer.
+func openFolder(path string) error {
+	// Explorer returns a non-zero exit code even if it opened the folder, so
+	// the command isn't awaited
+	cmd := exec.Command("explorer", path)
+	if err := cmd.Start(); err != nil {
+		return WrapErrorf(err, execCommandError, "explorer")
+	}
+	go cmd.Wait()
+	return nil
+}
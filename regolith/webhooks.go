@@ -0,0 +1,226 @@
+package regolith
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookTypes are the valid values of the "type" property of the webhooks,
+// the formats of the messages sent to them.
+var webhookTypes = []string{"json", "discord", "slack"}
+
+// webhookEvents are the valid values of the "on" property of the webhooks.
+var webhookEvents = []string{"success", "failure"}
+
+// webhookTimeout is the time limit of sending a message to a webhook.
+const webhookTimeout = 10 * time.Second
+
+// webhookErrorLength is the maximal length of the error summary in the
+// messages of the webhooks. Discord and Slack limit the length of the
+// messages.
+const webhookErrorLength = 1000
+
+// Webhook is a part of "config.json" with a URL notified after the runs of
+// the profiles.
+type Webhook struct {
+	// Url is the URL of the webhook. It can use the "${secret:NAME}"
+	// placeholders (see expandSecrets), because the URLs of the webhooks of
+	// Discord and Slack are their credentials.
+	Url string `json:"url"`
+	// Type is the format of the messages, one of the webhookTypes ("json" by
+	// default).
+	Type string `json:"type,omitempty"`
+	// On are the results of the runs that are sent to the webhook, from
+	// the webhookEvents (all of them by default).
+	On []string `json:"on,omitempty"`
+}
+
+// webhookEvent is the message sent to the webhooks with the "json" type.
+type webhookEvent struct {
+	// Event is "success" or "failure"
+	Event    string  `json:"event"`
+	Project  string  `json:"project"`
+	Profile  string  `json:"profile"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// WebhooksFromObject creates the webhooks of the project from the "webhooks"
+// property of the regolith namespace.
+func WebhooksFromObject(obj []interface{}) ([]Webhook, error) {
+	result := make([]Webhook, len(obj))
+	for i, webhookObj := range obj {
+		path := fmt.Sprintf("webhooks->%d", i)
+		webhookMap, ok := webhookObj.(map[string]interface{})
+		if !ok {
+			return nil, WrappedErrorf(jsonPropertyTypeError, path, "object")
+		}
+		url, ok := webhookMap["url"].(string)
+		if !ok || url == "" {
+			return nil, WrappedErrorf(jsonPropertyMissingError, path+"->url")
+		}
+		result[i].Url = url
+		result[i].Type = "json"
+		if typeObj, ok := webhookMap["type"]; ok {
+			webhookType, ok := typeObj.(string)
+			if !ok || !stringInSlice(webhookType, webhookTypes) {
+				return nil, WrappedErrorf(
+					jsonPropertyTypeError, path+"->type",
+					strings.Join(webhookTypes, ", "))
+			}
+			result[i].Type = webhookType
+		}
+		result[i].On = webhookEvents
+		if onObj, ok := webhookMap["on"]; ok {
+			on, ok := onObj.([]interface{})
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPropertyTypeError, path+"->on", "array")
+			}
+			result[i].On = make([]string, len(on))
+			for j, eventObj := range on {
+				event, ok := eventObj.(string)
+				if !ok || !stringInSlice(event, webhookEvents) {
+					return nil, WrappedErrorf(
+						jsonPropertyTypeError,
+						fmt.Sprintf("%s->on->%d", path, j),
+						strings.Join(webhookEvents, ", "))
+				}
+				result[i].On[j] = event
+			}
+		}
+	}
+	return result, nil
+}
+
+// notifyWebhooks sends the result of the run of the profile to the webhooks
+// of the project. The runErr is the error of the run, nil if it succeeded.
+// All of the webhooks are notified, even if some of them fail.
+func notifyWebhooks(
+	config *Config, profileName string, runErr error, duration time.Duration,
+) error {
+	if config == nil || len(config.Webhooks) == 0 {
+		return nil
+	}
+	event := webhookEvent{
+		Event:    "success",
+		Project:  config.Name,
+		Profile:  profileName,
+		Duration: duration.Seconds(),
+	}
+	if runErr != nil {
+		event.Event = "failure"
+		event.Error = truncateText(
+			PassError(runErr).Error(), webhookErrorLength)
+	}
+	var errs []error
+	for _, webhook := range config.Webhooks {
+		if !stringInSlice(event.Event, webhook.On) {
+			continue
+		}
+		err := sendWebhook(webhook, event)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := firstErr(errs...); err != nil {
+		return PassError(err)
+	}
+	return nil
+}
+
+// sendWebhook posts the event to the webhook, in the format of its type.
+func sendWebhook(webhook Webhook, event webhookEvent) error {
+	resolvedUrl, err := expandSecrets(webhook.Url)
+	if err != nil {
+		return WrapError(err, "Failed to resolve the URL of the webhook.")
+	}
+	var payload interface{} = event
+	switch webhook.Type {
+	case "discord":
+		payload = discordWebhookPayload(event)
+	case "slack":
+		payload = slackWebhookPayload(event)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return WrapError(err, "Failed to encode the message of the webhook.")
+	}
+	client := http.Client{Timeout: webhookTimeout}
+	response, err := client.Post(
+		resolvedUrl, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// The error of the request contains the resolved URL with the
+		// secrets
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return WrappedErrorf(
+			"Failed to send the message to the webhook.\nURL: %s\nError: %s",
+			maskSecrets(webhook.Url), hideSecrets(err.Error()))
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return WrappedErrorf(
+			"The webhook rejected the message.\nURL: %s\nStatus: %s",
+			maskSecrets(webhook.Url), response.Status)
+	}
+	Logger.Debugf("Sent the result of the run to the webhook %s.",
+		maskSecrets(webhook.Url))
+	return nil
+}
+
+// webhookTitle returns the title of the messages for Discord and Slack.
+func webhookTitle(event webhookEvent) string {
+	duration := time.Duration(event.Duration * float64(time.Second)).
+		Round(time.Millisecond)
+	if event.Event == "success" {
+		return fmt.Sprintf(
+			"✅ %s: the %q profile succeeded in %s",
+			event.Project, event.Profile, duration)
+	}
+	return fmt.Sprintf(
+		"❌ %s: the %q profile failed after %s",
+		event.Project, event.Profile, duration)
+}
+
+// discordWebhookPayload returns the message of a Discord webhook, with an
+// embed colored by the result of the run.
+func discordWebhookPayload(event webhookEvent) interface{} {
+	embed := map[string]interface{}{
+		"title": webhookTitle(event),
+		"color": 0x2ecc71,
+	}
+	if event.Event == "failure" {
+		embed["color"] = 0xe74c3c
+		embed["description"] = "```\n" + event.Error + "\n```"
+	}
+	return map[string]interface{}{
+		"username": "Regolith",
+		"embeds":   []interface{}{embed},
+	}
+}
+
+// slackWebhookPayload returns the message of a Slack incoming webhook.
+func slackWebhookPayload(event webhookEvent) interface{} {
+	text := webhookTitle(event)
+	if event.Event == "failure" {
+		text += "\n```" + event.Error + "```"
+	}
+	return map[string]interface{}{"text": text}
+}
+
+// truncateText shortens the text to the maximal number of characters,
+// marking the end of the shortened text with "...".
+func truncateText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-3]) + "..."
+}
@@ -0,0 +1,280 @@
+package regolith
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// WhenEnvironment is a set of values that can be accessed by the "when"
+// expressions of the filters.
+type WhenEnvironment struct {
+	// Profile is the name of the profile that runs the filter.
+	Profile string
+	// Defines is a map of custom values passed to Regolith with the
+	// "--define" flag.
+	Defines map[string]string
+}
+
+// EvaluateWhenExpression evaluates the "when" expression of a filter.
+//
+// The expressions support string literals in single or double quotes,
+// "true" and "false" literals, the "==" and "!=" comparison operators,
+// the "&&", "||" and "!" logical operators and parentheses. They can access
+// following variables:
+//   - os - the name of the operating system (like "windows" or "linux"),
+//   - arch - the architecture of the processor (like "amd64"),
+//   - profile - the name of the profile that runs the filter,
+//   - env.<NAME> - the value of an environment variable,
+//   - define.<NAME> - the value of a define passed with "--define" flag.
+//
+// Undefined environment variables and defines evaluate to empty strings.
+// Other unknown identifiers (like a misspelled "define") are errors. Empty
+// strings, "false" and "0" are falsy, other values are truthy.
+func EvaluateWhenExpression(
+	expression string, environment WhenEnvironment,
+) (bool, error) {
+	tokens, err := tokenizeWhenExpression(expression)
+	if err != nil {
+		return false, WrapErrorf(
+			err, "Failed to parse \"when\" expression.\nExpression: %s",
+			expression)
+	}
+	parser := whenExpressionParser{
+		tokens: tokens, environment: environment}
+	value, err := parser.parseOr()
+	if err == nil && parser.pos < len(parser.tokens) {
+		err = WrappedErrorf(
+			"Unexpected token.\nToken: %s", parser.tokens[parser.pos].value)
+	}
+	if err != nil {
+		return false, WrapErrorf(
+			err, "Failed to evaluate \"when\" expression.\nExpression: %s",
+			expression)
+	}
+	return isTruthy(value), nil
+}
+
+// whenTokenKind is the type of a token of the "when" expression.
+type whenTokenKind int
+
+const (
+	whenTokenOperator whenTokenKind = iota
+	whenTokenString
+	whenTokenIdentifier
+)
+
+type whenToken struct {
+	kind  whenTokenKind
+	value string
+}
+
+// tokenizeWhenExpression splits the "when" expression into tokens.
+func tokenizeWhenExpression(expression string) ([]whenToken, error) {
+	var tokens []whenToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, whenToken{whenTokenOperator, string(r)})
+			i++
+		case r == '!' || r == '=' || r == '&' || r == '|':
+			if i+1 < len(runes) {
+				op := string(runes[i : i+2])
+				if op == "==" || op == "!=" || op == "&&" || op == "||" {
+					tokens = append(tokens, whenToken{whenTokenOperator, op})
+					i += 2
+					continue
+				}
+			}
+			if r != '!' {
+				return nil, WrappedErrorf("Unexpected character.\nCharacter: %c", r)
+			}
+			tokens = append(tokens, whenToken{whenTokenOperator, "!"})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, WrappedError("Unterminated string literal.")
+			}
+			tokens = append(
+				tokens, whenToken{whenTokenString, string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) ||
+				unicode.IsDigit(runes[end]) || runes[end] == '_' ||
+				runes[end] == '.') {
+				end++
+			}
+			tokens = append(
+				tokens, whenToken{whenTokenIdentifier, string(runes[i:end])})
+			i = end
+		default:
+			return nil, WrappedErrorf("Unexpected character.\nCharacter: %c", r)
+		}
+	}
+	return tokens, nil
+}
+
+// whenExpressionParser is a recursive descent parser that evaluates the
+// "when" expressions while parsing them.
+type whenExpressionParser struct {
+	tokens      []whenToken
+	pos         int
+	environment WhenEnvironment
+}
+
+// peekOperator returns true if the next token is the given operator.
+func (p *whenExpressionParser) peekOperator(op string) bool {
+	return p.pos < len(p.tokens) &&
+		p.tokens[p.pos].kind == whenTokenOperator &&
+		p.tokens[p.pos].value == op
+}
+
+func (p *whenExpressionParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peekOperator("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = boolToWhenValue(isTruthy(left) || isTruthy(right))
+	}
+	return left, nil
+}
+
+func (p *whenExpressionParser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for p.peekOperator("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = boolToWhenValue(isTruthy(left) && isTruthy(right))
+	}
+	return left, nil
+}
+
+func (p *whenExpressionParser) parseUnary() (string, error) {
+	if p.peekOperator("!") {
+		p.pos++
+		value, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return boolToWhenValue(!isTruthy(value)), nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whenExpressionParser) parseComparison() (string, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return "", err
+	}
+	if p.peekOperator("==") || p.peekOperator("!=") {
+		op := p.tokens[p.pos].value
+		p.pos++
+		right, err := p.parsePrimary()
+		if err != nil {
+			return "", err
+		}
+		return boolToWhenValue((left == right) == (op == "==")), nil
+	}
+	return left, nil
+}
+
+func (p *whenExpressionParser) parsePrimary() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", WrappedError("Unexpected end of expression.")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case whenTokenString:
+		return token.value, nil
+	case whenTokenIdentifier:
+		return p.resolveIdentifier(token.value)
+	}
+	if token.value == "(" {
+		value, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if !p.peekOperator(")") {
+			return "", WrappedError("Missing closing parenthesis.")
+		}
+		p.pos++
+		return value, nil
+	}
+	return "", WrappedErrorf("Unexpected token.\nToken: %s", token.value)
+}
+
+// resolveIdentifier returns the value of a variable used in the expression.
+// It returns an error if the identifier isn't a known variable.
+func (p *whenExpressionParser) resolveIdentifier(name string) (string, error) {
+	switch {
+	case name == "true" || name == "false":
+		return name, nil
+	case name == "os":
+		return runtime.GOOS, nil
+	case name == "arch":
+		return runtime.GOARCH, nil
+	case name == "profile":
+		return p.environment.Profile, nil
+	case strings.HasPrefix(name, "env.") && name != "env.":
+		return os.Getenv(strings.TrimPrefix(name, "env.")), nil
+	case strings.HasPrefix(name, "define.") && name != "define.":
+		return p.environment.Defines[strings.TrimPrefix(name, "define.")], nil
+	}
+	return "", WrappedErrorf(
+		"Unknown identifier. The expressions can use the \"os\", \"arch\", "+
+			"\"profile\", \"env.<NAME>\" and \"define.<NAME>\" "+
+			"variables.\nIdentifier: %s", name)
+}
+
+func isTruthy(value string) bool {
+	return value != "" && value != "false" && value != "0"
+}
+
+func boolToWhenValue(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// ParseDefines parses a list of "name=value" strings (the values of the
+// "--define" flag) into a map. A define without a value is set to "true".
+func ParseDefines(defines []string) (map[string]string, error) {
+	result := make(map[string]string, len(defines))
+	for _, define := range defines {
+		name, value, found := strings.Cut(define, "=")
+		if !found {
+			value = "true"
+		}
+		if name == "" {
+			return nil, WrappedErrorf(
+				"Invalid define. Defines must use the \"name=value\" "+
+					"format.\nDefine: %s", define)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,241 @@
+package regolith
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkspaceFilePath is the name of the file that lists the Regolith projects
+// of a workspace (a repository with multiple projects). Regolith looks for
+// the file in the current directory and its parents.
+const WorkspaceFilePath = "regolith-workspace.json"
+
+// Workspace is a group of Regolith projects that can be run together with
+// the "--all" and "--project" flags.
+type Workspace struct {
+	// Root is the absolute path to the directory of the workspace file
+	Root     string
+	Projects map[string]WorkspaceProject
+	// FilterCache is the absolute path to the directory used as the shared
+	// filter cache of the projects (see sharedFilterCacheRoot). Empty string
+	// means the default shared filter cache.
+	FilterCache string
+}
+
+// WorkspaceProject is a project from the workspace file.
+type WorkspaceProject struct {
+	// Path is the absolute path to the directory of the project
+	Path string
+	// DependsOn are the names of the projects that run before this project
+	DependsOn []string
+}
+
+// WorkspaceFromObject creates a Workspace from the workspace file parsed to
+// map[string]interface{}. The paths are relative to the root.
+func WorkspaceFromObject(
+	obj map[string]interface{}, root string,
+) (*Workspace, error) {
+	result := &Workspace{
+		Root: root, Projects: make(map[string]WorkspaceProject)}
+	projectsObj, ok := obj["projects"]
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyMissingError, "projects")
+	}
+	projects, ok := projectsObj.(map[string]interface{})
+	if !ok {
+		return nil, WrappedErrorf(jsonPropertyTypeError, "projects", "object")
+	}
+	for name, projectObj := range projects {
+		project, ok := projectObj.(map[string]interface{})
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPathTypeError, "projects->"+name, "object")
+		}
+		path, ok := project["path"].(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPathMissingError, "projects->"+name+"->path")
+		}
+		workspaceProject := WorkspaceProject{Path: filepath.Join(root, path)}
+		// DependsOn - can be empty
+		if dependsOnObj, ok := project["dependsOn"]; ok {
+			dependsOn, ok := dependsOnObj.([]interface{})
+			if !ok {
+				return nil, WrappedErrorf(
+					jsonPathTypeError, "projects->"+name+"->dependsOn",
+					"array")
+			}
+			for i, dependency := range dependsOn {
+				dependency, ok := dependency.(string)
+				if !ok {
+					return nil, WrappedErrorf(
+						jsonPathTypeError,
+						fmt.Sprintf("projects->%s->dependsOn->%d", name, i),
+						"string")
+				}
+				workspaceProject.DependsOn = append(
+					workspaceProject.DependsOn, dependency)
+			}
+		}
+		result.Projects[name] = workspaceProject
+	}
+	for name, project := range result.Projects {
+		for _, dependency := range project.DependsOn {
+			if _, ok := result.Projects[dependency]; !ok {
+				return nil, WrappedErrorf(
+					"The project depends on a project that isn't in the "+
+						"workspace.\nProject: %s\nDependency: %s",
+					name, dependency)
+			}
+		}
+	}
+	// FilterCache - can be empty
+	if filterCacheObj, ok := obj["filterCache"]; ok {
+		filterCache, ok := filterCacheObj.(string)
+		if !ok {
+			return nil, WrappedErrorf(
+				jsonPropertyTypeError, "filterCache", "string")
+		}
+		result.FilterCache = filepath.Join(root, filterCache)
+	}
+	return result, nil
+}
+
+// FindWorkspace loads the workspace file from the current directory or the
+// closest of its parents.
+func FindWorkspace() (*Workspace, error) {
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		return nil, WrapErrorf(err, filepathAbsError, ".")
+	}
+	for {
+		path := filepath.Join(dir, WorkspaceFilePath)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var obj map[string]interface{}
+			err = unmarshalJsonc(data, &obj)
+			if err != nil {
+				return nil, WrapErrorf(err, jsonUnmarshalError, path)
+			}
+			workspace, err := WorkspaceFromObject(obj, dir)
+			if err != nil {
+				return nil, WrapErrorf(
+					err, "Failed to parse the workspace file.\nPath: %s", path)
+			}
+			return workspace, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, WrapErrorf(err, fileReadError, path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, WrappedErrorf(
+				"Failed to find the %q file in the current directory or "+
+					"any of its parents.", WorkspaceFilePath)
+		}
+		dir = parent
+	}
+}
+
+// ProjectOrder returns the names of the projects in the order in which they
+// should run, so every project runs after the projects it depends on. If the
+// selected project isn't empty, only the project and its dependencies are
+// returned.
+func (w *Workspace) ProjectOrder(selected string) ([]string, error) {
+	names := make([]string, 0, len(w.Projects))
+	if selected != "" {
+		if _, ok := w.Projects[selected]; !ok {
+			return nil, WrappedErrorf(
+				"The project isn't in the workspace.\nProject: %s", selected)
+		}
+		names = append(names, selected)
+	} else {
+		for name := range w.Projects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int)
+	var result []string
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visiting:
+			return WrappedErrorf(
+				"The projects of the workspace depend on each other in a "+
+					"cycle.\nCycle: %s",
+				strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		dependencies := append([]string(nil), w.Projects[name].DependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return PassError(err)
+			}
+		}
+		states[name] = visited
+		result = append(result, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, PassError(err)
+		}
+	}
+	return result, nil
+}
+
+// forEachWorkspaceProject runs the action in the directory of every project
+// of the workspace (or of the selected project and its dependencies), in the
+// dependency order (see ProjectOrder). It stops at the first project that
+// fails. The projects share the filter cache of the workspace.
+func forEachWorkspaceProject(
+	selected string, action func(name string) error,
+) error {
+	workspace, err := FindWorkspace()
+	if err != nil {
+		return PassError(err)
+	}
+	order, err := workspace.ProjectOrder(selected)
+	if err != nil {
+		return PassError(err)
+	}
+	if workspace.FilterCache != "" {
+		sharedFilterCacheRoot = workspace.FilterCache
+		defer func() { sharedFilterCacheRoot = "" }()
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return WrapError(err, "Failed to get the current working directory.")
+	}
+	defer os.Chdir(workingDir)
+	for i, name := range order {
+		project := workspace.Projects[name]
+		Logger.Infof(
+			"[%d/%d] Project %q (%s)", i+1, len(order), name, project.Path)
+		err = os.Chdir(project.Path)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to enter the directory of the project.\n"+
+					"Project: %s\nPath: %s", name, project.Path)
+		}
+		err = action(name)
+		if err != nil {
+			return WrapErrorf(
+				err, "Failed to process the project of the workspace.\n"+
+					"Project: %s", name)
+		}
+	}
+	Logger.Infof("Successfully processed %d projects.", len(order))
+	return nil
+}
@@ -0,0 +1,124 @@
+package regolith
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// The primes of the 64-bit xxHash algorithm
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is the 64-bit variant of the xxHash algorithm (XXH64) with the seed
+// 0, as described at https://github.com/Cyan4973/xxHash. It isn't a
+// cryptographic hash, but it's many times faster than SHA-1, so it's used
+// for detecting the changed files by the recycled mode. It implements
+// hash.Hash64.
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int // The number of the bytes used in mem
+}
+
+// NewXxh64 creates a new XXH64 hash. The result implements hash.Hash64.
+func NewXxh64() hash.Hash {
+	h := &xxh64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxh64) Reset() {
+	// The variable avoids the overflow of the constant expressions
+	prime1 := xxhPrime1
+	h.v = [4]uint64{prime1 + xxhPrime2, xxhPrime2, 0, -prime1}
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh64) Size() int { return 8 }
+
+func (h *xxh64) BlockSize() int { return 32 }
+
+func (h *xxh64) Write(b []byte) (int, error) {
+	n := len(b)
+	h.total += uint64(n)
+	if h.n+n < 32 {
+		h.n += copy(h.mem[h.n:], b)
+		return n, nil
+	}
+	if h.n > 0 {
+		// Complete the block from the previous writes
+		b = b[copy(h.mem[h.n:], b):]
+		h.writeBlock(h.mem[:])
+		h.n = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		h.writeBlock(b)
+	}
+	h.n = copy(h.mem[:], b)
+	return n, nil
+}
+
+// writeBlock processes a 32-byte block of the input.
+func (h *xxh64) writeBlock(b []byte) {
+	for i := range h.v {
+		h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(b[i*8:]))
+	}
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	s := h.Sum64()
+	return append(
+		b, byte(s>>56), byte(s>>48), byte(s>>40), byte(s>>32),
+		byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var result uint64
+	if h.total >= 32 {
+		v := h.v
+		result = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for i := range v {
+			result ^= xxhRound(0, v[i])
+			result = result*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		result = h.v[2] + xxhPrime5
+	}
+	result += h.total
+	mem := h.mem[:h.n]
+	for ; len(mem) >= 8; mem = mem[8:] {
+		result ^= xxhRound(0, binary.LittleEndian.Uint64(mem))
+		result = bits.RotateLeft64(result, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(mem) >= 4 {
+		result ^= uint64(binary.LittleEndian.Uint32(mem)) * xxhPrime1
+		result = bits.RotateLeft64(result, 23)*xxhPrime2 + xxhPrime3
+		mem = mem[4:]
+	}
+	for _, c := range mem {
+		result ^= uint64(c) * xxhPrime5
+		result = bits.RotateLeft64(result, 11) * xxhPrime1
+	}
+	result ^= result >> 33
+	result *= xxhPrime2
+	result ^= result >> 29
+	result *= xxhPrime3
+	result ^= result >> 32
+	return result
+}
+
+// xxhRound mixes a lane of the input into the accumulator.
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// adbPushLog returns the lines logged by the fake "adb" command while
+// pushing the packs of the adb_export project to the com.mojang folder on
+// the device. The prefix is added to the arguments of the commands.
+func adbPushLog(prefix, mojangDir string) string {
+	result := ""
+	for _, pack := range [][2]string{
+		{"build/BP", "development_behavior_packs/adb_export_test_project_bp"},
+		{"build/RP", "development_resource_packs/adb_export_test_project_rp"},
+	} {
+		target := mojangDir + "/" + pack[1]
+		result += "args: " + prefix + "shell rm -rf '" + target +
+			"' && mkdir -p '" + path.Dir(target) + "'\n"
+		result += "args: " + prefix + "push " + pack[0] + " " + target + "\n"
+	}
+	return result
+}
+
+// testAdbExport runs a project with the "adb" export target with a fake
+// "adb" command. It checks the search for the com.mojang folder on the
+// device, the commands that push the packs, and the errors of the export.
+// The fake command is a shell script, so the test is skipped on Windows.
+func testAdbExport(t *testing.T, recycled bool) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake adb command doesn't work on Windows")
+	}
+	bin, err := filepath.Abs(filepath.Join(adbExportPath, "bin"))
+	if err != nil {
+		t.Fatal("Unable to get the path to the fake adb command:", err)
+	}
+	logPath := filepath.Join(t.TempDir(), "adb.log")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("REGOLITH_TEST_ADB_LOG", logPath)
+	t.Setenv("REGOLITH_TEST_ADB_MOJANG_DIR", "/sdcard/games/com.mojang")
+	t.Setenv("REGOLITH_TEST_ADB_EXIT", "0")
+	_, cleanup := prepareTestProject(t, adbExportPath)
+	defer cleanup()
+	data := filepath.Join("packs", "BP", "data.json")
+
+	// THE TEST
+	t.Log("Pushing the packs to the detected com.mojang folder...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expected := "args: shell if [ -d '/sdcard/Android/data/" +
+		"com.mojang.minecraftpe/files/games/com.mojang' ]; " +
+		"then echo found; fi\n" +
+		"args: shell if [ -d '/sdcard/games/com.mojang' ]; " +
+		"then echo found; fi\n" +
+		adbPushLog("", "/sdcard/games/com.mojang")
+	if log := takeCommandLog(t, logPath); log != expected {
+		t.Errorf(
+			"Unexpected adb commands.\nExpected:\n%s\nActual:\n%s",
+			expected, log)
+	}
+
+	t.Log("Pushing the packs to the selected device and path...")
+	if err := regolith.Run("device", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expected = adbPushLog("-s emulator-5554 ", "/sdcard/custom/com.mojang")
+	if log := takeCommandLog(t, logPath); log != expected {
+		t.Errorf(
+			"Unexpected adb commands.\nExpected:\n%s\nActual:\n%s",
+			expected, log)
+	}
+
+	t.Log("Pushing the packs to a device without Minecraft...")
+	t.Setenv("REGOLITH_TEST_ADB_MOJANG_DIR", "")
+	writeTestFile(t, data, "{\"value\": 2}\n")
+	err = regolith.Run("dev", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded without the com.mojang folder")
+	}
+	if !strings.Contains(err.Error(), "Failed to find the \"com.mojang\"") {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if log := takeCommandLog(t, logPath); strings.Contains(log, "push") {
+		t.Errorf("The packs were pushed without the com.mojang folder:\n%s",
+			log)
+	}
+
+	t.Log("Running the failing adb command...")
+	t.Setenv("REGOLITH_TEST_ADB_MOJANG_DIR", "/sdcard/games/com.mojang")
+	t.Setenv("REGOLITH_TEST_ADB_EXIT", "1")
+	writeTestFile(t, data, "{\"value\": 3}\n")
+	err = regolith.Run("dev", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but adb failed")
+	}
+	if !strings.Contains(err.Error(), "Android device") {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestAdbExport(t *testing.T) {
+	testAdbExport(t, false)
+}
+
+func TestAdbExportRecycled(t *testing.T) {
+	testAdbExport(t, true)
+}
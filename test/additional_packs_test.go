@@ -0,0 +1,53 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testAdditionalPacks runs a project with the additional packs, which are
+// copied to the tmp directory next to the main packs and exported next to
+// them with the names of the additional packs.
+func testAdditionalPacks(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, additionalPacksPath)
+	defer cleanup()
+
+	// THE TEST
+	t.Log("Running the profile with the additional packs...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join("build", "BP", "packs.txt"), "hd\naddon\naddon\n")
+	expectFileContent(
+		t, filepath.Join("build", "RP_hd", "textures", "hd.txt"), "hd\n")
+	expectFileContent(t, filepath.Join("build", "RP_hd", "filtered.txt"), "hd")
+	expectNotExist(t, filepath.Join("build", "BP_hd"))
+	expectFileContent(t, filepath.Join("build", "BP_addon", "addon.txt"),
+		"addon\n")
+	expectFileContent(
+		t, filepath.Join("build", "BP_addon", "filtered.txt"), "addon")
+	expectFileContent(t, filepath.Join("build", "RP_addon", "addon.txt"),
+		"addon\n")
+	expectFileContent(
+		t, filepath.Join("packs", "RP_hd", "textures", "hd.txt"), "hd\n")
+	expectNotExist(t, filepath.Join("packs", "RP_hd", "filtered.txt"))
+
+	t.Log("Running the profile with an unsupported export target...")
+	err := regolith.Run("archive", nil, recycled, true)
+	if err == nil || !strings.Contains(
+		err.Error(), "doesn't support the additional packs") {
+		t.Fatal("Expected an error about the export target, got:", err)
+	}
+}
+
+func TestAdditionalPacks(t *testing.T) {
+	testAdditionalPacks(t, false)
+}
+
+func TestAdditionalPacksRecycled(t *testing.T) {
+	testAdditionalPacks(t, true)
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// readZipArchive returns the content of the files from the zip archive,
+// mapped to their paths inside the archive.
+func readZipArchive(t *testing.T, path string) map[string]string {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Unable to open the archive %q: %s", path, err)
+	}
+	defer reader.Close()
+	result := make(map[string]string)
+	for _, file := range reader.File {
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatalf("Unable to open %q in %q: %s", file.Name, path, err)
+		}
+		data, err := io.ReadAll(entry)
+		entry.Close()
+		if err != nil {
+			t.Fatalf("Unable to read %q from %q: %s", file.Name, path, err)
+		}
+		result[file.Name] = string(data)
+	}
+	return result
+}
+
+// expectArchiveFiles checks if the archive contains exactly the listed
+// files.
+func expectArchiveFiles(t *testing.T, path string, expected ...string) {
+	files := readZipArchive(t, path)
+	for _, name := range expected {
+		if _, ok := files[name]; !ok {
+			t.Errorf("The archive %q doesn't contain %q", path, name)
+		}
+	}
+	if len(files) != len(expected) {
+		t.Errorf(
+			"The archive %q contains %d files, expected %d",
+			path, len(files), len(expected))
+	}
+}
+
+// testPackArchiveExport runs the profiles of the "mcpack" and "mcaddon"
+// export targets and checks the created archives. Their names include the
+// version from the manifest.
+func testPackArchiveExport(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, archiveExportPath)
+	defer cleanup()
+	const name = "archive_export_test_project"
+	build := filepath.Join(tmpDir, "build")
+	// THE TEST
+	if err := regolith.Run("mcpack", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectArchiveFiles(
+		t, filepath.Join(build, name+"-1.2.3_bp.mcpack"), "manifest.json")
+	expectArchiveFiles(
+		t, filepath.Join(build, name+"-1.2.3_rp.mcpack"), "manifest.json")
+	if err := regolith.Run("mcaddon", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectArchiveFiles(
+		t, filepath.Join(build, name+"-1.2.3.mcaddon"),
+		name+"_bp/manifest.json", name+"_rp/manifest.json")
+}
+
+func TestPackArchiveExport(t *testing.T) {
+	testPackArchiveExport(t, false)
+}
+
+func TestPackArchiveExportRecycled(t *testing.T) {
+	testPackArchiveExport(t, true)
+}
+
+// testWorldArchiveExport runs the profiles of the "mcworld" and "mctemplate"
+// export targets and checks if the created archives contain the world with
+// the exported packs, which replace the old version of the behavior pack
+// from the world, and the lists of the packs of the world.
+func testWorldArchiveExport(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, archiveExportPath)
+	defer cleanup()
+	const name = "archive_export_test_project"
+	// THE TEST
+	for _, target := range []string{"mcworld", "mctemplate"} {
+		if err := regolith.Run(target, nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		path := filepath.Join(tmpDir, "build", name+"-1.2.3."+target)
+		expectArchiveFiles(
+			t, path,
+			"level.dat", "levelname.txt", "manifest.json",
+			"world_behavior_packs.json", "world_resource_packs.json",
+			"behavior_packs/"+name+"_bp/manifest.json",
+			"resource_packs/"+name+"_rp/manifest.json")
+		files := readZipArchive(t, path)
+		for listFile, expected := range map[string][]string{
+			"world_behavior_packs.json": {
+				"6f6b2b4c-8c3c-4f4e-9d7f-0d7a1c9b1a00",
+				"6f6b2b4c-8c3c-4f4e-9d7f-0d7a1c9b1a01",
+			},
+			"world_resource_packs.json": {
+				"6f6b2b4c-8c3c-4f4e-9d7f-0d7a1c9b1a02",
+			},
+		} {
+			var packs []struct {
+				PackId string `json:"pack_id"`
+			}
+			err := json.Unmarshal([]byte(files[listFile]), &packs)
+			if err != nil {
+				t.Fatalf("Unable to parse %q: %s", listFile, err)
+			}
+			if len(packs) != len(expected) {
+				t.Fatalf("Unexpected packs in %q: %v", listFile, packs)
+			}
+			for i, pack := range packs {
+				if pack.PackId != expected[i] {
+					t.Errorf(
+						"Unexpected pack in %q: %s, expected %s",
+						listFile, pack.PackId, expected[i])
+				}
+			}
+		}
+	}
+}
+
+func TestWorldArchiveExport(t *testing.T) {
+	testWorldArchiveExport(t, false)
+}
+
+func TestWorldArchiveExportRecycled(t *testing.T) {
+	testWorldArchiveExport(t, true)
+}
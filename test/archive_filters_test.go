@@ -0,0 +1,150 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// archiveServer is an HTTP server with a zip archive of the remote filters.
+type archiveServer struct {
+	mutex   sync.Mutex
+	archive []byte
+}
+
+// setFiles replaces the archive with the files (with the paths relative to
+// the root of the archive) and returns the checksum of the archive, in the
+// format used by the archive filters.
+func (s *archiveServer) setFiles(
+	t *testing.T, files map[string]string,
+) string {
+	var buffer bytes.Buffer
+	w := zip.NewWriter(&buffer)
+	for path, content := range files {
+		f, err := w.Create(path)
+		if err == nil {
+			_, err = f.Write([]byte(content))
+		}
+		if err != nil {
+			t.Fatal("Unable to create the archive:", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("Unable to create the archive:", err)
+	}
+	s.mutex.Lock()
+	s.archive = buffer.Bytes()
+	s.mutex.Unlock()
+	hash := sha256.Sum256(buffer.Bytes())
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// ServeHTTP sends the archive for every request.
+func (s *archiveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Write(s.archive)
+}
+
+// archiveFilterFiles returns the files of the "hello" filter in the
+// "filters" directory of the archive.
+func archiveFilterFiles(message string) map[string]string {
+	result := make(map[string]string)
+	for path, content := range helloFilterFiles(message) {
+		result["filters/"+path] = content
+	}
+	return result
+}
+
+// TestArchiveFilterDefinition checks if the archive filters require a valid
+// checksum instead of the version.
+func TestArchiveFilterDefinition(t *testing.T) {
+	url := "https://example.com/filters.zip//filters/hello"
+	cases := map[string]bool{
+		"":                        false,
+		"sha256:":                 false,
+		"md5:0123456789abcdef":    false,
+		"0123456789abcdef":        false,
+		"sha256:0123456789abcdef": true,
+		"sha512:0123456789abcdef": true,
+	}
+	for checksum, valid := range cases {
+		definition := map[string]interface{}{"url": url}
+		if checksum != "" {
+			definition["checksum"] = checksum
+		}
+		filter, err := regolith.RemoteFilterDefinitionFromObject(
+			"hello", definition)
+		if valid && err != nil {
+			t.Errorf("Checksum %q rejected: %s", checksum, err.Error())
+		} else if !valid && err == nil {
+			t.Errorf("Checksum %q accepted", checksum)
+		} else if valid && filter.Version != checksum {
+			t.Errorf(
+				"The version of the filter isn't the checksum: %q",
+				filter.Version)
+		}
+	}
+}
+
+// TestArchiveFilter installs a remote filter from a subdirectory of a zip
+// archive on an HTTP server. The archive must match the checksum from the
+// filter definition, and the filter is installed again when the checksum
+// changes.
+func TestArchiveFilter(t *testing.T) {
+	isolateUserDirs(t)
+	archive := &archiveServer{}
+	server := httptest.NewServer(archive)
+	defer server.Close()
+	checksum := archive.setFiles(t, archiveFilterFiles("1"))
+	_, cleanup := prepareTestProject(t, archiveFiltersPath)
+	defer cleanup()
+	replaceInTestFile(
+		t, "config.json", "ARCHIVE_URL",
+		server.URL+"/filters.zip//filters/hello")
+	replaceInTestFile(t, "config.json", "ARCHIVE_CHECKSUM", checksum)
+	hello := filepath.Join("build", "BP", "hello.txt")
+
+	// THE TEST
+	t.Log("Installing the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, hello, "1")
+	expectLockedFilter(t, "hello", checksum)
+
+	t.Log("Installing the filter from a changed archive...")
+	archive.setFiles(t, archiveFilterFiles("2"))
+	installPath := filepath.Join(".regolith", "cache", "filters", "hello")
+	err := regolith.InstallAll(true, false, true)
+	if err == nil ||
+		!strings.Contains(strings.ToLower(err.Error()), "checksum") {
+		t.Fatal("Expected an error about the checksum, got:", err)
+	}
+	expectNotExist(t, installPath)
+
+	t.Log("Installing the filter with the new checksum...")
+	newChecksum := archive.setFiles(t, archiveFilterFiles("3"))
+	replaceInTestFile(t, "config.json", checksum, newChecksum)
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, hello, "3")
+	expectLockedFilter(t, "hello", newChecksum)
+}
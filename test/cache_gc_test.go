@@ -0,0 +1,86 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestCacheGc installs a remote filter and adds the files that aren't used
+// by the project to its cache and to the shared filter cache. The "regolith
+// cache info" command reports the caches, and "regolith cache gc" removes the
+// unused files. The retention period of 0 days removes the filters from the
+// shared cache, but not the filters used by the project.
+func TestCacheGc(t *testing.T) {
+	isolateUserDirs(t)
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal("Unable to get the user cache directory:", err)
+	}
+	sharedCache := filepath.Join(userCache, "regolith", "filter-cache")
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	filters := filepath.Join(".regolith", "cache", "filters")
+	unusedFilter := filepath.Join(filters, "unused")
+	writeTestFile(t, filepath.Join(unusedFilter, "filter.json"), "{}\n")
+	unusedVenv := filepath.Join(".regolith", "cache", "venvs", "5")
+	writeTestFile(t, filepath.Join(unusedVenv, "pyvenv.cfg"), "\n")
+	unusedShared := filepath.Join(sharedCache, "unused")
+	writeTestFile(t, filepath.Join(unusedShared, "filter.json"), "{}\n")
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Printing the information about the caches...")
+	if err := regolith.CacheInfo(true); err != nil {
+		t.Fatal("'regolith cache info' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(
+		"Shared filter cache: "+sharedCache+" (2 filters").Len() == 0 {
+		t.Error("Missing the information about the shared filter cache.")
+	}
+	expectLogs(t, logs, "Known projects: 1")
+
+	t.Log("Removing the unused files...")
+	if err := regolith.CacheGc(-1, true); err == nil {
+		t.Fatal("'regolith cache gc' accepted a negative retention period")
+	}
+	if err := regolith.CacheGc(30, true); err != nil {
+		t.Fatal("'regolith cache gc' failed:", err.Error())
+	}
+	expectNotExist(t, unusedFilter)
+	expectNotExist(t, unusedVenv)
+	expectNotExist(t, unusedShared)
+	entries, err := os.ReadDir(sharedCache)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf(
+			"Expected one filter in the shared cache, found %d: %v",
+			len(entries), err)
+	}
+	expectFileContent(
+		t, filepath.Join(filters, "hello", "hello.lua"),
+		helloFilterFiles("1")["hello/hello.lua"])
+
+	t.Log("Removing the files with the retention period of 0 days...")
+	if err := regolith.CacheGc(0, true); err != nil {
+		t.Fatal("'regolith cache gc' failed:", err.Error())
+	}
+	if entries, _ := os.ReadDir(sharedCache); len(entries) != 0 {
+		t.Fatalf("Expected an empty shared cache, found %d filters.",
+			len(entries))
+	}
+	expectFileContent(
+		t, filepath.Join(filters, "hello", "hello.lua"),
+		helloFilterFiles("1")["hello/hello.lua"])
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestChangedFiles watches a project with the daemon and checks the list of
+// the changed files passed to the filter in the REGOLITH_CHANGED_FILES
+// environment variable. The list is unknown on the first run, and it isn't
+// passed outside of the watch mode. The command of the filter uses the syntax
+// of the POSIX shells, so the test is skipped if Regolith would run it in
+// PowerShell or cmd.
+func TestChangedFiles(t *testing.T) {
+	for _, shell := range []string{"powershell", "cmd"} {
+		if _, err := exec.LookPath(shell); err == nil {
+			t.Skipf("The command of the filter doesn't work in %s", shell)
+		}
+	}
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, changedFilesPath)
+	defer cleanup()
+	changed := filepath.Join("build", "BP", "changed.json")
+
+	// THE TEST
+	t.Log("Running the profile outside of the watch mode...")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, changed, "none\n")
+
+	t.Log("Watching the project...")
+	daemon, stop := startTestDaemon(t, 0)
+	defer stop()
+	conn, reader := daemon.send(
+		t, map[string]interface{}{"command": "watch", "profile": "dev"})
+	defer conn.Close()
+	success := "Successfully ran the \"dev\" profile."
+	awaitDaemonMessage(t, conn, reader, success)
+	expectFileContent(t, changed, "null")
+
+	t.Log("Adding a file...")
+	writeTestFile(t, filepath.Join("packs", "RP", "added.json"), "{}")
+	awaitDaemonMessage(t, conn, reader, success)
+	expectFileContent(t, changed, "[\"RP/added.json\"]")
+
+	t.Log("Removing a file...")
+	if err := os.Remove(filepath.Join("packs", "BP", "data.json")); err != nil {
+		t.Fatal("Unable to remove the file:", err)
+	}
+	awaitDaemonMessage(t, conn, reader, success)
+	expectFileContent(t, changed, "[\"BP/data.json\"]")
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestCleanScope removes the parts of the cache of a project selected with
+// the flags of "regolith clean". The other parts of the cache stay, and the
+// whole cache is removed without the flags.
+func TestCleanScope(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	tmp := filepath.Join(".regolith", "tmp")
+	filters := filepath.Join(".regolith", "cache", "filters")
+	expectExists := func(path string) {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%q was removed: %s", path, err)
+		}
+	}
+
+	// THE TEST
+	t.Log("Cleaning the tmp directory...")
+	err := regolith.Clean(true, false, regolith.CleanScope{Tmp: true})
+	if err != nil {
+		t.Fatal("'regolith clean --tmp' failed:", err.Error())
+	}
+	expectNotExist(t, tmp)
+	expectExists(filters)
+
+	t.Log("Cleaning the filters...")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	err = regolith.Clean(true, false, regolith.CleanScope{Filters: true})
+	if err != nil {
+		t.Fatal("'regolith clean --filters' failed:", err.Error())
+	}
+	expectNotExist(t, filters)
+	expectExists(tmp)
+
+	t.Log("Mixing the flags with --user-cache...")
+	err = regolith.Clean(true, true, regolith.CleanScope{Tmp: true})
+	if err == nil {
+		t.Fatal("'regolith clean --user-cache --tmp' succeeded")
+	}
+
+	t.Log("Cleaning the whole cache...")
+	if err := regolith.Clean(true, false, regolith.CleanScope{}); err != nil {
+		t.Fatal("'regolith clean' failed:", err.Error())
+	}
+	expectNotExist(t, ".regolith")
+}
+
+// TestCleanExported removes the packs exported to the development packs of
+// Minecraft installed with mcpelauncher, whose folder is created in a
+// temporary home directory. The launcher isn't used on Windows.
+func TestCleanExported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mcpelauncher isn't used on Windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	_, cleanup := prepareTestProject(t, minecraftBuildsPath)
+	defer cleanup()
+	mojangDir := createMojangDir(
+		t, home,
+		".var/app/io.mrarm.mcpelauncher/data/mcpelauncher/games/com.mojang")
+	expectDevelopmentExport(t, "standard", mojangDir, false)
+
+	// THE TEST
+	err := regolith.Clean(true, false, regolith.CleanScope{Exported: true})
+	if err != nil {
+		t.Fatal("'regolith clean --exported' failed:", err.Error())
+	}
+	expectNotExist(
+		t, filepath.Join(
+			mojangDir, "development_behavior_packs",
+			"minecraft_builds_test_project_bp"))
+	expectNotExist(
+		t, filepath.Join(
+			mojangDir, "development_resource_packs",
+			"minecraft_builds_test_project_rp"))
+	expectDevelopmentExport(t, "standard", mojangDir, false)
+}
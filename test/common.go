@@ -1,13 +1,24 @@
 package test
 
 import (
+	"bufio"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"io/fs"
 	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/otiai10/copy"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // The ".ignoreme" files inside the test directories are files used to simulate
@@ -51,6 +62,212 @@ const (
 	// ProfileFilter. It contains a project and an expected result. The
 	// projects has both valid and invalid profiles.
 	profileFilterPath = "testdata/profile_filter"
+
+	// filterCachePath is a directory with a project that uses a cached local
+	// filter, which counts its runs in the "runs.txt" file.
+	filterCachePath = "testdata/filter_cache"
+
+	// parallelNeedsPath is a directory with a project whose profiles run
+	// their filters in parallel using the "needs" property. The "dev" profile
+	// is valid, the other profiles use invalid "needs", except for the
+	// "files" profile, which uses the "inputs" and "outputs" properties
+	// instead.
+	parallelNeedsPath = "testdata/parallel_needs"
+
+	// configMergePath is a directory with a project that has a local config
+	// (config.local.json) and profiles that extend other profiles.
+	configMergePath = "testdata/config_merge"
+
+	// configEditPath is a directory with a project with comments in
+	// config.json and the expected config.json after editing it with the
+	// "regolith config" command.
+	configEditPath = "testdata/config_edit"
+
+	// exportJournalPath is a directory with a project for testing the
+	// journal of the export. The "fail" profile has a filter that always
+	// fails, the "dev" profile doesn't have any filters.
+	exportJournalPath = "testdata/export_journal"
+
+	// unicodeNormalizationPath is a directory with a project that links the
+	// source files to the tmp directory and normalizes the Unicode in the
+	// exported files. The files with the Unicode names are created by the
+	// tests, because git and some file systems change their names.
+	unicodeNormalizationPath = "testdata/unicode_normalization"
+
+	// persistentFilterPath is a directory with a project with a Python
+	// filter that runs as a persistent process and counts the handled run
+	// requests.
+	persistentFilterPath = "testdata/persistent_filter"
+
+	// webhooksPath is a directory with a project that notifies two webhooks,
+	// whose URLs are set by the tests with the environment variables. The
+	// "fail" profile has a filter that always fails.
+	webhooksPath = "testdata/webhooks"
+
+	// filterTimeoutPath is a directory with a project with a Lua filter that
+	// never finishes, with a timeout and a retry. The filter counts its
+	// attempts in the "attempts.txt" file.
+	filterTimeoutPath = "testdata/filter_timeout"
+
+	// dockerFilterPath is a directory with a project with a Docker filter
+	// that copies a file from its directory to the behavior pack, in a
+	// directory created by the filter.
+	dockerFilterPath = "testdata/docker_filter"
+
+	// hardlinkExportPath is a directory with a project that links the source
+	// files to the tmp directory and exports them without any filters.
+	hardlinkExportPath = "testdata/hardlink_export"
+
+	// filterProtocolPath is a directory with a project with a Python filter
+	// that uses the "stdio" protocol. The filter logs a message of the size
+	// from its settings and reports a diagnostic, which is an error in the
+	// "fail" profile.
+	filterProtocolPath = "testdata/filter_protocol"
+
+	// denoFilterPath is a directory with a project with a Deno filter that
+	// writes the message from its settings to the behavior pack. The
+	// "denied" profile runs the filter without the permission to write files.
+	denoFilterPath = "testdata/deno_filter"
+
+	// luaFilterPath is a directory with a project with a Lua filter that
+	// uses the functions of the "regolith" module and a module from the
+	// filter directory. The "fail" profile has a filter that always fails.
+	luaFilterPath = "testdata/lua_filter"
+
+	// filterVariablesPath is a directory with a project with a Lua filter
+	// that writes its settings and arguments, which use the variables, to
+	// the "variables.json" file.
+	filterVariablesPath = "testdata/filter_variables"
+
+	// dryRunPath is a directory with a project with a Lua filter that writes
+	// a file. The first filter uses a secret in its settings, the second
+	// filter runs only with the "release" define.
+	dryRunPath = "testdata/dry_run"
+
+	// archiveExportPath is a directory with a project with a profile for
+	// every archive export target. Both of the packs have manifests with the
+	// version 1.2.3. The world targets use the world from the "world"
+	// directory, which has an old version of the behavior pack and another
+	// behavior pack on its list.
+	archiveExportPath = "testdata/archive_export"
+
+	// minecraftBuildsPath is a directory with a project with the profiles
+	// that export the packs to the different builds of Minecraft. The tests
+	// create the "com.mojang" folders of the builds in temporary
+	// directories.
+	minecraftBuildsPath = "testdata/minecraft_builds"
+
+	// sftpExportPath is a directory with a project that exports the packs
+	// with the "sftp" export target, and a fake "sftp" command in the "bin"
+	// directory, which logs its arguments and batch files.
+	sftpExportPath = "testdata/sftp_export"
+
+	// s3ExportPath is a directory with a project with the profiles that
+	// upload the archives of the packs to an S3 bucket. The endpoint of the
+	// bucket is set by the tests with an environment variable.
+	s3ExportPath = "testdata/s3_export"
+
+	// symlinkExportPath is a directory with a project with the profiles that
+	// export the packs to the same paths with and without the "symlink"
+	// property.
+	symlinkExportPath = "testdata/symlink_export"
+
+	// incrementalExportPath is a directory with a project that exports the
+	// packs to the "EXPORT_DIR" placeholder, which is replaced by the tests
+	// with a path on another file system than the project.
+	incrementalExportPath = "testdata/incremental_export"
+
+	// exportRollbackPath is a directory with a project with a profile whose
+	// export fails after exporting the packs, because the behavior pack is
+	// exported into the data path.
+	exportRollbackPath = "testdata/export_rollback"
+
+	// exportExcludePath is a directory with a project with a profile that
+	// excludes files from the export with glob patterns.
+	exportExcludePath = "testdata/export_exclude"
+
+	// exportHooksPath is a directory with a project with the profiles that
+	// run the "preExport" and "postExport" commands, some of which fail.
+	exportHooksPath = "testdata/export_hooks"
+
+	// exportReportPath is a directory with a project with the profiles that
+	// write the export report to a file and to the logs.
+	exportReportPath = "testdata/export_report"
+
+	// adbExportPath is a directory with a project with the profiles that
+	// push the packs to an Android device, and a fake "adb" command in the
+	// "bin" directory, which logs its arguments.
+	adbExportPath = "testdata/adb_export"
+
+	// gitFiltersPath is a directory with a project that uses the "hello"
+	// remote filter from a local git repository (see filterRepo). The
+	// "FILTER_REPO_URL" placeholder is replaced by the tests with the URL of
+	// the repository.
+	gitFiltersPath = "testdata/git_filters"
+
+	// parallelInstallPath is a directory with a project that uses eight
+	// remote filters ("hello1" to "hello8") from the same local git
+	// repository, which are installed in parallel.
+	parallelInstallPath = "testdata/parallel_install"
+
+	// archiveFiltersPath is a directory with a project that uses the "hello"
+	// remote filter from a zip archive. The "ARCHIVE_URL" and
+	// "ARCHIVE_CHECKSUM" placeholders are replaced by the tests.
+	archiveFiltersPath = "testdata/archive_filters"
+
+	// pythonVenvsPath is a directory with a project with four Python filters
+	// with empty requirements, which write the paths to their venvs to the
+	// behavior pack. The "c" and "d" filters share the venv of a venv slot.
+	pythonVenvsPath = "testdata/python_venvs"
+
+	// nodePackagesPath is a directory with a project with two Node.js
+	// filters with package.json files, and fake "npm", "pnpm" and "yarn"
+	// commands in the "bin" directory, which log their arguments. The "yarn"
+	// filter uses the "packageManager" property.
+	nodePackagesPath = "testdata/node_packages"
+
+	// pythonVersionsPath is a directory with a project with two Python
+	// filters that require different Python versions, and fake Python 3.98
+	// and 3.99 interpreters in the "bin" directory. The filters write the
+	// versions of the interpreters that run them to the BP.
+	pythonVersionsPath = "testdata/python_versions"
+
+	// jsoncConfigPath is a directory with a project whose config.json has a
+	// byte order mark, comments and trailing commas. The Lua filter writes
+	// the message from its settings, which looks like a comment, to the BP.
+	jsoncConfigPath = "testdata/jsonc_config"
+
+	// additionalPacksPath is a directory with a project with two additional
+	// packs: "hd" with a resource pack and "addon" with both packs. The Lua
+	// filter reads and writes the files of the additional packs. The
+	// "archive" profile uses an export target that doesn't support them.
+	additionalPacksPath = "testdata/additional_packs"
+
+	// workspacePath is a directory with a workspace of three projects. The
+	// "addon" project depends on the "library" project. The Lua filter of
+	// every project writes the name of the project to the BP.
+	workspacePath = "testdata/workspace"
+
+	// configMigratePath is a directory with a project whose config.json
+	// doesn't have the format version, and uses the deprecated "script"
+	// property of the Java filters.
+	configMigratePath = "testdata/config_migrate"
+
+	// secretsPath is a directory with a fake "secret-tool" command in the
+	// "bin" directory, which saves the secrets as files in the directory from
+	// an environment variable.
+	secretsPath = "testdata/secrets"
+
+	// changedFilesPath is a directory with a project with a shell filter,
+	// which copies the list of the changed files from the watch mode to the
+	// BP, or writes "none" outside of the watch mode.
+	changedFilesPath = "testdata/changed_files"
+
+	// filterResumePath is a directory with a project whose filters declare
+	// their inputs and outputs. The "entities" filter uses the output of the
+	// "textures" filter, and both of them count their runs in the files in
+	// the root of the project.
+	filterResumePath = "testdata/filter_resume"
 )
 
 // firstErr returns the first error in a list of errors. If the list is empty
@@ -76,7 +293,10 @@ func listPaths(path string, root string) (map[string]string, error) {
 			if err != nil {
 				return err
 			}
-			if data.Name() == ".ignoreme" || data.Name() == "lockfile.txt" { // Ignored file
+			// The content of regolith-lock.json depends on the current
+			// versions of the remote filters
+			if data.Name() == ".ignoreme" || data.Name() == "lockfile.txt" ||
+				data.Name() == "regolith-lock.json" { // Ignored file
 				return nil
 			}
 			relPath, err := filepath.Rel(root, path)
@@ -148,3 +368,333 @@ func comparePathMaps(
 		}
 	}
 }
+
+// prepareTestProject copies the "project" directory from the path to a
+// temporary directory, changes the working directory to it and unlocks
+// Regolith. It returns the path to the temporary directory and a function that
+// restores the working directory and removes the temporary directory.
+func prepareTestProject(t *testing.T, path string) (string, func()) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	// Create a temporary directory
+	tmpDir, err := ioutil.TempDir("", "regolith-test")
+	if err != nil {
+		t.Fatal("Unable to create temporary directory:", err)
+	}
+	t.Log("Created temporary directory:", tmpDir)
+	// Before deleting "workingDir" the test must stop using it
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(tmpDir)
+	}
+	// Copy the test project to the working directory
+	project := filepath.Join(path, "project")
+	err = copy.Copy(
+		project,
+		tmpDir,
+		copy.Options{PreserveTimes: false, Sync: false},
+	)
+	if err != nil {
+		cleanup()
+		t.Fatalf(
+			"Failed to copy test files from %q into the working directory %q",
+			project, tmpDir,
+		)
+	}
+	os.Chdir(tmpDir)
+	if err := regolith.Unlock(true); err != nil {
+		cleanup()
+		t.Fatal("'regolith unlock' failed:", err.Error())
+	}
+	return tmpDir, cleanup
+}
+
+// writeTestFile writes the file and creates its parent directories.
+func writeTestFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal("Unable to create the directory:", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Unable to write the file:", err)
+	}
+}
+
+// expectFileContent checks if the file exists and has the expected content.
+func expectFileContent(t *testing.T, path, expected string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("Unable to read %q: %s", path, err)
+		return
+	}
+	if string(content) != expected {
+		t.Errorf(
+			"Unexpected content of %q: %q, expected %q",
+			path, content, expected)
+	}
+}
+
+// expectNotExist checks if the path doesn't exist.
+func expectNotExist(t *testing.T, path string) {
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("%q exists, but it should be removed", path)
+	} else if !os.IsNotExist(err) {
+		t.Errorf("Unable to check if %q exists: %s", path, err)
+	}
+}
+
+// takeCommandLog returns the content of the log of a fake command used by the
+// tests (like "sftp" or "adb") and clears it.
+func takeCommandLog(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal("Unable to read the log of the command:", err)
+	}
+	os.Remove(path)
+	return string(data)
+}
+
+// captureLogs replaces the logger of Regolith with a logger that records
+// the messages, so the tests can check them. It returns the recorded
+// messages and a function that restores the previous logger.
+func captureLogs() (*observer.ObservedLogs, func()) {
+	logger, level := regolith.Logger, regolith.LoggerLevel
+	core, logs := observer.New(zap.DebugLevel)
+	regolith.SetLogger(
+		zap.New(core).Sugar(), zap.NewAtomicLevelAt(zap.DebugLevel))
+	return logs, func() {
+		regolith.SetLogger(logger, level)
+	}
+}
+
+// replaceInTestFile replaces all occurrences of the placeholder in the file,
+// for example with the paths and the URLs created by the tests.
+func replaceInTestFile(t *testing.T, path, placeholder, value string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read %q: %s", path, err)
+	}
+	writeTestFile(
+		t, path, strings.ReplaceAll(string(content), placeholder, value))
+}
+
+// isolateUserDirs sets the environment variables used by os.UserConfigDir
+// and os.UserCacheDir to a temporary directory, so the tests don't use the
+// user config and the shared caches of the user. The user config lists only
+// an empty filter registry from a local file, so the tests don't download
+// the default registry. It returns the path to the user config file.
+func isolateUserDirs(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home) // macOS
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("APPDATA", filepath.Join(home, "AppData", "Roaming"))
+	t.Setenv("LOCALAPPDATA", filepath.Join(home, "AppData", "Local"))
+	registry := filepath.Join(home, "registry.json")
+	writeTestFile(
+		t, registry, "{\"formatVersion\": \"1.0.0\", \"filters\": {}}\n")
+	configPath, err := regolith.GetUserConfigPath()
+	if err != nil {
+		t.Fatal("Unable to get the path to the user config:", err)
+	}
+	registries, _ := json.Marshal([]string{registry})
+	writeTestFile(
+		t, configPath, "{\"registries\": "+string(registries)+"}\n")
+	return configPath
+}
+
+// setUserConfigProperty sets the property of the user config created by
+// isolateUserDirs.
+func setUserConfigProperty(
+	t *testing.T, configPath, property string, value interface{},
+) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal("Unable to read the user config:", err)
+	}
+	userConfig := make(map[string]interface{})
+	if err := json.Unmarshal(data, &userConfig); err != nil {
+		t.Fatal("Unable to parse the user config:", err)
+	}
+	userConfig[property] = value
+	data, _ = json.MarshalIndent(userConfig, "", "\t")
+	writeTestFile(t, configPath, string(data)+"\n")
+}
+
+// filterRepo is a local git repository with remote filters. The tests of
+// the remote filters use it instead of the repositories on GitHub, so they
+// don't depend on the network.
+type filterRepo struct {
+	t *testing.T
+	// path is the path to the repository
+	path string
+	// url is the URL of the repository used in the filter definitions
+	url string
+}
+
+// newFilterRepo creates an empty filterRepo in a temporary directory. The
+// test is skipped if git is not installed.
+func newFilterRepo(t *testing.T) *filterRepo {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Git is not installed")
+	}
+	path := t.TempDir()
+	url := "file://" + filepath.ToSlash(path)
+	if !strings.HasPrefix(url, "file:///") { // Windows paths
+		url = "file:///" + filepath.ToSlash(path)
+	}
+	r := &filterRepo{t: t, path: path, url: url}
+	r.git("init", "--quiet")
+	return r
+}
+
+// git runs a git command in the repository and returns its output.
+func (r *filterRepo) git(args ...string) string {
+	cmd := exec.Command("git", append([]string{
+		"-c", "user.name=Regolith", "-c", "user.email=regolith@example.com",
+		"-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false",
+	}, args...)...)
+	cmd.Dir = r.path
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("'git %s' failed: %s\n%s", args[0], err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit writes the files (with the paths relative to the root of the
+// repository) and commits them. If the tag isn't empty, the commit is tagged
+// with it. It returns the SHA of the commit.
+func (r *filterRepo) commit(files map[string]string, tag string) string {
+	for path, content := range files {
+		writeTestFile(
+			r.t, filepath.Join(r.path, filepath.FromSlash(path)), content)
+	}
+	r.git("add", "--all")
+	r.git("commit", "--quiet", "--message", "Update the filters")
+	if tag != "" {
+		r.git("tag", tag)
+	}
+	return r.git("rev-parse", "HEAD")
+}
+
+// luaFilterFiles returns the files of a remote filter for
+// filterRepo.commit. The filter is a Lua filter that writes the message to
+// "BP/<name>.txt".
+func luaFilterFiles(name, message string) map[string]string {
+	return map[string]string{
+		name + "/filter.json": "{\"filters\": [" +
+			"{\"runWith\": \"lua\", \"script\": \"" + name + ".lua\"}]}\n",
+		name + "/" + name + ".lua": "require(\"regolith\").write_file(" +
+			"\"BP/" + name + ".txt\", \"" + message + "\")\n",
+	}
+}
+
+// helloFilterFiles returns the files of the "hello" filter (see
+// luaFilterFiles).
+func helloFilterFiles(message string) map[string]string {
+	return luaFilterFiles("hello", message)
+}
+
+// testDaemon is the daemon of the project in the current working directory
+// (see regolith.Serve), started by startTestDaemon. The fields are loaded
+// from the file with the address of the daemon in the .regolith directory.
+type testDaemon struct {
+	Address     string `json:"address"`
+	Token       string `json:"token"`
+	HttpAddress string `json:"httpAddress"`
+}
+
+// startTestDaemon starts the daemon of the project in the current working
+// directory in a new goroutine, with the HTTP API on the httpPort if it isn't
+// 0, and waits until the daemon is ready. It returns the daemon and a
+// function that stops it.
+func startTestDaemon(t *testing.T, httpPort int) (testDaemon, func()) {
+	done := make(chan error, 1)
+	go func() { done <- regolith.Serve(httpPort, true) }()
+	var daemon testDaemon
+	infoPath := filepath.Join(".regolith", "serve.json")
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		data, err := os.ReadFile(infoPath)
+		if err == nil && json.Unmarshal(data, &daemon) == nil {
+			break
+		}
+		select {
+		case err := <-done:
+			t.Fatal("The daemon stopped before it was ready:", err)
+		default:
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("The daemon didn't start.")
+		}
+	}
+	stop := func() {
+		if err := regolith.StopServe(true); err != nil {
+			t.Error("'regolith serve --stop' failed:", err.Error())
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error("'regolith serve' failed:", err.Error())
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("The daemon didn't stop.")
+		}
+	}
+	return daemon, stop
+}
+
+// send sends the request (without the token) to the daemon. It returns the
+// connection with the response, which stops the "watch" command when it's
+// closed.
+func (d testDaemon) send(
+	t *testing.T, request map[string]interface{},
+) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", d.Address)
+	if err != nil {
+		t.Fatal("Unable to connect to the daemon:", err)
+	}
+	request["token"] = d.Token
+	data, _ := json.Marshal(request)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		t.Fatal("Unable to send the request to the daemon:", err)
+	}
+	return conn, bufio.NewReader(conn)
+}
+
+// awaitDaemonMessage reads the response of the daemon until it receives a
+// log message with the snippet. It fails if the response ends first. It
+// returns the error of the command if the snippet is empty and the response
+// ended.
+func awaitDaemonMessage(
+	t *testing.T, conn net.Conn, reader *bufio.Reader, snippet string,
+) string {
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("Missing the message %q from the daemon: %s", snippet, err)
+		}
+		var response struct {
+			Message string `json:"message"`
+			Done    bool   `json:"done"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatalf("Invalid response of the daemon: %q", line)
+		}
+		if response.Done && snippet == "" {
+			return response.Error
+		}
+		if response.Done {
+			t.Fatalf(
+				"The command ended without the message %q: %s",
+				snippet, response.Error)
+		}
+		if snippet != "" && strings.Contains(response.Message, snippet) {
+			return ""
+		}
+	}
+}
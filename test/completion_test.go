@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/urfave/cli/v2"
+)
+
+// complete runs an app with the completions of the Regolith commands, like
+// the completion scripts do, and returns the printed completions. The
+// arguments are the arguments of the command line, without the
+// "--generate-bash-completion" flag.
+func complete(t *testing.T, args ...string) string {
+	var output bytes.Buffer
+	app := &cli.App{
+		Name:                 "regolith",
+		EnableBashCompletion: true,
+		Writer:               &output,
+		Commands: []*cli.Command{
+			{
+				Name:         "run",
+				Flags:        []cli.Flag{&cli.BoolFlag{Name: "recycled"}},
+				BashComplete: regolith.CompleteProfiles,
+			},
+			{Name: "update", BashComplete: regolith.CompleteFilters},
+			{Name: "completion", BashComplete: regolith.CompleteShells},
+		},
+	}
+	// The completions of the flags check the arguments of the program
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	os.Args = append(
+		append([]string{"regolith"}, args...), "--generate-bash-completion")
+	if err := app.Run(os.Args); err != nil {
+		t.Fatalf("Completion of %v failed: %s", args, err.Error())
+	}
+	return output.String()
+}
+
+// TestCompletion checks the completion scripts of the shells and the
+// completions of the names of the profiles, the filters and the shells,
+// including the profiles from the local config.
+func TestCompletion(t *testing.T) {
+	// THE TEST
+	t.Log("Printing the completion scripts...")
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		var err error
+		script := captureStdout(t, func() {
+			err = regolith.Completion(shell, true)
+		})
+		if err != nil {
+			t.Fatalf("'regolith completion %s' failed: %s", shell, err.Error())
+		}
+		if !strings.Contains(script, "--generate-bash-completion") {
+			t.Errorf("Invalid completion script of %s:\n%s", shell, script)
+		}
+	}
+	err := regolith.Completion("tcsh", true)
+	if err == nil || !strings.Contains(
+		err.Error(), "bash, zsh, fish, powershell") {
+		t.Error("Expected an error with the supported shells, got:", err)
+	}
+
+	t.Log("Completing the arguments outside of a project...")
+	restoreDir := enterEmptyDir(t)
+	profiles := complete(t, "run")
+	restoreDir()
+	if profiles != "" {
+		t.Errorf("Unexpected completions outside of a project:\n%s", profiles)
+	}
+
+	t.Log("Completing the arguments in a project...")
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+	writeTestFile(
+		t, regolith.LocalConfigFilePath,
+		"{\"regolith\": {\"profiles\": {\"local\": {\"filters\": [], "+
+			"\"export\": {\"target\": \"local\"}}}}}")
+	// The completions of the arguments, separated with spaces
+	expected := map[string]string{
+		"run":                    "circular_needs dev files local unknown_need",
+		"run dev":                "",
+		"run --rec":              "--recycled",
+		"update":                 "combine generate_a generate_b skipped",
+		"update combine skipped": "generate_a generate_b",
+		"completion":             "bash zsh fish powershell",
+		"completion bash":        "",
+	}
+	for args, values := range expected {
+		actual := strings.Join(
+			strings.Fields(complete(t, strings.Fields(args)...)), " ")
+		if actual != values {
+			t.Errorf(
+				"Wrong completions of %q.\nExpected: %q\nActual: %q",
+				args, values, actual)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/otiai10/copy"
+)
+
+// TestConfigEdit tests if the "regolith config" command changes only the
+// edited values of config.json, keeping the comments and the order of the
+// properties.
+func TestConfigEdit(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	defer os.Chdir(wd)
+	expected, err := os.ReadFile(
+		filepath.Join(configEditPath, "expected", "config.json"))
+	if err != nil {
+		t.Fatal("Unable to read the expected config:", err)
+	}
+	// Create a temporary directory
+	tmpDir, err := ioutil.TempDir("", "regolith-test")
+	if err != nil {
+		t.Fatal("Unable to create temporary directory:", err)
+	}
+	t.Log("Created temporary directory:", tmpDir)
+	// Before deleting "workingDir" the test must stop using it
+	defer os.RemoveAll(tmpDir)
+	defer os.Chdir(wd)
+	// Copy the test project to the working directory
+	project := filepath.Join(configEditPath, "project")
+	err = copy.Copy(
+		project,
+		tmpDir,
+		copy.Options{PreserveTimes: false, Sync: false},
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to copy test files from %q into the working directory %q",
+			project, tmpDir,
+		)
+	}
+	// THE TEST
+	os.Chdir(tmpDir)
+	if err := regolith.ConfigSet("author", "Tester", false, true); err != nil {
+		t.Fatal("'regolith config set' failed:", err)
+	}
+	err = regolith.ConfigSet("profiles.dev.export.readOnly", "true", false, true)
+	if err != nil {
+		t.Fatal("'regolith config set' failed:", err)
+	}
+	if err := regolith.ConfigUnset("$schema", false, true); err != nil {
+		t.Fatal("'regolith config unset' failed:", err)
+	}
+	actual, err := os.ReadFile("config.json")
+	if err != nil {
+		t.Fatal("Unable to read the edited config:", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf(
+			"Unexpected content of the edited config:\n%s\nExpected:\n%s",
+			actual, expected)
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestConfigMerge tests merging the local config (config.local.json) over
+// config.json and resolving the profiles that extend other profiles.
+func TestConfigMerge(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	defer os.Chdir(wd)
+	// The test only reads the project, so it doesn't need a copy
+	os.Chdir(filepath.Join(configMergePath, "project"))
+	// THE TEST
+	configJson, err := regolith.LoadConfigAsMap()
+	if err != nil {
+		t.Fatal("Failed to load the config:", err)
+	}
+	config, err := regolith.ConfigFromObject(configJson)
+	if err != nil {
+		t.Fatal("Failed to parse the config:", err)
+	}
+	// filter returns the filter of the profile with the index
+	filter := func(profile regolith.Profile, i int) *regolith.LuaFilter {
+		if i >= len(profile.Filters) {
+			t.Fatalf("The profile doesn't have the filter %d", i)
+		}
+		result, ok := profile.Filters[i].(*regolith.LuaFilter)
+		if !ok {
+			t.Fatalf("The filter %d isn't a Lua filter", i)
+		}
+		return result
+	}
+	// The "base" profile is only changed by the local config
+	base := config.Profiles["base"]
+	if len(base.Filters) != 2 {
+		t.Fatalf(
+			"The \"base\" profile has %d filters, expected 2",
+			len(base.Filters))
+	}
+	if level := filter(base, 0).Settings["level"]; level != 2.0 {
+		t.Errorf(
+			"The local config didn't override the settings of the filter: "+
+				"level = %v", level)
+	}
+	if filter(base, 1).Disabled {
+		t.Error("The local config of the \"dev\" profile changed the " +
+			"\"base\" profile")
+	}
+	if base.ExportTarget.Target != "development" ||
+		base.ExportTarget.ReadOnly {
+		t.Errorf(
+			"Unexpected export target of the \"base\" profile: %+v",
+			base.ExportTarget)
+	}
+	// The "dev" profile extends the "base" profile after merging the local
+	// config
+	dev := config.Profiles["dev"]
+	expectedIds := []string{"a", "b", "c"}
+	if len(dev.Filters) != len(expectedIds) {
+		t.Fatalf(
+			"The \"dev\" profile has %d filters, expected %d",
+			len(dev.Filters), len(expectedIds))
+	}
+	for i, id := range expectedIds {
+		if dev.Filters[i].GetId() != id {
+			t.Errorf(
+				"Unexpected filter %d of the \"dev\" profile: %q, expected %q",
+				i, dev.Filters[i].GetId(), id)
+		}
+	}
+	if level := filter(dev, 0).Settings["level"]; level != 2.0 {
+		t.Errorf(
+			"The \"dev\" profile didn't inherit the settings of the filter "+
+				"from the local config: level = %v", level)
+	}
+	if !filter(dev, 1).Disabled {
+		t.Error("The local config didn't disable the filter of the " +
+			"extended profile")
+	}
+	if dev.ExportTarget.Target != "local" || !dev.ExportTarget.ReadOnly {
+		t.Errorf(
+			"Unexpected export target of the \"dev\" profile: %+v",
+			dev.ExportTarget)
+	}
+}
+
+// TestProfileInheritance checks the chains of the profiles that extend other
+// profiles and the errors of the invalid "extends" properties.
+func TestProfileInheritance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(minimalProjectPath, "config.json"))
+	if err != nil {
+		t.Fatal("Unable to read the config:", err)
+	}
+	// parseConfig parses the minimal config with the additional profiles
+	parseConfig := func(
+		profiles map[string]interface{},
+	) (*regolith.Config, error) {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			t.Fatal("Unable to parse the config:", err)
+		}
+		for name, profile := range profiles {
+			jsonObject(t, obj, "regolith", "profiles")[name] = profile
+		}
+		return regolith.ConfigFromObject(obj)
+	}
+
+	// THE TEST
+	t.Log("Parsing a chain of the profiles...")
+	config, err := parseConfig(map[string]interface{}{
+		"a": map[string]interface{}{"extends": "b"},
+		"b": map[string]interface{}{
+			"extends": "dev",
+			"export":  map[string]interface{}{"readOnly": true},
+		},
+	})
+	if err != nil {
+		t.Fatal("Unable to parse the config:", err.Error())
+	}
+	if export := config.Profiles["a"].ExportTarget; export.Target !=
+		"development" || !export.ReadOnly {
+		t.Errorf("Unexpected export target of the \"a\" profile: %+v", export)
+	}
+
+	t.Log("Parsing the invalid profiles...")
+	for expected, profiles := range map[string]map[string]interface{}{
+		"Cycle: a -> b -> a": {
+			"a": map[string]interface{}{"extends": "b"},
+			"b": map[string]interface{}{"extends": "a"},
+		},
+		"JSON Path: regolith->profiles->a->extends\nProfile: missing": {
+			"a": map[string]interface{}{"extends": "missing"},
+		},
+		"regolith->profiles->a->extends": {
+			"a": map[string]interface{}{"extends": 1.0},
+		},
+	} {
+		_, err := parseConfig(profiles)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf(
+				"Expected an error containing %q, got: %v", expected, err)
+		}
+	}
+}
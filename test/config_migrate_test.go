@@ -0,0 +1,71 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// readConfigFile returns the config.json file of the project from the
+// current working directory, parsed to a map.
+func readConfigFile(t *testing.T) map[string]interface{} {
+	data, err := os.ReadFile(regolith.ConfigFilePath)
+	if err != nil {
+		t.Fatal("Unable to read the config:", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal("Unable to parse the config:", err)
+	}
+	return result
+}
+
+// TestConfigMigrate upgrades a config without the format version to the
+// latest format version with "regolith migrate".
+func TestConfigMigrate(t *testing.T) {
+	_, cleanup := prepareTestProject(t, configMigratePath)
+	defer cleanup()
+	original, err := os.ReadFile(regolith.ConfigFilePath)
+	if err != nil {
+		t.Fatal("Unable to read the config:", err)
+	}
+
+	// THE TEST
+	t.Log("Migrating the config in the dry run...")
+	if err := regolith.Migrate(true, true); err != nil {
+		t.Fatal("'regolith migrate --dry-run' failed:", err.Error())
+	}
+	expectFileContent(t, regolith.ConfigFilePath, string(original))
+
+	t.Log("Migrating the config...")
+	if err := regolith.Migrate(false, true); err != nil {
+		t.Fatal("'regolith migrate' failed:", err.Error())
+	}
+	config := readConfigFile(t)
+	regolithObj := jsonObject(t, config, "regolith")
+	if version := regolithObj["formatVersion"]; version !=
+		regolith.LatestConfigFormatVersion {
+		t.Errorf("Unexpected format version: %v", version)
+	}
+	java := jsonObject(t, regolithObj, "filterDefinitions", "java")
+	if _, ok := java["script"]; ok || java["path"] != "./filters/java.jar" {
+		t.Errorf("The Java filter wasn't migrated: %v", java)
+	}
+	if err := regolith.Migrate(false, true); err != nil {
+		t.Fatal("'regolith migrate' failed for the migrated config:",
+			err.Error())
+	}
+
+	t.Log("Loading a config from a newer version of Regolith...")
+	regolithObj["formatVersion"] = "99.0.0"
+	if _, err := regolith.ConfigFromObject(config); err == nil {
+		t.Fatal("The config with a newer format version was accepted.")
+	}
+	data, _ := json.Marshal(config) // no error
+	writeTestFile(t, regolith.ConfigFilePath, string(data))
+	if err := regolith.Migrate(false, true); err == nil {
+		t.Fatal("'regolith migrate' accepted a newer format version.")
+	}
+}
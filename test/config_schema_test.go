@@ -0,0 +1,121 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// jsonObject returns the JSON object from the path of the properties in the
+// parsed JSON.
+func jsonObject(
+	t *testing.T, obj map[string]interface{}, path ...string,
+) map[string]interface{} {
+	for _, key := range path {
+		child, ok := obj[key].(map[string]interface{})
+		if !ok {
+			t.Fatalf("The %q property isn't an object.", key)
+		}
+		obj = child
+	}
+	return obj
+}
+
+// TestConfigSchema checks if the errors of the invalid config.json files
+// show the JSON paths of the invalid properties and the suggestions how to
+// fix them.
+func TestConfigSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(minimalProjectPath, "config.json"))
+	if err != nil {
+		t.Fatal("Unable to read the config:", err)
+	}
+	cases := []struct {
+		name     string
+		edit     func(config map[string]interface{})
+		expected []string
+	}{
+		{
+			name:     "valid config",
+			edit:     func(config map[string]interface{}) {},
+			expected: nil,
+		},
+		{
+			name: "misspelled export target",
+			edit: func(config map[string]interface{}) {
+				export := jsonObject(t, config, "regolith", "profiles", "dev",
+					"export")
+				export["target"] = "developmnet"
+			},
+			expected: []string{
+				"JSON Path: regolith->profiles->dev->export->target\n" +
+					"Invalid value: \"developmnet\"",
+				"Suggestion: Did you mean \"development\"?",
+			},
+		},
+		{
+			name: "wrong types",
+			edit: func(config map[string]interface{}) {
+				config["name"] = 1.0
+				jsonObject(t, config, "regolith")["watchDelay"] = "500"
+			},
+			expected: []string{
+				"JSON Path: name\nExpected type: string\nFound type: integer",
+				"Suggestion: Put the value in quotes: \"1\"",
+				"JSON Path: regolith->watchDelay",
+				"Suggestion: Remove the quotes: 500",
+			},
+		},
+		{
+			name: "misspelled required property",
+			edit: func(config map[string]interface{}) {
+				regolithObj := jsonObject(t, config, "regolith")
+				regolithObj["datapath"] = regolithObj["dataPath"]
+				delete(regolithObj, "dataPath")
+			},
+			expected: []string{
+				"JSON Path: regolith->dataPath\n" +
+					"Required property is missing.",
+				"Suggestion: Rename the \"datapath\" property to \"dataPath\".",
+			},
+		},
+		{
+			name: "value out of range",
+			edit: func(config map[string]interface{}) {
+				jsonObject(t, config, "regolith")["watchDelay"] = -1.0
+			},
+			expected: []string{"The value must be at least 0.\nFound: -1"},
+		},
+	}
+
+	// THE TEST
+	for _, c := range cases {
+		t.Log("Parsing the config with the " + c.name + "...")
+		var config map[string]interface{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatal("Unable to parse the config:", err)
+		}
+		c.edit(config)
+		_, err := regolith.ConfigFromObject(config)
+		if len(c.expected) == 0 {
+			if err != nil {
+				t.Errorf("The valid config was rejected: %s", err.Error())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("The config with the %s was accepted.", c.name)
+			continue
+		}
+		for _, expected := range c.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf(
+					"The error doesn't contain the expected message.\n"+
+						"Expected:\n%s\nActual:\n%s", expected, err.Error())
+			}
+		}
+	}
+}
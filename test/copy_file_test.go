@@ -0,0 +1,94 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestCopyFile copies the files with CopyFile, which clones them on the file
+// systems with the copy-on-write support and copies them on the other file
+// systems. The copies have the same contents as the source files, and
+// changing them doesn't change the source files.
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.bin")
+	data := bytes.Repeat([]byte("regolith"), 1<<17) // 1 MiB
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal("Unable to create the source file:", err)
+	}
+	target := filepath.Join(dir, "nested", "target.bin")
+
+	// THE TEST
+	t.Log("Copying the file...")
+	if err := regolith.CopyFile(source, target); err != nil {
+		t.Fatal("CopyFile failed:", err.Error())
+	}
+	if copied, err := os.ReadFile(target); err != nil ||
+		!bytes.Equal(copied, data) {
+		t.Fatal("The copy doesn't match the source file:", err)
+	}
+
+	t.Log("Changing the copy...")
+	writeTestFile(t, target, "changed")
+	if original, err := os.ReadFile(source); err != nil ||
+		!bytes.Equal(original, data) {
+		t.Fatal("Changing the copy changed the source file:", err)
+	}
+
+	t.Log("Copying a file over the larger file...")
+	small := filepath.Join(dir, "small.txt")
+	writeTestFile(t, small, "small")
+	if err := regolith.CopyFile(small, source); err != nil {
+		t.Fatal("CopyFile failed:", err.Error())
+	}
+	expectFileContent(t, source, "small")
+}
+
+// testCopyManyFiles runs a project with hundreds of files in the nested
+// directories of the resource pack, which are copied in parallel to the tmp
+// directory and to the export target. The exported pack must be identical
+// to the source pack.
+func testCopyManyFiles(t *testing.T, recycled bool) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	rp := filepath.Join("packs", "RP")
+	for i := 0; i < 500; i++ {
+		writeTestFile(
+			t, filepath.Join(rp, fmt.Sprint(i%7), fmt.Sprint(i%5),
+				fmt.Sprintf("%d.json", i)),
+			fmt.Sprintf("{\"file\": %d}", i))
+	}
+
+	// THE TEST
+	for i := 0; i < 2; i++ {
+		t.Logf("Running the project (%d)...", i+1)
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		expectedPaths, err := listPaths(rp, rp)
+		if err != nil {
+			t.Fatal("Unable to list the source files:", err)
+		}
+		exported := filepath.Join("build", "RP")
+		createdPaths, err := listPaths(exported, exported)
+		if err != nil {
+			t.Fatal("Unable to list the exported files:", err)
+		}
+		comparePathMaps(expectedPaths, createdPaths, t)
+		writeTestFile(t, filepath.Join(rp, "0", "0", "0.json"), "{}")
+	}
+}
+
+func TestCopyManyFiles(t *testing.T) {
+	testCopyManyFiles(t, false)
+}
+
+func TestCopyManyFilesRecycled(t *testing.T) {
+	testCopyManyFiles(t, true)
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestDenoFilterPermissions checks the default permissions of the Deno
+// filters and the validation of the "permissions" property.
+func TestDenoFilterPermissions(t *testing.T) {
+	filter, err := regolith.DenoFilterDefinitionFromObject(
+		"test", map[string]interface{}{"script": "./filters/test.ts"})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	if len(filter.Permissions) != 2 ||
+		filter.Permissions[0] != "--allow-read" ||
+		filter.Permissions[1] != "--allow-write" {
+		t.Fatalf("Unexpected default permissions: %v", filter.Permissions)
+	}
+	filter, err = regolith.DenoFilterDefinitionFromObject(
+		"test", map[string]interface{}{
+			"script":      "./filters/test.ts",
+			"permissions": []interface{}{"--allow-net=example.com"},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	if len(filter.Permissions) != 1 ||
+		filter.Permissions[0] != "--allow-net=example.com" {
+		t.Fatalf("Unexpected permissions: %v", filter.Permissions)
+	}
+	for _, permissions := range []interface{}{
+		"--allow-read",
+		[]interface{}{"--reload"},
+		[]interface{}{1},
+	} {
+		_, err = regolith.DenoFilterDefinitionFromObject(
+			"test", map[string]interface{}{
+				"script":      "./filters/test.ts",
+				"permissions": permissions,
+			})
+		if err == nil {
+			t.Errorf("The invalid permissions were accepted: %v", permissions)
+		}
+	}
+}
+
+// testDenoFilter runs the profiles of a project with a Deno filter. The
+// "dev" profile checks if the filter gets its settings and can write the
+// files, and the "denied" profile checks if the filter without the
+// permission to write the files fails. The test is skipped if Deno isn't
+// installed.
+func testDenoFilter(t *testing.T, recycled bool) {
+	if _, err := exec.LookPath("deno"); err != nil {
+		t.Skip("Deno isn't installed")
+	}
+	tmpDir, cleanup := prepareTestProject(t, denoFilterPath)
+	defer cleanup()
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "message.txt"),
+		"Hello from Deno")
+	if err := regolith.Run("denied", nil, recycled, true); err == nil {
+		t.Fatal(
+			"'regolith run' succeeded, but the filter doesn't have the " +
+				"permission to write files")
+	}
+}
+
+func TestDenoFilter(t *testing.T) {
+	testDenoFilter(t, false)
+}
+
+func TestDenoFilterRecycled(t *testing.T) {
+	testDenoFilter(t, true)
+}
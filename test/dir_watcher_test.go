@@ -0,0 +1,146 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// startWatcher runs WaitForChangeGroup of the watcher in a new goroutine,
+// with the given delay (in milliseconds). The interruption is sent to the
+// channel with the "watcher" message.
+func startWatcher(
+	watcher *regolith.DirWatcher, delay uint32, interruption chan string,
+) {
+	go watcher.WaitForChangeGroup(delay, interruption, "watcher")
+}
+
+// expectInterruption checks if the channel receives the interruption (or
+// doesn't receive it, if expected is false) within the timeout.
+func expectInterruption(
+	t *testing.T, interruption chan string, expected bool,
+	timeout time.Duration,
+) {
+	select {
+	case message := <-interruption:
+		if !expected {
+			t.Fatalf("Unexpected interruption: %q", message)
+		}
+	case <-time.After(timeout):
+		if expected {
+			t.Fatal("The change wasn't detected.")
+		}
+	}
+}
+
+// TestDirWatcher watches a directory with the files that match the
+// "watchIgnore" patterns and the default ignore patterns. The changes of the
+// ignored files don't interrupt the watcher, and the new subdirectories are
+// watched.
+func TestDirWatcher(t *testing.T) {
+	defer enterEmptyDir(t)()
+	rp := filepath.Join("packs", "RP")
+	writeTestFile(t, filepath.Join(rp, ".git", "HEAD"), "main\n")
+	writeTestFile(t, filepath.Join(rp, "textures", "a.png"), "")
+	project, err := regolith.RegolithProjectFromObject(
+		map[string]interface{}{
+			"dataPath":    "packs/data",
+			"watchIgnore": []interface{}{"packs/RP/**/*.psd"},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the project:", err.Error())
+	}
+
+	// THE TEST
+	t.Log("Parsing the invalid watchIgnore patterns...")
+	for _, pattern := range []interface{}{"", "[", 1.0} {
+		_, err := regolith.RegolithProjectFromObject(
+			map[string]interface{}{
+				"dataPath":    "packs/data",
+				"watchIgnore": []interface{}{pattern},
+			})
+		if err == nil {
+			t.Errorf("Accepted the watchIgnore pattern: %v", pattern)
+		}
+	}
+
+	t.Log("Changing the ignored files...")
+	watcher, err := regolith.NewDirWatcher(rp, project.WatchIgnore)
+	if err != nil {
+		t.Fatal("Unable to watch the directory:", err.Error())
+	}
+	defer watcher.Close()
+	interruption := make(chan string, 10)
+	startWatcher(watcher, 50, interruption)
+	writeTestFile(t, filepath.Join(rp, ".git", "HEAD"), "other\n")
+	writeTestFile(t, filepath.Join(rp, "textures", "a.psd"), "")
+	writeTestFile(t, filepath.Join(rp, "textures", "a.png.swp"), "")
+	expectInterruption(t, interruption, false, 500*time.Millisecond)
+
+	t.Log("Changing the watched files...")
+	writeTestFile(t, filepath.Join(rp, "textures", "a.png"), "1")
+	expectInterruption(t, interruption, true, 5*time.Second)
+
+	t.Log("Changing a file in a new directory...")
+	startWatcher(watcher, 50, interruption)
+	if err := os.Mkdir(filepath.Join(rp, "entities"), 0755); err != nil {
+		t.Fatal("Unable to create the directory:", err)
+	}
+	expectInterruption(t, interruption, true, 5*time.Second)
+	startWatcher(watcher, 50, interruption)
+	writeTestFile(t, filepath.Join(rp, "entities", "a.json"), "{}")
+	expectInterruption(t, interruption, true, 5*time.Second)
+}
+
+// TestDirWatcherDelay changes the files of a watched directory many times in
+// short intervals. The changes are reported as one interruption, after the
+// "watchDelay" without changes.
+func TestDirWatcherDelay(t *testing.T) {
+	defer enterEmptyDir(t)()
+	writeTestFile(t, filepath.Join("packs", "BP", "a.json"), "{}")
+	project, err := regolith.RegolithProjectFromObject(
+		map[string]interface{}{"dataPath": "packs/data"})
+	if err != nil {
+		t.Fatal("Unable to parse the project:", err.Error())
+	}
+
+	// THE TEST
+	t.Log("Parsing the watchDelay...")
+	if project.WatchDelay != 100 {
+		t.Errorf("Wrong default watchDelay: %d", project.WatchDelay)
+	}
+	for _, delay := range []interface{}{-1.0, 0.5, "300"} {
+		_, err := regolith.RegolithProjectFromObject(
+			map[string]interface{}{
+				"dataPath": "packs/data", "watchDelay": delay,
+			})
+		if err == nil {
+			t.Errorf("Accepted the watchDelay: %v", delay)
+		}
+	}
+
+	t.Log("Changing the files many times...")
+	watcher, err := regolith.NewDirWatcher(filepath.Join("packs", "BP"), nil)
+	if err != nil {
+		t.Fatal("Unable to watch the directory:", err.Error())
+	}
+	defer watcher.Close()
+	interruption := make(chan string, 10)
+	startWatcher(watcher, 300, interruption)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		writeTestFile(
+			t, filepath.Join("packs", "BP", "a.json"), fmt.Sprint(i))
+		time.Sleep(50 * time.Millisecond)
+	}
+	expectInterruption(t, interruption, true, 5*time.Second)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("The interruption didn't wait for the delay: %s", elapsed)
+	}
+	startWatcher(watcher, 300, interruption)
+	expectInterruption(t, interruption, false, 500*time.Millisecond)
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testDockerFilter runs a project with a Docker filter twice. The filter
+// can only read the files from its own directory. The second run checks if
+// Regolith can remove the files created by the container in the tmp
+// directory. The test is skipped if Docker isn't running.
+func testDockerFilter(t *testing.T, recycled bool) {
+	if exec.Command("docker", "version").Run() != nil {
+		t.Skip("Docker isn't available")
+	}
+	tmpDir, cleanup := prepareTestProject(t, dockerFilterPath)
+	defer cleanup()
+	// THE TEST
+	for i := 0; i < 2; i++ {
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		expectFileContent(
+			t,
+			filepath.Join(tmpDir, "build", "BP", "generated", "message.txt"),
+			"Hello from the filter directory")
+	}
+}
+
+func TestDockerFilter(t *testing.T) {
+	testDockerFilter(t, false)
+}
+
+func TestDockerFilterRecycled(t *testing.T) {
+	testDockerFilter(t, true)
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestDoctor checks the environment of the projects with "regolith doctor".
+// The command fails outside of a project and when the filters of a profile
+// aren't installed, and passes for a project that can run, even before the
+// first export creates the "build" folder.
+func TestDoctor(t *testing.T) {
+	isolateUserDirs(t)
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Checking a folder without a project...")
+	restoreDir := enterEmptyDir(t)
+	err := regolith.Doctor(true)
+	restoreDir()
+	if err == nil {
+		t.Fatal("'regolith doctor' passed outside of a project.")
+	}
+	if logs.FilterMessageSnippet(
+		"Failed to load \"config.json\"").Len() == 0 {
+		t.Error("Missing the error about the missing config.")
+	}
+	logs.TakeAll()
+
+	t.Log("Checking a project that can run...")
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	bpPath, err := filepath.Abs(filepath.Join("build", "BP"))
+	if err != nil {
+		t.Fatal("Unable to get the export path:", err)
+	}
+	if err := regolith.Doctor(true); err != nil {
+		t.Fatal("'regolith doctor' failed:", err.Error())
+	}
+	expectLogs(
+		t, logs,
+		"[OK] config.json is valid",
+		"[OK] Profile \"file\" is ready to run",
+		"[OK] Profile \"log\" is ready to run",
+		"[OK] Export path of profile \"file\": "+bpPath,
+		"No problems found.")
+	expectNotExist(t, "build")
+
+	t.Log("Checking a project with a filter that isn't installed...")
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup = prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	err = regolith.Doctor(true)
+	if err == nil {
+		t.Fatal("'regolith doctor' passed without the installed filter.")
+	}
+	if logs.FilterMessageSnippet(
+		"The filters of profile \"dev\" can't run.").Len() == 0 {
+		t.Error("Missing the error about the filter that isn't installed.")
+	}
+	logs.TakeAll()
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Doctor(true); err != nil {
+		t.Fatal("'regolith doctor' failed:", err.Error())
+	}
+	expectLogs(t, logs, "[OK] Profile \"dev\" is ready to run")
+}
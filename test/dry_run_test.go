@@ -0,0 +1,54 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestDryRun checks if the dry run prints the execution plan and the export
+// plan without running the filters, exporting the files or resolving the
+// secrets.
+func TestDryRun(t *testing.T) {
+	const token = "dry-run-token"
+	t.Setenv("REGOLITH_SECRET_DRY_RUN_TOKEN", token)
+	tmpDir, cleanup := prepareTestProject(t, dryRunPath)
+	defer cleanup()
+	logs, restoreLogger := captureLogs()
+	defer restoreLogger()
+	// THE TEST
+	if err := regolith.DryRun("dev", nil, nil, false, true); err != nil {
+		t.Fatal("'regolith run --dry-run' failed:", err.Error())
+	}
+	for _, message := range []string{
+		"Execution plan of the \"dev\" profile:",
+		"  1. write",
+		"  2. write (disabled, skipped)",
+		"Export target: local",
+		"Dry run finished. No files were changed.",
+	} {
+		if logs.FilterMessage(message).Len() == 0 {
+			t.Errorf("The dry run didn't print %q", message)
+		}
+	}
+	settings := `settings: {"profile":"dev","token":"<secret:DRY_RUN_TOKEN>"}`
+	if logs.FilterMessageSnippet(settings).Len() == 0 {
+		t.Errorf("The dry run didn't print the settings: %s", settings)
+	}
+	if logs.FilterMessageSnippet(token).Len() != 0 {
+		t.Error("The dry run printed the value of the secret")
+	}
+	expectNotExist(t, filepath.Join(tmpDir, "build"))
+	expectNotExist(t, filepath.Join(tmpDir, ".regolith", "tmp"))
+	// With the define, the second filter isn't skipped
+	logs.TakeAll()
+	err := regolith.DryRun("dev", []string{"release"}, nil, false, true)
+	if err != nil {
+		t.Fatal("'regolith run --dry-run' failed:", err.Error())
+	}
+	if logs.FilterMessage("  2. write").Len() == 0 {
+		t.Error("The dry run skipped the filter enabled by the define")
+	}
+	expectNotExist(t, filepath.Join(tmpDir, "build"))
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	api "github.com/Bedrock-OSS/regolith/pkg/regolith"
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testLogger is the api.Logger that records the messages.
+type testLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) record(level, message string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, level+": "+message)
+}
+
+func (l *testLogger) Debug(message string) { l.record("debug", message) }
+func (l *testLogger) Info(message string)  { l.record("info", message) }
+func (l *testLogger) Warn(message string)  { l.record("warn", message) }
+func (l *testLogger) Error(message string) { l.record("error", message) }
+
+// contains returns true if a message has the level and the text.
+func (l *testLogger) contains(level, message string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, m := range l.messages {
+		if m == level+": "+message {
+			return true
+		}
+	}
+	return false
+}
+
+// TestEmbedApi runs a project with the public Go API for the programs that
+// embed Regolith. The API reports the log messages and the progress of the
+// run, works in the project directory without changing the working
+// directory of the caller, and stops the run when the context is cancelled.
+func TestEmbedApi(t *testing.T) {
+	isolateUserDirs(t)
+	project, cleanup := prepareTestProject(t, filterResumePath)
+	defer cleanup()
+	workingDir := t.TempDir()
+	if err := os.Chdir(workingDir); err != nil {
+		t.Fatal("Unable to change the working directory:", err)
+	}
+	// The API replaces the logger of Regolith
+	logger, level := regolith.Logger, regolith.LoggerLevel
+	defer regolith.SetLogger(logger, level)
+	runs := func(filter string) string {
+		return filepath.Join(project, filter+"_runs.txt")
+	}
+
+	// THE TEST
+	t.Log("Loading the config...")
+	config, err := api.LoadConfig(project)
+	if err != nil {
+		t.Fatal("Unable to load the config:", err.Error())
+	}
+	if config.Name != "filter_resume_test_project" {
+		t.Errorf("Wrong name of the project: %q", config.Name)
+	}
+
+	t.Log("Running the project...")
+	var progressMutex sync.Mutex
+	var progress []string
+	testLogger := &testLogger{}
+	err = api.Run(context.Background(), api.Options{
+		ProjectDir: project,
+		Profile:    "dev",
+		Logger:     testLogger,
+		OnProgress: func(p api.Progress) {
+			progressMutex.Lock()
+			defer progressMutex.Unlock()
+			if p.Done {
+				progress = append(
+					progress, strings.Join(p.Profiles, "/")+" "+
+						string(p.Phase)+" "+p.Filter)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal("Failed to run the project:", err.Error())
+	}
+	if wd, _ := os.Getwd(); wd != workingDir {
+		t.Errorf("The working directory changed to %q.", wd)
+	}
+	expectFileContent(
+		t, filepath.Join(project, "build", "BP", "entities.txt"), "atlas")
+	expected := "dev setup ,dev filter textures,dev filter entities,dev export "
+	if actual := strings.Join(progress, ","); actual != expected {
+		t.Errorf(
+			"Wrong progress of the run.\nExpected: %q\nActual: %q",
+			expected, actual)
+	}
+	if !testLogger.contains("info", "Successfully ran the \"dev\" profile.") {
+		t.Error("Missing the log message about the successful run.")
+	}
+
+	t.Log("Cancelling the run...")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = api.Run(ctx, api.Options{
+		ProjectDir: project,
+		Profile:    "dev",
+		OnProgress: func(p api.Progress) {
+			if p.Filter == "textures" {
+				cancel()
+			}
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "The run was cancelled.") {
+		t.Fatal("Expected the error about the cancelled run, got:", err)
+	}
+	// The running filter finishes, the next one doesn't start
+	expectFileContent(t, runs("textures"), "2")
+	expectFileContent(t, runs("entities"), "1")
+	err = api.Run(ctx, api.Options{ProjectDir: project, Profile: "dev"})
+	if err == nil {
+		t.Error("The run with the cancelled context succeeded.")
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestErrorCodes checks if the errors created from the error constants have
+// the codes of the constants, and if "regolith explain" prints the causes of
+// the errors with the codes.
+func TestErrorCodes(t *testing.T) {
+	isolateUserDirs(t)
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Creating the errors with the codes...")
+	err := regolith.WrapError(
+		regolith.WrappedErrorf("Path doesn't exist.\nPath: %s", "BP"),
+		"Failed to export project.")
+	codes := strings.Join(regolith.ErrorCodes(err), " ")
+	if codes != "R0045 R0004" {
+		t.Errorf("Wrong codes of the error: %q\n%s", codes, err.Error())
+	}
+	err = regolith.WrappedError("An error without a code.")
+	if codes := regolith.ErrorCodes(err); len(codes) != 0 {
+		t.Errorf("Unexpected codes of the error: %v", codes)
+	}
+
+	t.Log("Running a project with an invalid config...")
+	restoreDir := enterEmptyDir(t)
+	defer restoreDir()
+	writeTestFile(t, "config.json", "{")
+	err = regolith.Run("dev", nil, false, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded with an invalid config.")
+	}
+	codes = strings.Join(regolith.ErrorCodes(err), " ")
+	if !strings.Contains(codes, "R0024") {
+		t.Errorf("Missing the code of the JSON error: %q\n%s",
+			codes, err.Error())
+	}
+
+	t.Log("Explaining the errors...")
+	if err := regolith.Explain("r0006", true); err != nil {
+		t.Fatal("'regolith explain' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(
+		"R0006: Failed to move file or directory:\nSource: <value>\n"+
+			"Target: <value>\n\nCauses:\n").Len() == 0 {
+		t.Error("Missing the explanation of the error.")
+	}
+	if err := regolith.Explain("", true); err != nil {
+		t.Fatal("'regolith explain' failed:", err.Error())
+	}
+	expectLogs(
+		t, logs,
+		"R0004: Path doesn't exist.",
+		"R0024: Failed to parse JSON.")
+	if err := regolith.Explain("R9999", true); err == nil {
+		t.Error("'regolith explain' accepted an unknown code.")
+	}
+}
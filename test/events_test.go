@@ -0,0 +1,146 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// readEvents reads the NDJSON events and returns their types, with the
+// profiles and the filters, like "filter-start dev protocol". It's also
+// called outside of the goroutine of the test, so it doesn't stop the test.
+func readEvents(t *testing.T, reader io.Reader) []string {
+	var result []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var event struct {
+			Type       string `json:"type"`
+			Profile    string `json:"profile"`
+			Filter     string `json:"filter"`
+			Error      string `json:"error"`
+			Diagnostic *struct {
+				Message string `json:"message"`
+			} `json:"diagnostic"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Errorf("Invalid event %q: %s", scanner.Text(), err)
+			continue
+		}
+		line := strings.Join(strings.Fields(
+			event.Type+" "+event.Profile+" "+event.Filter), " ")
+		if event.Diagnostic != nil {
+			line += ": " + event.Diagnostic.Message
+		}
+		if event.Error != "" {
+			line += ": failed"
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// testEvents runs the profiles of a project with the "--events" flag. The
+// events of the runs, their phases and the diagnostics of the filters are
+// written to a file or sent to a TCP socket, one JSON object per line.
+func testEvents(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterProtocolPath)
+	defer cleanup()
+	defer func() { regolith.EventsOutput = "" }()
+	_, restore := captureLogs()
+	defer restore()
+	expectEvents := func(actual []string, expected ...string) {
+		if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+			t.Errorf(
+				"Wrong events.\nExpected:\n%s\nActual:\n%s",
+				strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+
+	// THE TEST
+	t.Log("Writing the events of the failed run to a file...")
+	eventsPath := filepath.Join(t.TempDir(), "events.ndjson")
+	regolith.EventsOutput = eventsPath
+	if err := regolith.OpenEventStream(); err != nil {
+		t.Fatal("Unable to open the stream of the events:", err.Error())
+	}
+	err := regolith.Run("fail", nil, recycled, true)
+	regolith.CloseEventStream()
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter reported an error")
+	}
+	file, err := os.Open(eventsPath)
+	if err != nil {
+		t.Fatal("Unable to open the events:", err)
+	}
+	defer file.Close()
+	expectEvents(
+		readEvents(t, file),
+		"run-start fail",
+		"setup-start fail",
+		"setup-done fail",
+		"filter-start fail protocol",
+		"filter-diagnostic protocol: Test diagnostic",
+		"filter-done fail protocol",
+		"run-done fail: failed")
+
+	t.Log("Sending the events of the successful run to a socket...")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to listen on a TCP socket:", err)
+	}
+	defer listener.Close()
+	events := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			events <- nil
+			return
+		}
+		defer conn.Close()
+		events <- readEvents(t, conn)
+	}()
+	regolith.EventsOutput = "tcp:" + listener.Addr().String()
+	if err := regolith.OpenEventStream(); err != nil {
+		t.Fatal("Unable to open the stream of the events:", err.Error())
+	}
+	err = regolith.Run("dev", nil, recycled, true)
+	regolith.CloseEventStream()
+	if err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectEvents(
+		<-events,
+		"run-start dev",
+		"setup-start dev",
+		"setup-done dev",
+		"filter-start dev protocol",
+		"filter-diagnostic protocol: Test diagnostic",
+		"filter-done dev protocol",
+		"export-start dev",
+		"export-done dev",
+		"run-done dev")
+
+	t.Log("Opening the invalid targets...")
+	for _, target := range []string{"fd:x", "tcp:127.0.0.1:0"} {
+		regolith.EventsOutput = target
+		if err := regolith.OpenEventStream(); err == nil {
+			regolith.CloseEventStream()
+			t.Errorf("Opened the invalid target of the events: %q", target)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	testEvents(t, false)
+}
+
+func TestEventsRecycled(t *testing.T) {
+	testEvents(t, true)
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestExeFilterPlatforms checks selecting the executable of an exe filter
+// with the platform-specific "exe" property. The "<os>-<arch>" keys are
+// preferred over the "<os>" keys.
+func TestExeFilterPlatforms(t *testing.T) {
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	filter, err := regolith.ExeFilterDefinitionFromObject(
+		"test", map[string]interface{}{
+			"exe": map[string]interface{}{
+				runtime.GOOS: "./os",
+				platform:     "./platform",
+			},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	if filter.PlatformExes[platform] != "./platform" {
+		t.Fatalf("Unexpected executables: %v", filter.PlatformExes)
+	}
+	if err := filter.Check(regolith.RunContext{}); err != nil {
+		t.Fatal("The filter doesn't support this platform:", err.Error())
+	}
+	filter, err = regolith.ExeFilterDefinitionFromObject(
+		"test", map[string]interface{}{
+			"exe": map[string]interface{}{"plan9-arm": "./plan9"},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	err = filter.Check(regolith.RunContext{})
+	if err == nil {
+		t.Fatal("The filter supports only plan9-arm, but the check passed")
+	}
+	if !strings.Contains(err.Error(), "plan9-arm") {
+		t.Fatal("The error doesn't list the supported platforms:", err)
+	}
+	for _, exe := range []interface{}{
+		1, map[string]interface{}{"linux": 1},
+	} {
+		_, err = regolith.ExeFilterDefinitionFromObject(
+			"test", map[string]interface{}{"exe": exe})
+		if err == nil {
+			t.Errorf("The invalid \"exe\" property was accepted: %v", exe)
+		}
+	}
+}
+
+// testExeFilterPlatformsRun runs the "platforms" profile of the exe filter
+// project, which selects the executable for the current platform. The test
+// executables are built only for Windows and Linux on amd64.
+func testExeFilterPlatformsRun(t *testing.T, recycled bool) {
+	if runtime.GOARCH != "amd64" ||
+		(runtime.GOOS != "windows" && runtime.GOOS != "linux") {
+		t.Skip("The test executables don't support this platform")
+	}
+	tmpDir, cleanup := prepareTestProject(t, exeFilterPath)
+	defer cleanup()
+	// THE TEST
+	if err := regolith.Run("platforms", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "hello.txt"), "Hello World")
+}
+
+func TestExeFilterPlatformsRun(t *testing.T) {
+	testExeFilterPlatformsRun(t, false)
+}
+
+func TestExeFilterPlatformsRunRecycled(t *testing.T) {
+	testExeFilterPlatformsRun(t, true)
+}
@@ -0,0 +1,78 @@
+//go:build !windows
+// +build !windows
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestExportAcl exports a file whose permissions were changed by a filter,
+// with every mode of the "acl" property of the export target. The "inherit"
+// mode resets the permissions to the defaults of the new files, and the
+// "preserve" and "none" modes keep the permissions from the tmp directory.
+// The "--recycled" mode copies the files to the export target, so the
+// permissions from the tmp directory can't be preserved on this system, and
+// it isn't tested.
+func TestExportAcl(t *testing.T) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	writeTestFile(
+		t, filepath.Join("filters", "chmod.py"),
+		"import os\n"+
+			"os.chmod(os.path.join(\"BP\", \"a.json\"), 0o600)\n")
+	replaceInTestFile(
+		t, "config.json", "\"filters\": []",
+		"\"filters\": [{\"filter\": \"chmod\"}]")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {}",
+		"\"filterDefinitions\": {\"chmod\": {\"runWith\": \"python\", "+
+			"\"script\": \"./filters/chmod.py\"}}")
+	// The umask can only be read by changing it
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	acl := "\"report\": true"
+	setAcl := func(mode string) {
+		replacement := "\"report\": true, \"acl\": \"" + mode + "\""
+		replaceInTestFile(t, "config.json", acl, replacement)
+		acl = replacement
+	}
+	expectMode := func(expected os.FileMode) {
+		info, err := os.Stat(filepath.Join("build", "BP", "a.json"))
+		if err != nil {
+			t.Fatal("The file wasn't exported:", err)
+		}
+		if info.Mode().Perm() != expected {
+			t.Errorf(
+				"Wrong permissions of the exported file.\nExpected: %s\n"+
+					"Actual: %s", expected, info.Mode().Perm())
+		}
+	}
+
+	// THE TEST
+	t.Log("Inheriting the permissions...")
+	if err := regolith.Run("log", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectMode(0666 &^ os.FileMode(umask))
+
+	for _, mode := range []string{"preserve", "none"} {
+		t.Logf("Exporting the files with the %q mode...", mode)
+		setAcl(mode)
+		if err := regolith.Run("log", nil, false, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		expectMode(0600)
+	}
+
+	t.Log("Using an invalid mode...")
+	setAcl("copy")
+	if err := regolith.Run("log", nil, false, true); err == nil {
+		t.Error("'regolith run' accepted an invalid \"acl\" mode.")
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestExportExcludeInvalidPattern checks if the export targets with invalid
+// glob patterns in the "exclude" property are rejected.
+func TestExportExcludeInvalidPattern(t *testing.T) {
+	for _, exclude := range [][]interface{}{{"[a"}, {""}, {1}} {
+		_, err := regolith.ExportTargetFromObject(map[string]interface{}{
+			"target":  "local",
+			"exclude": exclude,
+		})
+		if err == nil {
+			t.Errorf("The \"exclude\" property %v was accepted.", exclude)
+		}
+	}
+}
+
+// testExportExclude runs a profile with the "exclude" property and checks if
+// the matching files and directories are not exported, while the other
+// files are. The source files of the packs must not be affected.
+func testExportExclude(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportExcludePath)
+	defer cleanup()
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	bp := filepath.Join("build", "BP")
+	rp := filepath.Join("build", "RP")
+	expectFileContent(t, filepath.Join(bp, "data.json"), "{}\n")
+	expectFileContent(t, filepath.Join(bp, "textures", "block.png"), "png\n")
+	expectFileContent(t, filepath.Join(rp, "data.json"), "{}\n")
+	expectFileContent(t, filepath.Join(rp, "textures", "docs.png"), "png\n")
+	// Patterns without a slash match the files at any depth
+	expectNotExist(t, filepath.Join(bp, "art.psd"))
+	expectNotExist(t, filepath.Join(bp, "textures", "block.psd"))
+	// Patterns with a slash match the paths relative to the tmp directory
+	expectNotExist(t, filepath.Join(bp, "docs"))
+	expectNotExist(t, filepath.Join(rp, "source"))
+	if _, err := os.Stat(
+		filepath.Join("packs", "BP", "docs", "nested", "notes.md"),
+	); err != nil {
+		t.Error("The excluded source file was removed:", err)
+	}
+}
+
+func TestExportExclude(t *testing.T) {
+	testExportExclude(t, false)
+}
+
+func TestExportExcludeRecycled(t *testing.T) {
+	testExportExclude(t, true)
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testExportFileNames exports the packs with the names of the files that
+// don't work on Windows and Android, which can only be created on the
+// case-sensitive file systems of Linux. The "fileNameCheck" property of the
+// export target decides if the problems are printed, stop the export or
+// aren't checked.
+func testExportFileNames(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	for _, path := range []string{
+		filepath.Join("packs", "BP", "textures", "Stone.png"),
+		filepath.Join("packs", "BP", "textures", "stone.png"),
+		filepath.Join("packs", "RP", "aux.json"),
+		filepath.Join("packs", "RP", "a:b.json"),
+	} {
+		writeTestFile(t, path, "{}")
+	}
+	logs, restore := captureLogs()
+	defer restore()
+	warning := "The names of these files don't work on every platform:\n" +
+		"BP/textures/stone.png: differs only in the case of the letters " +
+		"from BP/textures/Stone.png\n" +
+		"RP/a:b.json: contains the invalid character :\n" +
+		"RP/aux.json: uses the reserved name aux"
+	setCheck := func(previous, mode string) {
+		replaceInTestFile(
+			t, "config.json", previous,
+			"\"report\": true, \"fileNameCheck\": \""+mode+"\"")
+	}
+
+	// THE TEST
+	t.Log("Exporting the files with the default check...")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectLogs(t, logs, warning)
+	expectFileContent(t, filepath.Join("build", "RP", "aux.json"), "{}")
+
+	t.Log("Exporting the files with the \"error\" check...")
+	setCheck("\"report\": true", "error")
+	writeTestFile(t, filepath.Join("packs", "BP", "new.json"), "{}")
+	err := regolith.Run("log", nil, recycled, true)
+	if err == nil || !strings.Contains(err.Error(), "RP/aux.json") {
+		t.Fatal("Expected the error about the names of the files, got:", err)
+	}
+	expectNotExist(t, filepath.Join("build", "BP", "new.json"))
+
+	t.Log("Exporting the files without the check...")
+	setCheck("\"report\": true, \"fileNameCheck\": \"error\"", "off")
+	logs.TakeAll()
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	if logs.FilterMessage(warning).Len() != 0 {
+		t.Error("The names of the files were checked with the \"off\" mode.")
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "new.json"), "{}")
+
+	t.Log("Exporting the files with an invalid check...")
+	setCheck("\"report\": true, \"fileNameCheck\": \"off\"", "sometimes")
+	if err := regolith.Run("log", nil, recycled, true); err == nil {
+		t.Error("'regolith run' accepted an invalid \"fileNameCheck\".")
+	}
+}
+
+func TestExportFileNames(t *testing.T) {
+	testExportFileNames(t, false)
+}
+
+func TestExportFileNamesRecycled(t *testing.T) {
+	testExportFileNames(t, true)
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testExportHooks runs the profiles with the "preExport" and "postExport"
+// commands. It checks the environment variables of the commands, and that a
+// failing "preExport" command stops the export before changing the exported
+// files. The commands use the syntax of the POSIX shells, so the test is
+// skipped if Regolith would run them in PowerShell or cmd.
+func testExportHooks(t *testing.T, recycled bool) {
+	for _, shell := range []string{"powershell", "cmd"} {
+		if _, err := exec.LookPath(shell); err == nil {
+			t.Skipf("The commands of the test don't work in %s", shell)
+		}
+	}
+	_, cleanup := prepareTestProject(t, exportHooksPath)
+	defer cleanup()
+	bpPath, err := filepath.Abs(filepath.Join("build", "BP"))
+	if err != nil {
+		t.Fatal("Unable to get the absolute path to the export:", err)
+	}
+	rpPath, err := filepath.Abs(filepath.Join("build", "RP"))
+	if err != nil {
+		t.Fatal("Unable to get the absolute path to the export:", err)
+	}
+	exported := filepath.Join("build", "BP", "data.json")
+
+	// THE TEST
+	t.Log("Running the export commands...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, "pre.txt",
+		"export_hooks_test_project local\n"+bpPath+"\n"+rpPath+"\n")
+	// The postExport commands run after the export
+	expectFileContent(t, "post.txt", "{\"value\": 1}\n")
+
+	t.Log("Running the failing preExport command...")
+	writeTestFile(
+		t, filepath.Join("packs", "BP", "data.json"), "{\"value\": 2}\n")
+	err = regolith.Run("pre_fail", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the preExport command failed")
+	}
+	if !strings.Contains(err.Error(), "preExport") {
+		t.Errorf("Unexpected error of the failing preExport command: %s", err)
+	}
+	expectNotExist(t, "skipped.txt")
+	expectFileContent(t, exported, "{\"value\": 1}\n")
+
+	t.Log("Running the failing postExport command...")
+	err = regolith.Run("post_fail", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the postExport command failed")
+	}
+	if !strings.Contains(err.Error(), "postExport") {
+		t.Errorf(
+			"Unexpected error of the failing postExport command: %s", err)
+	}
+	expectFileContent(t, exported, "{\"value\": 2}\n")
+}
+
+func TestExportHooks(t *testing.T) {
+	testExportHooks(t, false)
+}
+
+func TestExportHooksRecycled(t *testing.T) {
+	testExportHooks(t, true)
+}
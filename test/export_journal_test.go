@@ -0,0 +1,132 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testExportJournalRecovery creates the files and the journal of an
+// interrupted export and checks if the next run restores the files from
+// before the export. The restored files are checked after a failing filter,
+// which stops the run before the next export.
+func testExportJournalRecovery(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, exportJournalPath)
+	defer cleanup()
+	// THE TEST
+	dotRegolith := filepath.Join(tmpDir, ".regolith")
+	backup := filepath.Join(dotRegolith, ".dataBackup")
+	bp := filepath.Join(tmpDir, "build", "BP")
+	journal := filepath.Join(dotRegolith, "cache", "export-journal")
+	writeTestFile(t, filepath.Join(backup, "0"), "old")
+	// A backup whose journal entry was lost
+	writeTestFile(t, filepath.Join(backup, "2"), "lost")
+	writeTestFile(t, filepath.Join(bp, "new.txt"), "new")
+	writeTestFile(t, filepath.Join(bp, "moved.txt"), "moved")
+	writeTestFile(t, filepath.Join(bp, "untouched.txt"), "untouched")
+	entries := []map[string]string{
+		{"op": "start", "path": backup},
+		{
+			"op":     "delete",
+			"path":   filepath.Join(bp, "old.txt"),
+			"backup": filepath.Join(backup, "0"),
+		},
+		{"op": "rmdir", "path": filepath.Join(bp, "removed_dir")},
+		{"op": "create", "path": filepath.Join(bp, "new.txt")},
+		{
+			"op":     "move",
+			"source": filepath.Join(tmpDir, "source", "moved.txt"),
+			"path":   filepath.Join(bp, "moved.txt"),
+		},
+		// The export was interrupted before moving the file to the backup
+		{
+			"op":     "delete",
+			"path":   filepath.Join(bp, "untouched.txt"),
+			"backup": filepath.Join(backup, "1"),
+		},
+	}
+	lines := []string{}
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal("Unable to encode the journal entry:", err)
+		}
+		lines = append(lines, string(line))
+	}
+	// The last line was interrupted while writing it
+	lines = append(lines, `{"op":"create","pa`)
+	writeTestFile(t, journal, strings.Join(lines, "\n"))
+
+	if err := regolith.Run("fail", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter should fail")
+	}
+	expectFileContent(t, filepath.Join(bp, "old.txt"), "old")
+	if info, err := os.Stat(filepath.Join(bp, "removed_dir")); err != nil ||
+		!info.IsDir() {
+		t.Error("The removed directory wasn't restored")
+	}
+	expectNotExist(t, filepath.Join(bp, "new.txt"))
+	expectNotExist(t, filepath.Join(bp, "moved.txt"))
+	expectFileContent(t, filepath.Join(tmpDir, "source", "moved.txt"), "moved")
+	expectFileContent(t, filepath.Join(bp, "untouched.txt"), "untouched")
+	expectNotExist(t, journal)
+	expectNotExist(t, backup)
+	recovered, err := filepath.Glob(filepath.Join(dotRegolith, "recovered-*"))
+	if err != nil || len(recovered) != 1 {
+		t.Fatalf("Expected one recovered directory, found %v", recovered)
+	}
+	expectFileContent(t, filepath.Join(recovered[0], "2"), "lost")
+}
+
+func TestExportJournalRecovery(t *testing.T) {
+	testExportJournalRecovery(t, false)
+}
+
+func TestExportJournalRecoveryRecycled(t *testing.T) {
+	testExportJournalRecovery(t, true)
+}
+
+// testExportJournalRemoved checks if the journal of the export is removed
+// after the successful exports and after the exports that failed with an
+// error handled by Regolith.
+func testExportJournalRemoved(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, exportJournalPath)
+	defer cleanup()
+	// THE TEST
+	journal := filepath.Join(tmpDir, ".regolith", "cache", "export-journal")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectNotExist(t, journal)
+	// The packs changed, so the export isn't skipped, but the file that
+	// wasn't exported by Regolith makes the export fail
+	writeTestFile(t, filepath.Join(tmpDir, "packs", "BP", "changed.txt"), "")
+	unexpected := filepath.Join(tmpDir, "build", "BP", "unexpected.txt")
+	writeTestFile(t, unexpected, "")
+	if err := regolith.Run("dev", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but the export should fail")
+	}
+	expectNotExist(t, journal)
+	// The next export works normally
+	if err := os.Remove(unexpected); err != nil {
+		t.Fatal("Unable to remove the unexpected file:", err)
+	}
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectNotExist(t, journal)
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "changed.txt"), "")
+}
+
+func TestExportJournalRemoved(t *testing.T) {
+	testExportJournalRemoved(t, false)
+}
+
+func TestExportJournalRemovedRecycled(t *testing.T) {
+	testExportJournalRemoved(t, true)
+}
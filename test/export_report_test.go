@@ -0,0 +1,66 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testExportReport runs the profiles with the export reports and checks the
+// files added, changed and removed since the previous export. The "file"
+// profile writes the report to a file, and the "log" profile logs it.
+func testExportReport(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	reportPath := filepath.Join("reports", "export.txt")
+	bp := filepath.Join("packs", "BP")
+
+	// THE TEST
+	t.Log("Reporting the first export...")
+	if err := regolith.Run("file", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, reportPath, "+ BP/a.json\n+ BP/b.json\n+ RP/c.json\n")
+
+	t.Log("Reporting the changes of the files...")
+	writeTestFile(t, filepath.Join(bp, "a.json"), "{\"value\": 2}\n")
+	writeTestFile(t, filepath.Join(bp, "d.json"), "{}\n")
+	if err := os.Remove(filepath.Join(bp, "b.json")); err != nil {
+		t.Fatal("Unable to remove b.json:", err)
+	}
+	if err := regolith.Run("file", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, reportPath, "+ BP/d.json\n~ BP/a.json\n- BP/b.json\n")
+
+	t.Log("Logging the report...")
+	logs, restore := captureLogs()
+	defer restore()
+	writeTestFile(t, filepath.Join("packs", "RP", "c.json"), "{\"c\": 1}\n")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	for _, message := range []string{
+		"Export report: 0 added, 1 changed and 0 removed files.",
+		"  ~ RP/c.json",
+	} {
+		if logs.FilterMessage(message).Len() == 0 {
+			t.Errorf("Missing log message: %q", message)
+		}
+	}
+	// The report file is written only by the "file" profile
+	expectFileContent(
+		t, reportPath, "+ BP/d.json\n~ BP/a.json\n- BP/b.json\n")
+}
+
+func TestExportReport(t *testing.T) {
+	testExportReport(t, false)
+}
+
+func TestExportReportRecycled(t *testing.T) {
+	testExportReport(t, true)
+}
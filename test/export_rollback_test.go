@@ -0,0 +1,54 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestExportRollback runs a profile whose export fails after exporting the
+// packs and checks if the export paths and the data path are restored to
+// their state from before the export. The behavior pack of the profile is
+// exported into the data path, so moving the data back to the data path
+// fails. The recycled export can't be reverted (it's repeated by the next
+// run instead), so the test uses only the normal export.
+func TestExportRollback(t *testing.T) {
+	tmpDir, cleanup := prepareTestProject(t, exportRollbackPath)
+	defer cleanup()
+	rpPath := filepath.Join("export", "RP")
+	dataPath := filepath.Join("packs", "data")
+
+	// THE TEST
+	t.Log("Exporting the packs...")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' of \"dev\" failed:", err)
+	}
+	expectFileContent(
+		t, filepath.Join(rpPath, "data.json"), "{\"value\": 1}\n")
+
+	t.Log("Running the export that fails...")
+	writeTestFile(
+		t, filepath.Join("packs", "RP", "data.json"), "{\"value\": 2}\n")
+	if err := regolith.Run("overlap", nil, false, true); err == nil {
+		t.Fatal("'regolith run' of \"overlap\" succeeded, but the export " +
+			"should fail")
+	}
+	// The previous version of the resource pack is restored
+	expectFileContent(
+		t, filepath.Join(rpPath, "data.json"), "{\"value\": 1}\n")
+	// The behavior pack exported to the data path is removed, and the data
+	// removed before the export is restored
+	expectNotExist(t, filepath.Join(dataPath, "BP"))
+	expectFileContent(t, filepath.Join(dataPath, "data.json"), "{}\n")
+	dotRegolith := filepath.Join(tmpDir, ".regolith")
+	expectNotExist(t, filepath.Join(dotRegolith, ".dataBackup"))
+	expectNotExist(t, filepath.Join(dotRegolith, "cache", "export-journal"))
+
+	t.Log("Exporting the packs after the failed export...")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' of \"dev\" failed:", err)
+	}
+	expectFileContent(
+		t, filepath.Join(rpPath, "data.json"), "{\"value\": 2}\n")
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testExportSkip runs a project multiple times. The export is skipped when
+// the packs didn't change since the previous export and the export paths
+// exist, but the filter data is still moved back to the data folder.
+func testExportSkip(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	logs, restore := captureLogs()
+	defer restore()
+	skipped := "The packs didn't change since the previous export, " +
+		"skipping the export."
+	run := func(expectSkipped bool) {
+		logs.TakeAll()
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		if (logs.FilterMessage(skipped).Len() != 0) != expectSkipped {
+			t.Fatalf("Wrong export, expected skipping: %t", expectSkipped)
+		}
+	}
+
+	// THE TEST
+	t.Log("Running the project twice...")
+	run(false)
+	run(true)
+	expectFileContent(t, filepath.Join("build", "BP", "stamp.txt"), "first")
+
+	t.Log("Changing only the data...")
+	dataFile := filepath.Join("packs", "data", "data.json")
+	writeTestFile(t, dataFile, "{}")
+	run(true)
+	expectFileContent(t, dataFile, "{}")
+
+	t.Log("Removing the exported packs...")
+	if err := os.RemoveAll(filepath.Join("build", "BP")); err != nil {
+		t.Fatal("Unable to remove the exported pack:", err)
+	}
+	run(false)
+	expectFileContent(t, filepath.Join("build", "BP", "stamp.txt"), "first")
+
+	t.Log("Changing the packs...")
+	writeTestFile(t, filepath.Join("packs", "RP", "new.json"), "{}")
+	run(false)
+	expectFileContent(t, filepath.Join("build", "RP", "new.json"), "{}")
+	run(true)
+}
+
+func TestExportSkip(t *testing.T) {
+	testExportSkip(t, false)
+}
+
+func TestExportSkipRecycled(t *testing.T) {
+	testExportSkip(t, true)
+}
@@ -0,0 +1,34 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestUnicodeNormalizationHardlink runs a project that links the source
+// files to the tmp directory and normalizes the Unicode of the exported
+// files. It checks if the JSON file with the reference to a file with a
+// different Unicode normalization form is normalized in the export, but not
+// in the source pack.
+func TestUnicodeNormalizationHardlink(t *testing.T) {
+	tmpDir, cleanup := prepareTestProject(t, unicodeNormalizationPath)
+	defer cleanup()
+	// THE TEST
+	// The reference uses the NFD form (the combining accent), the file uses
+	// the NFC form
+	nfd := `{"texture": "textures/cafe` + "\u0301" + `"}`
+	nfc := `{"texture": "textures/caf` + "\u00e9" + `"}`
+	source := filepath.Join(tmpDir, "packs", "BP", "data.json")
+	writeTestFile(t, source, nfd)
+	writeTestFile(
+		t, filepath.Join(tmpDir, "packs", "BP", "textures", "caf\u00e9.png"),
+		"")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "data.json"), nfc)
+	expectFileContent(t, source, nfd)
+}
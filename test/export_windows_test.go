@@ -77,7 +77,7 @@ func testMoveFilesAcl(t *testing.T, recycled bool) {
 		mojangDir, "development_resource_packs", config.Name+"_rp")
 	os.Chdir(workingDir)
 	// THE TEST
-	err = regolith.Run("dev", recycled, true)
+	err = regolith.Run("dev", nil, recycled, true)
 	if err != nil {
 		t.Fatal("'regolith init' failed:", err)
 	}
@@ -50,16 +50,16 @@ func testSwitchingExportTargets(t *testing.T, recycled bool) {
 	os.Chdir(workingDir)
 	// THE TEST
 	// Run Regolith with targets: A, B, A
-	err = regolith.Run("exact_export_A", recycled, true)
+	err = regolith.Run("exact_export_A", nil, recycled, true)
 	if err != nil {
 		t.Fatal(
 			"Unable RunProfile failed on first attempt to export to A:", err)
 	}
-	err = regolith.Run("exact_export_B", recycled, true)
+	err = regolith.Run("exact_export_B", nil, recycled, true)
 	if err != nil {
 		t.Fatal("Unable RunProfile failed on attempt to export to B:", err)
 	}
-	err = regolith.Run("exact_export_A", recycled, true)
+	err = regolith.Run("exact_export_A", nil, recycled, true)
 	if err != nil {
 		t.Fatal(
 			"Unable RunProfile failed on second attempt to export to A:", err)
@@ -114,7 +114,7 @@ func testTriggerFileProtection(t *testing.T, recycled bool) {
 	os.Chdir(workingDir)
 	// THE TEST
 	// Run Regolith (export to A)
-	err = regolith.Run("exact_export_A", recycled, true)
+	err = regolith.Run("exact_export_A", nil, recycled, true)
 	if err != nil {
 		t.Fatal(
 			"Unable RunProfile failed on first attempt to export to A:", err)
@@ -126,7 +126,7 @@ func testTriggerFileProtection(t *testing.T, recycled bool) {
 	}
 	file.Close()
 	// 3. Run Regolith (export to A)
-	err = regolith.Run("exact_export_A", recycled, true)
+	err = regolith.Run("exact_export_A", nil, recycled, true)
 	if err == nil {
 		t.Fatal("Expected RunProfile to fail on second attempt to export to A")
 	}
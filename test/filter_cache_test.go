@@ -0,0 +1,142 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/otiai10/copy"
+)
+
+// testFilterCacheRun runs a project with a cached local filter multiple
+// times and checks if the filter runs only when the code of the filter
+// changes.
+func testFilterCacheRun(t *testing.T, recycled bool) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	defer os.Chdir(wd)
+	// Create a temporary directory
+	tmpDir, err := ioutil.TempDir("", "regolith-test")
+	if err != nil {
+		t.Fatal("Unable to create temporary directory:", err)
+	}
+	t.Log("Created temporary directory:", tmpDir)
+	// Before deleting "workingDir" the test must stop using it
+	defer os.RemoveAll(tmpDir)
+	defer os.Chdir(wd)
+	// Copy the test project to the working directory
+	project := filepath.Join(filterCachePath, "project")
+	err = copy.Copy(
+		project,
+		tmpDir,
+		copy.Options{PreserveTimes: false, Sync: false},
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to copy test files from %q into the working directory %q",
+			project, tmpDir,
+		)
+	}
+	// THE TEST
+	os.Chdir(tmpDir)
+	if err := regolith.Unlock(true); err != nil {
+		t.Fatal("'regolith unlock' failed:", err.Error())
+	}
+	// run runs the project and checks the number of the runs of the filter
+	// and the exported file
+	run := func(expectedRuns, expectedStamp string) {
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		runs, err := os.ReadFile("runs.txt")
+		if err != nil {
+			t.Fatal("Unable to read the number of the runs:", err)
+		}
+		if string(runs) != expectedRuns {
+			t.Errorf(
+				"The filter ran %s times, expected %s", runs, expectedRuns)
+		}
+		stamp, err := os.ReadFile(filepath.Join("build", "BP", "stamp.txt"))
+		if err != nil {
+			t.Fatal("Unable to read the exported file:", err)
+		}
+		if string(stamp) != expectedStamp {
+			t.Errorf(
+				"Unexpected content of the exported file: %q, expected %q",
+				stamp, expectedStamp)
+		}
+	}
+	run("1", "first")
+	// Nothing changed, the output is restored from the cache
+	run("1", "first")
+	// The code of the filter changed
+	err = os.WriteFile(
+		filepath.Join("filters", "stamp", "message.txt"), []byte("second"),
+		0644)
+	if err != nil {
+		t.Fatal("Unable to modify the filter:", err)
+	}
+	run("2", "second")
+	run("2", "second")
+}
+
+func TestFilterCacheRun(t *testing.T) {
+	testFilterCacheRun(t, false)
+}
+
+func TestFilterCacheRunRecycled(t *testing.T) {
+	testFilterCacheRun(t, true)
+}
+
+// testFilterCacheOutputs switches the code of a cached local filter back to
+// the previous version. The output of the previous version is restored from
+// the build cache, without running the filter, also after the garbage
+// collection of the caches.
+func testFilterCacheOutputs(t *testing.T, recycled bool) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	message := filepath.Join("filters", "stamp", "message.txt")
+	stamp := filepath.Join("build", "BP", "stamp.txt")
+	run := func(expectedRuns, expectedStamp string) {
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		expectFileContent(t, "runs.txt", expectedRuns)
+		expectFileContent(t, stamp, expectedStamp)
+	}
+
+	// THE TEST
+	t.Log("Running the filter with two versions of the code...")
+	run("1", "first")
+	writeTestFile(t, message, "second")
+	run("2", "second")
+
+	t.Log("Restoring the output of the first version...")
+	writeTestFile(t, message, "first")
+	run("2", "first")
+	objects, err := os.ReadDir(
+		filepath.Join(".regolith", "cache", "build", "objects"))
+	if err != nil || len(objects) == 0 {
+		t.Fatal("The build cache doesn't have any objects:", err)
+	}
+
+	t.Log("Restoring the outputs after the garbage collection...")
+	if err := regolith.CacheGc(30, true); err != nil {
+		t.Fatal("'regolith cache gc' failed:", err.Error())
+	}
+	writeTestFile(t, message, "second")
+	run("2", "second")
+}
+
+func TestFilterCacheOutputs(t *testing.T) {
+	testFilterCacheOutputs(t, false)
+}
+
+func TestFilterCacheOutputsRecycled(t *testing.T) {
+	testFilterCacheOutputs(t, true)
+}
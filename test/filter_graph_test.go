@@ -0,0 +1,109 @@
+package test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// captureStdout returns the output printed to the standard output by the
+// function.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal("Unable to create a pipe:", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	w.Close()
+	return <-output
+}
+
+// testFilterFilesRun runs a profile whose filters declare their "inputs" and
+// "outputs". The filter that combines the outputs of the other filters must
+// run after them, and the filter whose inputs don't match any files is
+// skipped.
+func testFilterFilesRun(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+
+	// THE TEST
+	if err := regolith.Run("files", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "combined.txt"), "ab")
+	expectNotExist(t, filepath.Join("build", "BP", "skipped.txt"))
+}
+
+func TestFilterFilesRun(t *testing.T) {
+	testFilterFilesRun(t, false)
+}
+
+func TestFilterFilesRunRecycled(t *testing.T) {
+	testFilterFilesRun(t, true)
+}
+
+// TestGraph prints the graphs of the profiles that use the "needs" and the
+// "inputs" and "outputs" properties in the DOT and Mermaid formats.
+func TestGraph(t *testing.T) {
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+	cases := []struct {
+		profile, format, expected string
+	}{
+		{
+			profile: "files",
+			format:  "dot",
+			expected: "digraph \"files\" {\n" +
+				"\tf1 [label=\"generate_a\"];\n" +
+				"\tf2 [label=\"generate_b\"];\n" +
+				"\tf3 [label=\"combine\"];\n" +
+				"\tf4 [label=\"skipped\"];\n" +
+				"\tf1 -> f3;\n" +
+				"\tf2 -> f3;\n" +
+				"}\n",
+		},
+		{
+			profile: "dev",
+			format:  "mermaid",
+			expected: "flowchart TD\n" +
+				"\tf1[\"combine\"]\n" +
+				"\tf2[\"generate_a\"]\n" +
+				"\tf3[\"generate_b\"]\n" +
+				"\tf2 --> f1\n" +
+				"\tf3 --> f1\n",
+		},
+	}
+
+	// THE TEST
+	for _, c := range cases {
+		var err error
+		output := captureStdout(t, func() {
+			err = regolith.Graph(c.profile, c.format, true)
+		})
+		if err != nil {
+			t.Fatalf("'regolith graph %s' failed: %s", c.profile, err.Error())
+		}
+		if output != c.expected {
+			t.Errorf(
+				"Wrong graph of the %q profile.\nExpected:\n%s\nActual:\n%s",
+				c.profile, c.expected, output)
+		}
+	}
+	if err := regolith.Graph("files", "svg", true); err == nil {
+		t.Error("'regolith graph' accepted an unknown format")
+	}
+	if err := regolith.Graph("circular_needs", "dot", true); err == nil {
+		t.Error("'regolith graph' accepted the circular needs")
+	}
+}
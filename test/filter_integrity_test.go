@@ -0,0 +1,88 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// writeLockFile saves the lock file of the project in the working
+// directory.
+func writeLockFile(t *testing.T, lockFile *regolith.LockFile) {
+	data, err := json.MarshalIndent(lockFile, "", "\t")
+	if err != nil {
+		t.Fatal("Unable to encode the lock file:", err)
+	}
+	writeTestFile(t, regolith.LockFilePath, string(data)+"\n")
+}
+
+// expectIntegrityError checks if the installation failed because of the
+// integrity hash and if the filter was uninstalled.
+func expectIntegrityError(t *testing.T, err error) {
+	if err == nil {
+		t.Fatal("The installation succeeded, but the files of the filter " +
+			"don't match the lock file.")
+	}
+	if !strings.Contains(err.Error(), "don't match the integrity hash") {
+		t.Fatal("Unexpected error:", err.Error())
+	}
+	expectNotExist(
+		t, filepath.Join(".regolith", "cache", "filters", "hello"))
+}
+
+// TestFilterIntegrity checks if the files of the downloaded and the
+// vendored remote filters are verified with the integrity hash from the lock
+// file. The filters locked without the hash get the hash of their files.
+func TestFilterIntegrity(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+
+	// THE TEST
+	t.Log("Installing the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	integrity := readLockFile(t).Filters["hello"].Integrity
+
+	t.Log("Installing the filter locked with another hash...")
+	lockFile := readLockFile(t)
+	locked := lockFile.Filters["hello"]
+	locked.Integrity = "sha256-" + strings.Repeat("0", 64)
+	lockFile.Filters["hello"] = locked
+	writeLockFile(t, lockFile)
+	if err := os.RemoveAll(".regolith"); err != nil {
+		t.Fatal("Unable to remove the .regolith directory:", err)
+	}
+	expectIntegrityError(t, regolith.InstallAll(false, false, true))
+
+	t.Log("Installing the filter locked without the hash...")
+	locked.Integrity = ""
+	lockFile.Filters["hello"] = locked
+	writeLockFile(t, lockFile)
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if actual := readLockFile(t).Filters["hello"].Integrity; actual !=
+		integrity {
+		t.Fatalf(
+			"Wrong integrity hash in the lock file.\n"+
+				"Expected: %s\nActual: %s", integrity, actual)
+	}
+
+	t.Log("Installing the modified vendored filter...")
+	if err := regolith.Vendor(true); err != nil {
+		t.Fatal("'regolith vendor' failed:", err.Error())
+	}
+	writeTestFile(
+		t, filepath.Join(regolith.VendorPath, "hello", "hello.lua"),
+		"-- Modified\n")
+	expectIntegrityError(t, regolith.InstallAll(false, true, true))
+}
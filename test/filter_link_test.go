@@ -0,0 +1,98 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestFilterLink links an installed remote filter to its local working copy
+// with "regolith link". The linked filter runs the files from the working
+// copy, and it's never downloaded or vendored, until it's unlinked with
+// "regolith unlink".
+func TestFilterLink(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	workingCopies := t.TempDir()
+	for path, content := range helloFilterFiles("local") {
+		writeTestFile(
+			t, filepath.Join(workingCopies, filepath.FromSlash(path)), content)
+	}
+	workingCopy := filepath.Join(workingCopies, "hello")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	hello := filepath.Join("build", "BP", "hello.txt")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+
+	// THE TEST
+	t.Log("Linking the directories that aren't remote filters...")
+	if err := regolith.Link(workingCopies, "hello", true); err == nil {
+		t.Fatal("'regolith link' accepted a directory without filter.json")
+	}
+	if err := regolith.Link(workingCopy, "missing", true); err == nil {
+		t.Fatal("'regolith link' accepted a filter that isn't defined")
+	}
+
+	t.Log("Linking the filter...")
+	if err := regolith.Link(workingCopy, "", true); err != nil {
+		t.Fatal("'regolith link' failed:", err.Error())
+	}
+	links, err := regolith.LoadFilterLinks(".regolith")
+	if err != nil {
+		t.Fatal("Unable to load the linked filters:", err.Error())
+	}
+	if target, err := filepath.Abs(workingCopy); err != nil ||
+		links["hello"] != target {
+		t.Fatalf("Unexpected linked filters: %v", links)
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, hello, "local")
+
+	t.Log("Running the edited working copy...")
+	for path, content := range helloFilterFiles("edited") {
+		writeTestFile(
+			t, filepath.Join(workingCopies, filepath.FromSlash(path)), content)
+	}
+	if err := regolith.InstallAll(true, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, hello, "edited")
+	expectFileContent(
+		t, filepath.Join(workingCopy, "hello.lua"),
+		helloFilterFiles("edited")["hello/hello.lua"])
+	err = regolith.Vendor(true)
+	if err == nil || !strings.Contains(err.Error(), "regolith unlink") {
+		t.Fatal("Expected an error about vendoring the linked filter, got:",
+			err)
+	}
+
+	t.Log("Unlinking the filter...")
+	if err := regolith.Unlink("hello", true); err != nil {
+		t.Fatal("'regolith unlink' failed:", err.Error())
+	}
+	if err := regolith.Unlink("hello", true); err == nil {
+		t.Fatal("'regolith unlink' succeeded for the filter that isn't " +
+			"linked")
+	}
+	expectFileContent(
+		t, filepath.Join(workingCopy, "hello.lua"),
+		helloFilterFiles("edited")["hello/hello.lua"])
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, hello, "1")
+}
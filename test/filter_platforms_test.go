@@ -0,0 +1,97 @@
+package test
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestFilterPlatforms checks if the properties from the "platforms" property
+// of the filter definitions and the filters of the profiles replace the
+// other properties on the current platform.
+func TestFilterPlatforms(t *testing.T) {
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	other := "plan9-mips"
+	if runtime.GOOS == "plan9" {
+		other = "aix-ppc64"
+	}
+
+	// THE TEST
+	t.Log("Parsing the definition with the OS and architecture keys...")
+	definition, err := regolith.FilterInstallerFromObject(
+		"lua", map[string]interface{}{
+			"runWith": "lua",
+			"script":  "./filters/default.lua",
+			"platforms": map[string]interface{}{
+				runtime.GOOS: map[string]interface{}{
+					"script": "./filters/os.lua"},
+				platform: map[string]interface{}{
+					"script": "./filters/platform.lua"},
+				other: map[string]interface{}{
+					"script": "./filters/other.lua"},
+			},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	lua, ok := definition.(*regolith.LuaFilterDefinition)
+	if !ok || lua.Script != "./filters/platform.lua" {
+		t.Fatalf("Unexpected filter definition: %+v", definition)
+	}
+
+	t.Log("Parsing the definition with a different runner...")
+	definition, err = regolith.FilterInstallerFromObject(
+		"lua", map[string]interface{}{
+			"runWith": "shell",
+			"command": "./filters/default.sh",
+			"platforms": map[string]interface{}{
+				runtime.GOOS: map[string]interface{}{
+					"runWith": "lua", "script": "./filters/os.lua"},
+			},
+		})
+	if err != nil {
+		t.Fatal("Unable to parse the filter definition:", err.Error())
+	}
+	lua, ok = definition.(*regolith.LuaFilterDefinition)
+	if !ok || lua.Script != "./filters/os.lua" {
+		t.Fatalf("Unexpected filter definition: %+v", definition)
+	}
+
+	t.Log("Parsing the filter of a profile...")
+	runner, err := regolith.FilterRunnerFromObjectAndDefinitions(
+		map[string]interface{}{
+			"filter":    "lua",
+			"arguments": []interface{}{"default"},
+			"platforms": map[string]interface{}{
+				runtime.GOOS: map[string]interface{}{
+					"arguments": []interface{}{"os"}},
+				other: map[string]interface{}{
+					"arguments": []interface{}{"other"}},
+			},
+		}, map[string]regolith.FilterInstaller{"lua": lua})
+	if err != nil {
+		t.Fatal("Unable to parse the filter:", err.Error())
+	}
+	luaFilter, ok := runner.(*regolith.LuaFilter)
+	if !ok || !reflect.DeepEqual(luaFilter.Arguments, []string{"os"}) {
+		t.Fatalf("Unexpected filter: %+v", runner)
+	}
+
+	t.Log("Parsing the invalid platforms...")
+	for _, platforms := range []interface{}{
+		"windows",
+		map[string]interface{}{runtime.GOOS: "./filters/os.lua"},
+	} {
+		_, err := regolith.FilterInstallerFromObject(
+			"lua", map[string]interface{}{
+				"runWith":   "lua",
+				"script":    "./filters/default.lua",
+				"platforms": platforms,
+			})
+		if err == nil {
+			t.Errorf("The platforms %v were accepted.", platforms)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testFilterProtocol runs the profiles of a project with a filter that uses
+// the "stdio" protocol. The "dev" profile checks if the messages longer than
+// the default limit of bufio.Scanner (64 KiB) are handled, and the "fail"
+// profile checks if the error diagnostics make the run fail.
+func testFilterProtocol(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, filterProtocolPath)
+	defer cleanup()
+	logs, restoreLogger := captureLogs()
+	defer restoreLogger()
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "done.txt"), "done")
+	message := strings.Repeat("x", 1024*1024)
+	if logs.FilterMessageSnippet(message).Len() == 0 {
+		t.Fatal("The long message of the filter wasn't logged.")
+	}
+	err := regolith.Run("fail", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter reported an error")
+	}
+	if !strings.Contains(err.Error(), "reported 1 error(s)") {
+		t.Fatal("Unexpected error:", err.Error())
+	}
+}
+
+func TestFilterProtocol(t *testing.T) {
+	testFilterProtocol(t, false)
+}
+
+func TestFilterProtocolRecycled(t *testing.T) {
+	testFilterProtocol(t, true)
+}
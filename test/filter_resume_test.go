@@ -0,0 +1,50 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestFilterResume watches a project with the daemon and changes the files
+// used only by the second filter. The watch mode must reuse the output of
+// the first filter from the checkpoint instead of running it again.
+func TestFilterResume(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterResumePath)
+	defer cleanup()
+	daemon, stop := startTestDaemon(t, 0)
+	defer stop()
+	success := "Successfully ran the \"dev\" profile."
+	expectRuns := func(textures, entities string) {
+		expectFileContent(t, "textures_runs.txt", textures)
+		expectFileContent(t, "entities_runs.txt", entities)
+		expectFileContent(
+			t, filepath.Join("build", "BP", "entities.txt"), "atlas")
+	}
+
+	// THE TEST
+	t.Log("Watching the project...")
+	conn, reader := daemon.send(
+		t, map[string]interface{}{"command": "watch", "profile": "dev"})
+	defer conn.Close()
+	awaitDaemonMessage(t, conn, reader, success)
+	expectRuns("1", "1")
+
+	t.Log("Changing the files of the second filter...")
+	writeTestFile(t, filepath.Join("packs", "BP", "entities", "b.json"), "{}")
+	awaitDaemonMessage(
+		t, conn, reader, "The changed files don't affect the first 1 "+
+			"filters, reusing their output.")
+	awaitDaemonMessage(t, conn, reader, success)
+	expectRuns("1", "2")
+	expectFileContent(t, filepath.Join("build", "RP", "atlas.txt"), "atlas")
+	expectFileContent(
+		t, filepath.Join("build", "BP", "entities", "b.json"), "{}")
+
+	t.Log("Changing the files of the first filter...")
+	writeTestFile(t, filepath.Join("packs", "RP", "textures", "b.txt"), "b")
+	awaitDaemonMessage(t, conn, reader, success)
+	expectRuns("2", "3")
+	expectFileContent(
+		t, filepath.Join("build", "RP", "textures", "b.txt"), "b")
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testFilterSandbox runs a filter that changes the files of the project
+// outside of the tmp directory, with every mode of the "sandbox" property.
+// The changes aren't checked by default, they're printed by "warn", they stop
+// the run with "error", and the "allowedPaths" of the filter aren't
+// reported.
+func testFilterSandbox(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	// The filter runs in the .regolith/tmp directory
+	writeTestFile(
+		t, "outside.py",
+		"with open(\"../../notes.txt\", \"w\") as f:\n"+
+			"    f.write(\"notes\")\n"+
+			"with open(\"../../packs/RP/c.json\", \"a\") as f:\n"+
+			"    f.write(\" \")\n")
+	filter := "{\"filter\": \"outside\"}"
+	replaceInTestFile(
+		t, "config.json", "\"filters\": []", "\"filters\": ["+filter+"]")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {}",
+		"\"filterDefinitions\": {\"outside\": {\"runWith\": \"python\", "+
+			"\"script\": \"./outside.py\"}}")
+	sandbox := "\"dataPath\""
+	setSandbox := func(mode string) {
+		replacement := "\"sandbox\": \"" + mode + "\", \"dataPath\""
+		replaceInTestFile(t, "config.json", sandbox, replacement)
+		sandbox = replacement
+	}
+	logs, restore := captureLogs()
+	defer restore()
+	changes := "Files outside of the tmp directory were changed while the " +
+		"filter \"outside\" was running:\ncreated: notes.txt\n" +
+		"modified: packs/RP/c.json\n"
+	run := func() error {
+		if err := os.Remove("notes.txt"); err != nil && !os.IsNotExist(err) {
+			t.Fatal("Unable to remove the file:", err)
+		}
+		logs.TakeAll()
+		return regolith.Run("log", nil, recycled, true)
+	}
+
+	// THE TEST
+	t.Log("Running the filter without the sandbox...")
+	if err := run(); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(changes).Len() != 0 {
+		t.Error("The changes were checked without the sandbox.")
+	}
+	expectFileContent(t, "notes.txt", "notes")
+
+	t.Log("Running the filter with the \"warn\" sandbox...")
+	setSandbox("warn")
+	if err := run(); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(changes).Len() != 1 {
+		t.Errorf("Missing the warning about the changes: %q", changes)
+	}
+
+	t.Log("Running the filter with the \"error\" sandbox...")
+	setSandbox("error")
+	err := run()
+	if err == nil || !strings.Contains(err.Error(), changes) {
+		t.Fatal("Expected the error about the changes, got:", err)
+	}
+
+	t.Log("Running the filter with the allowed paths...")
+	replaceInTestFile(
+		t, "config.json", filter,
+		"{\"filter\": \"outside\", "+
+			"\"allowedPaths\": [\"notes.txt\", \"packs/RP/**\"]}")
+	if err := run(); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+
+	t.Log("Using an invalid mode...")
+	setSandbox("strict")
+	if err := run(); err == nil {
+		t.Error("'regolith run' accepted an invalid \"sandbox\" mode.")
+	}
+}
+
+func TestFilterSandbox(t *testing.T) {
+	testFilterSandbox(t, false)
+}
+
+func TestFilterSandboxRecycled(t *testing.T) {
+	testFilterSandbox(t, true)
+}
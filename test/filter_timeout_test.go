@@ -0,0 +1,36 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testFilterTimeout runs a Lua filter that never finishes and checks if it's
+// stopped by its timeout, so it can be retried.
+func testFilterTimeout(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterTimeoutPath)
+	defer cleanup()
+	// THE TEST
+	err := regolith.Run("dev", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter should time out")
+	}
+	if !strings.Contains(err.Error(), "time limit") {
+		t.Errorf("The run failed for another reason than the timeout: %s", err)
+	}
+	if strings.Contains(err.Error(), "didn't stop") {
+		t.Errorf("The filter wasn't stopped by the timeout: %s", err)
+	}
+	// The filter is retried only if it stopped after the first timeout
+	expectFileContent(t, "attempts.txt", "2")
+}
+
+func TestFilterTimeout(t *testing.T) {
+	testFilterTimeout(t, false)
+}
+
+func TestFilterTimeoutRecycled(t *testing.T) {
+	testFilterTimeout(t, true)
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// gitAuthToken is the personal access token accepted by the server from
+// newPrivateGitServer.
+const gitAuthToken = "regolith-test-token"
+
+// newPrivateGitServer serves the repository over HTTPS with the smart HTTP
+// protocol of git, like a private repository on GitHub. The server accepts
+// only the requests authenticated with gitAuthToken. It returns the URL of
+// the repository without the scheme, like the URLs of the remote filters.
+func newPrivateGitServer(t *testing.T, repo *filterRepo) string {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Fatal("Unable to find the programs of git:", err)
+	}
+	backend := &cgi.Handler{
+		Path: filepath.Join(
+			strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + filepath.Dir(repo.path),
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, password, ok := r.BasicAuth()
+			if !ok || password != gitAuthToken {
+				w.Header().Set("WWW-Authenticate", "Basic realm=\"filters\"")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			backend.ServeHTTP(w, r)
+		}))
+	t.Cleanup(server.Close)
+	// The certificate of the test server is self-signed
+	t.Setenv("GIT_SSL_NO_VERIFY", "1")
+	return strings.TrimPrefix(server.URL, "https://") + "/" +
+		filepath.Base(repo.path)
+}
+
+// expectAccessDenied checks if the error explains that the access to the
+// repository was denied, without revealing the token.
+func expectAccessDenied(t *testing.T, err error, token string) {
+	if err == nil {
+		t.Fatal("'regolith install-all' succeeded without the access to " +
+			"the repository")
+	}
+	if !strings.Contains(
+		err.Error(), "Access to the filter repository was denied.") {
+		t.Fatal("Unexpected error:", err.Error())
+	}
+	if token != "" && strings.Contains(err.Error(), token) {
+		t.Fatal("The error reveals the token:", err.Error())
+	}
+}
+
+// TestGitAuthToken installs a remote filter from a private repository,
+// which requires the personal access token from the REGOLITH_GIT_TOKEN
+// environment variable. The installation without the token or with a wrong
+// token fails with an error that explains how to authenticate.
+func TestGitAuthToken(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	url := newPrivateGitServer(t, repo)
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", url)
+
+	// THE TEST
+	t.Log("Installing the filter without the token...")
+	t.Setenv("REGOLITH_GIT_TOKEN", "")
+	expectAccessDenied(t, regolith.InstallAll(false, false, true), "")
+
+	t.Log("Installing the filter with a wrong token...")
+	t.Setenv("REGOLITH_GIT_TOKEN", "wrong-regolith-token")
+	expectAccessDenied(
+		t, regolith.InstallAll(false, false, true), "wrong-regolith-token")
+
+	t.Log("Installing the filter with the token...")
+	t.Setenv("REGOLITH_GIT_TOKEN", gitAuthToken)
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+}
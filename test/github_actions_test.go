@@ -0,0 +1,95 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/fatih/color"
+)
+
+// testGithubActions runs the profiles of a project with the "github" log
+// format. The diagnostics of the filters and the errors of the runs are
+// printed as the annotations of GitHub Actions, and the results of the runs
+// are appended to the step summary of the job.
+func testGithubActions(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterProtocolPath)
+	defer cleanup()
+	projectDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get the working directory:", err)
+	}
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	t.Setenv("GITHUB_WORKSPACE", filepath.Dir(projectDir))
+	regolith.LogFormat = "github"
+	defer func() { regolith.LogFormat = "text" }()
+	var output bytes.Buffer
+	colorOutput := color.Output
+	color.Output = &output
+	defer func() { color.Output = colorOutput }()
+	_, restore := captureLogs()
+	defer restore()
+	// The paths of the annotations are relative to the workspace, and the
+	// paths in the summary are relative to the project
+	file := filepath.Base(projectDir) + "/BP/done.txt"
+	expectSummary := func(snippets ...string) {
+		summary, err := os.ReadFile(summaryPath)
+		if err != nil {
+			t.Fatal("Unable to read the step summary:", err)
+		}
+		for _, snippet := range snippets {
+			if !strings.Contains(string(summary), snippet) {
+				t.Errorf(
+					"Missing %q in the step summary:\n%s", snippet, summary)
+			}
+		}
+	}
+
+	// THE TEST
+	t.Log("Running the failing profile...")
+	if err := regolith.Run("fail", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter reported an error")
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	expected := "::error col=1,file=" + file + ",line=1,title=protocol::" +
+		"Test diagnostic"
+	if len(lines) != 2 || lines[0] != expected ||
+		!strings.HasPrefix(lines[1], "::error title=Regolith::") ||
+		!strings.Contains(lines[1], "reported 1 error(s)") {
+		t.Errorf("Wrong annotations of the failed run:\n%s", output.String())
+	}
+	expectSummary(
+		"### ❌ Regolith: the \"fail\" profile failed after ",
+		"| Phase | Duration |",
+		"| error | protocol | BP/done.txt:1 | Test diagnostic |")
+
+	t.Log("Running the successful profile...")
+	output.Reset()
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expected = "::warning col=1,file=" + file + ",line=1,title=protocol::" +
+		"Test diagnostic\n"
+	if output.String() != expected {
+		t.Errorf(
+			"Wrong annotations of the successful run.\nExpected: %q\n"+
+				"Actual: %q", expected, output.String())
+	}
+	// The summaries of the runs are appended to the file
+	expectSummary(
+		"### ❌ Regolith: the \"fail\" profile failed after ",
+		"### ✅ Regolith: the \"dev\" profile succeeded in ",
+		"| warning | protocol | BP/done.txt:1 | Test diagnostic |")
+}
+
+func TestGithubActions(t *testing.T) {
+	testGithubActions(t, false)
+}
+
+func TestGithubActionsRecycled(t *testing.T) {
+	testGithubActions(t, true)
+}
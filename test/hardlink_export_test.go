@@ -0,0 +1,60 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testHardlinkExport runs a project that links the source files to the tmp
+// directory in the reproducible mode, which changes the modification times
+// of the exported files. It checks if the exported file isn't the same file
+// as its source, and if the source file keeps its modification time.
+func testHardlinkExport(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, hardlinkExportPath)
+	defer cleanup()
+	regolith.Reproducible = true
+	defer func() { regolith.Reproducible = false }()
+	source := filepath.Join(tmpDir, "packs", "BP", "data.json")
+	sourceTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(source, sourceTime, sourceTime); err != nil {
+		t.Fatal("Unable to change the time of the source file:", err)
+	}
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	exported := filepath.Join(tmpDir, "build", "BP", "data.json")
+	expectFileContent(t, exported, "{\"value\": 1}\n")
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		t.Fatal("Unable to read the source file info:", err)
+	}
+	exportedInfo, err := os.Stat(exported)
+	if err != nil {
+		t.Fatal("Unable to read the exported file info:", err)
+	}
+	if os.SameFile(sourceInfo, exportedInfo) {
+		t.Fatal("The exported file is a hardlink to the source file.")
+	}
+	if !sourceInfo.ModTime().Equal(sourceTime) {
+		t.Fatalf(
+			"The export changed the time of the source file from %s to %s.",
+			sourceTime, sourceInfo.ModTime())
+	}
+}
+
+// TestHardlinkExport runs testHardlinkExport with the standard setup of the
+// tmp directory.
+func TestHardlinkExport(t *testing.T) {
+	testHardlinkExport(t, false)
+}
+
+// TestHardlinkExportRecycled runs testHardlinkExport with the recycled
+// setup of the tmp directory.
+func TestHardlinkExportRecycled(t *testing.T) {
+	testHardlinkExport(t, true)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// otherFileSystemDir is a directory that is usually on another file system
+// than the temporary directory of the tests, so the packs exported to it
+// can't be moved from the tmp directory of the project.
+const otherFileSystemDir = "/dev/shm"
+
+// expectCopiedFiles checks if the incremental export logged that it copied
+// and removed the numbers of files from one of the packs.
+func expectCopiedFiles(
+	t *testing.T, logs *observer.ObservedLogs, copied, removed int,
+) {
+	expected := fmt.Sprintf(
+		"Copied %d changed files and removed %d old files.", copied, removed)
+	if logs.FilterMessage(expected).Len() == 0 {
+		t.Errorf("Missing log message: %q", expected)
+	}
+}
+
+// testIncrementalExport exports the packs to a directory on another file
+// system than the project, which can't be done by moving the packs. The
+// export without the recycled mode copies only the changed files and removes
+// the files that are no longer a part of the packs. The files edited in the
+// export path are copied again. The recycled mode has its own way of
+// exporting only the changed files, so only the exported files are checked.
+func testIncrementalExport(t *testing.T, recycled bool) {
+	if _, err := os.Stat(otherFileSystemDir); err != nil {
+		t.Skipf("%s doesn't exist", otherFileSystemDir)
+	}
+	exportDir, err := os.MkdirTemp(otherFileSystemDir, "regolith-test-")
+	if err != nil {
+		t.Fatal("Unable to create the export directory:", err)
+	}
+	defer os.RemoveAll(exportDir)
+	_, cleanup := prepareTestProject(t, incrementalExportPath)
+	defer cleanup()
+	config, err := os.ReadFile("config.json")
+	if err != nil {
+		t.Fatal("Unable to read config.json:", err)
+	}
+	writeTestFile(
+		t, "config.json",
+		strings.ReplaceAll(
+			string(config), "EXPORT_DIR", filepath.ToSlash(exportDir)))
+	bpPath := filepath.Join(exportDir, "BP")
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Exporting the packs for the first time...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, filepath.Join(bpPath, "data.json"), "{\"value\": 1}\n")
+	expectFileContent(t, filepath.Join(bpPath, "old.json"), "{\"old\": true}\n")
+	if !recycled {
+		if logs.FilterMessageSnippet("Copied ").Len() == 0 {
+			t.Skipf(
+				"%s is on the same file system as the project", exportDir)
+		}
+		expectCopiedFiles(t, logs, 3, 0)
+	}
+
+	t.Log("Exporting the changed and removed files...")
+	logs.TakeAll()
+	writeTestFile(
+		t, filepath.Join("packs", "BP", "data.json"), "{\"value\": 2}\n")
+	if err := os.Remove(filepath.Join("packs", "BP", "old.json")); err != nil {
+		t.Fatal("Unable to remove old.json:", err)
+	}
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, filepath.Join(bpPath, "data.json"), "{\"value\": 2}\n")
+	expectFileContent(
+		t, filepath.Join(bpPath, "keep.json"), "{\"keep\": true}\n")
+	expectNotExist(t, filepath.Join(bpPath, "old.json"))
+	if !recycled {
+		expectCopiedFiles(t, logs, 1, 1) // Behavior pack
+		expectCopiedFiles(t, logs, 0, 0) // Resource pack
+	}
+
+	t.Log("Exporting the packs after editing the exported file...")
+	logs.TakeAll()
+	writeTestFile(
+		t, filepath.Join("packs", "BP", "data.json"), "{\"value\": 3}\n")
+	writeTestFile(
+		t, filepath.Join(bpPath, "keep.json"), "{\"keep\": false}\n")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(
+		t, filepath.Join(bpPath, "data.json"), "{\"value\": 3}\n")
+	if !recycled {
+		expectFileContent(
+			t, filepath.Join(bpPath, "keep.json"), "{\"keep\": true}\n")
+		expectCopiedFiles(t, logs, 2, 0)
+	}
+}
+
+func TestIncrementalExport(t *testing.T) {
+	testIncrementalExport(t, false)
+}
+
+func TestIncrementalExportRecycled(t *testing.T) {
+	testIncrementalExport(t, true)
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// enterEmptyDir changes the working directory to a new empty temporary
+// directory. It returns a function that restores the working directory.
+func enterEmptyDir(t *testing.T) func() {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal("Unable to enter the temporary directory:", err)
+	}
+	return func() { os.Chdir(wd) }
+}
+
+// manifestUuids returns the UUIDs of the header and the dependencies of the
+// manifest of a pack.
+func manifestUuids(t *testing.T, path string) (string, []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unable to read the manifest:", err)
+	}
+	var manifest struct {
+		Header struct {
+			Uuid string `json:"uuid"`
+		} `json:"header"`
+		Dependencies []struct {
+			Uuid string `json:"uuid"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal("Unable to parse the manifest:", err)
+	}
+	var dependencies []string
+	for _, dependency := range manifest.Dependencies {
+		dependencies = append(dependencies, dependency.Uuid)
+	}
+	return manifest.Header.Uuid, dependencies
+}
+
+// TestInitStarterTemplate creates a project from the built-in "starter"
+// template. The manifests of the packs get new UUIDs and depend on each
+// other.
+func TestInitStarterTemplate(t *testing.T) {
+	isolateUserDirs(t)
+	defer enterEmptyDir(t)()
+
+	// THE TEST
+	err := regolith.InitFromTemplate("starter", "My Project", "", true)
+	if err != nil {
+		t.Fatal("'regolith init --template starter' failed:", err.Error())
+	}
+	config := readConfigFile(t)
+	if config["name"] != "My Project" {
+		t.Errorf("Unexpected name of the project: %v", config["name"])
+	}
+	if _, err := regolith.ConfigFromObject(config); err != nil {
+		t.Errorf("Invalid config of the template: %s", err.Error())
+	}
+	bpUuid, bpDependencies := manifestUuids(
+		t, filepath.Join("packs", "BP", "manifest.json"))
+	rpUuid, _ := manifestUuids(
+		t, filepath.Join("packs", "RP", "manifest.json"))
+	if bpUuid == rpUuid || strings.Contains(bpUuid+rpUuid, "${") {
+		t.Errorf("Invalid UUIDs of the packs: %q, %q", bpUuid, rpUuid)
+	}
+	if len(bpDependencies) != 1 || bpDependencies[0] != rpUuid {
+		t.Errorf("The BP doesn't depend on the RP: %v", bpDependencies)
+	}
+	if _, err := os.Stat(".gitignore"); err != nil {
+		t.Error("Missing .gitignore:", err)
+	}
+	err = regolith.InitFromTemplate("starter", "My Project", "", true)
+	if err == nil {
+		t.Fatal("'regolith init' accepted a directory that isn't empty")
+	}
+}
+
+// TestInitUserTemplate creates a project from a template from the user
+// config, with a custom namespace in the names and the contents of its
+// files.
+func TestInitUserTemplate(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	template := t.TempDir()
+	writeTestFile(
+		t, filepath.Join(template, "config.json"),
+		"{\"name\": \"${PROJECT_NAME}\"}\n")
+	writeTestFile(
+		t, filepath.Join(template, "${NAMESPACE}", "entity.txt"),
+		"${NAMESPACE}:entity ${UNKNOWN}\n")
+	setUserConfigProperty(
+		t, configPath, "templates", map[string]string{"mine": template})
+	setUserConfigProperty(t, configPath, "initTemplate", "mine")
+	defer enterEmptyDir(t)()
+
+	// THE TEST
+	t.Log("Using the invalid templates and namespaces...")
+	err := regolith.InitFromTemplate("", "Name", "Bad-NS", true)
+	if err == nil {
+		t.Fatal("'regolith init' accepted an invalid namespace")
+	}
+	err = regolith.InitFromTemplate("missing", "Name", "", true)
+	if err == nil || !strings.Contains(err.Error(), "mine") {
+		t.Fatal("Expected an error listing the templates, got:", err)
+	}
+
+	t.Log("Creating the project from the default template...")
+	err = regolith.InitFromTemplate("", "Other Project", "custom_ns", true)
+	if err != nil {
+		t.Fatal("'regolith init' failed:", err.Error())
+	}
+	expectFileContent(t, "config.json", "{\"name\": \"Other Project\"}\n")
+	expectFileContent(
+		t, filepath.Join("custom_ns", "entity.txt"),
+		"custom_ns:entity ${UNKNOWN}\n")
+}
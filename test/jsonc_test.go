@@ -0,0 +1,49 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestJsoncConfig runs a project whose config.json has comments and
+// trailing commas. The comment characters inside of the strings must stay
+// unchanged.
+func TestJsoncConfig(t *testing.T) {
+	_, cleanup := prepareTestProject(t, jsoncConfigPath)
+	defer cleanup()
+
+	// THE TEST
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join("build", "BP", "message.txt"),
+		"// not a comment /* # */ \"quoted\"")
+}
+
+// TestJsoncFilterJson installs and runs a remote filter whose filter.json
+// has comments and trailing commas.
+func TestJsoncFilterJson(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	files := helloFilterFiles("1")
+	files["hello/filter.json"] = "// The filter with comments\n" +
+		"{\n" +
+		"\t\"filters\": [\n" +
+		"\t\t{\n" +
+		"\t\t\t\"runWith\": \"lua\", # The runner\n" +
+		"\t\t\t\"script\": \"hello.lua\", /* The script */\n" +
+		"\t\t},\n" +
+		"\t],\n" +
+		"}\n"
+	repo.commit(files, "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+
+	// THE TEST
+	installAndRunFilters(t)
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// junitReport is the part of the JUnit XML report checked by the tests.
+type junitReport struct {
+	Tests    int `xml:"tests,attr"`
+	Failures int `xml:"failures,attr"`
+	Skipped  int `xml:"skipped,attr"`
+	Suites   []struct {
+		Name  string `xml:"name,attr"`
+		Cases []struct {
+			Name      string `xml:"name,attr"`
+			ClassName string `xml:"classname,attr"`
+			Failure   *struct {
+				Message string `xml:"message,attr"`
+			} `xml:"failure"`
+			Skipped *struct {
+				Message string `xml:"message,attr"`
+			} `xml:"skipped"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+// readJunitReport reads the JUnit XML report from the path.
+func readJunitReport(t *testing.T, path string) junitReport {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unable to read the JUnit report:", err)
+	}
+	var report junitReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatal("Invalid JUnit report:", err)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("Expected one test suite, got %d.", len(report.Suites))
+	}
+	return report
+}
+
+// testJunitReport runs a profile with the "--junit" flag. Every execution of
+// a filter is a test case of the JUnit XML report, and the skipped and the
+// failed filters are marked in the report.
+func testJunitReport(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+	regolith.JunitOutput = filepath.Join("reports", "junit.xml")
+	defer func() { regolith.JunitOutput = "" }()
+	// The filters that don't depend on each other run in parallel, so the
+	// order of the test cases isn't checked
+	cases := func(report junitReport) string {
+		var result []string
+		for _, c := range report.Suites[0].Cases {
+			status := "passed"
+			if c.Failure != nil {
+				status = "failed"
+			} else if c.Skipped != nil {
+				status = "skipped: " + c.Skipped.Message
+			}
+			result = append(result, c.ClassName+"."+c.Name+" "+status)
+		}
+		sort.Strings(result)
+		return strings.Join(result, "\n")
+	}
+
+	// THE TEST
+	t.Log("Running the profile...")
+	if err := regolith.Run("files", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	report := readJunitReport(t, regolith.JunitOutput)
+	expected := "files.combine passed\nfiles.generate_a passed\n" +
+		"files.generate_b passed\nfiles.skipped skipped: None of the files " +
+		"matches the inputs of the filter."
+	if actual := cases(report); actual != expected {
+		t.Errorf(
+			"Wrong test cases.\nExpected: %q\nActual: %q", expected, actual)
+	}
+	if report.Tests != 4 || report.Failures != 0 || report.Skipped != 1 ||
+		report.Suites[0].Name != "files" {
+		t.Errorf("Wrong totals of the report: %+v", report)
+	}
+
+	t.Log("Running the profile with a failing filter...")
+	writeTestFile(
+		t, filepath.Join("filters", "combine.lua"), "error(\"Failed\")\n")
+	if err := regolith.Run("files", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded with a failing filter.")
+	}
+	report = readJunitReport(t, regolith.JunitOutput)
+	expected = "files.combine failed\nfiles.generate_a passed\n" +
+		"files.generate_b passed"
+	if actual := cases(report); actual != expected {
+		t.Errorf(
+			"Wrong test cases.\nExpected: %q\nActual: %q", expected, actual)
+	}
+	if report.Tests != 3 || report.Failures != 1 || report.Skipped != 0 {
+		t.Errorf("Wrong totals of the report: %+v", report)
+	}
+}
+
+func TestJunitReport(t *testing.T) {
+	testJunitReport(t, false)
+}
+
+func TestJunitReportRecycled(t *testing.T) {
+	testJunitReport(t, true)
+}
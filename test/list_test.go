@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestList prints the profiles of a project with "regolith list". The
+// remote filter is listed with its resolved version after the installation,
+// the filters with the "when" expressions with their statuses, and the
+// filter definition that isn't used by the profiles separately.
+func TestList(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	replaceInTestFile(
+		t, "config.json", "\"profiles\": {",
+		"\"profiles\": {\"release\": {\"filters\": ["+
+			"{\"filter\": \"hello\", \"when\": \"define.release\"}, "+
+			"{\"filter\": \"hello\", \"when\": \"unknown\"}, "+
+			"{\"profile\": \"dev\"}], "+
+			"\"export\": {\"target\": \"exact\", \"bpPath\": \"bp\", "+
+			"\"rpPath\": \"rp\"}},")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {",
+		"\"filterDefinitions\": {\"unused\": "+
+			"{\"runWith\": \"lua\", \"script\": \"./unused.lua\"},")
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Listing the profiles before installing the filter...")
+	if err := regolith.List(true); err != nil {
+		t.Fatal("'regolith list' failed:", err.Error())
+	}
+	expectLogs(
+		t, logs,
+		"Profiles: 2",
+		"  dev (export target: local)",
+		"    1. hello (remote, HEAD -> not installed, enabled)",
+		"  release (export target: exact)",
+		"    1. hello (remote, HEAD -> not installed, disabled)",
+		"    2. hello (remote, HEAD -> not installed, invalid \"when\" "+
+			"expression)",
+		"    3. profile: dev (enabled)",
+		"Filter definitions not used by any profile: 1",
+		"  unused (lua)")
+
+	t.Log("Listing the profiles after installing the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	resolved := readLockFile(t).Filters["hello"].Resolved
+	if err := regolith.List(true); err != nil {
+		t.Fatal("'regolith list' failed:", err.Error())
+	}
+	expectLogs(
+		t, logs,
+		"    1. hello (remote, HEAD -> "+resolved+", enabled)")
+}
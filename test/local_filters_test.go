@@ -86,7 +86,7 @@ func testRegolithRunMissingRp(t *testing.T, recycled bool) {
 	// Switch to the working directory
 	os.Chdir(tmpDir)
 	// THE TEST
-	err = regolith.Run("dev", recycled, true)
+	err = regolith.Run("dev", nil, recycled, true)
 	if err != nil {
 		t.Fatal("'regolith run' failed:", err)
 	}
@@ -133,14 +133,14 @@ func TestLocalRequirementsInstallAndRun(t *testing.T) {
 	// Switch to the working directory
 	os.Chdir(filepath.Join(tmpDir, "project"))
 	// THE TEST
-	err = regolith.InstallAll(false, true)
+	err = regolith.InstallAll(false, false, true)
 	if err != nil {
 		t.Fatal("'regolith install-all' failed", err.Error())
 	}
 	if err := regolith.Unlock(true); err != nil {
 		t.Fatal("'regolith unlock' failed:", err.Error())
 	}
-	if err := regolith.Run("dev", false, true); err != nil {
+	if err := regolith.Run("dev", nil, false, true); err != nil {
 		t.Fatal("'regolith run' failed:", err.Error())
 	}
 }
@@ -189,7 +189,7 @@ func testExeFilterRun(t *testing.T, recycled bool) {
 	if err := regolith.Unlock(true); err != nil {
 		t.Fatal("'regolith unlock' failed:", err.Error())
 	}
-	if err := regolith.Run("dev", recycled, true); err != nil {
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
 		t.Fatal("'regolith run' failed:", err.Error())
 	}
 	// Load expected result
@@ -264,7 +264,7 @@ func testProfileFilterRun(t *testing.T, recycled bool) {
 	t.Log("Running invalid profile filter with circular " +
 		"dependencies (this should fail)")
 	if err := regolith.Run(
-		"invalid_circular_profile_1", recycled, true); err == nil {
+		"invalid_circular_profile_1", nil, recycled, true); err == nil {
 		t.Fatal("'regolith run' didn't return an error after running"+
 			" a circular profile filter:", err.Error())
 	} else {
@@ -272,7 +272,7 @@ func testProfileFilterRun(t *testing.T, recycled bool) {
 	}
 	t.Log("Running valid profile filter ")
 	if err := regolith.Run(
-		"correct_nested_profile", recycled, true); err != nil {
+		"correct_nested_profile", nil, recycled, true); err != nil {
 		t.Fatal("'regolith run' failed:", err.Error())
 	}
 	// Load expected result
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// readLockFile reads the regolith-lock.json file of the project in the
+// working directory.
+func readLockFile(t *testing.T) *regolith.LockFile {
+	lockFile := &regolith.LockFile{}
+	data, err := os.ReadFile(regolith.LockFilePath)
+	if err != nil {
+		t.Fatal("Unable to read the lock file:", err)
+	}
+	if err := json.Unmarshal(data, lockFile); err != nil {
+		t.Fatal("Unable to parse the lock file:", err)
+	}
+	return lockFile
+}
+
+// expectLockedFilter checks if the filter is locked in the version.
+func expectLockedFilter(t *testing.T, name, resolved string) {
+	locked, ok := readLockFile(t).Filters[name]
+	if !ok {
+		t.Fatalf("The %q filter is not in the lock file.", name)
+	}
+	if locked.Resolved != resolved {
+		t.Fatalf(
+			"The %q filter is locked in a wrong version.\n"+
+				"Expected: %s\nActual: %s", name, resolved, locked.Resolved)
+	}
+	if !strings.HasPrefix(locked.Integrity, "sha256-") {
+		t.Fatalf("Invalid integrity hash of %q: %q", name, locked.Integrity)
+	}
+}
+
+// installAndRunFilters installs the filters of the project in the working
+// directory, like a fresh clone of the project, and runs the "dev" profile.
+func installAndRunFilters(t *testing.T) {
+	if err := os.RemoveAll(".regolith"); err != nil {
+		t.Fatal("Unable to remove the .regolith directory:", err)
+	}
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err)
+	}
+	if err := regolith.Unlock(true); err != nil {
+		t.Fatal("'regolith unlock' failed:", err)
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+}
+
+// TestLockFile installs a remote filter defined with the "HEAD" version and
+// checks if "regolith install-all" keeps installing the commit from the lock
+// file after new commits are added to the repository of the filter, until
+// the filter is updated with "regolith update".
+func TestLockFile(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	first := repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	hello := filepath.Join("build", "BP", "hello.txt")
+
+	// THE TEST
+	t.Log("Installing the filter...")
+	installAndRunFilters(t)
+	expectLockedFilter(t, "hello", first)
+	expectFileContent(t, hello, "1")
+
+	t.Log("Installing the filter after a new commit...")
+	second := repo.commit(helloFilterFiles("2"), "")
+	installAndRunFilters(t)
+	expectLockedFilter(t, "hello", first)
+	expectFileContent(t, hello, "1")
+
+	t.Log("Updating the filter...")
+	if err := regolith.Update([]string{"hello"}, true); err != nil {
+		t.Fatal("'regolith update' failed:", err)
+	}
+	expectLockedFilter(t, "hello", second)
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err)
+	}
+	expectFileContent(t, hello, "2")
+
+	t.Log("Installing the filter with the changed definition...")
+	repo.commit(helloFilterFiles("3"), "hello-1.0.0")
+	replaceInTestFile(
+		t, "config.json", "\"version\": \"HEAD\"", "\"version\": \"1.0.0\"")
+	installAndRunFilters(t)
+	expectLockedFilter(t, "hello", "1.0.0")
+	expectFileContent(t, hello, "3")
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// expectLogFields checks if the first message with the text has the fields
+// with the values. The "duration" field only has to exist.
+func expectLogFields(
+	t *testing.T, logs *observer.ObservedLogs, message string,
+	fields map[string]interface{},
+) {
+	entries := logs.FilterMessage(message).All()
+	if len(entries) == 0 {
+		t.Errorf("Missing the message %q.", message)
+		return
+	}
+	actual := entries[0].ContextMap()
+	if len(actual) != len(fields) {
+		t.Errorf("Wrong fields of the message %q: %v", message, actual)
+	}
+	for key, value := range fields {
+		if key == "duration" {
+			if _, ok := actual[key].(time.Duration); !ok {
+				t.Errorf("Missing the duration of the message %q: %v",
+					message, actual)
+			}
+		} else if actual[key] != value {
+			t.Errorf("Wrong %q field of the message %q: %v",
+				key, message, actual[key])
+		}
+	}
+}
+
+// TestLogFormat checks if the messages of the JSON log format have the
+// fields with the filters and the phases of the run.
+func TestLogFormat(t *testing.T) {
+	testLogFormat(t, false)
+}
+
+// TestLogFormatRecycled is the same as TestLogFormat but uses the recycled
+// version of the function.
+func TestLogFormatRecycled(t *testing.T) {
+	testLogFormat(t, true)
+}
+
+func testLogFormat(t *testing.T, recycled bool) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterResumePath)
+	defer cleanup()
+	defer func() { regolith.LogFormat = "text" }()
+
+	// THE TEST
+	t.Log("Running the project with the text log format...")
+	logs, restore := captureLogs()
+	err := regolith.Run("dev", nil, recycled, true)
+	restore()
+	if err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectLogFields(t, logs, "Running filter textures", nil)
+	if logs.FilterMessageSnippet("Finished ").Len() != 0 {
+		t.Error("The text log format logged the phases of the run.")
+	}
+
+	t.Log("Running the project with the JSON log format...")
+	regolith.LogFormat = "json"
+	logs, restore = captureLogs()
+	err = regolith.Run("dev", nil, recycled, true)
+	restore()
+	if err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectLogFields(t, logs, "Running filter textures",
+		map[string]interface{}{"filter": "textures"})
+	expectLogFields(t, logs, "Running filter entities",
+		map[string]interface{}{"filter": "entities"})
+	for _, phase := range []string{"setup", "export"} {
+		expectLogFields(t, logs, "Finished "+phase,
+			map[string]interface{}{
+				"phase": phase, "profile": "dev", "duration": nil,
+			})
+	}
+	expectLogFields(t, logs, "Finished filter entities",
+		map[string]interface{}{
+			"phase": "filter", "filter": "entities", "profile": "dev",
+			"duration": nil,
+		})
+}
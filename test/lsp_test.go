@@ -0,0 +1,246 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// lspMessage is a message sent by the language server to the editor.
+type lspMessage struct {
+	Id     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// lspDiagnostics are the parameters of the "textDocument/publishDiagnostics"
+// notification.
+type lspDiagnostics struct {
+	Uri         string `json:"uri"`
+	Diagnostics []struct {
+		Range struct {
+			Start struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+		Severity int    `json:"severity"`
+		Message  string `json:"message"`
+	} `json:"diagnostics"`
+}
+
+// frameLspMessage returns the message from the editor to the language
+// server with the "Content-Length" header. The message is a notification if
+// the ID is 0.
+func frameLspMessage(id int, method string, params interface{}) string {
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if id != 0 {
+		message["id"] = id
+	}
+	if params != nil {
+		message["params"] = params
+	}
+	body, _ := json.Marshal(message)
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readLspMessages reads the messages with the "Content-Length" headers from
+// the output of the language server.
+func readLspMessages(t *testing.T, output string) []lspMessage {
+	var result []lspMessage
+	reader := bufio.NewReader(strings.NewReader(output))
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return result
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(
+			strings.TrimPrefix(header, "Content-Length:")))
+		if err != nil {
+			t.Fatalf("Invalid header of the message: %q", header)
+		}
+		reader.ReadString('\n')
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatal("Unable to read the message:", err)
+		}
+		var message lspMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatalf("Invalid message %q: %s", body, err)
+		}
+		result = append(result, message)
+	}
+}
+
+// TestLsp sends the requests of an editor to the language server of
+// Regolith. The server reports the problems of the config.json file, like the
+// invalid values and the filters that don't exist, completes the values from
+// the schema, shows the documentation of the properties, and must be shut
+// down before exiting.
+func TestLsp(t *testing.T) {
+	isolateUserDirs(t)
+	// The language server replaces the logger of Regolith
+	logger, level := regolith.Logger, regolith.LoggerLevel
+	defer regolith.SetLogger(logger, level)
+	path := filepath.Join(t.TempDir(), "config.json")
+	uri := (&url.URL{
+		Scheme: "file",
+		Path:   "/" + strings.TrimPrefix(filepath.ToSlash(path), "/"),
+	}).String()
+	config := strings.Join([]string{
+		"{",
+		"\t\"name\": \"lsp_test_project\",",
+		"\t\"author\": \"Bedrock-OSS\",",
+		"\t\"packs\": {\"behaviorPack\": \"./BP\", " +
+			"\"resourcePack\": \"./RP\"},",
+		"\t\"regolith\": {",
+		"\t\t\"dataPath\": \"./data\",",
+		"\t\t\"logLevel\": \"\",",
+		"\t\t\"profiles\": {",
+		"\t\t\t\"dev\": {",
+		"\t\t\t\t\"filters\": [{\"filter\": \"textures\"}],",
+		"\t\t\t\t\"export\": {\"target\": \"local\", " +
+			"\"readOnly\": false}",
+		"\t\t\t}",
+		"\t\t},",
+		"\t\t\"filterDefinitions\": {",
+		"\t\t\t\"texture\": {\"runWith\": \"lua\", \"script\": \"./t.lua\"}",
+		"\t\t}",
+		"\t}",
+		"}",
+	}, "\n")
+	document := map[string]interface{}{"uri": uri}
+	at := func(line, character int) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": document,
+			"position": map[string]int{
+				"line": line, "character": character},
+		}
+	}
+	lsp := func(messages ...string) ([]lspMessage, error) {
+		setStdin(t, strings.Join(messages, ""))
+		var err error
+		output := captureStdout(t, func() { err = regolith.Lsp(false) })
+		return readLspMessages(t, output), err
+	}
+
+	// THE TEST
+	t.Log("Sending the requests...")
+	messages, err := lsp(
+		frameLspMessage(1, "initialize", map[string]interface{}{}),
+		frameLspMessage(0, "initialized", map[string]interface{}{}),
+		frameLspMessage(0, "textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri": uri, "languageId": "json", "version": 1,
+				"text": config}}),
+		// Inside of the "logLevel" value and of the "dataPath" key
+		frameLspMessage(2, "textDocument/completion", at(6, 15)),
+		frameLspMessage(3, "textDocument/hover", at(5, 4)),
+		frameLspMessage(4, "textDocument/definition", at(5, 4)),
+		frameLspMessage(0, "textDocument/didClose", map[string]interface{}{
+			"textDocument": document}),
+		frameLspMessage(5, "shutdown", nil),
+		frameLspMessage(0, "exit", nil))
+	if err != nil {
+		t.Fatal("'regolith lsp' failed:", err.Error())
+	}
+	if len(messages) != 7 {
+		t.Fatalf("Expected 7 messages, got %d: %+v", len(messages), messages)
+	}
+
+	t.Log("Checking the capabilities...")
+	var initialize struct {
+		Capabilities struct {
+			TextDocumentSync int  `json:"textDocumentSync"`
+			HoverProvider    bool `json:"hoverProvider"`
+		} `json:"capabilities"`
+	}
+	json.Unmarshal(messages[0].Result, &initialize)
+	if messages[0].Id == nil || *messages[0].Id != 1 ||
+		initialize.Capabilities.TextDocumentSync != 1 ||
+		!initialize.Capabilities.HoverProvider {
+		t.Errorf("Wrong response to 'initialize': %s", messages[0].Result)
+	}
+
+	t.Log("Checking the diagnostics...")
+	var diagnostics lspDiagnostics
+	json.Unmarshal(messages[1].Params, &diagnostics)
+	if messages[1].Method != "textDocument/publishDiagnostics" ||
+		diagnostics.Uri != uri {
+		t.Fatalf("Expected the diagnostics, got: %+v", messages[1])
+	}
+	foundLogLevel, foundFilter := false, false
+	for _, d := range diagnostics.Diagnostics {
+		if d.Severity != 1 {
+			continue
+		}
+		switch d.Range.Start.Line {
+		case 6:
+			foundLogLevel = true
+		case 9:
+			foundFilter = d.Message == "The \"textures\" filter doesn't "+
+				"exist.\nDid you mean \"texture\"?"
+		}
+	}
+	if !foundLogLevel || !foundFilter {
+		t.Errorf("Wrong diagnostics: %s", messages[1].Params)
+	}
+
+	t.Log("Checking the completion and the hover...")
+	var completion []struct {
+		Label      string `json:"label"`
+		InsertText string `json:"insertText"`
+	}
+	json.Unmarshal(messages[2].Result, &completion)
+	var labels []string
+	for _, item := range completion {
+		labels = append(labels, item.Label+"="+item.InsertText)
+	}
+	expected := "debug=debug,info=info,warn=warn,error=error"
+	if strings.Join(labels, ",") != expected {
+		t.Errorf("Wrong completion of the log level: %s", messages[2].Result)
+	}
+	var hover struct {
+		Contents struct {
+			Value string `json:"value"`
+		} `json:"contents"`
+	}
+	json.Unmarshal(messages[3].Result, &hover)
+	expected = "**dataPath**\n\nThe path to the data folder of the " +
+		"filters.\n\nType: `string`"
+	if hover.Contents.Value != expected {
+		t.Errorf(
+			"Wrong hover of the \"dataPath\" property.\nExpected: %q\n"+
+				"Actual: %q", expected, hover.Contents.Value)
+	}
+
+	t.Log("Checking the unsupported request and the closed document...")
+	if messages[4].Error == nil || messages[4].Error.Code != -32601 {
+		t.Errorf("Expected the 'method not found' error, got: %+v", messages[4])
+	}
+	diagnostics = lspDiagnostics{}
+	json.Unmarshal(messages[5].Params, &diagnostics)
+	if diagnostics.Uri != uri || len(diagnostics.Diagnostics) != 0 {
+		t.Errorf("The diagnostics weren't cleared: %s", messages[5].Params)
+	}
+	if messages[6].Id == nil || *messages[6].Id != 5 {
+		t.Errorf("Expected the response to 'shutdown', got: %+v", messages[6])
+	}
+
+	t.Log("Exiting without the shutdown...")
+	_, err = lsp(frameLspMessage(0, "exit", nil))
+	if err == nil {
+		t.Error("'regolith lsp' exited without the shutdown without an error.")
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testLuaFilter runs the profiles of a project with the Lua filters. The
+// "dev" profile checks the settings, the arguments, the functions of the
+// "regolith" module and loading the modules from the filter directory. The
+// "fail" profile checks if the errors of the scripts fail the run.
+func testLuaFilter(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, luaFilterPath)
+	defer cleanup()
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "BP", "data.json"),
+		"{\n"+
+			"\t\"count\": 3,\n"+
+			"\t\"files\": 2,\n"+
+			"\t\"greeting\": \"Hello test\",\n"+
+			"\t\"name\": \"test\"\n"+
+			"}")
+	expectFileContent(
+		t, filepath.Join(tmpDir, "build", "RP", "exists.txt"), "true")
+	expectFileContent(
+		t, filepath.Join(tmpDir, "packs", "BP", "data.json"),
+		"{\"count\": 1}\n")
+	err := regolith.Run("fail", nil, recycled, true)
+	if err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter should fail")
+	}
+	if !strings.Contains(err.Error(), "The filter failed on purpose") {
+		t.Fatal("The error doesn't include the error of the script:", err)
+	}
+}
+
+func TestLuaFilter(t *testing.T) {
+	testLuaFilter(t, false)
+}
+
+func TestLuaFilterRecycled(t *testing.T) {
+	testLuaFilter(t, true)
+}
@@ -0,0 +1,186 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// createMojangDir creates the "com.mojang" folder at the path, which is
+// relative to the root directory, and returns its full path.
+func createMojangDir(t *testing.T, root, path string) string {
+	mojangDir := filepath.Join(root, filepath.FromSlash(path))
+	if err := os.MkdirAll(mojangDir, 0755); err != nil {
+		t.Fatal("Unable to create the com.mojang folder:", err)
+	}
+	return mojangDir
+}
+
+// expectDevelopmentExport runs the profile and checks if the behavior pack
+// was exported to the development packs of the "com.mojang" folder.
+func expectDevelopmentExport(
+	t *testing.T, profile, mojangDir string, recycled bool,
+) {
+	if err := regolith.Run(profile, nil, recycled, true); err != nil {
+		t.Fatalf("'regolith run' of %q failed: %s", profile, err.Error())
+	}
+	expectFileContent(
+		t,
+		filepath.Join(
+			mojangDir, "development_behavior_packs",
+			"minecraft_builds_test_project_bp", "data.json"),
+		"{}\n")
+}
+
+// testPreviewExport runs the profile with the "preview" export target. On
+// Windows, the packs are exported to the GDK installation of Minecraft
+// Preview, whose folder is created in a temporary %APPDATA%. Other systems
+// don't have Minecraft Preview, so the export fails.
+func testPreviewExport(t *testing.T, recycled bool) {
+	appData := t.TempDir()
+	t.Setenv("APPDATA", appData)
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	_, cleanup := prepareTestProject(t, minecraftBuildsPath)
+	defer cleanup()
+	// THE TEST
+	if runtime.GOOS != "windows" {
+		if err := regolith.Run("preview", nil, recycled, true); err == nil {
+			t.Fatal("'regolith run' exported the packs to Minecraft Preview")
+		}
+		return
+	}
+	mojangDir := createMojangDir(
+		t, appData, "Minecraft Bedrock Preview/Users/Shared/games/com.mojang")
+	expectDevelopmentExport(t, "preview", mojangDir, recycled)
+}
+
+func TestPreviewExport(t *testing.T) {
+	testPreviewExport(t, false)
+}
+
+func TestPreviewExportRecycled(t *testing.T) {
+	testPreviewExport(t, true)
+}
+
+// testWindowsBuildsExport runs the profiles that export the packs to the
+// GDK and Education Edition builds of Minecraft, whose folders are created
+// in a temporary %APPDATA% and %LOCALAPPDATA%. These builds exist only on
+// Windows.
+func testWindowsBuildsExport(t *testing.T, recycled bool) {
+	if runtime.GOOS != "windows" {
+		t.Skip("The GDK and Education Edition builds exist only on Windows")
+	}
+	appData := t.TempDir()
+	localAppData := t.TempDir()
+	t.Setenv("APPDATA", appData)
+	t.Setenv("LOCALAPPDATA", localAppData)
+	_, cleanup := prepareTestProject(t, minecraftBuildsPath)
+	defer cleanup()
+	// THE TEST
+	for profile, path := range map[string]string{
+		"gdk": "Minecraft Bedrock/Users/Shared/games/com.mojang",
+		"gdk_preview": "Minecraft Bedrock Preview/Users/Shared/games/" +
+			"com.mojang",
+		"education":         "Minecraft Education Edition/games/com.mojang",
+		"education_preview": "Minecraft Education Preview/games/com.mojang",
+	} {
+		mojangDir := createMojangDir(t, appData, path)
+		expectDevelopmentExport(t, profile, mojangDir, recycled)
+	}
+	// The UWP installation of Education Edition is preferred
+	mojangDir := createMojangDir(
+		t, localAppData,
+		"Packages/Microsoft.MinecraftEducationEdition_8wekyb3d8bbwe/"+
+			"LocalState/games/com.mojang")
+	expectDevelopmentExport(t, "education", mojangDir, recycled)
+}
+
+func TestWindowsBuildsExport(t *testing.T) {
+	testWindowsBuildsExport(t, false)
+}
+
+func TestWindowsBuildsExportRecycled(t *testing.T) {
+	testWindowsBuildsExport(t, true)
+}
+
+// TestUnknownMinecraftBuild checks if the unknown builds of Minecraft are
+// rejected.
+func TestUnknownMinecraftBuild(t *testing.T) {
+	_, err := regolith.FindMojangDirOfBuild("unknown")
+	if err == nil {
+		t.Fatal("The unknown build of Minecraft was accepted")
+	}
+}
+
+// testLauncherBuildsExport runs the profiles that export the packs to
+// Minecraft installed with mcpelauncher, natively or from Flathub. Their
+// folders are created in a temporary home directory. The launcher isn't
+// used on Windows.
+func testLauncherBuildsExport(t *testing.T, recycled bool) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mcpelauncher isn't used on Windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	dataHome := "data"
+	if runtime.GOOS == "darwin" {
+		dataHome = "Library/Application Support"
+	}
+	_, cleanup := prepareTestProject(t, minecraftBuildsPath)
+	defer cleanup()
+	// THE TEST
+	flatpakDir := createMojangDir(
+		t, home,
+		".var/app/io.mrarm.mcpelauncher/data/mcpelauncher/games/com.mojang")
+	expectDevelopmentExport(t, "flatpak", flatpakDir, recycled)
+	expectDevelopmentExport(t, "standard", flatpakDir, recycled)
+	if err := regolith.Run("mcpelauncher", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' exported the packs to a missing folder")
+	}
+	// The native installation is preferred
+	nativeDir := createMojangDir(
+		t, home, dataHome+"/mcpelauncher/games/com.mojang")
+	expectDevelopmentExport(t, "mcpelauncher", nativeDir, recycled)
+	expectDevelopmentExport(t, "standard", nativeDir, recycled)
+}
+
+func TestLauncherBuildsExport(t *testing.T) {
+	testLauncherBuildsExport(t, false)
+}
+
+func TestLauncherBuildsExportRecycled(t *testing.T) {
+	testLauncherBuildsExport(t, true)
+}
+
+// testAndroidExport runs the profile that exports the packs to an Android
+// device mounted in the "device" directory of the project. The folder used
+// by the newer versions of Minecraft is preferred.
+func testAndroidExport(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, minecraftBuildsPath)
+	defer cleanup()
+	// THE TEST
+	if err := regolith.Run("android", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' exported the packs to a missing device")
+	}
+	device := filepath.Join(tmpDir, "device")
+	oldDir := createMojangDir(t, device, "games/com.mojang")
+	expectDevelopmentExport(t, "android", oldDir, recycled)
+	newDir := createMojangDir(
+		t, device, "Android/data/com.mojang.minecraftpe/files/games/com.mojang")
+	expectDevelopmentExport(t, "android", newDir, recycled)
+	if _, err := regolith.FindAndroidMojangDir(""); err == nil {
+		t.Fatal("The Android device without the mount path was accepted")
+	}
+}
+
+func TestAndroidExport(t *testing.T) {
+	testAndroidExport(t, false)
+}
+
+func TestAndroidExportRecycled(t *testing.T) {
+	testAndroidExport(t, true)
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// freeTcpPort returns a port that isn't used by any other program.
+func freeTcpPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal("Unable to find a free port:", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// connectWebSocket connects to the WebSocket server like Minecraft does with
+// the "/connect" command and checks the response to the handshake.
+func connectWebSocket(t *testing.T, port int) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatal("Unable to connect to the WebSocket server:", err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	key := base64.StdEncoding.EncodeToString([]byte("regolith-test-key"))
+	_, err = fmt.Fprintf(
+		conn, "GET / HTTP/1.1\r\n"+
+			"Host: localhost\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Key: %s\r\n"+
+			"Sec-WebSocket-Version: 13\r\n\r\n", key)
+	if err != nil {
+		conn.Close()
+		t.Fatal("Unable to send the WebSocket handshake:", err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		t.Fatal("Unable to read the WebSocket handshake:", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		t.Fatalf("Unexpected handshake status: %s", response.Status)
+	}
+	acceptHash := sha1.Sum(
+		[]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	expected := base64.StdEncoding.EncodeToString(acceptHash[:])
+	accept := response.Header.Get("Sec-WebSocket-Accept")
+	if accept != expected {
+		conn.Close()
+		t.Fatalf(
+			"Wrong Sec-WebSocket-Accept header.\nExpected: %s\nActual: %s",
+			expected, accept)
+	}
+	return conn, reader
+}
+
+// writeClientFrame sends a masked WebSocket frame, like the clients do.
+func writeClientFrame(
+	t *testing.T, conn net.Conn, opcode byte, payload []byte,
+) {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal("Unable to send a WebSocket frame:", err)
+	}
+}
+
+// readServerFrame reads an unmasked WebSocket frame sent by the server.
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal("Unable to read a WebSocket frame:", err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		t.Fatalf("Unexpected WebSocket frame header: %x", header)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			t.Fatal("Unable to read a WebSocket frame:", err)
+		}
+		length = int(extended[0])<<8 | int(extended[1])
+	} else if length == 127 {
+		t.Fatal("Unexpectedly large WebSocket frame.")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal("Unable to read a WebSocket frame:", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// TestMinecraftWebSocketServer tests the WebSocket server used for
+// reloading Minecraft after the exports. It connects a fake Minecraft
+// client, checks that the server answers the pings and that it sends the
+// "reload" command request to the client.
+func TestMinecraftWebSocketServer(t *testing.T) {
+	port := freeTcpPort(t)
+	server, err := regolith.StartMinecraftWebSocketServer(port)
+	if err != nil {
+		t.Fatal("Unable to start the WebSocket server:", err)
+	}
+	defer server.Close()
+
+	// THE TEST
+	t.Log("Connecting to the WebSocket server...")
+	conn, reader := connectWebSocket(t, port)
+	defer conn.Close()
+
+	// The pong also confirms that the server registered the connection
+	t.Log("Sending a ping...")
+	writeClientFrame(t, conn, 0x9, []byte("ping"))
+	if opcode, payload := readServerFrame(t, reader); opcode != 0xA ||
+		string(payload) != "ping" {
+		t.Fatalf(
+			"Expected a pong with the payload of the ping.\n"+
+				"Opcode: %x\nPayload: %q", opcode, payload)
+	}
+
+	t.Log("Reloading Minecraft...")
+	server.Reload()
+	opcode, payload := readServerFrame(t, reader)
+	if opcode != 0x1 {
+		t.Fatalf("Expected a text frame, got opcode %x.", opcode)
+	}
+	var request struct {
+		Header struct {
+			RequestId      string `json:"requestId"`
+			MessagePurpose string `json:"messagePurpose"`
+		} `json:"header"`
+		Body struct {
+			CommandLine string `json:"commandLine"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(payload, &request); err != nil {
+		t.Fatalf("Invalid command request: %s\n%s", err, payload)
+	}
+	if request.Header.MessagePurpose != "commandRequest" {
+		t.Fatalf(
+			"Unexpected message purpose: %q", request.Header.MessagePurpose)
+	}
+	if len(request.Header.RequestId) != 36 {
+		t.Fatalf("Invalid request ID: %q", request.Header.RequestId)
+	}
+	if request.Body.CommandLine != "reload" {
+		t.Fatalf(
+			"Expected the \"reload\" command, got %q.",
+			request.Body.CommandLine)
+	}
+
+	t.Log("Closing the connection...")
+	writeClientFrame(t, conn, 0x8, nil)
+	if opcode, _ := readServerFrame(t, reader); opcode != 0x8 {
+		t.Fatalf("Expected a close frame, got opcode %x.", opcode)
+	}
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestMirrors installs a remote filter from a repository that doesn't exist,
+// using the mirrors from the user config. The first mirror doesn't exist
+// either, so the filter is downloaded from the second one. The lock file
+// keeps the URL from the filter definition.
+func TestMirrors(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	primaryUrl := repo.url + "-primary"
+	deadMirrorUrl := repo.url + "-mirror"
+	setUserConfigProperty(t, configPath, "mirrors", map[string][]string{
+		primaryUrl: {deadMirrorUrl, repo.url},
+	})
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", primaryUrl)
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Installing the filter from the mirror...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(
+		"Failed to access \""+deadMirrorUrl+"\", trying \""+repo.url+
+			"\".").Len() == 0 {
+		t.Error("Missing the warning about the mirror that doesn't exist.")
+	}
+	if url := readLockFile(t).Filters["hello"].Url; url != primaryUrl {
+		t.Errorf("Wrong URL in the lock file: %q", url)
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+
+	t.Log("Installing the filter without the mirrors...")
+	isolateUserDirs(t) // Without the mirrors and the shared filter cache
+	if err := os.RemoveAll(".regolith"); err != nil {
+		t.Fatal("Unable to remove the .regolith directory:", err)
+	}
+	if err := regolith.InstallAll(false, false, true); err == nil {
+		t.Fatal("'regolith install-all' succeeded without the mirrors")
+	}
+}
+
+// TestUserProxy checks if the proxy from the user config is used by the
+// downloads, unless the proxy environment variables are already set.
+func TestUserProxy(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	proxy := "http://proxy.example.com:8080"
+	setUserConfigProperty(t, configPath, "proxy", proxy)
+	for _, name := range []string{
+		"http_proxy", "HTTP_PROXY", "https_proxy", "HTTPS_PROXY",
+		"all_proxy", "ALL_PROXY",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HTTPS_PROXY", "http://other.example.com:3128")
+
+	// THE TEST
+	regolith.ApplyUserProxy()
+	expected := map[string]string{
+		"http_proxy":  proxy,
+		"HTTP_PROXY":  proxy,
+		"https_proxy": "",
+		"HTTPS_PROXY": "http://other.example.com:3128",
+		"all_proxy":   proxy,
+		"ALL_PROXY":   proxy,
+	}
+	for name, value := range expected {
+		if actual := os.Getenv(name); actual != value {
+			t.Errorf(
+				"Wrong value of %s.\nExpected: %q\nActual: %q",
+				name, value, actual)
+		}
+	}
+}
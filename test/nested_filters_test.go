@@ -0,0 +1,76 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// nestingFilterFiles returns the files of a remote filter for
+// filterRepo.commit, like luaFilterFiles, but the filter also runs the
+// nested remote filter from the repository with the URL.
+func nestingFilterFiles(name, message, nested, url string) map[string]string {
+	files := luaFilterFiles(name, message)
+	files[name+"/filter.json"] = "{\"filters\": [" +
+		"{\"filter\": \"" + nested + "\", \"url\": \"" + url + "\", " +
+		"\"version\": \"HEAD\"}, " +
+		"{\"runWith\": \"lua\", \"script\": \"" + name + ".lua\"}]}\n"
+	return files
+}
+
+// TestNestedRemoteFilters installs a remote filter that runs a nested
+// remote filter from another repository. The nested filter is installed and
+// locked automatically, unless config.json defines a filter with the same
+// name in another version.
+func TestNestedRemoteFilters(t *testing.T) {
+	isolateUserDirs(t)
+	innerRepo := newFilterRepo(t)
+	innerSha := innerRepo.commit(
+		luaFilterFiles("inner", "inner"), "inner-1.0.0")
+	repo := newFilterRepo(t)
+	repo.commit(nestingFilterFiles("hello", "1", "inner", innerRepo.url), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+
+	// THE TEST
+	t.Log("Installing and running the filters...")
+	installAndRunFilters(t)
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+	expectFileContent(t, filepath.Join("build", "BP", "inner.txt"), "inner")
+	expectLockedFilter(t, "inner", innerSha)
+
+	t.Log("Installing the nested filter in a conflicting version...")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {",
+		"\"filterDefinitions\": {\"inner\": "+
+			"{\"url\": \""+innerRepo.url+"\", \"version\": \"1.0.0\"},")
+	err := regolith.InstallAll(false, false, true)
+	if err == nil || !strings.Contains(
+		err.Error(), "Conflicting definitions of a nested remote filter") {
+		t.Fatal("Expected an error about the conflicting filters, got:", err)
+	}
+}
+
+// TestNestedRemoteFilterCycle installs two remote filters that require each
+// other as the nested remote filters, which must fail.
+func TestNestedRemoteFilterCycle(t *testing.T) {
+	isolateUserDirs(t)
+	innerRepo := newFilterRepo(t)
+	repo := newFilterRepo(t)
+	innerRepo.commit(
+		nestingFilterFiles("inner", "inner", "hello", repo.url), "")
+	repo.commit(nestingFilterFiles("hello", "1", "inner", innerRepo.url), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+
+	// THE TEST
+	err := regolith.InstallAll(false, false, true)
+	if err == nil || !strings.Contains(
+		err.Error(), "Cycle: hello -> inner -> hello") {
+		t.Fatal("Expected an error about the cycle, got:", err)
+	}
+}
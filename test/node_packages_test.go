@@ -0,0 +1,86 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// takeSortedCommandLog returns the sorted lines of the log of the fake
+// commands, which are run by the parallel installations in any order (see
+// takeCommandLog).
+func takeSortedCommandLog(t *testing.T, path string) string {
+	lines := strings.SplitAfter(takeCommandLog(t, path), "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+// TestNodePackageManagers installs the dependencies of the Node.js filters
+// with fake package managers. The package manager is selected by the
+// "packageManager" property of the filter or by the user config, and the
+// dependencies are installed again only when the package.json file or the
+// package manager changes. The fake commands are shell scripts, so the test
+// is skipped on Windows.
+func TestNodePackageManagers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake package managers don't work on Windows")
+	}
+	configPath := isolateUserDirs(t)
+	bin, err := filepath.Abs(filepath.Join(nodePackagesPath, "bin"))
+	if err != nil {
+		t.Fatal("Unable to get the path to the fake package managers:", err)
+	}
+	logPath := filepath.Join(t.TempDir(), "node.log")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("REGOLITH_TEST_NODE_LOG", logPath)
+	_, cleanup := prepareTestProject(t, nodePackagesPath)
+	defer cleanup()
+	installAll := func(expected string) {
+		if err := regolith.InstallAll(false, false, true); err != nil {
+			t.Fatal("'regolith install-all' failed:", err.Error())
+		}
+		if log := takeSortedCommandLog(t, logPath); log != expected {
+			t.Fatalf(
+				"Unexpected installs.\nExpected:\n%s\nActual:\n%s",
+				expected, log)
+		}
+	}
+
+	// THE TEST
+	t.Log("Installing the dependencies...")
+	installAll(
+		"default: npm i --no-fund --no-audit\n" +
+			"yarn: yarn install\n")
+
+	t.Log("Installing the dependencies that didn't change...")
+	installAll("")
+
+	t.Log("Installing the dependencies with pnpm from the user config...")
+	setUserConfigProperty(t, configPath, "nodePackageManager", "pnpm")
+	installAll("default: pnpm install\n")
+
+	t.Log("Installing the changed dependencies...")
+	writeTestFile(
+		t, filepath.Join("filters", "yarn", "package.json"),
+		"{\n\t\"name\": \"yarn\",\n\t\"version\": \"1.0.1\"\n}\n")
+	installAll("yarn: yarn install\n")
+
+	t.Log("Using an unknown package manager...")
+	_, err = regolith.NodeJSFilterDefinitionFromObject(
+		"bun", map[string]interface{}{
+			"script": "./filters/default/main.js", "packageManager": "bun",
+		})
+	if err == nil {
+		t.Fatal("The unknown package manager was accepted.")
+	}
+	setUserConfigProperty(t, configPath, "nodePackageManager", "bun")
+	if err := regolith.InstallAll(false, false, true); err == nil {
+		t.Fatal("'regolith install-all' accepted the unknown package " +
+			"manager from the user config.")
+	}
+}
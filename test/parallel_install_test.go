@@ -0,0 +1,64 @@
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestParallelInstall installs eight remote filters at the same time and
+// checks if all of them are installed and locked. The forced reinstallation
+// with a filter that doesn't exist (the last one to install) fails with an
+// error about that filter, after installing the other filters again.
+func TestParallelInstall(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	files := make(map[string]string)
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("hello%d", i)
+		for path, content := range luaFilterFiles(name, name) {
+			files[path] = content
+		}
+	}
+	repo.commit(files, "")
+	_, cleanup := prepareTestProject(t, parallelInstallPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+
+	// THE TEST
+	t.Log("Installing the filters...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	lockFile := readLockFile(t)
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("hello%d", i)
+		expectFileContent(t, filepath.Join("build", "BP", name+".txt"), name)
+		if _, ok := lockFile.Filters[name]; !ok {
+			t.Errorf("The %q filter is not in the lock file.", name)
+		}
+	}
+
+	t.Log("Installing the filters with a filter that doesn't exist...")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {",
+		"\"filterDefinitions\": {\"missing\": "+
+			"{\"url\": \""+repo.url+"\", \"version\": \"HEAD\"},")
+	err := regolith.InstallAll(true, false, true)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatal("Expected an error about the missing filter, got:", err)
+	}
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("hello%d", i)
+		expectFileContent(
+			t,
+			filepath.Join(".regolith", "cache", "filters", name, name+".lua"),
+			luaFilterFiles(name, name)[name+"/"+name+".lua"])
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testPersistentFilterReuse runs the profile with a persistent filter
+// multiple times in the same context, like the watch mode, and checks if
+// every run is handled by the same process, even though the working
+// directory of the filter is created again by every run.
+func testPersistentFilterReuse(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, persistentFilterPath)
+	defer cleanup()
+	// THE TEST
+	configJson, err := regolith.LoadConfigAsMap()
+	if err != nil {
+		t.Fatal("Unable to load the config:", err.Error())
+	}
+	config, err := regolith.ConfigFromObject(configJson)
+	if err != nil {
+		t.Fatal("Unable to parse the config:", err.Error())
+	}
+	dotRegolithPath, err := regolith.GetDotRegolith(false, true, ".")
+	if err != nil {
+		t.Fatal("Unable to get the .regolith path:", err.Error())
+	}
+	err = regolith.CheckProfileImpl(
+		config.Profiles["dev"], "dev", *config, nil, dotRegolithPath)
+	if err != nil {
+		t.Fatal("Unable to check the profile:", err.Error())
+	}
+	context := regolith.RunContext{
+		AbsoluteLocation: tmpDir,
+		Config:           config,
+		Profile:          "dev",
+		DotRegolithPath:  dotRegolithPath,
+	}
+	defer regolith.StopPersistentProcesses()
+	runProfile := regolith.RunProfile
+	if recycled {
+		runProfile = regolith.RecycledRunProfile
+	}
+	for i := 1; i <= 3; i++ {
+		if err := runProfile(context); err != nil {
+			t.Fatalf("Run %d failed: %s", i, err.Error())
+		}
+		expectFileContent(
+			t, filepath.Join(tmpDir, "build", "BP", "runs.txt"),
+			strconv.Itoa(i))
+	}
+}
+
+func TestPersistentFilterReuse(t *testing.T) {
+	testPersistentFilterReuse(t, false)
+}
+
+func TestPersistentFilterReuseRecycled(t *testing.T) {
+	testPersistentFilterReuse(t, true)
+}
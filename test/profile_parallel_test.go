@@ -0,0 +1,75 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"github.com/otiai10/copy"
+)
+
+// testParallelNeedsRun runs the profiles of a project that uses the "needs"
+// property. The first filter of the "dev" profile needs the output of the
+// filters listed below it, so it fails unless they run before it. The other
+// profiles use invalid "needs" and must fail.
+func testParallelNeedsRun(t *testing.T, recycled bool) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("Unable to get current working directory")
+	}
+	defer os.Chdir(wd)
+	// Create a temporary directory
+	tmpDir, err := ioutil.TempDir("", "regolith-test")
+	if err != nil {
+		t.Fatal("Unable to create temporary directory:", err)
+	}
+	t.Log("Created temporary directory:", tmpDir)
+	// Before deleting "workingDir" the test must stop using it
+	defer os.RemoveAll(tmpDir)
+	defer os.Chdir(wd)
+	// Copy the test project to the working directory
+	project := filepath.Join(parallelNeedsPath, "project")
+	err = copy.Copy(
+		project,
+		tmpDir,
+		copy.Options{PreserveTimes: false, Sync: false},
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to copy test files from %q into the working directory %q",
+			project, tmpDir,
+		)
+	}
+	// THE TEST
+	os.Chdir(tmpDir)
+	if err := regolith.Unlock(true); err != nil {
+		t.Fatal("'regolith unlock' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	combined, err := os.ReadFile(filepath.Join("build", "BP", "combined.txt"))
+	if err != nil {
+		t.Fatal("Unable to read the exported file:", err)
+	}
+	if string(combined) != "ab" {
+		t.Errorf(
+			"Unexpected content of the exported file: %q, expected %q",
+			combined, "ab")
+	}
+	for _, profile := range []string{"unknown_need", "circular_needs"} {
+		if err := regolith.Run(profile, nil, recycled, true); err == nil {
+			t.Errorf("Expected an error when running %q profile", profile)
+		}
+	}
+}
+
+func TestParallelNeedsRun(t *testing.T) {
+	testParallelNeedsRun(t, false)
+}
+
+func TestParallelNeedsRunRecycled(t *testing.T) {
+	testParallelNeedsRun(t, true)
+}
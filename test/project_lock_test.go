@@ -0,0 +1,47 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestProjectLock locks the project with the daemon and installs the filters
+// of the project. The installation must fail while the daemon holds the
+// lock, with the ID of the process of the daemon in the error message, and
+// succeed after the daemon stops.
+func TestProjectLock(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	lockPath := filepath.Join(".regolith", "project.lock")
+
+	// THE TEST
+	t.Log("Installing the filters while the project is locked...")
+	_, stop := startTestDaemon(t, 0)
+	expectFileContent(t, lockPath, fmt.Sprint(os.Getpid()))
+	err := regolith.InstallAll(false, false, true)
+	if err == nil || !strings.Contains(
+		err.Error(), fmt.Sprintf("(process ID: %d)", os.Getpid())) {
+		stop()
+		t.Fatal("Expected an error about the locked project, got:", err)
+	}
+	if err := regolith.Serve(0, true); err == nil {
+		stop()
+		t.Fatal("Started a second daemon of the same project.")
+	}
+
+	t.Log("Installing the filters after unlocking the project...")
+	stop()
+	expectFileContent(t, lockPath, "")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// expectFilterVenv checks if the filter ran in the venv, based on the path
+// written by the filter.
+func expectFilterVenv(t *testing.T, filter, venv string) {
+	prefix, err := os.ReadFile(filepath.Join("build", "BP", filter+".txt"))
+	if err != nil {
+		t.Fatalf("Unable to read the output of the %q filter: %s", filter, err)
+	}
+	prefixInfo, err1 := os.Stat(string(prefix))
+	venvInfo, err2 := os.Stat(venv)
+	if err := firstErr(err1, err2); err != nil {
+		t.Fatalf("Unable to compare the venv of the %q filter: %s", filter, err)
+	}
+	if !os.SameFile(prefixInfo, venvInfo) {
+		t.Fatalf(
+			"The %q filter used a wrong venv.\nExpected: %s\nActual: %s",
+			filter, venv, prefix)
+	}
+}
+
+// TestPythonFilterVenvs checks if every Python filter has its own venv,
+// unless it uses the "venvSlot" property, which makes it share the venv of
+// the slot with the other filters.
+func TestPythonFilterVenvs(t *testing.T) {
+	// The requirements are empty, pip doesn't need the network
+	t.Setenv("PIP_NO_INDEX", "1")
+	_, cleanup := prepareTestProject(t, pythonVenvsPath)
+	defer cleanup()
+	cache := filepath.Join(".regolith", "cache")
+
+	// THE TEST
+	t.Log("Installing the dependencies of the filters...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFilterVenv(t, "a", filepath.Join(cache, "filter-venvs", "a"))
+	expectFilterVenv(t, "b", filepath.Join(cache, "filter-venvs", "b"))
+	expectFilterVenv(t, "c", filepath.Join(cache, "venvs", "1"))
+	expectFilterVenv(t, "d", filepath.Join(cache, "venvs", "1"))
+
+	t.Log("Parsing the invalid venv slots...")
+	for _, venvSlot := range []interface{}{1.5, "1", true} {
+		_, err := regolith.PythonFilterDefinitionFromObject(
+			"a", map[string]interface{}{
+				"script": "./filters/a/venv.py", "venvSlot": venvSlot,
+			})
+		if err == nil {
+			t.Errorf("The venv slot %v was accepted.", venvSlot)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestPythonVersions installs and runs the Python filters with the
+// "pythonVersion" property, which select the newest matching interpreter
+// from the PATH. The fake interpreters are shell scripts, so the test is
+// skipped on Windows.
+func TestPythonVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake Python interpreters don't work on Windows")
+	}
+	bin, err := filepath.Abs(filepath.Join(pythonVersionsPath, "bin"))
+	if err != nil {
+		t.Fatal("Unable to get the path to the fake interpreters:", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	_, cleanup := prepareTestProject(t, pythonVersionsPath)
+	defer cleanup()
+
+	// THE TEST
+	t.Log("Installing and running the filters...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "newest.txt"), "3.99")
+	expectFileContent(t, filepath.Join("build", "BP", "exact.txt"), "3.98")
+	interpreters, err := os.ReadFile(
+		filepath.Join(".regolith", "cache", "python-interpreters.json"))
+	if err != nil {
+		t.Fatal("Unable to read the selected interpreters:", err)
+	}
+	if !strings.Contains(string(interpreters), "python3.98") ||
+		!strings.Contains(string(interpreters), "python3.99") {
+		t.Fatalf("Unexpected selected interpreters:\n%s", interpreters)
+	}
+
+	t.Log("Installing a filter without a matching interpreter...")
+	replaceInTestFile(t, "config.json", "==3.98", "==3.97")
+	err = regolith.InstallAll(false, false, true)
+	if err == nil || !strings.Contains(
+		err.Error(), "No Python interpreter matches") {
+		t.Fatal("Expected an error about the missing interpreter, got:", err)
+	}
+
+	t.Log("Parsing the invalid version ranges...")
+	for _, pythonVersion := range []interface{}{
+		"3.8", ">=3.x", ">=3.8;<3.12", "^three", 3.8,
+	} {
+		_, err := regolith.PythonFilterDefinitionFromObject(
+			"exact", map[string]interface{}{
+				"script":        "./filters/exact/version.py",
+				"pythonVersion": pythonVersion,
+			})
+		if err == nil {
+			t.Errorf("The version range %v was accepted.", pythonVersion)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// writeRegistries writes the indices of the filter registries to the
+// temporary directory and lists them in the user config. It returns the
+// paths to the indices.
+func writeRegistries(
+	t *testing.T, configPath string, indices ...string,
+) []string {
+	dir := t.TempDir()
+	var paths []string
+	for i, index := range indices {
+		path := filepath.Join(dir, fmt.Sprintf("registry%d.json", i))
+		writeTestFile(t, path, index)
+		paths = append(paths, path)
+	}
+	registries, _ := json.Marshal(paths)
+	writeTestFile(
+		t, configPath, "{\"registries\": "+string(registries)+"}\n")
+	return paths
+}
+
+// expectLogs checks if the messages were logged and clears the logs.
+func expectLogs(
+	t *testing.T, logs *observer.ObservedLogs, messages ...string,
+) {
+	for _, message := range messages {
+		if logs.FilterMessage(message).Len() == 0 {
+			t.Errorf("Missing log message: %q", message)
+		}
+	}
+	logs.TakeAll()
+}
+
+// TestRegistrySearch searches the filters in two filter registries with
+// "regolith search" and installs a filter by its name from the registries.
+// The filters from the first registry take precedence over the filters with
+// the same names from the second one.
+func TestRegistrySearch(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "hello-1.0.0")
+	registries := writeRegistries(
+		t, configPath,
+		"{\"formatVersion\": \"1.0.0\", \"filters\": {"+
+			"\"hello\": {\"url\": \""+repo.url+"\", "+
+			"\"description\": \"Writes a greeting\", "+
+			"\"versions\": [\"1.0.0\"]}}}\n",
+		"{\"formatVersion\": \"1.0.0\", \"filters\": {"+
+			"\"hello\": {\"url\": \"example.com/hidden\"}, "+
+			"\"greeter\": {\"url\": \"example.com/greeter\", "+
+			"\"description\": \"Says hello\"}, "+
+			"\"other\": {\"url\": \"example.com/other\"}}}\n")
+	_, cleanup := prepareTestProject(t, minimalProjectPath)
+	defer cleanup()
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Searching the filters...")
+	if err := regolith.Search("HELLO", true); err != nil {
+		t.Fatal("'regolith search' failed:", err.Error())
+	}
+	expectLogs(
+		t, logs,
+		"Found 2 filters:",
+		"greeter - example.com/greeter",
+		"\tSays hello",
+		"hello - "+repo.url,
+		"\tWrites a greeting",
+		"\tVersions: 1.0.0")
+
+	t.Log("Searching the filters that don't exist...")
+	if err := regolith.Search("missing", true); err != nil {
+		t.Fatal("'regolith search' failed:", err.Error())
+	}
+	expectLogs(t, logs, "No filters found for \"missing\".")
+
+	t.Log("Searching the previously downloaded registry...")
+	if err := os.Remove(registries[1]); err != nil {
+		t.Fatal("Unable to remove the registry:", err)
+	}
+	if err := regolith.Search("greet", true); err != nil {
+		t.Fatal("'regolith search' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet(
+		"Failed to download the filter registries.").Len() == 0 {
+		t.Error("Missing the warning about the failed download.")
+	}
+	expectLogs(t, logs, "Found 1 filters:", "greeter - example.com/greeter")
+
+	t.Log("Installing the filter by its name...")
+	if err := regolith.Install([]string{"hello"}, false, true); err != nil {
+		t.Fatal("'regolith install' failed:", err.Error())
+	}
+	config, err := regolith.LoadConfigAsMap()
+	if err != nil {
+		t.Fatal("Unable to load the config:", err.Error())
+	}
+	definitions := config["regolith"].(map[string]interface{})
+	definitions = definitions["filterDefinitions"].(map[string]interface{})
+	hello, _ := definitions["hello"].(map[string]interface{})
+	if hello["url"] != repo.url || hello["version"] != "1.0.0" {
+		t.Fatalf("Unexpected definition of the filter: %v", hello)
+	}
+}
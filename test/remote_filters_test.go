@@ -55,7 +55,7 @@ func TestInstallAllUnlockAndRun(t *testing.T) {
 	os.Chdir(workingDir)
 	// THE TEST
 	// Run InstallDependencies
-	err = regolith.InstallAll(false, true)
+	err = regolith.InstallAll(false, false, true)
 	if err != nil {
 		t.Fatal("'regolith install-all' failed:", err)
 	}
@@ -63,7 +63,7 @@ func TestInstallAllUnlockAndRun(t *testing.T) {
 	if err != nil {
 		t.Fatal("'regolith unlock' failed:", err)
 	}
-	err = regolith.Run("dev", false, true)
+	err = regolith.Run("dev", nil, false, true)
 	if err != nil {
 		t.Fatal("'regolith run' failed:", err)
 	}
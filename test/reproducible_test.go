@@ -0,0 +1,100 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testReproducible runs a profile with the "--reproducible" flag. The
+// exported files and directories get the time from the SOURCE_DATE_EPOCH
+// environment variable, or 1980-01-01 if it isn't set, and the filters get
+// that time in the same variable. The runs without the flag export the files
+// with the current time.
+func testReproducible(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	writeTestFile(
+		t, filepath.Join("filters", "epoch.py"),
+		"import os\n"+
+			"with open(os.path.join(\"BP\", \"epoch.txt\"), \"w\") as f:\n"+
+			"    f.write(str(os.environ.get(\"SOURCE_DATE_EPOCH\")))\n")
+	replaceInTestFile(
+		t, "config.json", "\"filters\": []",
+		"\"filters\": [{\"filter\": \"epoch\"}]")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {}",
+		"\"filterDefinitions\": {\"epoch\": {\"runWith\": \"python\", "+
+			"\"script\": \"./filters/epoch.py\"}}")
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+	regolith.Reproducible = true
+	defer func() { regolith.Reproducible = false }()
+	expectTimes := func(expected time.Time, paths ...string) {
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Unable to get the info of %q: %s", path, err)
+			}
+			if !info.ModTime().Equal(expected) {
+				t.Errorf(
+					"Wrong modification time of %q.\nExpected: %s\n"+
+						"Actual: %s", path, expected, info.ModTime())
+			}
+		}
+	}
+	exported := []string{
+		filepath.Join("build", "BP"),
+		filepath.Join("build", "BP", "a.json"),
+		filepath.Join("build", "BP", "epoch.txt"),
+		filepath.Join("build", "RP", "c.json"),
+	}
+
+	// THE TEST
+	t.Log("Exporting the packs with the default time...")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectTimes(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), exported...)
+	expectFileContent(
+		t, filepath.Join("build", "BP", "epoch.txt"), "315532800")
+
+	t.Log("Exporting the packs with the time from SOURCE_DATE_EPOCH...")
+	os.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	writeTestFile(t, filepath.Join("packs", "BP", "new.json"), "{}")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectTimes(
+		time.Unix(1700000000, 0),
+		append(exported, filepath.Join("build", "BP", "new.json"))...)
+	expectFileContent(
+		t, filepath.Join("build", "BP", "epoch.txt"), "1700000000")
+
+	t.Log("Exporting the packs without the flag...")
+	regolith.Reproducible = false
+	start := time.Now().Add(-time.Minute)
+	writeTestFile(t, filepath.Join("packs", "BP", "new.json"), "{\"a\": 1}")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	info, err := os.Stat(filepath.Join("build", "BP", "new.json"))
+	if err != nil {
+		t.Fatal("The file wasn't exported:", err)
+	}
+	if info.ModTime().Before(start) {
+		t.Errorf(
+			"The file was exported with the old time: %s", info.ModTime())
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	testReproducible(t, false)
+}
+
+func TestReproducibleRecycled(t *testing.T) {
+	testReproducible(t, true)
+}
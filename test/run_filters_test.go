@@ -0,0 +1,58 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testRunFilters runs only some of the filters of a profile with the
+// "--filter" flag, with and without the "--no-export" flag. The chosen
+// filters run in the order of the profile, even if the profile runs its
+// filters in parallel, and the "needs" of the other filters are ignored.
+func testRunFilters(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+	tmpBp := filepath.Join(".regolith", "tmp", "BP")
+
+	// THE TEST
+	t.Log("Running the filters without the export...")
+	err := regolith.RunFilters(
+		"dev", []string{"generate_a", " generate_b"}, nil, recycled, true,
+		regolith.RunPaths{}, true)
+	if err != nil {
+		t.Fatal("'regolith run --filter --no-export' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join(tmpBp, "a.txt"), "a")
+	expectFileContent(t, filepath.Join(tmpBp, "b.txt"), "b")
+	expectNotExist(t, filepath.Join(tmpBp, "combined.txt"))
+	expectNotExist(t, "build")
+
+	t.Log("Running the filters with the export...")
+	err = regolith.RunFilters(
+		"dev", []string{"generate_a"}, nil, recycled, false,
+		regolith.RunPaths{}, true)
+	if err != nil {
+		t.Fatal("'regolith run --filter' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "a.txt"), "a")
+	expectNotExist(t, filepath.Join("build", "BP", "b.txt"))
+
+	t.Log("Running the invalid filters...")
+	for _, filters := range [][]string{{"combine"}, {"generate_c"}} {
+		err = regolith.RunFilters(
+			"dev", filters, nil, recycled, true, regolith.RunPaths{}, true)
+		if err == nil {
+			t.Errorf("'regolith run --filter %s' succeeded", filters[0])
+		}
+	}
+}
+
+func TestRunFilters(t *testing.T) {
+	testRunFilters(t, false)
+}
+
+func TestRunFiltersRecycled(t *testing.T) {
+	testRunFilters(t, true)
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testRunPaths runs a profile on the packs from outside of the project and
+// exports them to a directory from outside of the project, with the "--rp",
+// "--bp" and "--out" flags. The paths from the config aren't used, and the
+// next run without the flags uses them again.
+func testRunPaths(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	external := t.TempDir()
+	paths := regolith.RunPaths{
+		Rp:  filepath.Join(external, "rp"),
+		Bp:  filepath.Join(external, "bp"),
+		Out: filepath.Join(external, "out"),
+	}
+	writeTestFile(t, filepath.Join(paths.Rp, "x.json"), "{\"x\": 1}")
+	writeTestFile(t, filepath.Join(paths.Bp, "y.json"), "{\"y\": 1}")
+	writeTestFile(t, filepath.Join("packs", "BP", "a.json"), "{\"a\": 1}")
+
+	// THE TEST
+	t.Log("Running the profile with the external packs...")
+	err := regolith.RunFilters(
+		"log", nil, nil, recycled, false, paths, true)
+	if err != nil {
+		t.Fatal("'regolith run --rp --bp --out' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(paths.Out, "RP", "x.json"), "{\"x\": 1}")
+	expectFileContent(
+		t, filepath.Join(paths.Out, "BP", "y.json"), "{\"y\": 1}")
+	expectNotExist(t, filepath.Join(paths.Out, "BP", "a.json"))
+	expectNotExist(t, "build")
+
+	t.Log("Running the profile without the flags...")
+	if err := regolith.Run("log", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "a.json"), "{\"a\": 1}")
+	expectNotExist(t, filepath.Join("build", "BP", "y.json"))
+
+	t.Log("Running the profile with the invalid paths...")
+	invalid := []regolith.RunPaths{
+		{Rp: filepath.Join(external, "missing")},
+		{Bp: filepath.Join(paths.Bp, "y.json")},
+	}
+	for _, p := range invalid {
+		err := regolith.RunFilters("log", nil, nil, recycled, false, p, true)
+		if err == nil {
+			t.Errorf("'regolith run' accepted the invalid paths: %v", p)
+		}
+	}
+}
+
+func TestRunPaths(t *testing.T) {
+	testRunPaths(t, false)
+}
+
+func TestRunPathsRecycled(t *testing.T) {
+	testRunPaths(t, true)
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestRunSummary checks the summary printed after the run of a profile,
+// with the numbers of the executed, the skipped and the failed filters, and
+// of the exported files.
+func TestRunSummary(t *testing.T) {
+	testRunSummary(t, false)
+}
+
+// TestRunSummaryRecycled is the same as TestRunSummary but uses the recycled
+// version of the function.
+func TestRunSummaryRecycled(t *testing.T) {
+	testRunSummary(t, true)
+}
+
+func testRunSummary(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, parallelNeedsPath)
+	defer cleanup()
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Running the profile...")
+	if err := regolith.Run("files", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	exported := 0
+	err := filepath.WalkDir(
+		"build", func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				exported++
+			}
+			return err
+		})
+	if err != nil {
+		t.Fatal("Unable to count the exported files:", err)
+	}
+	summary := "Summary: 3 filters executed, 1 skipped, 0 failed, " +
+		"0 warnings, " + strconv.Itoa(exported) + " files exported in "
+	if logs.FilterMessageSnippet(summary).Len() != 1 {
+		t.Errorf("Missing the summary of the run: %q", summary)
+	}
+
+	t.Log("Running the profile with a failing filter...")
+	writeTestFile(
+		t, filepath.Join("filters", "combine.lua"), "error(\"Failed\")\n")
+	logs.TakeAll()
+	if err := regolith.Run("files", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded with a failing filter.")
+	}
+	if logs.FilterMessageSnippet(" 1 failed, ").Len() != 1 ||
+		logs.FilterMessageSnippet(" 0 files exported in ").Len() != 1 {
+		t.Error("Missing the summary of the failed run.")
+	}
+}
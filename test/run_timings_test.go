@@ -0,0 +1,96 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testRunTimings runs a project with the "--timings-output" flag and checks
+// the phases of the run saved in the JSON format and in the folded stacks
+// format.
+func testRunTimings(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	defer func() {
+		regolith.Timings = false
+		regolith.TimingsOutput = ""
+	}()
+	logs, restore := captureLogs()
+	defer restore()
+	expectedStacks := []string{"dev;export", "dev;filter stamp", "dev;setup"}
+
+	// THE TEST
+	t.Log("Saving the timings in the JSON format...")
+	regolith.Timings = true
+	regolith.TimingsOutput = "timings.json"
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	if logs.FilterMessage("Timings:").Len() == 0 {
+		t.Error("The timings weren't printed.")
+	}
+	data, err := os.ReadFile("timings.json")
+	if err != nil {
+		t.Fatal("Unable to read the timings:", err)
+	}
+	var report struct {
+		Seconds float64 `json:"seconds"`
+		Phases  []struct {
+			Stack   []string `json:"stack"`
+			Seconds float64  `json:"seconds"`
+		} `json:"phases"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal("Unable to parse the timings:", err)
+	}
+	var stacks []string
+	for _, phase := range report.Phases {
+		if phase.Seconds < 0 || phase.Seconds > report.Seconds {
+			t.Errorf("Invalid duration of %v: %f", phase.Stack, phase.Seconds)
+		}
+		stacks = append(stacks, strings.Join(phase.Stack, ";"))
+	}
+	sort.Strings(stacks)
+	if strings.Join(stacks, "\n") != strings.Join(expectedStacks, "\n") {
+		t.Errorf("Wrong phases of the run: %v", stacks)
+	}
+
+	t.Log("Saving the timings in the folded stacks format...")
+	regolith.TimingsOutput = filepath.Join("build", "timings.txt")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	data, err = os.ReadFile(regolith.TimingsOutput)
+	if err != nil {
+		t.Fatal("Unable to read the timings:", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	line := regexp.MustCompile(`^(.+) \d+$`)
+	stacks = nil
+	for _, l := range lines {
+		match := line.FindStringSubmatch(l)
+		if match == nil {
+			t.Fatalf("Invalid line of the folded stacks: %q", l)
+		}
+		stacks = append(stacks, match[1])
+	}
+	sort.Strings(stacks)
+	if strings.Join(stacks, "\n") != strings.Join(expectedStacks, "\n") {
+		t.Errorf("Wrong phases of the run: %v", stacks)
+	}
+}
+
+func TestRunTimings(t *testing.T) {
+	testRunTimings(t, false)
+}
+
+func TestRunTimingsRecycled(t *testing.T) {
+	testRunTimings(t, true)
+}
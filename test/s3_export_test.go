@@ -0,0 +1,87 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testS3Export runs the profiles of a project with the "s3" export target,
+// which upload the archives to a fake S3 server, and checks the uploaded
+// objects. The profiles with the invalid properties must fail.
+func testS3Export(t *testing.T, recycled bool) {
+	var mutex sync.Mutex
+	uploads := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mutex.Lock()
+			uploads[r.Method+" "+r.URL.Path] = string(body)
+			mutex.Unlock()
+			w.Header().Set("ETag", `"test"`)
+		}))
+	defer server.Close()
+	t.Setenv("REGOLITH_TEST_S3_ENDPOINT", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv(
+		"AWS_SHARED_CREDENTIALS_FILE",
+		filepath.Join(t.TempDir(), "credentials"))
+	_, cleanup := prepareTestProject(t, s3ExportPath)
+	defer cleanup()
+	const name = "s3_export_test_project-1.0.0"
+	// THE TEST
+	for profile, expected := range map[string][]string{
+		"mcaddon": {"PUT /test-bucket/builds/" + name + ".mcaddon"},
+		"mcpack": {
+			"PUT /test-bucket/" + name + "_bp.mcpack",
+			"PUT /test-bucket/" + name + "_rp.mcpack",
+		},
+	} {
+		mutex.Lock()
+		uploads = make(map[string]string)
+		mutex.Unlock()
+		if err := regolith.Run(profile, nil, recycled, true); err != nil {
+			t.Fatalf("'regolith run' of %q failed: %s", profile, err.Error())
+		}
+		mutex.Lock()
+		var actual []string
+		for request, body := range uploads {
+			actual = append(actual, request)
+			if !strings.HasPrefix(body, "PK") {
+				t.Errorf("The upload of %q isn't a zip archive", request)
+			}
+		}
+		mutex.Unlock()
+		sort.Strings(actual)
+		if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+			t.Errorf(
+				"Unexpected uploads of %q:\n%s\nExpected:\n%s", profile,
+				strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+		}
+	}
+	for _, profile := range []string{"invalid_archive", "missing_bucket"} {
+		if err := regolith.Run(profile, nil, recycled, true); err == nil {
+			t.Errorf("'regolith run' of %q succeeded", profile)
+		}
+	}
+}
+
+func TestS3Export(t *testing.T) {
+	testS3Export(t, false)
+}
+
+func TestS3ExportRecycled(t *testing.T) {
+	testS3Export(t, true)
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// sarifReport is the part of the SARIF report checked by the tests.
+type sarifReport struct {
+	Version string `json:"version"`
+	Runs    []struct {
+		Tool struct {
+			Driver struct {
+				Rules []struct {
+					Id string `json:"id"`
+				} `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleId  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						Uri string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine   int `json:"startLine"`
+						StartColumn int `json:"startColumn"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// readSarifReport reads the SARIF report from the path.
+func readSarifReport(t *testing.T, path string) sarifReport {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unable to read the SARIF report:", err)
+	}
+	var report sarifReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal("Invalid SARIF report:", err)
+	}
+	if report.Version != "2.1.0" || len(report.Runs) != 1 {
+		t.Fatalf("Wrong SARIF report:\n%s", data)
+	}
+	return report
+}
+
+// testSarifReport runs the profiles of a project with the "--sarif" flag.
+// The diagnostics of the filters are saved as the results of the SARIF
+// report, with the filters as the rules, and the report of a run without the
+// diagnostics has no results.
+func testSarifReport(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterProtocolPath)
+	defer cleanup()
+	// The paths are relative to the repository on GitHub Actions
+	t.Setenv("GITHUB_WORKSPACE", "")
+	regolith.SarifOutput = filepath.Join("reports", "results.sarif")
+	defer func() { regolith.SarifOutput = "" }()
+	_, restore := captureLogs()
+	defer restore()
+	expectResult := func(level string) {
+		report := readSarifReport(t, regolith.SarifOutput)
+		run := report.Runs[0]
+		if len(run.Tool.Driver.Rules) != 1 ||
+			run.Tool.Driver.Rules[0].Id != "protocol" {
+			t.Errorf("Wrong rules: %+v", run.Tool.Driver.Rules)
+		}
+		if len(run.Results) != 1 {
+			t.Fatalf("Expected one result, got: %+v", run.Results)
+		}
+		result := run.Results[0]
+		if result.RuleId != "protocol" || result.Level != level ||
+			result.Message.Text != "Test diagnostic" ||
+			len(result.Locations) != 1 {
+			t.Fatalf("Wrong result: %+v", result)
+		}
+		location := result.Locations[0].PhysicalLocation
+		if location.ArtifactLocation.Uri != "BP/done.txt" ||
+			location.Region.StartLine != 1 ||
+			location.Region.StartColumn != 1 {
+			t.Errorf("Wrong location of the result: %+v", location)
+		}
+	}
+
+	// THE TEST
+	t.Log("Running the failing profile...")
+	if err := regolith.Run("fail", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter reported an error")
+	}
+	expectResult("error")
+
+	t.Log("Running the successful profile...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectResult("warning")
+
+	t.Log("Running the filter without the diagnostics...")
+	writeTestFile(
+		t, filepath.Join("filters", "protocol.py"),
+		"import json\n"+
+			"import sys\n"+
+			"sys.stdin.readline()\n"+
+			"print(json.dumps({\"type\": \"done\"}), flush=True)\n")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	report := readSarifReport(t, regolith.SarifOutput)
+	if len(report.Runs[0].Results) != 0 {
+		t.Errorf("The fixed problems are still reported: %+v", report.Runs[0])
+	}
+}
+
+func TestSarifReport(t *testing.T) {
+	testSarifReport(t, false)
+}
+
+func TestSarifReportRecycled(t *testing.T) {
+	testSarifReport(t, true)
+}
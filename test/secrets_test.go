@@ -0,0 +1,79 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestSecrets saves a secret in the credential store with "regolith secret
+// set" and runs a filter that uses it in its settings. The credential store
+// is a fake "secret-tool" command, which is used on Linux.
+func TestSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("The fake credential store only works on Linux")
+	}
+	bin, err := filepath.Abs(filepath.Join(secretsPath, "bin"))
+	if err != nil {
+		t.Fatal("Unable to get the path to the fake secret-tool:", err)
+	}
+	store := t.TempDir()
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("REGOLITH_TEST_SECRETS", store)
+	t.Setenv("REGOLITH_TEST_VARIABLE", "env value")
+	_, cleanup := prepareTestProject(t, filterVariablesPath)
+	defer cleanup()
+	secret := filepath.Join(store, "TEST_VARIABLE_SECRET")
+
+	// THE TEST
+	t.Log("Running the filter without the secret...")
+	err = regolith.Run("dev", []string{"VERSION=1.0"}, false, true)
+	if err == nil || !strings.Contains(err.Error(), "regolith secret set") {
+		t.Fatal("Expected an error about the missing secret, got:", err)
+	}
+
+	t.Log("Saving the secrets...")
+	if err := regolith.SecretSet("1_INVALID", "value", true); err == nil {
+		t.Fatal("'regolith secret set' accepted an invalid name")
+	}
+	err = regolith.SecretSet("TEST_VARIABLE_SECRET", "stored value", true)
+	if err != nil {
+		t.Fatal("'regolith secret set' failed:", err.Error())
+	}
+	expectFileContent(t, secret, "stored value")
+
+	t.Log("Running the filter with the secret...")
+	err = regolith.Run("dev", []string{"VERSION=1.0"}, false, true)
+	if err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	data, err := os.ReadFile(filepath.Join("build", "BP", "variables.json"))
+	if err != nil {
+		t.Fatal("Unable to read the output of the filter:", err)
+	}
+	var output struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal("Unable to parse the output of the filter:", err)
+	}
+	if output.Settings["secret"] != "stored value" {
+		t.Errorf("Unexpected value of the secret: %v",
+			output.Settings["secret"])
+	}
+
+	t.Log("Deleting the secret...")
+	if err := regolith.SecretDelete("TEST_VARIABLE_SECRET", true); err != nil {
+		t.Fatal("'regolith secret delete' failed:", err.Error())
+	}
+	expectNotExist(t, secret)
+	err = regolith.SecretGet("TEST_MISSING_SECRET", true)
+	if err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Fatal("Expected an error about the missing secret, got:", err)
+	}
+}
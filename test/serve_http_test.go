@@ -0,0 +1,157 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freePort returns a port of localhost that isn't used.
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to find a free port:", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// request sends the HTTP request to the HTTP API of the daemon, with the
+// token of the daemon. The body is sent as JSON if it isn't nil.
+func (d testDaemon) request(
+	t *testing.T, method, path string, body interface{},
+) *http.Response {
+	reader := strings.NewReader("")
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = strings.NewReader(string(data))
+	}
+	request, err := http.NewRequest(
+		method, "http://"+d.HttpAddress+path, reader)
+	if err != nil {
+		t.Fatal("Unable to create the request:", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+d.Token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("The request %s %s failed: %s", method, path, err)
+	}
+	return response
+}
+
+// decodeResponse decodes the JSON body of the response with the status code
+// 200.
+func decodeResponse(t *testing.T, response *http.Response, v interface{}) {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status of the response: %s", response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		t.Fatal("Invalid body of the response:", err)
+	}
+}
+
+// TestServeHttp runs the profiles of a project with the HTTP API of the
+// daemon. The API requires the token of the daemon, reports the results of
+// the runs, and streams the status of the daemon and its log messages as the
+// server-sent events.
+func TestServeHttp(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	writeTestFile(t, filepath.Join("packs", "BP", "a.json"), "{\"a\": 1}")
+	// The HTTP API adds its output to the logger
+	_, restore := captureLogs()
+	defer restore()
+	daemon, stop := startTestDaemon(t, freePort(t))
+	defer stop()
+	type runResult struct {
+		Profile string `json:"profile"`
+		Error   string `json:"error"`
+	}
+	var status struct {
+		Pid     int        `json:"pid"`
+		Running bool       `json:"running"`
+		LastRun *runResult `json:"lastRun"`
+	}
+
+	// THE TEST
+	t.Log("Sending the invalid requests...")
+	invalid := testDaemon{HttpAddress: daemon.HttpAddress, Token: "invalid"}
+	response := invalid.request(t, http.MethodGet, "/status", nil)
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("The API accepted an invalid token: %s", response.Status)
+	}
+	response = daemon.request(t, http.MethodGet, "/run", nil)
+	response.Body.Close()
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("The API accepted the GET /run request: %s", response.Status)
+	}
+	decodeResponse(
+		t, daemon.request(t, http.MethodGet, "/status", nil), &status)
+	if status.Pid == 0 || status.Running || status.LastRun != nil {
+		t.Errorf("Wrong status of the idle daemon: %+v", status)
+	}
+
+	t.Log("Streaming the events...")
+	events := daemon.request(t, http.MethodGet, "/events", nil)
+	defer events.Body.Close()
+	eventLines := make(chan string, 1024)
+	go func() {
+		scanner := bufio.NewScanner(events.Body)
+		for scanner.Scan() {
+			eventLines <- scanner.Text()
+		}
+		close(eventLines)
+	}()
+	awaitEvent := func(snippet string) {
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case line, ok := <-eventLines:
+				if !ok {
+					t.Fatalf("The events ended without %q.", snippet)
+				}
+				if strings.Contains(line, snippet) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Missing the event with %q.", snippet)
+			}
+		}
+	}
+	awaitEvent("event: status")
+
+	t.Log("Running the profiles...")
+	var result runResult
+	decodeResponse(
+		t, daemon.request(
+			t, http.MethodPost, "/run", map[string]string{"profile": "log"}),
+		&result)
+	if result.Profile != "log" || result.Error != "" {
+		t.Errorf("Wrong result of the run: %+v", result)
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "a.json"), "{\"a\": 1}")
+	awaitEvent("\"running\":true")
+	awaitEvent("Successfully ran the \\\"log\\\" profile.")
+	decodeResponse(
+		t, daemon.request(
+			t, http.MethodPost, "/run",
+			map[string]string{"profile": "missing"}),
+		&result)
+	if result.Error == "" {
+		t.Error("The run of a profile that doesn't exist succeeded.")
+	}
+	decodeResponse(
+		t, daemon.request(t, http.MethodGet, "/status", nil), &status)
+	if status.LastRun == nil || status.LastRun.Profile != "missing" ||
+		status.LastRun.Error == "" {
+		t.Errorf("Wrong status after the failed run: %+v", status)
+	}
+}
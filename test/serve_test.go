@@ -0,0 +1,55 @@
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestServe starts the daemon of a project and runs the profile with the
+// daemon. The daemon holds the lock of the project, so the run succeeds only
+// if it's handled by the daemon. The daemon rejects the requests with an
+// invalid token, and the requests sent while it watches the project.
+func TestServe(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, filterCachePath)
+	defer cleanup()
+	daemon, stop := startTestDaemon(t, 0)
+	defer stop()
+	stamp := filepath.Join("build", "BP", "stamp.txt")
+
+	// THE TEST
+	t.Log("Running the profile with the daemon...")
+	for i := 0; i < 2; i++ {
+		if err := regolith.Run("dev", nil, false, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+	}
+	expectFileContent(t, stamp, "first")
+	expectFileContent(t, "runs.txt", "1")
+
+	t.Log("Sending a request with an invalid token...")
+	invalid := testDaemon{Address: daemon.Address, Token: "invalid"}
+	conn, reader := invalid.send(
+		t, map[string]interface{}{"command": "run", "profile": "dev"})
+	if line, err := reader.ReadBytes('\n'); err == nil {
+		t.Errorf("The daemon accepted an invalid token: %s", line)
+	}
+	conn.Close()
+
+	t.Log("Sending a request while the daemon watches the project...")
+	watchConn, watchReader := daemon.send(
+		t, map[string]interface{}{"command": "watch", "profile": "dev"})
+	defer watchConn.Close()
+	awaitDaemonMessage(
+		t, watchConn, watchReader, "Successfully ran the \"dev\" profile.")
+	conn, reader = daemon.send(
+		t, map[string]interface{}{"command": "run", "profile": "dev"})
+	err := awaitDaemonMessage(t, conn, reader, "")
+	conn.Close()
+	if !strings.Contains(err, "The daemon is busy") {
+		t.Errorf("Expected an error about the busy daemon, got: %q", err)
+	}
+}
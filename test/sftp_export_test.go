@@ -0,0 +1,92 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testSftpExport runs a project with the "sftp" export target with a fake
+// "sftp" command, and checks if only the changes of the files since the
+// previous upload are uploaded. The fake command is a shell script, so the
+// test is skipped on Windows.
+func testSftpExport(t *testing.T, recycled bool) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake sftp command doesn't work on Windows")
+	}
+	bin, err := filepath.Abs(filepath.Join(sftpExportPath, "bin"))
+	if err != nil {
+		t.Fatal("Unable to get the path to the fake sftp command:", err)
+	}
+	logPath := filepath.Join(t.TempDir(), "sftp.log")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("REGOLITH_TEST_SFTP_LOG", logPath)
+	tmpDir, cleanup := prepareTestProject(t, sftpExportPath)
+	defer cleanup()
+	const remotePack = `"/srv/minecraft/development_behavior_packs/` +
+		`sftp_export_test_project_bp`
+	const put = `put "build/BP/data.json" ` + remotePack + `/data.json"`
+	source := filepath.Join(tmpDir, "packs", "BP", "data.json")
+	run := func() string {
+		if err := regolith.Run("dev", nil, recycled, true); err != nil {
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		return takeCommandLog(t, logPath)
+	}
+	expectCommands := func(log string, expected ...string) {
+		for _, command := range expected {
+			if !strings.Contains(log, command+"\n") {
+				t.Errorf("sftp didn't get %q:\n%s", command, log)
+			}
+		}
+	}
+	// THE TEST
+	log := run()
+	expectCommands(
+		log,
+		`-mkdir "/srv/minecraft/development_behavior_packs"`,
+		`-mkdir `+remotePack+`"`,
+		put)
+	if !strings.Contains(log, "-P 2222") ||
+		!strings.Contains(log, "builder@example.com") {
+		t.Errorf("sftp didn't get the port or the host:\n%s", log)
+	}
+	// Nothing changed
+	if log := run(); log != "" {
+		t.Errorf("sftp ran without any changes:\n%s", log)
+	}
+	// A changed file
+	writeTestFile(t, source, `{"changed": true}`)
+	log = run()
+	expectCommands(log, put)
+	if strings.Contains(log, "-mkdir") {
+		t.Errorf("sftp created the existing directories again:\n%s", log)
+	}
+	// A removed file
+	if err := os.Remove(source); err != nil {
+		t.Fatal("Unable to remove the source file:", err)
+	}
+	expectCommands(run(), `-rm `+remotePack+`/data.json"`)
+	// A failed upload makes the next upload upload all of the files
+	writeTestFile(t, source, "{}")
+	t.Setenv("REGOLITH_TEST_SFTP_EXIT", "1")
+	if err := regolith.Run("dev", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but sftp failed")
+	}
+	takeCommandLog(t, logPath)
+	t.Setenv("REGOLITH_TEST_SFTP_EXIT", "0")
+	expectCommands(
+		run(), `-mkdir "/srv/minecraft/development_behavior_packs"`, put)
+}
+
+func TestSftpExport(t *testing.T) {
+	testSftpExport(t, false)
+}
+
+func TestSftpExportRecycled(t *testing.T) {
+	testSftpExport(t, true)
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestSharedFilterCache installs a remote filter, which saves it in the
+// filter cache shared by the projects, and installs it again after its
+// repository is removed. The second installation copies the filter from the
+// shared cache, until the cache is removed with "regolith clean
+// --user-cache".
+func TestSharedFilterCache(t *testing.T) {
+	isolateUserDirs(t)
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal("Unable to get the user cache directory:", err)
+	}
+	sharedCache := filepath.Join(userCache, "regolith", "filter-cache")
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Installing the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	entries, err := os.ReadDir(sharedCache)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf(
+			"Expected one filter in the shared cache, found %d: %v",
+			len(entries), err)
+	}
+
+	t.Log("Installing the filter from the shared cache...")
+	if err := os.RemoveAll(repo.path); err != nil {
+		t.Fatal("Unable to remove the repository:", err)
+	}
+	logs.TakeAll()
+	installAndRunFilters(t)
+	if logs.FilterMessageSnippet(
+		"copied from the shared filter cache").Len() == 0 {
+		t.Error("The filter wasn't copied from the shared filter cache.")
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+
+	t.Log("Installing the filter after removing the shared cache...")
+	if err := regolith.CleanUserCache(); err != nil {
+		t.Fatal("'regolith clean --user-cache' failed:", err.Error())
+	}
+	expectNotExist(t, sharedCache)
+	if err := os.RemoveAll(".regolith"); err != nil {
+		t.Fatal("Unable to remove the .regolith directory:", err)
+	}
+	if err := regolith.InstallAll(false, false, true); err == nil {
+		t.Fatal("'regolith install-all' succeeded without the repository " +
+			"and the shared cache.")
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// testSourceMap runs a filter that prints the paths to the files in the tmp
+// directory. The paths to the unchanged files are replaced with the paths to
+// their source files, and the paths to the files changed by the filter point
+// to the tmp directory, because their lines don't match the source files.
+func testSourceMap(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, filterProtocolPath)
+	defer cleanup()
+	writeTestFile(t, filepath.Join("packs", "BP", "source.json"), "{}")
+	writeTestFile(t, filepath.Join("packs", "BP", "changed.json"), "{}")
+	writeTestFile(
+		t, filepath.Join("filters", "paths.py"),
+		"import json\n"+
+			"import os\n"+
+			"import sys\n"+
+			"request = json.loads(sys.stdin.readline())\n"+
+			"os.chdir(request[\"workingDir\"])\n"+
+			"with open(\"BP/changed.json\", \"w\") as f:\n"+
+			"    f.write('{\"changed\": true}')\n"+
+			"print(json.dumps({\"type\": \"diagnostic\", "+
+			"\"severity\": \"warning\", \"message\": \"See BP/changed.json\", "+
+			"\"file\": \"BP/source.json\", \"line\": 2, \"column\": 3}), "+
+			"flush=True)\n"+
+			"print('Checked \"BP/source.json\" and BP/RP', flush=True)\n"+
+			"print(json.dumps({\"type\": \"done\"}), flush=True)\n")
+	replaceInTestFile(
+		t, "config.json", "./filters/protocol.py", "./filters/paths.py")
+	logs, restore := captureLogs()
+	defer restore()
+	source := filepath.Join("packs", "BP", "source.json")
+	changed := filepath.Join(".regolith", "tmp", "BP", "changed.json")
+
+	// THE TEST
+	t.Log("Running the filter...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join("build", "BP", "changed.json"), "{\"changed\": true}")
+	expectLogs(
+		t, logs, "[protocol] "+source+":2:3: See "+changed,
+		"[protocol] Checked \""+source+"\" and BP/RP")
+}
+
+func TestSourceMap(t *testing.T) {
+	testSourceMap(t, false)
+}
+
+func TestSourceMapRecycled(t *testing.T) {
+	testSourceMap(t, true)
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestSparseFetch installs a remote filter from a repository with another
+// filter, in a version from a tag. The filter must be downloaded with the
+// sparse fetch, without the files of the other filter and without the
+// temporary repository.
+func TestSparseFetch(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	files := helloFilterFiles("1")
+	for path, content := range luaFilterFiles("other", "other") {
+		files[path] = content
+	}
+	repo.commit(files, "hello-1.0.0")
+	repo.commit(helloFilterFiles("2"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	replaceInTestFile(
+		t, "config.json", "\"version\": \"HEAD\"", "\"version\": \"1.0.0\"")
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Installing the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet("Sparse fetch of the").Len() != 0 {
+		t.Fatal("The sparse fetch failed, the filter was downloaded with " +
+			"the full download.")
+	}
+	filters := filepath.Join(".regolith", "cache", "filters")
+	entries, err := os.ReadDir(filters)
+	if err != nil {
+		t.Fatal("Unable to list the installed filters:", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "hello" {
+			t.Errorf("Unexpected file in the filters cache: %s", entry.Name())
+		}
+	}
+	expectNotExist(t, filepath.Join(filters, "hello", ".git"))
+	expectFileContent(
+		t, filepath.Join(filters, "hello", "hello.lua"),
+		helloFilterFiles("1")["hello/hello.lua"])
+
+	t.Log("Installing a filter that isn't in the repository...")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {",
+		"\"filterDefinitions\": {\"missing\": "+
+			"{\"url\": \""+repo.url+"\", \"version\": \"HEAD\"},")
+	err = regolith.InstallAll(false, false, true)
+	if err == nil {
+		t.Fatal("'regolith install-all' installed a filter that isn't in " +
+			"the repository.")
+	}
+	if logs.FilterMessageSnippet(
+		"The repository doesn't contain the filter.").Len() == 0 {
+		t.Error("Missing the error of the sparse fetch.")
+	}
+	entries, _ = os.ReadDir(filters)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") ||
+			entry.Name() == "missing" {
+			t.Errorf("Unexpected file in the filters cache: %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// expectExportLink checks if the export path is a link (a symbolic link or
+// a Windows junction) to the folder of the pack in the "build" folder.
+func expectExportLink(t *testing.T, link, target string) {
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Unable to get the info of %q: %s", link, err)
+	}
+	if info.Mode()&(os.ModeSymlink|os.ModeIrregular) == 0 {
+		t.Fatalf("%q is not a link.", link)
+	}
+	linkInfo, err := os.Stat(link)
+	if err != nil {
+		t.Fatalf("Unable to follow the link %q: %s", link, err)
+	}
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Unable to get the info of %q: %s", target, err)
+	}
+	if !os.SameFile(linkInfo, targetInfo) {
+		t.Fatalf("%q doesn't link to %q.", link, target)
+	}
+}
+
+// testSymlinkExport tests the "symlink" property of the export targets. The
+// packs exported by the "copy" profile are replaced with links to the
+// "build" folder by the "dev" profile, and the next exports update the
+// "build" folder without replacing the links. The "symlink" property can't
+// be used with the targets that export to the "build" folder.
+func testSymlinkExport(t *testing.T, recycled bool) {
+	_, cleanup := prepareTestProject(t, symlinkExportPath)
+	defer cleanup()
+	bpLink := filepath.Join("export", "BP")
+	rpLink := filepath.Join("export", "RP")
+
+	// THE TEST
+	t.Log("Exporting the packs without the links...")
+	if err := regolith.Run("copy", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' of \"copy\" failed:", err)
+	}
+	info, err := os.Lstat(bpLink)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("The behavior pack wasn't exported to %q.", bpLink)
+	}
+
+	t.Log("Replacing the exported packs with the links...")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' of \"dev\" failed:", err)
+	}
+	expectExportLink(t, bpLink, filepath.Join("build", "BP"))
+	expectExportLink(t, rpLink, filepath.Join("build", "RP"))
+	expectFileContent(
+		t, filepath.Join(bpLink, "data.json"), "{\"value\": 1}\n")
+	expectFileContent(t, filepath.Join(rpLink, "data.json"), "{}\n")
+
+	t.Log("Exporting the changed files through the links...")
+	writeTestFile(
+		t, filepath.Join("packs", "BP", "data.json"), "{\"value\": 2}\n")
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' of \"dev\" failed:", err)
+	}
+	expectExportLink(t, bpLink, filepath.Join("build", "BP"))
+	expectFileContent(
+		t, filepath.Join("build", "BP", "data.json"), "{\"value\": 2}\n")
+	expectFileContent(
+		t, filepath.Join(bpLink, "data.json"), "{\"value\": 2}\n")
+
+	t.Log("Linking the packs of the \"local\" export target...")
+	if err := regolith.Run("local", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' accepted the \"symlink\" property of the " +
+			"\"local\" export target")
+	}
+}
+
+func TestSymlinkExport(t *testing.T) {
+	testSymlinkExport(t, false)
+}
+
+func TestSymlinkExportRecycled(t *testing.T) {
+	testSymlinkExport(t, true)
+}
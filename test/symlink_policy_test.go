@@ -0,0 +1,98 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestSymlinkPolicy runs a profile of a project whose behavior pack has a
+// symbolic link, with every policy of the "symlinks" property. The link is
+// copied as a link by default, replaced with the linked file by "follow",
+// left out by "skip" and stops the run with "error". The "--recycled" mode
+// always copies the content of the linked files, so it isn't tested.
+func TestSymlinkPolicy(t *testing.T) {
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	writeTestFile(t, filepath.Join("shared", "x.json"), "{\"x\": 1}")
+	shared, err := filepath.Abs(filepath.Join("shared", "x.json"))
+	if err != nil {
+		t.Fatal("Unable to get the absolute path:", err)
+	}
+	link := filepath.Join("packs", "BP", "linked.json")
+	if err := os.Symlink(shared, link); err != nil {
+		t.Skip("Unable to create a symbolic link:", err)
+	}
+	exported := filepath.Join("build", "BP", "linked.json")
+	logs, restore := captureLogs()
+	defer restore()
+	policy := "\"dataPath\""
+	setPolicy := func(value string) {
+		replacement := "\"symlinks\": \"" + value + "\", \"dataPath\""
+		replaceInTestFile(t, "config.json", policy, replacement)
+		policy = replacement
+	}
+	expectLink := func(expected bool) {
+		info, err := os.Lstat(exported)
+		if err != nil {
+			t.Fatal("The linked file wasn't exported:", err)
+		}
+		if isLink := info.Mode()&os.ModeSymlink != 0; isLink != expected {
+			t.Errorf("Expected the link to be copied as a link: %v", expected)
+		}
+		expectFileContent(t, exported, "{\"x\": 1}")
+	}
+
+	// THE TEST
+	t.Log("Copying the links as links...")
+	if err := regolith.Run("log", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectLink(true)
+
+	t.Log("Following the links...")
+	setPolicy("follow")
+	if err := regolith.Run("log", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectLink(false)
+	if logs.FilterMessageSnippet("with the \"follow\" policy:").Len() == 0 {
+		t.Error("Missing the list of the followed links.")
+	}
+	loop := filepath.Join("packs", "BP", "loop")
+	if err := os.Symlink(".", loop); err != nil {
+		t.Fatal("Unable to create a symbolic link:", err)
+	}
+	err = regolith.Run("log", nil, false, true)
+	if err == nil || !strings.Contains(err.Error(), "own parent directory") {
+		t.Fatal("Expected the error about the link to its parent, got:", err)
+	}
+	if err := os.Remove(loop); err != nil {
+		t.Fatal("Unable to remove the link:", err)
+	}
+
+	t.Log("Skipping the links...")
+	setPolicy("skip")
+	if err := regolith.Run("log", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectNotExist(t, exported)
+	expectFileContent(
+		t, filepath.Join("build", "BP", "a.json"), "{\"value\": 1}")
+
+	t.Log("Rejecting the links...")
+	setPolicy("error")
+	err = regolith.Run("log", nil, false, true)
+	if err == nil || !strings.Contains(err.Error(), "linked.json") {
+		t.Fatal("Expected the error about the link, got:", err)
+	}
+
+	t.Log("Using an invalid policy...")
+	setPolicy("ignore")
+	if err := regolith.Run("log", nil, false, true); err == nil {
+		t.Error("'regolith run' accepted an invalid \"symlinks\" policy.")
+	}
+}
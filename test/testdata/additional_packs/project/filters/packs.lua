@@ -0,0 +1,10 @@
+-- Writes the files of the additional packs to BP/packs.txt and a file to
+-- every additional pack.
+local regolith = require("regolith")
+regolith.write_file("BP/packs.txt", table.concat({
+	regolith.read_file("RP_hd/textures/hd.txt"),
+	regolith.read_file("BP_addon/addon.txt"),
+	regolith.read_file("RP_addon/addon.txt"),
+}))
+regolith.write_file("RP_hd/filtered.txt", "hd")
+regolith.write_file("BP_addon/filtered.txt", "addon")
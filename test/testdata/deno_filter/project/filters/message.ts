@@ -0,0 +1,3 @@
+// Writes the message from the settings to the behavior pack.
+const settings = JSON.parse(Deno.args[0]);
+await Deno.writeTextFile("BP/message.txt", settings.message);
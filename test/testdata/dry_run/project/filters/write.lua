@@ -0,0 +1,3 @@
+-- Writes a file to the behavior pack. The dry run shouldn't run it.
+local regolith = require("regolith")
+regolith.write_file("BP/written.txt", "The filter ran")
@@ -0,0 +1,3 @@
+-- Fails before the export, so the test can check the files restored from
+-- the journal of the interrupted export.
+error("The test filter always fails")
@@ -0,0 +1,12 @@
+-- Copies the message to the behavior pack and counts the runs of the filter
+-- in a file outside of the tmp directory, which isn't restored by the cache.
+local regolith = require("regolith")
+local filterDir = regolith.filter_dir .. "/filters/stamp"
+regolith.write_file(
+	"BP/stamp.txt", regolith.read_file(filterDir .. "/message.txt"))
+local runsPath = regolith.filter_dir .. "/runs.txt"
+local runs = 0
+if regolith.exists(runsPath) then
+	runs = tonumber(regolith.read_file(runsPath))
+end
+regolith.write_file(runsPath, tostring(runs + 1))
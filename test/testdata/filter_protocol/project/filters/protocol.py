@@ -0,0 +1,26 @@
+# Handles a run request of Regolith sent with the "stdio" protocol. It logs
+# a message of the size from the settings and reports a diagnostic, which is
+# an error if the "fail" setting is true.
+import json
+import os
+import sys
+
+request = json.loads(sys.stdin.readline())
+settings = request["settings"]
+os.chdir(request["workingDir"])
+print(json.dumps({
+    "type": "log",
+    "level": "info",
+    "message": "x" * settings["messageSize"]
+}), flush=True)
+print(json.dumps({
+    "type": "diagnostic",
+    "severity": "error" if settings.get("fail") else "warning",
+    "message": "Test diagnostic",
+    "file": "BP/done.txt",
+    "line": 1,
+    "column": 1
+}), flush=True)
+with open("BP/done.txt", "w") as f:
+    f.write("done")
+print(json.dumps({"type": "done"}), flush=True)
@@ -0,0 +1,10 @@
+-- Uses the atlas of the textures and counts its runs in a file outside of the
+-- tmp directory
+local regolith = require("regolith")
+local runsPath = regolith.filter_dir .. "/entities_runs.txt"
+local runs = 0
+if regolith.exists(runsPath) then
+	runs = tonumber(regolith.read_file(runsPath))
+end
+regolith.write_file(runsPath, tostring(runs + 1))
+regolith.write_file("BP/entities.txt", regolith.read_file("RP/atlas.txt"))
@@ -0,0 +1,11 @@
+-- Counts its attempts in a file outside of the tmp directory and never
+-- finishes, so it's always stopped by the timeout.
+local regolith = require("regolith")
+local attemptsPath = regolith.filter_dir .. "/attempts.txt"
+local attempts = 0
+if regolith.exists(attemptsPath) then
+	attempts = tonumber(regolith.read_file(attemptsPath))
+end
+regolith.write_file(attemptsPath, tostring(attempts + 1))
+while true do
+end
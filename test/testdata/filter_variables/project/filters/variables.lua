@@ -0,0 +1,6 @@
+-- Writes the settings and the arguments of the filter to BP/variables.json.
+local regolith = require("regolith")
+regolith.write_json("BP/variables.json", {
+	settings = regolith.settings,
+	arguments = regolith.arguments
+})
@@ -0,0 +1,3 @@
+-- Writes the message from the settings of the filter to BP/message.txt.
+local regolith = require("regolith")
+regolith.write_file("BP/message.txt", regolith.settings.message)
@@ -0,0 +1,2 @@
+-- Always fails.
+error("The filter failed on purpose")
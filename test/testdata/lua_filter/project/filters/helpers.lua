@@ -0,0 +1,8 @@
+-- A module loaded by update.lua with "require".
+local helpers = {}
+
+function helpers.greet(name)
+	return "Hello " .. name
+end
+
+return helpers
@@ -0,0 +1,12 @@
+-- Updates BP/data.json with the values from the settings, the arguments and
+-- the helper module from the filter directory.
+local regolith = require("regolith")
+local helpers = require("filters.helpers")
+
+local data = regolith.read_json("BP/data.json")
+data.count = data.count + regolith.settings.increment
+data.name = regolith.arguments[1]
+data.files = #regolith.list_files("BP")
+data.greeting = helpers.greet(data.name)
+regolith.write_json("BP/data.json", data)
+regolith.write_file("RP/exists.txt", tostring(regolith.exists("BP/data.json")))
@@ -0,0 +1,5 @@
+-- Fails if the filters that it needs didn't run before it
+local regolith = require("regolith")
+regolith.write_file(
+	"BP/combined.txt",
+	regolith.read_file("BP/a.txt") .. regolith.read_file("BP/b.txt"))
@@ -0,0 +1,2 @@
+local regolith = require("regolith")
+regolith.write_file("BP/b.txt", "b")
@@ -0,0 +1,3 @@
+-- Has inputs that never exist, so it never runs
+local regolith = require("regolith")
+regolith.write_file("BP/skipped.txt", "skipped")
@@ -0,0 +1,15 @@
+# Handles the run requests of Regolith as a persistent process. The number of
+# the handled requests is kept in the memory of the process, so it's higher
+# than 1 only if the process is reused by the next runs.
+import json
+import os
+import sys
+
+runs = 0
+for line in sys.stdin:
+    request = json.loads(line)
+    runs += 1
+    os.chdir(request["workingDir"])
+    with open("BP/runs.txt", "w") as f:
+        f.write(str(runs))
+    print(json.dumps({"type": "done"}), flush=True)
@@ -0,0 +1,7 @@
+# Writes the path to the venv of the filter to "BP/<filter directory>.txt"
+import os
+import sys
+
+name = os.path.basename(os.path.dirname(os.path.abspath(__file__)))
+with open(os.path.join("BP", name + ".txt"), "w") as f:
+    f.write(sys.prefix)
@@ -0,0 +1,7 @@
+# Writes the version of the fake Python interpreter that runs the filter to
+# "BP/<filter directory>.txt"
+import os
+
+name = os.path.basename(os.path.dirname(os.path.abspath(__file__)))
+with open(os.path.join("BP", name + ".txt"), "w") as f:
+    f.write(os.environ.get("REGOLITH_TEST_PYTHON", "none"))
@@ -0,0 +1,2 @@
+-- Makes the run fail, so the webhooks are notified about the failure.
+error("The test filter always fails")
@@ -0,0 +1,3 @@
+-- Writes the name of the project to BP/<project name>.txt.
+local regolith = require("regolith")
+regolith.write_file("BP/addon.txt", "addon")
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestTool runs a filter that isn't used by any profile on the source files
+// with "regolith tool". The command asks for the confirmation, changes the
+// source files and backs up the original files. The source files don't
+// change if the filter fails.
+func TestTool(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	writeTestFile(
+		t, filepath.Join("filters", "stamp.lua"),
+		"local regolith = require(\"regolith\")\n"+
+			"regolith.write_file(\"BP/stamp.txt\", regolith.arguments[1])\n"+
+			"regolith.write_file(\"RP/c.json\", \"changed\")\n")
+	writeTestFile(
+		t, filepath.Join("filters", "fail.lua"),
+		"local regolith = require(\"regolith\")\n"+
+			"regolith.write_file(\"RP/c.json\", \"failed\")\n"+
+			"error(\"Failed\")\n")
+	replaceInTestFile(
+		t, "config.json", "\"filterDefinitions\": {}",
+		"\"filterDefinitions\": {"+
+			"\"stamp\": {\"runWith\": \"lua\", \"script\": "+
+			"\"./filters/stamp.lua\"}, "+
+			"\"fail\": {\"runWith\": \"lua\", \"script\": "+
+			"\"./filters/fail.lua\"}}")
+	rpFile := filepath.Join("packs", "RP", "c.json")
+	original, err := os.ReadFile(rpFile)
+	if err != nil {
+		t.Fatal("Unable to read the source file:", err)
+	}
+	stamp := filepath.Join("packs", "BP", "stamp.txt")
+
+	// THE TEST
+	t.Log("Cancelling the tool...")
+	setStdin(t, "n\n")
+	if err := regolith.Tool("stamp", []string{"1"}, false, true); err != nil {
+		t.Fatal("'regolith tool' failed:", err.Error())
+	}
+	expectNotExist(t, stamp)
+	expectFileContent(t, rpFile, string(original))
+
+	t.Log("Running the tool...")
+	if err := regolith.Tool("stamp", []string{"2"}, true, true); err != nil {
+		t.Fatal("'regolith tool' failed:", err.Error())
+	}
+	expectFileContent(t, stamp, "2")
+	expectFileContent(t, rpFile, "changed")
+	expectNotExist(t, "build")
+	backups, err := os.ReadDir(
+		filepath.Join(".regolith", "cache", "tool_backups"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected one backup, found %d: %v", len(backups), err)
+	}
+	expectFileContent(
+		t, filepath.Join(
+			".regolith", "cache", "tool_backups", backups[0].Name(), "RP",
+			"c.json"),
+		string(original))
+
+	t.Log("Running a failing filter and a filter that doesn't exist...")
+	if err := regolith.Tool("fail", nil, true, true); err == nil {
+		t.Fatal("'regolith tool' succeeded with a failing filter.")
+	}
+	expectFileContent(t, rpFile, "changed")
+	if err := regolith.Tool("missing", nil, true, true); err == nil {
+		t.Fatal("'regolith tool' succeeded with a filter that doesn't exist.")
+	}
+}
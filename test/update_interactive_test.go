@@ -0,0 +1,108 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// setStdin replaces the standard input with a pipe that contains the
+// input, for the commands that ask questions.
+func setStdin(t *testing.T, input string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal("Unable to create a pipe:", err)
+	}
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal("Unable to write to the pipe:", err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+// expectDefinitionVersion checks the version of the filter definition in
+// the config.json file.
+func expectDefinitionVersion(t *testing.T, name, expected string) {
+	config, err := regolith.LoadConfigAsMap()
+	if err != nil {
+		t.Fatal("Unable to load the config:", err.Error())
+	}
+	definitions := config["regolith"].(map[string]interface{})
+	definitions = definitions["filterDefinitions"].(map[string]interface{})
+	definition, _ := definitions[name].(map[string]interface{})
+	if definition["version"] != expected {
+		t.Fatalf(
+			"Wrong version of the %q filter in the config.\n"+
+				"Expected: %s\nActual: %v", name, expected,
+			definition["version"])
+	}
+}
+
+// TestUpdateInteractive updates a filter pinned to a version with "regolith
+// update --interactive". The command prints the commits that changed the
+// filter since the installed version, and updates the filter and its
+// version in the config only if the user accepts the update.
+func TestUpdateInteractive(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	repo.commit(helloFilterFiles("1"), "hello-1.0.0")
+	writeTestFile(t, filepath.Join(repo.path, "README.md"), "# Filters\n")
+	repo.git("add", "--all")
+	repo.git("commit", "--quiet", "--message", "Add the readme")
+	second := repo.commit(helloFilterFiles("2"), "hello-1.1.0")
+	shortSha := repo.git("rev-parse", "--short", second)
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	replaceInTestFile(
+		t, "config.json", "\"version\": \"HEAD\"", "\"version\": \"1.0.0\"")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Rejecting the update...")
+	setStdin(t, "n\n")
+	if err := regolith.UpdateInteractive(nil, true); err != nil {
+		t.Fatal("'regolith update --interactive' failed:", err.Error())
+	}
+	if logs.FilterMessageSnippet("Add the readme").Len() != 0 {
+		t.Error("The changelog includes a commit that didn't change the " +
+			"filter.")
+	}
+	expectLogs(
+		t, logs,
+		"Filter \"hello\": 1.0.0 -> 1.1.0",
+		"\t"+shortSha+" Update the filters",
+		"No filters selected for the update.")
+	expectDefinitionVersion(t, "hello", "1.0.0")
+	expectLockedFilter(t, "hello", "1.0.0")
+
+	t.Log("Accepting the update...")
+	setStdin(t, "y\n")
+	if err := regolith.UpdateInteractive(nil, true); err != nil {
+		t.Fatal("'regolith update --interactive' failed:", err.Error())
+	}
+	expectDefinitionVersion(t, "hello", "1.1.0")
+	expectLockedFilter(t, "hello", "1.1.0")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "2")
+
+	t.Log("Checking the updates of the updated filter...")
+	logs.TakeAll()
+	if err := regolith.UpdateInteractive(nil, true); err != nil {
+		t.Fatal("'regolith update --interactive' failed:", err.Error())
+	}
+	expectLogs(t, logs, "All of the filters are up to date.")
+}
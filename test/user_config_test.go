@@ -0,0 +1,90 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestUserConfigDefaults runs a project with the project defaults and the
+// cache directory from the user config. The values from config.json have
+// priority over the project defaults.
+func TestUserConfigDefaults(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	cacheDir := t.TempDir()
+	setUserConfigProperty(t, configPath, "cacheDir", cacheDir)
+	defaults := map[string]interface{}{
+		"author": "Default author",
+		"regolith": map[string]interface{}{
+			"useAppData": true,
+			"logLevel":   "warn",
+		},
+	}
+	setUserConfigProperty(t, configPath, "projectDefaults", defaults)
+	_, cleanup := prepareTestProject(t, jsoncConfigPath)
+	defer cleanup()
+
+	// THE TEST
+	t.Log("Loading the config with the project defaults...")
+	config, err := regolith.LoadConfigAsMap()
+	if err != nil {
+		t.Fatal("Unable to load the config:", err.Error())
+	}
+	if config["author"] != "Bedrock-OSS" {
+		t.Errorf("The project default replaced the author: %v",
+			config["author"])
+	}
+	regolithObj := jsonObject(t, config, "regolith")
+	if regolithObj["useAppData"] != true || regolithObj["logLevel"] != "warn" ||
+		regolithObj["dataPath"] != "./packs/data" {
+		t.Errorf("The project defaults weren't merged: %v", regolithObj)
+	}
+
+	t.Log("Running the project with the cache in the cache directory...")
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectNotExist(t, ".regolith")
+	entries, err := os.ReadDir(filepath.Join(cacheDir, "project-cache"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected the cache of the project in the cache "+
+			"directory, found %d entries: %v", len(entries), err)
+	}
+
+	t.Log("Running the project with a relative cache directory...")
+	setUserConfigProperty(t, configPath, "cacheDir", "cache")
+	if err := regolith.Run("dev", nil, false, true); err == nil {
+		t.Fatal("'regolith run' accepted a relative cache directory")
+	}
+}
+
+// TestUserConfigLegacyPath checks if the user config is loaded from the
+// user cache directory used by the older versions of Regolith, if it
+// doesn't exist in the user config directory.
+func TestUserConfigLegacyPath(t *testing.T) {
+	configPath := isolateUserDirs(t)
+	legacyDir, err := regolith.GetRegolithConfigPath()
+	if err != nil {
+		t.Fatal("Unable to get the legacy path:", err.Error())
+	}
+	legacyPath := filepath.Join(legacyDir, filepath.Base(configPath))
+
+	// THE TEST
+	writeTestFile(t, legacyPath, "{\"nodePackageManager\": \"yarn\"}\n")
+	if path, err := regolith.GetUserConfigPath(); err != nil ||
+		path != configPath {
+		t.Fatalf("Unexpected path to the user config: %q, %v", path, err)
+	}
+	if err := os.Remove(configPath); err != nil {
+		t.Fatal("Unable to remove the user config:", err)
+	}
+	userConfig, err := regolith.LoadUserConfig()
+	if err != nil {
+		t.Fatal("Unable to load the user config:", err.Error())
+	}
+	if userConfig.NodePackageManager != "yarn" {
+		t.Errorf("The legacy user config wasn't loaded: %+v", userConfig)
+	}
+}
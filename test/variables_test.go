@@ -0,0 +1,167 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestExpandVariables checks the placeholders replaced by ExpandVariables.
+func TestExpandVariables(t *testing.T) {
+	t.Setenv("REGOLITH_TEST_VARIABLE", "env value")
+	context := regolith.RunContext{
+		Config:  &regolith.Config{Name: "project", Author: "author"},
+		Profile: "dev",
+		Defines: map[string]string{"VERSION": "1.0"},
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	for text, expected := range map[string]string{
+		"${PROJECT_NAME} by ${PROJECT_AUTHOR}": "project by author",
+		"${PROFILE}-${PROFILE}":                "dev-dev",
+		"${OS}/${ARCH}":                        platform,
+		"${define:VERSION}":                    "1.0",
+		"${define:MISSING}":                    "",
+		"${env:REGOLITH_TEST_VARIABLE}":        "env value",
+		"${env:REGOLITH_TEST_VARIABLE:-none}":  "env value",
+		"${env:REGOLITH_TEST_MISSING:-none}":   "none",
+		"${env:REGOLITH_TEST_MISSING}":         "",
+		"${UNKNOWN}${unknown:VALUE}":           "${UNKNOWN}${unknown:VALUE}",
+		"$PROFILE and ${PROFILE":               "$PROFILE and ${PROFILE",
+	} {
+		actual := regolith.ExpandVariables(text, context)
+		if actual != expected {
+			t.Errorf(
+				"Unexpected result of expanding %q: %q, expected %q",
+				text, actual, expected)
+		}
+	}
+}
+
+// testFilterVariables runs a project with a filter that uses the variables
+// in its settings (including the nested values) and arguments, and checks
+// the values received by the filter.
+func testFilterVariables(t *testing.T, recycled bool) {
+	t.Setenv("REGOLITH_TEST_VARIABLE", "env value")
+	t.Setenv("REGOLITH_SECRET_TEST_VARIABLE_SECRET", "secret value")
+	tmpDir, cleanup := prepareTestProject(t, filterVariablesPath)
+	defer cleanup()
+	// THE TEST
+	err := regolith.Run("dev", []string{"VERSION=1.0"}, recycled, true)
+	if err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	data, err := os.ReadFile(
+		filepath.Join(tmpDir, "build", "BP", "variables.json"))
+	if err != nil {
+		t.Fatal("Unable to read the output of the filter:", err)
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal("Unable to parse the output of the filter:", err)
+	}
+	expected := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"name": "filter_variables_test_project",
+			"nested": map[string]interface{}{
+				"items": []interface{}{"dev", "1.0"},
+			},
+			"env":     "env value",
+			"default": "fallback",
+			"secret":  "secret value",
+			"unknown": "${UNKNOWN}",
+		},
+		"arguments": []interface{}{
+			runtime.GOOS + "-" + runtime.GOARCH, "Bedrock-OSS"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(
+			"Unexpected values received by the filter:\n%v\nExpected:\n%v",
+			actual, expected)
+	}
+}
+
+func TestFilterVariables(t *testing.T) {
+	testFilterVariables(t, false)
+}
+
+func TestFilterVariablesRecycled(t *testing.T) {
+	testFilterVariables(t, true)
+}
+
+// TestConfigEnvVariables checks if the "${env:NAME}" placeholders are
+// replaced in the values of config.json, but not in the property names.
+func TestConfigEnvVariables(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(minimalProjectPath, "config.json"))
+	if err != nil {
+		t.Fatal("Unable to read the config:", err)
+	}
+	t.Setenv("REGOLITH_TEST_TARGET", "exact")
+	t.Setenv("REGOLITH_TEST_MISSING", "") // Restored after the test
+	os.Unsetenv("REGOLITH_TEST_MISSING")
+	parseConfig := func() *regolith.Config {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			t.Fatal("Unable to parse the config:", err)
+		}
+		obj["author"] = "${env:REGOLITH_TEST_MISSING}"
+		jsonObject(t, obj, "packs")["behaviorPack"] =
+			"${env:REGOLITH_TEST_PACKS:-./packs}/BP"
+		profiles := jsonObject(t, obj, "regolith", "profiles")
+		profiles["${env:REGOLITH_TEST_TARGET}"] = profiles["dev"]
+		export := jsonObject(t, profiles, "dev", "export")
+		export["target"] = "${env:REGOLITH_TEST_TARGET}"
+		export["bpPath"] = "${env:REGOLITH_TEST_MISSING:-./build/BP}"
+		export["rpPath"] = "${env:REGOLITH_TEST_MISSING:-./build/RP}"
+		config, err := regolith.ConfigFromObject(obj)
+		if err != nil {
+			t.Fatal("Unable to parse the config:", err.Error())
+		}
+		if obj["author"] != "${env:REGOLITH_TEST_MISSING}" {
+			t.Errorf("The parsed object was changed: %v", obj["author"])
+		}
+		return config
+	}
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Parsing the config with the default values...")
+	config := parseConfig()
+	export := config.Profiles["dev"].ExportTarget
+	if export.Target != "exact" || export.BpPath != "./build/BP" ||
+		export.RpPath != "./build/RP" {
+		t.Errorf("Unexpected export target: %+v", export)
+	}
+	if config.BehaviorFolder != "./packs/BP" {
+		t.Errorf("Unexpected behavior pack: %q", config.BehaviorFolder)
+	}
+	if config.Author != "" {
+		t.Errorf("Unexpected author: %q", config.Author)
+	}
+	if _, ok := config.Profiles["${env:REGOLITH_TEST_TARGET}"]; !ok {
+		t.Error("The placeholder in the name of the profile was replaced.")
+	}
+	if logs.FilterMessageSnippet("Variable: REGOLITH_TEST_MISSING").Len() ==
+		0 {
+		t.Error("Missing the warning about the variable that isn't set.")
+	}
+
+	t.Log("Parsing the config with the environment variables...")
+	t.Setenv("REGOLITH_TEST_PACKS", "./other")
+	t.Setenv("REGOLITH_TEST_MISSING", "set")
+	config = parseConfig()
+	if config.BehaviorFolder != "./other/BP" {
+		t.Errorf("Unexpected behavior pack: %q", config.BehaviorFolder)
+	}
+	export = config.Profiles["dev"].ExportTarget
+	if config.Author != "set" || export.BpPath != "set" {
+		t.Errorf(
+			"The values weren't replaced: author = %q, bpPath = %q",
+			config.Author, export.BpPath)
+	}
+}
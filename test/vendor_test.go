@@ -0,0 +1,83 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// installOffline removes the installed filters of the project in the working
+// directory, like in a fresh clone of the project, and installs them with
+// "regolith install-all --offline".
+func installOffline(t *testing.T) error {
+	if err := os.RemoveAll(".regolith"); err != nil {
+		t.Fatal("Unable to remove the .regolith directory:", err)
+	}
+	if err := regolith.Unlock(true); err != nil {
+		t.Fatal("'regolith unlock' failed:", err.Error())
+	}
+	return regolith.InstallAll(false, true, true)
+}
+
+// TestVendorOffline vendors a remote filter with "regolith vendor" and
+// installs it with "regolith install-all --offline" after its repository is
+// removed. The offline installation fails if the vendored filter doesn't
+// match the lock file or if the filter isn't available.
+func TestVendorOffline(t *testing.T) {
+	isolateUserDirs(t)
+	repo := newFilterRepo(t)
+	first := repo.commit(helloFilterFiles("1"), "")
+	_, cleanup := prepareTestProject(t, gitFiltersPath)
+	defer cleanup()
+	replaceInTestFile(t, "config.json", "FILTER_REPO_URL", repo.url)
+	vendored := filepath.Join(regolith.VendorPath, "hello")
+	stale := filepath.Join(regolith.VendorPath, "removed", "filter.json")
+	writeTestFile(t, stale, "{}\n")
+
+	// THE TEST
+	t.Log("Vendoring the filter...")
+	if err := regolith.InstallAll(false, false, true); err != nil {
+		t.Fatal("'regolith install-all' failed:", err.Error())
+	}
+	if err := regolith.Vendor(true); err != nil {
+		t.Fatal("'regolith vendor' failed:", err.Error())
+	}
+	expectFileContent(
+		t, filepath.Join(vendored, "hello.lua"),
+		helloFilterFiles("1")["hello/hello.lua"])
+	expectNotExist(t, filepath.Dir(stale))
+
+	t.Log("Installing the vendored filter without the repository...")
+	repo.commit(helloFilterFiles("2"), "")
+	if err := os.RemoveAll(repo.path); err != nil {
+		t.Fatal("Unable to remove the repository:", err)
+	}
+	if err := installOffline(t); err != nil {
+		t.Fatal("'regolith install-all --offline' failed:", err.Error())
+	}
+	if err := regolith.Run("dev", nil, false, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	expectFileContent(t, filepath.Join("build", "BP", "hello.txt"), "1")
+
+	t.Log("Installing the vendored filter that doesn't match the lock...")
+	replaceInTestFile(t, regolith.LockFilePath, first, strings.Repeat("0", 40))
+	err := installOffline(t)
+	if err == nil || !strings.Contains(err.Error(), "doesn't match the lock") {
+		t.Fatal("Expected an error about the lock file, got:", err)
+	}
+	replaceInTestFile(t, regolith.LockFilePath, strings.Repeat("0", 40), first)
+
+	t.Log("Installing the filter that isn't vendored...")
+	isolateUserDirs(t) // Without the shared filter cache
+	if err := os.RemoveAll(regolith.VendorPath); err != nil {
+		t.Fatal("Unable to remove the vendored filters:", err)
+	}
+	err = installOffline(t)
+	if err == nil || !strings.Contains(err.Error(), "neither vendored") {
+		t.Fatal("Expected an error about the missing filter, got:", err)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// initVerbosityLogging creates the logger of Regolith for the verbosity
+// level, the same way as the "-v", "-vv" and "--quiet" flags, and replaces it
+// with a logger that records the messages (see captureLogs). It returns the
+// recorded messages, the level selected for the verbosity and a function
+// that restores the previous logger.
+func initVerbosityLogging(
+	verbosity int,
+) (*observer.ObservedLogs, zapcore.Level, func()) {
+	logger, level := regolith.Logger, regolith.LoggerLevel
+	regolith.Verbosity = verbosity
+	regolith.SetLogger(nil, zap.NewAtomicLevel())
+	regolith.InitLogging(false)
+	selected := regolith.LoggerLevel.Level()
+	logs, restoreCaptured := captureLogs()
+	return logs, selected, func() {
+		restoreCaptured()
+		// Reset the tracing of the file operations
+		regolith.Verbosity = 0
+		regolith.SetLogger(nil, zap.NewAtomicLevel())
+		regolith.InitLogging(false)
+		regolith.SetLogger(logger, level)
+	}
+}
+
+// TestVerbosity runs a project with every verbosity level. The level
+// selects the level of the logger, only the "-vv" flag logs the file
+// operations and the "logLevel" from config.json is used only without the
+// verbosity flags.
+func TestVerbosity(t *testing.T) {
+	isolateUserDirs(t)
+	_, cleanup := prepareTestProject(t, exportReportPath)
+	defer cleanup()
+	replaceInTestFile(
+		t, "config.json", "\"dataPath\"",
+		"\"logLevel\": \"error\", \"dataPath\"")
+	cases := []struct {
+		verbosity int
+		level     zapcore.Level
+		traces    bool
+	}{
+		{0, zap.InfoLevel, false},
+		{1, zap.DebugLevel, false},
+		{2, zap.DebugLevel, true},
+		{-1, zap.WarnLevel, false},
+	}
+
+	// THE TEST
+	for _, c := range cases {
+		t.Logf("Running the project with the verbosity %d...", c.verbosity)
+		logs, level, restore := initVerbosityLogging(c.verbosity)
+		if level != c.level {
+			t.Errorf("Wrong log level for the verbosity %d: %s",
+				c.verbosity, level)
+		}
+		err := regolith.Run("log", nil, false, false)
+		if err != nil {
+			restore()
+			t.Fatal("'regolith run' failed:", err.Error())
+		}
+		traces := logs.FilterMessageSnippet("Copying ").Len() +
+			logs.FilterMessageSnippet("Moving ").Len()
+		if c.traces && traces == 0 {
+			t.Errorf("Missing the file operations for the verbosity %d.",
+				c.verbosity)
+		} else if !c.traces && traces != 0 {
+			t.Errorf("Unexpected file operations for the verbosity %d.",
+				c.verbosity)
+		}
+		// The captured logger starts at the debug level, only the run
+		// without the verbosity flags changes it to the "logLevel"
+		expected := zap.DebugLevel
+		if c.verbosity == 0 {
+			expected = zap.ErrorLevel
+		}
+		if actual := regolith.LoggerLevel.Level(); actual != expected {
+			t.Errorf("Wrong log level after the run with the verbosity "+
+				"%d: %s", c.verbosity, actual)
+		}
+		restore()
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestVersionInRange tests the ranges of the versions of the remote filters
+// ("^" and "~" operators) against the versions from the tags of the filters.
+func TestVersionInRange(t *testing.T) {
+	cases := map[[2]string]bool{
+		// The "^" operator
+		{"1.2.3", "^1.2.3"}: true,
+		{"1.9.0", "^1.2.3"}: true,
+		{"1.2.2", "^1.2.3"}: false,
+		{"2.0.0", "^1.2.3"}: false,
+		{"1.0.0", "^1"}:     true,
+		{"1.5.0", "^1.2"}:   true,
+		{"0.2.9", "^0.2.3"}: true,
+		{"0.3.0", "^0.2.3"}: false,
+		{"0.0.3", "^0.0.3"}: true,
+		{"0.0.4", "^0.0.3"}: false,
+		{"0.0.9", "^0.0"}:   true,
+		{"0.1.0", "^0.0"}:   false,
+		{"0.9.0", "^0"}:     true,
+		{"1.0.0", "^0"}:     false,
+		// The "~" operator
+		{"1.2.9", "~1.2.3"}: true,
+		{"1.3.0", "~1.2.3"}: false,
+		{"1.2.0", "~1.2"}:   true,
+		{"1.3.0", "~1.2"}:   false,
+		{"1.9.9", "~1"}:     true,
+		{"2.0.0", "~1"}:     false,
+		{"0.2.5", "~0.2"}:   true,
+		// The pre-release versions are never in the range
+		{"1.3.0-beta", "^1.2.3"}: false,
+		{"1.2.3-rc.1", "~1.2.3"}: false,
+		{"2.0.0-alpha", "^1"}:    false,
+		// Invalid versions are never in the range
+		{"", "^1"}:       false,
+		{"v1.2.3", "^1"}: false,
+		{"one", "^1"}:    false,
+	}
+	for c, expected := range cases {
+		version, versionRange := c[0], c[1]
+		result, err := regolith.VersionInRange(version, versionRange)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", versionRange, err)
+		}
+		if result != expected {
+			t.Errorf(
+				"Unexpected result for %q in %q: got %v, expected %v",
+				version, versionRange, result, expected)
+		}
+	}
+	for _, versionRange := range []string{
+		"", "1.2.3", ">=1.2.3", "^", "~", "^1.2.3.4", "^1..2", "^-1",
+		"^1.x", "~v1.2", "^ 1", "^1.2.3-beta",
+	} {
+		_, err := regolith.VersionInRange("1.2.3", versionRange)
+		if err == nil {
+			t.Errorf("Expected an error when parsing %q", versionRange)
+		}
+	}
+}
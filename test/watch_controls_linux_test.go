@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/sys/unix"
+)
+
+// openTerminal opens a pseudoterminal and returns its master side, which
+// sends the pressed keys, and its slave side, which replaces the standard
+// input of Regolith.
+func openTerminal(t *testing.T) (*os.File, *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("Unable to open a pseudoterminal:", err)
+	}
+	fd := int(master.Fd())
+	number, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err == nil {
+		err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0)
+	}
+	if err != nil {
+		master.Close()
+		t.Skip("Unable to unlock the pseudoterminal:", err)
+	}
+	slave, err := os.OpenFile(
+		fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Skip("Unable to open the pseudoterminal:", err)
+	}
+	return master, slave
+}
+
+// awaitLogs waits until the logs have the message the number of times.
+func awaitLogs(
+	t *testing.T, logs *observer.ObservedLogs, message string, count int,
+) {
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		if logs.FilterMessage(message).Len() >= count {
+			return
+		}
+		if time.Since(start) > 30*time.Second {
+			t.Fatalf("Missing the message %q.", message)
+		}
+	}
+}
+
+// TestWatchControls controls the watch mode with the keys pressed in the
+// terminal. The "r" key runs the profile again, the "p" key pauses the
+// watching, so the changed files are exported only after resuming it, and
+// the "q" key stops the watching.
+func TestWatchControls(t *testing.T) {
+	isolateUserDirs(t)
+	master, slave := openTerminal(t)
+	defer master.Close()
+	defer slave.Close()
+	_, cleanup := prepareTestProject(t, filterResumePath)
+	defer cleanup()
+	logs, restore := captureLogs()
+	defer restore()
+	stdin := os.Stdin
+	os.Stdin = slave
+	defer func() { os.Stdin = stdin }()
+	help := "Press \"r\" to rebuild, \"p\" to pause, \"o\" to open the " +
+		"export folder or \"q\" to stop watching."
+	press := func(key string) {
+		if _, err := master.WriteString(key); err != nil {
+			t.Fatal("Unable to press the key:", err)
+		}
+	}
+	expectRuns := func(textures, entities string) {
+		expectFileContent(t, "textures_runs.txt", textures)
+		expectFileContent(t, "entities_runs.txt", entities)
+	}
+
+	// THE TEST
+	t.Log("Watching the project...")
+	done := make(chan error, 1)
+	go func() { done <- regolith.Watch("dev", nil, false, true, 0) }()
+	awaitLogs(t, logs, help, 1)
+	expectRuns("1", "1")
+
+	t.Log("Rebuilding the project...")
+	press("r")
+	awaitLogs(t, logs, help, 2)
+	expectRuns("2", "2")
+
+	t.Log("Changing the files while the watching is paused...")
+	press("p")
+	awaitLogs(t, logs, "Paused watching. Press \"p\" to resume it.", 1)
+	writeTestFile(t, filepath.Join("packs", "BP", "entities", "b.json"), "{}")
+	time.Sleep(time.Second)
+	expectRuns("2", "2")
+	expectNotExist(t, filepath.Join("build", "BP", "entities", "b.json"))
+	press("p")
+	awaitLogs(t, logs, help, 3)
+	expectRuns("2", "3")
+	expectFileContent(
+		t, filepath.Join("build", "BP", "entities", "b.json"), "{}")
+
+	t.Log("Stopping the watching...")
+	press("q")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("'regolith watch' failed:", err.Error())
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("The watching didn't stop.")
+	}
+	expectLogs(t, logs, "Stopped watching.")
+}
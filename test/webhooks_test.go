@@ -0,0 +1,94 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// webhookRequest is a request received by the test server of the webhooks.
+type webhookRequest struct {
+	path  string
+	event map[string]interface{}
+}
+
+// testWebhooks runs the profiles of a project with webhooks and checks the
+// messages received by the webhook. The second webhook is unreachable, and
+// the test checks if the error doesn't reveal the secret from its URL.
+func testWebhooks(t *testing.T, recycled bool) {
+	var mutex sync.Mutex
+	var requests []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var event map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&event)
+			mutex.Lock()
+			requests = append(requests, webhookRequest{r.URL.Path, event})
+			mutex.Unlock()
+		}))
+	defer server.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	const token = "test-webhook-token"
+	t.Setenv("REGOLITH_TEST_WEBHOOK_URL", server.URL)
+	t.Setenv("REGOLITH_TEST_UNREACHABLE_URL", unreachable.URL)
+	t.Setenv("REGOLITH_SECRET_TEST_WEBHOOK_TOKEN", token)
+	_, cleanup := prepareTestProject(t, webhooksPath)
+	defer cleanup()
+	logs, restoreLogger := captureLogs()
+	defer restoreLogger()
+	// THE TEST
+	if err := regolith.Run("dev", nil, recycled, true); err != nil {
+		t.Fatal("'regolith run' failed:", err.Error())
+	}
+	if err := regolith.Run("fail", nil, recycled, true); err == nil {
+		t.Fatal("'regolith run' succeeded, but the filter should fail")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("The webhook received %d messages, expected 2", len(requests))
+	}
+	for i, expected := range []string{"success", "failure"} {
+		request := requests[i]
+		if request.path != "/"+token {
+			t.Errorf("Unexpected path of the webhook: %s", request.path)
+		}
+		if request.event["event"] != expected ||
+			request.event["project"] != "webhooks_test_project" {
+			t.Errorf(
+				"Unexpected message %v, expected the %q event",
+				request.event, expected)
+		}
+	}
+	runError, _ := requests[1].event["error"].(string)
+	if requests[1].event["profile"] != "fail" || runError == "" {
+		t.Errorf("The failure message has no error: %v", requests[1].event)
+	}
+	failed := false
+	for _, entry := range logs.All() {
+		if strings.Contains(entry.Message, token) {
+			t.Errorf("The log reveals the secret: %s", entry.Message)
+		}
+		if strings.Contains(
+			entry.Message, "Failed to send the message to the webhook") {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("The error of the unreachable webhook wasn't logged")
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	testWebhooks(t, false)
+}
+
+func TestWebhooksRecycled(t *testing.T) {
+	testWebhooks(t, true)
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// TestEvaluateWhenExpression tests the evaluation of the "when" expressions
+// used for skipping the filters.
+func TestEvaluateWhenExpression(t *testing.T) {
+	t.Setenv("REGOLITH_TEST_WHEN", "yes")
+	environment := regolith.WhenEnvironment{
+		Profile: "dev",
+		Defines: map[string]string{"release": "true", "level": "0"},
+	}
+	cases := map[string]bool{
+		"true":                                true,
+		"false":                               false,
+		"profile == 'dev'":                    true,
+		"profile != \"dev\"":                  false,
+		"os == '" + runtime.GOOS + "'":        true,
+		"define.release":                      true,
+		"define.level":                        false,
+		"define.missing":                      false,
+		"!define.missing":                     true,
+		"env.REGOLITH_TEST_WHEN == 'yes'":     true,
+		"define.release && profile == 'dev'":  true,
+		"define.level || !(profile == 'dev')": false,
+		"(define.level || define.release) && env.REGOLITH_TEST_WHEN": true,
+	}
+	for expression, expected := range cases {
+		result, err := regolith.EvaluateWhenExpression(expression, environment)
+		if err != nil {
+			t.Fatalf("Failed to evaluate %q: %s", expression, err)
+		}
+		if result != expected {
+			t.Errorf(
+				"Unexpected result of %q: got %v, expected %v",
+				expression, result, expected)
+		}
+	}
+	for _, expression := range []string{
+		"", "profile ==", "(true", "'unterminated", "a = b", "true false",
+		"defnie.release", "profile == dev", "env.", "define",
+	} {
+		_, err := regolith.EvaluateWhenExpression(expression, environment)
+		if err == nil {
+			t.Errorf("Expected an error when evaluating %q", expression)
+		}
+	}
+}
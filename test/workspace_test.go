@@ -0,0 +1,130 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// expectProjectLogs checks if the projects of the workspace were processed
+// in the order, and clears the logs.
+func expectProjectLogs(
+	t *testing.T, logs *observer.ObservedLogs, projects ...string,
+) {
+	for i, project := range projects {
+		message := fmt.Sprintf(
+			"[%d/%d] Project %q", i+1, len(projects), project)
+		if logs.FilterMessageSnippet(message).Len() == 0 {
+			t.Errorf("Missing log message: %q", message)
+		}
+	}
+	logs.TakeAll()
+}
+
+// expectWorkspaceBuilds checks which projects of the workspace exported
+// their packs (see workspacePath).
+func expectWorkspaceBuilds(t *testing.T, built map[string]bool) {
+	for name, expected := range built {
+		path := filepath.Join(name, "build", "BP", name+".txt")
+		if expected {
+			expectFileContent(t, path, name)
+		} else {
+			expectNotExist(t, path)
+		}
+	}
+}
+
+// testWorkspace runs the projects of a workspace with the "--all" and
+// "--project" flags. The projects run after the projects they depend on.
+func testWorkspace(t *testing.T, recycled bool) {
+	tmpDir, cleanup := prepareTestProject(t, workspacePath)
+	defer cleanup()
+	logs, restore := captureLogs()
+	defer restore()
+
+	// THE TEST
+	t.Log("Running the selected project from its directory...")
+	if err := os.Chdir("addon"); err != nil {
+		t.Fatal("Unable to enter the directory of the project:", err)
+	}
+	err := regolith.RunWorkspace("addon", "dev", nil, recycled, false, true)
+	if err != nil {
+		t.Fatal("'regolith run --project' failed:", err.Error())
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal("Unable to return to the workspace:", err)
+	}
+	expectWorkspaceBuilds(
+		t, map[string]bool{"library": true, "addon": true, "other": false})
+	expectProjectLogs(t, logs, "library", "addon")
+
+	t.Log("Running the project that isn't in the workspace...")
+	err = regolith.RunWorkspace("missing", "dev", nil, recycled, false, true)
+	if err == nil {
+		t.Fatal("'regolith run --project' accepted a missing project")
+	}
+
+	t.Log("Running every project in the dry run...")
+	err = regolith.RunWorkspace("", "dev", nil, recycled, true, true)
+	if err != nil {
+		t.Fatal("'regolith run --all --dry-run' failed:", err.Error())
+	}
+	expectWorkspaceBuilds(t, map[string]bool{"other": false})
+
+	t.Log("Running every project...")
+	logs.TakeAll()
+	err = regolith.RunWorkspace("", "dev", nil, recycled, false, true)
+	if err != nil {
+		t.Fatal("'regolith run --all' failed:", err.Error())
+	}
+	expectWorkspaceBuilds(
+		t, map[string]bool{"library": true, "addon": true, "other": true})
+	expectProjectLogs(t, logs, "library", "addon", "other")
+}
+
+func TestWorkspace(t *testing.T) {
+	testWorkspace(t, false)
+}
+
+func TestWorkspaceRecycled(t *testing.T) {
+	testWorkspace(t, true)
+}
+
+// TestWorkspaceDependencies checks the errors of the invalid dependencies of
+// the projects of a workspace.
+func TestWorkspaceDependencies(t *testing.T) {
+	project := func(dependsOn ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"path": ".", "dependsOn": dependsOn}
+	}
+	t.Log("Parsing a project with a missing dependency...")
+	_, err := regolith.WorkspaceFromObject(map[string]interface{}{
+		"projects": map[string]interface{}{"a": project("missing")},
+	}, ".")
+	if err == nil || !strings.Contains(err.Error(), "Dependency: missing") {
+		t.Error("Expected an error about the missing dependency, got:", err)
+	}
+
+	t.Log("Ordering the projects that depend on each other in a cycle...")
+	workspace, err := regolith.WorkspaceFromObject(map[string]interface{}{
+		"projects": map[string]interface{}{
+			"a": project("b"), "b": project("c"), "c": project("a"),
+			"d": project(),
+		},
+	}, ".")
+	if err != nil {
+		t.Fatal("Unable to parse the workspace:", err.Error())
+	}
+	_, err = workspace.ProjectOrder("d")
+	if err != nil {
+		t.Error("Unable to order the project without dependencies:", err)
+	}
+	_, err = workspace.ProjectOrder("")
+	if err == nil || !strings.Contains(err.Error(), "Cycle: a -> b -> c -> a") {
+		t.Error("Expected an error about the cycle, got:", err)
+	}
+}
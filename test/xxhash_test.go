@@ -0,0 +1,71 @@
+package test
+
+import (
+	"hash"
+	"testing"
+
+	"github.com/Bedrock-OSS/regolith/regolith"
+)
+
+// xxh64Vectors are the reference XXH64 hashes (with the seed 0) of the
+// inputs, from the reference implementation of the algorithm. The inputs
+// cover the data shorter than a block (32 bytes) and longer than a block.
+var xxh64Vectors = []struct {
+	input string
+	sum   uint64
+}{
+	{"", 0xef46db3751d8e999},
+	{"a", 0xd24ec4f1a98c6e5b},
+	{"abc", 0x44bc2cf5ad770999},
+	{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+}
+
+// TestXxh64 checks the hashes of the xxh64Vectors written at once.
+func TestXxh64(t *testing.T) {
+	for _, vector := range xxh64Vectors {
+		h := regolith.NewXxh64().(hash.Hash64)
+		h.Write([]byte(vector.input))
+		if sum := h.Sum64(); sum != vector.sum {
+			t.Errorf(
+				"Wrong hash of %q.\nExpected: %016x\nActual: %016x",
+				vector.input, vector.sum, sum)
+		}
+		expected := []byte{
+			byte(vector.sum >> 56), byte(vector.sum >> 48),
+			byte(vector.sum >> 40), byte(vector.sum >> 32),
+			byte(vector.sum >> 24), byte(vector.sum >> 16),
+			byte(vector.sum >> 8), byte(vector.sum)}
+		if sum := h.Sum(nil); string(sum) != string(expected) {
+			t.Errorf(
+				"Wrong bytes of the hash of %q.\nExpected: %x\nActual: %x",
+				vector.input, expected, sum)
+		}
+	}
+}
+
+// TestXxh64Chunked checks the hashes of the xxh64Vectors written in chunks
+// of every size, which tests completing the blocks from the previous
+// writes, and the hashes after resetting the hash.
+func TestXxh64Chunked(t *testing.T) {
+	h := regolith.NewXxh64().(hash.Hash64)
+	for _, vector := range xxh64Vectors {
+		for size := 1; size <= len(vector.input); size++ {
+			h.Reset()
+			input := []byte(vector.input)
+			for len(input) > 0 {
+				n := size
+				if n > len(input) {
+					n = len(input)
+				}
+				h.Write(input[:n])
+				input = input[n:]
+			}
+			if sum := h.Sum64(); sum != vector.sum {
+				t.Errorf(
+					"Wrong hash of %q written in chunks of %d bytes.\n"+
+						"Expected: %016x\nActual: %016x",
+					vector.input, size, vector.sum, sum)
+			}
+		}
+	}
+}